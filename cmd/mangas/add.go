@@ -2,11 +2,13 @@ package cmd
 
 import (
 	"fmt"
-	"log"
 	"strings"
 
 	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/log"
+	"github.com/kerbaras/mangas/pkg/services"
 	"github.com/kerbaras/mangas/pkg/sources"
+	"github.com/kerbaras/mangas/pkg/utils"
 	"github.com/spf13/cobra"
 )
 
@@ -17,24 +19,33 @@ var addCmd = &cobra.Command{
 	Args:  cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		query := strings.Join(args, " ")
-		source := sources.NewMangaDex()
+		source, err := sourceFromFlags(cmd)
+		cobra.CheckErr(err)
 		repo := data.NewDuckDBRepository()
 
-		fmt.Printf("🔍 Searching for '%s'...\n", query)
+		fmt.Printf("%s Searching for '%s'...\n", utils.IconSearch, query)
 
-		results, err := source.Search(query)
+		results, err := source.Search(query, sources.SearchOptions{})
 		if err != nil {
 			cobra.CheckErr(fmt.Errorf("search failed: %w", err))
 		}
 
 		if len(results) == 0 {
-			fmt.Println("❌ No results found.")
+			fmt.Println(utils.IconFailure, "No results found.")
 			return
 		}
 
 	// Take the first result
 	manga := results[0]
-	fmt.Printf("✅ Found: %s (ID: %s)\n", manga.Name, manga.ID)
+	fmt.Printf("%s Found: %s (ID: %s)\n", utils.IconSuccess, manga.Name, manga.ID)
+
+	// The same entry may already be rolled up under a manga from another source
+	if link, err := repo.FindMangaLink(manga.Source, manga.ID); err == nil && link != nil {
+		if linked, err := repo.GetManga(link.MangaID); err == nil && linked != nil {
+			fmt.Printf("%s Already in library, linked to '%s'\n", utils.IconInfo, linked.Name)
+			return
+		}
+	}
 
 	// Get chapters to count them
 	chapters, err := source.GetChapters(manga)
@@ -51,17 +62,34 @@ var addCmd = &cobra.Command{
 	for i := range chapters {
 		chapters[i].MangaID = manga.ID
 		if err := repo.SaveChapter(chapters[i]); err != nil {
-			log.Printf("Warning: Failed to save chapter %s: %v", chapters[i].Number, err)
+			log.Warn("failed to save chapter", "manga_id", manga.ID, "chapter", chapters[i].Number, "err", err)
 		}
 	}
 
-		fmt.Printf("✅ Added '%s' to library with %d chapters\n", manga.Name, len(chapters))
-		fmt.Printf("💡 To download chapters, use: mangas download \"%s\" --language en\n", manga.Name)
+		// Save related series (sequels, prequels, spin-offs), nice to have
+		controller := services.NewMangaController()
+		defer controller.Close()
+		if _, err := controller.RefreshRelations(manga); err != nil {
+			log.Warn("failed to refresh related series", "manga_id", manga.ID, "err", err)
+		}
+
+		fmt.Printf("%s Added '%s' to library with %d chapters\n", utils.IconSuccess, manga.Name, len(chapters))
+		if library, err := repo.ListMangas(); err == nil {
+			for _, duplicate := range services.FindDuplicates(library, manga) {
+				if mangaSource(duplicate) == mangaSource(manga) {
+					continue
+				}
+				fmt.Printf("%s '%s' is in the library from %s too, link them with: mangas link %s %s\n",
+					utils.IconWarning, duplicate.Name, mangaSource(duplicate), duplicate.ID, manga.ID)
+			}
+		}
+		fmt.Printf("%s To download chapters, use: mangas download \"%s\" --language en\n", utils.IconTip, manga.Name)
 	},
 }
 
 func init() {
 	addCmd.Flags().StringP("language", "l", "en", "Language of the manga")
+	addSourceFlag(addCmd)
 
 	rootCmd.AddCommand(addCmd)
 }
@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+// backupKeyEnv signs and verifies backups and exports when --key is not given
+const backupKeyEnv = "MANGAS_BACKUP_KEY"
+
+// backupDatabase is the name of the library database inside a backup
+const backupDatabase = "mangas.db"
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Back up and restore the library with verified checksums",
+	Long: `Back up the library database into a folder with a SHA256SUMS checksum
+manifest, so corruption picked up while syncing backups through cloud drives
+is caught before restoring. Collection exports get the same manifest.
+
+With --key (or the ` + backupKeyEnv + ` environment variable) the manifest
+is also signed, and verifying then requires the same key.`,
+}
+
+var backupCreateCmd = &cobra.Command{
+	Use:   "create [dir]",
+	Short: "Back up the library database",
+	Long:  "Back up the library database into dir (default: mangas-backup-<date> in the current folder)",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := "mangas-backup-" + time.Now().Format("20060102-150405")
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			cobra.CheckErr(fmt.Errorf("failed to create backup directory: %w", err))
+		}
+
+		if err := data.NewDuckDBRepository().Backup(filepath.Join(dir, backupDatabase)); err != nil {
+			cobra.CheckErr(fmt.Errorf("backup failed: %w", err))
+		}
+
+		key := backupKey(cmd)
+		count, err := integrations.WriteChecksums(dir, key)
+		cobra.CheckErr(err)
+
+		signed := ""
+		if len(key) > 0 {
+			signed = ", signed"
+		}
+		fmt.Printf("%s Backed up the library to %s (%d files checksummed%s)\n", utils.IconDisk, dir, count, signed)
+	},
+}
+
+var backupVerifyCmd = &cobra.Command{
+	Use:   "verify [dir]",
+	Short: "Check a backup or collection export against its checksums",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		report, err := verifyBackup(args[0], backupKey(cmd))
+		cobra.CheckErr(err)
+		fmt.Printf("%s %d files intact%s\n", utils.IconSuccess, report.Files, signatureStatus(report))
+	},
+}
+
+var backupRestoreCmd = &cobra.Command{
+	Use:   "restore [dir]",
+	Short: "Replace the library with a verified backup",
+	Long: `Verify a backup and replace the library database with it. The current
+database is kept next to it as mangas.db.<date>.bak.`,
+	Args: cobra.ExactArgs(1),
+	// The library must not be opened while its file is replaced
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := args[0]
+		report, err := verifyBackup(dir, backupKey(cmd))
+		cobra.CheckErr(err)
+
+		source := filepath.Join(dir, backupDatabase)
+		if _, err := os.Stat(source); err != nil {
+			cobra.CheckErr(fmt.Errorf("%s is not a library backup: %w", dir, err))
+		}
+
+		dbPath, err := data.DefaultDBPath()
+		cobra.CheckErr(err)
+		kept, err := moveAsideDatabase(dbPath)
+		cobra.CheckErr(err)
+		if err := copyFile(source, dbPath); err != nil {
+			cobra.CheckErr(fmt.Errorf("restore failed: %w", err))
+		}
+
+		fmt.Printf("%s Restored the library from %s (%d files intact%s)\n", utils.IconRestore, dir, report.Files, signatureStatus(report))
+		if kept != "" {
+			fmt.Println("   Previous library kept as", kept)
+		}
+	},
+}
+
+// backupKey returns the signing key from --key or the environment
+func backupKey(cmd *cobra.Command) []byte {
+	if key, _ := cmd.Flags().GetString("key"); key != "" {
+		return []byte(key)
+	}
+	return []byte(os.Getenv(backupKeyEnv))
+}
+
+// verifyBackup checks dir against its checksums, failing on any missing or
+// corrupt file. Files added after the backup are reported but tolerated.
+func verifyBackup(dir string, key []byte) (*integrations.ChecksumReport, error) {
+	report, err := integrations.VerifyChecksums(dir, key)
+	if err != nil {
+		return nil, fmt.Errorf("verification failed: %w", err)
+	}
+
+	for _, file := range report.Unlisted {
+		fmt.Printf("  ? %s (not in checksums)\n", file)
+	}
+	for _, file := range report.Missing {
+		fmt.Printf("  %s %s is missing\n", utils.IconCross, file)
+	}
+	for _, file := range report.Corrupt {
+		fmt.Printf("  %s %s is corrupt\n", utils.IconCross, file)
+	}
+	if !report.OK() {
+		return nil, fmt.Errorf("verification failed: %d missing and %d corrupt files", len(report.Missing), len(report.Corrupt))
+	}
+	return report, nil
+}
+
+func signatureStatus(report *integrations.ChecksumReport) string {
+	switch {
+	case report.Verified:
+		return ", signature verified"
+	case report.Signed:
+		return ", signature not checked without a key"
+	}
+	return ""
+}
+
+// moveAsideDatabase renames the database at path, and its write-ahead log,
+// out of the way and returns the new name of the database, if there was one
+func moveAsideDatabase(path string) (string, error) {
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return "", os.MkdirAll(filepath.Dir(path), 0755)
+	}
+
+	kept := fmt.Sprintf("%s.%s.bak", path, time.Now().Format("20060102-150405"))
+	if err := os.Rename(path, kept); err != nil {
+		return "", fmt.Errorf("failed to keep the current library: %w", err)
+	}
+	// A leftover log would be replayed onto the restored database
+	if err := os.Rename(path+".wal", kept+".wal"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("failed to keep the current library log: %w", err)
+	}
+	return kept, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{backupCreateCmd, backupVerifyCmd, backupRestoreCmd} {
+		cmd.Flags().String("key", "", "Key signing the checksums (default: $"+backupKeyEnv+")")
+	}
+
+	backupCmd.AddCommand(backupCreateCmd, backupVerifyCmd, backupRestoreCmd)
+	rootCmd.AddCommand(backupCmd)
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var batchCmd = &cobra.Command{
+	Use:   "batch [file|-]",
+	Short: "Download the manga listed in a YAML or JSON file",
+	Long: `Download, one after the other and without prompting, the manga listed in
+a YAML or JSON file ("-" reads it from stdin), then print a summary. Handy to
+seed a new library or on a headless server:
+
+  - manga: Naruto            # Library name or ID, or ID on the source
+    chapters: 1-10           # Every chapter when left out
+  - manga: a1b2c3d4
+    source: mangadex         # Source of an ID not in the library
+    language: es             # en when left out
+    format: cbz              # epub (default) or cbz
+  - manga: One Piece
+    bundle: volume           # One EPUB per chapter (default) or volume
+
+The entries can also be listed under a "mangas" key. An entry failing does
+not stop the batch; the command exits with an error when any did. Chapters
+already downloaded are skipped, so a batch can be run again to resume it.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		entries, err := readBatchArg(args[0])
+		cobra.CheckErr(err)
+		cbzDir, _ := cmd.Flags().GetString("cbz-dir")
+		force, _ := cmd.Flags().GetBool("force")
+
+		options, err := downloaderOptionsFromFlags(cmd)
+		cobra.CheckErr(err)
+		controller, err := services.NewMangaControllerWithConfig(services.ControllerConfig{
+			SourceType: "mangadex",
+			Downloader: &options,
+		})
+		cobra.CheckErr(err)
+		defer controller.Close()
+
+		bars := progressBarsFromFlags(cmd)
+		updates := controller.GetProgressChannel()
+		go func() {
+			for progress := range updates {
+				bars.update(progress)
+			}
+		}()
+
+		ctx, stop := interruptContext()
+		defer stop()
+		controller.SetContext(ctx)
+		report := controller.RunBatch(entries, services.BatchOptions{
+			CBZDir: cbzDir,
+			Force:  force,
+			OnStart: func(entry services.BatchEntry) {
+				bars.printf("\n%s %s\n", utils.IconDownload, entry.Manga)
+			},
+		})
+
+		bars.finish()
+		fmt.Printf("\n%s Batch summary\n", utils.IconList)
+		for _, result := range report.Results {
+			name := result.Entry.Manga
+			if result.Manga != nil {
+				name = result.Manga.Name
+			}
+			switch {
+			case result.Err != nil:
+				fmt.Printf("  %s %s: %v\n", utils.IconCross, name, result.Err)
+			case result.Failed > 0:
+				fmt.Printf("  %s %s: %d downloaded, %d failed\n", utils.IconWarning, name, result.Downloaded, result.Failed)
+			default:
+				fmt.Printf("  %s %s: %d downloaded\n", utils.IconCheck, name, result.Downloaded)
+			}
+		}
+		fmt.Printf("\n%d chapters downloaded, %d failed, %d of %d entries failed\n",
+			report.Downloaded, report.Failed, report.Errors, len(report.Results))
+		if report.Interrupted {
+			fmt.Printf("%s Interrupted, run the batch again to download the rest\n", utils.IconStop)
+			return
+		}
+		if report.Errors > 0 || report.Failed > 0 {
+			cobra.CheckErr(fmt.Errorf("batch finished with failures"))
+		}
+	},
+}
+
+// readBatchArg reads the batch file in path, "-" for stdin
+func readBatchArg(path string) ([]services.BatchEntry, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		r = file
+	}
+	return services.ReadBatch(r)
+}
+
+func init() {
+	batchCmd.Flags().String("cbz-dir", ".", "Directory the CBZ of cbz entries are written to")
+	batchCmd.Flags().Bool("force", false, "Download chapters again even if they are already downloaded")
+	addDownloaderFlags(batchCmd)
+	addPlainFlag(batchCmd)
+
+	rootCmd.AddCommand(batchCmd)
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/sources"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var blocklistCmd = &cobra.Command{
+	Use:   "blocklist",
+	Short: "Hide chapters of scanlation groups and uploaders",
+	Long: `Block scanlation groups and uploaders so their chapters are left out of
+chapter lists and downloads.
+
+Groups can be blocked by MangaDex ID or by name, uploaders by MangaDex ID.
+Lists shared by others can be imported from a file or URL with one entry per
+line:
+
+  # Comments start with #
+  group 5fed0576-8b94-4f9a-b6a7-08eecd69800d  # Example Scans
+  uploader 2c0b6b6a-0bc5-4c39-9b4c-1b2b2b2b2b2b
+  Lazy Scans`,
+}
+
+var blocklistListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List blocked groups and uploaders",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		entries, err := data.NewDuckDBRepository().GetBlocklist()
+		cobra.CheckErr(err)
+
+		if len(entries) == 0 {
+			fmt.Println(utils.IconBlocked, "Blocklist is empty")
+			return
+		}
+		fmt.Printf("%s Blocklist (%d entries)\n", utils.IconBlocked, len(entries))
+		for _, entry := range entries {
+			line := fmt.Sprintf("  %s %-8s %s", utils.IconBullet, entry.Kind, entry.Value)
+			if entry.Note != "" {
+				line += "  # " + entry.Note
+			}
+			fmt.Println(line)
+		}
+	},
+}
+
+var blocklistAddCmd = &cobra.Command{
+	Use:   "add [group-id-or-name]",
+	Short: "Block a scanlation group, or an uploader with --uploader",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		note, _ := cmd.Flags().GetString("note")
+		entry := data.BlockedGroup{Kind: blocklistKind(cmd), Value: strings.TrimSpace(args[0]), Note: note}
+		cobra.CheckErr(sources.ValidateBlockedGroup(entry))
+
+		added, err := data.NewDuckDBRepository().AddToBlocklist(entry)
+		cobra.CheckErr(err)
+		if added == 0 {
+			fmt.Printf("%s %s %s is already blocked\n", utils.IconBlocked, entry.Kind, entry.Value)
+			return
+		}
+		fmt.Printf("%s Blocked %s %s\n", utils.IconBlocked, entry.Kind, entry.Value)
+	},
+}
+
+var blocklistRemoveCmd = &cobra.Command{
+	Use:   "remove [group-id-or-name]",
+	Short: "Unblock a scanlation group, or an uploader with --uploader",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		kind := blocklistKind(cmd)
+		removed, err := data.NewDuckDBRepository().RemoveFromBlocklist(kind, args[0])
+		cobra.CheckErr(err)
+		if !removed {
+			cobra.CheckErr(fmt.Errorf("%s %s is not blocked", kind, args[0]))
+		}
+		fmt.Printf("%s Unblocked %s %s\n", utils.IconSuccess, kind, args[0])
+	},
+}
+
+var blocklistImportCmd = &cobra.Command{
+	Use:   "import [file-or-url]",
+	Short: "Import a blocklist from a file or an http(s) URL",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		reader, err := openBlocklist(args[0])
+		cobra.CheckErr(err)
+		defer reader.Close()
+
+		entries, err := sources.ParseBlocklist(reader)
+		cobra.CheckErr(err)
+
+		added, err := data.NewDuckDBRepository().AddToBlocklist(entries...)
+		cobra.CheckErr(err)
+		fmt.Printf("%s Imported %d entries (%d already blocked)\n", utils.IconBlocked, added, len(entries)-added)
+	},
+}
+
+// blocklistKind returns the entry kind selected with --uploader
+func blocklistKind(cmd *cobra.Command) string {
+	if uploader, _ := cmd.Flags().GetBool("uploader"); uploader {
+		return data.BlockUploader
+	}
+	return data.BlockGroup
+}
+
+// openBlocklist opens a blocklist file, or downloads it when given a URL
+func openBlocklist(location string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		return os.Open(location)
+	}
+
+	resp, err := utils.HTTPClient("").Get(location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download blocklist: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download blocklist: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// applyBlocklist loads the stored blocklist into the sources
+func applyBlocklist() {
+	entries, err := data.NewDuckDBRepository().GetBlocklist()
+	if err != nil || len(entries) == 0 {
+		return
+	}
+	sources.SetBlocklist(sources.NewBlocklist(entries))
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{blocklistAddCmd, blocklistRemoveCmd} {
+		cmd.Flags().Bool("uploader", false, "The value is an uploader ID instead of a group")
+	}
+	blocklistAddCmd.Flags().String("note", "", "Why the group is blocked")
+
+	blocklistCmd.AddCommand(blocklistListCmd, blocklistAddCmd, blocklistRemoveCmd, blocklistImportCmd)
+	rootCmd.AddCommand(blocklistCmd)
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage cached files",
+	Long: `Manga covers are cached in ~/.mangas/covers, so they are downloaded once
+for the library, the TUI and every chapter instead of each time. A cover is
+revalidated with the source once its max-age (24 hours unless the source says
+otherwise) runs out.
+
+Source responses are cached in ~/.mangas/cache/api: searches for an hour,
+manga details for a day and chapter lists for 15 minutes. --no-cache asks the
+sources every time, --offline only uses the cache, expired responses included.`,
+}
+
+var cacheCleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Remove cached covers and source responses",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		expired, _ := cmd.Flags().GetBool("expired")
+		cache := services.NewCoverCache(services.DefaultCoverCacheDir())
+		removed, freed, err := cache.Clean(expired)
+		cobra.CheckErr(err)
+		responses := utils.NewAPICache(utils.DefaultResponseCacheDir())
+		removedResponses, freedResponses, err := responses.Clean(expired)
+		cobra.CheckErr(err)
+
+		if removed == 0 && removedResponses == 0 {
+			fmt.Println(utils.IconClean, "Nothing to clean")
+			return
+		}
+		if removed > 0 {
+			fmt.Printf("%s Removed %d covers from %s (%s freed)\n", utils.IconClean, removed, cache.Dir(), services.FormatBytes(freed))
+		}
+		if removedResponses > 0 {
+			fmt.Printf("%s Removed %d responses from %s (%s freed)\n", utils.IconClean, removedResponses, responses.Dir(), services.FormatBytes(freedResponses))
+		}
+	},
+}
+
+func init() {
+	cacheCleanCmd.Flags().Bool("expired", false, "Only remove covers and responses past their max-age")
+
+	cacheCmd.AddCommand(cacheCleanCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
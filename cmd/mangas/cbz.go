@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var cbzCmd = &cobra.Command{
+	Use:   "cbz [manga-name]",
+	Short: "Export downloaded chapters as CBZ archives",
+	Long: `Export downloaded chapters as CBZ archives (one per chapter) with ComicInfo.xml metadata.
+
+Examples:
+  mangas cbz "One Piece" --chapters 1,2,3
+  mangas cbz --collection Favorites --output ~/comics`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		chapters, _ := cmd.Flags().GetString("chapters")
+		output, _ := cmd.Flags().GetString("output")
+		collection, _ := cmd.Flags().GetString("collection")
+		webtoon, err := webtoonOptionsFromFlags(cmd)
+		cobra.CheckErr(err)
+		options := integrations.CBZOptions{Webtoon: webtoon}
+
+		if collection != "" {
+			if output == "" {
+				output = sanitizeFilename(collection) + "_cbz"
+			}
+			fmt.Printf("%s Exporting collection '%s' to %s\n", utils.IconPackage, collection, output)
+			manifest, err := exportCollection(os.Stdout, collection, "cbz", output, func(manga *data.Manga, chapters []*data.Chapter, seriesDir string) ([]string, error) {
+				return exportCBZSeries(manga, chapters, seriesDir, options)
+			})
+			if err != nil {
+				cobra.CheckErr(fmt.Errorf("export failed: %w", err))
+			}
+			fmt.Printf("%s Exported %d series, manifest: %s\n", utils.IconSuccess, len(manifest.Series), filepath.Join(output, integrations.ManifestFilename))
+			return
+		}
+
+		if len(args) == 0 {
+			cobra.CheckErr(fmt.Errorf("manga name or --collection is required"))
+		}
+
+		controller := services.NewMangaController()
+		defer controller.Close()
+
+		manga, err := findLibraryManga(os.Stdout, controller, args[0])
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("manga not found in library: %w", err))
+		}
+
+		allChapters, err := controller.GetChaptersFromLibrary(manga.ID)
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("failed to get chapters: %w", err))
+		}
+
+		selected, err := parseChapterSelection(chapters, allChapters)
+		cobra.CheckErr(err)
+		if len(selected) == 0 {
+			cobra.CheckErr(fmt.Errorf("no downloaded chapters found matching the selection"))
+		}
+
+		if output == "" {
+			output = sanitizeFilename(manga.Name) + "_cbz"
+		}
+
+		files, err := exportCBZSeries(manga, selected, output, options)
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("export failed: %w", err))
+		}
+		fmt.Printf("%s Exported %d chapter(s) to %s\n", utils.IconSuccess, len(files), output)
+	},
+}
+
+// exportCBZSeries writes one CBZ per chapter into dir
+func exportCBZSeries(manga *data.Manga, chapters []*data.Chapter, dir string, options integrations.CBZOptions) ([]string, error) {
+	var files []string
+	// Chapters of a volume, or merged, share a book that is exported once
+	seen := make(map[string]bool)
+	for _, ch := range chapters {
+		if ch.FilePath != "" {
+			if seen[ch.FilePath] {
+				continue
+			}
+			seen[ch.FilePath] = true
+		}
+		path := filepath.Join(dir, integrations.CBZFilename(manga, ch))
+		if err := integrations.ExportCBZWithOptions(manga, ch, path, options); err != nil {
+			return files, fmt.Errorf("chapter %s: %w", ch.Number, err)
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+func init() {
+	cbzCmd.Flags().StringP("chapters", "c", "", chapterSelectionHelp)
+	cbzCmd.Flags().StringP("output", "o", "", "Output directory (default: <manga-name>_cbz)")
+	cbzCmd.Flags().String("collection", "", "Export every downloaded chapter of a collection")
+	addWebtoonFlags(cbzCmd)
+
+	rootCmd.AddCommand(cbzCmd)
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var collectionCmd = &cobra.Command{
+	Use:   "collection",
+	Short: "Group library manga into named collections",
+	Long: `Group library manga into named collections such as "Favorites".
+
+Collections can be exported at once with 'mangas kindle --collection' or
+'mangas cbz --collection'.`,
+}
+
+var collectionAddCmd = &cobra.Command{
+	Use:   "add [collection] [manga-name]",
+	Short: "Add a manga from your library to a collection",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		controller := services.NewMangaController()
+		defer controller.Close()
+
+		manga, err := findLibraryManga(os.Stdout, controller, args[1])
+		cobra.CheckErr(err)
+
+		repo := data.NewDuckDBRepository()
+		cobra.CheckErr(repo.AddToCollection(args[0], manga.ID))
+		fmt.Printf("%s Added '%s' to %s\n", utils.IconSuccess, manga.Name, args[0])
+	},
+}
+
+var collectionRemoveCmd = &cobra.Command{
+	Use:   "remove [collection] [manga-name]",
+	Short: "Remove a manga from a collection",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		controller := services.NewMangaController()
+		defer controller.Close()
+
+		manga, err := findLibraryManga(os.Stdout, controller, args[1])
+		cobra.CheckErr(err)
+
+		repo := data.NewDuckDBRepository()
+		cobra.CheckErr(repo.RemoveFromCollection(args[0], manga.ID))
+		fmt.Printf("%s Removed '%s' from %s\n", utils.IconDelete, manga.Name, args[0])
+	},
+}
+
+var collectionListCmd = &cobra.Command{
+	Use:   "list [collection]",
+	Short: "List collections, or the manga in one collection",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := data.NewDuckDBRepository()
+
+		if len(args) == 1 {
+			mangas, err := repo.GetCollection(args[0])
+			cobra.CheckErr(err)
+			if len(mangas) == 0 {
+				fmt.Printf("%s Collection '%s' is empty\n", utils.IconLibrary, args[0])
+				return
+			}
+			fmt.Printf("%s %s (%d manga)\n", utils.IconLibrary, args[0], len(mangas))
+			for _, manga := range mangas {
+				fmt.Printf("  %s %s\n", utils.IconBullet, manga.Name)
+			}
+			return
+		}
+
+		collections, err := repo.ListCollections()
+		cobra.CheckErr(err)
+		if len(collections) == 0 {
+			fmt.Println(utils.IconLibrary, "No collections yet. Use 'mangas collection add <collection> <manga>' to create one.")
+			return
+		}
+		for _, name := range collections {
+			mangas, _ := repo.GetCollection(name)
+			fmt.Printf("  %s %s (%d manga)\n", utils.IconBullet, name, len(mangas))
+		}
+	},
+}
+
+// seriesExporter exports the downloaded chapters of one manga into seriesDir
+// and returns the created files
+type seriesExporter func(manga *data.Manga, chapters []*data.Chapter, seriesDir string) ([]string, error)
+
+// exportCollection exports every downloaded chapter of the manga in a
+// collection into per-series folders under outputDir and writes a manifest
+// and checksums. A failing series is recorded in the manifest and does not
+// stop the others. Progress messages are printed to out.
+func exportCollection(out io.Writer, collection, format, outputDir string, export seriesExporter) (*integrations.ExportManifest, error) {
+	repo := data.NewDuckDBRepository()
+	defer repo.Close()
+
+	mangas, err := repo.GetCollection(collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection: %w", err)
+	}
+	if len(mangas) == 0 {
+		return nil, fmt.Errorf("collection '%s' is empty or does not exist", collection)
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	manifest := &integrations.ExportManifest{
+		Collection: collection,
+		Format:     format,
+		CreatedAt:  time.Now(),
+	}
+
+	for _, manga := range mangas {
+		allChapters, err := repo.GetChapters(manga.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get chapters of %s: %w", manga.Name, err)
+		}
+
+		var chapters []*data.Chapter
+		for _, ch := range allChapters {
+			if ch.Downloaded && ch.FilePath != "" {
+				chapters = append(chapters, ch)
+			}
+		}
+		if len(chapters) == 0 {
+			fmt.Fprintf(out, "  %s %s: no downloaded chapters\n", utils.IconSkip, manga.Name)
+			continue
+		}
+
+		folder := integrations.SeriesFolder(manga.Name)
+		series := integrations.ManifestSeries{
+			MangaID: manga.ID,
+			Name:    manga.Name,
+			Folder:  folder,
+		}
+		for _, ch := range chapters {
+			series.Chapters = append(series.Chapters, ch.Number)
+		}
+
+		fmt.Fprintf(out, "  %s %s: exporting %d chapter(s)...\n", utils.IconBook, manga.Name, len(chapters))
+		seriesDir, err := utils.SafeJoin(outputDir, folder)
+		var files []string
+		if err == nil {
+			files, err = export(manga, chapters, seriesDir)
+		}
+		if err != nil {
+			fmt.Fprintf(out, "  %s %s: %v\n", utils.IconCross, manga.Name, err)
+			series.Error = err.Error()
+		}
+		for _, file := range files {
+			if rel, err := filepath.Rel(outputDir, file); err == nil {
+				file = filepath.ToSlash(rel)
+			}
+			series.Files = append(series.Files, file)
+		}
+
+		manifest.Series = append(manifest.Series, series)
+	}
+
+	if _, err := manifest.Write(outputDir); err != nil {
+		return nil, err
+	}
+	// Lets `mangas backup verify` catch exports corrupted while syncing
+	if _, err := integrations.WriteChecksums(outputDir, []byte(os.Getenv(backupKeyEnv))); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func init() {
+	collectionCmd.AddCommand(collectionAddCmd)
+	collectionCmd.AddCommand(collectionRemoveCmd)
+	collectionCmd.AddCommand(collectionListCmd)
+
+	rootCmd.AddCommand(collectionCmd)
+}
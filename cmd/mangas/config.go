@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kerbaras/mangas/pkg/app/keys"
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/log"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show or change settings",
+	Long: `Show the settings and their values, or change them with 'mangas config set'.
+
+Settings:
+  default_device  Kindle device profile used by 'mangas kindle' when --device
+                  is omitted and no Kindle model is detected`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := data.NewDuckDBRepository()
+		for _, key := range services.ConfigKeys() {
+			value, err := services.GetConfig(repo, key.Name)
+			cobra.CheckErr(err)
+			if value == "" {
+				value = "(unset)"
+			}
+			fmt.Printf("%-16s %s\n", key.Name, value)
+			fmt.Printf("%-16s %s\n", "", key.Description)
+		}
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get [key]",
+	Short: "Print the value of a setting",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		value, err := services.GetConfig(data.NewDuckDBRepository(), args[0])
+		cobra.CheckErr(err)
+		fmt.Println(value)
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set [key] [value]",
+	Short: "Change a setting, e.g. 'config set default_device kindle-oasis3'",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(services.SetConfig(data.NewDuckDBRepository(), args[0], args[1]))
+		fmt.Printf("%s %s set to %s\n", utils.IconSuccess, args[0], args[1])
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset [key]",
+	Short: "Clear a setting",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(services.UnsetConfig(data.NewDuckDBRepository(), args[0]))
+		fmt.Printf("%s %s unset\n", utils.IconSuccess, args[0])
+	},
+}
+
+// applyIcons switches to the icon set chosen with the icons setting, unless
+// MANGAS_ICONS already chose one
+func applyIcons() {
+	if os.Getenv(utils.IconsEnv) != "" {
+		return
+	}
+	name, err := services.LoadIconSet(data.NewDuckDBRepository())
+	if err == nil {
+		err = utils.SetIconSet(name)
+	}
+	if err != nil {
+		log.Warn("failed to load the icons setting", "err", err)
+	}
+}
+
+// applyKeys switches the TUI to the keys chosen with the key_style and
+// keybindings settings
+func applyKeys() {
+	style, overrides, err := services.LoadKeyBindings(data.NewDuckDBRepository())
+	if err == nil {
+		err = keys.Apply(style, overrides)
+	}
+	if err != nil {
+		log.Warn("failed to load the key bindings", "err", err)
+	}
+}
+
+func init() {
+	services.SetConfigValidator(services.KeyStyleKey, keys.ValidateStyle)
+	services.SetConfigValidator(services.KeyBindingsKey, keys.ValidateOverrides)
+
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var contactSheetCmd = &cobra.Command{
+	Use:   "contact-sheet [manga-name]",
+	Short: "Generate a thumbnail grid of a chapter's pages",
+	Long: `Generate a single contact-sheet image with numbered thumbnails of every page
+of a chapter, useful for checking scan quality or finding a remembered scene.
+Downloaded chapters are read from their EPUB, others are fetched from the source.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		chapterNumber, _ := cmd.Flags().GetString("chapter")
+		language, _ := cmd.Flags().GetString("language")
+		output, _ := cmd.Flags().GetString("output")
+		columns, _ := cmd.Flags().GetInt("columns")
+		width, _ := cmd.Flags().GetInt("width")
+
+		if chapterNumber == "" {
+			cobra.CheckErr(fmt.Errorf("--chapter is required"))
+		}
+
+		controller := services.NewMangaController()
+		defer controller.Close()
+
+		manga, err := findLibraryManga(os.Stdout, controller, args[0])
+		cobra.CheckErr(err)
+
+		chapter, err := findLibraryChapter(controller, manga, chapterNumber, language)
+		cobra.CheckErr(err)
+
+		fmt.Printf("%s Collecting pages of %s chapter %s...\n", utils.IconImage, manga.Name, chapter.Number)
+		pages, err := controller.ChapterPages(manga, chapter)
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("failed to get pages: %w", err))
+		}
+
+		options := integrations.DefaultContactSheetOptions()
+		options.Columns = columns
+		options.ThumbWidth = width
+
+		sheet, err := integrations.BuildContactSheet(pages, options)
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("failed to build contact sheet: %w", err))
+		}
+
+		if output == "" {
+			output = integrations.ContactSheetFilename(manga, chapter)
+		}
+		cobra.CheckErr(utils.CheckOutputPath(output))
+		if err := os.WriteFile(output, sheet, 0644); err != nil {
+			cobra.CheckErr(fmt.Errorf("failed to write contact sheet: %w", err))
+		}
+
+		fmt.Printf("%s Contact sheet with %d pages saved to: %s\n", utils.IconSuccess, len(pages), output)
+	},
+}
+
+func init() {
+	defaults := integrations.DefaultContactSheetOptions()
+	contactSheetCmd.Flags().StringP("chapter", "c", "", "Chapter number (required)")
+	contactSheetCmd.Flags().StringP("language", "l", "en", "Language code (e.g., en, ja, es)")
+	contactSheetCmd.Flags().StringP("output", "o", "", "Output image path (default: <manga>_ch_<n>_contact.jpg)")
+	contactSheetCmd.Flags().Int("columns", defaults.Columns, "Thumbnails per row")
+	contactSheetCmd.Flags().Int("width", defaults.ThumbWidth, "Thumbnail width in pixels")
+
+	rootCmd.AddCommand(contactSheetCmd)
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var coverCmd = &cobra.Command{
+	Use:   "cover [manga-name] [path-or-url]",
+	Short: "Use your own cover for a manga",
+	Long: `Replace the cover a source gives a manga with an image of your own, a
+local file or a URL. The custom cover is shown in the TUI and used for EPUB
+exports and Kindle conversions:
+
+  mangas cover "Naruto"                      show the cover in use
+  mangas cover "Naruto" ~/covers/naruto.png  set a custom cover
+  mangas cover "Naruto" --reset              go back to the source cover
+
+The image is copied to the cover cache, so exports keep working if the file
+is moved. Custom covers are kept when the library is updated.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		controller := services.NewMangaController()
+		defer controller.Close()
+
+		manga, err := findLibraryManga(os.Stdout, controller, args[0])
+		cobra.CheckErr(err)
+
+		repo := data.NewDuckDBRepository()
+		reset, _ := cmd.Flags().GetBool("reset")
+		switch {
+		case reset && len(args) == 1:
+			cobra.CheckErr(repo.SetCustomCover(manga.ID, ""))
+			fmt.Printf("%s %s uses the cover of its source again\n", utils.IconImage, manga.Name)
+		case !reset && len(args) == 2:
+			covers := services.NewCoverCache(services.DefaultCoverCacheDir())
+			cover, err := services.LoadCustomCover(covers, manga, args[1])
+			cobra.CheckErr(err)
+			cobra.CheckErr(repo.SetCustomCover(manga.ID, cover))
+			fmt.Printf("%s %s now uses %s as cover\n", utils.IconSuccess, manga.Name, cover)
+		case !reset:
+			if manga.CustomCover != "" {
+				fmt.Printf("Custom cover: %s\n", manga.CustomCover)
+			} else if manga.CoverURL != "" {
+				fmt.Printf("Source cover: %s\n", manga.CoverURL)
+			} else {
+				fmt.Println("Source cover")
+			}
+		default:
+			cobra.CheckErr(fmt.Errorf("give either a cover or --reset"))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(coverCmd)
+	coverCmd.Flags().Bool("reset", false, "Drop the custom cover and use the source one")
+}
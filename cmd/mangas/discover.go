@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var discoverCmd = &cobra.Command{
+	Use:   "discover",
+	Short: "Discover manga similar to your library",
+	Long:  "Recommend popular manga sharing tags with your most-read library entries, excluding titles already in your library",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		controller := services.NewMangaController()
+		defer controller.Close()
+
+		fmt.Println(utils.IconSearch, "Looking for manga you might like...")
+
+		results, err := controller.Discover(limit)
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("discover failed: %w", err))
+		}
+
+		if len(results) == 0 {
+			fmt.Println("No recommendations found.")
+			return
+		}
+
+		var (
+			purple = lipgloss.Color("99")
+
+			headerStyle = lipgloss.NewStyle().Foreground(purple).Bold(true).Align(lipgloss.Center)
+			cellStyle   = lipgloss.NewStyle().Padding(0, 1)
+		)
+
+		t := table.New().
+			Border(lipgloss.HiddenBorder()).
+			StyleFunc(func(row, col int) lipgloss.Style {
+				if row == table.HeaderRow {
+					return headerStyle
+				}
+				return cellStyle
+			}).
+			Headers("#", "Name", "ID")
+
+		for i, manga := range results {
+			t.Row(fmt.Sprintf("%d", i+1), truncateString(manga.Name, 58), manga.ID)
+		}
+
+		fmt.Println(t)
+		fmt.Println(utils.IconTip, "To add one to your library, use: mangas add \"<name>\"")
+	},
+}
+
+func init() {
+	discoverCmd.Flags().IntP("limit", "n", 10, "Maximum number of recommendations")
+
+	rootCmd.AddCommand(discoverCmd)
+}
@@ -1,15 +1,19 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
-	"strconv"
 	"strings"
 
+	"github.com/charmbracelet/x/term"
+	"github.com/kerbaras/mangas/pkg/chapterselect"
 	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
 	"github.com/kerbaras/mangas/pkg/services"
 	"github.com/kerbaras/mangas/pkg/sources"
+	"github.com/kerbaras/mangas/pkg/utils"
 	"github.com/spf13/cobra"
 )
 
@@ -20,101 +24,249 @@ var downloadCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		mangaIdentifier := args[0]
-		language, _ := cmd.Flags().GetString("language")
+		fallbackLanguages, _ := cmd.Flags().GetStringSlice("fallback-language")
 		chaptersFlag, _ := cmd.Flags().GetString("chapters")
+		openSource, _ := cmd.Flags().GetBool("open-source")
+		bundleFlag, _ := cmd.Flags().GetString("bundle")
+		progressStream, out := progressJSONFromFlags(cmd)
+		_, err := services.ParseBundleMode(bundleFlag)
+		progressStream.check(err)
 
 		repo := data.NewDuckDBRepository()
-		source := sources.NewMangaDex()
+		source, err := sourceFromFlags(cmd)
+		progressStream.check(err)
 
-		homeDir, _ := os.UserHomeDir()
-		downloadDir := filepath.Join(homeDir, ".mangas", "downloads")
+		downloadDir := services.DefaultDownloadDir()
 
-		downloader := services.NewDownloader(source, repo, downloadDir)
+		options, err := downloaderOptionsFromFlags(cmd)
+		progressStream.check(err)
+
+		downloader := services.NewDownloaderWithOptions(source, repo, downloadDir, options)
 		defer downloader.Close()
+		archivePasswords, _ := cmd.Flags().GetStringSlice("archive-password")
+		downloader.SetArchivePasswords(archivePasswords...)
+		downloader.SetChecksumStore(repo)
+		downloader.SetMangaSettings(repo)
+		downloader.SetQuotaStore(repo)
+		evicted := &evictions{out: out}
+		downloader.OnEviction(evicted.add)
+		downloader.SetThumbnailCache(services.NewThumbnailCache(services.DefaultThumbnailDir()))
+		templates, err := services.LoadPathTemplates(repo)
+		progressStream.check(err)
+		downloader.SetPathTemplates(templates)
+		imageCheckFlag, _ := cmd.Flags().GetString("verify-images")
+		imageCheck, err := integrations.ParseImageCheck(imageCheckFlag)
+		progressStream.check(err)
+		downloader.SetImageCheck(imageCheck)
+		webtoon, err := webtoonOptionsFromFlags(cmd)
+		progressStream.check(err)
+		downloader.SetWebtoon(webtoon)
+		ocr, err := ocrOptionsFromFlags(cmd)
+		progressStream.check(err)
+		downloader.SetOCR(ocr)
+		altText, err := altTextFromFlags(cmd)
+		progressStream.check(err)
+		downloader.SetAltText(altText)
+		downloader.SetPreprocess(preprocessFromFlags(cmd, integrations.PreprocessOptions{}))
+		transcode, err := services.LoadTranscodePages(repo)
+		progressStream.check(err)
+		if cmd.Flags().Changed("transcode") {
+			transcode, _ = cmd.Flags().GetBool("transcode")
+		}
+		downloader.SetTranscode(transcode)
+		hooks, err := services.LoadWebhooks(repo)
+		progressStream.check(err)
+		downloader.SetNotifier(services.NewNotifier(hooks))
 
 		// Try to find manga by name in library first
 		var manga *data.Manga
+		var matches []*data.Manga
 		mangas, _ := repo.ListMangas()
 		for _, m := range mangas {
 			if strings.EqualFold(m.Name, mangaIdentifier) {
-				manga = m
-				fmt.Printf("📚 Found '%s' in library\n", m.Name)
-				break
+				matches = append(matches, m)
+			}
+		}
+		switch {
+		case len(matches) == 1:
+			manga = matches[0]
+		case len(matches) > 1:
+			ambiguous := &services.AmbiguousMangaError{Name: mangaIdentifier, Candidates: matches, Exact: true}
+			if !term.IsTerminal(os.Stdin.Fd()) {
+				progressStream.check(ambiguous)
 			}
+			manga, err = pickManga(out, ambiguous)
+			progressStream.check(err)
+		}
+		if manga != nil {
+			fmt.Fprintf(out, "%s Found '%s' in library\n", utils.IconLibrary, manga.Name)
+		}
+
+		// Library entries are fetched from the source they were added from
+		if manga != nil {
+			source = sources.ForManga(manga, source)
 		}
 
 		// If not found in library, fetch from source
 		if manga == nil {
-			var err error
 			manga, err = source.GetManga(mangaIdentifier)
 			if err != nil {
-				cobra.CheckErr(fmt.Errorf("manga not found: %w", err))
+				progressStream.check(fmt.Errorf("manga not found: %w", err))
 			}
-			fmt.Printf("🔍 Found manga: %s (ID: %s)\n", manga.Name, manga.ID)
+			fmt.Fprintf(out, "%s Found manga: %s (ID: %s)\n", utils.IconSearch, manga.Name, manga.ID)
 		}
 
-		// Get chapters from source
-		chapters, err := source.GetChapters(manga)
+		// The manga's settings stand in for the flags left unset
+		settings, err := repo.GetMangaSettings(manga.ID)
+		progressStream.check(err)
+		language := flagOrSetting(cmd, "language", settings.Language)
+		bundleMode, err := services.ParseBundleMode(flagOrSetting(cmd, "bundle", settings.BundleMode))
+		progressStream.check(err)
+		if settings.Format == services.BatchCBZ && bundleMode != services.BundleChapter {
+			progressStream.check(fmt.Errorf("%s is set to cbz, written per chapter, it can't be bundled by %s", manga.Name, bundleMode))
+		}
+
+		// Get the chapters in the language, and the fallback languages, from the source
+		languages := append([]string{language}, fallbackLanguages...)
+		filteredChapters, err := sources.GetChaptersIn(source, manga, languages...)
 		if err != nil {
-			cobra.CheckErr(fmt.Errorf("failed to get chapters: %w", err))
+			progressStream.check(fmt.Errorf("failed to get chapters: %w", err))
 		}
+		// Entries linked to the manga fill the chapters its source misses
+		filteredChapters, err = services.WithLinkedChapters(repo, manga, filteredChapters, languages...)
+		progressStream.check(err)
+		filteredChapters, substituted := services.ApplyLanguageFallback(filteredChapters, language, fallbackLanguages)
+		filteredChapters = services.PreferGroup(filteredChapters, flagOrSetting(cmd, "group", settings.Group))
 
-		// Filter by language
-		var filteredChapters []*data.Chapter
-		for _, ch := range chapters {
-			if ch.Language == language {
-				filteredChapters = append(filteredChapters, ch)
+		// Filter by the chapter selection if specified
+		if chaptersFlag != "" {
+			filteredChapters, err = chapterselect.Filter(chaptersFlag, filteredChapters)
+			progressStream.check(err)
+			fmt.Fprintf(out, "%s Downloading %d chapters %s (language: %s)\n", utils.IconDownload, len(filteredChapters), chaptersFlag, language)
+		} else {
+			fmt.Fprintf(out, "%s Downloading %d chapters (language: %s)\n", utils.IconDownload, len(filteredChapters), language)
+		}
+
+		// Report the chapters of the selection that use a fallback language
+		kept := make(map[*data.Chapter]bool, len(filteredChapters))
+		for _, ch := range filteredChapters {
+			kept[ch] = true
+		}
+		for _, ch := range substituted {
+			if kept[ch] {
+				fmt.Fprintf(out, "%s Chapter %s: using %s (no %s translation)\n", utils.IconWeb, ch.Number, ch.Language, language)
 			}
 		}
 
-		// Filter by chapter range if specified
-		var startChapter, endChapter int
-		if chaptersFlag != "" {
-			parts := strings.Split(chaptersFlag, "-")
-			if len(parts) == 2 {
-				startChapter, _ = strconv.Atoi(parts[0])
-				endChapter, _ = strconv.Atoi(parts[1])
-				fmt.Printf("📥 Downloading chapters %d-%d (language: %s)\n", startChapter, endChapter, language)
-				
-				var rangeChapters []*data.Chapter
-				for _, ch := range filteredChapters {
-					chNum, _ := strconv.ParseFloat(ch.Number, 64)
-					if chNum >= float64(startChapter) && chNum <= float64(endChapter) {
-						rangeChapters = append(rangeChapters, ch)
-					}
+		// Open the source page instead of downloading: the first chapter of the
+		// range when one was given, otherwise the manga page
+		if openSource {
+			url := sources.MangaURL(source, manga)
+			if chaptersFlag != "" && len(filteredChapters) > 0 {
+				if chapterURL := sources.ChapterURL(source, manga, filteredChapters[0]); chapterURL != "" {
+					url = chapterURL
 				}
-				filteredChapters = rangeChapters
-			} else {
-				fmt.Println("⚠️  Invalid chapter range format. Use --chapters 1-10")
 			}
-		} else {
-			fmt.Printf("📥 Downloading %d chapters (language: %s)\n", len(filteredChapters), language)
+			fmt.Fprintln(out, utils.IconWeb, "Opening", url)
+			progressStream.check(utils.OpenBrowser(url))
+			return
+		}
+
+		// Chapters already downloaded are skipped, unless forced
+		force, _ := cmd.Flags().GetBool("force")
+		downloader.SetForce(force)
+		if !force {
+			if skipped := downloader.AlreadyDownloaded(manga, filteredChapters); len(skipped) > 0 {
+				fmt.Fprintf(out, "%s Skipping %d chapters already downloaded, use --force to download them again\n", utils.IconInfo, len(skipped))
+			}
 		}
 
 		// Listen for progress
+		bars := progressBarsFromFlags(cmd)
+		printed := make(chan struct{})
+		// Subscribed before downloading so no update is missed
+		updates := downloader.GetProgressChannel()
 		go func() {
-			for progress := range downloader.GetProgressChannel() {
-				if progress.ChapterNumber != "" {
-					if progress.Status == "complete" {
-						fmt.Printf("  ✓ Chapter %s complete\n", progress.ChapterNumber)
-					} else if progress.TotalPages > 0 {
-						fmt.Printf("  Chapter %s: %d/%d pages\n", progress.ChapterNumber, progress.CurrentPage, progress.TotalPages)
-					} else if progress.Status == "error" {
-						fmt.Printf("  ✗ Chapter %s error: %v\n", progress.ChapterNumber, progress.Error)
-					}
+			defer close(printed)
+			for progress := range updates {
+				if progressStream != nil {
+					progressStream.download(progress)
+					continue
 				}
+				bars.update(progress)
 			}
 		}()
 
-		if err := downloader.DownloadManga(manga, filteredChapters); err != nil {
-			cobra.CheckErr(fmt.Errorf("download failed: %w", err))
+		// Ctrl-C stops the download, keeping the chapters already downloaded
+		ctx, stop := interruptContext()
+		defer stop()
+		downloader.SetContext(ctx)
+		err = downloader.DownloadMangaBundled(manga, filteredChapters, bundleMode, nil)
+
+		// Print the remaining updates before the summary
+		downloader.Close()
+		<-printed
+		if progressStream == nil {
+			bars.finish()
+		}
+		if errors.Is(err, context.Canceled) {
+			progressStream.done("", err)
+			printInterrupted(out, filteredChapters)
+			evicted.print()
+			return
 		}
+		if err != nil {
+			evicted.print()
+			progressStream.check(fmt.Errorf("download failed: %w", err))
+		}
+		progressStream.done(downloadDir, nil)
 
-		fmt.Println("\n✅ Download complete! EPUBs have been created in:", downloadDir)
+		books := "EPUBs"
+		if settings.Format == services.BatchCBZ {
+			books = "CBZs"
+		}
+		fmt.Fprintf(out, "\n%s Download complete! %s have been created in: %s\n", utils.IconSuccess, books, downloadDir)
+		evicted.print()
 	},
 }
 
 func init() {
-	downloadCmd.Flags().StringP("language", "l", "en", "Language code (e.g., en, ja, es)")
-	downloadCmd.Flags().StringP("chapters", "c", "", "Chapter range (e.g., 1-10)")
+	downloadCmd.Flags().StringP("language", "l", "en", "Language code (e.g., en, ja, es), the manga's preferred language when unset")
+	downloadCmd.Flags().StringSlice("fallback-language", nil, "Languages to use, in order, for chapters missing in --language (repeatable)")
+	downloadCmd.Flags().StringP("chapters", "c", "", chapterSelectionHelp)
+	downloadCmd.Flags().Bool("open-source", false, "Open the manga (or first chapter of --chapters) on the source website instead of downloading")
+	downloadCmd.Flags().String("bundle", string(services.BundleChapter), "Group chapters into one EPUB per chapter or per volume (chapter, volume), the manga's setting when unset")
+	downloadCmd.Flags().String("group", "", "Scanlation group preferred when several translated a chapter, the manga's setting when unset")
+	downloadCmd.Flags().Bool("force", false, "Download chapters again even if they are already downloaded")
+	downloadCmd.Flags().StringSlice("archive-password", nil, "Password for protected chapter archives (repeatable)")
+	addPreprocessFlags(downloadCmd)
+	downloadCmd.Flags().Bool("transcode", false, "Convert WebP, AVIF and GIF pages to JPEG, which every reader renders (default from 'mangas config get transcode_pages')")
+	downloadCmd.Flags().String("verify-images", string(integrations.ImageCheckOff), "Check downloaded pages and download damaged ones again: off, magic (file signature matches the Content-Type) or decode")
+	addSourceFlag(downloadCmd)
+	addDownloaderFlags(downloadCmd)
+	addWebtoonFlags(downloadCmd)
+	addOCRFlags(downloadCmd)
+	addProgressJSONFlag(downloadCmd)
+	addPlainFlag(downloadCmd)
+}
+
+// transferSuffix renders the bytes, speed and ETA of a page update
+func transferSuffix(progress services.DownloadProgress) string {
+	if summary := progress.TransferSummary(); summary != "" {
+		return " · " + summary
+	}
+	return ""
+}
+
+// mangaProgressSuffix renders how far the manga download is when a chapter
+// completes, e.g. " (3 of 12 chapters, ETA 2m30s)"
+func mangaProgressSuffix(progress services.DownloadProgress) string {
+	if progress.MangaChapters <= 1 {
+		return ""
+	}
+	suffix := fmt.Sprintf(" (%d of %d chapters", progress.MangaCompleted, progress.MangaChapters)
+	if eta := services.FormatETA(progress.ETA); eta != "" {
+		suffix += ", ETA " + eta
+	}
+	return suffix + ")"
 }
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/kerbaras/mangas/pkg/utils"
 	"github.com/spf13/cobra"
 )
 
@@ -12,8 +13,8 @@ var epubCmd = &cobra.Command{
 	Long:  "This command is deprecated. EPUBs are now generated automatically during chapter download.",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("ℹ️  This command is deprecated.")
-		fmt.Println("📖 EPUBs are now generated automatically during chapter download.")
-		fmt.Println("💡 Use 'mangas download' to download chapters and create EPUBs in one step.")
+		fmt.Println(utils.IconInfo, "This command is deprecated.")
+		fmt.Println(utils.IconBook, "EPUBs are now generated automatically during chapter download.")
+		fmt.Println(utils.IconTip, "Use 'mangas download' to download chapters and create EPUBs in one step.")
 	},
 }
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export [manga-name]",
+	Short: "Export manga optimized for an e-reader (Kindle, Kobo, EPUB3)",
+	Long: `Export downloaded manga chapters into a single book optimized for a reading device.
+
+Kindle devices go through the same pipeline as 'mangas kindle'. Kobo devices and
+the generic epub3 profile get an EPUB3 fixed-layout book (pre-paginated pages,
+right-to-left spine) with pages resized and tuned for the screen. Kindles with
+Virtual Panels get their panels marked, use --no-panel-view for a plain book.
+
+Examples:
+  mangas export "One Piece" --device kobo-libra --chapters 1,2,3
+  mangas export "Naruto" --device kobo-clara --format kepub
+  mangas export "Bleach" --device epub3 --output bleach.epub
+  mangas export --collection Favorites --device kobo-elipsa --output ~/kobo
+
+Use 'mangas export --list-devices' to see all supported devices.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if listDevices, _ := cmd.Flags().GetBool("list-devices"); listDevices {
+			fmt.Println(utils.IconDevice, "Supported devices:")
+			for _, device := range integrations.ListExportDevices() {
+				fmt.Println("  " + device)
+			}
+			return
+		}
+
+		deviceID, _ := cmd.Flags().GetString("device")
+		format, _ := cmd.Flags().GetString("format")
+		chapters, _ := cmd.Flags().GetString("chapters")
+		output, _ := cmd.Flags().GetString("output")
+		title, _ := cmd.Flags().GetString("title")
+		author, _ := cmd.Flags().GetString("author")
+		cover, _ := cmd.Flags().GetString("cover")
+		series, _ := cmd.Flags().GetString("series")
+		seriesIndex, _ := cmd.Flags().GetFloat64("series-index")
+		titleSort, _ := cmd.Flags().GetString("title-sort")
+		collection, _ := cmd.Flags().GetString("collection")
+		noPanelView, _ := cmd.Flags().GetBool("no-panel-view")
+		progressStream, out := progressJSONFromFlags(cmd)
+
+		if deviceID == "" {
+			progressStream.check(fmt.Errorf("device is required. Use --list-devices to see available options"))
+		}
+		device, ok := integrations.GetExportDevice(deviceID)
+		if !ok {
+			progressStream.check(fmt.Errorf("unknown device: %s. Use --list-devices to see available options", deviceID))
+		}
+		if format == "" {
+			format = string(integrations.FormatMOBI)
+			if integrations.IsFixedLayoutDevice(deviceID) {
+				format = string(integrations.FormatEPUB)
+			}
+		}
+		if author == "" {
+			author = "MangaDex"
+		}
+
+		exporter, err := integrations.NewExporter(deviceID)
+		if err != nil {
+			progressStream.check(fmt.Errorf("failed to create exporter: %w", err))
+		}
+		defer exporter.Close()
+		progressStream.check(applyExportProfile(cmd, exporter, deviceID))
+
+		if collection != "" {
+			if output == "" {
+				output = sanitizeFilename(collection) + "_" + deviceID
+			}
+			fmt.Fprintf(out, "%s Exporting collection '%s' for %s to %s\n", utils.IconPackage, collection, device.Name, output)
+			manifest, err := exportCollection(out, collection, format, output, func(manga *data.Manga, chapters []*data.Chapter, seriesDir string) ([]string, error) {
+				return exportDeviceSeries(exporter, manga, chapters, deviceID, format, author, deviceID, seriesDir, !noPanelView, progressStream.onExport())
+			})
+			if err != nil {
+				progressStream.check(fmt.Errorf("export failed: %w", err))
+			}
+			progressStream.done(output, nil)
+			fmt.Fprintf(out, "%s Exported %d series, manifest: %s\n", utils.IconSuccess, len(manifest.Series), filepath.Join(output, integrations.ManifestFilename))
+			return
+		}
+
+		if len(args) == 0 {
+			progressStream.check(fmt.Errorf("manga name or --collection is required (use --list-devices to see supported devices)"))
+		}
+
+		controller := services.NewMangaController()
+		defer controller.Close()
+
+		manga, err := findLibraryManga(out, controller, args[0])
+		if err != nil {
+			progressStream.check(fmt.Errorf("manga not found in library: %w", err))
+		}
+		allChapters, err := controller.GetChaptersFromLibrary(manga.ID)
+		if err != nil {
+			progressStream.check(fmt.Errorf("failed to get chapters: %w", err))
+		}
+
+		selected, err := parseChapterSelection(chapters, allChapters)
+		progressStream.check(err)
+		if len(selected) == 0 {
+			progressStream.check(fmt.Errorf("no downloaded chapters found matching the selection"))
+		}
+		fmt.Fprintf(out, "%s Exporting %d chapter(s) of %s for %s\n", utils.IconLibrary, len(selected), manga.Name, device.Name)
+
+		if output == "" {
+			output = fmt.Sprintf("%s_%s.%s", sanitizeFilename(manga.Name), deviceID, format)
+		}
+		if title == "" {
+			title = manga.Name
+		}
+		if cover == "" {
+			if coverPath, err := downloadMangaCover(manga); err == nil {
+				cover = coverPath
+				defer os.Remove(coverPath)
+			} else {
+				fmt.Fprintf(out, "%s Exporting without cover: %v\n", utils.IconWarning, err)
+			}
+		}
+		if series == "" {
+			series = manga.Name
+		}
+		if seriesIndex == 0 {
+			seriesIndex, _ = strconv.ParseFloat(selected[0].Number, 64)
+		}
+
+		chapterPaths, chapterNumbers := services.ChapterBooks(selected)
+
+		outputPath, err := exporter.ConvertChapters(integrations.ExportOptions{
+			Device:         device,
+			Format:         integrations.KindleFormat(format),
+			Title:          title,
+			Author:         author,
+			Chapters:       chapterPaths,
+			ChapterNumbers: chapterNumbers,
+			OutputPath:     output,
+			Optimize:       true,
+			PanelView:      device.PanelView && !noPanelView,
+			RightToLeft:    services.RightToLeft(manga),
+			CoverImage:     cover,
+			Series:         series,
+			SeriesIndex:    seriesIndex,
+			TitleSort:      titleSort,
+			Language:       selected[0].Language,
+			OnProgress:     progressStream.onExport(),
+		})
+		if report := exporter.Report(); report != nil && report.Output != "" {
+			fmt.Fprint(out, report.Summary())
+			fmt.Fprintf(out, "%s Report: %s\n", utils.IconNote, integrations.ReportPath(report.Output))
+		}
+		if err != nil {
+			progressStream.check(fmt.Errorf("conversion failed: %w", err))
+		}
+		progressStream.done(outputPath, nil)
+
+		if resumed := exporter.ResumedPages(); resumed > 0 {
+			fmt.Fprintf(out, "%s Resumed %d page(s) processed by an interrupted export\n", utils.IconRestore, resumed)
+		}
+		fmt.Fprintf(out, "%s Export complete: %s\n", utils.IconSuccess, outputPath)
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringP("device", "d", "", "Device model, see --list-devices (required)")
+	exportCmd.Flags().StringP("format", "f", "", "Output format: mobi, azw3 or epub for Kindle; epub or kepub for the others (default: mobi for Kindle, epub otherwise)")
+	exportCmd.Flags().StringP("chapters", "c", "", chapterSelectionHelp)
+	exportCmd.Flags().StringP("output", "o", "", "Output file path (default: <manga-name>_<device>.<format>)")
+	exportCmd.Flags().StringP("title", "t", "", "Custom title for the export")
+	exportCmd.Flags().StringP("author", "a", "", "Custom author name")
+	exportCmd.Flags().String("cover", "", "Cover image path (default: the manga cover from its source)")
+	exportCmd.Flags().String("series", "", "Series name for grouping on the device (default: manga name)")
+	exportCmd.Flags().Float64("series-index", 0, "Position in the series (default: first exported chapter number)")
+	exportCmd.Flags().String("title-sort", "", "Sort key for the title (default: title)")
+	exportCmd.Flags().String("collection", "", "Export every downloaded chapter of a collection into per-series folders")
+	addPreprocessFlags(exportCmd)
+	addProgressJSONFlag(exportCmd)
+	exportCmd.Flags().Bool("no-panel-view", false, "Do not mark panels for Virtual Panels on Kindle devices supporting it")
+	exportCmd.Flags().Bool("list-devices", false, "List all supported devices")
+
+	rootCmd.AddCommand(exportCmd)
+}
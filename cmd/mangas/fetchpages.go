@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var fetchPagesCmd = &cobra.Command{
+	Use:   "fetch-pages [urls.txt]",
+	Short: "Build a chapter from a list of page image URLs or files",
+	Long: `Build the book of a chapter from a list of page images instead of the pages
+its source lists, for when a source is broken but you have the links. The list
+holds one image URL or local file per line, in reading order; blank lines and
+lines starting with # are skipped. Use - to read it from stdin.
+
+Pages are processed like any download (image checks, cleanup, transcoding) and
+the chapter is marked as downloaded. Chapters missing from the library are
+added. With --format cbz a CBZ is also written.
+
+Examples:
+  mangas fetch-pages --manga "One Piece" --chapter 5 urls.txt
+  ls scans/*.png | mangas fetch-pages --manga "One Piece" --chapter 5.5 -
+  mangas fetch-pages --manga "One Piece" --chapter 5 urls.txt --format cbz`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		mangaName, _ := cmd.Flags().GetString("manga")
+		number, _ := cmd.Flags().GetString("chapter")
+		language, _ := cmd.Flags().GetString("language")
+		title, _ := cmd.Flags().GetString("title")
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+
+		if mangaName == "" || number == "" {
+			cobra.CheckErr(fmt.Errorf("--manga and --chapter are required"))
+		}
+		if format != "epub" && format != "cbz" {
+			cobra.CheckErr(fmt.Errorf("invalid format %q, expected epub or cbz", format))
+		}
+		pages, err := readPageListArg(args[0])
+		cobra.CheckErr(err)
+
+		controller := services.NewMangaController()
+		defer controller.Close()
+
+		manga, err := findLibraryManga(os.Stdout, controller, mangaName)
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("manga not found in library: %w", err))
+		}
+		chapter, err := findLibraryChapter(controller, manga, number, language)
+		if err != nil {
+			chapter = &data.Chapter{
+				ID:       fmt.Sprintf("manual-%s-%s-%s", manga.ID, language, number),
+				MangaID:  manga.ID,
+				Number:   number,
+				Title:    title,
+				Language: language,
+			}
+			if err := data.NewDuckDBRepository().SaveChapter(chapter); err != nil {
+				cobra.CheckErr(fmt.Errorf("failed to add chapter: %w", err))
+			}
+			fmt.Printf("%s Added chapter %s to %s\n", utils.IconNew, number, manga.Name)
+		}
+
+		fmt.Printf("%s Building %s chapter %s from %d page(s)\n", utils.IconDownload, manga.Name, chapter.Number, len(pages))
+		if err := controller.DownloadChapterPages(manga, chapter, pages); err != nil {
+			cobra.CheckErr(fmt.Errorf("failed to build chapter: %w", err))
+		}
+
+		if format == "cbz" {
+			if output == "" {
+				output = integrations.CBZFilename(manga, chapter)
+			}
+			if err := integrations.ExportCBZ(manga, chapter, output); err != nil {
+				cobra.CheckErr(fmt.Errorf("failed to write CBZ: %w", err))
+			}
+			fmt.Printf("%s Chapter %s saved to: %s\n", utils.IconSuccess, chapter.Number, output)
+			return
+		}
+		fmt.Printf("%s Chapter %s saved to: %s\n", utils.IconSuccess, chapter.Number, chapter.FilePath)
+	},
+}
+
+// readPageListArg reads the page list in path, "-" for stdin
+func readPageListArg(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		r = file
+	}
+	return services.ReadPageList(r)
+}
+
+func init() {
+	fetchPagesCmd.Flags().String("manga", "", "Library manga the chapter belongs to (required)")
+	fetchPagesCmd.Flags().StringP("chapter", "c", "", "Chapter number (required)")
+	fetchPagesCmd.Flags().StringP("language", "l", "en", "Language code of the chapter")
+	fetchPagesCmd.Flags().String("title", "", "Title of the chapter, when it is added to the library")
+	fetchPagesCmd.Flags().StringP("format", "f", "epub", "Output format: epub (library book) or cbz (library book and a CBZ)")
+	fetchPagesCmd.Flags().StringP("output", "o", "", "CBZ path with --format cbz (default: the usual CBZ file name)")
+
+	rootCmd.AddCommand(fetchPagesCmd)
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var filtersCmd = &cobra.Command{
+	Use:   "filters",
+	Short: "Run extra image filters on the pages of exports",
+	Long: `Show the image filters available and the ones set for each export profile.
+
+Filters run on every page exported for a profile by 'mangas kindle',
+'mangas export' and 'mangas sync --convert', after the page is resized and
+before the e-ink adjustments. Margin trimming and auto-level run before
+resizing, see 'mangas filters preprocess'. The command filter pipes each page as PNG
+through a script that prints the filtered image, so any tool can be used:
+
+  mangas filters set kindle-oasis3 levels=16,240
+  mangas filters set kobo-libra "command=./remove-watermark.sh --strength 2"
+
+Plugins built into mangas add their own filters with
+integrations.RegisterImageFilter.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println(utils.IconSettings, "Available filters:")
+		for _, filter := range integrations.ListImageFilters() {
+			fmt.Printf("  %s\n", filter)
+		}
+
+		repo := data.NewDuckDBRepository()
+		var deviceIDs []string
+		for id := range integrations.KindleDevices {
+			deviceIDs = append(deviceIDs, id)
+		}
+		for id := range integrations.FixedLayoutDevices {
+			deviceIDs = append(deviceIDs, id)
+		}
+		sort.Strings(deviceIDs)
+
+		fmt.Println()
+		configured := false
+		for _, id := range deviceIDs {
+			filters, err := services.LoadImageFilters(repo, id)
+			cobra.CheckErr(err)
+			preprocess, err := services.LoadPreprocess(repo, id)
+			cobra.CheckErr(err)
+			var steps []string
+			if preprocess.TrimMargins {
+				steps = append(steps, "trim-margins")
+			}
+			if preprocess.AutoLevel {
+				steps = append(steps, "autolevel")
+			}
+			for _, filter := range filters {
+				steps = append(steps, filter.String())
+			}
+			if len(steps) == 0 {
+				continue
+			}
+			if !configured {
+				fmt.Println("Filters by profile:")
+				configured = true
+			}
+			fmt.Printf("  %-20s %s\n", id, strings.Join(steps, " "+utils.IconArrow.String()+" "))
+		}
+		if !configured {
+			fmt.Println("No filters set, use 'mangas filters set <device> <filter>...'")
+		}
+	},
+}
+
+var filtersSetCmd = &cobra.Command{
+	Use:   "set [device] [filter...]",
+	Short: "Set the filters of an export profile, run in the order given",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		filters := make([]integrations.ImageFilterSpec, len(args)-1)
+		for i, arg := range args[1:] {
+			var err error
+			filters[i], err = integrations.ParseImageFilterSpec(arg)
+			cobra.CheckErr(err)
+		}
+		cobra.CheckErr(services.SaveImageFilters(data.NewDuckDBRepository(), args[0], filters))
+		fmt.Printf("%s %d filter(s) set for %s\n", utils.IconSuccess, len(filters), args[0])
+	},
+}
+
+var filtersPreprocessCmd = &cobra.Command{
+	Use:   "preprocess [device] [--trim-margins] [--autolevel]",
+	Short: "Set the clean-ups run on the full-size pages of an export profile",
+	Long: `Set the clean-ups run on the pages exported for a profile before they are
+resized. Trimming the white or black borders of scans makes the pages
+noticeably larger on 6" screens; auto-level makes faded scans crisp:
+
+  mangas filters preprocess kindle-paperwhite3 --trim-margins --autolevel
+  mangas filters preprocess kindle-paperwhite3 --autolevel=false
+
+Flags left out keep their value. 'mangas kindle' and 'mangas export' take
+the same flags to override the profile for one export.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := data.NewDuckDBRepository()
+		options, err := services.LoadPreprocess(repo, args[0])
+		cobra.CheckErr(err)
+		options = preprocessFromFlags(cmd, options)
+		cobra.CheckErr(services.SavePreprocess(repo, args[0], options))
+		fmt.Printf("%s %s: trim margins %s, auto-level %s\n", utils.IconSuccess, args[0], onOff(options.TrimMargins), onOff(options.AutoLevel))
+	},
+}
+
+// onOff shows a switch
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+var filtersClearCmd = &cobra.Command{
+	Use:   "clear [device]",
+	Short: "Remove the filters of an export profile",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(services.SaveImageFilters(data.NewDuckDBRepository(), args[0], nil))
+		fmt.Printf("%s Filters of %s removed\n", utils.IconSuccess, args[0])
+	},
+}
+
+// applyExportProfile sets the image filters and clean-ups configured for
+// deviceID on an exporter, the clean-up flags of cmd, if any, overriding
+// the configured ones
+func applyExportProfile(cmd *cobra.Command, exporter integrations.Exporter, deviceID string) error {
+	repo := data.NewDuckDBRepository()
+	filters, err := services.LoadImageFilters(repo, deviceID)
+	if err != nil {
+		return err
+	}
+	if len(filters) > 0 {
+		if err := exporter.SetFilters(filters); err != nil {
+			return err
+		}
+	}
+	preprocess, err := services.LoadPreprocess(repo, deviceID)
+	if err != nil {
+		return err
+	}
+	exporter.SetPreprocess(preprocessFromFlags(cmd, preprocess))
+	return nil
+}
+
+func init() {
+	filtersCmd.AddCommand(filtersSetCmd)
+	filtersCmd.AddCommand(filtersClearCmd)
+	filtersCmd.AddCommand(filtersPreprocessCmd)
+	addPreprocessFlags(filtersPreprocessCmd)
+	rootCmd.AddCommand(filtersCmd)
+}
@@ -1,5 +1,25 @@
 package cmd
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/sources"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
 // truncateString truncates a string to maxLen, adding "..." if truncated
 func truncateString(s string, maxLen int) string {
 	if len(s) <= maxLen {
@@ -7,3 +27,240 @@ func truncateString(s string, maxLen int) string {
 	}
 	return s[:maxLen-3] + "..."
 }
+
+// chapterSelectionHelp describes the --chapters flags, see chapterselect
+const chapterSelectionHelp = "Chapters to select: numbers and ranges (e.g., 1-10,15,20-), latest:5 or volume:3"
+
+// interruptContext returns a context cancelled by Ctrl-C or SIGTERM, for
+// downloads to stop cleanly and leave what is left to the next run. Plugin
+// calls stop with it too. A second Ctrl-C exits right away.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	sources.SetPluginContext(ctx)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// printInterrupted prints how far an interrupted download of chapters got
+func printInterrupted(out io.Writer, chapters []*data.Chapter) {
+	downloaded := 0
+	for _, ch := range chapters {
+		if ch.Downloaded {
+			downloaded++
+		}
+	}
+	fmt.Fprintf(out, "\n%s Interrupted: %d of %d chapters downloaded, run the same command again to download the rest\n",
+		utils.IconStop, downloaded, len(chapters))
+}
+
+// addSourceFlag registers the --source flag on a command
+func addSourceFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("source", "s", sources.DefaultSource,
+		fmt.Sprintf("Manga source (%s)", strings.Join(sources.Names(), ", ")))
+}
+
+// sourceFromFlags returns the source selected with the --source flag
+func sourceFromFlags(cmd *cobra.Command) (sources.Source, error) {
+	name, _ := cmd.Flags().GetString("source")
+	source, err := sources.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w (available: %s)", err, strings.Join(sources.Names(), ", "))
+	}
+	return source, nil
+}
+
+// addDownloaderFlags registers the concurrency and rate limit flags on a command
+func addDownloaderFlags(cmd *cobra.Command) {
+	defaults := services.DefaultDownloaderOptions()
+	cmd.Flags().Int("concurrent-chapters", defaults.MaxConcurrentChapters, "Chapters downloaded in parallel")
+	cmd.Flags().Int("concurrent-pages", defaults.MaxConcurrentPages, "Pages downloaded in parallel per chapter")
+	cmd.Flags().Float64("rate", defaults.RequestsPerSecond, "Maximum requests per second (0 for unlimited)")
+	cmd.Flags().StringSlice("host-limit", nil, "Per-host requests per second, as host=rate (repeatable)")
+	cmd.Flags().Int("retries", defaults.Retry.Attempts-1, "Retries for failed page downloads")
+	cmd.Flags().Duration("retry-backoff", defaults.Retry.Backoff, "Delay before the first retry, doubled on each retry")
+}
+
+// addPreprocessFlags registers the page clean-up flags on a command
+func addPreprocessFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("trim-margins", false, "Crop the uniform white or black borders of the pages")
+	cmd.Flags().Bool("autolevel", false, "Stretch the levels of faded scans so ink is black and paper white")
+}
+
+// preprocessFromFlags overrides options with the flags added by
+// addPreprocessFlags, when given
+func preprocessFromFlags(cmd *cobra.Command, options integrations.PreprocessOptions) integrations.PreprocessOptions {
+	if cmd.Flags().Changed("trim-margins") {
+		options.TrimMargins, _ = cmd.Flags().GetBool("trim-margins")
+	}
+	if cmd.Flags().Changed("autolevel") {
+		options.AutoLevel, _ = cmd.Flags().GetBool("autolevel")
+	}
+	return options
+}
+
+// downloaderOptionsFromFlags builds downloader options from the flags added by addDownloaderFlags
+func downloaderOptionsFromFlags(cmd *cobra.Command) (services.DownloaderOptions, error) {
+	options := services.DefaultDownloaderOptions()
+	options.MaxConcurrentChapters, _ = cmd.Flags().GetInt("concurrent-chapters")
+	options.MaxConcurrentPages, _ = cmd.Flags().GetInt("concurrent-pages")
+	options.RequestsPerSecond, _ = cmd.Flags().GetFloat64("rate")
+	retries, _ := cmd.Flags().GetInt("retries")
+	options.Retry.Attempts = retries + 1
+	options.Retry.Backoff, _ = cmd.Flags().GetDuration("retry-backoff")
+
+	hostLimits, _ := cmd.Flags().GetStringSlice("host-limit")
+	for _, limit := range hostLimits {
+		host, rate, ok := strings.Cut(limit, "=")
+		if !ok || host == "" {
+			return options, fmt.Errorf("invalid host limit %q, expected host=rate", limit)
+		}
+		perSecond, err := strconv.ParseFloat(rate, 64)
+		if err != nil || perSecond <= 0 {
+			return options, fmt.Errorf("invalid rate in host limit %q", limit)
+		}
+		if options.PerHostLimits == nil {
+			options.PerHostLimits = make(map[string]float64)
+		}
+		options.PerHostLimits[host] = perSecond
+	}
+	return options, nil
+}
+
+// addWebtoonFlags registers the flags slicing long strips into pages
+func addWebtoonFlags(cmd *cobra.Command) {
+	defaults := integrations.DefaultWebtoonOptions()
+	cmd.Flags().Bool("webtoon", false, "Slice long vertical strips (webtoons) into screen-height pages")
+	cmd.Flags().Int("webtoon-overlap", defaults.Overlap, "Pixels repeated between consecutive webtoon slices")
+	cmd.Flags().Float64("webtoon-aspect", defaults.PageAspect, "Height of webtoon slices relative to their width")
+}
+
+// webtoonOptionsFromFlags returns the slicing options from the flags added by
+// addWebtoonFlags, or nil when --webtoon is off
+func webtoonOptionsFromFlags(cmd *cobra.Command) (*integrations.WebtoonOptions, error) {
+	if webtoon, _ := cmd.Flags().GetBool("webtoon"); !webtoon {
+		return nil, nil
+	}
+	options := integrations.DefaultWebtoonOptions()
+	options.Overlap, _ = cmd.Flags().GetInt("webtoon-overlap")
+	options.PageAspect, _ = cmd.Flags().GetFloat64("webtoon-aspect")
+	if options.Overlap < 0 {
+		return nil, fmt.Errorf("webtoon overlap cannot be negative")
+	}
+	if options.PageAspect <= 0 {
+		return nil, fmt.Errorf("webtoon aspect must be positive")
+	}
+	return &options, nil
+}
+
+// addOCRFlags registers the flags recognizing the text of pages and wording
+// their ALT text
+func addOCRFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("ocr", false, "Recognize the text of pages with tesseract so EPUBs can be searched")
+	cmd.Flags().String("ocr-lang", "eng", "Tesseract languages, e.g. jpn or jpn+eng")
+	cmd.Flags().String("ocr-mode", string(integrations.OCRAlt), "Where recognized text goes: alt, sidecar (.txt next to the EPUB) or both")
+	addAltTextFlag(cmd)
+}
+
+// addAltTextFlag registers the flag wording the ALT text of pages
+func addAltTextFlag(cmd *cobra.Command) {
+	cmd.Flags().String("alt-text", string(integrations.AltTextPage), "ALT text of the pages: page (\"Page 3\") or descriptive (\"Naruto, Chapter 12, page 3 of 20\")")
+}
+
+// altTextFromFlags returns the ALT text wording of the flag added by
+// addAltTextFlag
+func altTextFromFlags(cmd *cobra.Command) (integrations.AltTextMode, error) {
+	altText, _ := cmd.Flags().GetString("alt-text")
+	return integrations.ParseAltTextMode(altText)
+}
+
+// ocrOptionsFromFlags returns the OCR options from the flags added by
+// addOCRFlags, or nil when --ocr is off
+func ocrOptionsFromFlags(cmd *cobra.Command) (*integrations.OCROptions, error) {
+	if ocr, _ := cmd.Flags().GetBool("ocr"); !ocr {
+		return nil, nil
+	}
+	modeName, _ := cmd.Flags().GetString("ocr-mode")
+	mode, err := integrations.ParseOCRMode(modeName)
+	if err != nil {
+		return nil, err
+	}
+	languages, _ := cmd.Flags().GetString("ocr-lang")
+	tesseract, err := integrations.NewTesseract(languages)
+	if err != nil {
+		return nil, err
+	}
+	return &integrations.OCROptions{Recognizer: tesseract, Mode: mode}, nil
+}
+
+// flagOrSetting returns the value of a string flag when it was set on the
+// command line, else the manga's setting when it has one, else the flag's
+// default
+func flagOrSetting(cmd *cobra.Command, flag, setting string) string {
+	value, _ := cmd.Flags().GetString(flag)
+	if !cmd.Flags().Changed(flag) && setting != "" {
+		return setting
+	}
+	return value
+}
+
+// findLibraryManga finds a library manga by name. When several manga match,
+// the user picks one if the command runs in a terminal.
+func findLibraryManga(out io.Writer, controller *services.MangaController, name string) (*data.Manga, error) {
+	manga, err := controller.FindMangaByName(name)
+	var ambiguous *services.AmbiguousMangaError
+	if errors.As(err, &ambiguous) && term.IsTerminal(os.Stdin.Fd()) {
+		return pickManga(out, ambiguous)
+	}
+	return manga, err
+}
+
+// findLibraryChapter finds a chapter of a library manga by number and
+// language, preferring a downloaded copy when several releases share the
+// number
+func findLibraryChapter(controller *services.MangaController, manga *data.Manga, number, language string) (*data.Chapter, error) {
+	chapters, err := controller.GetChaptersFromLibrary(manga.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chapters: %w", err)
+	}
+
+	var chapter *data.Chapter
+	for _, ch := range chapters {
+		if ch.Number != number || ch.Language != language {
+			continue
+		}
+		if chapter == nil || (ch.Downloaded && !chapter.Downloaded) {
+			chapter = ch
+		}
+	}
+	if chapter == nil {
+		return nil, fmt.Errorf("chapter %s (%s) not found in library", number, language)
+	}
+	return chapter, nil
+}
+
+// pickManga lists the candidates of an ambiguous name and asks for a number
+func pickManga(out io.Writer, ambiguous *services.AmbiguousMangaError) (*data.Manga, error) {
+	if ambiguous.Exact {
+		fmt.Fprintf(out, "%s %d manga are named '%s':\n", utils.IconChoice, len(ambiguous.Candidates), ambiguous.Name)
+	} else {
+		fmt.Fprintf(out, "%s No manga named '%s', did you mean:\n", utils.IconSearch, ambiguous.Name)
+	}
+	for i, m := range ambiguous.Candidates {
+		details := m.Source
+		if m.Year > 0 {
+			details = fmt.Sprintf("%s, %d", details, m.Year)
+		}
+		fmt.Fprintf(out, "  %d. %s (%s) %s\n", i+1, m.Name, details, m.ID)
+	}
+
+	fmt.Fprintf(out, "Pick one [1-%d]: ", len(ambiguous.Candidates))
+	answer := prompt("", false)
+	choice, err := strconv.Atoi(answer)
+	if err != nil || choice < 1 || choice > len(ambiguous.Candidates) {
+		return nil, fmt.Errorf("no manga picked")
+	}
+	return ambiguous.Candidates[choice-1], nil
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/sources"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+// addHTTPFlags adds the flags configuring the HTTP clients of the sources.
+// Every value may start with "<source>=" to apply to one source only.
+func addHTTPFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringArray("user-agent", nil, "User-Agent sent to the sources ([source=]agent, $MANGAS_USER_AGENT)")
+	cmd.PersistentFlags().StringArray("header", nil, "Extra request header, e.g. a Referer ([source=]\"Name: value\", repeatable)")
+	cmd.PersistentFlags().StringArray("proxy", nil, "Proxy for the sources: http, https or socks5 URL, or direct ([source=]url, $MANGAS_PROXY)")
+	cmd.PersistentFlags().StringArray("http-timeout", nil, "Time limit for a request, e.g. 30s ([source=]duration, $MANGAS_HTTP_TIMEOUT)")
+	cmd.PersistentFlags().StringArray("chaos", nil, "Simulate a bad network, for development ([source=]default or [source=]latency=200ms,errors=0.1,drops=0.05,truncate=0.05,seed=N)")
+	cmd.PersistentFlags().Bool("offline", false, "Use only cached source responses, without touching the network")
+	cmd.PersistentFlags().Bool("no-cache", false, "Ask the sources every time instead of using cached responses")
+}
+
+// applyCacheOptions caches the responses of the sources in
+// ~/.mangas/cache/api, unless --no-cache, and turns offline mode on with
+// --offline
+func applyCacheOptions(cmd *cobra.Command) error {
+	offline, _ := cmd.PersistentFlags().GetBool("offline")
+	noCache, _ := cmd.PersistentFlags().GetBool("no-cache")
+	if offline && noCache {
+		return fmt.Errorf("--offline needs the cache, it can't be used with --no-cache")
+	}
+	if !noCache {
+		utils.SetResponseCache(utils.NewAPICache(utils.DefaultResponseCacheDir()))
+	}
+	utils.SetOffline(offline)
+	return nil
+}
+
+// applyHTTPOptions configures the HTTP clients of the sources from the
+// environment, then the flags
+func applyHTTPOptions(cmd *cobra.Command) error {
+	options := make(map[string]utils.ClientOptions)
+	for _, source := range append([]string{""}, sources.Names()...) {
+		env, err := utils.ClientOptionsFromEnv(source, os.Getenv)
+		if err != nil {
+			return err
+		}
+		options[source] = env
+	}
+
+	set := func(flag string, apply func(value string) (utils.ClientOptions, error)) error {
+		values, _ := cmd.PersistentFlags().GetStringArray(flag)
+		for _, value := range values {
+			source, value := splitSourceOption(value)
+			override, err := apply(value)
+			if err != nil {
+				return fmt.Errorf("--%s: %w", flag, err)
+			}
+			options[source] = options[source].Merge(override)
+		}
+		return nil
+	}
+	err := set("user-agent", func(value string) (utils.ClientOptions, error) {
+		return utils.ClientOptions{UserAgent: value}, nil
+	})
+	if err == nil {
+		err = set("header", func(value string) (utils.ClientOptions, error) {
+			name, headerValue, err := utils.ParseHeader(value)
+			return utils.ClientOptions{Header: http.Header{name: {headerValue}}}, err
+		})
+	}
+	if err == nil {
+		err = set("proxy", func(value string) (utils.ClientOptions, error) {
+			return utils.ClientOptions{Proxy: value}, nil
+		})
+	}
+	if err == nil {
+		err = set("http-timeout", func(value string) (utils.ClientOptions, error) {
+			timeout, err := time.ParseDuration(value)
+			return utils.ClientOptions{Timeout: timeout}, err
+		})
+	}
+	if err == nil {
+		err = set("chaos", func(value string) (utils.ClientOptions, error) {
+			chaos, err := utils.ParseChaos(value)
+			return utils.ClientOptions{Chaos: &chaos}, err
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	for source, sourceOptions := range options {
+		if sourceOptions.IsZero() {
+			continue
+		}
+		if err := utils.SetClientOptions(source, sourceOptions); err != nil {
+			if source != "" {
+				return fmt.Errorf("%s: %w", source, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// splitSourceOption splits a "<source>=value" flag value, returning "" as the
+// source when the value doesn't start with the name of a source
+func splitSourceOption(value string) (string, string) {
+	name, rest, ok := strings.Cut(value, "=")
+	if !ok {
+		return "", value
+	}
+	for _, source := range sources.Names() {
+		if name == source {
+			return source, rest
+		}
+	}
+	return "", value
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import manga into the library from other services",
+}
+
+var importMangaDexFollowsCmd = &cobra.Command{
+	Use:   "mangadex-follows",
+	Short: "Import the manga you follow on MangaDex",
+	Long: `Add the manga followed by your MangaDex account to the library, with their
+reading status (reading, plan to read, completed...). Manga already in the
+library only get their reading status updated.
+
+Log in first with 'mangas login mangadex', then run 'mangas update' to fetch
+the chapter lists of the imported manga.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := data.NewDuckDBRepository()
+		mangadex, err := loggedInMangaDex(repo)
+		cobra.CheckErr(err)
+
+		controller := services.NewMangaController()
+		defer controller.Close()
+
+		fmt.Println(utils.IconSync, "Fetching your MangaDex follows...")
+		report, err := controller.ImportFollows(mangadex)
+		// Tokens renewed during the import are kept for next time
+		if session := mangadex.Session(); session != nil {
+			if saveErr := saveMangaDexSession(repo, session); saveErr != nil {
+				fmt.Printf("%s Failed to save the renewed session, log in again if the next import fails: %v\n", utils.IconWarning, saveErr)
+			}
+		}
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("import failed: %w", err))
+		}
+
+		statuses := make(map[string]int)
+		for _, manga := range report.Follows {
+			status := manga.ReadingStatus
+			if status == "" {
+				status = "no status"
+			}
+			statuses[status]++
+		}
+		for status, count := range statuses {
+			fmt.Printf("  %s %-14s %d\n", utils.IconBullet, status, count)
+		}
+		fmt.Printf("\n%s %d followed manga: %d added, %d reading status(es) updated\n", utils.IconLibrary,
+			len(report.Follows), report.Added, report.Updated)
+	},
+}
+
+func init() {
+	importCmd.AddCommand(importMangaDexFollowsCmd)
+	rootCmd.AddCommand(importCmd)
+}
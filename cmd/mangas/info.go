@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/sources"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var infoCmd = &cobra.Command{
+	Use:   "info [manga-name or manga-id]",
+	Short: "Show the details of a manga",
+	Long: `Show everything known about a manga of the library: description, tags,
+authors, status, chapter languages, how many chapters are downloaded, the
+disk space they take and where their books are.
+
+With --remote, the details and chapters are fetched fresh from the source
+instead, without saving them; manga not in the library are looked up by ID
+on --source.
+
+Examples:
+  mangas info "One Piece"
+  mangas info "One Piece" --remote
+  mangas info a1c7c817-4e59-43b7-9365-09675a149a6f --remote`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		remote, _ := cmd.Flags().GetBool("remote")
+		source, err := sourceFromFlags(cmd)
+		cobra.CheckErr(err)
+
+		controller := services.NewMangaController()
+		defer controller.Close()
+
+		manga, err := controller.GetMangaFromLibrary(args[0])
+		cobra.CheckErr(err)
+		if manga == nil {
+			manga, err = findLibraryManga(os.Stdout, controller, args[0])
+			// With --remote, what isn't in the library is looked up on the source
+			if err != nil && !(remote && errors.Is(err, services.ErrMangaNotFound)) {
+				cobra.CheckErr(err)
+			}
+		}
+
+		if !remote {
+			chapters, err := controller.GetChaptersFromLibrary(manga.ID)
+			cobra.CheckErr(err)
+			printMangaInfo(manga, services.SummarizeChapters(chapters), true)
+			return
+		}
+
+		// Library entries are fetched from the source they were added from
+		id := args[0]
+		if manga != nil {
+			id = manga.ID
+			source = sources.ForManga(manga, source)
+		}
+		fmt.Printf("%s Fetching '%s' from the source...\n\n", utils.IconNetwork, id)
+		fresh, err := source.GetManga(id)
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("failed to get manga: %w", err))
+		}
+		chapters, err := source.GetChapters(fresh)
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("failed to get chapters: %w", err))
+		}
+		printMangaInfo(fresh, services.SummarizeChapters(chapters), false)
+	},
+}
+
+// printMangaInfo prints the details of a manga and a summary of its
+// chapters, with the downloaded books when they come from the library
+func printMangaInfo(manga *data.Manga, summary *services.ChapterSummary, library bool) {
+	fmt.Printf("%s %s\n\n", utils.IconBook, manga.Name)
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Printf("  %-14s %s\n", name, value)
+		}
+	}
+	field("ID", manga.ID)
+	field("Source", manga.Source)
+	field("URL", manga.URL)
+	field("Authors", strings.Join(manga.Authors, ", "))
+	field("Artists", strings.Join(manga.Artists, ", "))
+	if manga.Year > 0 {
+		field("Year", strconv.Itoa(manga.Year))
+	}
+	field("Publication", manga.PublicationStatus)
+	if library {
+		field("Reading", manga.ReadingStatus)
+		field("Status", manga.Status)
+	}
+	field("Genres", strings.Join(manga.Genres, ", "))
+	field("Tags", strings.Join(manga.Tags, ", "))
+	field("Languages", strings.Join(summary.Languages, ", "))
+
+	if library {
+		field("Chapters", fmt.Sprintf("%d (%d downloaded, %d read)", summary.Chapters, summary.Downloaded, summary.Read))
+		field("Disk usage", services.FormatBytes(summary.DiskUsage))
+		if !manga.AddedAt.IsZero() {
+			field("Added", manga.AddedAt.Format("2006-01-02"))
+		}
+	} else {
+		field("Chapters", strconv.Itoa(summary.Chapters))
+	}
+
+	if description := strings.TrimSpace(manga.Description); description != "" {
+		fmt.Printf("\n  %s\n", strings.ReplaceAll(description, "\n", "\n  "))
+	}
+
+	if !library {
+		return
+	}
+	if len(summary.Files) > 0 {
+		fmt.Printf("\n%s Files:\n", utils.IconDisk)
+		for _, path := range summary.Files {
+			fmt.Printf("  %s %s\n", utils.IconBullet, path)
+		}
+	}
+	if len(summary.Missing) > 0 {
+		fmt.Printf("\n%s Missing from disk, 'mangas verify \"%s\" --fix' queues them, 'mangas queue run' downloads them again:\n", utils.IconWarning, manga.Name)
+		for _, path := range summary.Missing {
+			fmt.Printf("  %s %s\n", utils.IconBullet, path)
+		}
+	}
+}
+
+func init() {
+	infoCmd.Flags().Bool("remote", false, "Fetch fresh details from the source instead of the library")
+	addSourceFlag(infoCmd)
+
+	rootCmd.AddCommand(infoCmd)
+}
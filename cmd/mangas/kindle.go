@@ -2,11 +2,19 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
 
+	"github.com/kerbaras/mangas/pkg/chapterselect"
 	"github.com/kerbaras/mangas/pkg/data"
 	"github.com/kerbaras/mangas/pkg/integrations"
 	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/sources"
+	"github.com/kerbaras/mangas/pkg/utils"
 	"github.com/spf13/cobra"
 )
 
@@ -16,156 +24,275 @@ var kindleCmd = &cobra.Command{
 	Long: `Export downloaded manga chapters to Kindle-optimized format.
 
 Supports all Kindle devices with optimized image processing for better reading experience.
-Use --device to specify your Kindle model for optimal results.
+Use --device to specify your Kindle model for optimal results. Without it, the
+model of a Kindle connected over USB is used when it can be detected, then the
+default_device setting ('mangas config set default_device kindle-oasis3').
+
+On devices with Virtual Panels (Paperwhite, Voyage, Oasis, Scribe and Fire),
+the book is fixed-layout with the panels of each page marked, so a double tap
+zooms on them one at a time. Use --no-panel-view for a plain book.
 
 Examples:
   mangas kindle "One Piece" --device kindle-paperwhite3 --chapters 1-10
   mangas kindle "Naruto" --device kindle-oasis --format mobi
   mangas kindle "Bleach" --device kindle-scribe --chapters 5,6,7
+  mangas kindle --collection Favorites --device kindle-oasis --output ~/kindle
 
 Use 'mangas kindle --list-devices' to see all supported Kindle devices.`,
 	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		// Check if user wants to list devices
-		listDevices, _ := cmd.Flags().GetBool("list-devices")
-		if listDevices {
-			printDeviceList()
-			return
-		}
-
-		if len(args) == 0 {
-			cobra.CheckErr(fmt.Errorf("manga name is required (use --list-devices to see supported devices)"))
-		}
-
-		mangaName := args[0]
-		deviceID, _ := cmd.Flags().GetString("device")
-		format, _ := cmd.Flags().GetString("format")
-		chapters, _ := cmd.Flags().GetString("chapters")
-		output, _ := cmd.Flags().GetString("output")
-		title, _ := cmd.Flags().GetString("title")
-		author, _ := cmd.Flags().GetString("author")
-
-		// Validate device
-		if deviceID == "" {
-			cobra.CheckErr(fmt.Errorf("device is required. Use --list-devices to see available options"))
-		}
-
-		_, ok := integrations.GetDeviceProfile(deviceID)
-		if !ok {
-			cobra.CheckErr(fmt.Errorf("unknown device: %s. Use --list-devices to see available options", deviceID))
-		}
-
-		// Initialize components
-		repo := data.NewDuckDBRepository()
-		controller := services.NewMangaController()
-		defer controller.Close()
-
-		// Find manga in library
-		fmt.Printf("?? Searching for '%s' in library...\n", mangaName)
-		manga, err := controller.FindMangaByName(mangaName)
+		progressStream, out := progressJSONFromFlags(cmd)
+		err := runKindle(cmd, args, progressStream, out)
 		if err != nil {
-			cobra.CheckErr(fmt.Errorf("manga not found in library: %w", err))
+			progressStream.done("", err)
 		}
+		cobra.CheckErr(err)
+	},
+}
 
-		fmt.Printf("? Found: %s (ID: %s)\n", manga.Name, manga.ID)
+func init() {
+	kindleCmd.Flags().StringP("device", "d", "", "Kindle device model (default: the connected Kindle, then the default_device setting)")
+	kindleCmd.Flags().StringP("format", "f", "mobi", "Output format: mobi, azw3, or epub")
+	kindleCmd.Flags().StringP("chapters", "c", "", chapterSelectionHelp)
+	kindleCmd.Flags().StringP("output", "o", "", "Output file path (default: <manga-name>_kindle.<format>)")
+	kindleCmd.Flags().StringP("title", "t", "", "Custom title for the export")
+	kindleCmd.Flags().StringP("author", "a", "", "Custom author name")
+	kindleCmd.Flags().String("cover", "", "Cover image path (default: the manga cover from its source)")
+	kindleCmd.Flags().String("series", "", "Series name for grouping on the device (default: manga name)")
+	kindleCmd.Flags().Float64("series-index", 0, "Position in the series (default: first exported chapter number)")
+	kindleCmd.Flags().String("title-sort", "", "Sort key for the title (default: title)")
+	kindleCmd.Flags().String("collection", "", "Export every downloaded chapter of a collection into per-series folders")
+	addPreprocessFlags(kindleCmd)
+	addProgressJSONFlag(kindleCmd)
+	kindleCmd.Flags().Bool("no-panel-view", false, "Do not mark panels for Virtual Panels on devices supporting it")
+	kindleCmd.Flags().Bool("list-devices", false, "List all supported Kindle devices")
 
-		// Get chapters from library
-		allChapters, err := repo.GetChapters(manga.ID)
-		if err != nil {
-			cobra.CheckErr(fmt.Errorf("failed to get chapters: %w", err))
-		}
+	rootCmd.AddCommand(kindleCmd)
+}
 
-		// Filter chapters based on --chapters flag
-		var selectedChapters []*data.Chapter
-		if chapters == "" {
-			// Export all downloaded chapters
-			for _, ch := range allChapters {
-				if ch.Downloaded && ch.FilePath != "" {
-					selectedChapters = append(selectedChapters, ch)
-				}
-			}
-		} else {
-			selectedChapters = parseChapterSelection(chapters, allChapters)
-		}
+// runKindle exports the chapters of a manga or a collection. Errors are
+// returned rather than checked so the converter and the cover are cleaned up
+// before cobra.CheckErr exits. Messages are printed to out.
+func runKindle(cmd *cobra.Command, args []string, progressStream *progressJSON, out io.Writer) error {
+	// Check if user wants to list devices
+	listDevices, _ := cmd.Flags().GetBool("list-devices")
+	if listDevices {
+		printDeviceList()
+		return nil
+	}
 
-		if len(selectedChapters) == 0 {
-			cobra.CheckErr(fmt.Errorf("no downloaded chapters found matching the selection"))
+	deviceID, _ := cmd.Flags().GetString("device")
+	format, _ := cmd.Flags().GetString("format")
+	chapters, _ := cmd.Flags().GetString("chapters")
+	output, _ := cmd.Flags().GetString("output")
+	title, _ := cmd.Flags().GetString("title")
+	author, _ := cmd.Flags().GetString("author")
+	cover, _ := cmd.Flags().GetString("cover")
+	series, _ := cmd.Flags().GetString("series")
+	seriesIndex, _ := cmd.Flags().GetFloat64("series-index")
+	titleSort, _ := cmd.Flags().GetString("title-sort")
+	noPanelView, _ := cmd.Flags().GetBool("no-panel-view")
+
+	// Validate device
+	if deviceID == "" {
+		var err error
+		deviceID, err = resolveKindleDevice(out)
+		if err != nil {
+			return err
 		}
+	}
 
-		fmt.Printf("?? Selected %d chapter(s) for export\n", len(selectedChapters))
+	_, ok := integrations.GetDeviceProfile(deviceID)
+	if !ok {
+		return fmt.Errorf("unknown device: %s. Use --list-devices to see available options", deviceID)
+	}
 
-		// Determine output path
+	collection, _ := cmd.Flags().GetString("collection")
+	if collection != "" {
 		if output == "" {
-			safeTitle := sanitizeFilename(manga.Name)
-			output = fmt.Sprintf("%s_kindle.%s", safeTitle, format)
-		}
-
-		// Set title if not provided
-		if title == "" {
-			title = manga.Name
+			output = sanitizeFilename(collection) + "_kindle"
 		}
 		if author == "" {
 			author = "MangaDex"
 		}
 
-		fmt.Printf("?? Optimizing for %s...\n", deviceID)
-
-		// Create Kindle converter
 		converter, err := integrations.NewKindleConverter(deviceID)
 		if err != nil {
-			cobra.CheckErr(fmt.Errorf("failed to create converter: %w", err))
+			return fmt.Errorf("failed to create converter: %w", err)
 		}
 		defer converter.Close()
-
-		// Prepare chapter paths
-		chapterPaths := make([]string, len(selectedChapters))
-		for i, ch := range selectedChapters {
-			chapterPaths[i] = ch.FilePath
+		if err := applyExportProfile(cmd, converter, deviceID); err != nil {
+			return err
 		}
 
-		// Set up export options
-		device, _ := integrations.GetDeviceProfile(deviceID)
-		options := integrations.ExportOptions{
-			Device:      device,
-			Format:      integrations.KindleFormat(format),
-			Title:       title,
-			Author:      author,
-			Chapters:    chapterPaths,
-			OutputPath:  output,
-			Optimize:    true,
-			PanelView:   device.PanelView,
-			RightToLeft: true, // Manga reading direction
+		fmt.Fprintf(out, "%s Exporting collection '%s' for %s to %s\n", utils.IconPackage, collection, deviceID, output)
+		manifest, err := exportCollection(out, collection, format, output, func(manga *data.Manga, chapters []*data.Chapter, seriesDir string) ([]string, error) {
+			return exportKindleSeries(converter, manga, chapters, deviceID, format, author, seriesDir, !noPanelView, progressStream.onExport())
+		})
+		if err != nil {
+			return fmt.Errorf("export failed: %w", err)
 		}
+		progressStream.done(output, nil)
+		fmt.Fprintf(out, "%s Exported %d series, manifest: %s\n", utils.IconSuccess, len(manifest.Series), filepath.Join(output, integrations.ManifestFilename))
+		return nil
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("manga name or --collection is required (use --list-devices to see supported devices)")
+	}
+	mangaName := args[0]
+
+	// Initialize components
+	repo := data.NewDuckDBRepository()
+	controller := services.NewMangaController()
+	defer controller.Close()
+
+	// Find manga in library
+	fmt.Fprintf(out, "%s Searching for '%s' in library...\n", utils.IconSearch, mangaName)
+	manga, err := findLibraryManga(out, controller, mangaName)
+	if err != nil {
+		return fmt.Errorf("manga not found in library: %w", err)
+	}
+
+	fmt.Fprintf(out, "%s Found: %s (ID: %s)\n", utils.IconSuccess, manga.Name, manga.ID)
+
+	// Get chapters from library
+	allChapters, err := repo.GetChapters(manga.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get chapters: %w", err)
+	}
+
+	// Filter chapters based on --chapters flag
+	selectedChapters, err := parseChapterSelection(chapters, allChapters)
+	if err != nil {
+		return err
+	}
 
-		fmt.Println("??  Converting and optimizing images...")
+	if len(selectedChapters) == 0 {
+		return fmt.Errorf("no downloaded chapters found matching the selection")
+	}
+
+	fmt.Fprintf(out, "%s Selected %d chapter(s) for export\n", utils.IconList, len(selectedChapters))
+
+	// Determine output path
+	if output == "" {
+		safeTitle := sanitizeFilename(manga.Name)
+		output = fmt.Sprintf("%s_kindle.%s", safeTitle, format)
+	}
 
-		// Convert
-		outputPath, err := converter.ConvertChapters(options)
+	// Set title if not provided
+	if title == "" {
+		title = manga.Name
+	}
+	if author == "" {
+		author = "MangaDex"
+	}
+
+	fmt.Fprintf(out, "%s Optimizing for %s...\n", utils.IconDevice, deviceID)
+
+	// Create Kindle converter
+	converter, err := integrations.NewKindleConverter(deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to create converter: %w", err)
+	}
+	defer converter.Close()
+	if err := applyExportProfile(cmd, converter, deviceID); err != nil {
+		return err
+	}
+
+	// Prepare chapter paths, a volume shared by several chapters only once
+	chapterPaths, chapterNumbers := services.ChapterBooks(selectedChapters)
+
+	// Use the series cover unless a custom one was given
+	if cover == "" {
+		coverPath, err := downloadMangaCover(manga)
 		if err != nil {
-			cobra.CheckErr(fmt.Errorf("conversion failed: %w", err))
+			fmt.Fprintf(out, "%s Exporting without cover: %v\n", utils.IconWarning, err)
+		} else {
+			cover = coverPath
+			defer utils.Temp.Release(coverPath)
 		}
+	}
 
-		fmt.Printf("? Export complete!\n")
-		fmt.Printf("?? Output: %s\n", outputPath)
-		fmt.Printf("?? Optimized for: %s\n", device.Name)
-		fmt.Printf("?? Transfer this file to your Kindle device or email it to your Kindle email address\n")
-	},
+	// Group exports of the same manga as a series on the Kindle home screen
+	if series == "" {
+		series = manga.Name
+	}
+	if seriesIndex == 0 {
+		seriesIndex, _ = strconv.ParseFloat(selectedChapters[0].Number, 64)
+	}
+
+	// Set up export options
+	device, _ := integrations.GetDeviceProfile(deviceID)
+	options := integrations.ExportOptions{
+		Device:         device,
+		Format:         integrations.KindleFormat(format),
+		Title:          title,
+		Author:         author,
+		Chapters:       chapterPaths,
+		ChapterNumbers: chapterNumbers,
+		OutputPath:     output,
+		Optimize:       true,
+		PanelView:      device.PanelView && !noPanelView,
+		RightToLeft:    services.RightToLeft(manga),
+		CoverImage:     cover,
+		Series:         series,
+		SeriesIndex:    seriesIndex,
+		TitleSort:      titleSort,
+		Language:       selectedChapters[0].Language,
+		OnProgress:     progressStream.onExport(),
+	}
+
+	fmt.Fprintln(out, utils.IconImage, "Converting and optimizing images...")
+
+	// Convert
+	outputPath, err := converter.ConvertChapters(options)
+	if report := converter.Report(); report != nil && report.Output != "" {
+		fmt.Fprint(out, report.Summary())
+		fmt.Fprintf(out, "%s Report: %s\n", utils.IconNote, integrations.ReportPath(report.Output))
+	}
+	if err != nil {
+		return fmt.Errorf("conversion failed: %w", err)
+	}
+	progressStream.done(outputPath, nil)
+
+	if resumed := converter.ResumedPages(); resumed > 0 {
+		fmt.Fprintf(out, "%s Resumed %d page(s) processed by an interrupted export\n", utils.IconRestore, resumed)
+	}
+	fmt.Fprintf(out, "%s Export complete!\n", utils.IconSuccess)
+	fmt.Fprintf(out, "%s Output: %s\n", utils.IconFolder, outputPath)
+	fmt.Fprintf(out, "%s Optimized for: %s\n", utils.IconDevice, device.Name)
+	fmt.Fprintln(out, utils.IconTip, "Transfer this file to your Kindle device or email it to your Kindle email address")
+	return nil
 }
 
-func init() {
-	kindleCmd.Flags().StringP("device", "d", "", "Kindle device model (required)")
-	kindleCmd.Flags().StringP("format", "f", "mobi", "Output format: mobi, azw3, or epub")
-	kindleCmd.Flags().StringP("chapters", "c", "", "Chapter selection (e.g., '1-10' or '1,3,5')")
-	kindleCmd.Flags().StringP("output", "o", "", "Output file path (default: <manga-name>_kindle.<format>)")
-	kindleCmd.Flags().StringP("title", "t", "", "Custom title for the export")
-	kindleCmd.Flags().StringP("author", "a", "", "Custom author name")
-	kindleCmd.Flags().Bool("list-devices", false, "List all supported Kindle devices")
+// resolveKindleDevice picks the device profile when --device is omitted: the
+// model of the connected Kindle when known, then the default_device setting,
+// then the profile guessed for a connected Kindle of unknown model
+func resolveKindleDevice(out io.Writer) (string, error) {
+	kindle := integrations.FindKindle()
+	if kindle != nil && kindle.Model != "" {
+		fmt.Fprintf(out, "%s Detected %s at %s, using %s\n", utils.IconPlug, kindle.Model, kindle.Root, kindle.ProfileID)
+		return kindle.ProfileID, nil
+	}
 
-	rootCmd.AddCommand(kindleCmd)
+	deviceID, err := services.GetConfig(data.NewDuckDBRepository(), services.DefaultDeviceKey)
+	if err != nil {
+		return "", err
+	}
+	if deviceID != "" {
+		return deviceID, nil
+	}
+
+	if kindle != nil {
+		fmt.Fprintf(out, "%s Detected a Kindle at %s, using %s (use --device if your model differs)\n", utils.IconPlug, kindle.Root, kindle.ProfileID)
+		return kindle.ProfileID, nil
+	}
+	return "", fmt.Errorf("no Kindle detected: use --device or 'mangas config set default_device <device>' (see --list-devices)")
 }
 
 func printDeviceList() {
-	fmt.Println("📱 Supported Kindle Devices:")
+	fmt.Println(utils.IconDevice, "Supported Kindle Devices:")
 	fmt.Println("E-Ink Readers:")
 	fmt.Println("  kindle-paperwhite3    - Kindle Paperwhite 3/4 (300 DPI)")
 	fmt.Println("  kindle-oasis          - Kindle Oasis 1/2 (300 DPI)")
@@ -182,42 +309,100 @@ func printDeviceList() {
 	fmt.Println("  kindle-fire-hd        - Kindle Fire HD 7 (216 DPI)")
 	fmt.Println("  kindle-fire           - Kindle Fire (169 DPI)")
 	fmt.Println()
-	fmt.Println("?? Recommended devices for manga:")
+	fmt.Println(utils.IconTip, "Recommended devices for manga:")
 	fmt.Println("   - kindle-paperwhite3 (best balance of quality and compatibility)")
 	fmt.Println("   - kindle-oasis3 (larger screen, great for manga)")
 	fmt.Println("   - kindle-scribe (largest screen available)")
 }
 
-func parseChapterSelection(selection string, allChapters []*data.Chapter) []*data.Chapter {
-	var selected []*data.Chapter
-
-	// Create a map of chapter numbers to chapters
-	chapterMap := make(map[string]*data.Chapter)
+// parseChapterSelection returns the downloaded chapters selected by a
+// --chapters flag, all of them when it is empty
+func parseChapterSelection(selection string, allChapters []*data.Chapter) ([]*data.Chapter, error) {
+	var downloaded []*data.Chapter
 	for _, ch := range allChapters {
 		if ch.Downloaded && ch.FilePath != "" {
-			chapterMap[ch.Number] = ch
+			downloaded = append(downloaded, ch)
 		}
 	}
+	return chapterselect.Filter(selection, downloaded)
+}
 
-	// Handle comma-separated list
-	parts := strings.Split(selection, ",")
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
+// exportKindleSeries converts the chapters of one manga of a collection export
+// into a single Kindle file in seriesDir, with the manga cover and series metadata
+func exportKindleSeries(converter *integrations.KindleConverter, manga *data.Manga, chapters []*data.Chapter, deviceID, format, author, seriesDir string, panelView bool, onProgress func(integrations.ExportProgress)) ([]string, error) {
+	return exportDeviceSeries(converter, manga, chapters, deviceID, format, author, "kindle", seriesDir, panelView, onProgress)
+}
 
-		// Handle range (e.g., "1-5")
-		if strings.Contains(part, "-") {
-			// Range parsing would need chapter number parsing
-			// For simplicity, just add all chapters in the range
-			continue
-		}
+// exportDeviceSeries converts the chapters of one manga of a collection export
+// into a single file named <manga>_<suffix>.<format> in seriesDir. Virtual
+// Panels are added when panelView is set and the device supports them, and
+// onProgress, when set, follows the conversion.
+func exportDeviceSeries(exporter integrations.Exporter, manga *data.Manga, chapters []*data.Chapter, deviceID, format, author, suffix, seriesDir string, panelView bool, onProgress func(integrations.ExportProgress)) ([]string, error) {
+	device, _ := integrations.GetExportDevice(deviceID)
 
-		// Single chapter
-		if ch, ok := chapterMap[part]; ok {
-			selected = append(selected, ch)
-		}
+	chapterPaths, chapterNumbers := services.ChapterBooks(chapters)
+
+	cover, err := downloadMangaCover(manga)
+	if err == nil {
+		defer os.Remove(cover)
+	} else {
+		cover = ""
+	}
+	seriesIndex, _ := strconv.ParseFloat(chapters[0].Number, 64)
+
+	outputPath, err := exporter.ConvertChapters(integrations.ExportOptions{
+		Device:         device,
+		Format:         integrations.KindleFormat(format),
+		Title:          manga.Name,
+		Author:         author,
+		Chapters:       chapterPaths,
+		ChapterNumbers: chapterNumbers,
+		OutputPath:     filepath.Join(seriesDir, fmt.Sprintf("%s_%s.%s", sanitizeFilename(manga.Name), suffix, format)),
+		Optimize:       true,
+		PanelView:      panelView && device.PanelView,
+		RightToLeft:    services.RightToLeft(manga),
+		CoverImage:     cover,
+		Series:         manga.Name,
+		SeriesIndex:    seriesIndex,
+		Language:       chapters[0].Language,
+		OnProgress:     onProgress,
+	})
+	if err != nil {
+		return nil, err
 	}
+	return []string{outputPath}, nil
+}
 
-	return selected
+// downloadMangaCover saves the manga cover to a temp file: its custom cover
+// when set, the one of its source otherwise, through the cover cache
+func downloadMangaCover(manga *data.Manga) (string, error) {
+	fallback, err := sources.Get(sources.DefaultSource)
+	if err != nil {
+		return "", err
+	}
+	coverURL, err := services.MangaCoverURL(sources.ForManga(manga, fallback), manga)
+	if err != nil {
+		return "", err
+	}
+	if coverURL == "" {
+		return "", fmt.Errorf("source has no cover")
+	}
+
+	cover, err := services.NewCoverCache(services.DefaultCoverCacheDir()).Get(manga.Source, manga.ID, coverURL)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := utils.Temp.CreateTemp("cover-*" + path.Ext(coverURL))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	if _, err := file.Write(cover.Content); err != nil {
+		utils.Temp.Release(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
 }
 
 func sanitizeFilename(name string) string {
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/sources"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var exportLibraryCmd = &cobra.Command{
+	Use:   "export-library [file]",
+	Short: "Export the library to JSON",
+	Long: `Write the whole library (manga, collections, chapters, download state and
+reading progress) to a JSON file, default mangas-library-<date>.json. Use "-"
+to write to the standard output. Import it with 'mangas import-library'.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := "mangas-library-" + time.Now().Format("20060102-150405") + ".json"
+		if len(args) == 1 {
+			path = args[0]
+		}
+
+		export, err := data.NewDuckDBRepository().ExportLibrary()
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("export failed: %w", err))
+		}
+
+		var out io.Writer = os.Stdout
+		if path != "-" {
+			file, err := os.Create(path)
+			cobra.CheckErr(err)
+			defer file.Close()
+			out = file
+		}
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		cobra.CheckErr(encoder.Encode(export))
+
+		if path != "-" {
+			fmt.Printf("%s Exported %d manga to %s\n", utils.IconUpload, len(export.Mangas), path)
+		}
+	},
+}
+
+var importLibraryCmd = &cobra.Command{
+	Use:   "import-library [file]",
+	Short: "Import a library export or a Tachiyomi/Mihon backup",
+	Long: `Add the manga of a library export (from 'mangas export-library') or of a
+Tachiyomi/Mihon backup (.tachibk, .proto.gz) to the library. Manga already in
+the library are updated with the imported data.
+
+From Tachiyomi backups, only the library entries of sources mangas supports
+are imported, with their categories as collections and the chapters read.
+Run 'mangas update' afterwards to fetch their full chapter lists.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		file, err := os.Open(args[0])
+		cobra.CheckErr(err)
+		defer file.Close()
+
+		var export *data.LibraryExport
+		if isTachiyomiBackup(args[0]) {
+			var report *integrations.TachiyomiReport
+			export, report, err = integrations.ReadTachiyomiBackup(file, sources.Names())
+			cobra.CheckErr(err)
+			for _, title := range report.Skipped {
+				fmt.Printf("  %s Skipped %s (unsupported source)\n", utils.IconWarning, title)
+			}
+		} else {
+			export = &data.LibraryExport{}
+			if err := json.NewDecoder(file).Decode(export); err != nil {
+				cobra.CheckErr(fmt.Errorf("invalid library export: %w", err))
+			}
+		}
+
+		result, err := data.NewDuckDBRepository().ImportLibrary(export)
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("import failed: %w", err))
+		}
+		fmt.Printf("%s Imported %d manga and %d chapters\n", utils.IconDownload, result.Mangas, result.Chapters)
+	},
+}
+
+// isTachiyomiBackup tells Tachiyomi/Mihon backups from library exports by name
+func isTachiyomiBackup(path string) bool {
+	path = strings.ToLower(path)
+	return strings.HasSuffix(path, ".tachibk") || strings.HasSuffix(path, ".proto.gz") || strings.HasSuffix(path, ".proto")
+}
+
+func init() {
+	rootCmd.AddCommand(exportLibraryCmd)
+	rootCmd.AddCommand(importLibraryCmd)
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/sources"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var linkCmd = &cobra.Command{
+	Use:   "link <manga> [other-manga]",
+	Short: "Roll the same manga added from different sources up into one",
+	Long: `Link other-manga, the same series added from another source, to manga:
+its chapters, queued downloads and collections move to manga and it leaves
+the library. Updating manga then fetches the chapters of both sources, and
+downloads take a chapter from the other source only when manga's own source
+lacks it. Both are library names or IDs.
+
+Without other-manga, list the entries linked to manga.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		controller := services.NewMangaController()
+		defer controller.Close()
+
+		manga, err := findLibraryManga(os.Stdout, controller, args[0])
+		cobra.CheckErr(err)
+
+		if len(args) == 1 {
+			links, err := controller.GetMangaLinks(manga.ID)
+			cobra.CheckErr(err)
+			if len(links) == 0 {
+				fmt.Printf("%s '%s' has no linked manga\n", utils.IconInfo, manga.Name)
+				return
+			}
+			fmt.Printf("%s '%s' (%s) is linked to:\n", utils.IconList, manga.Name, mangaSource(manga))
+			for _, link := range links {
+				fmt.Printf("  %s %s on %s (ID: %s)\n", utils.IconBullet, link.Name, link.Source, link.SourceID)
+			}
+			return
+		}
+
+		other, err := findLibraryManga(os.Stdout, controller, args[1])
+		cobra.CheckErr(err)
+		cobra.CheckErr(controller.LinkManga(manga, other))
+		fmt.Printf("%s Linked '%s' (%s) to '%s' (%s)\n", utils.IconSuccess, other.Name, mangaSource(other), manga.Name, mangaSource(manga))
+		fmt.Printf("%s Fetch the chapters of both sources with: mangas update \"%s\"\n", utils.IconTip, manga.Name)
+	},
+}
+
+// mangaSource returns the name of the source a manga was added from
+func mangaSource(manga *data.Manga) string {
+	if manga.Source == "" {
+		return sources.DefaultSource
+	}
+	return manga.Source
+}
+
+func init() {
+	rootCmd.AddCommand(linkCmd)
+}
@@ -2,26 +2,57 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/table"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
 	"github.com/spf13/cobra"
 )
 
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all manga in your library",
-	Long:  "Display all manga in your library in a formatted table",
+	Long: `Display all manga in your library in a formatted table.
+
+Use --search to filter the library by name, description or tags. Names are
+matched approximately, so small typos still find the manga. Use --tag to only
+list manga with a given tag or genre.
+
+With --with-progress, the time left to read the downloaded chapters is
+estimated from their page counts at the reading_speed config (pages per
+minute, 6 by default).
+
+Use --sort to order the library by name, by when manga were added (newest
+first) or by when they were last updated, a new chapter found or downloaded
+counting as an update.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		withProgress, _ := cmd.Flags().GetBool("with-progress")
+		search, _ := cmd.Flags().GetString("search")
+		tag, _ := cmd.Flags().GetString("tag")
+		sortName, _ := cmd.Flags().GetString("sort")
+		sort, err := data.ParseMangaSort(sortName)
+		cobra.CheckErr(err)
+
 		repo := data.NewDuckDBRepository()
-		mangas, err := repo.ListMangas()
+		mangas, err := repo.SearchLibrary(search)
 		if err != nil {
 			cobra.CheckErr(err)
 		}
+		if tag != "" {
+			mangas = filterByTag(mangas, tag)
+		}
+		data.SortMangas(mangas, sort)
 
 		if len(mangas) == 0 {
-			fmt.Println("📚 No manga in library. Use 'mangas search' to find manga to add.")
+			if search != "" || tag != "" {
+				fmt.Println(utils.IconSearch, "No manga in library matches the filters")
+				return
+			}
+			fmt.Println(utils.IconLibrary, "No manga in library. Use 'mangas search' to find manga to add.")
 			return
 		}
 
@@ -33,6 +64,22 @@ var listCmd = &cobra.Command{
 			{Title: "Chapters", Width: 10},
 			{Title: "Downloaded", Width: 12},
 		}
+		if withProgress {
+			columns = append(columns,
+				table.Column{Title: "Read", Width: 10},
+				table.Column{Title: "Last Read", Width: 24},
+				table.Column{Title: "Time Left", Width: 10},
+			)
+		}
+		switch sort {
+		case data.SortAdded:
+			columns = append(columns, table.Column{Title: "Added", Width: 12})
+		case data.SortUpdated:
+			columns = append(columns, table.Column{Title: "Updated", Width: 12})
+		}
+
+		speed, err := services.LoadReadingSpeed(repo)
+		cobra.CheckErr(err)
 
 		rows := []table.Row{}
 		for _, manga := range mangas {
@@ -42,13 +89,23 @@ var listCmd = &cobra.Command{
 				status = "ready"
 			}
 
-			rows = append(rows, table.Row{
+			row := table.Row{
 				truncateString(manga.Name, 38),
 				manga.Source,
 				status,
 				fmt.Sprintf("%d", total),
 				fmt.Sprintf("%d", downloaded),
-			})
+			}
+			if withProgress {
+				row = append(row, readingProgressColumns(repo, manga.ID, speed)...)
+			}
+			switch sort {
+			case data.SortAdded:
+				row = append(row, formatDate(manga.AddedAt))
+			case data.SortUpdated:
+				row = append(row, formatDate(manga.UpdatedAt))
+			}
+			rows = append(rows, row)
 		}
 
 		t := table.New(
@@ -70,7 +127,73 @@ var listCmd = &cobra.Command{
 			Bold(false)
 		t.SetStyles(s)
 
-		fmt.Printf("\n📚 Library (%d manga)\n\n", len(mangas))
+		if search != "" || tag != "" {
+			fmt.Printf("\n%s Library matches (%d manga)\n\n", utils.IconSearch, len(mangas))
+		} else {
+			fmt.Printf("\n%s Library (%d manga)\n\n", utils.IconLibrary, len(mangas))
+		}
 		fmt.Println(t.View())
+
+		if withProgress {
+			if backlog, err := repo.GetReadingBacklog(""); err == nil {
+				if summary := services.ReadingSummary(backlog, speed); summary != "" {
+					fmt.Printf("\n%s Downloaded and unread: %s\n", utils.IconTime, summary)
+				}
+			}
+		}
 	},
 }
+
+// filterByTag keeps the mangas having tag (or genre), ignoring case
+func filterByTag(mangas []*data.Manga, tag string) []*data.Manga {
+	var filtered []*data.Manga
+	for _, manga := range mangas {
+		for _, t := range manga.Tags {
+			if strings.EqualFold(t, tag) {
+				filtered = append(filtered, manga)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// formatDate formats the day of t, "-" when unknown
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02")
+}
+
+// readingProgressColumns returns the Read, Last Read and Time Left cells of
+// a manga, the time left to read its downloaded chapters at speed pages per
+// minute
+func readingProgressColumns(repo *data.Repository, mangaID string, speed float64) []string {
+	progress, err := repo.GetReadingProgress(mangaID)
+	if err != nil {
+		return []string{"-", "-", "-"}
+	}
+
+	lastRead := "-"
+	if progress.LastRead != nil {
+		lastRead = fmt.Sprintf("Ch. %s", progress.LastRead.Number)
+		if !progress.LastRead.Read && progress.LastRead.LastReadPage > 0 {
+			lastRead += fmt.Sprintf(" p.%d", progress.LastRead.LastReadPage)
+		}
+		lastRead += " " + progress.LastRead.ReadAt.Format("2006-01-02")
+	}
+
+	timeLeft := "-"
+	if backlog, err := repo.GetReadingBacklog(mangaID); err == nil && backlog.EstimatedPages() > 0 {
+		timeLeft = services.FormatReadingTime(services.ReadingTime(backlog, speed))
+	}
+	return []string{fmt.Sprintf("%d/%d", progress.Read, progress.Total), lastRead, timeLeft}
+}
+
+func init() {
+	listCmd.Flags().StringP("search", "s", "", "Only show manga whose name, description or tags match")
+	listCmd.Flags().StringP("tag", "t", "", "Only show manga with this tag or genre (e.g. seinen)")
+	listCmd.Flags().String("sort", "", "Order by name, added (newest first) or updated (last updated first)")
+	listCmd.Flags().Bool("with-progress", false, "Show how many chapters were read and the last one read")
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/sources"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var loginCmd = &cobra.Command{
+	Use:   "login mangadex",
+	Short: "Log in to a source account",
+	Long: `Log in to MangaDex with a personal API client, created under Settings > API
+Clients on mangadex.org, to access your account (e.g. 'mangas import
+mangadex-follows').
+
+Credentials can also be given with the MANGADEX_CLIENT_ID,
+MANGADEX_CLIENT_SECRET, MANGADEX_USERNAME and MANGADEX_PASSWORD environment
+variables. The password is asked for when missing and never stored: the
+session is kept next to the library database, in a sessions directory only
+you can read, and renewed automatically.`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"mangadex"},
+	Run: func(cmd *cobra.Command, args []string) {
+		if args[0] != "mangadex" {
+			cobra.CheckErr(fmt.Errorf("login is only supported for mangadex"))
+		}
+
+		credentials := sources.MangaDexCredentials{
+			ClientID:     flagOrEnv(cmd, "client-id", "MANGADEX_CLIENT_ID"),
+			ClientSecret: flagOrEnv(cmd, "client-secret", "MANGADEX_CLIENT_SECRET"),
+			Username:     flagOrEnv(cmd, "username", "MANGADEX_USERNAME"),
+			Password:     os.Getenv("MANGADEX_PASSWORD"),
+		}
+		if credentials.Username == "" {
+			credentials.Username = prompt("Username: ", false)
+		}
+		if credentials.Password == "" {
+			credentials.Password = prompt("Password: ", true)
+		}
+
+		mangadex := sources.NewMangaDex().(*sources.MangaDex)
+		session, err := mangadex.Login(credentials)
+		cobra.CheckErr(err)
+		cobra.CheckErr(saveMangaDexSession(data.NewDuckDBRepository(), session))
+		fmt.Printf("%s Logged in to MangaDex as %s\n", utils.IconKey, session.Username)
+	},
+}
+
+var logoutCmd = &cobra.Command{
+	Use:       "logout mangadex",
+	Short:     "Forget the session of a source account",
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"mangadex"},
+	Run: func(cmd *cobra.Command, args []string) {
+		removed, err := data.NewDuckDBRepository().DeleteSession(args[0])
+		cobra.CheckErr(err)
+		if !removed {
+			fmt.Printf("%s Not logged in to %s\n", utils.IconKey, args[0])
+			return
+		}
+		fmt.Printf("%s Logged out of %s\n", utils.IconKey, args[0])
+	},
+}
+
+// loggedInMangaDex returns a MangaDex source resuming the saved session
+func loggedInMangaDex(repo *data.Repository) (*sources.MangaDex, error) {
+	saved, err := repo.GetSession("mangadex")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+	if saved == "" {
+		return nil, fmt.Errorf("not logged in to MangaDex, run 'mangas login mangadex'")
+	}
+	var session sources.MangaDexSession
+	if err := json.Unmarshal([]byte(saved), &session); err != nil {
+		return nil, fmt.Errorf("invalid session, log in again: %w", err)
+	}
+
+	mangadex := sources.NewMangaDex().(*sources.MangaDex)
+	mangadex.SetSession(&session)
+	return mangadex, nil
+}
+
+// saveMangaDexSession stores a session, including tokens renewed while in use
+func saveMangaDexSession(repo *data.Repository, session *sources.MangaDexSession) error {
+	encoded, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return repo.SaveSession("mangadex", string(encoded))
+}
+
+// flagOrEnv returns a string flag, or the environment variable when the flag
+// is not set
+func flagOrEnv(cmd *cobra.Command, flag, env string) string {
+	if value, _ := cmd.Flags().GetString(flag); value != "" {
+		return value
+	}
+	return os.Getenv(env)
+}
+
+// prompt reads a line from the terminal, without echoing it for secrets
+func prompt(label string, secret bool) string {
+	fmt.Print(label)
+	if secret && term.IsTerminal(os.Stdin.Fd()) {
+		value, _ := term.ReadPassword(os.Stdin.Fd())
+		fmt.Println()
+		return string(value)
+	}
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+func init() {
+	loginCmd.Flags().String("client-id", "", "Personal API client ID")
+	loginCmd.Flags().String("client-secret", "", "Personal API client secret")
+	loginCmd.Flags().String("username", "", "MangaDex username")
+	rootCmd.AddCommand(loginCmd)
+	rootCmd.AddCommand(logoutCmd)
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge [manga-name] [chapter]...",
+	Short: "Merge chapters released in parts into one",
+	Long: `Some groups release a chapter in parts, numbered 12.1, 12.2 and so on.
+Merge combines the downloaded parts into one book, pages in chapter order;
+parts downloaded as CBZ make a CBZ, any others an EPUB:
+
+  mangas merge "Naruto" 12.1 12.2             merged into chapter 12
+  mangas merge "Naruto" 12.1 12.2 12.3 --number 12
+
+Chapters are given by ID or number. The first part becomes the merged
+chapter, its number is locked as with renumber; every part now points to
+the merged book, so none is downloaded again. The files of the parts are
+removed unless --keep is set.`,
+	Args: cobra.MinimumNArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		controller := services.NewMangaController()
+		defer controller.Close()
+
+		manga, err := findLibraryManga(os.Stdout, controller, args[0])
+		cobra.CheckErr(err)
+
+		repo := data.NewDuckDBRepository()
+		chapters, err := repo.GetChapters(manga.ID)
+		cobra.CheckErr(err)
+
+		parts, err := mergeParts(chapters, args[1:])
+		cobra.CheckErr(err)
+
+		number, _ := cmd.Flags().GetString("number")
+		if number == "" {
+			number, err = mergedNumber(parts)
+			cobra.CheckErr(err)
+		}
+		if !utils.IsNumericChapter(number) {
+			cobra.CheckErr(fmt.Errorf("invalid chapter number %q", number))
+		}
+		number = utils.NormalizeChapterNumber(number)
+		cobra.CheckErr(checkMergedNumber(chapters, parts, number))
+
+		merged := *parts[0]
+		merged.Number = number
+		paths := make([]string, len(parts))
+		for i, part := range parts {
+			paths[i] = part.FilePath
+		}
+		path, err := integrations.MergeChapterBooks(manga, &merged, paths, filepath.Dir(paths[0]))
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("merge failed: %w", err))
+		}
+
+		cobra.CheckErr(repo.SetChapterNumber(parts[0].ID, number, true))
+		for _, part := range parts {
+			cobra.CheckErr(repo.UpdateChapterStatus(part.ID, true, path))
+		}
+
+		keep, _ := cmd.Flags().GetBool("keep")
+		if !keep {
+			for _, part := range paths {
+				if part != path {
+					if err := os.Remove(part); err != nil && !os.IsNotExist(err) {
+						fmt.Printf("%s Failed to remove %s: %v\n", utils.IconWarning, part, err)
+					}
+				}
+			}
+		}
+
+		numbers := make([]string, len(parts))
+		for i, part := range parts {
+			numbers[i] = displayNumber(part.Number)
+		}
+		fmt.Printf("%s Merged chapters %s into chapter %s: %s\n", utils.IconSuccess, strings.Join(numbers, ", "), number, path)
+	},
+}
+
+// mergeParts returns the downloaded chapters to merge, in chapter order
+func mergeParts(chapters []*data.Chapter, refs []string) ([]*data.Chapter, error) {
+	var parts []*data.Chapter
+	seen := make(map[string]bool)
+	files := make(map[string]string)
+	for _, ref := range refs {
+		chapter, err := findChapter(chapters, ref)
+		if err != nil {
+			return nil, err
+		}
+		if seen[chapter.ID] {
+			return nil, fmt.Errorf("chapter %s is given twice", ref)
+		}
+		seen[chapter.ID] = true
+
+		if !chapter.Downloaded || chapter.FilePath == "" {
+			return nil, fmt.Errorf("chapter %s is not downloaded", displayNumber(chapter.Number))
+		}
+		if _, err := os.Stat(chapter.FilePath); err != nil {
+			return nil, fmt.Errorf("chapter %s: %w", displayNumber(chapter.Number), err)
+		}
+		// Chapters of a volume EPUB, or merged already, share a file
+		if other, ok := files[chapter.FilePath]; ok {
+			return nil, fmt.Errorf("chapters %s and %s are already in the same file", other, displayNumber(chapter.Number))
+		}
+		files[chapter.FilePath] = displayNumber(chapter.Number)
+		parts = append(parts, chapter)
+	}
+
+	sort.SliceStable(parts, func(i, j int) bool {
+		return utils.ChapterSortKey(parts[i].Number) < utils.ChapterSortKey(parts[j].Number)
+	})
+	return parts, nil
+}
+
+// mergedNumber returns the number the parts share, 12 for 12.1 and 12.2
+func mergedNumber(parts []*data.Chapter) (string, error) {
+	var number string
+	for _, part := range parts {
+		base, _, _ := strings.Cut(utils.NormalizeChapterNumber(part.Number), ".")
+		if base == "" || (number != "" && base != number) {
+			return "", fmt.Errorf("the chapters are not parts of one chapter, set the merged number with --number")
+		}
+		number = base
+	}
+	return number, nil
+}
+
+// checkMergedNumber makes sure no other chapter in the language of the parts
+// already has the merged number
+func checkMergedNumber(chapters, parts []*data.Chapter, number string) error {
+	isPart := make(map[string]bool, len(parts))
+	for _, part := range parts {
+		isPart[part.ID] = true
+	}
+	for _, ch := range chapters {
+		if !isPart[ch.ID] && ch.Language == parts[0].Language && utils.NormalizeChapterNumber(ch.Number) == number {
+			return fmt.Errorf("chapter %s already exists (%s), set another number with --number", number, ch.ID)
+		}
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(mergeCmd)
+	mergeCmd.Flags().String("number", "", "Number of the merged chapter (default: the number the parts share)")
+	mergeCmd.Flags().Bool("keep", false, "Keep the files of the parts")
+}
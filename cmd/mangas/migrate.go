@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade downloads and the library written by older releases",
+	Long: `Bring a library from an older release up to date, once:
+
+  - fill in library rows missing the columns added since
+  - link the flat "{manga}_ch_{number}.epub" downloads the library lost
+    track of back to their chapter
+  - move the downloads to follow the path templates (see 'mangas organize')
+  - record the size and checksums of the books, checked by 'mangas verify'
+
+Files are never overwritten, and if the library can't be updated the moved
+files go back. Run it again with --force to redo what is left to do.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := data.NewDuckDBRepository()
+		force, _ := cmd.Flags().GetBool("force")
+		migratedAt, err := services.LegacyMigratedAt(repo)
+		cobra.CheckErr(err)
+		if !migratedAt.IsZero() && !force {
+			fmt.Printf("%s Already migrated on %s, use --force to run again\n", utils.IconSuccess, migratedAt.Local().Format("2006-01-02 15:04"))
+			return
+		}
+
+		templates, err := services.LoadPathTemplates(repo)
+		cobra.CheckErr(err)
+		dir := services.DefaultDownloadDir()
+
+		fmt.Println(utils.IconRepair, "Migrating the library, checking every book may take a while...")
+		report, err := services.MigrateLegacy(repo, dir, templates)
+		cobra.CheckErr(err)
+
+		fmt.Printf("  %s %d library rows upgraded\n", utils.IconBullet, report.RowsUpgraded)
+		fmt.Printf("  %s %d legacy files found, %d linked back to their chapter\n", utils.IconBullet, report.LegacyFiles, report.Relinked)
+		fmt.Printf("  %s %d books moved to follow the path templates\n", utils.IconBullet, len(report.Organize.Moves))
+		fmt.Printf("  %s %d books got their size and checksums recorded\n", utils.IconBullet, report.Recorded)
+		for _, path := range report.Unmatched {
+			fmt.Printf("%s No chapter for %s, run 'mangas rescan' to add it\n", utils.IconWarning, path)
+		}
+		for _, skipped := range report.Organize.Skipped {
+			fmt.Printf("%s Not moved %s\n", utils.IconWarning, skipped)
+		}
+		for _, damaged := range report.Damaged {
+			fmt.Printf("%s %s is %s, run 'mangas repair'\n", utils.IconWarning, damaged.Path, damaged.Status)
+		}
+		fmt.Println(utils.IconSuccess, "Migration complete")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.Flags().Bool("force", false, "Run even if the library was already migrated")
+}
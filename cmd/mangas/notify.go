@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var notifyCmd = &cobra.Command{
+	Use:   "notify",
+	Short: "Post download events to webhooks (Discord, Slack, Gotify, ntfy)",
+	Long: `List the webhooks notified when a chapter is downloaded, when a manga
+download finishes, or when a chapter fails to download.
+
+Discord, Slack, Gotify and ntfy URLs are recognized, other URLs get the event
+as JSON. Messages are Go templates of the event, with the fields .Manga,
+.Chapter, .ChapterTitle, .Path, .Error, .Downloaded and .Failed:
+
+  mangas notify add discord https://discord.com/api/webhooks/<id>/<token>
+  mangas notify add phone https://ntfy.sh/my-mangas --events manga,error
+  mangas notify add phone https://ntfy.sh/my-mangas \
+    --template "chapter=New {{.Manga}} chapter: {{.Chapter}}"`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		hooks, err := services.LoadWebhooks(data.NewDuckDBRepository())
+		cobra.CheckErr(err)
+		if len(hooks) == 0 {
+			fmt.Println(utils.IconNetwork, "No webhooks. Use 'mangas notify add <name> <url>' to add one.")
+			return
+		}
+		fmt.Printf("%s Webhooks (%d)\n", utils.IconNetwork, len(hooks))
+		for _, hook := range hooks {
+			events := "all events"
+			if len(hook.Events) > 0 {
+				events = strings.Join(hook.Events, ", ")
+			}
+			fmt.Printf("  %s %-12s %-8s %s (%s)\n", utils.IconBullet, hook.Name, hook.Kind, hook.URL, events)
+			for event, template := range hook.Templates {
+				fmt.Printf("    %s: %s\n", event, template)
+			}
+		}
+	},
+}
+
+var notifyAddCmd = &cobra.Command{
+	Use:   "add [name] [url]",
+	Short: "Add a webhook, or replace the one with the same name",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		kind, _ := cmd.Flags().GetString("kind")
+		events, _ := cmd.Flags().GetStringSlice("events")
+		templates, _ := cmd.Flags().GetStringArray("template")
+
+		hook := services.Webhook{Name: args[0], URL: args[1], Kind: kind, Events: events}
+		if hook.Kind == "" {
+			hook.Kind = services.DetectWebhookKind(hook.URL)
+		}
+		for _, value := range templates {
+			event, text, ok := strings.Cut(value, "=")
+			if !ok {
+				cobra.CheckErr(fmt.Errorf("invalid --template %q, expected event=template", value))
+			}
+			if hook.Templates == nil {
+				hook.Templates = make(map[string]string)
+			}
+			hook.Templates[event] = text
+		}
+
+		cobra.CheckErr(services.SaveWebhook(data.NewDuckDBRepository(), hook))
+		fmt.Printf("%s Webhook %s added (%s)\n", utils.IconSuccess, hook.Name, hook.Kind)
+	},
+}
+
+var notifyRemoveCmd = &cobra.Command{
+	Use:   "remove [name]",
+	Short: "Remove a webhook",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(services.RemoveWebhook(data.NewDuckDBRepository(), args[0]))
+		fmt.Printf("%s Webhook %s removed\n", utils.IconDelete, args[0])
+	},
+}
+
+var notifyTestCmd = &cobra.Command{
+	Use:   "test [name]",
+	Short: "Send a sample chapter event to a webhook",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		hooks, err := services.LoadWebhooks(data.NewDuckDBRepository())
+		cobra.CheckErr(err)
+		for _, hook := range hooks {
+			if hook.Name != args[0] {
+				continue
+			}
+			event := services.NotifyEvent{Event: services.EventChapter, Manga: "Test Manga", MangaID: "test", Chapter: "1"}
+			if err := services.SendWebhook(hook, event); err != nil {
+				cobra.CheckErr(fmt.Errorf("webhook %s failed: %w", hook.Name, err))
+			}
+			fmt.Printf("%s Test event sent to %s\n", utils.IconSuccess, hook.Name)
+			return
+		}
+		cobra.CheckErr(fmt.Errorf("no webhook named %q", args[0]))
+	},
+}
+
+func init() {
+	notifyAddCmd.Flags().String("kind", "", "Payload: "+strings.Join(services.WebhookKinds, ", ")+" (default: guessed from the URL)")
+	notifyAddCmd.Flags().StringSlice("events", nil, "Events to send: "+strings.Join(services.NotifyEvents, ", ")+" (default: all)")
+	notifyAddCmd.Flags().StringArray("template", nil, "Message of an event, e.g. \"error={{.Manga}} failed: {{.Error}}\" (repeatable)")
+
+	notifyCmd.AddCommand(notifyAddCmd, notifyRemoveCmd, notifyTestCmd)
+	rootCmd.AddCommand(notifyCmd)
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var openCmd = &cobra.Command{
+	Use:   "open [manga-name]",
+	Short: "Read a downloaded chapter in the default e-book reader",
+	Long: `Open a downloaded chapter with the default application for EPUBs.
+
+With prefetching on ('mangas prefetch 3' or --prefetch), the next chapters not
+downloaded yet are queued and downloaded while you read, so the next one is
+ready when you get there. Press Ctrl+C to stop, they stay queued.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		chapterNumber, _ := cmd.Flags().GetString("chapter")
+		language, _ := cmd.Flags().GetString("language")
+		if chapterNumber == "" {
+			cobra.CheckErr(fmt.Errorf("--chapter is required"))
+		}
+
+		options, err := downloaderOptionsFromFlags(cmd)
+		cobra.CheckErr(err)
+		controller, err := services.NewMangaControllerWithConfig(services.ControllerConfig{
+			SourceType: "mangadex",
+			Downloader: &options,
+		})
+		cobra.CheckErr(err)
+		defer controller.Close()
+
+		manga, err := findLibraryManga(os.Stdout, controller, args[0])
+		cobra.CheckErr(err)
+		chapter, err := findLibraryChapter(controller, manga, chapterNumber, language)
+		cobra.CheckErr(err)
+		if !chapter.Downloaded || chapter.FilePath == "" {
+			cobra.CheckErr(fmt.Errorf("chapter %s is not downloaded, use 'mangas download \"%s\" --chapters %s'", chapter.Number, manga.Name, chapter.Number))
+		}
+
+		cobra.CheckErr(utils.OpenFile(chapter.FilePath))
+		fmt.Printf("%s Opened %s chapter %s\n", utils.IconBook, manga.Name, chapter.Number)
+
+		count, _ := cmd.Flags().GetInt("prefetch")
+		if !cmd.Flags().Changed("prefetch") {
+			count, err = services.LoadPrefetch(data.NewDuckDBRepository())
+			cobra.CheckErr(err)
+		}
+		queued, err := controller.Queue().Prefetch(manga, chapter, count)
+		cobra.CheckErr(err)
+		if len(queued) == 0 {
+			return
+		}
+
+		fmt.Printf("%s Prefetching %d chapter(s) while you read...\n", utils.IconDownload, len(queued))
+		evicted := &evictions{}
+		controller.OnEviction(evicted.add)
+		ctx, stop := interruptContext()
+		defer stop()
+		controller.SetContext(ctx)
+		updates := controller.GetProgressChannel()
+		go func() {
+			for progress := range updates {
+				if progress.Status == "complete" && progress.ChapterNumber != "" {
+					fmt.Printf("  %s Chapter %s ready\n", utils.IconCheck, progress.ChapterNumber)
+				}
+			}
+		}()
+
+		err = controller.Queue().Run(ctx)
+		if errors.Is(err, context.Canceled) {
+			fmt.Printf("\n%s Stopped, remaining chapters stay queued\n", utils.IconStop)
+			evicted.print()
+			return
+		}
+		cobra.CheckErr(err)
+		evicted.print()
+	},
+}
+
+var prefetchCmd = &cobra.Command{
+	Use:   "prefetch [count|off]",
+	Short: "Download the next chapters while one is being read",
+	Long: `Show or set how many chapters after the one being read are downloaded in the
+background, when a chapter is opened with 'mangas open' or from the TUI
+("Open chapter..." in the command palette). Use 'off' to turn it off.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := data.NewDuckDBRepository()
+		if len(args) == 0 {
+			count, err := services.LoadPrefetch(repo)
+			cobra.CheckErr(err)
+			if count == 0 {
+				fmt.Println("Prefetching is off, use 'mangas prefetch 3' to turn it on")
+				return
+			}
+			fmt.Printf("%s Prefetching the next %d chapter(s) when one is opened\n", utils.IconDownload, count)
+			return
+		}
+
+		count := 0
+		if args[0] != "off" {
+			var err error
+			if count, err = strconv.Atoi(args[0]); err != nil || count < 0 {
+				cobra.CheckErr(fmt.Errorf("invalid count %q, use a number of chapters or off", args[0]))
+			}
+		}
+		cobra.CheckErr(services.SavePrefetch(repo, count))
+		if count == 0 {
+			fmt.Println(utils.IconSuccess, "Prefetching turned off")
+			return
+		}
+		fmt.Printf("%s The next %d chapter(s) will be prefetched when one is opened\n", utils.IconSuccess, count)
+	},
+}
+
+func init() {
+	openCmd.Flags().StringP("chapter", "c", "", "Chapter number (required)")
+	openCmd.Flags().StringP("language", "l", "en", "Language code (e.g., en, ja, es)")
+	openCmd.Flags().Int("prefetch", 0, "Chapters to download ahead while reading (default: the 'mangas prefetch' setting)")
+	addDownloaderFlags(openCmd)
+
+	rootCmd.AddCommand(openCmd)
+	rootCmd.AddCommand(prefetchCmd)
+}
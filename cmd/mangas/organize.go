@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var organizeCmd = &cobra.Command{
+	Use:   "organize",
+	Short: "Move downloads to follow the path templates",
+	Long: `Preview, then move the downloaded books so they follow the current path
+templates. Templates only apply to new downloads until organize is run:
+
+  mangas config set chapter_path_template "{manga}/{manga} - c{number}"
+  mangas organize           preview the moves
+  mangas organize --apply   move the files and update the library
+
+Books whose new path is taken, by another book or an existing file, are left
+where they are. If the library can't be updated the files are moved back.
+
+Try a layout without changing the configuration with --chapter-template and
+--volume-template; with --apply they are saved for the next downloads:
+
+  mangas organize --chapter-template "{manga}/{volume}/{manga} - c{number}"`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := data.NewDuckDBRepository()
+		templates, err := services.LoadPathTemplates(repo)
+		cobra.CheckErr(err)
+
+		// Templates tried out with the flags, saved once the files follow them
+		if cmd.Flags().Changed("chapter-template") {
+			templates.Chapter, _ = cmd.Flags().GetString("chapter-template")
+			cobra.CheckErr(integrations.ValidateChapterPathTemplate(templates.Chapter))
+		}
+		if cmd.Flags().Changed("volume-template") {
+			templates.Volume, _ = cmd.Flags().GetString("volume-template")
+			cobra.CheckErr(integrations.ValidateVolumePathTemplate(templates.Volume))
+		}
+
+		dir := services.DefaultDownloadDir()
+		plan, err := services.PlanOrganize(repo, dir, templates)
+		cobra.CheckErr(err)
+
+		for _, move := range plan.Moves {
+			from, _ := filepath.Rel(dir, move.From)
+			to, _ := filepath.Rel(dir, move.To)
+			fmt.Printf("  %s\n    %s %s\n", from, utils.IconArrow, to)
+		}
+		for _, skipped := range plan.Skipped {
+			fmt.Printf("%s Skipped %s\n", utils.IconWarning, skipped)
+		}
+		if len(plan.Moves) == 0 {
+			fmt.Println(utils.IconSuccess, "Downloads already follow the path templates")
+			if apply, _ := cmd.Flags().GetBool("apply"); apply {
+				saveTemplateFlags(cmd, repo)
+			}
+			return
+		}
+
+		apply, _ := cmd.Flags().GetBool("apply")
+		if !apply {
+			fmt.Printf("\n%d books to move, run again with --apply to move them\n", len(plan.Moves))
+			return
+		}
+		cobra.CheckErr(services.ApplyOrganize(repo, plan))
+		fmt.Printf("\n%s Moved %d books\n", utils.IconSuccess, len(plan.Moves))
+		saveTemplateFlags(cmd, repo)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(organizeCmd)
+	organizeCmd.Flags().Bool("apply", false, "Move the files instead of previewing the moves")
+	organizeCmd.Flags().String("chapter-template", "", "Chapter path template to organize by instead of the configured one, saved with --apply")
+	organizeCmd.Flags().String("volume-template", "", "Volume path template to organize by instead of the configured one, saved with --apply")
+}
+
+// saveTemplateFlags saves the templates given to organize as the
+// configured ones, so new downloads follow the layout of the moved files
+func saveTemplateFlags(cmd *cobra.Command, store services.StateStore) {
+	flags := []struct{ key, flag string }{
+		{services.ChapterPathTemplateKey, "chapter-template"},
+		{services.VolumePathTemplateKey, "volume-template"},
+	}
+	for _, f := range flags {
+		key, flag := f.key, f.flag
+		if !cmd.Flags().Changed(flag) {
+			continue
+		}
+		value, _ := cmd.Flags().GetString(flag)
+		cobra.CheckErr(services.SetConfig(store, key, value))
+		fmt.Printf("%s %s set to %q\n", utils.IconSettings, key, value)
+	}
+}
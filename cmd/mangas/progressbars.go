@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+const (
+	progressBarWidth    = 24
+	progressRedrawEvery = 100 * time.Millisecond
+)
+
+// chapterBar is the state of a chapter being downloaded
+type chapterBar struct {
+	number  string
+	page    int
+	pages   int
+	stage   string
+	retries int
+}
+
+// progressBars renders download progress: a bar per chapter being
+// downloaded, updated in place under the finished chapters, and a summary
+// line. In plain mode, for logs and CI, every update is a line instead.
+type progressBars struct {
+	mu      sync.Mutex
+	out     io.Writer
+	plain   bool
+	bars    map[string]*chapterBar
+	order   []string                  // Chapter IDs of bars, in the order they started
+	last    services.DownloadProgress // Latest update, for the summary line
+	drawn   int                       // Lines of the live area on screen
+	drawnAt time.Time
+	started time.Time
+
+	completed, failed int
+}
+
+// addPlainFlag registers the --plain flag on a command
+func addPlainFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("plain", false, "Print progress as plain lines instead of progress bars, the default when the output is not a terminal")
+}
+
+// progressBarsFromFlags returns the progress renderer of a command, plain
+// when asked with --plain or when stdout is not a terminal
+func progressBarsFromFlags(cmd *cobra.Command) *progressBars {
+	plain, _ := cmd.Flags().GetBool("plain")
+	return newProgressBars(os.Stdout, plain || !term.IsTerminal(os.Stdout.Fd()))
+}
+
+func newProgressBars(out io.Writer, plain bool) *progressBars {
+	return &progressBars{out: out, plain: plain, bars: make(map[string]*chapterBar), started: time.Now()}
+}
+
+// update renders a progress update
+func (p *progressBars) update(progress services.DownloadProgress) {
+	if progress.ChapterNumber == "" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.last = progress
+
+	switch progress.Status {
+	case "complete", "error":
+		if progress.Status == "complete" {
+			p.completed++
+		} else {
+			p.failed++
+		}
+		p.remove(progress.ChapterID)
+		p.clear()
+		p.printFinished(progress)
+		p.draw()
+		return
+	}
+
+	if p.plain {
+		p.printPlain(progress)
+		return
+	}
+	bar := p.bars[progress.ChapterID]
+	if bar == nil {
+		bar = &chapterBar{number: progress.ChapterNumber}
+		p.bars[progress.ChapterID] = bar
+		p.order = append(p.order, progress.ChapterID)
+	}
+	bar.page, bar.pages, bar.stage, bar.retries = progress.CurrentPage, progress.TotalPages, progress.Stage, progress.Retries
+	if time.Since(p.drawnAt) >= progressRedrawEvery {
+		p.clear()
+		p.draw()
+	}
+}
+
+// printf prints a message above the bars
+func (p *progressBars) printf(format string, args ...any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	fmt.Fprintf(p.out, format, args...)
+	p.draw()
+}
+
+// finish removes the bars and prints the summary line
+func (p *progressBars) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	p.bars, p.order = make(map[string]*chapterBar), nil
+	if p.completed == 0 && p.failed == 0 {
+		return
+	}
+	elapsed := time.Since(p.started).Round(time.Second)
+	if p.failed > 0 {
+		fmt.Fprintf(p.out, "%s %d chapters downloaded, %d failed in %s\n", utils.IconWarning, p.completed, p.failed, elapsed)
+	} else {
+		fmt.Fprintf(p.out, "%s %d chapters downloaded in %s\n", utils.IconCheck, p.completed, elapsed)
+	}
+}
+
+func (p *progressBars) remove(chapterID string) {
+	delete(p.bars, chapterID)
+	for i, id := range p.order {
+		if id == chapterID {
+			p.order = append(p.order[:i], p.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func (p *progressBars) printFinished(progress services.DownloadProgress) {
+	if progress.Status == "complete" {
+		fmt.Fprintf(p.out, "  %s Chapter %s complete%s\n", utils.IconCheck, progress.ChapterNumber, mangaProgressSuffix(progress))
+	} else {
+		fmt.Fprintf(p.out, "  %s Chapter %s error: %v\n", utils.IconCross, progress.ChapterNumber, progress.Error)
+	}
+}
+
+// printPlain prints an update of a chapter being downloaded as a line
+func (p *progressBars) printPlain(progress services.DownloadProgress) {
+	switch {
+	case progress.Stage == string(integrations.FinalizeRecognizing):
+		fmt.Fprintf(p.out, "  Chapter %s: %d/%d images recognized\n", progress.ChapterNumber, progress.CurrentPage, progress.TotalPages)
+	case progress.Stage == string(integrations.FinalizeStaging):
+		fmt.Fprintf(p.out, "  Chapter %s: %d/%d images staged\n", progress.ChapterNumber, progress.CurrentPage, progress.TotalPages)
+	case progress.Stage == string(integrations.FinalizeWriting):
+		fmt.Fprintf(p.out, "  Chapter %s: writing EPUB\n", progress.ChapterNumber)
+	case progress.TotalPages > 0 && progress.Retries > 0:
+		fmt.Fprintf(p.out, "  Chapter %s: %d/%d pages (page needed %d retries)%s\n", progress.ChapterNumber, progress.CurrentPage, progress.TotalPages, progress.Retries, transferSuffix(progress))
+	case progress.TotalPages > 0:
+		fmt.Fprintf(p.out, "  Chapter %s: %d/%d pages%s\n", progress.ChapterNumber, progress.CurrentPage, progress.TotalPages, transferSuffix(progress))
+	}
+}
+
+// clear erases the live area, the cursor ends where it started
+func (p *progressBars) clear() {
+	if p.drawn == 0 {
+		return
+	}
+	fmt.Fprintf(p.out, "\x1b[%dA", p.drawn)
+	for i := 0; i < p.drawn; i++ {
+		fmt.Fprint(p.out, "\x1b[2K\n")
+	}
+	fmt.Fprintf(p.out, "\x1b[%dA", p.drawn)
+	p.drawn = 0
+}
+
+// draw writes the live area: the bars, then the summary line
+func (p *progressBars) draw() {
+	p.drawnAt = time.Now()
+	if p.plain || len(p.order) == 0 {
+		return
+	}
+	for _, id := range p.order {
+		fmt.Fprintln(p.out, renderChapterBar(p.bars[id]))
+	}
+	fmt.Fprintln(p.out, p.summary())
+	p.drawn = len(p.order) + 1
+}
+
+// summary renders how far the whole download is
+func (p *progressBars) summary() string {
+	parts := []string{fmt.Sprintf("%d downloading", len(p.order))}
+	if p.last.MangaChapters > 1 {
+		parts = append(parts, fmt.Sprintf("%d of %d chapters", p.last.MangaCompleted, p.last.MangaChapters))
+	}
+	if p.failed > 0 {
+		parts = append(parts, fmt.Sprintf("%d failed", p.failed))
+	}
+	if p.last.Speed > 0 {
+		parts = append(parts, services.FormatBytes(int64(p.last.Speed))+"/s")
+	}
+	if eta := services.FormatETA(p.last.ETA); eta != "" {
+		parts = append(parts, "ETA "+eta)
+	}
+	return "  " + strings.Join(parts, " · ")
+}
+
+// renderChapterBar renders the line of a chapter being downloaded
+func renderChapterBar(bar *chapterBar) string {
+	line := fmt.Sprintf("  Chapter %-6s ", bar.number)
+	switch {
+	case bar.stage == string(integrations.FinalizeWriting):
+		return line + renderBar(1, 1) + " writing EPUB"
+	case bar.stage != "":
+		return line + renderBar(bar.page, bar.pages) + fmt.Sprintf(" %s %d/%d", bar.stage, bar.page, bar.pages)
+	case bar.pages == 0:
+		return line + renderBar(0, 0) + " waiting for pages"
+	}
+	line += renderBar(bar.page, bar.pages) + fmt.Sprintf(" %d/%d pages", bar.page, bar.pages)
+	if bar.retries > 0 {
+		line += fmt.Sprintf(" (%d retries)", bar.retries)
+	}
+	return line
+}
+
+func renderBar(done, total int) string {
+	filled := 0
+	if total > 0 {
+		filled = min(done*progressBarWidth/total, progressBarWidth)
+	}
+	return "[" + strings.Repeat("█", filled) + strings.Repeat("░", progressBarWidth-filled) + "]"
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/spf13/cobra"
+)
+
+// progressEvent is a line of the --progress-json stream. Event is
+// "download" for chapter updates, "export" for conversions and "done" when
+// the command finished.
+type progressEvent struct {
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+
+	// Downloads
+	MangaID        string  `json:"manga_id,omitempty"`
+	ChapterID      string  `json:"chapter_id,omitempty"`
+	Chapter        string  `json:"chapter,omitempty"`
+	Status         string  `json:"status,omitempty"` // downloading, processing, complete or error
+	Page           int     `json:"page,omitempty"`
+	Pages          int     `json:"pages,omitempty"`
+	Retries        int     `json:"retries,omitempty"`
+	Bytes          int64   `json:"bytes,omitempty"`
+	Speed          float64 `json:"speed,omitempty"`       // Bytes per second
+	ETA            float64 `json:"eta_seconds,omitempty"` // 0 when unknown
+	MangaChapters  int     `json:"manga_chapters,omitempty"`
+	MangaCompleted int     `json:"manga_completed,omitempty"`
+
+	// Downloads while finalizing, and exports
+	Stage   string `json:"stage,omitempty"`
+	Current int    `json:"current,omitempty"`
+	Total   int    `json:"total,omitempty"`
+	Output  string `json:"output,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// progressJSON writes the --progress-json stream, one JSON object per line
+type progressJSON struct {
+	mu  sync.Mutex
+	out *json.Encoder
+}
+
+// addProgressJSONFlag registers the --progress-json flag on a command
+func addProgressJSONFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("progress-json", false, "Print progress as newline-delimited JSON events on stdout, the usual messages go to stderr")
+}
+
+// progressJSONFromFlags returns the progress stream asked with
+// --progress-json, nil when off, and the writer for the usual messages:
+// stderr while the stream has stdout, so it stays parseable.
+func progressJSONFromFlags(cmd *cobra.Command) (*progressJSON, io.Writer) {
+	if enabled, _ := cmd.Flags().GetBool("progress-json"); !enabled {
+		return nil, os.Stdout
+	}
+	return newProgressJSON(os.Stdout), os.Stderr
+}
+
+func newProgressJSON(w io.Writer) *progressJSON {
+	return &progressJSON{out: json.NewEncoder(w)}
+}
+
+// emit writes an event, stamped with the current time. A nil stream
+// ignores it.
+func (p *progressJSON) emit(event progressEvent) {
+	if p == nil {
+		return
+	}
+	event.Time = time.Now().UTC()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.out.Encode(event)
+}
+
+// download emits a chapter download update
+func (p *progressJSON) download(progress services.DownloadProgress) {
+	event := progressEvent{
+		Event:          "download",
+		MangaID:        progress.MangaID,
+		ChapterID:      progress.ChapterID,
+		Chapter:        progress.ChapterNumber,
+		Status:         progress.Status,
+		Stage:          progress.Stage,
+		Page:           progress.CurrentPage,
+		Pages:          progress.TotalPages,
+		Retries:        progress.Retries,
+		Bytes:          progress.Bytes,
+		Speed:          progress.Speed,
+		ETA:            progress.ETA.Seconds(),
+		MangaChapters:  progress.MangaChapters,
+		MangaCompleted: progress.MangaCompleted,
+	}
+	if progress.Error != nil {
+		event.Error = progress.Error.Error()
+	}
+	p.emit(event)
+}
+
+// export emits a conversion update
+func (p *progressJSON) export(progress integrations.ExportProgress) {
+	p.emit(progressEvent{Event: "export", Stage: string(progress.Stage), Current: progress.Current, Total: progress.Total})
+}
+
+// onExport returns the OnProgress of an export reporting to the stream, nil
+// when off
+func (p *progressJSON) onExport() func(integrations.ExportProgress) {
+	if p == nil {
+		return nil
+	}
+	return p.export
+}
+
+// done emits the end of the command with its output, or its error
+func (p *progressJSON) done(output string, err error) {
+	event := progressEvent{Event: "done", Output: output}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	p.emit(event)
+}
+
+// check ends the command on an error, like cobra.CheckErr, after emitting
+// the "done" event so consumers of the stream learn about it
+func (p *progressJSON) check(err error) {
+	if err != nil {
+		p.done("", err)
+		cobra.CheckErr(err)
+	}
+}
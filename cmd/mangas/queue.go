@@ -0,0 +1,297 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var queueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "Manage the persistent download queue",
+	Long: `Queue chapters for download and process them later.
+
+The queue is stored in the library database, so queued chapters survive
+restarts and an interrupted 'mangas queue run' picks up where it stopped.`,
+}
+
+var queueListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show the download queue",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		controller := services.NewMangaController()
+		defer controller.Close()
+
+		entries, err := controller.Queue().List()
+		cobra.CheckErr(err)
+		if len(entries) == 0 {
+			fmt.Println(utils.IconEmpty, "The download queue is empty. Use 'mangas queue add <manga>' to queue chapters.")
+			return
+		}
+
+		for i, entry := range entries {
+			fmt.Printf("%3d. %s %s\n", i+1, queueStatusIcon(entry.Status), queueEntryLabel(entry))
+			if entry.Error != "" {
+				fmt.Printf("       %s\n", entry.Error)
+			}
+		}
+	},
+}
+
+var queueAddCmd = &cobra.Command{
+	Use:   "add [manga-name]",
+	Short: "Queue chapters of a library manga for download",
+	Long: `Queue the chapters of a library manga that are not downloaded yet.
+
+Examples:
+  mangas queue add "One Piece"
+  mangas queue add "One Piece" --chapters 1-10 --language es`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		chapters, _ := cmd.Flags().GetString("chapters")
+
+		controller := services.NewMangaController()
+		defer controller.Close()
+
+		manga, err := findLibraryManga(os.Stdout, controller, args[0])
+		cobra.CheckErr(err)
+
+		settings, err := controller.GetMangaSettings(manga.ID)
+		cobra.CheckErr(err)
+		queued, err := controller.QueueDownloads(manga, services.DownloadOptions{
+			Language:     flagOrSetting(cmd, "language", settings.Language),
+			ChapterRange: chapters,
+		})
+		cobra.CheckErr(err)
+		fmt.Printf("%s Queued %d chapter(s) of %s. Run 'mangas queue run' to download them.\n", utils.IconDownload, len(queued), manga.Name)
+	},
+}
+
+var queuePauseCmd = &cobra.Command{
+	Use:   "pause [manga-name]",
+	Short: "Hold the waiting chapters of the queue or of one manga",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		controller := services.NewMangaController()
+		defer controller.Close()
+
+		paused, err := controller.Queue().Pause(queueMangaID(controller, args))
+		cobra.CheckErr(err)
+		fmt.Printf("%s Paused %d chapter(s)\n", utils.IconPause, paused)
+	},
+}
+
+var queueResumeCmd = &cobra.Command{
+	Use:   "resume [manga-name]",
+	Short: "Queue paused and failed chapters again",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		controller := services.NewMangaController()
+		defer controller.Close()
+
+		resumed, err := controller.Queue().Resume(queueMangaID(controller, args))
+		cobra.CheckErr(err)
+		fmt.Printf("%s Resumed %d chapter(s)\n", utils.IconResume, resumed)
+	},
+}
+
+var queueMoveCmd = &cobra.Command{
+	Use:   "move [position] [new-position]",
+	Short: "Move a queued chapter to another position",
+	Long: `Move a queued chapter, identified by its position in 'mangas queue list'.
+The new position is a number, top or bottom.
+
+Example:
+  mangas queue move 5 1     # download the fifth chapter next
+  mangas queue move 2 top   # same as 1
+  mangas queue move 1 bottom`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		from, err := strconv.Atoi(args[0])
+		cobra.CheckErr(err)
+
+		controller := services.NewMangaController()
+		defer controller.Close()
+
+		entries, err := controller.Queue().List()
+		cobra.CheckErr(err)
+		if from < 1 || from > len(entries) {
+			cobra.CheckErr(fmt.Errorf("no queued chapter at position %d", from))
+		}
+
+		var to int
+		switch args[1] {
+		case "top":
+			to = 1
+		case "bottom":
+			to = len(entries)
+		default:
+			to, err = strconv.Atoi(args[1])
+			cobra.CheckErr(err)
+		}
+
+		entry := entries[from-1]
+		cobra.CheckErr(controller.Queue().Move(entry.ChapterID, to))
+		fmt.Printf("%s Moved %s to position %d\n", utils.IconMove, queueEntryLabel(entry), to)
+	},
+}
+
+var queueClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove finished chapters from the queue",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		all, _ := cmd.Flags().GetBool("all")
+		failed, _ := cmd.Flags().GetBool("failed")
+
+		controller := services.NewMangaController()
+		defer controller.Close()
+
+		statuses := []string{data.QueueDone}
+		if failed {
+			statuses = append(statuses, data.QueueFailed)
+		}
+		if all {
+			statuses = nil
+		}
+
+		removed, err := controller.Queue().Clear(statuses...)
+		cobra.CheckErr(err)
+		fmt.Printf("%s Removed %d chapter(s) from the queue\n", utils.IconDelete, removed)
+	},
+}
+
+var queueRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Download the queued chapters",
+	Long:  "Download the queued chapters in order until the queue is empty. Press Ctrl+C to stop after the current chapter.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		options, err := downloaderOptionsFromFlags(cmd)
+		cobra.CheckErr(err)
+		altText, err := altTextFromFlags(cmd)
+		cobra.CheckErr(err)
+		ocr, err := ocrOptionsFromFlags(cmd)
+		cobra.CheckErr(err)
+
+		controller, err := services.NewMangaControllerWithConfig(services.ControllerConfig{
+			SourceType: "mangadex",
+			Downloader: &options,
+		})
+		cobra.CheckErr(err)
+		defer controller.Close()
+		controller.SetAltText(altText)
+		controller.SetOCR(ocr)
+		evicted := &evictions{}
+		controller.OnEviction(evicted.add)
+
+		ctx, stop := interruptContext()
+		defer stop()
+		controller.SetContext(ctx)
+
+		updates := controller.GetProgressChannel()
+		go func() {
+			for progress := range updates {
+				if progress.ChapterNumber == "" {
+					continue
+				}
+				if progress.Status == "complete" {
+					fmt.Printf("  %s Chapter %s complete\n", utils.IconCheck, progress.ChapterNumber)
+				} else if progress.Status == "downloading" && progress.TotalPages == 0 {
+					fmt.Printf("%s Chapter %s\n", utils.IconDownload, progress.ChapterNumber)
+				}
+			}
+		}()
+
+		err = controller.Queue().Run(ctx)
+		if err == context.Canceled {
+			fmt.Printf("\n%s Stopped, remaining chapters stay queued\n", utils.IconStop)
+			evicted.print()
+			return
+		}
+		cobra.CheckErr(err)
+		defer evicted.print()
+
+		entries, _ := controller.Queue().List()
+		failed := 0
+		for _, entry := range entries {
+			if entry.Status == data.QueueFailed {
+				failed++
+				fmt.Printf("  %s %s: %s\n", utils.IconCross, queueEntryLabel(entry), entry.Error)
+			}
+		}
+		if failed > 0 {
+			fmt.Printf("\n%s %d chapter(s) failed, use 'mangas queue resume' to retry them\n", utils.IconWarning, failed)
+			return
+		}
+		fmt.Printf("\n%s Queue complete! EPUBs have been created in: %s\n", utils.IconSuccess, controller.GetDownloadDirectory())
+	},
+}
+
+// queueMangaID resolves the optional manga-name argument of a queue command,
+// returning "" for the whole queue
+func queueMangaID(controller *services.MangaController, args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	manga, err := findLibraryManga(os.Stdout, controller, args[0])
+	cobra.CheckErr(err)
+	return manga.ID
+}
+
+// queueEntryLabel describes a queued chapter for display
+func queueEntryLabel(entry *services.QueueEntry) string {
+	name := entry.MangaID
+	if entry.Manga != nil {
+		name = entry.Manga.Name
+	}
+	if entry.Chapter == nil {
+		return fmt.Sprintf("%s (chapter %s)", name, entry.ChapterID)
+	}
+	label := fmt.Sprintf("%s - Chapter %s", name, entry.Chapter.Number)
+	if entry.Chapter.Title != "" {
+		label += ": " + entry.Chapter.Title
+	}
+	return label
+}
+
+func queueStatusIcon(status string) utils.Icon {
+	switch status {
+	case data.QueueActive:
+		return utils.IconWait
+	case data.QueuePaused:
+		return utils.IconPause
+	case data.QueueFailed:
+		return utils.IconCross
+	case data.QueueDone:
+		return utils.IconCheck
+	default:
+		return utils.IconBullet
+	}
+}
+
+func init() {
+	queueAddCmd.Flags().StringP("language", "l", "en", "Language of the chapters to queue (e.g., en, ja, es), the manga's preferred language when unset")
+	queueAddCmd.Flags().StringP("chapters", "c", "", chapterSelectionHelp)
+	queueClearCmd.Flags().Bool("failed", false, "Also remove failed chapters")
+	queueClearCmd.Flags().Bool("all", false, "Remove every chapter, including waiting ones")
+	addDownloaderFlags(queueRunCmd)
+	addOCRFlags(queueRunCmd)
+
+	queueCmd.AddCommand(queueListCmd)
+	queueCmd.AddCommand(queueAddCmd)
+	queueCmd.AddCommand(queuePauseCmd)
+	queueCmd.AddCommand(queueResumeCmd)
+	queueCmd.AddCommand(queueMoveCmd)
+	queueCmd.AddCommand(queueClearCmd)
+	queueCmd.AddCommand(queueRunCmd)
+
+	rootCmd.AddCommand(queueCmd)
+}
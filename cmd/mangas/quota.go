@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var quotaCmd = &cobra.Command{
+	Use:   "quota",
+	Short: "Limit the space taken by downloaded chapters",
+	Long: `Show how much space the downloads take and the storage quota.
+
+Once a quota is set, chapters are evicted after every download that goes over
+it, from the download, queue and update commands and the TUI alike: their
+file is removed and they are marked as not downloaded again. The chapters
+just downloaded are never evicted. The policy picks which go first:
+
+  oldest-read  read chapters, the ones read longest ago first (default);
+               unread chapters are never evicted
+  largest      any chapter, the largest files first
+
+Mangas in the "Favorites" collection are never evicted.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := data.NewDuckDBRepository()
+		quota, err := services.LoadQuota(repo)
+		cobra.CheckErr(err)
+		usage, err := services.DirSize(quotaDownloadDir())
+		cobra.CheckErr(err)
+
+		fmt.Printf("%s Downloads: %s\n", utils.IconDisk, services.FormatBytes(usage))
+		if quota.Limit == 0 {
+			fmt.Println("No quota set, use 'mangas quota set 20GB' to set one")
+			return
+		}
+		fmt.Printf("Quota: %s (%.0f%% used), evicting %s first\n", services.FormatBytes(quota.Limit), float64(usage)/float64(quota.Limit)*100, quota.Policy)
+	},
+}
+
+var quotaSetCmd = &cobra.Command{
+	Use:   "set [size]",
+	Short: "Set the storage quota, e.g. 20GB",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		limit, err := services.ParseSize(args[0])
+		cobra.CheckErr(err)
+		if limit == 0 {
+			cobra.CheckErr(fmt.Errorf("the quota must be above 0, use 'mangas quota off' to turn it off"))
+		}
+		policyFlag, _ := cmd.Flags().GetString("policy")
+		policy, err := services.ParseEvictionPolicy(policyFlag)
+		cobra.CheckErr(err)
+
+		repo := data.NewDuckDBRepository()
+		quota := services.Quota{Limit: limit, Policy: policy}
+		cobra.CheckErr(services.SaveQuota(repo, quota))
+		fmt.Printf("%s Quota set to %s, evicting %s first\n", utils.IconSuccess, services.FormatBytes(limit), policy)
+		enforceQuota(repo)
+	},
+}
+
+var quotaOffCmd = &cobra.Command{
+	Use:   "off",
+	Short: "Remove the storage quota",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(services.SaveQuota(data.NewDuckDBRepository(), services.Quota{}))
+		fmt.Println(utils.IconSuccess, "Quota removed, nothing will be evicted")
+	},
+}
+
+var quotaEnforceCmd = &cobra.Command{
+	Use:   "enforce",
+	Short: "Evict chapters until the downloads fit in the quota",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo := data.NewDuckDBRepository()
+		quota, err := services.LoadQuota(repo)
+		cobra.CheckErr(err)
+		if quota.Limit == 0 {
+			cobra.CheckErr(fmt.Errorf("no quota set, use 'mangas quota set 20GB' to set one"))
+		}
+
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		report, err := services.EnforceQuota(repo, quotaDownloadDir(), quota, dryRun)
+		cobra.CheckErr(err)
+		if len(report.Evicted) == 0 && !report.Over() {
+			fmt.Printf("%s Downloads fit in the quota (%s of %s)\n", utils.IconSuccess, services.FormatBytes(report.Usage), services.FormatBytes(report.Limit))
+			return
+		}
+		printEvictionReport(os.Stdout, report, dryRun)
+	},
+}
+
+// quotaDownloadDir returns the directory the quota applies to
+func quotaDownloadDir() string {
+	return services.DefaultDownloadDir()
+}
+
+// enforceQuota evicts chapters when the downloads went over the quota, if
+// one is set, and reports what was removed. Failures are only reported.
+func enforceQuota(repo *data.Repository) {
+	quota, err := services.LoadQuota(repo)
+	if err == nil && quota.Limit == 0 {
+		return
+	}
+	var report *services.EvictionReport
+	if err == nil {
+		report, err = services.EnforceQuota(repo, quotaDownloadDir(), quota, false)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s Storage quota not enforced: %v\n", utils.IconWarning, err)
+		return
+	}
+	if len(report.Evicted) > 0 || report.Over() {
+		fmt.Println()
+		printEvictionReport(os.Stdout, report, false)
+	}
+}
+
+// evictions collects what the downloads of a command evicted to get under
+// the storage quota, printed once they are done
+type evictions struct {
+	mu     sync.Mutex
+	report *services.EvictionReport // Merged reports, nil when nothing was evicted
+	err    error
+	out    io.Writer // Where the report is printed, os.Stdout when nil
+}
+
+// add records a report of services.Downloader.OnEviction
+func (e *evictions) add(report *services.EvictionReport, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err != nil {
+		e.err = err
+		return
+	}
+	if e.report == nil {
+		e.report = report
+		return
+	}
+	// Usage before every eviction, so what is left is the latest usage
+	e.report = &services.EvictionReport{
+		Usage:   report.Usage + e.report.Freed,
+		Limit:   report.Limit,
+		Freed:   e.report.Freed + report.Freed,
+		Evicted: append(e.report.Evicted, report.Evicted...),
+	}
+}
+
+// print reports the files evicted and a quota that couldn't be enforced
+func (e *evictions) print() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err != nil {
+		fmt.Fprintf(os.Stderr, "%s Storage quota not enforced: %v\n", utils.IconWarning, e.err)
+	}
+	if e.report != nil {
+		out := e.out
+		if out == nil {
+			out = os.Stdout
+		}
+		fmt.Fprintln(out)
+		printEvictionReport(out, e.report, false)
+	}
+}
+
+// printEvictionReport lists the files evicted to get under the quota
+func printEvictionReport(out io.Writer, report *services.EvictionReport, dryRun bool) {
+	verb := "Evicted"
+	if dryRun {
+		verb = "Would evict"
+	}
+	fmt.Fprintf(out, "%s Downloads take %s, over the %s quota\n", utils.IconDisk, services.FormatBytes(report.Usage), services.FormatBytes(report.Limit))
+	for _, file := range report.Evicted {
+		numbers := ""
+		for i, chapter := range file.Chapters {
+			if i > 0 {
+				numbers += ", "
+			}
+			numbers += displayNumber(chapter.Number)
+		}
+		fmt.Fprintf(out, "  %s %s - Chapter %s (%s)\n", utils.IconDelete, file.Manga.Name, numbers, services.FormatBytes(file.Size))
+	}
+	if len(report.Evicted) > 0 {
+		fmt.Fprintf(out, "%s %d file(s), %s freed\n", verb, len(report.Evicted), services.FormatBytes(report.Freed))
+	}
+	if report.Over() {
+		fmt.Fprintf(out, "%s Still %s over the quota: the policy keeps the remaining chapters\n", utils.IconWarning, services.FormatBytes(report.Usage-report.Freed-report.Limit))
+	}
+}
+
+func init() {
+	quotaSetCmd.Flags().String("policy", string(services.EvictOldestRead), "Chapters evicted first: oldest-read or largest")
+	quotaEnforceCmd.Flags().Bool("dry-run", false, "Only list the chapters that would be evicted")
+
+	quotaCmd.AddCommand(quotaSetCmd)
+	quotaCmd.AddCommand(quotaOffCmd)
+	quotaCmd.AddCommand(quotaEnforceCmd)
+	rootCmd.AddCommand(quotaCmd)
+}
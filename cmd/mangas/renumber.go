@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var renumberCmd = &cobra.Command{
+	Use:   "renumber [manga-name] [chapter] [number]",
+	Short: "Fix the number of a chapter",
+	Long: `Chapters a source publishes without a number get one inferred from their
+title ("Ch. 12 — The Duel" becomes 12). Use renumber to fix a wrong guess:
+
+  mangas renumber "Naruto"               list the chapters with their IDs
+  mangas renumber "Naruto" <chapter> 12  set the number of a chapter
+  mangas renumber "Naruto" <chapter> --reset
+
+The chapter is given by ID or current number. Numbers set by hand are kept
+when the library is updated, until reset.`,
+	Args: cobra.RangeArgs(1, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		controller := services.NewMangaController()
+		defer controller.Close()
+
+		manga, err := findLibraryManga(os.Stdout, controller, args[0])
+		cobra.CheckErr(err)
+
+		repo := data.NewDuckDBRepository()
+		chapters, err := repo.GetChapters(manga.ID)
+		cobra.CheckErr(err)
+
+		if len(args) == 1 {
+			for _, ch := range chapters {
+				lock := ""
+				if ch.NumberLocked {
+					lock = " " + utils.IconLock.String()
+				}
+				fmt.Printf("  %-8s %-5s %-36s %s%s\n", ch.Number, ch.Language, ch.ID, truncateString(ch.Title, 40), lock)
+			}
+			return
+		}
+
+		chapter, err := findChapter(chapters, args[1])
+		cobra.CheckErr(err)
+
+		reset, _ := cmd.Flags().GetBool("reset")
+		switch {
+		case reset && len(args) == 2:
+			number := utils.InferChapterNumber(chapter.Title)
+			if number == "" {
+				number = chapter.Number
+			}
+			cobra.CheckErr(repo.SetChapterNumber(chapter.ID, number, false))
+			fmt.Printf("%s Chapter %s unlocked, it follows the source again on the next update\n", utils.IconUnlock, number)
+		case !reset && len(args) == 3:
+			if !utils.IsNumericChapter(args[2]) {
+				cobra.CheckErr(fmt.Errorf("invalid chapter number %q", args[2]))
+			}
+			number := utils.NormalizeChapterNumber(args[2])
+			cobra.CheckErr(repo.SetChapterNumber(chapter.ID, number, true))
+			fmt.Printf("%s Chapter %s is now chapter %s\n", utils.IconSuccess, displayNumber(chapter.Number), number)
+		default:
+			cobra.CheckErr(fmt.Errorf("give either a new number or --reset"))
+		}
+	},
+}
+
+// findChapter returns the chapter with the given ID or number
+func findChapter(chapters []*data.Chapter, ref string) (*data.Chapter, error) {
+	var matches []*data.Chapter
+	for _, ch := range chapters {
+		if ch.ID == ref {
+			return ch, nil
+		}
+		if ch.Number != "" && utils.NormalizeChapterNumber(ch.Number) == utils.NormalizeChapterNumber(ref) {
+			matches = append(matches, ch)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("chapter not found: %s", ref)
+	case 1:
+		return matches[0], nil
+	}
+	ids := make([]string, len(matches))
+	for i, ch := range matches {
+		ids[i] = ch.ID + " (" + ch.Language + ")"
+	}
+	return nil, fmt.Errorf("several chapters are numbered %s, use one of their IDs: %s", ref, strings.Join(ids, ", "))
+}
+
+// displayNumber shows chapters without a number as such
+func displayNumber(number string) string {
+	if number == "" {
+		return "(no number)"
+	}
+	return number
+}
+
+func init() {
+	rootCmd.AddCommand(renumberCmd)
+	renumberCmd.Flags().Bool("reset", false, "Drop the manual number and follow the source again")
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var repairCmd = &cobra.Command{
+	Use:   "repair [manga-name]",
+	Short: "Reset chapters whose downloaded file is gone",
+	Long: `Cross-check the files of the downloaded chapters of a manga, or of the whole
+library, against the file system. Chapters whose file was deleted, moved or
+left empty are marked as not downloaded again.
+
+With --deep, every file is also checked for damage like 'mangas verify' does.
+With --download, the reset chapters are queued and the queue is run right
+away, downloading any chapter already waiting in it as well.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		options, err := downloaderOptionsFromFlags(cmd)
+		cobra.CheckErr(err)
+		controller, err := services.NewMangaControllerWithConfig(services.ControllerConfig{
+			SourceType: "mangadex",
+			Downloader: &options,
+		})
+		cobra.CheckErr(err)
+		defer controller.Close()
+
+		var mangas []*data.Manga
+		if len(args) == 1 {
+			manga, err := findLibraryManga(os.Stdout, controller, args[0])
+			cobra.CheckErr(err)
+			mangas = []*data.Manga{manga}
+		} else {
+			mangas, err = controller.ListLibraryMangas()
+			cobra.CheckErr(err)
+		}
+
+		repo := data.NewDuckDBRepository()
+		stale, err := services.FindStaleChapters(repo, mangas)
+		cobra.CheckErr(err)
+
+		deep, _ := cmd.Flags().GetBool("deep")
+		if deep {
+			report, err := services.VerifyDownloads(repo, repo, mangas, services.VerifyOptions{})
+			cobra.CheckErr(err)
+			// Empty or unreadable files are both stale and corrupt, list them once
+			found := make(map[string]bool, len(stale))
+			for _, s := range stale {
+				found[s.Chapter.ID] = true
+			}
+			for _, result := range report.Damaged {
+				if result.Status != services.VerifyCorrupt {
+					continue // Missing files were found above
+				}
+				for _, chapter := range result.Chapters {
+					if found[chapter.ID] {
+						continue
+					}
+					found[chapter.ID] = true
+					stale = append(stale, services.StaleChapter{Manga: result.Manga, Chapter: chapter, Reason: result.Err.Error()})
+				}
+			}
+		}
+
+		if len(stale) == 0 {
+			fmt.Println(utils.IconSuccess, "Every downloaded chapter has its file")
+			return
+		}
+		for _, s := range stale {
+			fmt.Printf("  %s %s - Chapter %s: %s\n", utils.IconCross, s.Manga.Name, displayNumber(s.Chapter.Number), s.Reason)
+		}
+
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if dryRun {
+			fmt.Printf("\n%d chapter(s) would be marked as not downloaded\n", len(stale))
+			return
+		}
+		for _, s := range stale {
+			cobra.CheckErr(repo.UpdateChapterStatus(s.Chapter.ID, false, ""))
+			cobra.CheckErr(repo.DeleteChapterChecksum(s.Chapter.ID))
+		}
+		fmt.Printf("\n%s Marked %d chapter(s) as not downloaded\n", utils.IconRepair, len(stale))
+
+		download, _ := cmd.Flags().GetBool("download")
+		if !download {
+			fmt.Println("Use 'mangas queue add' or 'mangas download' to download them again.")
+			return
+		}
+
+		// The queue downloads them in order, each with the source of its manga
+		queue := controller.Queue()
+		for _, s := range stale {
+			cobra.CheckErr(queue.Add(s.Manga, s.Chapter))
+		}
+		evicted := &evictions{}
+		controller.OnEviction(evicted.add)
+		ctx, stop := interruptContext()
+		defer stop()
+		controller.SetContext(ctx)
+		updates := controller.GetProgressChannel()
+		go func() {
+			for progress := range updates {
+				if progress.Status == "complete" && progress.ChapterNumber != "" {
+					fmt.Printf("  %s Chapter %s downloaded\n", utils.IconCheck, progress.ChapterNumber)
+				}
+			}
+		}()
+
+		err = queue.Run(ctx)
+		if errors.Is(err, context.Canceled) {
+			fmt.Printf("\n%s Stopped, remaining chapters stay queued\n", utils.IconStop)
+			evicted.print()
+			return
+		}
+		cobra.CheckErr(err)
+		fmt.Printf("\n%s Repaired chapters have been downloaded to: %s\n", utils.IconSuccess, controller.GetDownloadDirectory())
+		evicted.print()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(repairCmd)
+	repairCmd.Flags().Bool("deep", false, "Also read every file, repairing damaged ones too")
+	repairCmd.Flags().Bool("dry-run", false, "Only list the chapters that would be reset")
+	repairCmd.Flags().Bool("download", false, "Download the reset chapters again right away")
+	addDownloaderFlags(repairCmd)
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var rescanCmd = &cobra.Command{
+	Use:   "rescan [dir]",
+	Short: "Rebuild the library from downloaded files",
+	Long: `Walk the download directory (or dir) for EPUB and CBZ files and rebuild the
+library records they describe. Use it to recover the library when the database
+is lost or damaged but the downloads survive.
+
+Manga and chapters already in the library are matched and relinked to their
+files; missing ones are added. Files written by older versions, which carry no
+source IDs, are added under "local-" IDs.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		controller := services.NewMangaController()
+		defer controller.Close()
+
+		dir := controller.GetDownloadDirectory()
+		if len(args) == 1 {
+			dir = args[0]
+		}
+
+		fmt.Printf("%s Scanning %s...\n", utils.IconSearch, dir)
+		report, err := controller.Rescan(dir)
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("rescan failed: %w", err))
+		}
+
+		for _, skipped := range report.Skipped {
+			fmt.Printf("  %s Skipped %s\n", utils.IconWarning, skipped)
+		}
+		fmt.Printf("\n%s Read %d file(s): %d manga added, %d chapter(s) added, %d chapter(s) relinked\n", utils.IconLibrary,
+			report.Files, report.MangasAdded, report.ChaptersAdded, report.ChaptersRepaired)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rescanCmd)
+}
@@ -2,8 +2,13 @@ package cmd
 
 import (
 	"os"
+	"path/filepath"
 
 	"github.com/kerbaras/mangas/pkg/app"
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/log"
+	"github.com/kerbaras/mangas/pkg/sources"
+	"github.com/kerbaras/mangas/pkg/utils"
 	"github.com/spf13/cobra"
 )
 
@@ -11,9 +16,18 @@ var rootCmd = &cobra.Command{
 	Use:   "mangas",
 	Short: "A beautiful manga bookshelf CLI",
 	Long:  "Download and manage your manga collection with a beautiful TUI and CLI",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		applyBlocklist()
+		applyIcons()
+	},
 	Run: func(cmd *cobra.Command, args []string) {
-		// Launch TUI by default
+		// Launch TUI by default, it owns the terminal so logs only go to the file
+		log.Quiet()
+		applyKeys()
 		a := app.NewApp()
+		if dashboard, _ := cmd.Flags().GetBool("dashboard"); dashboard {
+			a.StartOnDashboard()
+		}
 		if err := a.Run(); err != nil {
 			cobra.CheckErr(err)
 		}
@@ -21,6 +35,48 @@ var rootCmd = &cobra.Command{
 }
 
 func init() {
+	rootCmd.Flags().Bool("dashboard", false, "Open the TUI on the home dashboard instead of the library")
+	rootCmd.PersistentFlags().String("db", "", "Library database to use (default: $"+data.DBPathEnv+" or ~/.mangas/mangas.db)")
+	rootCmd.PersistentFlags().Bool("allow-symlinks", false, "Write downloads and exports through symlinked files and directories")
+	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Show informational logs, not only warnings and errors")
+	rootCmd.PersistentFlags().Bool("debug", false, "Show and record debug logs (requests, retries, ...)")
+	addHTTPFlags(rootCmd)
+	cobra.OnInitialize(func() {
+		if path, _ := rootCmd.PersistentFlags().GetString("db"); path != "" {
+			data.SetDBPath(path)
+		}
+		utils.AllowSymlinks, _ = rootCmd.PersistentFlags().GetBool("allow-symlinks")
+
+		verbose, _ := rootCmd.PersistentFlags().GetBool("verbose")
+		debug, _ := rootCmd.PersistentFlags().GetBool("debug")
+		if err := log.Setup(log.Options{Verbose: verbose, Debug: debug}); err != nil {
+			log.Warn("logging to the console only", "err", err)
+		}
+		utils.Stats.OnDegraded(warnDegradedSource)
+		// Before the HTTP options, which can be given for the sites
+		if _, err := sources.LoadSites(sources.DefaultSitesDir()); err != nil {
+			log.Warn("skipped broken site definitions", "err", err)
+		}
+		if _, err := sources.LoadPlugins(sources.DefaultPluginsDir()); err != nil {
+			log.Warn("skipped plugins", "err", err)
+		}
+		cobra.CheckErr(applyHTTPOptions(rootCmd))
+		cobra.CheckErr(applyCacheOptions(rootCmd))
+
+		// Other mangas processes running at the same time share the budget
+		// of each source
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			utils.SharedLimiter.ShareBudgets(filepath.Join(homeDir, ".mangas", "ratelimit"))
+		}
+
+		// Runs that crashed, or exited on an error, leave their temp files
+		if removed, err := utils.Temp.RecoverStale(); err != nil {
+			log.Warn("failed to remove stale temp files", "err", err)
+		} else if removed > 0 {
+			log.Debug("removed stale temp workspaces", "count", removed)
+		}
+	})
+
 	// Add all subcommands
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(downloadCmd)
@@ -28,7 +84,13 @@ func init() {
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	saveSourceStats()
+	if cleanupErr := utils.Temp.Cleanup(); cleanupErr != nil {
+		log.Warn("failed to remove temp files", "err", cleanupErr)
+	}
+	log.Close()
+	if err != nil {
 		os.Exit(1)
 	}
 }
@@ -13,13 +13,24 @@ import (
 var searchCmd = &cobra.Command{
 	Use:   "search [query]",
 	Short: "Search for manga",
-	Long:  "Search for manga on MangaDex and display results in a table",
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Search for manga on a source (MangaDex by default) and display results in a table.
+
+Results can be narrowed down by language, publication status, year and
+content rating, and sorted. Sources ignore the filters they don't support.
+
+Examples:
+  mangas search "one piece"
+  mangas search --status completed --year 2020 romance
+  mangas search --language es --rating safe,suggestive --sort follows isekai`,
+	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		query := strings.Join(args, " ")
-		source := sources.NewMangaDex()
+		source, err := sourceFromFlags(cmd)
+		cobra.CheckErr(err)
+		options, err := searchOptionsFromFlags(cmd)
+		cobra.CheckErr(err)
 
-		results, err := source.Search(query)
+		results, err := source.Search(query, options)
 		if err != nil {
 			cobra.CheckErr(fmt.Errorf("search failed: %w", err))
 		}
@@ -57,6 +68,24 @@ var searchCmd = &cobra.Command{
 	},
 }
 
+// searchOptionsFromFlags returns the search filters set with flags
+func searchOptionsFromFlags(cmd *cobra.Command) (sources.SearchOptions, error) {
+	var options sources.SearchOptions
+	options.Language, _ = cmd.Flags().GetString("language")
+	options.Status, _ = cmd.Flags().GetString("status")
+	options.Year, _ = cmd.Flags().GetInt("year")
+	options.ContentRating, _ = cmd.Flags().GetStringSlice("rating")
+	options.Order, _ = cmd.Flags().GetString("sort")
+	return options, options.Validate()
+}
+
 func init() {
+	addSourceFlag(searchCmd)
+	searchCmd.Flags().StringP("language", "l", "", "Only manga with chapters in this language (e.g., en, ja, es)")
+	searchCmd.Flags().String("status", "", fmt.Sprintf("Publication status (%s)", strings.Join(sources.SearchStatuses, ", ")))
+	searchCmd.Flags().Int("year", 0, "Year of first publication")
+	searchCmd.Flags().StringSlice("rating", nil, fmt.Sprintf("Content ratings (%s)", strings.Join(sources.ContentRatings, ", ")))
+	searchCmd.Flags().String("sort", "", fmt.Sprintf("Sort order (%s)", strings.Join(sources.SearchOrders, ", ")))
+
 	rootCmd.AddCommand(searchCmd)
 }
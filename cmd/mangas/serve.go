@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve your library as an OPDS catalog over HTTP",
+	Long: `Start an HTTP server exposing the downloaded chapters of your library as an
+OPDS catalog. E-readers and apps such as KOReader or Moon+ Reader can add the
+catalog URL to browse the library and download chapters as EPUB or CBZ.
+
+Examples:
+  mangas serve
+  mangas serve --addr :9000 --title "Home Library"`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		addr, _ := cmd.Flags().GetString("addr")
+		title, _ := cmd.Flags().GetString("title")
+
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("failed to listen on %s: %w", addr, err))
+		}
+
+		port := listener.Addr().(*net.TCPAddr).Port
+		fmt.Println(utils.IconNetwork, "Serving OPDS catalog, add one of these URLs to your reader:")
+		for _, host := range lanAddresses() {
+			fmt.Printf("  %s http://%s/opds\n", utils.IconBullet, net.JoinHostPort(host, fmt.Sprint(port)))
+		}
+		fmt.Println("\nPress Ctrl+C to stop")
+
+		server := services.NewLibraryServer(data.NewDuckDBRepository(), title)
+		cobra.CheckErr(http.Serve(listener, server))
+	},
+}
+
+// lanAddresses returns the IPv4 addresses other devices can reach this machine on,
+// falling back to localhost
+func lanAddresses() []string {
+	var hosts []string
+	addrs, _ := net.InterfaceAddrs()
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.To4() == nil {
+			continue
+		}
+		if strings.HasPrefix(ipNet.IP.String(), "169.254.") {
+			continue // Link-local
+		}
+		hosts = append(hosts, ipNet.IP.String())
+	}
+	if len(hosts) == 0 {
+		hosts = append(hosts, "localhost")
+	}
+	return hosts
+}
+
+func init() {
+	serveCmd.Flags().String("addr", ":8080", "Address to listen on")
+	serveCmd.Flags().String("title", "Manga Library", "Catalog title shown by readers")
+
+	rootCmd.AddCommand(serveCmd)
+}
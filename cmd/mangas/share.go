@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var shareCmd = &cobra.Command{
+	Use:   "share [manga-name]",
+	Short: "Export a chapter as a single HTML file readable in any browser",
+	Long: `Package the pages of a chapter into one standalone HTML file, images included,
+with a small reader: arrow keys, swipes or taps turn the pages, 's' switches to
+continuous scrolling. Send it to someone or open it on any device, no reader
+app needed. Use --pages to share a preview of the first pages only.
+
+Downloaded chapters are read from their EPUB, others are fetched from the source.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		chapterNumber, _ := cmd.Flags().GetString("chapter")
+		language, _ := cmd.Flags().GetString("language")
+		output, _ := cmd.Flags().GetString("output")
+		maxPages, _ := cmd.Flags().GetInt("pages")
+		leftToRight, _ := cmd.Flags().GetBool("ltr")
+
+		if chapterNumber == "" {
+			cobra.CheckErr(fmt.Errorf("--chapter is required"))
+		}
+
+		controller := services.NewMangaController()
+		defer controller.Close()
+
+		manga, err := findLibraryManga(os.Stdout, controller, args[0])
+		cobra.CheckErr(err)
+		chapter, err := findLibraryChapter(controller, manga, chapterNumber, language)
+		cobra.CheckErr(err)
+
+		fmt.Printf("%s Collecting pages of %s chapter %s...\n", utils.IconWeb, manga.Name, chapter.Number)
+		pages, err := controller.ChapterPages(manga, chapter)
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("failed to get pages: %w", err))
+		}
+
+		title := fmt.Sprintf("%s - Chapter %s", manga.Name, chapter.Number)
+		if chapter.Title != "" {
+			title += ": " + chapter.Title
+		}
+		page, err := integrations.BuildWebReader(pages, integrations.WebReaderOptions{
+			Title:       title,
+			RightToLeft: !leftToRight,
+			MaxPages:    maxPages,
+		})
+		if err != nil {
+			cobra.CheckErr(fmt.Errorf("failed to build web reader: %w", err))
+		}
+
+		if output == "" {
+			output = integrations.WebReaderFilename(manga, chapter)
+		}
+		cobra.CheckErr(utils.CheckOutputPath(output))
+		if err := os.WriteFile(output, page, 0644); err != nil {
+			cobra.CheckErr(fmt.Errorf("failed to write web reader: %w", err))
+		}
+
+		shared := len(pages)
+		if maxPages > 0 && maxPages < shared {
+			shared = maxPages
+		}
+		fmt.Printf("%s %d of %d pages saved to: %s (%s)\n", utils.IconSuccess, shared, len(pages), output, services.FormatBytes(int64(len(page))))
+	},
+}
+
+func init() {
+	shareCmd.Flags().StringP("chapter", "c", "", "Chapter number (required)")
+	shareCmd.Flags().StringP("language", "l", "en", "Language code (e.g., en, ja, es)")
+	shareCmd.Flags().StringP("output", "o", "", "Output HTML path (default: <manga>_ch_<n>.html)")
+	shareCmd.Flags().Int("pages", 0, "Only include the first pages, for a preview (0 for all)")
+	shareCmd.Flags().Bool("ltr", false, "Read left to right instead of the manga right-to-left direction")
+
+	rootCmd.AddCommand(shareCmd)
+}
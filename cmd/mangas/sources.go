@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/log"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/sources"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var sourcesCmd = &cobra.Command{
+	Use:   "sources",
+	Short: "List the manga sources and how well they work",
+}
+
+var sourcesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the available sources",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, name := range sources.Names() {
+			marker := ""
+			if name == sources.DefaultSource {
+				marker = " (default)"
+			}
+			source, _ := sources.Get(name)
+			switch source.(type) {
+			case *sources.Plugin:
+				marker += " (plugin)"
+			case *sources.Madara:
+				marker += " (site)"
+			}
+			fmt.Printf("  %s %s%s\n", utils.IconBullet, name, marker)
+		}
+	},
+}
+
+var sourcesInstallCmd = &cobra.Command{
+	Use:   "install <program>",
+	Short: "Install a source plugin",
+	Long: `Install a source plugin: a program speaking the mangas plugin protocol,
+copied to ~/.mangas/plugins (or $` + sources.PluginsDirEnv + `) under the name it
+reports. Installing a plugin again upgrades it.
+
+Plugins run with your permissions, only install the ones you trust.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		info, err := sources.InstallPlugin(args[0], sources.DefaultPluginsDir())
+		cobra.CheckErr(err)
+		version := ""
+		if info.Version != "" {
+			version = " " + info.Version
+		}
+		fmt.Printf("%s Installed plugin %s%s\n", utils.IconCheck, info.Name, version)
+		fmt.Printf("%s Use it with: mangas search --source %s <query>\n", utils.IconTip, info.Name)
+	},
+}
+
+var sourcesRemoveCmd = &cobra.Command{
+	Use:   "remove <plugin>",
+	Short: "Remove a source plugin",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(sources.RemovePlugin(args[0], sources.DefaultPluginsDir()))
+		fmt.Printf("%s Removed plugin %s\n", utils.IconCheck, args[0])
+	},
+}
+
+var sourcesStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show request counts, error rates and latency per source",
+	Long: `Show the requests made to each source, API calls and image downloads alike:
+how many failed, how long they took on average and how much was downloaded.
+Sources where too many requests failed or were slow are flagged.
+
+Examples:
+  mangas sources status            the last 7 days
+  mangas sources status --days 1   today only
+  mangas sources status --days 0   all time`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		days, _ := cmd.Flags().GetInt("days")
+		var since time.Time
+		if days > 0 {
+			since = time.Now().AddDate(0, 0, -(days - 1))
+		}
+
+		stats, err := data.NewDuckDBRepository().GetSourceStats(since)
+		cobra.CheckErr(err)
+		if len(stats) == 0 {
+			fmt.Println(utils.IconNetwork, "No requests recorded yet")
+			return
+		}
+
+		period := "all time"
+		if days > 0 {
+			period = fmt.Sprintf("last %d days", days)
+		}
+		fmt.Printf("%s Source status (%s)\n\n", utils.IconNetwork, period)
+		fmt.Printf("  %-12s %10s %16s %12s %12s\n", "SOURCE", "REQUESTS", "FAILURES", "AVG LATENCY", "DOWNLOADED")
+		var degraded []string
+		for _, stat := range stats {
+			counters := sourceCounters(stat)
+			failures := fmt.Sprintf("%d (%.0f%%)", counters.Failures, counters.FailureRate()*100)
+			fmt.Printf("  %-12s %10d %16s %12s %12s\n", stat.Source, counters.Requests, failures,
+				counters.AverageLatency().Round(time.Millisecond), services.FormatBytes(counters.Bytes))
+			if reason := counters.Degraded(); reason != "" {
+				degraded = append(degraded, fmt.Sprintf("%s: %s", stat.Source, reason))
+			}
+		}
+		if len(degraded) > 0 {
+			fmt.Printf("\n%s Degraded: %s\n", utils.IconWarning, strings.Join(degraded, "; "))
+		}
+	},
+}
+
+// sourceCounters converts stored stats to counters
+func sourceCounters(stat *data.SourceStat) utils.SourceCounters {
+	return utils.SourceCounters{
+		Requests: stat.Requests,
+		Failures: stat.Failures,
+		Latency:  stat.Latency,
+		Bytes:    stat.Bytes,
+	}
+}
+
+// warnDegradedSource tells the user a source works badly during the run
+func warnDegradedSource(source, reason string) {
+	log.Warn("source is degraded, downloads may fail or be slow", "source", source, "reason", reason)
+}
+
+// saveSourceStats stores the requests counted during the run in the library.
+// Runs ending on an error exit before, their requests are not stored.
+func saveSourceStats() {
+	taken := utils.Stats.Take()
+	if len(taken) == 0 {
+		return
+	}
+	repo := data.NewDuckDBRepository()
+	today := time.Now()
+	for _, source := range utils.SourceNames(taken) {
+		counters := taken[source]
+		err := repo.AddSourceStat(&data.SourceStat{
+			Source:   source,
+			Day:      today,
+			Requests: counters.Requests,
+			Failures: counters.Failures,
+			Latency:  counters.Latency,
+			Bytes:    counters.Bytes,
+		})
+		if err != nil {
+			log.Warn("failed to save source stats", "source", source, "err", err)
+			return
+		}
+	}
+}
+
+func init() {
+	sourcesStatusCmd.Flags().Int("days", 7, "Days to cover, 0 for all time")
+
+	sourcesCmd.AddCommand(sourcesListCmd, sourcesInstallCmd, sourcesRemoveCmd, sourcesStatusCmd)
+	rootCmd.AddCommand(sourcesCmd)
+}
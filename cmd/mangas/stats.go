@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/kerbaras/mangas/pkg/app/components"
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show library statistics: chapters, disk usage, pages read and downloads",
+	Long: `Show how big the library is, how much of it was read, the disk space used
+by the downloaded chapters of each manga and how many chapters were
+downloaded on each of the last days.
+
+Disk usage and page counts come from what was recorded when the chapters
+were downloaded; chapters downloaded before are only counted once 'mangas
+verify' records them.
+
+Examples:
+  mangas stats
+  mangas stats --days 30 --top 20`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		days, _ := cmd.Flags().GetInt("days")
+		top, _ := cmd.Flags().GetInt("top")
+
+		repo := data.NewDuckDBRepository()
+		stats, err := repo.GetLibraryStats(days)
+		cobra.CheckErr(err)
+		if stats.Mangas == 0 {
+			fmt.Println(utils.IconLibrary, "No manga in library. Use 'mangas search' to find manga to add.")
+			return
+		}
+
+		fmt.Printf("%s Library statistics\n\n", utils.IconStats)
+		fmt.Printf("  %-14s %d\n", "Manga", stats.Mangas)
+		fmt.Printf("  %-14s %d (%d downloaded, %d read)\n", "Chapters", stats.Chapters, stats.Downloaded, stats.Read)
+		fmt.Printf("  %-14s %d\n", "Pages read", stats.PagesRead)
+		fmt.Printf("  %-14s %s\n", "Disk usage", services.FormatBytes(stats.DiskUsage))
+		speed, err := services.LoadReadingSpeed(repo)
+		cobra.CheckErr(err)
+		if backlog, err := repo.GetReadingBacklog(""); err == nil {
+			if summary := services.ReadingSummary(backlog, speed); summary != "" {
+				fmt.Printf("  %-14s %s\n", "To read", summary)
+			}
+		}
+
+		perManga := stats.PerManga
+		if top > 0 && len(perManga) > top {
+			perManga = perManga[:top]
+		}
+		fmt.Printf("\n  %-32s %9s %11s %6s %10s\n", "MANGA", "CHAPTERS", "DOWNLOADED", "READ", "DISK")
+		for _, manga := range perManga {
+			fmt.Printf("  %-32s %9d %11d %6d %10s %s\n", truncateString(manga.Name, 32),
+				manga.Chapters, manga.Downloaded, manga.Read, services.FormatBytes(manga.DiskUsage),
+				components.HorizontalBar(manga.DiskUsage, stats.DiskUsage, 20))
+		}
+		if hidden := len(stats.PerManga) - len(perManga); hidden > 0 {
+			fmt.Printf("  ... and %d more\n", hidden)
+		}
+
+		if len(stats.Downloads) > 0 {
+			counts := make([]int, len(stats.Downloads))
+			total := 0
+			for i, day := range stats.Downloads {
+				counts[i] = day.Count
+				total += day.Count
+			}
+			fmt.Printf("\n%s Chapters downloaded, last %d days: %d\n\n", utils.IconDownload, len(counts), total)
+			fmt.Printf("  %s\n", components.Sparkline(counts))
+			first, last := stats.Downloads[0].Day, stats.Downloads[len(counts)-1].Day
+			fmt.Printf("  %-*s%s\n", max(len(counts)-5, 6), first.Format("01-02"), last.Format("01-02"))
+		}
+	},
+}
+
+func init() {
+	statsCmd.Flags().Int("days", 14, "Days of downloads to chart, 0 to leave the chart out")
+	statsCmd.Flags().Int("top", 10, "Manga listed by disk usage, 0 for all")
+
+	rootCmd.AddCommand(statsCmd)
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync [mount-path] [manga-name...]",
+	Short: "Copy new and updated chapters to a mounted e-reader",
+	Long: `Copy the downloaded chapters missing on a mounted e-reader to it, into
+one folder per series under mangas/ (documents/mangas/ on Kindles).
+
+Kindles and Kobos are recognized from their folders. Chapters are compared by
+hash with what was copied before, which is recorded in the library, so only
+new or updated chapters, and the ones deleted from the device, are copied.
+With --convert, chapters are converted for the device first, as 'mangas
+export' does, using the profile of the detected device unless --device is
+given.
+
+With --import-read, chapters read on the device with KOReader are marked as
+read in the library first, from the sidecar folders KOReader keeps next to
+the books. Finished books mark all their chapters as read, volumes read
+partway the chapters before the position reached.
+
+Examples:
+  mangas sync /media/Kindle --convert --format azw3
+  mangas sync /Volumes/KOBOeReader "One Piece" --convert --device kobo-libra
+  mangas sync /media/reader --collection Favorites --dry-run
+  mangas sync /media/Kindle --import-read`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		convert, _ := cmd.Flags().GetBool("convert")
+		deviceID, _ := cmd.Flags().GetString("device")
+		format, _ := cmd.Flags().GetString("format")
+		collection, _ := cmd.Flags().GetString("collection")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		importRead, _ := cmd.Flags().GetBool("import-read")
+
+		device, err := integrations.DetectDevice(args[0])
+		cobra.CheckErr(err)
+		fmt.Printf("%s Found a %s reader at %s\n", utils.IconPlug, device.Kind, device.Root)
+
+		repo := data.NewDuckDBRepository()
+		controller := services.NewMangaController()
+		defer controller.Close()
+
+		options := services.DeviceSyncOptions{DryRun: dryRun}
+		for _, name := range args[1:] {
+			manga, err := findLibraryManga(os.Stdout, controller, name)
+			cobra.CheckErr(err)
+			options.MangaIDs = append(options.MangaIDs, manga.ID)
+		}
+		if collection != "" {
+			mangas, err := repo.GetCollection(collection)
+			cobra.CheckErr(err)
+			if len(mangas) == 0 {
+				cobra.CheckErr(fmt.Errorf("collection '%s' is empty or does not exist", collection))
+			}
+			for _, manga := range mangas {
+				options.MangaIDs = append(options.MangaIDs, manga.ID)
+			}
+		}
+
+		if importRead {
+			importReadStatus(repo, device, options)
+		}
+
+		if convert {
+			if deviceID == "" {
+				deviceID = device.ProfileID
+			}
+			if _, ok := integrations.GetExportDevice(deviceID); !ok {
+				cobra.CheckErr(fmt.Errorf("unknown device: %s. Use 'mangas export --list-devices' to see available options", deviceID))
+			}
+			if format == "" {
+				format = string(integrations.FormatMOBI)
+				if integrations.IsFixedLayoutDevice(deviceID) {
+					format = string(integrations.FormatEPUB)
+				}
+			}
+			exporter, err := integrations.NewExporter(deviceID)
+			cobra.CheckErr(err)
+			defer exporter.Close()
+			cobra.CheckErr(applyExportProfile(cmd, exporter, deviceID))
+
+			fmt.Printf("%s Converting chapters for %s (%s)\n", utils.IconSync, deviceID, format)
+			options.Convert = func(manga *data.Manga, chapters []*data.Chapter, outputDir string) (string, error) {
+				suffix := "ch_" + chapters[0].Number
+				if len(chapters) > 1 && chapters[0].Volume != "" {
+					suffix = "vol_" + chapters[0].Volume
+				}
+				files, err := exportDeviceSeries(exporter, manga, chapters, deviceID, format, "MangaDex", suffix, outputDir, true, nil)
+				if err != nil {
+					return "", err
+				}
+				return files[0], nil
+			}
+		}
+
+		verb := "Copied"
+		if dryRun {
+			verb = "Would copy"
+		}
+		options.OnFile = func(file services.DeviceSyncFile) {
+			if file.Err != nil {
+				fmt.Printf("  %s %s: %v\n", utils.IconCross, file.Chapters[0].FilePath, file.Err)
+				return
+			}
+			fmt.Printf("  %s %s (%s)\n", verb, file.Path, file.Action)
+		}
+
+		report, err := services.SyncDevice(repo, repo, device, options)
+		cobra.CheckErr(err)
+
+		if dryRun {
+			fmt.Printf("%s %d to copy, %d already on the device\n", utils.IconList, len(report.Files), report.UpToDate)
+			return
+		}
+		fmt.Printf("%s %d copied, %d already on the device, %d failed\n", utils.IconSuccess,
+			len(report.Files)-report.Failed, report.UpToDate, report.Failed)
+	},
+}
+
+// importReadStatus marks the chapters read on the device as read in the
+// library, reporting what was found
+func importReadStatus(repo *data.Repository, device *integrations.Device, options services.DeviceSyncOptions) {
+	if device.ID == "" {
+		fmt.Println(utils.IconBook, "Nothing synced to this reader yet, no read status to import")
+		return
+	}
+
+	verb := "Marked"
+	if options.DryRun {
+		verb = "Would mark"
+	}
+	report, err := services.ImportDeviceReadStatus(repo, repo, repo, device, services.ReadStatusOptions{
+		MangaIDs: options.MangaIDs,
+		DryRun:   options.DryRun,
+		OnBook: func(book services.ReadStatusBook) {
+			if book.Err != nil {
+				fmt.Printf("  %s %s: %v\n", utils.IconCross, book.Path, book.Err)
+				return
+			}
+			for _, chapter := range book.Marked {
+				fmt.Printf("  %s %s chapter %s as read (%.0f%% of %s)\n",
+					verb, book.Manga.Name, chapter.Number, book.Progress.Percent*100, book.Path)
+			}
+		},
+	})
+	cobra.CheckErr(err)
+	fmt.Printf("%s %d books opened on the reader, %d chapters newly read\n", utils.IconBook, len(report.Books), report.Marked)
+}
+
+func init() {
+	syncCmd.Flags().Bool("convert", false, "Convert chapters for the device before copying them")
+	syncCmd.Flags().StringP("device", "d", "", "Device profile used with --convert (default: detected from the device)")
+	syncCmd.Flags().StringP("format", "f", "", "Output format used with --convert (default: mobi for Kindles, epub otherwise)")
+	syncCmd.Flags().String("collection", "", "Only sync the manga of a collection")
+	syncCmd.Flags().Bool("dry-run", false, "Only list what would be copied")
+	syncCmd.Flags().Bool("import-read", false, "Mark the chapters read on the device with KOReader as read in the library")
+
+	rootCmd.AddCommand(syncCmd)
+}
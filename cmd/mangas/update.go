@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var updateCmd = &cobra.Command{
+	Use:   "update [manga-name]",
+	Short: "Check your library for new chapters",
+	Long:  "Re-fetch the chapter list of every manga in your library (or a single one) and store newly released chapters",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		download, _ := cmd.Flags().GetBool("download")
+		options, err := downloaderOptionsFromFlags(cmd)
+		cobra.CheckErr(err)
+		altText, err := altTextFromFlags(cmd)
+		cobra.CheckErr(err)
+		ocr, err := ocrOptionsFromFlags(cmd)
+		cobra.CheckErr(err)
+
+		controller, err := services.NewMangaControllerWithConfig(services.ControllerConfig{
+			SourceType: "mangadex",
+			Downloader: &options,
+		})
+		cobra.CheckErr(err)
+		defer controller.Close()
+
+		var results []*services.SyncResult
+		if len(args) == 1 {
+			manga, err := findLibraryManga(os.Stdout, controller, args[0])
+			cobra.CheckErr(err)
+
+			fmt.Printf("%s Checking '%s' for new chapters...\n", utils.IconSync, manga.Name)
+			result, err := controller.SyncManga(manga)
+			if err != nil {
+				cobra.CheckErr(fmt.Errorf("update failed: %w", err))
+			}
+			results = append(results, result)
+		} else {
+			fmt.Println(utils.IconSync, "Checking library for new chapters...")
+			results, err = controller.SyncLibrary()
+			if err != nil {
+				cobra.CheckErr(fmt.Errorf("update failed: %w", err))
+			}
+		}
+
+		total := 0
+		for _, result := range results {
+			switch {
+			case result.Err != nil:
+				fmt.Printf("  %s %s: %v\n", utils.IconCross, result.Manga.Name, result.Err)
+			case len(result.NewChapters) > 0:
+				fmt.Printf("  %s %s: %d new chapters\n", utils.IconNew, result.Manga.Name, len(result.NewChapters))
+			default:
+				fmt.Printf("  %s %s: up to date\n", utils.IconCheck, result.Manga.Name)
+			}
+			total += len(result.NewChapters)
+		}
+		fmt.Printf("\n%s Found %d new chapters\n", utils.IconLibrary, total)
+
+		if !download || total == 0 {
+			return
+		}
+
+		evicted := &evictions{}
+		controller.OnEviction(evicted.add)
+
+		// Ctrl-C stops the downloads, the new chapters left stay in the library
+		ctx, stop := interruptContext()
+		defer stop()
+		controller.SetContext(ctx)
+
+		updates := controller.GetProgressChannel()
+		go func() {
+			for progress := range updates {
+				if progress.ChapterNumber == "" {
+					continue
+				}
+				if progress.Status == "complete" {
+					fmt.Printf("  %s Chapter %s complete\n", utils.IconCheck, progress.ChapterNumber)
+				} else if progress.Status == "error" {
+					fmt.Printf("  %s Chapter %s error: %v\n", utils.IconCross, progress.ChapterNumber, progress.Error)
+				}
+			}
+		}()
+
+		for _, result := range results {
+			if len(result.NewChapters) == 0 {
+				continue
+			}
+
+			ids := make([]string, len(result.NewChapters))
+			for i, ch := range result.NewChapters {
+				ids[i] = ch.ID
+			}
+
+			// The manga's own settings, unless --language was set
+			settings, err := controller.GetMangaSettings(result.Manga.ID)
+			if err != nil {
+				fmt.Printf("  %s %s: %v\n", utils.IconCross, result.Manga.Name, err)
+				continue
+			}
+			language := flagOrSetting(cmd, "language", settings.Language)
+			fmt.Printf("\n%s Downloading new chapters of %s (language: %s)\n", utils.IconDownload, result.Manga.Name, language)
+			err = controller.DownloadManga(result.Manga, services.DownloadOptions{
+				Language:   language,
+				ChapterIDs: ids,
+				OCR:        ocr,
+				AltText:    altText,
+			})
+			if errors.Is(err, context.Canceled) {
+				fmt.Printf("\n%s Interrupted, queue the chapters left with: mangas queue add \"%s\"\n", utils.IconStop, result.Manga.Name)
+				evicted.print()
+				return
+			}
+			if err != nil {
+				fmt.Printf("  %s %s: %v\n", utils.IconCross, result.Manga.Name, err)
+			}
+		}
+
+		fmt.Printf("\n%s Update complete! EPUBs have been created in: %s\n", utils.IconSuccess, controller.GetDownloadDirectory())
+		evicted.print()
+	},
+}
+
+func init() {
+	updateCmd.Flags().Bool("download", false, "Download the new chapters right away")
+	updateCmd.Flags().StringP("language", "l", "en", "Language of the chapters to download (e.g., en, ja, es), each manga's preferred language when unset")
+	addDownloaderFlags(updateCmd)
+	addOCRFlags(updateCmd)
+
+	rootCmd.AddCommand(updateCmd)
+}
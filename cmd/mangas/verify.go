@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [manga-name]",
+	Short: "Check downloaded chapters for missing or damaged files",
+	Long: `Check the downloaded chapters of a manga, or of the whole library, against
+the SHA-256 checksums recorded when they were downloaded.
+
+Files that changed, or were downloaded before checksums were recorded, have
+every page read and decoded; intact ones get their checksums recorded. With
+--fix, the chapters of missing and damaged files are marked as not downloaded
+and queued, run 'mangas queue run' to download them again.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		controller := services.NewMangaController()
+		defer controller.Close()
+
+		var mangas []*data.Manga
+		if len(args) == 1 {
+			manga, err := findLibraryManga(os.Stdout, controller, args[0])
+			cobra.CheckErr(err)
+			mangas = []*data.Manga{manga}
+		} else {
+			var err error
+			mangas, err = controller.ListLibraryMangas()
+			cobra.CheckErr(err)
+		}
+
+		deep, _ := cmd.Flags().GetBool("deep")
+		fix, _ := cmd.Flags().GetBool("fix")
+		repo := data.NewDuckDBRepository()
+		report, err := services.VerifyDownloads(repo, repo, mangas, services.VerifyOptions{
+			Deep: deep,
+			OnFile: func(result services.VerifyResult) {
+				switch result.Status {
+				case services.VerifyMissing:
+					fmt.Printf("  %s %s %s: file is missing (%s)\n", utils.IconCross, result.Manga.Name, verifyChapters(result), result.Path)
+				case services.VerifyCorrupt:
+					fmt.Printf("  %s %s %s: %v\n", utils.IconCross, result.Manga.Name, verifyChapters(result), result.Err)
+				}
+			},
+		})
+		cobra.CheckErr(err)
+
+		fmt.Printf("%s Checked %d file(s): %d intact, %d damaged\n", utils.IconSearch, report.Files, report.OK, len(report.Damaged))
+		if len(report.Damaged) == 0 {
+			return
+		}
+		if !fix {
+			cobra.CheckErr(fmt.Errorf("%d file(s) are missing or damaged, run with --fix to download them again", len(report.Damaged)))
+		}
+
+		queued := 0
+		for _, result := range report.Damaged {
+			for _, chapter := range result.Chapters {
+				cobra.CheckErr(repo.UpdateChapterStatus(chapter.ID, false, ""))
+				cobra.CheckErr(repo.DeleteChapterChecksum(chapter.ID))
+				cobra.CheckErr(repo.EnqueueChapter(result.Manga.ID, chapter.ID))
+				queued++
+			}
+		}
+		fmt.Printf("%s Queued %d chapter(s) again. Run 'mangas queue run' to download them.\n", utils.IconDownload, queued)
+	},
+}
+
+// verifyChapters names the chapters of a verified file
+func verifyChapters(result services.VerifyResult) string {
+	numbers := make([]string, len(result.Chapters))
+	for i, chapter := range result.Chapters {
+		numbers[i] = displayNumber(chapter.Number)
+	}
+	if len(numbers) == 1 {
+		return "chapter " + numbers[0]
+	}
+	return "chapters " + strings.Join(numbers, ", ")
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+	verifyCmd.Flags().Bool("deep", false, "Decode every page, even of files matching their checksums")
+	verifyCmd.Flags().Bool("fix", false, "Mark missing and damaged chapters as not downloaded and queue them")
+}
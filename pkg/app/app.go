@@ -3,17 +3,38 @@ package app
 import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/kerbaras/mangas/pkg/app/screens"
+	"github.com/kerbaras/mangas/pkg/data"
 )
 
 type App struct {
+	repo      *data.Repository // Library shown, the shared one when nil
+	dashboard bool             // Open on the home dashboard instead of the library
 }
 
 func NewApp() *App {
 	return &App{}
 }
 
+// NewAppWithRepository creates the TUI on a given library
+func NewAppWithRepository(repo *data.Repository) *App {
+	return &App{repo: repo}
+}
+
+// StartOnDashboard makes the TUI open on the home dashboard instead of the
+// library
+func (a *App) StartOnDashboard() {
+	a.dashboard = true
+}
+
 func (a *App) Run() error {
-	model := screens.NewRootScreen()
+	repo := a.repo
+	if repo == nil {
+		repo = data.NewDuckDBRepository()
+	}
+	model := screens.NewRootScreenWithRepository(repo)
+	if a.dashboard {
+		model.StartOnDashboard()
+	}
 	p := tea.NewProgram(model, tea.WithAltScreen(), tea.WithMouseCellMotion())
 	_, err := p.Run()
 	return err
@@ -0,0 +1,43 @@
+package components
+
+import (
+	"strings"
+)
+
+// sparkLevels are the block characters drawing a sparkline, lowest first
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws values as a one line chart, one character per value
+// scaled to the largest one. Zeros are drawn as the lowest level.
+func Sparkline(values []int) string {
+	peak := 0
+	for _, value := range values {
+		peak = max(peak, value)
+	}
+
+	var b strings.Builder
+	for _, value := range values {
+		level := 0
+		if peak > 0 && value > 0 {
+			// Any value above zero shows at least the second level
+			level = max(1, value*(len(sparkLevels)-1)/peak)
+		}
+		b.WriteRune(sparkLevels[level])
+	}
+	return b.String()
+}
+
+// HorizontalBar draws value as a bar of width cells, full at total
+func HorizontalBar(value, total int64, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	filled := 0
+	if total > 0 {
+		filled = int(min(max(value, 0), total) * int64(width) / total)
+	}
+	if filled == 0 && value > 0 {
+		filled = 1
+	}
+	return strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+}
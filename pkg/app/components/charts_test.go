@@ -0,0 +1,42 @@
+package components
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		values []int
+		want   string
+	}{
+		{[]int{0, 1, 7, 14}, "▁▂▄█"},
+		{[]int{0, 0, 0}, "▁▁▁"},
+		{[]int{1, 100}, "▂█"},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := Sparkline(tt.values); got != tt.want {
+			t.Errorf("Sparkline(%v) = %q, want %q", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestHorizontalBar(t *testing.T) {
+	tests := []struct {
+		value, total int64
+		want         string
+	}{
+		{50, 100, "█████░░░░░"},
+		{100, 100, "██████████"},
+		{1, 1000, "█░░░░░░░░░"}, // Never hidden
+		{0, 0, "░░░░░░░░░░"},
+		{200, 100, "██████████"},
+	}
+	for _, tt := range tests {
+		got := HorizontalBar(tt.value, tt.total, 10)
+		if got != tt.want || utf8.RuneCountInString(got) != 10 {
+			t.Errorf("HorizontalBar(%d, %d) = %q, want %q", tt.value, tt.total, got, tt.want)
+		}
+	}
+}
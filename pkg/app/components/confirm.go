@@ -0,0 +1,45 @@
+package components
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kerbaras/mangas/pkg/app/styles"
+)
+
+// confirmStyle frames a confirmation dialog
+var confirmStyle = lipgloss.NewStyle().
+	Border(styles.ThickBorder).
+	BorderForeground(styles.Warning).
+	Padding(0, 2)
+
+// Confirm asks a yes/no question before an action: y or enter runs it, n or
+// esc drops it
+type Confirm struct {
+	Prompt string
+	action tea.Cmd
+}
+
+// NewConfirm returns a dialog asking prompt, running action once confirmed
+func NewConfirm(prompt string, action tea.Cmd) *Confirm {
+	return &Confirm{Prompt: prompt, action: action}
+}
+
+// Update handles a key press, reporting whether the dialog was answered and
+// the command of the action when it was confirmed. Other keys are ignored.
+func (c *Confirm) Update(msg tea.KeyMsg) (done bool, cmd tea.Cmd) {
+	switch msg.String() {
+	case "y", "Y", "enter":
+		return true, c.action
+	case "n", "N", "esc":
+		return true, nil
+	}
+	return false, nil
+}
+
+// View renders the question and its answers
+func (c *Confirm) View() string {
+	answers := styles.MutedStyle.Render("y/enter: yes • n/esc: no")
+	return confirmStyle.Render(fmt.Sprintf("%s\n\n%s", styles.StatusWarning.Render(c.Prompt), answers))
+}
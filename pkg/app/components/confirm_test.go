@@ -0,0 +1,38 @@
+package components
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type confirmedMsg struct{}
+
+func TestConfirm(t *testing.T) {
+	action := func() tea.Msg { return confirmedMsg{} }
+	key := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+
+	confirm := NewConfirm("Remove Berserk?", action)
+	if !strings.Contains(confirm.View(), "Remove Berserk?") {
+		t.Errorf("view is missing the prompt:\n%s", confirm.View())
+	}
+
+	if done, cmd := confirm.Update(key("x")); done || cmd != nil {
+		t.Error("other keys should leave the dialog open")
+	}
+	if done, cmd := confirm.Update(key("y")); !done || cmd == nil {
+		t.Fatal("y should confirm")
+	} else if _, ok := cmd().(confirmedMsg); !ok {
+		t.Error("confirming should return the action")
+	}
+	if done, cmd := confirm.Update(tea.KeyMsg{Type: tea.KeyEnter}); !done || cmd == nil {
+		t.Error("enter should confirm")
+	}
+	if done, cmd := confirm.Update(key("n")); !done || cmd != nil {
+		t.Error("n should cancel without the action")
+	}
+	if done, cmd := confirm.Update(tea.KeyMsg{Type: tea.KeyEsc}); !done || cmd != nil {
+		t.Error("esc should cancel without the action")
+	}
+}
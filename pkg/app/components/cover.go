@@ -0,0 +1,396 @@
+package components
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/log"
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// GraphicsProtocol is a way for a terminal to display images inline
+type GraphicsProtocol int
+
+const (
+	GraphicsNone   GraphicsProtocol = iota // Text only, covers are placeholders
+	GraphicsKitty                          // Kitty graphics protocol
+	GraphicsITerm2                         // iTerm2 inline images
+	GraphicsSixel                          // DEC sixel graphics
+)
+
+func (p GraphicsProtocol) String() string {
+	switch p {
+	case GraphicsKitty:
+		return "kitty"
+	case GraphicsITerm2:
+		return "iterm2"
+	case GraphicsSixel:
+		return "sixel"
+	}
+	return "none"
+}
+
+// Cells are assumed twice as tall as wide, covers are scaled to this many
+// pixels per cell
+const (
+	coverCellWidth  = 10
+	coverCellHeight = 20
+)
+
+// DetectGraphics returns the graphics protocol of the terminal, from the
+// environment. MANGAS_GRAPHICS (kitty, iterm2, sixel or none) overrides it.
+func DetectGraphics() GraphicsProtocol {
+	return detectGraphics(os.Getenv)
+}
+
+func detectGraphics(getenv func(string) string) GraphicsProtocol {
+	switch strings.ToLower(getenv("MANGAS_GRAPHICS")) {
+	case "kitty":
+		return GraphicsKitty
+	case "iterm2":
+		return GraphicsITerm2
+	case "sixel":
+		return GraphicsSixel
+	case "none":
+		return GraphicsNone
+	}
+
+	term := getenv("TERM")
+	switch {
+	case getenv("KITTY_WINDOW_ID") != "" || term == "xterm-kitty" || getenv("TERM_PROGRAM") == "ghostty":
+		return GraphicsKitty
+	case getenv("TERM_PROGRAM") == "iTerm.app" || getenv("TERM_PROGRAM") == "WezTerm":
+		return GraphicsITerm2
+	case strings.Contains(term, "sixel") || strings.HasPrefix(term, "foot") || term == "mlterm":
+		return GraphicsSixel
+	}
+	return GraphicsNone
+}
+
+// CoverLoader fetches the cover image of a manga
+type CoverLoader func(manga *data.Manga) ([]byte, error)
+
+// CoverLoadedMsg reports that a cover was loaded, or failed to
+type CoverLoadedMsg struct {
+	MangaID string
+	Err     error
+}
+
+// CoverRenderer draws manga covers Width cells wide and Height cells tall,
+// as inline images when the terminal supports it and as colored placeholders
+// otherwise. Covers are downloaded once and kept rendered in memory.
+type CoverRenderer struct {
+	Protocol GraphicsProtocol
+	Width    int
+	Height   int
+
+	load    CoverLoader
+	mu      sync.Mutex
+	covers  map[string]string // Rendered images by manga ID
+	pending map[string]bool   // Loading or failed, not retried
+	frame   imageFrame
+}
+
+func NewCoverRenderer(protocol GraphicsProtocol, load CoverLoader) *CoverRenderer {
+	return &CoverRenderer{
+		Protocol: protocol,
+		Width:    8,
+		Height:   6,
+		load:     load,
+		covers:   make(map[string]string),
+		pending:  make(map[string]bool),
+	}
+}
+
+// Load returns a command loading the cover of manga, or nil when it is
+// loaded already, being loaded or images can't be shown
+func (r *CoverRenderer) Load(manga *data.Manga) tea.Cmd {
+	if r == nil || r.Protocol == GraphicsNone || r.load == nil || manga == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.covers[manga.ID]; ok || r.pending[manga.ID] {
+		return nil
+	}
+	r.pending[manga.ID] = true
+
+	return func() tea.Msg {
+		content, err := r.load(manga)
+		if err == nil {
+			var rendered string
+			rendered, err = r.render(manga.ID, content)
+			if err == nil {
+				r.mu.Lock()
+				r.covers[manga.ID] = rendered
+				delete(r.pending, manga.ID)
+				r.mu.Unlock()
+			}
+		}
+		if err != nil {
+			log.Debug("cover not shown", "manga_id", manga.ID, "err", err)
+		}
+		return CoverLoadedMsg{MangaID: manga.ID, Err: err}
+	}
+}
+
+// View returns the cover of manga, or its placeholder until it is loaded
+func (r *CoverRenderer) View(manga *data.Manga) string {
+	r.mu.Lock()
+	rendered, ok := r.covers[manga.ID]
+	r.mu.Unlock()
+	if ok {
+		return rendered
+	}
+	return CoverPlaceholder(manga.Name, r.Width, r.Height)
+}
+
+// Frame returns the sequences deleting the covers drawn in the last frame
+// that aren't among mangas, the ones in view now, so covers scrolled away
+// don't stay in the terminal's memory
+func (r *CoverRenderer) Frame(mangas []*data.Manga) string {
+	if r == nil {
+		return ""
+	}
+	keys := make([]string, len(mangas))
+	for i, manga := range mangas {
+		keys[i] = manga.ID
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.frame.next(r.Protocol, keys)
+}
+
+// render encodes a cover image for the protocol
+func (r *CoverRenderer) render(mangaID string, content []byte) (string, error) {
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode cover: %w", err)
+	}
+	return inlineImage(r.Protocol, imageID(mangaID), img, r.Width, r.Height)
+}
+
+// inlineImage draws img over width x height cells with protocol, followed
+// by blank cells the image is drawn over so the layout doesn't depend on it
+func inlineImage(protocol GraphicsProtocol, id uint32, img image.Image, width, height int) (string, error) {
+	scaled := image.NewRGBA(image.Rect(0, 0, width*coverCellWidth, height*coverCellHeight))
+	xdraw.ApproxBiLinear.Scale(scaled, scaled.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+
+	var sequence string
+	var err error
+	switch protocol {
+	case GraphicsKitty:
+		sequence, err = kittyImage(scaled, id, width, height)
+	case GraphicsITerm2:
+		sequence, err = iterm2Image(scaled, width, height)
+	case GraphicsSixel:
+		// The cursor is put back where the image started
+		sequence = "\x1b7" + sixelImage(scaled) + "\x1b8"
+	default:
+		return "", fmt.Errorf("no graphics protocol")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	blank := strings.Repeat(" ", width)
+	lines := make([]string, height)
+	for i := range lines {
+		lines[i] = blank
+	}
+	lines[0] = sequence + blank
+	return strings.Join(lines, "\n"), nil
+}
+
+// imageID derives a kitty image ID from a manga or chapter ID, so drawing
+// an image again moves it instead of leaving a copy behind
+func imageID(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()&0xffffff | 1
+}
+
+// ClearImages returns the sequence deleting every kitty image drawn and
+// freeing its data, "" with the other protocols as their images are
+// overwritten by the text drawn over them
+func ClearImages(protocol GraphicsProtocol) string {
+	if protocol != GraphicsKitty {
+		return ""
+	}
+	return "\x1b_Ga=d,d=A,q=2\x1b\\"
+}
+
+// kittyDelete returns the sequence deleting a kitty image and freeing its data
+func kittyDelete(id uint32) string {
+	return fmt.Sprintf("\x1b_Ga=d,d=I,i=%d,q=2\x1b\\", id)
+}
+
+// imageFrame tracks the kitty images drawn in the last frame, as kitty
+// keeps images until they are deleted
+type imageFrame struct {
+	shown map[uint32]bool
+}
+
+// next records the images drawn in a frame by key and returns the
+// sequences deleting the ones of the last frame not drawn anymore
+func (f *imageFrame) next(protocol GraphicsProtocol, keys []string) string {
+	if protocol != GraphicsKitty {
+		return ""
+	}
+	shown := make(map[uint32]bool, len(keys))
+	for _, key := range keys {
+		shown[imageID(key)] = true
+	}
+	var b strings.Builder
+	for id := range f.shown {
+		if !shown[id] {
+			b.WriteString(kittyDelete(id))
+		}
+	}
+	f.shown = shown
+	return b.String()
+}
+
+// kittyImage transmits a PNG and places it over width x height cells
+// without moving the cursor
+func kittyImage(img image.Image, id uint32, width, height int) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	payload := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	// Payloads are sent in chunks of at most 4096 bytes
+	const chunk = 4096
+	var b strings.Builder
+	for start := 0; start < len(payload); start += chunk {
+		end := min(start+chunk, len(payload))
+		more := 0
+		if end < len(payload) {
+			more = 1
+		}
+		if start == 0 {
+			fmt.Fprintf(&b, "\x1b_Ga=T,f=100,i=%d,p=1,c=%d,r=%d,C=1,q=2,m=%d;", id, width, height, more)
+		} else {
+			fmt.Fprintf(&b, "\x1b_Gm=%d;", more)
+		}
+		b.WriteString(payload[start:end])
+		b.WriteString("\x1b\\")
+	}
+	return b.String(), nil
+}
+
+// iterm2Image draws a PNG over width x height cells, the cursor is put back
+// where the image started
+func iterm2Image(img image.Image, width, height int) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("\x1b7\x1b]1337;File=inline=1;size=%d;width=%d;height=%d;preserveAspectRatio=0:%s\a\x1b8",
+		buf.Len(), width, height, base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
+
+// sixelImage encodes an image as sixels with the web-safe palette
+func sixelImage(img image.Image) string {
+	bounds := img.Bounds()
+	paletted := image.NewPaletted(image.Rect(0, 0, bounds.Dx(), bounds.Dy()), palette.WebSafe)
+	draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), img, bounds.Min)
+	width, height := paletted.Bounds().Dx(), paletted.Bounds().Dy()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\x1bPq\"1;1;%d;%d", width, height)
+	for i, c := range palette.WebSafe {
+		r, g, bl, _ := c.RGBA()
+		fmt.Fprintf(&b, "#%d;2;%d;%d;%d", i, r*100/0xffff, g*100/0xffff, bl*100/0xffff)
+	}
+
+	// Each band is 6 pixels tall, drawn once per color it uses
+	for top := 0; top < height; top += 6 {
+		bands := make(map[uint8][]byte)
+		for x := 0; x < width; x++ {
+			for dy := 0; dy < 6 && top+dy < height; dy++ {
+				index := paletted.ColorIndexAt(x, top+dy)
+				if bands[index] == nil {
+					bands[index] = make([]byte, width)
+				}
+				bands[index][x] |= 1 << dy
+			}
+		}
+		colors := make([]int, 0, len(bands))
+		for index := range bands {
+			colors = append(colors, int(index))
+		}
+		sort.Ints(colors)
+		for i, index := range colors {
+			if i > 0 {
+				b.WriteByte('$') // Back to the start of the band
+			}
+			fmt.Fprintf(&b, "#%d", index)
+			writeSixelRow(&b, bands[uint8(index)])
+		}
+		b.WriteByte('-') // Next band
+	}
+	b.WriteString("\x1b\\")
+	return b.String()
+}
+
+// writeSixelRow writes the sixels of a band color, run-length encoded
+func writeSixelRow(b *strings.Builder, bits []byte) {
+	for x := 0; x < len(bits); {
+		run := 1
+		for x+run < len(bits) && bits[x+run] == bits[x] {
+			run++
+		}
+		char := byte(63 + bits[x])
+		if run > 3 {
+			fmt.Fprintf(b, "!%d%c", run, char)
+		} else {
+			b.WriteString(strings.Repeat(string(char), run))
+		}
+		x += run
+	}
+}
+
+// CoverPlaceholder draws a block in a color picked from the title, with its
+// initials, for covers that can't be shown
+func CoverPlaceholder(title string, width, height int) string {
+	h := fnv.New32a()
+	h.Write([]byte(title))
+	sum := h.Sum32()
+	background := color.RGBA{R: uint8(sum>>16)/2 + 40, G: uint8(sum>>8)/2 + 40, B: uint8(sum)/2 + 40, A: 255}
+
+	var initials []rune
+	for _, word := range strings.Fields(title) {
+		if len(initials) == 2 {
+			break
+		}
+		initials = append(initials, []rune(word)[0])
+	}
+
+	return lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		Align(lipgloss.Center, lipgloss.Center).
+		Bold(true).
+		Foreground(lipgloss.Color("#FFFFFF")).
+		Background(lipgloss.Color(fmt.Sprintf("#%02X%02X%02X", background.R, background.G, background.B))).
+		Render(strings.ToUpper(string(initials)))
+}
@@ -0,0 +1,130 @@
+package components
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+func TestDetectGraphics(t *testing.T) {
+	tests := []struct {
+		env  map[string]string
+		want GraphicsProtocol
+	}{
+		{map[string]string{"TERM": "xterm-256color"}, GraphicsNone},
+		{map[string]string{"TERM": "xterm-kitty"}, GraphicsKitty},
+		{map[string]string{"KITTY_WINDOW_ID": "1"}, GraphicsKitty},
+		{map[string]string{"TERM_PROGRAM": "iTerm.app"}, GraphicsITerm2},
+		{map[string]string{"TERM": "foot"}, GraphicsSixel},
+		{map[string]string{"TERM": "xterm-kitty", "MANGAS_GRAPHICS": "none"}, GraphicsNone},
+		{map[string]string{"MANGAS_GRAPHICS": "sixel"}, GraphicsSixel},
+	}
+	for _, tt := range tests {
+		got := detectGraphics(func(key string) string { return tt.env[key] })
+		if got != tt.want {
+			t.Errorf("detectGraphics(%v) = %s, want %s", tt.env, got, tt.want)
+		}
+	}
+}
+
+func testCover(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 40, 60))
+	for y := 0; y < 60; y++ {
+		for x := 0; x < 40; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 6), G: uint8(y * 4), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCoverRenderer(t *testing.T) {
+	manga := &data.Manga{ID: "m1", Name: "One Piece"}
+	for _, protocol := range []GraphicsProtocol{GraphicsKitty, GraphicsITerm2, GraphicsSixel} {
+		t.Run(protocol.String(), func(t *testing.T) {
+			loads := 0
+			r := NewCoverRenderer(protocol, func(*data.Manga) ([]byte, error) {
+				loads++
+				return testCover(t), nil
+			})
+
+			placeholder := r.View(manga)
+			if !strings.Contains(placeholder, "OP") {
+				t.Errorf("Expected the placeholder to show the initials, got %q", placeholder)
+			}
+
+			msg := r.Load(manga)()
+			if loaded := msg.(CoverLoadedMsg); loaded.Err != nil || loaded.MangaID != "m1" {
+				t.Fatalf("Load() = %+v", loaded)
+			}
+			if r.Load(manga) != nil || loads != 1 {
+				t.Error("Expected the cover to be loaded once")
+			}
+
+			view := r.View(manga)
+			if view == placeholder || !strings.HasPrefix(view, "\x1b") {
+				t.Errorf("Expected an image, got %q", view[:min(len(view), 40)])
+			}
+			if w, h := lipgloss.Width(view), lipgloss.Height(view); w != r.Width || h != r.Height {
+				t.Errorf("Cover takes %dx%d cells, want %dx%d", w, h, r.Width, r.Height)
+			}
+		})
+	}
+}
+
+func TestCoverRenderer_Frame(t *testing.T) {
+	one, two := &data.Manga{ID: "m1"}, &data.Manga{ID: "m2"}
+	r := NewCoverRenderer(GraphicsKitty, nil)
+
+	if got := r.Frame([]*data.Manga{one, two}); got != "" {
+		t.Errorf("Nothing should be deleted on the first frame, got %q", got)
+	}
+	if got := r.Frame([]*data.Manga{two}); got != kittyDelete(imageID("m1")) {
+		t.Errorf("Expected the cover scrolled away to be deleted, got %q", got)
+	}
+	if got := r.Frame([]*data.Manga{two}); got != "" {
+		t.Errorf("Covers still in view should not be deleted, got %q", got)
+	}
+
+	r = NewCoverRenderer(GraphicsSixel, nil)
+	r.Frame([]*data.Manga{one})
+	if got := r.Frame(nil); got != "" {
+		t.Errorf("Only kitty images need deleting, got %q", got)
+	}
+	if ClearImages(GraphicsSixel) != "" || ClearImages(GraphicsKitty) == "" {
+		t.Error("Expected only kitty images to be cleared")
+	}
+}
+
+func TestCoverRenderer_Fallbacks(t *testing.T) {
+	manga := &data.Manga{ID: "m1", Name: "Berserk"}
+
+	r := NewCoverRenderer(GraphicsNone, func(*data.Manga) ([]byte, error) { return testCover(t), nil })
+	if r.Load(manga) != nil {
+		t.Error("Covers should not be loaded when images can't be shown")
+	}
+
+	r = NewCoverRenderer(GraphicsKitty, func(*data.Manga) ([]byte, error) { return nil, errors.New("offline") })
+	if msg := r.Load(manga)().(CoverLoadedMsg); msg.Err == nil {
+		t.Error("Expected the load error to be reported")
+	}
+	if view := r.View(manga); view != CoverPlaceholder(manga.Name, r.Width, r.Height) {
+		t.Error("Expected the placeholder after a failed load")
+	}
+
+	var nilRenderer *CoverRenderer
+	if nilRenderer.Load(manga) != nil {
+		t.Error("A nil renderer should not load covers")
+	}
+}
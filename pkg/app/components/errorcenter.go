@@ -0,0 +1,146 @@
+package components
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/app/styles"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// Severity tells transient problems apart from errors that leave a screen
+// without its content
+type Severity int
+
+const (
+	SeverityWarning Severity = iota // An action failed, retrying may work
+	SeverityFatal                   // A screen failed to load its data
+)
+
+func (s Severity) String() string {
+	if s == SeverityFatal {
+		return "error"
+	}
+	return "warning"
+}
+
+// ErrorEntry is an error reported by a screen
+type ErrorEntry struct {
+	Time     time.Time
+	Screen   string
+	Err      error
+	Severity Severity
+}
+
+// ErrorCenter keeps the most recent errors reported by the screens
+type ErrorCenter struct {
+	entries []ErrorEntry // Newest first
+	limit   int
+	unread  int
+	Width   int
+	Height  int
+}
+
+// NewErrorCenter creates an error center keeping up to limit errors
+func NewErrorCenter(limit int) *ErrorCenter {
+	if limit <= 0 {
+		limit = 50
+	}
+	return &ErrorCenter{limit: limit}
+}
+
+// Add records an error reported by screen. Nil errors are ignored.
+func (c *ErrorCenter) Add(screen string, err error, severity Severity) {
+	if err == nil {
+		return
+	}
+
+	entry := ErrorEntry{Time: time.Now(), Screen: screen, Err: err, Severity: severity}
+	c.entries = append([]ErrorEntry{entry}, c.entries...)
+	if len(c.entries) > c.limit {
+		c.entries = c.entries[:c.limit]
+	}
+	if c.unread < len(c.entries) {
+		c.unread++
+	}
+}
+
+// Entries returns the recorded errors, newest first
+func (c *ErrorCenter) Entries() []ErrorEntry {
+	return c.entries
+}
+
+// Unread returns how many errors were added since MarkRead
+func (c *ErrorCenter) Unread() int {
+	return c.unread
+}
+
+// MarkRead marks every recorded error as seen
+func (c *ErrorCenter) MarkRead() {
+	c.unread = 0
+}
+
+// Clear removes every recorded error
+func (c *ErrorCenter) Clear() {
+	c.entries = nil
+	c.unread = 0
+}
+
+// Badge returns a short unread counter for the tab bar, empty when there
+// is nothing new
+func (c *ErrorCenter) Badge() string {
+	if c.unread == 0 {
+		return ""
+	}
+
+	for _, entry := range c.entries[:c.unread] {
+		if entry.Severity == SeverityFatal {
+			return styles.StatusError.Render(fmt.Sprintf("%s %d", utils.IconCross, c.unread))
+		}
+	}
+	return styles.StatusWarning.Render(fmt.Sprintf("%s %d", utils.IconWarning, c.unread))
+}
+
+func (c *ErrorCenter) View() string {
+	var b strings.Builder
+	b.WriteString(styles.TitleStyle.Render(utils.IconError.String() + " Error Center"))
+	b.WriteString("\n\n")
+
+	if len(c.entries) == 0 {
+		b.WriteString(styles.MutedStyle.Render("No errors reported."))
+		b.WriteString("\n")
+		return b.String()
+	}
+
+	entries := c.entries
+	if c.Height > 0 && len(entries) > c.Height {
+		entries = entries[:c.Height]
+	}
+
+	for i, entry := range entries {
+		icon, style := utils.IconWarning, styles.StatusWarning
+		if entry.Severity == SeverityFatal {
+			icon, style = utils.IconCross, styles.StatusError
+		}
+
+		line := fmt.Sprintf("%s %s [%s] %s",
+			icon, entry.Time.Format("15:04:05"), entry.Screen, entry.Err)
+		if runes := []rune(line); c.Width > 3 && len(runes) > c.Width {
+			line = string(runes[:c.Width-3]) + "..."
+		}
+		if i < c.unread {
+			b.WriteString(style.Render(line))
+		} else {
+			b.WriteString(styles.MutedStyle.Render(line))
+		}
+		b.WriteString("\n")
+	}
+
+	if hidden := len(c.entries) - len(entries); hidden > 0 {
+		b.WriteString(styles.MutedStyle.Render(fmt.Sprintf("... and %d older", hidden)))
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,61 @@
+package components
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+func TestErrorCenter(t *testing.T) {
+	center := NewErrorCenter(3)
+
+	center.Add("library", nil, SeverityFatal)
+	if len(center.Entries()) != 0 || center.Badge() != "" {
+		t.Fatal("nil errors should be ignored")
+	}
+
+	center.Add("search", errors.New("timeout"), SeverityWarning)
+	if center.Unread() != 1 || !strings.Contains(center.Badge(), fmt.Sprintf("%s 1", utils.IconWarning)) {
+		t.Errorf("expected 1 unread warning, got %d %q", center.Unread(), center.Badge())
+	}
+
+	center.Add("library", errors.New("database locked"), SeverityFatal)
+	if !strings.Contains(center.Badge(), fmt.Sprintf("%s 2", utils.IconCross)) {
+		t.Errorf("badge should flag unread fatal errors, got %q", center.Badge())
+	}
+
+	for i := 0; i < 3; i++ {
+		center.Add("queue", fmt.Errorf("failure %d", i), SeverityWarning)
+	}
+	entries := center.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("expected the 3 most recent errors, got %d", len(entries))
+	}
+	if entries[0].Err.Error() != "failure 2" || entries[2].Err.Error() != "failure 0" {
+		t.Errorf("entries should be newest first, got %v ... %v", entries[0].Err, entries[2].Err)
+	}
+	if center.Unread() != 3 {
+		t.Errorf("unread count should not exceed the entries kept, got %d", center.Unread())
+	}
+
+	view := center.View()
+	if !strings.Contains(view, "[queue] failure 2") {
+		t.Errorf("view is missing the latest error:\n%s", view)
+	}
+
+	center.MarkRead()
+	if center.Badge() != "" {
+		t.Error("badge should be empty once errors are read")
+	}
+	if len(center.Entries()) != 3 {
+		t.Error("marking errors read should keep them")
+	}
+
+	center.Clear()
+	if len(center.Entries()) != 0 || !strings.Contains(center.View(), "No errors reported") {
+		t.Error("Clear() should remove every error")
+	}
+}
@@ -0,0 +1,48 @@
+package components
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kerbaras/mangas/pkg/app/keys"
+	"github.com/kerbaras/mangas/pkg/app/styles"
+)
+
+// keyHelpStyle frames the bindings of a screen in the help overlay
+var keyHelpStyle = lipgloss.NewStyle().
+	Border(styles.RoundedBorder).
+	BorderForeground(styles.Secondary).
+	Padding(0, 1)
+
+// keyStyle highlights the keys of the help overlay
+var keyStyle = lipgloss.NewStyle().Foreground(styles.Primary).Bold(true)
+
+// KeyHelp renders the help overlay: the bindings of each group in a box,
+// side by side when they fit in width
+func KeyHelp(groups []keys.Group, width int) string {
+	boxes := make([]string, len(groups))
+	total := 0
+	for i, group := range groups {
+		boxes[i] = keyHelpStyle.Render(keyHelpGroup(group))
+		total += lipgloss.Width(boxes[i])
+	}
+	if total <= width {
+		return lipgloss.JoinHorizontal(lipgloss.Top, boxes...)
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, boxes...)
+}
+
+func keyHelpGroup(group keys.Group) string {
+	entries := keys.HelpEntries(group.Bindings()...)
+	keyWidth := 0
+	for _, entry := range entries {
+		keyWidth = max(keyWidth, lipgloss.Width(entry.Keys))
+	}
+	lines := []string{styles.SubtitleStyle.Render(group.Title)}
+	for _, entry := range entries {
+		padding := strings.Repeat(" ", keyWidth-lipgloss.Width(entry.Keys))
+		lines = append(lines, fmt.Sprintf("%s%s  %s", keyStyle.Render(entry.Keys), padding, entry.Desc))
+	}
+	return strings.Join(lines, "\n")
+}
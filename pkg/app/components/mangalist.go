@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"strings"
 
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 	"github.com/kerbaras/mangas/pkg/app/styles"
 	"github.com/kerbaras/mangas/pkg/data"
 )
@@ -20,14 +22,22 @@ type MangaList struct {
 	SelectedIndex int
 	Width         int
 	Height        int
+	Covers        *CoverRenderer // Draws a cover next to each manga, nil for text only
+	Grid          bool           // Small cards in as many columns as fit in Width, instead of one card per line
+	scroller      *Scroller      // Rows of cards rendered, the ones fitting in Height
 }
 
+// gridCardWidth is the width of the cards of the grid layout, borders
+// included
+const gridCardWidth = 26
+
 func NewMangaList() *MangaList {
 	return &MangaList{
 		Items:         []MangaListItem{},
 		SelectedIndex: 0,
 		Width:         80,
 		Height:        20,
+		scroller:      NewScroller(1),
 	}
 }
 
@@ -61,6 +71,87 @@ func (m *MangaList) Prev() {
 	}
 }
 
+// Columns returns how many cards a row holds: the ones fitting in Width in
+// the grid layout, 1 in the list layout
+func (m *MangaList) Columns() int {
+	if !m.Grid {
+		return 1
+	}
+	return max(1, m.Width/gridCardWidth)
+}
+
+// Up selects the card above in the grid layout, the previous one in the list
+func (m *MangaList) Up() {
+	columns := m.Columns()
+	if columns == 1 {
+		m.Prev()
+		return
+	}
+	if m.SelectedIndex >= columns {
+		m.SelectedIndex -= columns
+	}
+}
+
+// Down selects the card below in the grid layout, the last one when the row
+// below is shorter, and the next one in the list
+func (m *MangaList) Down() {
+	columns := m.Columns()
+	if columns == 1 {
+		m.Next()
+		return
+	}
+	switch {
+	case m.SelectedIndex+columns < len(m.Items):
+		m.SelectedIndex += columns
+	case m.SelectedIndex/columns < (len(m.Items)-1)/columns:
+		m.SelectedIndex = len(m.Items) - 1
+	}
+}
+
+// PageUp selects the card a page of rows above
+func (m *MangaList) PageUp() {
+	m.SelectedIndex = max(m.SelectedIndex-m.pageSize(), 0)
+}
+
+// PageDown selects the card a page of rows below
+func (m *MangaList) PageDown() {
+	if len(m.Items) > 0 {
+		m.SelectedIndex = min(m.SelectedIndex+m.pageSize(), len(m.Items)-1)
+	}
+}
+
+// Home selects the first card
+func (m *MangaList) Home() {
+	m.SelectedIndex = 0
+}
+
+// End selects the last card
+func (m *MangaList) End() {
+	m.SelectedIndex = max(len(m.Items)-1, 0)
+}
+
+// pageSize returns how many cards a page of rows holds
+func (m *MangaList) pageSize() int {
+	m.scroller.Height = m.visibleRows()
+	return m.scroller.Page() * m.Columns()
+}
+
+// visibleRows returns how many rows of cards fit in Height, below the line
+// telling which are shown
+func (m *MangaList) visibleRows() int {
+	if len(m.Items) == 0 {
+		return 1
+	}
+	return max((m.Height-1)/lipgloss.Height(m.card(m.Items[0], false)), 1)
+}
+
+func (m *MangaList) card(item MangaListItem, selected bool) string {
+	if m.Grid {
+		return m.gridCard(item, selected)
+	}
+	return m.listCard(item, selected)
+}
+
 func (m *MangaList) Selected() *MangaListItem {
 	if len(m.Items) == 0 || m.SelectedIndex >= len(m.Items) {
 		return nil
@@ -68,56 +159,123 @@ func (m *MangaList) Selected() *MangaListItem {
 	return &m.Items[m.SelectedIndex]
 }
 
+// visible returns the range of rows of cards in view, end excluded
+func (m *MangaList) visible() (start, end int) {
+	columns := m.Columns()
+	m.scroller.Height = m.visibleRows()
+	return m.scroller.Visible(m.SelectedIndex/columns, (len(m.Items)+columns-1)/columns)
+}
+
+// LoadCovers returns the command loading the covers of the cards in view,
+// nil when they are loaded
+func (m *MangaList) LoadCovers() tea.Cmd {
+	if m.Covers == nil || len(m.Items) == 0 {
+		return nil
+	}
+	columns := m.Columns()
+	start, end := m.visible()
+	var cmds []tea.Cmd
+	for i := start * columns; i < min(end*columns, len(m.Items)); i++ {
+		cmds = append(cmds, m.Covers.Load(m.Items[i].Manga))
+	}
+	return tea.Batch(cmds...)
+}
+
 func (m *MangaList) View() string {
 	if len(m.Items) == 0 {
 		emptyMsg := styles.MutedStyle.Render("No manga in library")
 		return lipgloss.Place(m.Width, m.Height, lipgloss.Center, lipgloss.Center, emptyMsg)
 	}
+	columns := m.Columns()
+	start, end := m.visible()
 
 	var b strings.Builder
-	
-	for i, item := range m.Items {
-		cardStyle := styles.CardStyle
-		if i == m.SelectedIndex {
-			cardStyle = styles.ActiveCardStyle
-		}
-
-		// Build card content
-		title := styles.TitleStyle.Render(item.Manga.Name)
-		
-		statusText := fmt.Sprintf("Status: %s", item.Manga.Status)
-		if item.Manga.Status == "" {
-			statusText = "Status: Ready"
+	if m.Covers != nil {
+		var shown []*data.Manga
+		for i := start * columns; i < min(end*columns, len(m.Items)); i++ {
+			shown = append(shown, m.Items[i].Manga)
 		}
-		status := styles.StatusStyle(item.Manga.Status).Render(statusText)
-		
-		chapterInfo := styles.MutedStyle.Render(
-			fmt.Sprintf("Chapters: %d / %d downloaded", item.DownloadedCount, item.ChapterCount),
-		)
-		
-		source := styles.MutedStyle.Render(fmt.Sprintf("Source: %s", item.Manga.Source))
-		
-		// Truncate description
-		desc := item.Manga.Description
-		if len(desc) > 80 {
-			desc = desc[:77] + "..."
+		b.WriteString(m.Covers.Frame(shown))
+	}
+	for row := start; row < end; row++ {
+		var cards []string
+		for i := row * columns; i < min((row+1)*columns, len(m.Items)); i++ {
+			cards = append(cards, m.card(m.Items[i], i == m.SelectedIndex))
 		}
-		description := styles.TextStyle.Render(desc)
-		
-		cardContent := lipgloss.JoinVertical(
-			lipgloss.Left,
-			title,
-			description,
-			"",
-			chapterInfo,
-			status,
-			source,
-		)
-		
-		card := cardStyle.Width(m.Width - 4).Render(cardContent)
-		b.WriteString(card)
+		b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, cards...))
+		b.WriteString("\n")
+	}
+	if status := ScrollStatus(start*columns, min(end*columns, len(m.Items)), len(m.Items), "manga"); status != "" {
+		b.WriteString(styles.MutedStyle.Render(status))
 		b.WriteString("\n")
 	}
 
 	return b.String()
 }
+
+// listCard renders an item as a full-width card: cover, title, description
+// and download state
+func (m *MangaList) listCard(item MangaListItem, selected bool) string {
+	cardStyle := styles.CardStyle
+	if selected {
+		cardStyle = styles.ActiveCardStyle
+	}
+
+	// Build card content
+	title := styles.TitleStyle.Render(item.Manga.Name)
+	
+	statusText := fmt.Sprintf("Status: %s", item.Manga.Status)
+	if item.Manga.Status == "" {
+		statusText = "Status: Ready"
+	}
+	status := styles.StatusStyle(item.Manga.Status).Render(statusText)
+	
+	chapterInfo := styles.MutedStyle.Render(
+		fmt.Sprintf("Chapters: %d / %d downloaded", item.DownloadedCount, item.ChapterCount),
+	)
+	
+	source := styles.MutedStyle.Render(fmt.Sprintf("Source: %s", item.Manga.Source))
+	
+	// Truncate description
+	desc := item.Manga.Description
+	if len(desc) > 80 {
+		desc = desc[:77] + "..."
+	}
+	description := styles.TextStyle.Render(desc)
+	
+	cardContent := lipgloss.JoinVertical(
+		lipgloss.Left,
+		title,
+		description,
+		"",
+		chapterInfo,
+		status,
+		source,
+	)
+	if m.Covers != nil {
+		cardContent = lipgloss.JoinHorizontal(lipgloss.Top, m.Covers.View(item.Manga), "  ", cardContent)
+	}
+	
+	return cardStyle.Width(m.Width - 4).Render(cardContent)
+}
+
+// gridCard renders an item as a small card of the grid: cover, title and
+// download count
+func (m *MangaList) gridCard(item MangaListItem, selected bool) string {
+	style := styles.CardStyle
+	if selected {
+		style = styles.ActiveCardStyle
+	}
+	style = style.Padding(0, 1).MarginBottom(0).Width(gridCardWidth - 2)
+	width := gridCardWidth - 4 // Inside the borders and padding
+
+	var lines []string
+	if m.Covers != nil {
+		lines = append(lines, lipgloss.PlaceHorizontal(width, lipgloss.Center, m.Covers.View(item.Manga)))
+	}
+	lines = append(lines,
+		styles.TitleStyle.MarginBottom(0).Render(ansi.Truncate(item.Manga.Name, width, "…")),
+		styles.MutedStyle.Render(fmt.Sprintf("%d/%d downloaded", item.DownloadedCount, item.ChapterCount)),
+	)
+	return style.Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
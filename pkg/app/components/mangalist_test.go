@@ -1,9 +1,11 @@
 package components
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
+	"github.com/charmbracelet/lipgloss"
 	"github.com/kerbaras/mangas/pkg/data"
 )
 
@@ -230,3 +232,125 @@ func TestMangaListItem(t *testing.T) {
 	}
 }
 
+
+func TestGridNavigation(t *testing.T) {
+	list := NewMangaList()
+	var items []MangaListItem
+	for i := 0; i < 7; i++ {
+		items = append(items, MangaListItem{Manga: &data.Manga{ID: string(rune('a' + i))}})
+	}
+	list.SetItems(items)
+
+	list.Width = 3*gridCardWidth + 5
+	if list.Columns() != 1 {
+		t.Errorf("list layout has %d columns, want 1", list.Columns())
+	}
+	list.Grid = true
+	if list.Columns() != 3 {
+		t.Fatalf("grid of width %d has %d columns, want 3", list.Width, list.Columns())
+	}
+
+	// Rows of 3: a b c / d e f / g
+	steps := []struct {
+		move func()
+		want int
+	}{
+		{list.Down, 3},
+		{list.Next, 4},
+		{list.Down, 6}, // Onto the shorter last row
+		{list.Down, 6},
+		{list.Up, 3},
+		{list.Up, 0},
+		{list.Up, 0},
+		{list.Prev, 6},
+	}
+	for i, step := range steps {
+		step.move()
+		if list.SelectedIndex != step.want {
+			t.Fatalf("step %d: selected %d, want %d", i, list.SelectedIndex, step.want)
+		}
+	}
+
+	list.Width = gridCardWidth
+	list.SelectedIndex = 0
+	list.Down()
+	if list.Columns() != 1 || list.SelectedIndex != 1 {
+		t.Errorf("narrow grid: %d columns, selected %d", list.Columns(), list.SelectedIndex)
+	}
+}
+
+func TestGridView(t *testing.T) {
+	list := NewMangaList()
+	list.Width = 2 * gridCardWidth
+	list.Grid = true
+	list.SetItems([]MangaListItem{
+		{Manga: &data.Manga{ID: "1", Name: "Berserk"}, ChapterCount: 380, DownloadedCount: 12},
+		{Manga: &data.Manga{ID: "2", Name: "A Title Far Too Long For Its Card"}},
+		{Manga: &data.Manga{ID: "3", Name: "Vagabond"}},
+	})
+
+	lines := strings.Split(list.View(), "\n")
+	row := ""
+	for _, line := range lines {
+		if strings.Contains(line, "Berserk") {
+			row = line
+		}
+	}
+	if !strings.Contains(row, "A Title Far Too") {
+		t.Errorf("expected the first two cards side by side:\n%s", list.View())
+	}
+	if strings.Contains(list.View(), "Its Card") {
+		t.Errorf("long titles should be truncated:\n%s", list.View())
+	}
+	if !strings.Contains(list.View(), "12/380 downloaded") {
+		t.Errorf("expected the download count:\n%s", list.View())
+	}
+	for _, line := range lines {
+		if w := lipgloss.Width(line); w > list.Width {
+			t.Errorf("line %q is %d wide, over %d", line, w, list.Width)
+		}
+	}
+}
+
+func TestViewOnlyRendersVisibleCards(t *testing.T) {
+	list := NewMangaList()
+	list.Width = 80
+	list.Height = 40
+	var items []MangaListItem
+	for i := 0; i < 1000; i++ {
+		items = append(items, MangaListItem{Manga: &data.Manga{ID: fmt.Sprint(i), Name: fmt.Sprintf("Manga #%d", i)}})
+	}
+	list.SetItems(items)
+
+	view := list.View()
+	if !strings.Contains(view, "Manga #0") || strings.Contains(view, "Manga #500") {
+		t.Errorf("expected only the first cards:\n%s", view)
+	}
+	if lipgloss.Height(view) > list.Height+1 {
+		t.Errorf("view is %d lines, over the %d available", lipgloss.Height(view), list.Height)
+	}
+	if !strings.Contains(view, "of 1000 manga") {
+		t.Errorf("expected the scroll status:\n%s", view)
+	}
+
+	list.End()
+	if view := list.View(); !strings.Contains(view, "Manga #999") || strings.Contains(view, "Manga #0") {
+		t.Errorf("expected the last cards after End():\n%s", view)
+	}
+	list.Home()
+	list.PageDown()
+	page := list.SelectedIndex
+	if page < 1 {
+		t.Fatalf("PageDown() selected %d", page)
+	}
+	list.PageDown()
+	list.PageUp()
+	if list.SelectedIndex != page {
+		t.Errorf("PageUp() after PageDown() selected %d, want %d", list.SelectedIndex, page)
+	}
+	list.PageUp()
+	list.PageUp()
+	if list.SelectedIndex != 0 {
+		t.Errorf("PageUp() past the top selected %d", list.SelectedIndex)
+	}
+}
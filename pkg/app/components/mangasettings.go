@@ -0,0 +1,185 @@
+package components
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kerbaras/mangas/pkg/app/styles"
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+// defaultValue is the first value of every setting, it leaves the setting
+// to the global default
+const defaultValue = "default"
+
+// MangaSettingsPanel edits the download settings of a manga: one line per
+// setting, the selected one cycles through its values. The group is typed
+// in instead.
+type MangaSettingsPanel struct {
+	mangaID                  string
+	language, format, bundle *searchFilter
+	group                    textinput.Model
+	selected                 int // 0 language, 1 group, 2 format, 3 bundle
+}
+
+// mangaSettingsFields is how many lines the panel has
+const mangaSettingsFields = 4
+
+// groupField is the line of the group
+const groupField = 1
+
+func NewMangaSettingsPanel(settings *data.MangaSettings) *MangaSettingsPanel {
+	languages := append([]string{defaultValue}, searchLanguages...)
+	if settings.Language != "" && !slices.Contains(languages, settings.Language) {
+		languages = append(languages, settings.Language)
+	}
+	group := textinput.New()
+	group.Placeholder = "any"
+	group.CharLimit = 100
+	group.Width = 30
+	group.Prompt = ""
+	group.SetValue(settings.Group)
+
+	p := &MangaSettingsPanel{
+		mangaID:  settings.MangaID,
+		language: &searchFilter{label: "Language", values: languages},
+		format:   &searchFilter{label: "Format", values: []string{defaultValue, "epub", "cbz"}},
+		bundle:   &searchFilter{label: "Bundle", values: []string{defaultValue, "chapter", "volume"}},
+		group:    group,
+	}
+	p.language.index = max(slices.Index(p.language.values, settings.Language), 0)
+	p.format.index = max(slices.Index(p.format.values, settings.Format), 0)
+	p.bundle.index = max(slices.Index(p.bundle.values, settings.BundleMode), 0)
+	return p
+}
+
+// Next selects the next setting
+func (p *MangaSettingsPanel) Next() {
+	if p.selected < mangaSettingsFields-1 {
+		p.selected++
+	}
+}
+
+// Prev selects the previous setting
+func (p *MangaSettingsPanel) Prev() {
+	if p.selected > 0 {
+		p.selected--
+	}
+}
+
+// Cycle moves the selected setting delta values forward, wrapping around.
+// The group is typed in, it doesn't cycle.
+func (p *MangaSettingsPanel) Cycle(delta int) {
+	filter := p.filter()
+	if filter == nil {
+		return
+	}
+	n := len(filter.values)
+	filter.index = ((filter.index+delta)%n + n) % n
+}
+
+// OnGroup reports whether the group is selected
+func (p *MangaSettingsPanel) OnGroup() bool {
+	return p.selected == groupField
+}
+
+// Editing reports whether the group input is capturing key presses
+func (p *MangaSettingsPanel) Editing() bool {
+	return p.group.Focused()
+}
+
+// EditGroup starts typing the group in
+func (p *MangaSettingsPanel) EditGroup() tea.Cmd {
+	p.selected = groupField
+	p.group.Focus()
+	return textinput.Blink
+}
+
+// StopEditing stops typing the group in, keeping what was typed
+func (p *MangaSettingsPanel) StopEditing() {
+	p.group.Blur()
+}
+
+// Update passes a key press to the group input while it is edited
+func (p *MangaSettingsPanel) Update(msg tea.Msg) tea.Cmd {
+	var cmd tea.Cmd
+	p.group, cmd = p.group.Update(msg)
+	return cmd
+}
+
+// Settings returns the settings set in the panel
+func (p *MangaSettingsPanel) Settings() *data.MangaSettings {
+	value := func(filter *searchFilter) string {
+		if filter.index == 0 {
+			return ""
+		}
+		return filter.values[filter.index]
+	}
+	return &data.MangaSettings{
+		MangaID:    p.mangaID,
+		Language:   value(p.language),
+		Group:      strings.TrimSpace(p.group.Value()),
+		Format:     value(p.format),
+		BundleMode: value(p.bundle),
+	}
+}
+
+func (p *MangaSettingsPanel) filter() *searchFilter {
+	switch p.selected {
+	case 0:
+		return p.language
+	case 2:
+		return p.format
+	case 3:
+		return p.bundle
+	}
+	return nil
+}
+
+// View renders the panel, highlighting the selected setting
+func (p *MangaSettingsPanel) View() string {
+	var b strings.Builder
+	b.WriteString(styles.SubtitleStyle.Render("Download settings:"))
+	b.WriteString("\n")
+	lines := []string{
+		fmt.Sprintf("%-10s ◂ %s ▸", p.language.label, p.language.values[p.language.index]),
+		fmt.Sprintf("%-10s %s", "Group", p.group.View()),
+		fmt.Sprintf("%-10s ◂ %s ▸", p.format.label, p.format.values[p.format.index]),
+		fmt.Sprintf("%-10s ◂ %s ▸", p.bundle.label, p.bundle.values[p.bundle.index]),
+	}
+	for i, line := range lines {
+		if i == p.selected {
+			line = styles.SelectedStyle.Render("▸ " + line)
+		} else {
+			line = styles.MutedStyle.Render("  " + line)
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// SummarizeMangaSettings describes the settings of a manga in a line, ""
+// when it has none
+func SummarizeMangaSettings(settings *data.MangaSettings) string {
+	if settings == nil {
+		return ""
+	}
+	var parts []string
+	if settings.Language != "" {
+		parts = append(parts, settings.Language)
+	}
+	if settings.Group != "" {
+		parts = append(parts, "by "+settings.Group)
+	}
+	if settings.Format != "" {
+		parts = append(parts, settings.Format)
+	}
+	if settings.BundleMode != "" {
+		parts = append(parts, "bundled per "+settings.BundleMode)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,36 @@
+package components
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+)
+
+// readerImageKey is the image ID of the reader's pages: a page drawn
+// replaces the previous one instead of leaving it behind
+const readerImageKey = "reader"
+
+// RenderPage draws a page image as large as fits in width x height cells,
+// keeping its aspect ratio: as an inline image when the terminal supports
+// it and with half blocks otherwise
+func RenderPage(protocol GraphicsProtocol, content []byte, width, height int) (string, error) {
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode page: %w", err)
+	}
+	bounds := img.Bounds()
+	if bounds.Dx() == 0 || bounds.Dy() == 0 || width <= 0 || height <= 0 {
+		return "", fmt.Errorf("empty page")
+	}
+
+	// Cells are twice as tall as wide
+	w, h := width, (width*bounds.Dy()/bounds.Dx()+1)/2
+	if h > height {
+		w, h = height*2*bounds.Dx()/bounds.Dy(), height
+	}
+	w, h = max(w, 1), max(h, 1)
+	if protocol == GraphicsNone {
+		return halfBlocks(img, w, h), nil
+	}
+	return inlineImage(protocol, imageID(readerImageKey), img, w, h)
+}
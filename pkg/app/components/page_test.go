@@ -0,0 +1,33 @@
+package components
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestRenderPage(t *testing.T) {
+	// A 40x60 page is 1.5 times as tall as wide, 0.75 times in cells
+	tests := []struct {
+		width, height int
+		wantW, wantH  int
+	}{
+		{10, 100, 10, 8}, // Fits the width
+		{40, 10, 13, 10}, // Fits the height
+	}
+	for _, protocol := range []GraphicsProtocol{GraphicsNone, GraphicsKitty} {
+		for _, tt := range tests {
+			view, err := RenderPage(protocol, testCover(t), tt.width, tt.height)
+			if err != nil {
+				t.Fatalf("RenderPage(%s, %dx%d) error = %v", protocol, tt.width, tt.height, err)
+			}
+			if w, h := lipgloss.Width(view), lipgloss.Height(view); w != tt.wantW || h != tt.wantH {
+				t.Errorf("RenderPage(%s, %dx%d) takes %dx%d cells, want %dx%d", protocol, tt.width, tt.height, w, h, tt.wantW, tt.wantH)
+			}
+		}
+	}
+
+	if _, err := RenderPage(GraphicsNone, []byte("not an image"), 10, 10); err == nil {
+		t.Error("Expected an error for a page that isn't an image")
+	}
+}
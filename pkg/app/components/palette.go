@@ -0,0 +1,209 @@
+package components
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kerbaras/mangas/pkg/app/styles"
+)
+
+// PaletteItem is an entry of the command palette
+type PaletteItem struct {
+	Title string      // Matched against the query
+	Hint  string      // Shown dimmed after the title, e.g. what kind of entry it is
+	Value interface{} // What the entry stands for, returned by Selected
+}
+
+// Palette is a fuzzy finder over actions and library entries: typing
+// narrows the items down, best match first
+type Palette struct {
+	title    string
+	input    textinput.Model
+	items    []PaletteItem
+	matches  []PaletteItem
+	selected int
+	Width    int
+	Height   int // Items shown at most
+}
+
+func NewPalette() *Palette {
+	ti := textinput.New()
+	ti.Prompt = "> "
+	ti.Placeholder = "Type a command or a manga..."
+	ti.CharLimit = 100
+	ti.Width = 50
+	return &Palette{input: ti, Height: 10}
+}
+
+// Open clears the query and lists items under title
+func (p *Palette) Open(title string, items []PaletteItem) tea.Cmd {
+	p.title = title
+	p.input.SetValue("")
+	p.SetItems(items)
+	p.input.Focus()
+	return textinput.Blink
+}
+
+// SetItems replaces the items, keeping the query
+func (p *Palette) SetItems(items []PaletteItem) {
+	p.items = items
+	p.filter()
+}
+
+// Update handles a key press: arrows move the selection, anything else
+// edits the query
+func (p *Palette) Update(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "up", "ctrl+k":
+		if p.selected > 0 {
+			p.selected--
+		}
+		return nil
+	case "down", "ctrl+j":
+		if p.selected < len(p.matches)-1 {
+			p.selected++
+		}
+		return nil
+	}
+
+	query := p.input.Value()
+	var cmd tea.Cmd
+	p.input, cmd = p.input.Update(msg)
+	if p.input.Value() != query {
+		p.filter()
+	}
+	return cmd
+}
+
+// Query returns what was typed
+func (p *Palette) Query() string {
+	return p.input.Value()
+}
+
+// Matches returns the items matching the query, best first
+func (p *Palette) Matches() []PaletteItem {
+	return p.matches
+}
+
+// Selected returns the selected item, false when nothing matches
+func (p *Palette) Selected() (PaletteItem, bool) {
+	if p.selected >= len(p.matches) {
+		return PaletteItem{}, false
+	}
+	return p.matches[p.selected], true
+}
+
+// filter ranks the items against the query. Ties keep the order of the
+// items, so actions listed first stay first.
+func (p *Palette) filter() {
+	type match struct {
+		item  PaletteItem
+		score int
+	}
+	var matches []match
+	for _, item := range p.items {
+		if score, ok := fuzzyScore(p.input.Value(), item.Title); ok {
+			matches = append(matches, match{item, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	p.matches = make([]PaletteItem, len(matches))
+	for i, m := range matches {
+		p.matches[i] = m.item
+	}
+	p.selected = 0
+}
+
+// fuzzyScore reports whether the letters of query appear in text in order,
+// ignoring case and spaces. Runs of consecutive letters and letters starting
+// a word score higher, and shorter texts win ties.
+func fuzzyScore(query, text string) (int, bool) {
+	var q []rune
+	for _, r := range strings.ToLower(query) {
+		if !unicode.IsSpace(r) {
+			q = append(q, r)
+		}
+	}
+	t := []rune(strings.ToLower(text))
+
+	score, matched, previous := 0, 0, -2
+	for i := 0; i < len(t) && matched < len(q); i++ {
+		if t[i] != q[matched] {
+			continue
+		}
+		switch {
+		case i == previous+1:
+			score += 8
+		case i == 0 || !unicode.IsLetter(t[i-1]) && !unicode.IsDigit(t[i-1]):
+			score += 6
+		default:
+			score += 1
+		}
+		previous = i
+		matched++
+	}
+	if matched < len(q) {
+		return 0, false
+	}
+	if len(q) == 0 {
+		return 0, true
+	}
+	return score*100 - len(t), true
+}
+
+func (p *Palette) View() string {
+	var b strings.Builder
+	b.WriteString(styles.TitleStyle.Render(p.title))
+	b.WriteString("\n\n")
+	b.WriteString(styles.FocusedInputStyle.Render(p.input.View()))
+	b.WriteString("\n\n")
+
+	if len(p.matches) == 0 {
+		b.WriteString(styles.MutedStyle.Render("No matches."))
+		b.WriteString("\n")
+		return b.String()
+	}
+
+	// Keep the selection in view
+	start := 0
+	if p.Height > 0 && p.selected >= p.Height {
+		start = p.selected - p.Height + 1
+	}
+	end := len(p.matches)
+	if p.Height > 0 && end > start+p.Height {
+		end = start + p.Height
+	}
+
+	for i := start; i < end; i++ {
+		item := p.matches[i]
+		line := item.Title
+		if runes := []rune(line); p.Width > 20 && len(runes) > p.Width-20 {
+			line = string(runes[:p.Width-23]) + "..."
+		}
+		switch {
+		case i == p.selected:
+			if item.Hint != "" {
+				line += " · " + item.Hint
+			}
+			b.WriteString(styles.SelectedStyle.Render("▸ " + line))
+		case item.Hint != "":
+			b.WriteString(styles.TextStyle.Render("  "+line) + " " + styles.MutedStyle.Render(item.Hint))
+		default:
+			b.WriteString(styles.TextStyle.Render("  " + line))
+		}
+		b.WriteString("\n")
+	}
+
+	if hidden := len(p.matches) - end; hidden > 0 {
+		b.WriteString(styles.MutedStyle.Render(fmt.Sprintf("... and %d more", hidden)))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
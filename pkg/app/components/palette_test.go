@@ -0,0 +1,81 @@
+package components
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func typeQuery(p *Palette, query string) {
+	for _, r := range query {
+		p.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+}
+
+func paletteTitles(p *Palette) []string {
+	var titles []string
+	for _, item := range p.Matches() {
+		titles = append(titles, item.Title)
+	}
+	return titles
+}
+
+func TestFuzzyScore(t *testing.T) {
+	if _, ok := fuzzyScore("dwn", "Download..."); !ok {
+		t.Error("Expected letters in order to match")
+	}
+	if _, ok := fuzzyScore("nwd", "Download..."); ok {
+		t.Error("Expected letters out of order not to match")
+	}
+	if _, ok := fuzzyScore("one piece", "One Piece"); !ok {
+		t.Error("Expected spaces in the query to be ignored")
+	}
+
+	prefix, _ := fuzzyScore("go", "Go to Queue")
+	scattered, _ := fuzzyScore("go", "Gintama Oneshots")
+	if prefix <= scattered {
+		t.Errorf("Expected consecutive letters to score higher, got %d <= %d", prefix, scattered)
+	}
+	short, _ := fuzzyScore("berserk", "Berserk")
+	long, _ := fuzzyScore("berserk", "Berserk of Gluttony")
+	if short <= long {
+		t.Errorf("Expected the shorter title to win a tie, got %d <= %d", short, long)
+	}
+}
+
+func TestPalette(t *testing.T) {
+	p := NewPalette()
+	p.Open("Commands", []PaletteItem{
+		{Title: "Download...", Value: 1},
+		{Title: "Go to Library", Value: 2},
+		{Title: "Go to Queue", Value: 3},
+		{Title: "Queen's Quality", Hint: "library", Value: 4},
+	})
+	if len(p.Matches()) != 4 {
+		t.Fatalf("Expected every item without a query, got %v", paletteTitles(p))
+	}
+
+	typeQuery(p, "queu")
+	if got := paletteTitles(p); len(got) != 2 || got[0] != "Go to Queue" {
+		t.Fatalf("Expected the queue view first, got %v", got)
+	}
+	p.Update(tea.KeyMsg{Type: tea.KeyDown})
+	if item, ok := p.Selected(); !ok || item.Value != 4 {
+		t.Errorf("Expected the second match to be selected, got %+v", item)
+	}
+
+	// Items loaded later keep the query
+	p.SetItems(append(p.items, PaletteItem{Title: "Queue Runner"}))
+	if got := paletteTitles(p); len(got) != 3 || got[1] != "Queue Runner" {
+		t.Errorf("Expected the new item to match, got %v", got)
+	}
+
+	typeQuery(p, "zz")
+	if _, ok := p.Selected(); ok {
+		t.Error("Expected nothing to be selected without matches")
+	}
+	if !strings.Contains(p.View(), "No matches") {
+		t.Errorf("Expected the view to say nothing matches, got:\n%s", p.View())
+	}
+}
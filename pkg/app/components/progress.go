@@ -5,22 +5,34 @@ import (
 	"strings"
 
 	"github.com/kerbaras/mangas/pkg/app/styles"
+	"github.com/kerbaras/mangas/pkg/integrations"
 	"github.com/kerbaras/mangas/pkg/services"
 )
 
 type ProgressTracker struct {
 	downloads map[string]*services.DownloadProgress
+	mangas    map[string]*services.DownloadProgress // Latest update of multi-chapter downloads
 	width     int
 }
 
 func NewProgressTracker(width int) *ProgressTracker {
 	return &ProgressTracker{
 		downloads: make(map[string]*services.DownloadProgress),
+		mangas:    make(map[string]*services.DownloadProgress),
 		width:     width,
 	}
 }
 
 func (p *ProgressTracker) Update(progress services.DownloadProgress) {
+	if progress.MangaChapters > 0 {
+		if progress.MangaCompleted >= progress.MangaChapters {
+			delete(p.mangas, progress.MangaID)
+		} else {
+			prog := progress // Copy
+			p.mangas[progress.MangaID] = &prog
+		}
+	}
+
 	key := progress.MangaID + ":" + progress.ChapterID
 	if progress.Status == "complete" && progress.ChapterID != "" {
 		// Remove completed chapter downloads
@@ -33,6 +45,7 @@ func (p *ProgressTracker) Update(progress services.DownloadProgress) {
 
 func (p *ProgressTracker) Clear() {
 	p.downloads = make(map[string]*services.DownloadProgress)
+	p.mangas = make(map[string]*services.DownloadProgress)
 }
 
 func (p *ProgressTracker) HasActive() bool {
@@ -48,6 +61,21 @@ func (p *ProgressTracker) View() string {
 	b.WriteString(styles.TitleStyle.Render("Active Downloads"))
 	b.WriteString("\n\n")
 
+	// Multi-chapter downloads first, with their overall pace
+	for _, progress := range p.mangas {
+		text := fmt.Sprintf("%d of %d chapters", progress.MangaCompleted, progress.MangaChapters)
+		if progress.Speed > 0 {
+			text += " · " + services.FormatBytes(int64(progress.Speed)) + "/s"
+		}
+		if eta := services.FormatETA(progress.ETA); eta != "" {
+			text += " · ETA " + eta
+		}
+		b.WriteString(renderProgressBar(progress.MangaCompleted, progress.MangaChapters, p.width-4))
+		b.WriteString("\n")
+		b.WriteString(styles.TextStyle.Render(text))
+		b.WriteString("\n\n")
+	}
+
 	for _, progress := range p.downloads {
 		// Chapter info
 		chapterText := fmt.Sprintf("Chapter %s", progress.ChapterNumber)
@@ -60,10 +88,22 @@ func (p *ProgressTracker) View() string {
 
 		// Status and progress
 		statusText := progress.Status
+		unit := "pages"
+		switch integrations.FinalizeStage(progress.Stage) {
+		case integrations.FinalizeRecognizing:
+			statusText, unit = "processing: recognizing text", "images"
+		case integrations.FinalizeStaging:
+			statusText, unit = "processing: staging images", "images"
+		case integrations.FinalizeWriting:
+			statusText, unit = "processing: writing archive", "images"
+		}
 		if progress.TotalPages > 0 {
 			percentage := float64(progress.CurrentPage) / float64(progress.TotalPages) * 100
-			statusText = fmt.Sprintf("%s (%d/%d pages - %.0f%%)",
-				progress.Status, progress.CurrentPage, progress.TotalPages, percentage)
+			statusText = fmt.Sprintf("%s (%d/%d %s - %.0f%%)",
+				statusText, progress.CurrentPage, progress.TotalPages, unit, percentage)
+			if progress.Status == "downloading" && progress.Bytes > 0 {
+				statusText += " · " + services.FormatBytes(progress.Bytes)
+			}
 
 			// Progress bar
 			bar := renderProgressBar(progress.CurrentPage, progress.TotalPages, p.width-4)
@@ -3,6 +3,7 @@ package components
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/kerbaras/mangas/pkg/services"
 )
@@ -164,6 +165,39 @@ func TestViewWithProgress(t *testing.T) {
 	}
 }
 
+func TestViewWithFinalizationProgress(t *testing.T) {
+	tracker := NewProgressTracker(80)
+
+	tracker.Update(services.DownloadProgress{
+		MangaID:       "manga-1",
+		ChapterID:     "ch-1",
+		ChapterNumber: "5",
+		Status:        "processing",
+		Stage:         "staging",
+		TotalPages:    20,
+		CurrentPage:   7,
+	})
+
+	view := tracker.View()
+	if !strings.Contains(view, "staging images (7/20 images") {
+		t.Errorf("Expected staging progress in view, got: %s", view)
+	}
+
+	tracker.Update(services.DownloadProgress{
+		MangaID:       "manga-1",
+		ChapterID:     "ch-1",
+		ChapterNumber: "5",
+		Status:        "processing",
+		Stage:         "writing",
+		TotalPages:    20,
+		CurrentPage:   20,
+	})
+
+	if view := tracker.View(); !strings.Contains(view, "writing archive") {
+		t.Errorf("Expected writing stage in view, got: %s", view)
+	}
+}
+
 func TestRenderProgressBar(t *testing.T) {
 	bar := renderProgressBar(50, 100, 20)
 
@@ -283,3 +317,47 @@ func (e *testError) Error() string {
 	return e.msg
 }
 
+
+func TestProgressTrackerMangaAggregate(t *testing.T) {
+	tracker := NewProgressTracker(80)
+
+	tracker.Update(services.DownloadProgress{
+		MangaID:        "manga-1",
+		ChapterID:      "ch-1",
+		ChapterNumber:  "1",
+		Status:         "complete",
+		MangaChapters:  3,
+		MangaCompleted: 1,
+		Speed:          2048,
+		ETA:            90 * time.Second,
+	})
+	tracker.Update(services.DownloadProgress{
+		MangaID:       "manga-1",
+		ChapterID:     "ch-2",
+		ChapterNumber: "2",
+		Status:        "downloading",
+		CurrentPage:   2,
+		TotalPages:    4,
+		Bytes:         4096,
+	})
+
+	view := tracker.View()
+	for _, want := range []string{"1 of 3 chapters", "2.0 KB/s", "ETA 1m30s", "4.0 KB"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q in view:\n%s", want, view)
+		}
+	}
+
+	// The aggregate goes away once every chapter is done
+	tracker.Update(services.DownloadProgress{
+		MangaID:        "manga-1",
+		ChapterID:      "ch-3",
+		ChapterNumber:  "3",
+		Status:         "complete",
+		MangaChapters:  3,
+		MangaCompleted: 3,
+	})
+	if strings.Contains(tracker.View(), "chapters") {
+		t.Errorf("Expected the finished manga download to be removed:\n%s", tracker.View())
+	}
+}
@@ -0,0 +1,52 @@
+package components
+
+import "fmt"
+
+// scrollMargin is how many rows are kept visible around the selected one,
+// when the window is tall enough
+const scrollMargin = 2
+
+// Scroller is the window of a long list that is rendered: Height rows
+// around the selected one, so lists of thousands of rows only render what
+// fits. The window only moves as far as needed to keep the selected row in
+// view, one row at a time while stepping through the list.
+type Scroller struct {
+	Height int // Rows shown at once
+	offset int // First row shown
+}
+
+func NewScroller(height int) *Scroller {
+	return &Scroller{Height: height}
+}
+
+// Visible moves the window to show the selected row of total rows, and
+// returns the range of rows shown, end excluded
+func (s *Scroller) Visible(selected, total int) (start, end int) {
+	height := max(s.Height, 1)
+	if total <= height {
+		s.offset = 0
+		return 0, total
+	}
+	margin := min(scrollMargin, (height-1)/2)
+	if selected-margin < s.offset {
+		s.offset = selected - margin
+	}
+	if selected+margin >= s.offset+height {
+		s.offset = selected + margin - height + 1
+	}
+	s.offset = max(0, min(s.offset, total-height))
+	return s.offset, s.offset + height
+}
+
+// Page returns how many rows page up and down move
+func (s *Scroller) Page() int {
+	return max(s.Height-1, 1)
+}
+
+// ScrollStatus describes the rows of a list shown, "" when they all are
+func ScrollStatus(start, end, total int, noun string) string {
+	if start == 0 && end >= total {
+		return ""
+	}
+	return fmt.Sprintf("Showing %d-%d of %d %s", start+1, end, total, noun)
+}
@@ -0,0 +1,52 @@
+package components
+
+import "testing"
+
+func TestScroller_Visible(t *testing.T) {
+	s := NewScroller(10)
+
+	if start, end := s.Visible(3, 4); start != 0 || end != 4 {
+		t.Errorf("short list: rows %d-%d, want all 4", start, end)
+	}
+
+	// Stepping down, the window follows one row at a time, keeping
+	// scrollMargin rows below the selected one
+	steps := []struct{ selected, start int }{
+		{0, 0},
+		{7, 0},
+		{8, 1},
+		{9, 2},
+		{8, 2}, // Back up, the window stays
+		{3, 1},
+		{999, 990},
+		{500, 498}, // Jumped up: the selected row lands scrollMargin from the top
+	}
+	for _, step := range steps {
+		start, end := s.Visible(step.selected, 1000)
+		if start != step.start || end != step.start+10 {
+			t.Errorf("selected %d: rows %d-%d, want %d-%d", step.selected, start, end, step.start, step.start+10)
+		}
+	}
+
+	// The list shrinking under the window pulls it back
+	if start, end := s.Visible(20, 25); start != 15 || end != 25 {
+		t.Errorf("after shrinking: rows %d-%d, want 15-25", start, end)
+	}
+
+	tiny := NewScroller(1)
+	if start, end := tiny.Visible(5, 10); start != 5 || end != 6 {
+		t.Errorf("one row window: rows %d-%d, want 5-6", start, end)
+	}
+	if tiny.Page() != 1 || s.Page() != 9 {
+		t.Errorf("Page() = %d and %d, want 1 and 9", tiny.Page(), s.Page())
+	}
+}
+
+func TestScrollStatus(t *testing.T) {
+	if got := ScrollStatus(0, 10, 10, "chapters"); got != "" {
+		t.Errorf("all rows shown: status %q, want none", got)
+	}
+	if got, want := ScrollStatus(10, 20, 1000, "chapters"), "Showing 11-20 of 1000 chapters"; got != want {
+		t.Errorf("ScrollStatus() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,119 @@
+package components
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/app/styles"
+	"github.com/kerbaras/mangas/pkg/sources"
+)
+
+// anyValue is the first value of every filter, it doesn't filter
+const anyValue = "any"
+
+// searchLanguages are the languages offered by the filter panel
+var searchLanguages = []string{"en", "ja", "es", "es-la", "fr", "pt-br", "it", "de", "ru", "ko", "zh"}
+
+// firstSearchYear is the oldest year offered by the filter panel
+const firstSearchYear = 1970
+
+// searchFilter is a filter of the panel, set to one of its values
+type searchFilter struct {
+	label  string
+	values []string
+	index  int
+}
+
+func (f *searchFilter) value() string {
+	if f.index == 0 {
+		return ""
+	}
+	return f.values[f.index]
+}
+
+// SearchFilters is the filter panel of the search screen: one line per
+// filter, the selected one cycles through its values
+type SearchFilters struct {
+	language, status, year, rating, order *searchFilter
+	filters                               []*searchFilter
+	selected                              int
+}
+
+func NewSearchFilters() *SearchFilters {
+	years := []string{anyValue}
+	for year := time.Now().Year(); year >= firstSearchYear; year-- {
+		years = append(years, fmt.Sprint(year))
+	}
+
+	f := &SearchFilters{
+		language: &searchFilter{label: "Language", values: append([]string{anyValue}, searchLanguages...)},
+		status:   &searchFilter{label: "Status", values: append([]string{anyValue}, sources.SearchStatuses...)},
+		year:     &searchFilter{label: "Year", values: years},
+		rating:   &searchFilter{label: "Content rating", values: append([]string{anyValue}, sources.ContentRatings...)},
+		// Relevance is the default order, it goes first
+		order: &searchFilter{label: "Sort by", values: sources.SearchOrders},
+	}
+	f.filters = []*searchFilter{f.language, f.status, f.year, f.rating, f.order}
+	return f
+}
+
+// Next selects the next filter
+func (f *SearchFilters) Next() {
+	if f.selected < len(f.filters)-1 {
+		f.selected++
+	}
+}
+
+// Prev selects the previous filter
+func (f *SearchFilters) Prev() {
+	if f.selected > 0 {
+		f.selected--
+	}
+}
+
+// Cycle moves the selected filter delta values forward, wrapping around
+func (f *SearchFilters) Cycle(delta int) {
+	filter := f.filters[f.selected]
+	n := len(filter.values)
+	filter.index = ((filter.index+delta)%n + n) % n
+}
+
+// Reset clears every filter
+func (f *SearchFilters) Reset() {
+	for _, filter := range f.filters {
+		filter.index = 0
+	}
+}
+
+// Options returns the search options set in the panel
+func (f *SearchFilters) Options() sources.SearchOptions {
+	options := sources.SearchOptions{
+		Language: f.language.value(),
+		Status:   f.status.value(),
+		Order:    f.order.value(),
+	}
+	fmt.Sscan(f.year.value(), &options.Year)
+	if rating := f.rating.value(); rating != "" {
+		options.ContentRating = []string{rating}
+	}
+	return options
+}
+
+// View renders the panel, highlighting the selected filter
+func (f *SearchFilters) View() string {
+	var b strings.Builder
+	b.WriteString(styles.SubtitleStyle.Render("Filters:"))
+	b.WriteString("\n")
+	for i, filter := range f.filters {
+		line := fmt.Sprintf("%-15s ◂ %s ▸", filter.label, filter.values[filter.index])
+		if i == f.selected {
+			line = styles.SelectedStyle.Render("▸ " + line)
+		} else {
+			line = styles.MutedStyle.Render("  " + line)
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
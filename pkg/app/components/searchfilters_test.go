@@ -0,0 +1,39 @@
+package components
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSearchFilters_Options(t *testing.T) {
+	f := NewSearchFilters()
+	if got := f.Options().Summary(); got != "" {
+		t.Errorf("New panel should not filter, got %q", got)
+	}
+
+	f.Cycle(1) // Language: en
+	f.Next()
+	f.Cycle(2) // Status: completed
+	f.Next()
+	f.Cycle(1) // Year: this year
+	f.Next()
+	f.Cycle(-1) // Content rating: wraps to the last one
+	f.Next()
+	f.Cycle(1) // Sort by: latest
+
+	options := f.Options()
+	want := fmt.Sprintf("en, completed, %d, pornographic, by latest", time.Now().Year())
+	if got := options.Summary(); got != want {
+		t.Errorf("Options() = %q, want %q", got, want)
+	}
+	if err := options.Validate(); err != nil {
+		t.Errorf("Options() are not valid: %v", err)
+	}
+
+	f.Next() // Stays on the last filter
+	f.Reset()
+	if got := f.Options().Summary(); got != "" {
+		t.Errorf("Reset panel should not filter, got %q", got)
+	}
+}
@@ -0,0 +1,161 @@
+package components
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/log"
+	xdraw "golang.org/x/image/draw"
+)
+
+// Thumbnail strips are at most this many cells tall
+const maxStripHeight = 8
+
+// ThumbnailLoader fetches the page thumbnail strip of a chapter
+type ThumbnailLoader func(chapter *data.Chapter) ([]byte, error)
+
+// ThumbnailsLoadedMsg reports that the thumbnail strip of a chapter was
+// loaded, or failed to
+type ThumbnailsLoadedMsg struct {
+	ChapterID string
+	Err       error
+}
+
+// ThumbnailStrip draws the page thumbnails of downloaded chapters Width
+// cells wide, as inline images when the terminal supports it and with
+// half blocks otherwise. Strips are kept rendered in memory.
+type ThumbnailStrip struct {
+	Protocol GraphicsProtocol
+	Width    int
+
+	load    ThumbnailLoader
+	mu      sync.Mutex
+	strips  map[string]string // Rendered strips by chapter ID
+	pending map[string]bool   // Loading or failed, not retried
+	frame   imageFrame
+}
+
+func NewThumbnailStrip(protocol GraphicsProtocol, load ThumbnailLoader) *ThumbnailStrip {
+	return &ThumbnailStrip{
+		Protocol: protocol,
+		Width:    64,
+		load:     load,
+		strips:   make(map[string]string),
+		pending:  make(map[string]bool),
+	}
+}
+
+// Load returns a command loading the strip of chapter, or nil when it is
+// loaded already, being loaded or the chapter isn't downloaded
+func (s *ThumbnailStrip) Load(chapter *data.Chapter) tea.Cmd {
+	if s == nil || s.load == nil || chapter == nil || !chapter.Downloaded {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.strips[chapter.ID]; ok || s.pending[chapter.ID] {
+		return nil
+	}
+	s.pending[chapter.ID] = true
+
+	width := s.Width
+	return func() tea.Msg {
+		content, err := s.load(chapter)
+		if err == nil {
+			var rendered string
+			rendered, err = s.render(chapter.ID, content, width)
+			if err == nil {
+				s.mu.Lock()
+				s.strips[chapter.ID] = rendered
+				delete(s.pending, chapter.ID)
+				s.mu.Unlock()
+			}
+		}
+		if err != nil {
+			log.Debug("thumbnails not shown", "chapter_id", chapter.ID, "err", err)
+		}
+		return ThumbnailsLoadedMsg{ChapterID: chapter.ID, Err: err}
+	}
+}
+
+// Forget drops the strip of a chapter, so it is loaded again, e.g. after
+// the chapter was downloaded again
+func (s *ThumbnailStrip) Forget(chapterID string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.strips, chapterID)
+	delete(s.pending, chapterID)
+}
+
+// View returns the strip of chapter, "" until it is loaded
+func (s *ThumbnailStrip) View(chapter *data.Chapter) string {
+	if s == nil || chapter == nil {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.strips[chapter.ID]
+}
+
+// Frame returns the sequences deleting the strip drawn in the last frame
+// unless it is chapter's, the one in view now or nil for none
+func (s *ThumbnailStrip) Frame(chapter *data.Chapter) string {
+	if s == nil {
+		return ""
+	}
+	var keys []string
+	if chapter != nil {
+		keys = append(keys, chapter.ID)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.frame.next(s.Protocol, keys)
+}
+
+// render draws a strip width cells wide, as tall as its aspect ratio asks
+func (s *ThumbnailStrip) render(chapterID string, content []byte, width int) (string, error) {
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode thumbnails: %w", err)
+	}
+	bounds := img.Bounds()
+	if bounds.Dx() == 0 || width <= 0 {
+		return "", fmt.Errorf("empty thumbnails")
+	}
+
+	// Cells are twice as tall as wide
+	height := (width*bounds.Dy()/bounds.Dx() + 1) / 2
+	height = min(max(height, 1), maxStripHeight)
+	if s.Protocol == GraphicsNone {
+		return halfBlocks(img, width, height), nil
+	}
+	return inlineImage(s.Protocol, imageID(chapterID), img, width, height)
+}
+
+// halfBlocks draws img over width x height cells with "▀" characters, each
+// cell showing two pixels: the top one in the foreground color, the bottom
+// one in the background color
+func halfBlocks(img image.Image, width, height int) string {
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height*2))
+	xdraw.ApproxBiLinear.Scale(scaled, scaled.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+
+	lines := make([]string, height)
+	for y := range lines {
+		var b strings.Builder
+		for x := 0; x < width; x++ {
+			top, bottom := scaled.RGBAAt(x, 2*y), scaled.RGBAAt(x, 2*y+1)
+			fmt.Fprintf(&b, "\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm▀", top.R, top.G, top.B, bottom.R, bottom.G, bottom.B)
+		}
+		b.WriteString("\x1b[0m")
+		lines[y] = b.String()
+	}
+	return strings.Join(lines, "\n")
+}
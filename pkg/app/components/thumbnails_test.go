@@ -0,0 +1,68 @@
+package components
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+func TestThumbnailStrip(t *testing.T) {
+	chapter := &data.Chapter{ID: "ch-1", Number: "1", Downloaded: true}
+	for _, protocol := range []GraphicsProtocol{GraphicsNone, GraphicsKitty} {
+		t.Run(protocol.String(), func(t *testing.T) {
+			loads := 0
+			s := NewThumbnailStrip(protocol, func(*data.Chapter) ([]byte, error) {
+				loads++
+				return testCover(t), nil
+			})
+			s.Width = 20
+
+			if s.View(chapter) != "" {
+				t.Error("Expected nothing before the strip is loaded")
+			}
+			if msg := s.Load(chapter)().(ThumbnailsLoadedMsg); msg.Err != nil || msg.ChapterID != "ch-1" {
+				t.Fatalf("Load() = %+v", msg)
+			}
+			if s.Load(chapter) != nil || loads != 1 {
+				t.Error("Expected the strip to be loaded once")
+			}
+
+			// A 40x60 image 20 cells wide is 30 pixels, 15 cells, tall
+			view := s.View(chapter)
+			if w, h := lipgloss.Width(view), lipgloss.Height(view); w != 20 || h != maxStripHeight {
+				t.Errorf("Strip takes %dx%d cells, want 20x%d", w, h, maxStripHeight)
+			}
+			if protocol == GraphicsNone && !strings.Contains(view, "▀") {
+				t.Errorf("Expected half blocks, got %q", view[:min(len(view), 40)])
+			}
+
+			s.Forget("ch-1")
+			if s.View(chapter) != "" || s.Load(chapter) == nil {
+				t.Error("Expected a forgotten strip to be loaded again")
+			}
+		})
+	}
+}
+
+func TestThumbnailStrip_Fallbacks(t *testing.T) {
+	s := NewThumbnailStrip(GraphicsNone, func(*data.Chapter) ([]byte, error) { return nil, errors.New("missing") })
+	if s.Load(&data.Chapter{ID: "ch-1"}) != nil {
+		t.Error("Chapters that aren't downloaded have no thumbnails")
+	}
+
+	chapter := &data.Chapter{ID: "ch-2", Downloaded: true}
+	if msg := s.Load(chapter)().(ThumbnailsLoadedMsg); msg.Err == nil {
+		t.Error("Expected the load error to be reported")
+	}
+	if s.Load(chapter) != nil || s.View(chapter) != "" {
+		t.Error("Failed strips should not be retried")
+	}
+
+	var nilStrip *ThumbnailStrip
+	if nilStrip.Load(chapter) != nil || nilStrip.View(chapter) != "" {
+		t.Error("A nil strip should show nothing")
+	}
+}
@@ -0,0 +1,75 @@
+package components
+
+import (
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kerbaras/mangas/pkg/app/styles"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// ToastDuration is how long a toast is shown
+const ToastDuration = 4 * time.Second
+
+// maxToasts is how many toasts are shown at once, the oldest go first
+const maxToasts = 3
+
+// toast is a transient notification
+type toast struct {
+	id   int
+	text string
+}
+
+// ToastExpiredMsg removes a toast once its time is up
+type ToastExpiredMsg struct {
+	id int
+}
+
+// Toasts shows short notifications, such as a finished download, for
+// ToastDuration each
+type Toasts struct {
+	toasts []toast // Oldest first
+	nextID int
+}
+
+func NewToasts() *Toasts {
+	return &Toasts{}
+}
+
+// Push shows a notification, returning the command expiring it
+func (t *Toasts) Push(text string) tea.Cmd {
+	t.nextID++
+	id := t.nextID
+	t.toasts = append(t.toasts, toast{id: id, text: text})
+	if len(t.toasts) > maxToasts {
+		t.toasts = t.toasts[len(t.toasts)-maxToasts:]
+	}
+	return tea.Tick(ToastDuration, func(time.Time) tea.Msg {
+		return ToastExpiredMsg{id: id}
+	})
+}
+
+// Expire removes the toast of msg
+func (t *Toasts) Expire(msg ToastExpiredMsg) {
+	for i, toast := range t.toasts {
+		if toast.id == msg.id {
+			t.toasts = append(t.toasts[:i], t.toasts[i+1:]...)
+			return
+		}
+	}
+}
+
+// Len returns how many toasts are shown
+func (t *Toasts) Len() int {
+	return len(t.toasts)
+}
+
+// View renders the toasts, newest last, "" when there are none
+func (t *Toasts) View() string {
+	lines := make([]string, len(t.toasts))
+	for i, toast := range t.toasts {
+		lines[i] = styles.StatusCompleted.Render(utils.IconInfo.String() + " " + toast.text)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,40 @@
+package components
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToasts(t *testing.T) {
+	toasts := NewToasts()
+	if toasts.Len() != 0 || toasts.View() != "" {
+		t.Fatal("no toasts should render nothing")
+	}
+
+	if cmd := toasts.Push("Queued 3 chapters"); cmd == nil {
+		t.Fatal("pushing a toast should return its expiry")
+	}
+	toasts.Push("Settings saved")
+	if view := toasts.View(); !strings.Contains(view, "Queued 3 chapters") || !strings.Contains(view, "Settings saved") {
+		t.Errorf("view is missing toasts:\n%s", view)
+	}
+
+	// The oldest go first past maxToasts
+	for i := 0; i < maxToasts; i++ {
+		toasts.Push("Chapter downloaded")
+	}
+	if toasts.Len() != maxToasts || strings.Contains(toasts.View(), "Queued 3 chapters") {
+		t.Errorf("expected the %d newest toasts, got %d:\n%s", maxToasts, toasts.Len(), toasts.View())
+	}
+
+	newest := toasts.toasts[maxToasts-1].id
+	toasts.Expire(ToastExpiredMsg{id: newest})
+	if toasts.Len() != maxToasts-1 {
+		t.Errorf("expired toast still shown, %d left", toasts.Len())
+	}
+	// Toasts pushed out already are expired without effect
+	toasts.Expire(ToastExpiredMsg{id: 1})
+	if toasts.Len() != maxToasts-1 {
+		t.Errorf("expiring a dropped toast removed another, %d left", toasts.Len())
+	}
+}
@@ -0,0 +1,504 @@
+package keys
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// Key styles, see Apply
+const (
+	StyleAll    = "all"    // Arrows and vim keys both move
+	StyleVim    = "vim"    // Only h, j, k and l move
+	StyleArrows = "arrows" // Only the arrows move
+)
+
+// Styles are the names accepted by Apply
+var Styles = []string{StyleAll, StyleVim, StyleArrows}
+
+// Global are the bindings working on every screen
+type Global struct {
+	Quit, NextView, Errors, Palette, Help key.Binding
+}
+
+// Dashboard are the bindings of the home dashboard
+type Dashboard struct {
+	Up, Down, Open, Library, Search, Queue, Refresh key.Binding
+}
+
+// Library are the bindings of the library
+type Library struct {
+	Up, Down, Left, Right, PageUp, PageDown, Home, End, Open, Filter, ClearFilter, Sort, Layout, OpenSource, EPUB,
+	Delete, Refresh key.Binding
+}
+
+// Search are the bindings of the search screen
+type Search struct {
+	Submit, Focus, Up, Down, Filters key.Binding
+}
+
+// Queue are the bindings of the download queue
+type Queue struct {
+	Up, Down, MoveUp, MoveDown, Top, Bottom, Start, Stop, Pause, Resume, Clear, Refresh key.Binding
+}
+
+// Stats are the bindings of the reading stats
+type Stats struct {
+	Up, Down, Open, Refresh key.Binding
+}
+
+// Details are the bindings of the manga details
+type Details struct {
+	Up, Down, PageUp, PageDown, Home, End, Read, Pick, PickAll, PickRange, Download, Queue, PrevRelated, NextRelated,
+	AddRelated, OpenSource, EPUB, ToggleRead, Settings, Refresh, Back key.Binding
+}
+
+// Reader are the bindings of the chapter reader
+type Reader struct {
+	PrevPage, NextPage, FirstPage, LastPage, PrevChapter, NextChapter, External, Back key.Binding
+}
+
+// ErrorCenter are the bindings of the error center
+type ErrorCenter struct {
+	Clear, Close key.Binding
+}
+
+// Panel are the bindings of the panels opened over a screen, such as the
+// search filters, the download settings of a manga and the palette
+type Panel struct {
+	Up, Down, Prev, Next, Reset, Confirm, Close key.Binding
+}
+
+// Map holds the bindings of every screen
+type Map struct {
+	Global      Global
+	Dashboard   Dashboard
+	Library     Library
+	Search      Search
+	Queue       Queue
+	Stats       Stats
+	Details     Details
+	Reader      Reader
+	ErrorCenter ErrorCenter
+	Panel       Panel
+}
+
+// Action is a binding and the name overriding its keys, e.g. download in
+// details.download=D
+type Action struct {
+	Name    string
+	Binding *key.Binding
+}
+
+// Group is the bindings of a screen, as listed by the help overlay
+type Group struct {
+	Screen  string // Prefix of the actions' names in overrides
+	Title   string
+	Actions []Action
+}
+
+func newBinding(desc string, keys ...string) key.Binding {
+	return key.NewBinding(key.WithKeys(keys...), key.WithHelp(helpKeys(keys), desc))
+}
+
+// Default returns the bindings the TUI ships with
+func Default() *Map {
+	return &Map{
+		Global: Global{
+			Quit:     newBinding("quit", "q"),
+			NextView: newBinding("switch view", "tab"),
+			Errors:   newBinding("errors", "ctrl+e"),
+			Palette:  newBinding("commands", "ctrl+p"),
+			Help:     newBinding("help", "?"),
+		},
+		Dashboard: Dashboard{
+			Up:      newBinding("navigate", "up", "k"),
+			Down:    newBinding("navigate", "down", "j"),
+			Open:    newBinding("open", "enter"),
+			Library: newBinding("library", "l"),
+			Search:  newBinding("search", "s"),
+			Queue:   newBinding("queue", "u"),
+			Refresh: newBinding("refresh", "r"),
+		},
+		Library: Library{
+			Up:          newBinding("up", "up", "k"),
+			Down:        newBinding("down", "down", "j"),
+			Left:        newBinding("left/right", "left", "h"),
+			Right:       newBinding("left/right", "right", "l"),
+			PageUp:      newBinding("page up/down", "pgup", "ctrl+u"),
+			PageDown:    newBinding("page up/down", "pgdown", "ctrl+d"),
+			Home:        newBinding("first/last", "home", "g"),
+			End:         newBinding("first/last", "end", "G"),
+			Open:        newBinding("details", "enter"),
+			Filter:      newBinding("filter", "/"),
+			ClearFilter: newBinding("clear filter", "esc"),
+			Sort:        newBinding("sort", "s"),
+			Layout:      newBinding("grid/list", "v"),
+			OpenSource:  newBinding("open source page", "O"),
+			EPUB:        newBinding("generate EPUB", "e"),
+			Delete:      newBinding("delete", "d"),
+			Refresh:     newBinding("refresh", "r"),
+		},
+		Search: Search{
+			Submit:  newBinding("search/download", "enter"),
+			Focus:   newBinding("switch focus", "esc"),
+			Up:      newBinding("navigate", "up", "k"),
+			Down:    newBinding("navigate", "down", "j"),
+			Filters: newBinding("filters", "ctrl+f"),
+		},
+		Queue: Queue{
+			Up:       newBinding("navigate", "up", "k"),
+			Down:     newBinding("navigate", "down", "j"),
+			MoveUp:   newBinding("move up/down", "K"),
+			MoveDown: newBinding("move up/down", "J"),
+			Top:      newBinding("move to top/bottom", "T"),
+			Bottom:   newBinding("move to top/bottom", "B"),
+			Start:    newBinding("start", "s"),
+			Stop:     newBinding("stop", "x"),
+			Pause:    newBinding("pause", "p"),
+			Resume:   newBinding("resume", "u"),
+			Clear:    newBinding("clear finished", "c"),
+			Refresh:  newBinding("refresh", "r"),
+		},
+		Stats: Stats{
+			Up:      newBinding("navigate", "up", "k"),
+			Down:    newBinding("navigate", "down", "j"),
+			Open:    newBinding("details", "enter"),
+			Refresh: newBinding("refresh", "r"),
+		},
+		Details: Details{
+			Up:          newBinding("navigate", "up", "k"),
+			Down:        newBinding("navigate", "down", "j"),
+			PageUp:      newBinding("page up/down", "pgup", "ctrl+u"),
+			PageDown:    newBinding("page up/down", "pgdown", "ctrl+d"),
+			Home:        newBinding("first/last", "home", "g"),
+			End:         newBinding("first/last", "end", "G"),
+			Read:        newBinding("read", "enter"),
+			Pick:        newBinding("pick", " "),
+			PickAll:     newBinding("pick all", "a"),
+			PickRange:   newBinding("pick range", "v"),
+			Download:    newBinding("download", "d"),
+			Queue:       newBinding("queue", "Q"),
+			PrevRelated: newBinding("related", "["),
+			NextRelated: newBinding("related", "]"),
+			AddRelated:  newBinding("add related", "+"),
+			OpenSource:  newBinding("open source page", "O"),
+			EPUB:        newBinding("generate EPUB", "e"),
+			ToggleRead:  newBinding("mark read/unread", "m"),
+			Settings:    newBinding("settings", "s"),
+			Refresh:     newBinding("refresh", "r"),
+			Back:        newBinding("back", "esc", "backspace"),
+		},
+		Reader: Reader{
+			PrevPage:    newBinding("page", "left", "h", "pgup"),
+			NextPage:    newBinding("page", "right", "l", " ", "pgdown"),
+			FirstPage:   newBinding("first/last page", "home", "g"),
+			LastPage:    newBinding("first/last page", "end", "G"),
+			PrevChapter: newBinding("previous/next chapter", "["),
+			NextChapter: newBinding("previous/next chapter", "]"),
+			External:    newBinding("open in viewer", "o"),
+			Back:        newBinding("back", "esc", "backspace"),
+		},
+		ErrorCenter: ErrorCenter{
+			Clear: newBinding("clear", "c"),
+			Close: newBinding("close", "esc"),
+		},
+		Panel: Panel{
+			Up:      newBinding("select", "up", "k"),
+			Down:    newBinding("select", "down", "j"),
+			Prev:    newBinding("change value", "left", "h"),
+			Next:    newBinding("change value", "right", "l"),
+			Reset:   newBinding("reset", "x"),
+			Confirm: newBinding("confirm", "enter"),
+			Close:   newBinding("close", "esc"),
+		},
+	}
+}
+
+// Groups returns the bindings by screen, global ones first
+func (m *Map) Groups() []Group {
+	return []Group{
+		{Screen: "global", Title: "Everywhere", Actions: []Action{
+			{"quit", &m.Global.Quit}, {"next-view", &m.Global.NextView}, {"errors", &m.Global.Errors},
+			{"palette", &m.Global.Palette}, {"help", &m.Global.Help},
+		}},
+		{Screen: "home", Title: "Home", Actions: []Action{
+			{"up", &m.Dashboard.Up}, {"down", &m.Dashboard.Down}, {"open", &m.Dashboard.Open},
+			{"library", &m.Dashboard.Library}, {"search", &m.Dashboard.Search}, {"queue", &m.Dashboard.Queue},
+			{"refresh", &m.Dashboard.Refresh},
+		}},
+		{Screen: "library", Title: "Library", Actions: []Action{
+			{"up", &m.Library.Up}, {"down", &m.Library.Down}, {"left", &m.Library.Left},
+			{"right", &m.Library.Right}, {"page-up", &m.Library.PageUp}, {"page-down", &m.Library.PageDown},
+			{"home", &m.Library.Home}, {"end", &m.Library.End}, {"open", &m.Library.Open}, {"filter", &m.Library.Filter},
+			{"clear-filter", &m.Library.ClearFilter}, {"sort", &m.Library.Sort}, {"layout", &m.Library.Layout},
+			{"open-source", &m.Library.OpenSource}, {"epub", &m.Library.EPUB}, {"delete", &m.Library.Delete},
+			{"refresh", &m.Library.Refresh},
+		}},
+		{Screen: "search", Title: "Search", Actions: []Action{
+			{"submit", &m.Search.Submit}, {"focus", &m.Search.Focus}, {"up", &m.Search.Up},
+			{"down", &m.Search.Down}, {"filters", &m.Search.Filters},
+		}},
+		{Screen: "queue", Title: "Queue", Actions: []Action{
+			{"up", &m.Queue.Up}, {"down", &m.Queue.Down}, {"move-up", &m.Queue.MoveUp},
+			{"move-down", &m.Queue.MoveDown}, {"top", &m.Queue.Top}, {"bottom", &m.Queue.Bottom},
+			{"start", &m.Queue.Start}, {"stop", &m.Queue.Stop}, {"pause", &m.Queue.Pause}, {"resume", &m.Queue.Resume},
+			{"clear", &m.Queue.Clear}, {"refresh", &m.Queue.Refresh},
+		}},
+		{Screen: "stats", Title: "Stats", Actions: []Action{
+			{"up", &m.Stats.Up}, {"down", &m.Stats.Down}, {"open", &m.Stats.Open}, {"refresh", &m.Stats.Refresh},
+		}},
+		{Screen: "details", Title: "Details", Actions: []Action{
+			{"up", &m.Details.Up}, {"down", &m.Details.Down}, {"page-up", &m.Details.PageUp},
+			{"page-down", &m.Details.PageDown}, {"home", &m.Details.Home}, {"end", &m.Details.End},
+			{"read", &m.Details.Read},
+			{"pick", &m.Details.Pick}, {"pick-all", &m.Details.PickAll}, {"pick-range", &m.Details.PickRange},
+			{"download", &m.Details.Download}, {"queue", &m.Details.Queue},
+			{"prev-related", &m.Details.PrevRelated}, {"next-related", &m.Details.NextRelated},
+			{"add-related", &m.Details.AddRelated}, {"open-source", &m.Details.OpenSource},
+			{"epub", &m.Details.EPUB}, {"toggle-read", &m.Details.ToggleRead},
+			{"settings", &m.Details.Settings}, {"refresh", &m.Details.Refresh}, {"back", &m.Details.Back},
+		}},
+		{Screen: "reader", Title: "Reader", Actions: []Action{
+			{"prev-page", &m.Reader.PrevPage}, {"next-page", &m.Reader.NextPage},
+			{"first-page", &m.Reader.FirstPage}, {"last-page", &m.Reader.LastPage},
+			{"prev-chapter", &m.Reader.PrevChapter}, {"next-chapter", &m.Reader.NextChapter},
+			{"external", &m.Reader.External}, {"back", &m.Reader.Back},
+		}},
+		{Screen: "errors", Title: "Error center", Actions: []Action{
+			{"clear", &m.ErrorCenter.Clear}, {"close", &m.ErrorCenter.Close},
+		}},
+		{Screen: "panel", Title: "Panels", Actions: []Action{
+			{"up", &m.Panel.Up}, {"down", &m.Panel.Down}, {"prev", &m.Panel.Prev}, {"next", &m.Panel.Next},
+			{"reset", &m.Panel.Reset}, {"confirm", &m.Panel.Confirm}, {"close", &m.Panel.Close},
+		}},
+	}
+}
+
+// Group returns the bindings of a screen, by the name used in overrides
+func (m *Map) Group(screen string) (Group, bool) {
+	for _, group := range m.Groups() {
+		if group.Screen == screen {
+			return group, true
+		}
+	}
+	return Group{}, false
+}
+
+// New returns the default bindings in a key style, with the keys of some
+// actions replaced. overrides is a list of screen.action=key,key entries
+// separated by spaces or semicolons, e.g. "details.download=D library.delete=x,delete".
+func New(style string, overrides string) (*Map, error) {
+	m := Default()
+	if err := m.applyStyle(style); err != nil {
+		return nil, err
+	}
+	for _, entry := range strings.FieldsFunc(overrides, func(r rune) bool { return r == ' ' || r == ';' || r == '\n' }) {
+		if err := m.override(entry); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.checkConflicts(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// navigationKeys are the keys dropped from bindings by key styles, when the
+// bindings keep other keys
+var navigationKeys = map[string][]string{
+	StyleVim:    {"up", "down", "left", "right"},
+	StyleArrows: {"k", "j", "h", "l"},
+}
+
+func (m *Map) applyStyle(style string) error {
+	if err := ValidateStyle(style); err != nil {
+		return err
+	}
+	dropped := navigationKeys[strings.ToLower(strings.TrimSpace(style))]
+	if len(dropped) == 0 {
+		return nil
+	}
+	for _, group := range m.Groups() {
+		for _, action := range group.Actions {
+			keys := slices.DeleteFunc(slices.Clone(action.Binding.Keys()), func(k string) bool {
+				return slices.Contains(dropped, k)
+			})
+			if len(keys) > 0 && len(keys) < len(action.Binding.Keys()) {
+				setKeys(action.Binding, keys)
+			}
+		}
+	}
+	return nil
+}
+
+// override applies one screen.action=key,key entry
+func (m *Map) override(entry string) error {
+	name, value, ok := strings.Cut(entry, "=")
+	screen, action, dotted := strings.Cut(name, ".")
+	if !ok || !dotted {
+		return fmt.Errorf("invalid key binding %q, expected screen.action=key", entry)
+	}
+	group, ok := m.Group(screen)
+	if !ok {
+		return fmt.Errorf("unknown screen %q in key binding %q", screen, entry)
+	}
+	var keys []string
+	for _, k := range strings.Split(value, ",") {
+		if k == "space" {
+			k = " "
+		}
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no keys in key binding %q", entry)
+	}
+	for _, a := range group.Actions {
+		if a.Name == action {
+			setKeys(a.Binding, keys)
+			return nil
+		}
+	}
+	names := make([]string, len(group.Actions))
+	for i, a := range group.Actions {
+		names[i] = a.Name
+	}
+	return fmt.Errorf("unknown action %q in key binding %q, %s has %s", action, entry, screen, strings.Join(names, ", "))
+}
+
+// checkConflicts fails when a key is bound to two actions of a screen, or
+// to an action of a screen and a global one
+func (m *Map) checkConflicts() error {
+	groups := m.Groups()
+	global := groups[0]
+	for _, group := range groups {
+		bound := make(map[string]string)
+		if group.Screen != global.Screen {
+			for _, action := range global.Actions {
+				for _, k := range action.Binding.Keys() {
+					bound[k] = global.Screen + "." + action.Name
+				}
+			}
+		}
+		for _, action := range group.Actions {
+			for _, k := range action.Binding.Keys() {
+				if other, ok := bound[k]; ok {
+					return fmt.Errorf("key %q is bound to both %s and %s.%s", k, other, group.Screen, action.Name)
+				}
+				bound[k] = group.Screen + "." + action.Name
+			}
+		}
+	}
+	return nil
+}
+
+func setKeys(b *key.Binding, keys []string) {
+	b.SetKeys(keys...)
+	b.SetHelp(helpKeys(keys), b.Help().Desc)
+}
+
+// keySymbols are how keys are shown in help
+var keySymbols = map[string]string{
+	"up":    "↑",
+	"down":  "↓",
+	"left":  "←",
+	"right": "→",
+	" ":     "space",
+}
+
+func helpKeys(keys []string) string {
+	shown := make([]string, len(keys))
+	for i, k := range keys {
+		shown[i] = k
+		if symbol, ok := keySymbols[k]; ok {
+			shown[i] = symbol
+		}
+	}
+	return strings.Join(shown, "/")
+}
+
+// ValidateStyle fails for key styles Apply doesn't accept
+func ValidateStyle(style string) error {
+	style = strings.ToLower(strings.TrimSpace(style))
+	if style == "" || slices.Contains(Styles, style) {
+		return nil
+	}
+	return fmt.Errorf("unknown key style %q, expected one of %s", style, strings.Join(Styles, ", "))
+}
+
+// ValidateOverrides fails for key binding overrides New doesn't accept
+func ValidateOverrides(overrides string) error {
+	_, err := New("", overrides)
+	return err
+}
+
+// current are the bindings in use, set with Apply
+var current = Default()
+
+// Apply switches the bindings in use to the ones of New, keeping the
+// current ones on error
+func Apply(style, overrides string) error {
+	m, err := New(style, overrides)
+	if err != nil {
+		return err
+	}
+	current = m
+	return nil
+}
+
+// Current returns the bindings in use
+func Current() *Map {
+	return current
+}
+
+// Bindings returns the bindings of the group's actions
+func (g Group) Bindings() []key.Binding {
+	bindings := make([]key.Binding, len(g.Actions))
+	for i, action := range g.Actions {
+		bindings[i] = *action.Binding
+	}
+	return bindings
+}
+
+// Entry is a line of help: the keys of bindings in a row sharing their
+// description, e.g. "↑/k ↓/j" to navigate
+type Entry struct {
+	Keys string
+	Desc string
+}
+
+// HelpEntries returns the help of bindings, merging the ones in a row
+// sharing their description
+func HelpEntries(bindings ...key.Binding) []Entry {
+	var entries []Entry
+	for i := 0; i < len(bindings); {
+		desc := bindings[i].Help().Desc
+		var keys []string
+		for ; i < len(bindings) && bindings[i].Help().Desc == desc; i++ {
+			keys = append(keys, bindings[i].Help().Key)
+		}
+		entries = append(entries, Entry{Keys: strings.Join(keys, " "), Desc: desc})
+	}
+	return entries
+}
+
+// Describe returns binding with another description in help, for actions
+// doing something different from one panel to the next
+func Describe(binding key.Binding, desc string) key.Binding {
+	binding.SetHelp(binding.Help().Key, desc)
+	return binding
+}
+
+// HelpLine renders bindings as a help line, "↑/k ↓/j: navigate • d: delete"
+func HelpLine(bindings ...key.Binding) string {
+	entries := HelpEntries(bindings...)
+	shown := make([]string, len(entries))
+	for i, entry := range entries {
+		shown[i] = entry.Keys + ": " + entry.Desc
+	}
+	return strings.Join(shown, " • ")
+}
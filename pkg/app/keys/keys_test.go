@@ -0,0 +1,114 @@
+package keys
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func press(k string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+}
+
+func TestDefault_NoConflicts(t *testing.T) {
+	if err := Default().checkConflicts(); err != nil {
+		t.Fatalf("default bindings conflict: %v", err)
+	}
+}
+
+func TestNew_Overrides(t *testing.T) {
+	m, err := New("", "details.download=D,ctrl+x; library.delete=x details.pick=space errors.clear=C")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if !key.Matches(press("D"), m.Details.Download) || key.Matches(press("d"), m.Details.Download) {
+		t.Errorf("details.download keys = %v", m.Details.Download.Keys())
+	}
+	if got := m.Details.Download.Help().Key; got != "D/ctrl+x" {
+		t.Errorf("help key = %q, want the new keys", got)
+	}
+	if !key.Matches(press("x"), m.Library.Delete) {
+		t.Errorf("library.delete keys = %v", m.Library.Delete.Keys())
+	}
+	if !key.Matches(press("C"), m.ErrorCenter.Clear) || key.Matches(press("c"), m.ErrorCenter.Clear) {
+		t.Errorf("errors.clear keys = %v", m.ErrorCenter.Clear.Keys())
+	}
+	if !key.Matches(tea.KeyMsg{Type: tea.KeySpace}, m.Details.Pick) {
+		t.Errorf("space should name the space bar, got %v", m.Details.Pick.Keys())
+	}
+	if !key.Matches(press("d"), Default().Details.Download) {
+		t.Error("overrides should not change the defaults")
+	}
+
+	for _, overrides := range []string{
+		"download=D",         // No screen
+		"nowhere.download=D", // Unknown screen
+		"details.nothing=D",  // Unknown action
+		"details.download=",  // No keys
+		"details.download=a", // Taken by pick-all
+		"library.delete=tab", // Taken by a global binding
+		"global.help=d",      // Taken by library.delete
+	} {
+		if _, err := New("", overrides); err == nil {
+			t.Errorf("New(%q) should fail", overrides)
+		}
+	}
+}
+
+func TestNew_Styles(t *testing.T) {
+	vim, err := New(StyleVim, "")
+	if err != nil {
+		t.Fatalf("New(vim) error = %v", err)
+	}
+	if keys := strings.Join(vim.Library.Up.Keys(), " "); keys != "k" {
+		t.Errorf("vim library.up = %q, want k", keys)
+	}
+	if keys := fmt.Sprintf("%q", vim.Reader.NextPage.Keys()); keys != `["l" " " "pgdown"]` {
+		t.Errorf("vim reader.next-page = %s", keys)
+	}
+
+	arrows, err := New(StyleArrows, "")
+	if err != nil {
+		t.Fatalf("New(arrows) error = %v", err)
+	}
+	if keys := strings.Join(arrows.Details.Down.Keys(), " "); keys != "down" {
+		t.Errorf("arrows details.down = %q, want down", keys)
+	}
+	// Bindings with only a letter keep it
+	if !key.Matches(press("l"), arrows.Dashboard.Library) {
+		t.Error("arrows style dropped the only key of home.library")
+	}
+
+	if _, err := New("emacs", ""); err == nil {
+		t.Error("expected an error for an unknown style")
+	}
+}
+
+func TestHelpLine(t *testing.T) {
+	m := Default()
+	got := HelpLine(m.Queue.Up, m.Queue.Down, m.Queue.MoveUp, m.Queue.MoveDown, m.Queue.Start)
+	want := "↑/k ↓/j: navigate • K J: move up/down • s: start"
+	if got != want {
+		t.Errorf("HelpLine() = %q, want %q", got, want)
+	}
+}
+
+func TestApply(t *testing.T) {
+	defer func() { current = Default() }()
+
+	if err := Apply("", "queue.start=S"); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if !key.Matches(press("S"), Current().Queue.Start) {
+		t.Error("Apply() should switch the bindings in use")
+	}
+	if err := Apply("", "queue.start="); err == nil {
+		t.Fatal("expected an error for an empty binding")
+	}
+	if !key.Matches(press("S"), Current().Queue.Start) {
+		t.Error("a failed Apply() should keep the bindings in use")
+	}
+}
@@ -0,0 +1,342 @@
+package screens
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kerbaras/mangas/pkg/app/components"
+	"github.com/kerbaras/mangas/pkg/app/keys"
+	"github.com/kerbaras/mangas/pkg/app/styles"
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// dashboardLimit is how many entries each dashboard section lists
+const dashboardLimit = 5
+
+// DashboardScreen is the home screen: what to read next, what is downloading
+// and what changed since the last visit
+type DashboardScreen struct {
+	repo       *data.Repository
+	downloader *services.Downloader
+	queue      *services.DownloadQueue
+
+	since     time.Time // Last visit, zero on the first one
+	visited   bool      // The visit was recorded
+	listening bool      // Progress updates are being received
+
+	reading      []*data.MangaChapter
+	downloads    []*services.QueueEntry // Queued and downloading chapters
+	updates      []*data.MangaChapter
+	added        []*data.Manga
+	progress     map[string]services.DownloadProgress // Live progress by chapter ID
+	subscription <-chan services.DownloadProgress     // Progress updates of the downloader
+
+	selected int
+	width    int
+	height   int
+	err      error
+}
+
+// dashboardItem is an entry that can be selected on the dashboard
+type dashboardItem struct {
+	screen string // Screen enter opens
+	data   interface{}
+}
+
+func NewDashboardScreen(repo *data.Repository, downloader *services.Downloader, queue *services.DownloadQueue) *DashboardScreen {
+	return &DashboardScreen{
+		repo:       repo,
+		downloader: downloader,
+		queue:      queue,
+		progress:   make(map[string]services.DownloadProgress),
+	}
+}
+
+func (s *DashboardScreen) Init() tea.Cmd {
+	var cmds []tea.Cmd
+	if !s.visited {
+		cmds = append(cmds, s.visit)
+	} else {
+		cmds = append(cmds, s.load)
+	}
+	if !s.listening && s.downloader != nil {
+		s.listening = true
+		s.subscription, _ = s.downloader.SubscribeProgress()
+		cmds = append(cmds, s.listenForProgress)
+	}
+	return tea.Batch(cmds...)
+}
+
+func (s *DashboardScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		s.width = msg.Width
+		s.height = msg.Height
+
+	case tea.KeyMsg:
+		km := keys.Current().Dashboard
+		switch {
+		case key.Matches(msg, km.Up):
+			if s.selected > 0 {
+				s.selected--
+			}
+		case key.Matches(msg, km.Down):
+			if s.selected < len(s.items())-1 {
+				s.selected++
+			}
+		case key.Matches(msg, km.Open):
+			if items := s.items(); s.selected < len(items) {
+				item := items[s.selected]
+				return s, func() tea.Msg {
+					return SwitchScreenMsg{Screen: item.screen, Data: item.data}
+				}
+			}
+		case key.Matches(msg, km.Library):
+			return s, switchScreen("library")
+		case key.Matches(msg, km.Search):
+			return s, switchScreen("search")
+		case key.Matches(msg, km.Queue):
+			return s, switchScreen("queue")
+		case key.Matches(msg, km.Refresh):
+			return s, s.load
+		}
+
+	case dashboardVisitMsg:
+		s.visited = true
+		s.since = msg.since
+		return s, tea.Batch(s.load, reportError("home", msg.err, components.SeverityWarning))
+
+	case dashboardLoadedMsg:
+		s.err = msg.err
+		if msg.err == nil {
+			s.reading, s.downloads, s.updates, s.added = msg.reading, msg.downloads, msg.updates, msg.added
+		}
+		if s.selected >= len(s.items()) {
+			s.selected = len(s.items()) - 1
+		}
+		if s.selected < 0 {
+			s.selected = 0
+		}
+		return s, reportError("home", msg.err, components.SeverityFatal)
+
+	case dashboardProgressMsg:
+		progress := services.DownloadProgress(msg)
+		cmds := []tea.Cmd{s.listenForProgress}
+		switch progress.Status {
+		case "complete", "error":
+			// The chapter leaves the downloads, the next one starts
+			delete(s.progress, progress.ChapterID)
+			cmds = append(cmds, s.load)
+		default:
+			if _, known := s.progress[progress.ChapterID]; !known {
+				cmds = append(cmds, s.load)
+			}
+			s.progress[progress.ChapterID] = progress
+		}
+		return s, tea.Batch(cmds...)
+	}
+
+	return s, nil
+}
+
+func (s *DashboardScreen) View() string {
+	if s.width == 0 {
+		return "Loading..."
+	}
+
+	header := styles.TitleStyle.Render(utils.IconHome.String() + " Home")
+	if !s.since.IsZero() {
+		header += " " + styles.MutedStyle.Render("last visit "+s.since.Format("Jan 2 15:04"))
+	}
+
+	var errorMsg string
+	if s.err != nil {
+		errorMsg = styles.StatusError.Render(fmt.Sprintf("Error: %s", s.err))
+		errorMsg += "\n\n"
+	}
+
+	km, global := keys.Current().Dashboard, keys.Current().Global
+	help := styles.HelpStyle.Render(keys.HelpLine(
+		km.Up, km.Down, km.Open, km.Library, km.Search, km.Queue, km.Refresh, global.NextView, global.Help, global.Quit,
+	))
+
+	return fmt.Sprintf("%s\n\n%s%s\n%s", header, errorMsg, s.renderSections(), help)
+}
+
+// items lists the selectable entries in the order they are displayed
+func (s *DashboardScreen) items() []dashboardItem {
+	var items []dashboardItem
+	for _, entry := range s.reading {
+		items = append(items, dashboardItem{screen: "details", data: entry.Manga.ID})
+	}
+	for range s.downloads {
+		items = append(items, dashboardItem{screen: "queue"})
+	}
+	for _, entry := range s.updates {
+		items = append(items, dashboardItem{screen: "details", data: entry.Manga.ID})
+	}
+	for _, manga := range s.added {
+		items = append(items, dashboardItem{screen: "details", data: manga.ID})
+	}
+	return items
+}
+
+func (s *DashboardScreen) renderSections() string {
+	var b strings.Builder
+	index := 0
+	section := func(title, empty string, lines []string) {
+		b.WriteString(styles.SubtitleStyle.Render(title))
+		b.WriteString("\n")
+		if len(lines) == 0 {
+			b.WriteString(styles.MutedStyle.Render("  " + empty))
+			b.WriteString("\n")
+		}
+		for _, line := range lines {
+			if index == s.selected {
+				b.WriteString(styles.SelectedStyle.Render(line))
+			} else {
+				b.WriteString(styles.TextStyle.Render("  " + line))
+			}
+			b.WriteString("\n")
+			index++
+		}
+		b.WriteString("\n")
+	}
+
+	var lines []string
+	for _, entry := range s.reading {
+		line := fmt.Sprintf("▶ %s - Ch. %s", entry.Manga.Name, entry.Chapter.Number)
+		if entry.Chapter.LastReadPage > 0 {
+			line += fmt.Sprintf(" (page %d)", entry.Chapter.LastReadPage)
+		}
+		lines = append(lines, line)
+	}
+	section(utils.IconBook.String()+" Continue Reading", "Nothing in progress. Mark chapters read in the details view.", lines)
+
+	lines = nil
+	for _, entry := range s.downloads {
+		lines = append(lines, s.downloadLine(entry))
+	}
+	section(utils.IconDownload.String()+" Downloads", "Nothing is downloading.", lines)
+
+	lines = nil
+	for _, entry := range s.updates {
+		line := fmt.Sprintf("%s %s - Ch. %s", utils.IconNew, entry.Manga.Name, entry.Chapter.Number)
+		if entry.Chapter.Title != "" {
+			line += ": " + entry.Chapter.Title
+		}
+		lines = append(lines, line)
+	}
+	empty := "No new chapters since your last visit."
+	if s.since.IsZero() {
+		empty = "Chapters found from now on will show up here."
+	}
+	section(utils.IconNew.String()+" New Chapters", empty, lines)
+
+	lines = nil
+	for _, manga := range s.added {
+		lines = append(lines, "+ "+manga.Name)
+	}
+	section(utils.IconLibrary.String()+" Recently Added", "The library is empty. Find mangas in the search view.", lines)
+
+	return b.String()
+}
+
+// downloadLine describes a queued chapter with its live progress
+func (s *DashboardScreen) downloadLine(entry *services.QueueEntry) string {
+	name := entry.MangaID
+	if entry.Manga != nil {
+		name = entry.Manga.Name
+	}
+	chapter := entry.ChapterID
+	if entry.Chapter != nil {
+		chapter = entry.Chapter.Number
+	}
+
+	if entry.Status != data.QueueActive {
+		return fmt.Sprintf("○ %s - Ch. %s", name, chapter)
+	}
+	line := fmt.Sprintf("◐ %s - Ch. %s", name, chapter)
+	if progress, ok := s.progress[entry.ChapterID]; ok {
+		switch {
+		case progress.Status == "processing":
+			line += " (processing)"
+		case progress.TotalPages > 0:
+			line += fmt.Sprintf(" (%d/%d pages)", progress.CurrentPage, progress.TotalPages)
+		}
+	}
+	return line
+}
+
+// Messages
+type dashboardVisitMsg struct {
+	since time.Time
+	err   error
+}
+
+type dashboardLoadedMsg struct {
+	reading   []*data.MangaChapter
+	downloads []*services.QueueEntry
+	updates   []*data.MangaChapter
+	added     []*data.Manga
+	err       error
+}
+
+// dashboardProgressMsg is a download progress update received by the
+// dashboard, delivered to it even while another screen is displayed
+type dashboardProgressMsg services.DownloadProgress
+
+// Commands
+
+// visit records this visit, returning when the previous one was
+func (s *DashboardScreen) visit() tea.Msg {
+	msg := dashboardVisitMsg{}
+	if last, err := s.repo.GetState(data.LastVisitKey); err != nil {
+		msg.err = err
+	} else if last != "" {
+		msg.since, msg.err = time.Parse(time.RFC3339Nano, last)
+	}
+	if err := s.repo.SetState(data.LastVisitKey, time.Now().Format(time.RFC3339Nano)); err != nil && msg.err == nil {
+		msg.err = err
+	}
+	return msg
+}
+
+func (s *DashboardScreen) load() tea.Msg {
+	msg := dashboardLoadedMsg{}
+	if msg.reading, msg.err = s.repo.ContinueReading(dashboardLimit); msg.err != nil {
+		return msg
+	}
+	if !s.since.IsZero() {
+		if msg.updates, msg.err = s.repo.NewChaptersSince(s.since, dashboardLimit); msg.err != nil {
+			return msg
+		}
+	}
+	if msg.added, msg.err = s.repo.RecentlyAddedMangas(dashboardLimit); msg.err != nil {
+		return msg
+	}
+
+	entries, err := s.queue.List()
+	if err != nil {
+		msg.err = err
+		return msg
+	}
+	for _, entry := range entries {
+		if len(msg.downloads) == dashboardLimit {
+			break
+		}
+		if entry.Status == data.QueueActive || entry.Status == data.QueueQueued {
+			msg.downloads = append(msg.downloads, entry)
+		}
+	}
+	return msg
+}
+
+func (s *DashboardScreen) listenForProgress() tea.Msg {
+	return dashboardProgressMsg(<-s.subscription)
+}
@@ -0,0 +1,59 @@
+package screens
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/services"
+)
+
+func TestDashboardScreen(t *testing.T) {
+	s := NewDashboardScreen(nil, nil, nil)
+	s.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	reading := &data.MangaChapter{
+		Manga:   &data.Manga{ID: "m1", Name: "Reading Manga"},
+		Chapter: &data.Chapter{ID: "c1", Number: "3", LastReadPage: 7},
+	}
+	download := &services.QueueEntry{
+		QueueItem: &data.QueueItem{MangaID: "m2", ChapterID: "c2", Status: data.QueueActive},
+		Manga:     &data.Manga{ID: "m2", Name: "Downloading Manga"},
+	}
+	s.Update(dashboardLoadedMsg{
+		reading:   []*data.MangaChapter{reading},
+		downloads: []*services.QueueEntry{download},
+		added:     []*data.Manga{{ID: "m3", Name: "Added Manga"}},
+	})
+	s.Update(dashboardProgressMsg{ChapterID: "c2", CurrentPage: 4, TotalPages: 20, Status: "downloading"})
+
+	view := s.View()
+	for _, want := range []string{"Reading Manga - Ch. 3 (page 7)", "Downloading Manga - Ch. c2 (4/20 pages)", "+ Added Manga", "Chapters found from now on"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected the view to show %q, got:\n%s", want, view)
+		}
+	}
+
+	// Selection moves across the sections
+	open := func() SwitchScreenMsg {
+		_, cmd := s.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		return cmd().(SwitchScreenMsg)
+	}
+	if msg := open(); msg.Screen != "details" || msg.Data != "m1" {
+		t.Errorf("Expected the manga being read to open, got %+v", msg)
+	}
+	s.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("j")})
+	if msg := open(); msg.Screen != "queue" {
+		t.Errorf("Expected a download to open the queue, got %+v", msg)
+	}
+	s.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("j")})
+	s.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("j")})
+	if msg := open(); msg.Data != "m3" {
+		t.Errorf("Expected the selection to stop at the last manga, got %+v", msg)
+	}
+
+	_, cmd := s.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("l")})
+	if msg := cmd().(SwitchScreenMsg); msg.Screen != "library" {
+		t.Errorf("Expected l to open the library, got %+v", msg)
+	}
+}
@@ -1,40 +1,69 @@
 package screens
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/kerbaras/mangas/pkg/app/components"
+	"github.com/kerbaras/mangas/pkg/app/keys"
 	"github.com/kerbaras/mangas/pkg/app/styles"
 	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/log"
 	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/sources"
+	"github.com/kerbaras/mangas/pkg/utils"
 )
 
 type DetailsScreen struct {
-	repo           *data.Repository
-	downloader     *services.Downloader
-	mangaID        string
-	manga          *data.Manga
-	chapters       []*data.Chapter
-	selectedChapter int
-	progressTracker *components.ProgressTracker
-	width          int
-	height         int
-	err            error
-}
-
-func NewDetailsScreen(repo *data.Repository, downloader *services.Downloader, mangaID string) *DetailsScreen {
+	repo             *data.Repository
+	downloader       *services.Downloader
+	queue            *services.DownloadQueue
+	thumbnails       *components.ThumbnailStrip // Page thumbnails of downloaded chapters, nil shows none
+	mangaID          string
+	manga            *data.Manga
+	chapters         []*data.Chapter
+	selectedChapter  int
+	chapterScroller  *components.Scroller // Chapters shown around the selected one
+	picked           map[string]bool   // Chapters picked for download, by ID
+	rangeStart       int               // Start of the range being picked with v, -1 when none
+	chapterStatus    map[string]string // Live download status of chapters, by ID
+	relations        []*data.Relation
+	readingLeft      string // Unread downloaded chapters and the time to read them
+	settings         *data.MangaSettings
+	settingsPanel    *components.MangaSettingsPanel // Open while the settings are edited, nil otherwise
+	confirm          *components.Confirm            // Bulk download waiting for confirmation, nil when none
+	selectedRelation int
+	progressTracker  *components.ProgressTracker
+	progress         <-chan services.DownloadProgress // Download progress, until Close unsubscribes
+	unsubscribe      func()
+	width            int
+	height           int
+	err              error
+}
+
+func NewDetailsScreen(repo *data.Repository, downloader *services.Downloader, queue *services.DownloadQueue, thumbnails *components.ThumbnailStrip, mangaID string) *DetailsScreen {
 	return &DetailsScreen{
 		repo:            repo,
 		downloader:      downloader,
+		queue:           queue,
+		thumbnails:      thumbnails,
 		mangaID:         mangaID,
+		picked:          make(map[string]bool),
+		rangeStart:      -1,
+		chapterScroller: components.NewScroller(minChapterRows),
+		chapterStatus:   make(map[string]string),
 		progressTracker: components.NewProgressTracker(80),
 	}
 }
 
 func (s *DetailsScreen) Init() tea.Cmd {
+	if s.downloader != nil && s.progress == nil {
+		s.progress, s.unsubscribe = s.downloader.SubscribeProgress()
+	}
 	return tea.Batch(
 		s.loadDetails,
 		s.listenForProgress,
@@ -49,21 +78,102 @@ func (s *DetailsScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		s.progressTracker = components.NewProgressTracker(msg.Width - 4)
 
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "up", "k":
+		if s.settingsPanel != nil {
+			return s, s.updateSettings(msg)
+		}
+		if s.confirm != nil {
+			done, cmd := s.confirm.Update(msg)
+			if done {
+				s.confirm = nil
+			}
+			return s, cmd
+		}
+		km := keys.Current().Details
+		switch {
+		case key.Matches(msg, km.Up):
 			if s.selectedChapter > 0 {
 				s.selectedChapter--
 			}
-		case "down", "j":
+			return s, s.loadThumbnails()
+		case key.Matches(msg, km.Down):
 			if s.selectedChapter < len(s.chapters)-1 {
 				s.selectedChapter++
 			}
-		case "r":
+			return s, s.loadThumbnails()
+		case key.Matches(msg, km.PageUp):
+			return s, s.selectChapter(s.selectedChapter - s.chapterScroller.Page())
+		case key.Matches(msg, km.PageDown):
+			return s, s.selectChapter(s.selectedChapter + s.chapterScroller.Page())
+		case key.Matches(msg, km.Home):
+			return s, s.selectChapter(0)
+		case key.Matches(msg, km.End):
+			return s, s.selectChapter(len(s.chapters) - 1)
+		case key.Matches(msg, km.PrevRelated):
+			if s.selectedRelation > 0 {
+				s.selectedRelation--
+			}
+		case key.Matches(msg, km.NextRelated):
+			if s.selectedRelation < len(s.relations)-1 {
+				s.selectedRelation++
+			}
+		case key.Matches(msg, km.Pick):
+			// Pick the selected chapter for download, or unpick it
+			if len(s.chapters) > 0 {
+				s.togglePicked(s.selectedChapter)
+			}
+		case key.Matches(msg, km.PickAll):
+			// Pick every chapter, or none when all are picked
+			s.pickAll()
+		case key.Matches(msg, km.PickRange):
+			// Start a range at the selected chapter, or pick up to it
+			if len(s.chapters) > 0 {
+				if s.rangeStart < 0 {
+					s.rangeStart = s.selectedChapter
+				} else {
+					s.pickRange(s.rangeStart, s.selectedChapter)
+					s.rangeStart = -1
+				}
+			}
+		case key.Matches(msg, km.Download):
+			// Download the picked chapters, or the selected one
+			return s, s.confirmQueue(true)
+		case key.Matches(msg, km.AddRelated):
+			// Add the selected related series to the library
+			if len(s.relations) > 0 {
+				return s, s.addRelated(s.relations[s.selectedRelation])
+			}
+		case key.Matches(msg, km.Read):
+			// Read the selected chapter
+			if len(s.chapters) > 0 {
+				return s, s.readChapter(s.chapters[s.selectedChapter])
+			}
+		case key.Matches(msg, km.Refresh):
 			return s, s.loadDetails
-		case "e":
+		case key.Matches(msg, km.OpenSource):
+			// Open the selected chapter (or the manga) on the source website
+			return s, openSource(s.sourcePageURL())
+		case key.Matches(msg, km.EPUB):
 			// Generate EPUB
 			return s, s.generateEPUB()
-		case "esc", "backspace":
+		case key.Matches(msg, km.ToggleRead):
+			// Toggle the read state of the selected chapter
+			if len(s.chapters) > 0 {
+				return s, s.toggleRead(s.chapters[s.selectedChapter])
+			}
+		case key.Matches(msg, km.Queue):
+			// Queue the picked chapters, or the selected one, for later
+			return s, s.confirmQueue(false)
+		case key.Matches(msg, km.Settings):
+			// Edit the download settings of the manga
+			if s.settings != nil {
+				s.settingsPanel = components.NewMangaSettingsPanel(s.settings)
+			}
+		case key.Matches(msg, km.Back):
+			if s.rangeStart >= 0 {
+				// Cancel the range instead of leaving
+				s.rangeStart = -1
+				return s, nil
+			}
 			// Go back to library
 			return s, func() tea.Msg {
 				return SwitchScreenMsg{Screen: "library", Data: nil}
@@ -73,17 +183,82 @@ func (s *DetailsScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case detailsLoadedMsg:
 		s.manga = msg.manga
 		s.chapters = msg.chapters
+		s.relations = msg.relations
+		s.readingLeft = msg.readingLeft
+		s.settings = msg.settings
+		if s.selectedRelation >= len(s.relations) {
+			s.selectedRelation = 0
+		}
+		s.err = msg.err
+		return s, tea.Batch(s.loadThumbnails(), reportError("details", msg.err, components.SeverityFatal),
+			reportError("details", msg.relationErr, components.SeverityWarning))
+
+	case relatedAddedMsg:
+		s.err = msg.err
+		return s, reportError("details", msg.err, components.SeverityWarning)
+
+	case chapterQueuedMsg:
+		s.err = msg.err
+		if msg.err != nil {
+			return s, reportError("details", msg.err, components.SeverityWarning)
+		}
+		for _, id := range msg.chapterIDs {
+			s.chapterStatus[id] = "queued"
+		}
+		s.picked = make(map[string]bool)
+		toast := showToast("Queued %d chapters", len(msg.chapterIDs))
+		if msg.start {
+			toast = showToast("Downloading %d chapters", len(msg.chapterIDs))
+		}
+		if msg.start && !s.queue.Running() {
+			return s, tea.Batch(toast, s.runQueue)
+		}
+		return s, toast
+
+	case queueDoneMsg:
+		return s, tea.Batch(s.loadDetails, reportError("queue", msg.err, components.SeverityWarning))
+
+	case chapterReadMsg:
+		if msg.err != nil {
+			s.err = msg.err
+		}
+		return s, tea.Batch(s.loadDetails, reportError("details", msg.err, components.SeverityWarning))
+
+	case settingsSavedMsg:
+		if msg.err != nil {
+			return s, reportError("details", msg.err, components.SeverityWarning)
+		}
+		s.settings = msg.settings
+		s.settingsPanel = nil
+		return s, showToast("Download settings saved")
+
+	case sourceOpenedMsg:
 		s.err = msg.err
+		return s, reportError("details", msg.err, components.SeverityWarning)
 
 	case services.DownloadProgress:
 		s.progressTracker.Update(msg)
-		return s, s.listenForProgress
+		s.trackChapter(msg)
+		var err error
+		if msg.Status == "error" && msg.Error != nil {
+			err = fmt.Errorf("chapter %s: %w", msg.ChapterNumber, msg.Error)
+		}
+		var thumbnails, toast tea.Cmd
+		if msg.Status == "complete" {
+			// The chapter may have been downloaded again, with other pages
+			s.thumbnails.Forget(msg.ChapterID)
+			thumbnails = s.loadThumbnails()
+			if msg.MangaID == s.mangaID && msg.ChapterNumber != "" {
+				toast = showToast("Chapter %s downloaded", msg.ChapterNumber)
+			}
+		}
+		return s, tea.Batch(s.listenForProgress, thumbnails, toast, reportError("download", err, components.SeverityWarning))
 
 	case epubGeneratedMsg:
 		if msg.err != nil {
 			s.err = msg.err
 		}
-		return s, s.loadDetails
+		return s, tea.Batch(s.loadDetails, reportError("details", msg.err, components.SeverityWarning))
 	}
 
 	return s, nil
@@ -94,7 +269,7 @@ func (s *DetailsScreen) View() string {
 		return "Loading..."
 	}
 
-	header := styles.TitleStyle.Render(fmt.Sprintf("📖 %s", s.manga.Name))
+	header := styles.TitleStyle.Render(fmt.Sprintf("%s %s", utils.IconBook, s.manga.Name))
 
 	var errorMsg string
 	if s.err != nil {
@@ -105,28 +280,58 @@ func (s *DetailsScreen) View() string {
 	// Manga info section
 	info := s.renderMangaInfo()
 
-	// Chapters list
-	chaptersList := s.renderChaptersList()
+	// Related series
+	related := s.renderRelations()
 
 	// Progress section
 	progressView := s.progressTracker.View()
 
-	help := styles.HelpStyle.Render(
-		"↑/k ↓/j: navigate • e: generate EPUB • r: refresh • esc: back • q: quit",
-	)
+	km, global := keys.Current().Details, keys.Current().Global
+	help := styles.HelpStyle.Render(keys.HelpLine(
+		km.Up, km.Down, km.PageUp, km.PageDown, km.Home, km.End, km.Read, km.Pick, km.PickAll, km.PickRange, km.Download, km.Queue, km.PrevRelated, km.NextRelated,
+		km.AddRelated, km.OpenSource, km.EPUB, km.ToggleRead, km.Settings, km.Refresh, km.Back, global.Help, global.Quit,
+	))
+	panel := keys.Current().Panel
+	switch {
+	case s.settingsPanel != nil && s.settingsPanel.Editing():
+		help = styles.HelpStyle.Render("type the scanlation group, empty for any • " + keys.HelpLine(keys.Describe(panel.Confirm, "done")))
+	case s.settingsPanel != nil:
+		help = styles.HelpStyle.Render(keys.HelpLine(
+			keys.Describe(panel.Up, "select setting"), keys.Describe(panel.Down, "select setting"), panel.Prev, panel.Next,
+			keys.Describe(panel.Confirm, "edit group/save"), keys.Describe(panel.Close, "cancel"),
+		))
+		info += "\n" + s.settingsPanel.View()
+	case s.confirm != nil:
+		help = s.confirm.View()
+	}
 
-	content := fmt.Sprintf("%s\n\n%s%s\n%s\n%s\n%s",
-		header,
-		errorMsg,
-		info,
-		chaptersList,
-		progressView,
-		help,
-	)
+	layout := func(chaptersList string) string {
+		return fmt.Sprintf("%s\n\n%s%s\n%s%s\n%s\n%s",
+			header,
+			errorMsg,
+			info,
+			related,
+			chaptersList,
+			progressView,
+			help,
+		)
+	}
 
-	return content
+	// Chapters list, with the first pages of the selected chapter, in the
+	// lines left by the rest
+	thumbnails := s.renderThumbnails()
+	s.chapterScroller.Height = max(s.height-detailsChrome-lipgloss.Height(layout(thumbnails)), minChapterRows)
+	return layout(s.renderChaptersList() + thumbnails)
 }
 
+// detailsChrome is how many lines around the details aren't left to the
+// chapters: the root's above, the list's title and scroll status
+const detailsChrome = 2 + 4
+
+// minChapterRows is how many chapters are listed at least, however short
+// the terminal
+const minChapterRows = 5
+
 func (s *DetailsScreen) renderMangaInfo() string {
 	status := styles.StatusStyle(s.manga.Status).Render(s.manga.Status)
 	if s.manga.Status == "" {
@@ -138,46 +343,113 @@ func (s *DetailsScreen) renderMangaInfo() string {
 		desc = desc[:197] + "..."
 	}
 
-	info := lipgloss.JoinVertical(
-		lipgloss.Left,
-		styles.TextStyle.Render(desc),
-		"",
+	lines := []string{styles.TextStyle.Render(desc), ""}
+	for _, line := range s.metadataLines() {
+		lines = append(lines, styles.MutedStyle.Render(line))
+	}
+	lines = append(lines,
 		styles.MutedStyle.Render(fmt.Sprintf("Source: %s", s.manga.Source)),
-		status,
-		"",
+		styles.MutedStyle.Render(s.readingSummary()),
 	)
+	if summary := components.SummarizeMangaSettings(s.settings); summary != "" {
+		lines = append(lines, styles.MutedStyle.Render("Downloads: "+summary))
+	}
+	lines = append(lines, status, "")
+	info := lipgloss.JoinVertical(lipgloss.Left, lines...)
 
 	return styles.CardStyle.Width(s.width - 4).Render(info)
 }
 
+// metadataLines describes the authors, publication and tags of the manga,
+// leaving out what the source didn't provide
+func (s *DetailsScreen) metadataLines() []string {
+	var lines []string
+	if len(s.manga.Authors) > 0 {
+		lines = append(lines, "Author: "+strings.Join(s.manga.Authors, ", "))
+	}
+	if len(s.manga.Artists) > 0 && strings.Join(s.manga.Artists, ",") != strings.Join(s.manga.Authors, ",") {
+		lines = append(lines, "Artist: "+strings.Join(s.manga.Artists, ", "))
+	}
+
+	var published []string
+	if s.manga.Year > 0 {
+		published = append(published, fmt.Sprint(s.manga.Year))
+	}
+	if s.manga.PublicationStatus != "" {
+		published = append(published, s.manga.PublicationStatus)
+	}
+	if len(published) > 0 {
+		lines = append(lines, "Published: "+strings.Join(published, ", "))
+	}
+
+	if len(s.manga.Genres) > 0 {
+		lines = append(lines, "Genres: "+strings.Join(s.manga.Genres, ", "))
+	}
+	if len(s.manga.Tags) > len(s.manga.Genres) {
+		genres := make(map[string]bool, len(s.manga.Genres))
+		for _, genre := range s.manga.Genres {
+			genres[genre] = true
+		}
+		var tags []string
+		for _, tag := range s.manga.Tags {
+			if !genres[tag] {
+				tags = append(tags, tag)
+			}
+		}
+		lines = append(lines, "Tags: "+strings.Join(tags, ", "))
+	}
+	return lines
+}
+
+func (s *DetailsScreen) renderRelations() string {
+	if len(s.relations) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(styles.SubtitleStyle.Render("Related series:"))
+	b.WriteString("\n")
+
+	for i, rel := range s.relations {
+		name := rel.Name
+		if name == "" {
+			name = rel.RelatedID
+		}
+		kind := strings.ReplaceAll(rel.Type, "_", " ")
+		line := fmt.Sprintf("%s: %s", kind, name)
+
+		if i == s.selectedRelation {
+			line = styles.TextStyle.Bold(true).Render("▸ " + line)
+		} else {
+			line = styles.MutedStyle.Render("  " + line)
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	return b.String()
+}
+
 func (s *DetailsScreen) renderChaptersList() string {
 	if len(s.chapters) == 0 {
 		return styles.MutedStyle.Render("No chapters available")
 	}
 
 	var b strings.Builder
-	b.WriteString(styles.SubtitleStyle.Render(fmt.Sprintf("Chapters (%d total):", len(s.chapters))))
-	b.WriteString("\n\n")
-
-	// Show limited chapters (scrollable view would be better, but simplified for now)
-	start := 0
-	end := len(s.chapters)
-	if end > 10 {
-		// Show 10 chapters around selected
-		start = s.selectedChapter - 5
-		if start < 0 {
-			start = 0
-		}
-		end = start + 10
-		if end > len(s.chapters) {
-			end = len(s.chapters)
-			start = end - 10
-			if start < 0 {
-				start = 0
-			}
-		}
+	title := fmt.Sprintf("Chapters (%d total", len(s.chapters))
+	if picked := len(s.pickedChapters()); picked > 0 {
+		title += fmt.Sprintf(", %d picked", picked)
+	}
+	title += "):"
+	b.WriteString(styles.SubtitleStyle.Render(title))
+	if s.rangeStart >= 0 && s.rangeStart < len(s.chapters) {
+		b.WriteString(styles.MutedStyle.Render(fmt.Sprintf("  range from Ch. %s, press v again to pick", s.chapters[s.rangeStart].Number)))
 	}
+	b.WriteString("\n\n")
 
+	// Only the chapters in view are rendered
+	start, end := s.chapterScroller.Visible(s.selectedChapter, len(s.chapters))
 	for i := start; i < end; i++ {
 		ch := s.chapters[i]
 		chapterText := fmt.Sprintf("Ch. %s", ch.Number)
@@ -188,15 +460,19 @@ func (s *DetailsScreen) renderChaptersList() string {
 			chapterText = fmt.Sprintf("%s: %s", chapterText, ch.Title)
 		}
 
-		statusIcon := "○"
-		statusColor := styles.MutedStyle
-		if ch.Downloaded {
-			statusIcon = "●"
-			statusColor = styles.StatusCompleted
+		statusIcon, statusColor := s.chapterIcon(ch)
+		checkbox := "[ ]"
+		if s.picked[ch.ID] {
+			checkbox = "[x]"
+		}
+
+		line := fmt.Sprintf("%s %s %s", checkbox, statusIcon, chapterText)
+		if ch.Read {
+			line += " " + utils.IconCheck.String()
+		} else if ch.LastReadPage > 0 {
+			line += fmt.Sprintf(" (p. %d)", ch.LastReadPage)
 		}
 
-		line := fmt.Sprintf("%s %s", statusIcon, chapterText)
-		
 		if i == s.selectedChapter {
 			line = styles.SelectedStyle.Render(line)
 		} else {
@@ -207,23 +483,72 @@ func (s *DetailsScreen) renderChaptersList() string {
 		b.WriteString("\n")
 	}
 
-	if len(s.chapters) > 10 {
+	if status := components.ScrollStatus(start, end, len(s.chapters), "chapters"); status != "" {
 		b.WriteString("\n")
-		b.WriteString(styles.MutedStyle.Render(
-			fmt.Sprintf("Showing %d-%d of %d chapters", start+1, end, len(s.chapters)),
-		))
+		b.WriteString(styles.MutedStyle.Render(status))
 	}
 
 	return b.String()
 }
 
+// renderThumbnails shows the first pages of the selected chapter once it
+// is downloaded
+func (s *DetailsScreen) renderThumbnails() string {
+	if s.thumbnails == nil {
+		return ""
+	}
+	if s.selectedChapter >= len(s.chapters) || s.width < s.thumbnails.Width+4 {
+		return s.thumbnails.Frame(nil)
+	}
+	chapter := s.chapters[s.selectedChapter]
+	if !chapter.Downloaded {
+		return s.thumbnails.Frame(nil)
+	}
+	strip := s.thumbnails.View(chapter)
+	if strip == "" {
+		return s.thumbnails.Frame(nil) + "\n" + styles.MutedStyle.Render("Loading page thumbnails...") + "\n"
+	}
+	return s.thumbnails.Frame(chapter) + "\n" + styles.SubtitleStyle.Render(fmt.Sprintf("Ch. %s pages:", chapter.Number)) + "\n" + strip + "\n"
+}
+
+// loadThumbnails loads the page thumbnails of the selected chapter
+func (s *DetailsScreen) loadThumbnails() tea.Cmd {
+	if s.selectedChapter >= len(s.chapters) {
+		return nil
+	}
+	return s.thumbnails.Load(s.chapters[s.selectedChapter])
+}
+
 // Messages
 type detailsLoadedMsg struct {
-	manga    *data.Manga
-	chapters []*data.Chapter
+	manga       *data.Manga
+	chapters    []*data.Chapter
+	relations   []*data.Relation
+	readingLeft string
+	settings    *data.MangaSettings
+	err         error
+	relationErr error // Related series couldn't be saved, they still show
+}
+
+type settingsSavedMsg struct {
+	settings *data.MangaSettings
 	err      error
 }
 
+type relatedAddedMsg struct {
+	err error
+}
+
+type chapterQueuedMsg struct {
+	chapterIDs []string
+	start      bool // Start the queue once the chapters are added
+	err        error
+}
+
+type chapterReadMsg struct {
+	err error
+}
+
 // Commands
 func (s *DetailsScreen) loadDetails() tea.Msg {
 	manga, err := s.repo.GetManga(s.mangaID)
@@ -239,7 +564,314 @@ func (s *DetailsScreen) loadDetails() tea.Msg {
 		return detailsLoadedMsg{manga: manga, err: err}
 	}
 
-	return detailsLoadedMsg{manga: manga, chapters: chapters}
+	relations, err := s.repo.GetRelations(s.mangaID)
+	if err != nil {
+		return detailsLoadedMsg{manga: manga, chapters: chapters, err: err}
+	}
+	var relationErr error
+	if len(relations) == 0 {
+		// Entries added before relations were tracked, fetch them once
+		if provider, ok := s.sourceFor(manga).(sources.RelationProvider); ok {
+			if fetched, err := provider.GetRelations(manga); err == nil && len(fetched) > 0 {
+				if err := s.repo.SaveRelations(manga.ID, fetched); err != nil {
+					relationErr = fmt.Errorf("failed to save related series: %w", err)
+				}
+				relations = fetched
+			}
+		}
+	}
+
+	settings, err := s.repo.GetMangaSettings(s.mangaID)
+	if err != nil {
+		return detailsLoadedMsg{manga: manga, chapters: chapters, relations: relations, err: err}
+	}
+
+	return detailsLoadedMsg{manga: manga, chapters: chapters, relations: relations, readingLeft: s.readingEstimate(), settings: settings, relationErr: relationErr}
+}
+
+// Editing reports whether a text input of the screen is capturing key presses
+func (s *DetailsScreen) Editing() bool {
+	return s.settingsPanel != nil && s.settingsPanel.Editing()
+}
+
+// updateSettings handles the keys of the settings panel
+func (s *DetailsScreen) updateSettings(msg tea.KeyMsg) tea.Cmd {
+	panel, km := s.settingsPanel, keys.Current().Panel
+	if panel.Editing() {
+		if key.Matches(msg, km.Confirm, km.Close) {
+			panel.StopEditing()
+			return nil
+		}
+		return panel.Update(msg)
+	}
+
+	switch {
+	case key.Matches(msg, km.Up):
+		panel.Prev()
+	case key.Matches(msg, km.Down):
+		panel.Next()
+	case key.Matches(msg, km.Prev):
+		panel.Cycle(-1)
+	case key.Matches(msg, km.Next):
+		panel.Cycle(1)
+	case key.Matches(msg, km.Close):
+		s.settingsPanel = nil
+	case key.Matches(msg, km.Confirm):
+		if panel.OnGroup() {
+			return panel.EditGroup()
+		}
+		return s.saveSettings(panel.Settings())
+	}
+	return nil
+}
+
+// saveSettings validates and saves the download settings of the manga
+func (s *DetailsScreen) saveSettings(settings *data.MangaSettings) tea.Cmd {
+	return func() tea.Msg {
+		if err := services.ValidateMangaSettings(settings); err != nil {
+			return settingsSavedMsg{err: err}
+		}
+		if err := s.repo.SaveMangaSettings(settings); err != nil {
+			return settingsSavedMsg{err: err}
+		}
+		return settingsSavedMsg{settings: settings}
+	}
+}
+
+// readingEstimate describes the time left to read the downloaded chapters,
+// "" when unknown
+func (s *DetailsScreen) readingEstimate() string {
+	backlog, err := s.repo.GetReadingBacklog(s.mangaID)
+	if err != nil {
+		return ""
+	}
+	speed, err := services.LoadReadingSpeed(s.repo)
+	if err != nil {
+		speed = services.DefaultReadingSpeed
+	}
+	return services.ReadingSummary(backlog, speed)
+}
+
+// addRelated adds a related series to the library with its chapter metadata
+func (s *DetailsScreen) addRelated(rel *data.Relation) tea.Cmd {
+	source := s.sourceFor(s.manga)
+	return func() tea.Msg {
+		manga, err := source.GetManga(rel.RelatedID)
+		if err != nil {
+			return relatedAddedMsg{err: fmt.Errorf("failed to get %s: %w", rel.Name, err)}
+		}
+		if err := s.repo.SaveManga(manga); err != nil {
+			return relatedAddedMsg{err: err}
+		}
+
+		chapters, err := source.GetChapters(manga)
+		if err != nil {
+			return relatedAddedMsg{err: fmt.Errorf("failed to get chapters: %w", err)}
+		}
+		for _, chapter := range chapters {
+			chapter.MangaID = manga.ID
+			if err := s.repo.SaveChapter(chapter); err != nil {
+				log.Warn("failed to save chapter", "manga_id", manga.ID, "chapter", chapter.Number, "err", err)
+			}
+		}
+		return relatedAddedMsg{}
+	}
+}
+
+// sourceFor returns the source the manga was added from
+func (s *DetailsScreen) sourceFor(manga *data.Manga) sources.Source {
+	fallback, _ := sources.Get(sources.DefaultSource)
+	return sources.ForManga(manga, fallback)
+}
+
+// sourcePageURL returns the web page of the selected chapter, falling back to
+// the manga page when no chapter is selected or it has no page
+func (s *DetailsScreen) sourcePageURL() string {
+	if s.manga == nil {
+		return ""
+	}
+	source := s.sourceFor(s.manga)
+	if s.selectedChapter < len(s.chapters) {
+		if url := sources.ChapterURL(source, s.manga, s.chapters[s.selectedChapter]); url != "" {
+			return url
+		}
+	}
+	return sources.MangaURL(source, s.manga)
+}
+
+// readingSummary describes how far the manga has been read
+func (s *DetailsScreen) readingSummary() string {
+	read := 0
+	var last *data.Chapter
+	for _, ch := range s.chapters {
+		if ch.Read {
+			read++
+		}
+		if !ch.ReadAt.IsZero() && (last == nil || ch.ReadAt.After(last.ReadAt)) {
+			last = ch
+		}
+	}
+
+	summary := fmt.Sprintf("Read: %d/%d chapters", read, len(s.chapters))
+	if last != nil {
+		summary += fmt.Sprintf(" • Last read: Ch. %s on %s", last.Number, last.ReadAt.Format("2006-01-02"))
+	}
+	if s.readingLeft != "" {
+		summary += " • " + s.readingLeft
+	}
+	return summary
+}
+
+// readChapter opens a downloaded chapter in the reader
+func (s *DetailsScreen) readChapter(chapter *data.Chapter) tea.Cmd {
+	if !chapter.Downloaded || chapter.FilePath == "" {
+		return reportError("details", fmt.Errorf("chapter %s is not downloaded, d downloads it", chapter.Number), components.SeverityWarning)
+	}
+	mangaID := s.mangaID
+	return func() tea.Msg {
+		return SwitchScreenMsg{Screen: "reader", Data: readerTarget{mangaID: mangaID, chapterID: chapter.ID}}
+	}
+}
+
+func (s *DetailsScreen) toggleRead(chapter *data.Chapter) tea.Cmd {
+	return func() tea.Msg {
+		return chapterReadMsg{err: s.repo.MarkChapterRead(chapter.ID, !chapter.Read)}
+	}
+}
+
+// chapterIcon returns the icon and style of a chapter, from its live download
+// status when it is being downloaded in this session
+func (s *DetailsScreen) chapterIcon(ch *data.Chapter) (string, lipgloss.Style) {
+	switch s.chapterStatus[ch.ID] {
+	case "queued":
+		return utils.IconWait.String(), styles.StatusWarning
+	case "downloading":
+		return utils.IconDownload.String(), styles.StatusDownloading
+	case "processing":
+		return utils.IconSync.String(), styles.StatusDownloading
+	case "error":
+		return utils.IconCross.String(), styles.StatusError
+	}
+	if ch.Downloaded {
+		return "●", styles.StatusCompleted
+	}
+	return "○", styles.MutedStyle
+}
+
+// selectChapter selects a chapter of the list, the first or last one past
+// its ends, and shows its pages
+func (s *DetailsScreen) selectChapter(i int) tea.Cmd {
+	if len(s.chapters) == 0 {
+		return nil
+	}
+	s.selectedChapter = max(0, min(i, len(s.chapters)-1))
+	return s.loadThumbnails()
+}
+
+// trackChapter records the live status of a chapter of the manga
+func (s *DetailsScreen) trackChapter(progress services.DownloadProgress) {
+	if progress.MangaID != s.mangaID || progress.ChapterID == "" {
+		return
+	}
+	s.chapterStatus[progress.ChapterID] = progress.Status
+	if progress.Status != "complete" {
+		return
+	}
+	delete(s.chapterStatus, progress.ChapterID)
+	for _, ch := range s.chapters {
+		if ch.ID == progress.ChapterID {
+			ch.Downloaded = true
+		}
+	}
+}
+
+func (s *DetailsScreen) togglePicked(i int) {
+	id := s.chapters[i].ID
+	if s.picked[id] {
+		delete(s.picked, id)
+	} else {
+		s.picked[id] = true
+	}
+}
+
+// pickAll picks every chapter, or unpicks them all when they already are
+func (s *DetailsScreen) pickAll() {
+	if len(s.chapters) > 0 && len(s.pickedChapters()) == len(s.chapters) {
+		s.picked = make(map[string]bool)
+		return
+	}
+	for _, ch := range s.chapters {
+		s.picked[ch.ID] = true
+	}
+}
+
+// pickRange picks the chapters between two positions of the list, both
+// included, in any order
+func (s *DetailsScreen) pickRange(from, to int) {
+	if from > to {
+		from, to = to, from
+	}
+	for i := max(from, 0); i <= to && i < len(s.chapters); i++ {
+		s.picked[s.chapters[i].ID] = true
+	}
+}
+
+// pickedChapters returns the picked chapters in list order
+func (s *DetailsScreen) pickedChapters() []*data.Chapter {
+	var picked []*data.Chapter
+	for _, ch := range s.chapters {
+		if s.picked[ch.ID] {
+			picked = append(picked, ch)
+		}
+	}
+	return picked
+}
+
+// confirmQueueAbove is how many picked chapters it takes for a download or
+// queueing to be confirmed first
+const confirmQueueAbove = 20
+
+// confirmQueue queues the picked chapters like queueChapters, asking first
+// when there are more than confirmQueueAbove of them
+func (s *DetailsScreen) confirmQueue(start bool) tea.Cmd {
+	picked := len(s.pickedChapters())
+	if s.manga == nil || picked <= confirmQueueAbove {
+		return s.queueChapters(start)
+	}
+	prompt := fmt.Sprintf("Queue %d chapters of %s?", picked, s.manga.Name)
+	if start {
+		prompt = fmt.Sprintf("Download %d chapters of %s?", picked, s.manga.Name)
+	}
+	s.confirm = components.NewConfirm(prompt, s.queueChapters(start))
+	return nil
+}
+
+// queueChapters adds the picked chapters, or the selected one when none is
+// picked, to the download queue, and starts it when start is set
+func (s *DetailsScreen) queueChapters(start bool) tea.Cmd {
+	chapters := s.pickedChapters()
+	if len(chapters) == 0 && s.selectedChapter < len(s.chapters) {
+		chapters = []*data.Chapter{s.chapters[s.selectedChapter]}
+	}
+	if s.manga == nil || len(chapters) == 0 {
+		return nil
+	}
+	manga := s.manga
+	return func() tea.Msg {
+		if err := s.queue.Add(manga, chapters...); err != nil {
+			return chapterQueuedMsg{err: err}
+		}
+		ids := make([]string, len(chapters))
+		for i, ch := range chapters {
+			ids[i] = ch.ID
+		}
+		return chapterQueuedMsg{chapterIDs: ids, start: start}
+	}
+}
+
+// runQueue downloads the queue in the background
+func (s *DetailsScreen) runQueue() tea.Msg {
+	return queueDoneMsg{err: s.queue.Run(context.Background())}
 }
 
 func (s *DetailsScreen) generateEPUB() tea.Cmd {
@@ -255,5 +887,19 @@ func (s *DetailsScreen) generateEPUB() tea.Cmd {
 }
 
 func (s *DetailsScreen) listenForProgress() tea.Msg {
-	return <-s.downloader.GetProgressChannel()
+	if s.progress == nil {
+		return nil
+	}
+	progress, ok := <-s.progress
+	if !ok {
+		return nil
+	}
+	return progress
+}
+
+// Close stops following the downloads, once the screen is left for good
+func (s *DetailsScreen) Close() {
+	if s.unsubscribe != nil {
+		s.unsubscribe()
+	}
 }
@@ -0,0 +1,179 @@
+package screens
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+func newPickerScreen(n int) *DetailsScreen {
+	s := NewDetailsScreen(nil, nil, nil, nil, "m1")
+	for i := 0; i < n; i++ {
+		s.chapters = append(s.chapters, &data.Chapter{ID: string(rune('a' + i)), MangaID: "m1"})
+	}
+	return s
+}
+
+func press(s *DetailsScreen, keys ...string) {
+	for _, key := range keys {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+		if key == " " {
+			msg = tea.KeyMsg{Type: tea.KeySpace}
+		}
+		s.Update(msg)
+	}
+}
+
+func pickedIDs(s *DetailsScreen) string {
+	var ids string
+	for _, ch := range s.pickedChapters() {
+		ids += ch.ID
+	}
+	return ids
+}
+
+func TestDetailsScreen_PickChapters(t *testing.T) {
+	s := newPickerScreen(5)
+
+	press(s, " ", "j", "j", " ")
+	if got := pickedIDs(s); got != "ac" {
+		t.Errorf("After toggling: picked %q, want %q", got, "ac")
+	}
+	press(s, " ")
+	if got := pickedIDs(s); got != "a" {
+		t.Errorf("After untoggling: picked %q, want %q", got, "a")
+	}
+
+	// Ranges work in both directions
+	press(s, "j", "j", "v", "k", "k", "v")
+	if got := pickedIDs(s); got != "acde" {
+		t.Errorf("After range: picked %q, want %q", got, "acde")
+	}
+	if s.rangeStart != -1 {
+		t.Errorf("Expected the range to end, start = %d", s.rangeStart)
+	}
+
+	press(s, "a")
+	if got := pickedIDs(s); got != "abcde" {
+		t.Errorf("After pick all: picked %q, want %q", got, "abcde")
+	}
+	press(s, "a")
+	if got := pickedIDs(s); got != "" {
+		t.Errorf("Pick all again should unpick all, picked %q", got)
+	}
+}
+
+func TestDetailsScreen_ChapterStatusFromProgress(t *testing.T) {
+	s := newPickerScreen(2)
+
+	s.trackChapter(services.DownloadProgress{MangaID: "m1", ChapterID: "a", Status: "downloading"})
+	s.trackChapter(services.DownloadProgress{MangaID: "other", ChapterID: "b", Status: "error"})
+	if icon, _ := s.chapterIcon(s.chapters[0]); icon != utils.IconDownload.String() {
+		t.Errorf("Downloading chapter icon = %q", icon)
+	}
+	if icon, _ := s.chapterIcon(s.chapters[1]); icon != "○" {
+		t.Errorf("Progress of another manga changed the icon to %q", icon)
+	}
+
+	s.trackChapter(services.DownloadProgress{MangaID: "m1", ChapterID: "a", Status: "complete"})
+	if icon, _ := s.chapterIcon(s.chapters[0]); icon != "●" || !s.chapters[0].Downloaded {
+		t.Errorf("Completed chapter icon = %q, downloaded = %v", icon, s.chapters[0].Downloaded)
+	}
+}
+
+func TestDetailsScreen_SettingsPanel(t *testing.T) {
+	s := newPickerScreen(2)
+	s.settings = &data.MangaSettings{MangaID: "m1", Format: "cbz"}
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+
+	press(s, "s")
+	if s.settingsPanel == nil {
+		t.Fatal("Expected s to open the settings panel")
+	}
+	press(s, "l", "j")
+	s.Update(enter)
+	if !s.Editing() {
+		t.Fatal("Expected enter on the group to start typing it")
+	}
+	press(s, "q", "s") // Typed in, not handled as keys
+	s.Update(enter)
+	if s.Editing() || s.settingsPanel == nil {
+		t.Fatal("Expected enter to stop typing the group, keeping the panel open")
+	}
+	press(s, "j", "l") // Format wraps around from cbz to the default
+
+	want := data.MangaSettings{MangaID: "m1", Language: "en", Group: "qs"}
+	if got := s.settingsPanel.Settings(); *got != want {
+		t.Errorf("Settings() = %+v, want %+v", got, want)
+	}
+
+	s.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if s.settingsPanel != nil || s.settings.Format != "cbz" {
+		t.Errorf("Expected esc to close the panel without saving, settings = %+v", s.settings)
+	}
+}
+
+func TestDetailsScreen_ConfirmBulkDownload(t *testing.T) {
+	s := newPickerScreen(confirmQueueAbove + 1)
+	s.manga = &data.Manga{ID: "m1", Name: "Berserk"}
+
+	press(s, "a", "d")
+	if s.confirm == nil {
+		t.Fatal("downloading many chapters should ask first")
+	}
+	if !strings.Contains(s.confirm.Prompt, "Download 21 chapters of Berserk?") {
+		t.Errorf("prompt = %q", s.confirm.Prompt)
+	}
+	// Keys answer the dialog instead of moving through the list
+	press(s, "j", "n")
+	if s.confirm != nil {
+		t.Error("n should close the dialog")
+	}
+	if s.selectedChapter != 0 || len(s.pickedChapters()) != confirmQueueAbove+1 {
+		t.Error("cancelling should leave the selection and picks alone")
+	}
+
+	press(s, "a", " ", "Q")
+	if s.confirm != nil {
+		t.Error("queueing a few chapters should not ask")
+	}
+}
+
+func TestDetailsScreen_ScrollsLongChapterLists(t *testing.T) {
+	s := NewDetailsScreen(nil, nil, nil, nil, "m1")
+	s.manga = &data.Manga{ID: "m1", Name: "One Piece"}
+	for i := 1; i <= 1000; i++ {
+		s.chapters = append(s.chapters, &data.Chapter{ID: fmt.Sprint(i), MangaID: "m1", Number: fmt.Sprint(i)})
+	}
+	s.Update(tea.WindowSizeMsg{Width: 120, Height: 50})
+
+	view := s.View()
+	if !strings.Contains(view, "Showing 1-") {
+		t.Errorf("expected the first chapters:\n%s", view)
+	}
+	if strings.Contains(view, "Ch. 500") {
+		t.Error("chapters out of view should not be rendered")
+	}
+	if lipgloss.Height(view) > 50 {
+		t.Errorf("view is %d lines, over the terminal's 50", lipgloss.Height(view))
+	}
+
+	s.Update(tea.KeyMsg{Type: tea.KeyEnd})
+	if s.selectedChapter != 999 || !strings.Contains(s.View(), "of 1000 chapters") || !strings.Contains(s.View(), "Ch. 1000") {
+		t.Errorf("end: selected %d\n%s", s.selectedChapter, s.View())
+	}
+	s.Update(tea.KeyMsg{Type: tea.KeyPgUp})
+	if want := 999 - s.chapterScroller.Page(); s.selectedChapter != want {
+		t.Errorf("page up: selected %d, want %d", s.selectedChapter, want)
+	}
+	press(s, "g")
+	if s.selectedChapter != 0 {
+		t.Errorf("g: selected %d, want 0", s.selectedChapter)
+	}
+}
@@ -0,0 +1,24 @@
+package screens
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kerbaras/mangas/pkg/app/components"
+)
+
+// ErrorMsg reports an error to the error center
+type ErrorMsg struct {
+	Screen   string
+	Err      error
+	Severity components.Severity
+}
+
+// reportError returns a command sending err to the error center, or nil
+// when there is no error
+func reportError(screen string, err error, severity components.Severity) tea.Cmd {
+	if err == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		return ErrorMsg{Screen: screen, Err: err, Severity: severity}
+	}
+}
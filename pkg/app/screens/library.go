@@ -2,33 +2,57 @@ package screens
 
 import (
 	"fmt"
+	"slices"
 
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/kerbaras/mangas/pkg/app/components"
+	"github.com/kerbaras/mangas/pkg/app/keys"
 	"github.com/kerbaras/mangas/pkg/app/styles"
 	"github.com/kerbaras/mangas/pkg/data"
 	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/sources"
+	"github.com/kerbaras/mangas/pkg/utils"
 )
 
 type LibraryScreen struct {
 	repo         *data.Repository
 	downloader   *services.Downloader
 	mangaList    *components.MangaList
+	filter       textinput.Model     // Live search over the library, active while focused
+	sort         data.MangaSort      // Order of the entries, cycled with s
+	confirm      *components.Confirm // Deletion waiting for confirmation, nil when none
 	width        int
 	height       int
 	err          error
 }
 
-func NewLibraryScreen(repo *data.Repository, downloader *services.Downloader) *LibraryScreen {
+func NewLibraryScreen(repo *data.Repository, downloader *services.Downloader, covers *components.CoverRenderer) *LibraryScreen {
+	ti := textinput.New()
+	ti.Prompt = "/ "
+	ti.Placeholder = "Filter by name, description or tag..."
+	ti.CharLimit = 100
+	ti.Width = 50
+
+	mangaList := components.NewMangaList()
+	mangaList.Covers = covers
+
 	return &LibraryScreen{
 		repo:       repo,
 		downloader: downloader,
-		mangaList:  components.NewMangaList(),
+		mangaList:  mangaList,
+		filter:     ti,
 	}
 }
 
+// Filtering reports whether the filter input is capturing key presses
+func (s *LibraryScreen) Filtering() bool {
+	return s.filter.Focused()
+}
+
 func (s *LibraryScreen) Init() tea.Cmd {
-	return s.loadLibrary
+	return s.loadLibrary()
 }
 
 func (s *LibraryScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -38,28 +62,84 @@ func (s *LibraryScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		s.height = msg.Height
 		s.mangaList.Width = msg.Width - 4
 		s.mangaList.Height = msg.Height - 10
+		return s, s.mangaList.LoadCovers()
 		
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "up", "k":
+		if s.filter.Focused() {
+			return s.updateFilter(msg)
+		}
+		if s.confirm != nil {
+			done, cmd := s.confirm.Update(msg)
+			if done {
+				s.confirm = nil
+			}
+			return s, cmd
+		}
+
+		km := keys.Current().Library
+		switch {
+		case key.Matches(msg, km.Filter):
+			s.filter.Focus()
+			return s, textinput.Blink
+		case key.Matches(msg, km.ClearFilter):
+			if s.filter.Value() != "" {
+				s.filter.SetValue("")
+				return s, s.loadLibrary()
+			}
+		case key.Matches(msg, km.Up):
+			s.mangaList.Up()
+			return s, s.mangaList.LoadCovers()
+		case key.Matches(msg, km.Down):
+			s.mangaList.Down()
+			return s, s.mangaList.LoadCovers()
+		case key.Matches(msg, km.PageUp):
+			s.mangaList.PageUp()
+			return s, s.mangaList.LoadCovers()
+		case key.Matches(msg, km.PageDown):
+			s.mangaList.PageDown()
+			return s, s.mangaList.LoadCovers()
+		case key.Matches(msg, km.Home):
+			s.mangaList.Home()
+			return s, s.mangaList.LoadCovers()
+		case key.Matches(msg, km.End):
+			s.mangaList.End()
+			return s, s.mangaList.LoadCovers()
+		case key.Matches(msg, km.Left):
 			s.mangaList.Prev()
-		case "down", "j":
+			return s, s.mangaList.LoadCovers()
+		case key.Matches(msg, km.Right):
 			s.mangaList.Next()
-		case "r":
-			return s, s.loadLibrary
-		case "d":
-			// Delete selected manga
+			return s, s.mangaList.LoadCovers()
+		case key.Matches(msg, km.Layout):
+			// Switch between the list and the grid of covers
+			s.mangaList.Grid = !s.mangaList.Grid
+			return s, s.mangaList.LoadCovers()
+		case key.Matches(msg, km.Refresh):
+			return s, s.loadLibrary()
+		case key.Matches(msg, km.Sort):
+			s.sort = nextSort(s.sort)
+			return s, s.loadLibrary()
+		case key.Matches(msg, km.Delete):
+			// Delete selected manga, once confirmed
 			selected := s.mangaList.Selected()
 			if selected != nil {
-				return s, s.deleteManga(selected.Manga.ID)
+				s.confirm = components.NewConfirm(deletePrompt(selected), s.deleteManga(selected.Manga))
 			}
-		case "e":
+		case key.Matches(msg, km.EPUB):
 			// Generate EPUB for selected manga
 			selected := s.mangaList.Selected()
 			if selected != nil {
 				return s, s.generateEPUB(selected.Manga.ID)
 			}
-		case "enter":
+		case key.Matches(msg, km.OpenSource):
+			// Open selected manga on the source website
+			selected := s.mangaList.Selected()
+			if selected != nil {
+				fallback, _ := sources.Get(sources.DefaultSource)
+				manga := selected.Manga
+				return s, openSource(sources.MangaURL(sources.ForManga(manga, fallback), manga))
+			}
+		case key.Matches(msg, km.Open):
 			// Return selected manga to switch to details view
 			selected := s.mangaList.Selected()
 			if selected != nil {
@@ -70,20 +150,33 @@ func (s *LibraryScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		
 	case libraryLoadedMsg:
+		if msg.query != s.filter.Value() || msg.sort != s.sort {
+			break // Results of an outdated filter or sort
+		}
 		s.mangaList.SetItems(msg.items)
 		s.err = msg.err
+		// Covers are loaded as their cards come into view
+		return s, tea.Batch(reportError("library", msg.err, components.SeverityFatal), s.mangaList.LoadCovers())
+
+	case components.CoverLoadedMsg:
+		// Drawn with the next view
 		
 	case epubGeneratedMsg:
 		if msg.err != nil {
 			s.err = msg.err
 		}
-		return s, s.loadLibrary
+		return s, tea.Batch(s.loadLibrary(), reportError("library", msg.err, components.SeverityWarning))
 		
 	case mangaDeletedMsg:
 		if msg.err != nil {
 			s.err = msg.err
+			return s, tea.Batch(s.loadLibrary(), reportError("library", msg.err, components.SeverityWarning))
 		}
-		return s, s.loadLibrary
+		return s, tea.Batch(s.loadLibrary(), showToast("Removed %s from the library", msg.name))
+
+	case sourceOpenedMsg:
+		s.err = msg.err
+		return s, reportError("library", msg.err, components.SeverityWarning)
 	}
 	
 	return s, nil
@@ -94,7 +187,10 @@ func (s *LibraryScreen) View() string {
 		return "Loading..."
 	}
 
-	header := styles.TitleStyle.Render("📚 Manga Library")
+	header := styles.TitleStyle.Render(utils.IconLibrary.String() + " Manga Library")
+	if s.sort != data.SortDefault {
+		header += styles.HelpStyle.Render("  sorted by " + string(s.sort))
+	}
 	
 	var errorMsg string
 	if s.err != nil {
@@ -102,19 +198,37 @@ func (s *LibraryScreen) View() string {
 		errorMsg += "\n\n"
 	}
 	
+	var filter string
+	if s.filter.Focused() || s.filter.Value() != "" {
+		filter = s.filter.View() + "\n\n"
+	}
+
 	listView := s.mangaList.View()
 	
-	help := styles.HelpStyle.Render(
-		"↑/k: up • ↓/j: down • enter: details • e: generate EPUB • d: delete • r: refresh • tab: switch view • q: quit",
-	)
+	km, global := keys.Current().Library, keys.Current().Global
+	help := styles.HelpStyle.Render(keys.HelpLine(
+		km.Up, km.Down, km.PageUp, km.PageDown, km.Home, km.End, km.Open, km.Filter, km.Sort, km.Layout, km.OpenSource, km.EPUB, km.Delete, km.Refresh,
+		global.NextView, global.Help, global.Quit,
+	))
+	if s.confirm != nil {
+		help = s.confirm.View()
+	} else if s.filter.Focused() {
+		help = styles.HelpStyle.Render("type to filter • enter: done • esc: clear filter")
+	} else if s.filter.Value() != "" {
+		help = styles.HelpStyle.Render(keys.HelpLine(
+			km.Up, km.Down, km.Open, km.Filter, km.ClearFilter, km.Sort, global.NextView, global.Help, global.Quit,
+		))
+	}
 	
-	content := fmt.Sprintf("%s\n\n%s%s\n%s", header, errorMsg, listView, help)
+	content := fmt.Sprintf("%s\n\n%s%s%s\n%s", header, errorMsg, filter, listView, help)
 	
 	return content
 }
 
 // Messages
 type libraryLoadedMsg struct {
+	query string
+	sort  data.MangaSort
 	items []components.MangaListItem
 	err   error
 }
@@ -125,15 +239,53 @@ type epubGeneratedMsg struct {
 }
 
 type mangaDeletedMsg struct {
+	name string // Manga deleted
+	err  error
+}
+
+type sourceOpenedMsg struct {
 	err error
 }
 
+// updateFilter handles key presses while the filter input is focused,
+// reloading the library as the query changes
+func (s *LibraryScreen) updateFilter(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		s.filter.Blur()
+		return s, nil
+	case "esc":
+		s.filter.Blur()
+		s.filter.SetValue("")
+		return s, s.loadLibrary()
+	}
+
+	query := s.filter.Value()
+	var cmd tea.Cmd
+	s.filter, cmd = s.filter.Update(msg)
+	if s.filter.Value() != query {
+		return s, tea.Batch(cmd, s.loadLibrary())
+	}
+	return s, cmd
+}
+
 // Commands
-func (s *LibraryScreen) loadLibrary() tea.Msg {
-	mangas, err := s.repo.ListMangas()
+
+// loadLibrary loads the library entries matching the current filter, in
+// the current order
+func (s *LibraryScreen) loadLibrary() tea.Cmd {
+	query, sort := s.filter.Value(), s.sort
+	return func() tea.Msg {
+		return s.searchLibrary(query, sort)
+	}
+}
+
+func (s *LibraryScreen) searchLibrary(query string, sort data.MangaSort) tea.Msg {
+	mangas, err := s.repo.SearchLibrary(query)
 	if err != nil {
-		return libraryLoadedMsg{err: err}
+		return libraryLoadedMsg{query: query, sort: sort, err: err}
 	}
+	data.SortMangas(mangas, sort)
 	
 	items := make([]components.MangaListItem, len(mangas))
 	for i, manga := range mangas {
@@ -145,7 +297,13 @@ func (s *LibraryScreen) loadLibrary() tea.Msg {
 		}
 	}
 	
-	return libraryLoadedMsg{items: items}
+	return libraryLoadedMsg{query: query, sort: sort, items: items}
+}
+
+// nextSort returns the order following sort in data.MangaSorts
+func nextSort(sort data.MangaSort) data.MangaSort {
+	i := slices.Index(data.MangaSorts, sort)
+	return data.MangaSorts[(i+1)%len(data.MangaSorts)]
 }
 
 func (s *LibraryScreen) generateEPUB(mangaID string) tea.Cmd {
@@ -159,9 +317,26 @@ func (s *LibraryScreen) generateEPUB(mangaID string) tea.Cmd {
 	}
 }
 
-func (s *LibraryScreen) deleteManga(mangaID string) tea.Cmd {
+// openSource opens a source page in the browser
+func openSource(url string) tea.Cmd {
+	return func() tea.Msg {
+		return sourceOpenedMsg{err: utils.OpenBrowser(url)}
+	}
+}
+
+// deletePrompt asks to confirm the deletion of a library entry, telling
+// what goes with it
+func deletePrompt(item *components.MangaListItem) string {
+	prompt := fmt.Sprintf("Remove %s and its %d chapters from the library?", item.Manga.Name, item.ChapterCount)
+	if item.DownloadedCount > 0 {
+		prompt += fmt.Sprintf("\nThe %d downloaded books stay on disk.", item.DownloadedCount)
+	}
+	return prompt
+}
+
+func (s *LibraryScreen) deleteManga(manga *data.Manga) tea.Cmd {
 	return func() tea.Msg {
-		err := s.repo.DeleteManga(mangaID)
-		return mangaDeletedMsg{err: err}
+		err := s.repo.DeleteManga(manga.ID)
+		return mangaDeletedMsg{name: manga.Name, err: err}
 	}
 }
@@ -0,0 +1,201 @@
+package screens
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kerbaras/mangas/pkg/app/components"
+	"github.com/kerbaras/mangas/pkg/app/keys"
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// paletteMode is what the command palette lists
+type paletteMode int
+
+const (
+	paletteCommands paletteMode = iota // Actions and library mangas
+	paletteDownload                    // Library mangas to download the missing chapters of
+	paletteChapters                    // Downloaded chapters to open
+)
+
+// paletteAction runs a palette entry once it is picked
+type paletteAction func() tea.Cmd
+
+// paletteItemsMsg carries the entries of the palette loaded from the library
+type paletteItemsMsg struct {
+	mode  paletteMode
+	items []components.PaletteItem
+	err   error
+}
+
+// openPalette shows the palette in mode, loading its library entries
+func (r *RootScreen) openPalette(mode paletteMode) tea.Cmd {
+	r.showPalette = true
+	r.paletteMode = mode
+
+	var title string
+	var items []components.PaletteItem
+	switch mode {
+	case paletteDownload:
+		title = utils.IconDownload.String() + " Download missing chapters of..."
+	case paletteChapters:
+		title = utils.IconBook.String() + " Open chapter..."
+	default:
+		title = utils.IconPalette.String() + " Command Palette"
+		items = r.paletteCommands()
+	}
+	return tea.Batch(r.palette.Open(title, items), r.loadPaletteItems(mode))
+}
+
+// updatePalette handles key presses while the palette is displayed
+func (r *RootScreen) updatePalette(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m := keys.Current()
+	switch {
+	case msg.String() == "ctrl+c":
+		return r, tea.Quit
+	case key.Matches(msg, m.Panel.Close, m.Global.Palette):
+		r.showPalette = false
+		return r, nil
+	case key.Matches(msg, m.Panel.Confirm):
+		item, ok := r.palette.Selected()
+		if !ok {
+			return r, nil
+		}
+		r.showPalette = false
+		return r, item.Value.(paletteAction)()
+	}
+	return r, r.palette.Update(msg)
+}
+
+// paletteCommands lists the actions of the palette
+func (r *RootScreen) paletteCommands() []components.PaletteItem {
+	command := func(title, hint string, action paletteAction) components.PaletteItem {
+		return components.PaletteItem{Title: title, Hint: hint, Value: action}
+	}
+	goTo := func(screen string) paletteAction {
+		return func() tea.Cmd { return switchScreen(screen) }
+	}
+
+	return []components.PaletteItem{
+		command("Download...", "queue the missing chapters of a manga", func() tea.Cmd {
+			return r.openPalette(paletteDownload)
+		}),
+		command("Add manga...", "search the source", goTo("search")),
+		command("Open chapter...", "read a downloaded chapter", func() tea.Cmd {
+			return r.openPalette(paletteChapters)
+		}),
+		command("Go to Home", "view", goTo("home")),
+		command("Go to Library", "view", goTo("library")),
+		command("Go to Search", "view", goTo("search")),
+		command("Go to Queue", "view", goTo("queue")),
+		command("Go to Stats", "view", goTo("stats")),
+		command("Start downloading the queue", "queue", func() tea.Cmd {
+			return switchScreenWith("queue", queueStart)
+		}),
+		command("Show errors", "error center", func() tea.Cmd {
+			r.showErrors = true
+			r.errors.MarkRead()
+			return nil
+		}),
+		command("Quit", "", func() tea.Cmd { return tea.Quit }),
+	}
+}
+
+// loadPaletteItems lists the library entries of the palette in mode
+func (r *RootScreen) loadPaletteItems(mode paletteMode) tea.Cmd {
+	return func() tea.Msg {
+		mangas, err := r.repo.ListMangas()
+		if err != nil {
+			return paletteItemsMsg{mode: mode, err: err}
+		}
+
+		var items []components.PaletteItem
+		for _, manga := range mangas {
+			manga := manga
+			switch mode {
+			case paletteCommands:
+				items = append(items, components.PaletteItem{Title: manga.Name, Hint: "library", Value: paletteAction(func() tea.Cmd {
+					return switchScreenWith("details", manga.ID)
+				})})
+			case paletteDownload:
+				items = append(items, components.PaletteItem{Title: manga.Name, Value: paletteAction(func() tea.Cmd {
+					return r.downloadMissing(manga)
+				})})
+			case paletteChapters:
+				chapters, err := r.repo.GetChapters(manga.ID)
+				if err != nil {
+					return paletteItemsMsg{mode: mode, err: err}
+				}
+				for _, chapter := range chapters {
+					if !chapter.Downloaded || chapter.FilePath == "" {
+						continue
+					}
+					chapter := chapter
+					items = append(items, components.PaletteItem{
+						Title: fmt.Sprintf("%s - Ch. %s", manga.Name, chapter.Number),
+						Hint:  chapter.Title,
+						Value: paletteAction(func() tea.Cmd { return r.openChapter(manga, chapter) }),
+					})
+				}
+			}
+		}
+		return paletteItemsMsg{mode: mode, items: items}
+	}
+}
+
+// downloadMissing queues the chapters of manga not downloaded yet and starts
+// the queue
+func (r *RootScreen) downloadMissing(manga *data.Manga) tea.Cmd {
+	return func() tea.Msg {
+		chapters, err := r.repo.GetChapters(manga.ID)
+		if err != nil {
+			return ErrorMsg{Screen: "palette", Err: err, Severity: components.SeverityWarning}
+		}
+		var missing []*data.Chapter
+		for _, chapter := range chapters {
+			if !chapter.Downloaded {
+				missing = append(missing, chapter)
+			}
+		}
+		if len(missing) == 0 {
+			err := fmt.Errorf("every chapter of %s is downloaded", manga.Name)
+			return ErrorMsg{Screen: "palette", Err: err, Severity: components.SeverityWarning}
+		}
+		if err := r.queue.Add(manga, missing...); err != nil {
+			return ErrorMsg{Screen: "palette", Err: err, Severity: components.SeverityWarning}
+		}
+		return SwitchScreenMsg{Screen: "queue", Data: queueStart}
+	}
+}
+
+// prefetchedMsg reports chapters queued ahead of the one being read
+type prefetchedMsg struct {
+	queued int
+}
+
+// openChapter opens a downloaded chapter in the default reader. When
+// prefetching is on, the chapters after it are queued and downloaded in the
+// background meanwhile.
+func (r *RootScreen) openChapter(manga *data.Manga, chapter *data.Chapter) tea.Cmd {
+	return func() tea.Msg {
+		if err := utils.OpenFile(chapter.FilePath); err != nil {
+			return ErrorMsg{Screen: "palette", Err: err, Severity: components.SeverityWarning}
+		}
+
+		count, err := services.LoadPrefetch(r.repo)
+		if err == nil {
+			var queued []*data.Chapter
+			if queued, err = r.queue.Prefetch(manga, chapter, count); err == nil {
+				return prefetchedMsg{queued: len(queued)}
+			}
+		}
+		err = fmt.Errorf("failed to prefetch the next chapters: %w", err)
+		return ErrorMsg{Screen: "palette", Err: err, Severity: components.SeverityWarning}
+	}
+}
+
+// queueStart is passed when switching to the queue to start downloading it
+const queueStart = "start"
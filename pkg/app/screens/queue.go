@@ -0,0 +1,257 @@
+package screens
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kerbaras/mangas/pkg/app/components"
+	"github.com/kerbaras/mangas/pkg/app/keys"
+	"github.com/kerbaras/mangas/pkg/app/styles"
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+type QueueScreen struct {
+	queue    *services.DownloadQueue
+	entries  []*services.QueueEntry
+	selected int
+	width    int
+	height   int
+	err      error
+}
+
+func NewQueueScreen(queue *services.DownloadQueue) *QueueScreen {
+	return &QueueScreen{
+		queue: queue,
+	}
+}
+
+func (s *QueueScreen) Init() tea.Cmd {
+	if s.queue.Running() {
+		return tea.Batch(s.loadQueue, s.tick())
+	}
+	return s.loadQueue
+}
+
+func (s *QueueScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		s.width = msg.Width
+		s.height = msg.Height
+
+	case tea.KeyMsg:
+		km := keys.Current().Queue
+		switch {
+		case key.Matches(msg, km.Up):
+			if s.selected > 0 {
+				s.selected--
+			}
+		case key.Matches(msg, km.Down):
+			if s.selected < len(s.entries)-1 {
+				s.selected++
+			}
+		case key.Matches(msg, km.MoveUp):
+			// Move the selected chapter up
+			return s, s.moveSelected(s.selected - 1)
+		case key.Matches(msg, km.MoveDown):
+			// Move the selected chapter down
+			return s, s.moveSelected(s.selected + 1)
+		case key.Matches(msg, km.Top):
+			// Move the selected chapter to the top, it downloads next
+			return s, s.moveSelected(0)
+		case key.Matches(msg, km.Bottom):
+			// Move the selected chapter to the bottom
+			return s, s.moveSelected(len(s.entries) - 1)
+		case key.Matches(msg, km.Start):
+			return s, s.Start()
+		case key.Matches(msg, km.Stop):
+			s.queue.Stop()
+		case key.Matches(msg, km.Pause):
+			return s, s.update(func() error {
+				_, err := s.queue.Pause("")
+				return err
+			})
+		case key.Matches(msg, km.Resume):
+			return s, s.update(func() error {
+				_, err := s.queue.Resume("")
+				return err
+			})
+		case key.Matches(msg, km.Clear):
+			return s, s.update(func() error {
+				_, err := s.queue.Clear(data.QueueDone)
+				return err
+			})
+		case key.Matches(msg, km.Refresh):
+			return s, s.loadQueue
+		}
+
+	case queueLoadedMsg:
+		s.entries = msg.entries
+		s.err = msg.err
+		if s.selected >= len(s.entries) {
+			s.selected = len(s.entries) - 1
+		}
+		if s.selected < 0 {
+			s.selected = 0
+		}
+		return s, reportError("queue", msg.err, components.SeverityWarning)
+
+	case queueTickMsg:
+		if s.queue.Running() {
+			return s, tea.Batch(s.loadQueue, s.tick())
+		}
+		return s, s.loadQueue
+
+	case queueDoneMsg:
+		s.err = msg.err
+		return s, tea.Batch(s.loadQueue, reportError("queue", msg.err, components.SeverityWarning))
+	}
+
+	return s, nil
+}
+
+func (s *QueueScreen) View() string {
+	if s.width == 0 {
+		return "Loading..."
+	}
+
+	header := styles.TitleStyle.Render(utils.IconDownload.String() + " Download Queue")
+	if s.queue.Running() {
+		header += " " + styles.StatusDownloading.Render("downloading...")
+	}
+
+	var errorMsg string
+	if s.err != nil {
+		errorMsg = styles.StatusError.Render(fmt.Sprintf("Error: %s", s.err))
+		errorMsg += "\n\n"
+	}
+
+	km, global := keys.Current().Queue, keys.Current().Global
+	help := styles.HelpStyle.Render(keys.HelpLine(
+		km.Up, km.Down, km.MoveUp, km.MoveDown, km.Top, km.Bottom, km.Start, km.Stop, km.Pause, km.Resume, km.Clear, km.Refresh,
+		global.NextView, global.Help, global.Quit,
+	))
+
+	return fmt.Sprintf("%s\n\n%s%s\n%s", header, errorMsg, s.renderEntries(), help)
+}
+
+func (s *QueueScreen) renderEntries() string {
+	if len(s.entries) == 0 {
+		return styles.MutedStyle.Render("The queue is empty. Queue chapters with Q in the details view.")
+	}
+
+	var b strings.Builder
+	for i, entry := range s.entries {
+		name := entry.MangaID
+		if entry.Manga != nil {
+			name = entry.Manga.Name
+		}
+		chapter := entry.ChapterID
+		if entry.Chapter != nil {
+			chapter = entry.Chapter.Number
+		}
+
+		icon, style := "○", styles.MutedStyle
+		switch entry.Status {
+		case data.QueueActive:
+			icon, style = "◐", styles.StatusDownloading
+		case data.QueuePaused:
+			icon = utils.IconPause.String()
+		case data.QueueFailed:
+			icon, style = utils.IconCross.String(), styles.StatusError
+		case data.QueueDone:
+			icon, style = "●", styles.StatusCompleted
+		}
+
+		line := fmt.Sprintf("%s %s - Ch. %s", icon, name, chapter)
+		if entry.Error != "" {
+			line += fmt.Sprintf(" (%s)", entry.Error)
+		}
+		if i == s.selected {
+			line = styles.SelectedStyle.Render(line)
+		} else {
+			line = style.Render(line)
+		}
+
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Messages
+type queueLoadedMsg struct {
+	entries []*services.QueueEntry
+	err     error
+}
+
+type queueTickMsg struct{}
+
+type queueDoneMsg struct {
+	err error
+}
+
+// Commands
+func (s *QueueScreen) loadQueue() tea.Msg {
+	entries, err := s.queue.List()
+	return queueLoadedMsg{entries: entries, err: err}
+}
+
+// tick refreshes the list while the queue is running
+func (s *QueueScreen) tick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return queueTickMsg{}
+	})
+}
+
+// Start downloads the queue in the background, unless it is running already
+func (s *QueueScreen) Start() tea.Cmd {
+	if s.queue.Running() {
+		return nil
+	}
+	return tea.Batch(s.run, s.tick())
+}
+
+func (s *QueueScreen) run() tea.Msg {
+	return queueDoneMsg{err: s.queue.Run(context.Background())}
+}
+
+// moveSelected moves the selected chapter to index, the selection following
+// it. Only chapters waiting to be downloaded can be moved.
+func (s *QueueScreen) moveSelected(index int) tea.Cmd {
+	if index < 0 || index >= len(s.entries) || index == s.selected {
+		return nil
+	}
+	entry := s.entries[s.selected]
+	if !pending(entry.Status) {
+		return nil
+	}
+	s.selected = index
+	return s.update(func() error {
+		return s.queue.Move(entry.ChapterID, index+1)
+	})
+}
+
+// pending reports whether a queue status is waiting to be downloaded
+func pending(status string) bool {
+	switch status {
+	case data.QueueQueued, data.QueuePaused, data.QueueFailed:
+		return true
+	}
+	return false
+}
+
+// update applies a change to the queue and reloads it
+func (s *QueueScreen) update(change func() error) tea.Cmd {
+	return func() tea.Msg {
+		if err := change(); err != nil {
+			return queueLoadedMsg{entries: s.entries, err: err}
+		}
+		return s.loadQueue()
+	}
+}
@@ -0,0 +1,53 @@
+package screens
+
+import (
+	"errors"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kerbaras/mangas/pkg/app/components"
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/services"
+)
+
+func TestQueueScreen_MoveSelected(t *testing.T) {
+	s := NewQueueScreen(nil)
+	for i, status := range []string{data.QueueActive, data.QueueQueued, data.QueueQueued, data.QueuePaused} {
+		s.entries = append(s.entries, &services.QueueEntry{
+			QueueItem: &data.QueueItem{ChapterID: string(rune('a' + i)), Status: status},
+		})
+	}
+	key := func(k string) tea.Cmd {
+		_, cmd := s.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+		return cmd
+	}
+
+	// The chapter downloading can't be moved
+	if cmd := key("J"); cmd != nil || s.selected != 0 {
+		t.Errorf("Expected the active chapter to stay, selected = %d", s.selected)
+	}
+
+	s.selected = 3
+	if cmd := key("T"); cmd == nil || s.selected != 0 {
+		t.Errorf("Expected the selection to follow the chapter to the top, selected = %d", s.selected)
+	}
+	s.selected = 1
+	if cmd := key("B"); cmd == nil || s.selected != 3 {
+		t.Errorf("Expected the selection to follow the chapter to the bottom, selected = %d", s.selected)
+	}
+	if cmd := key("J"); cmd != nil || s.selected != 3 {
+		t.Errorf("Expected the last chapter not to move further, selected = %d", s.selected)
+	}
+}
+
+func TestQueueScreen_LoadErrorIsWarning(t *testing.T) {
+	s := NewQueueScreen(nil)
+	_, cmd := s.Update(queueLoadedMsg{err: errors.New("database is locked")})
+	if cmd == nil {
+		t.Fatal("Expected the error to be reported")
+	}
+	msg, ok := cmd().(ErrorMsg)
+	if !ok || msg.Severity != components.SeverityWarning {
+		t.Errorf("Expected a warning, got %+v", msg)
+	}
+}
@@ -0,0 +1,389 @@
+package screens
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kerbaras/mangas/pkg/app/components"
+	"github.com/kerbaras/mangas/pkg/app/keys"
+	"github.com/kerbaras/mangas/pkg/app/styles"
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// readerTarget is the chapter a SwitchScreenMsg to the reader opens
+type readerTarget struct {
+	mangaID   string
+	chapterID string
+}
+
+// ReaderScreen shows the pages of a downloaded chapter one at a time, read
+// from its EPUB or CBZ as they are turned, and records the last page read.
+// The chapters of a volume, or merged, are read as their one book.
+type ReaderScreen struct {
+	repo      *data.Repository
+	protocol  components.GraphicsProtocol
+	mangaID   string
+	chapterID string // Chapter opened first
+	manga     *data.Manga
+	chapters  []*data.Chapter            // Books of the downloaded chapters in the language of the first one, in reading order
+	shared    map[string][]*data.Chapter // The chapters of each book, by path
+	current   int                        // Chapter being read
+	book      *integrations.Book         // Its book, nil until opened
+	page      int
+	rendered  string // The current page, drawn at the current size
+	loading   bool
+	width     int
+	height    int
+	err       error
+}
+
+func NewReaderScreen(repo *data.Repository, protocol components.GraphicsProtocol, mangaID, chapterID string) *ReaderScreen {
+	return &ReaderScreen{
+		repo:      repo,
+		protocol:  protocol,
+		mangaID:   mangaID,
+		chapterID: chapterID,
+		loading:   true,
+	}
+}
+
+func (s *ReaderScreen) Init() tea.Cmd {
+	return s.loadChapters
+}
+
+func (s *ReaderScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		s.width = msg.Width
+		s.height = msg.Height
+		s.render()
+
+	case tea.KeyMsg:
+		km := keys.Current().Reader
+		switch {
+		case key.Matches(msg, km.NextPage):
+			if s.page < s.pages()-1 {
+				return s, s.turnTo(s.page + 1)
+			}
+			// Past the last page, on to the next chapter
+			return s, s.openChapter(s.current + 1)
+		case key.Matches(msg, km.PrevPage):
+			if s.page > 0 {
+				return s, s.turnTo(s.page - 1)
+			}
+		case key.Matches(msg, km.FirstPage):
+			return s, s.turnTo(0)
+		case key.Matches(msg, km.LastPage):
+			return s, s.turnTo(s.pages() - 1)
+		case key.Matches(msg, km.NextChapter):
+			return s, s.openChapter(s.current + 1)
+		case key.Matches(msg, km.PrevChapter):
+			return s, s.openChapter(s.current - 1)
+		case key.Matches(msg, km.External):
+			// Hand the chapter to the external viewer
+			if chapter := s.chapter(); chapter != nil {
+				return s, s.openExternal(chapter.FilePath)
+			}
+		case key.Matches(msg, km.Back):
+			return s, func() tea.Msg {
+				return SwitchScreenMsg{Screen: "details", Data: s.mangaID}
+			}
+		}
+
+	case readerChaptersMsg:
+		s.manga = msg.manga
+		s.chapters = msg.chapters
+		s.shared = msg.shared
+		s.err = msg.err
+		if msg.err != nil {
+			s.loading = false
+			return s, reportError("reader", msg.err, components.SeverityWarning)
+		}
+		for i, ch := range s.chapters {
+			if ch.ID == s.chapterID || s.sharesBook(ch) {
+				s.current = i
+			}
+		}
+		return s, s.loadPages(s.current)
+
+	case readerPagesMsg:
+		if msg.index != s.current {
+			if msg.book != nil {
+				msg.book.Close() // Pages of a chapter since left
+			}
+			break
+		}
+		s.loading = false
+		s.err = msg.err
+		if msg.err != nil {
+			s.rendered = ""
+			return s, reportError("reader", msg.err, components.SeverityWarning)
+		}
+		s.book = msg.book
+		// Resume where the chapter was left, unless it was finished
+		start := 0
+		if chapter := s.chapter(); !chapter.Read && chapter.LastReadPage > 0 {
+			start = min(chapter.LastReadPage, s.pages()) - 1
+		}
+		return s, s.turnTo(start)
+
+	case readerProgressMsg:
+		return s, reportError("reader", msg.err, components.SeverityWarning)
+
+	case readerExitedMsg:
+		// Redraw the page the viewer may have drawn over
+		s.render()
+		return s, reportError("reader", msg.err, components.SeverityWarning)
+	}
+
+	return s, nil
+}
+
+func (s *ReaderScreen) View() string {
+	if s.width == 0 || s.manga == nil && s.err == nil {
+		return "Loading..."
+	}
+
+	header := styles.TitleStyle.Render(fmt.Sprintf("%s %s", utils.IconBook, s.title()))
+
+	// The page drawn last is deleted when no page replaces it
+	var body string
+	switch {
+	case s.err != nil:
+		body = components.ClearImages(s.protocol) + styles.StatusError.Render(fmt.Sprintf("Error: %s", s.err))
+	case s.loading:
+		body = components.ClearImages(s.protocol) + styles.MutedStyle.Render("Loading pages...")
+	case s.pages() == 0:
+		body = components.ClearImages(s.protocol) + styles.MutedStyle.Render("No pages in this chapter")
+	default:
+		body = lipgloss.PlaceHorizontal(s.width, lipgloss.Center, s.rendered)
+	}
+
+	km, global := keys.Current().Reader, keys.Current().Global
+	help := styles.HelpStyle.Render(keys.HelpLine(
+		km.PrevPage, km.NextPage, km.FirstPage, km.LastPage, km.PrevChapter, km.NextChapter, km.External, km.Back,
+		global.Help, global.Quit,
+	))
+	return fmt.Sprintf("%s\n%s\n%s", header, body, help)
+}
+
+// title names the chapter and the page being read
+func (s *ReaderScreen) title() string {
+	chapter := s.chapter()
+	if s.manga == nil || chapter == nil {
+		return "Reader"
+	}
+	number := chapter.Number
+	if shared := s.shared[chapter.FilePath]; len(shared) > 1 {
+		number += "–" + shared[len(shared)-1].Number
+	}
+	title := fmt.Sprintf("%s — Ch. %s", s.manga.Name, number)
+	if s.pages() > 0 {
+		title += fmt.Sprintf(" (%d/%d)", s.page+1, s.pages())
+	}
+	return title
+}
+
+// pages returns the number of pages of the book being read
+func (s *ReaderScreen) pages() int {
+	if s.book == nil {
+		return 0
+	}
+	return s.book.Len()
+}
+
+// sharesBook reports whether the chapter opened first is in the book of ch
+func (s *ReaderScreen) sharesBook(ch *data.Chapter) bool {
+	for _, other := range s.shared[ch.FilePath] {
+		if other.ID == s.chapterID {
+			return true
+		}
+	}
+	return false
+}
+
+// Close closes the book being read
+func (s *ReaderScreen) Close() {
+	if s.book != nil {
+		s.book.Close()
+		s.book = nil
+	}
+}
+
+// chapter returns the chapter being read, nil before they are loaded
+func (s *ReaderScreen) chapter() *data.Chapter {
+	if s.current < 0 || s.current >= len(s.chapters) {
+		return nil
+	}
+	return s.chapters[s.current]
+}
+
+// pageHeight is how many lines a page can take, below the header and above
+// the help
+func (s *ReaderScreen) pageHeight() int {
+	return s.height - 4
+}
+
+// render draws the current page at the current size
+func (s *ReaderScreen) render() {
+	s.rendered = ""
+	if s.page >= s.pages() || s.width == 0 {
+		return
+	}
+	rendered := ""
+	page, err := s.book.Page(s.page)
+	if err == nil {
+		rendered, err = components.RenderPage(s.protocol, page, s.width, s.pageHeight())
+	}
+	if err != nil {
+		s.rendered = components.ClearImages(s.protocol) + styles.StatusError.Render(fmt.Sprintf("Page %d: %s", s.page+1, err))
+		return
+	}
+	s.rendered = rendered
+}
+
+// turnTo shows a page of the chapter and records it as the last one read,
+// the chapter as read once its last page is reached
+func (s *ReaderScreen) turnTo(page int) tea.Cmd {
+	chapter := s.chapter()
+	if chapter == nil || page < 0 || page >= s.pages() {
+		return nil
+	}
+	s.page = page
+	s.render()
+
+	chapter.LastReadPage = page + 1
+	finished := page == s.pages()-1
+	// Finishing a book finishes every chapter in it
+	read := []string{chapter.ID}
+	if finished {
+		chapter.Read = true
+		for _, other := range s.shared[chapter.FilePath] {
+			if other.ID != chapter.ID {
+				other.Read = true
+				read = append(read, other.ID)
+			}
+		}
+	}
+	repo, chapterID := s.repo, chapter.ID
+	return func() tea.Msg {
+		if err := repo.SetReadProgress(chapterID, page+1); err != nil {
+			return readerProgressMsg{err: err}
+		}
+		if finished {
+			for _, id := range read {
+				if err := repo.MarkChapterRead(id, true); err != nil {
+					return readerProgressMsg{err: err}
+				}
+			}
+		}
+		return readerProgressMsg{}
+	}
+}
+
+// openChapter starts reading another downloaded chapter, from its first
+// page or where it was left
+func (s *ReaderScreen) openChapter(index int) tea.Cmd {
+	if index < 0 || index >= len(s.chapters) || index == s.current {
+		return nil
+	}
+	s.Close()
+	s.current = index
+	s.page = 0
+	s.rendered = ""
+	s.loading = true
+	return s.loadPages(index)
+}
+
+// Messages
+type readerChaptersMsg struct {
+	manga    *data.Manga
+	chapters []*data.Chapter
+	shared   map[string][]*data.Chapter
+	err      error
+}
+
+type readerPagesMsg struct {
+	index int
+	book  *integrations.Book
+	err   error
+}
+
+type readerProgressMsg struct {
+	err error
+}
+
+type readerExitedMsg struct {
+	err error
+}
+
+// Commands
+func (s *ReaderScreen) loadChapters() tea.Msg {
+	manga, err := s.repo.GetManga(s.mangaID)
+	if err != nil {
+		return readerChaptersMsg{err: err}
+	}
+	if manga == nil {
+		return readerChaptersMsg{err: fmt.Errorf("manga not found")}
+	}
+	chapters, err := s.repo.GetChapters(s.mangaID)
+	if err != nil {
+		return readerChaptersMsg{manga: manga, err: err}
+	}
+	readable, shared := readableChapters(chapters, s.chapterID)
+	return readerChaptersMsg{manga: manga, chapters: readable, shared: shared}
+}
+
+// readableChapters returns the downloaded chapters in the language of the
+// chapter opened, the ones read next and before it, one per book: the
+// chapters of a volume, or merged, share one. shared holds the chapters of
+// each book by path.
+func readableChapters(chapters []*data.Chapter, chapterID string) (readable []*data.Chapter, shared map[string][]*data.Chapter) {
+	language := ""
+	for _, ch := range chapters {
+		if ch.ID == chapterID {
+			language = ch.Language
+		}
+	}
+	shared = make(map[string][]*data.Chapter)
+	for _, ch := range chapters {
+		if !ch.Downloaded || ch.FilePath == "" || ch.Language != language {
+			continue
+		}
+		if len(shared[ch.FilePath]) == 0 {
+			readable = append(readable, ch)
+		}
+		shared[ch.FilePath] = append(shared[ch.FilePath], ch)
+	}
+	return readable, shared
+}
+
+func (s *ReaderScreen) loadPages(index int) tea.Cmd {
+	path := s.chapters[index].FilePath
+	return func() tea.Msg {
+		book, err := integrations.OpenBook(path)
+		return readerPagesMsg{index: index, book: book, err: err}
+	}
+}
+
+// openExternal opens a book with the program of services.ReaderCommandKey,
+// the TUI waiting for it to exit, or with the system's application for it
+func (s *ReaderScreen) openExternal(path string) tea.Cmd {
+	cmd, err := services.ReaderCommand(s.repo, path)
+	if err != nil {
+		return reportError("reader", err, components.SeverityWarning)
+	}
+	if cmd == nil {
+		return func() tea.Msg {
+			return readerExitedMsg{err: utils.OpenFile(path)}
+		}
+	}
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return readerExitedMsg{err: err}
+	})
+}
@@ -0,0 +1,141 @@
+package screens
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kerbaras/mangas/pkg/app/components"
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+// writeTestCBZ writes a CBZ of n blank pages
+func writeTestCBZ(t *testing.T, n int) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 20, 30))); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "chapter.cbz")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := zip.NewWriter(file)
+	for i := 1; i <= n; i++ {
+		w, _ := archive.Create(fmt.Sprintf("%03d.png", i))
+		w.Write(buf.Bytes())
+	}
+	archive.Close()
+	file.Close()
+	return path
+}
+
+// loadReader opens the reader on the chapter c1 of chapters, running the
+// commands loading its pages
+func loadReader(t *testing.T, chapters []*data.Chapter) *ReaderScreen {
+	t.Helper()
+	s := NewReaderScreen(nil, components.GraphicsNone, "m1", "c1")
+	s.Update(tea.WindowSizeMsg{Width: 40, Height: 20})
+	readable, shared := readableChapters(chapters, "c1")
+	_, cmd := s.Update(readerChaptersMsg{manga: &data.Manga{ID: "m1", Name: "Reader Manga"}, chapters: readable, shared: shared})
+	s.Update(cmd())
+	t.Cleanup(s.Close)
+	if s.loading || s.err != nil {
+		t.Fatalf("Pages not loaded: %v", s.err)
+	}
+	return s
+}
+
+func TestReaderScreen_Pages(t *testing.T) {
+	path := writeTestCBZ(t, 3)
+	chapters := []*data.Chapter{
+		{ID: "c1", Number: "1", Language: "en", Downloaded: true, FilePath: path, LastReadPage: 2},
+		{ID: "c1-es", Number: "1", Language: "es", Downloaded: true, FilePath: path},
+		{ID: "c2", Number: "2", Language: "en"},
+		{ID: "c3", Number: "3", Language: "en", Downloaded: true, FilePath: writeTestCBZ(t, 3)},
+	}
+	s := loadReader(t, chapters)
+
+	if len(s.chapters) != 2 || s.chapters[1].ID != "c3" {
+		t.Fatalf("Expected the downloaded English chapters, got %+v", s.chapters)
+	}
+	if s.page != 1 || s.rendered == "" {
+		t.Fatalf("Expected to resume on page 2, on page %d", s.page+1)
+	}
+
+	press := func(key string) tea.Cmd {
+		_, cmd := s.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		return cmd
+	}
+	press("h")
+	press("h") // Stays on the first page
+	if s.page != 0 || s.chapters[0].LastReadPage != 1 {
+		t.Errorf("After going back: page %d, last read %d", s.page+1, s.chapters[0].LastReadPage)
+	}
+	if press("G") == nil || s.page != 2 || !s.chapters[0].Read {
+		t.Errorf("Expected the last page to mark the chapter read, page %d, read %v", s.page+1, s.chapters[0].Read)
+	}
+
+	// Past the last page, on to the next chapter
+	cmd := press("l")
+	if s.current != 1 || !s.loading || cmd == nil {
+		t.Fatalf("Expected the next chapter to load, current %d", s.current)
+	}
+	s.Update(cmd())
+	if s.page != 0 || s.pages() != 3 {
+		t.Errorf("Expected the next chapter from its first page, page %d of %d", s.page+1, s.pages())
+	}
+	if press("]") != nil {
+		t.Error("Expected no chapter after the last one")
+	}
+}
+
+func TestReaderScreen_Errors(t *testing.T) {
+	chapters := []*data.Chapter{
+		{ID: "c1", Number: "1", Language: "en", Downloaded: true, FilePath: filepath.Join(t.TempDir(), "missing.cbz")},
+	}
+	s := NewReaderScreen(nil, components.GraphicsNone, "m1", "c1")
+	s.Update(tea.WindowSizeMsg{Width: 40, Height: 20})
+	_, cmd := s.Update(readerChaptersMsg{manga: &data.Manga{ID: "m1"}, chapters: chapters})
+	if _, cmd = s.Update(cmd()); cmd == nil || s.err == nil {
+		t.Error("Expected a book that can't be read to be reported")
+	}
+
+	_, cmd = s.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if msg, ok := cmd().(SwitchScreenMsg); !ok || msg.Screen != "details" || msg.Data != "m1" {
+		t.Errorf("Expected esc to go back to the details, got %+v", msg)
+	}
+}
+
+func TestReaderScreen_SharedBook(t *testing.T) {
+	volume := writeTestCBZ(t, 4)
+	chapters := []*data.Chapter{
+		{ID: "c0", Number: "0", Language: "en", Downloaded: true, FilePath: writeTestCBZ(t, 1)},
+		{ID: "c1", Number: "1", Language: "en", Downloaded: true, FilePath: volume},
+		{ID: "c2", Number: "2", Language: "en", Downloaded: true, FilePath: volume},
+		{ID: "c3", Number: "3", Language: "en", Downloaded: true, FilePath: volume},
+	}
+	s := loadReader(t, chapters)
+
+	// The volume is read once, as one book
+	if len(s.chapters) != 2 || s.current != 1 || s.pages() != 4 {
+		t.Fatalf("Expected 2 books, the volume open with 4 pages, got %d books, book %d with %d pages", len(s.chapters), s.current+1, s.pages())
+	}
+	if title := s.title(); !strings.Contains(title, "Ch. 1–3") {
+		t.Errorf("Expected the title to name the volume's chapters, got %q", title)
+	}
+	s.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("G")})
+	for _, ch := range chapters[1:] {
+		if !ch.Read {
+			t.Errorf("Expected finishing the volume to mark chapter %s read", ch.Number)
+		}
+	}
+}
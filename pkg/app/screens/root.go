@@ -2,65 +2,167 @@ package screens
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
 
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/kerbaras/mangas/pkg/app/components"
+	"github.com/kerbaras/mangas/pkg/app/keys"
 	"github.com/kerbaras/mangas/pkg/app/styles"
 	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/log"
 	"github.com/kerbaras/mangas/pkg/services"
 	"github.com/kerbaras/mangas/pkg/sources"
+	"github.com/kerbaras/mangas/pkg/utils"
 )
 
 type screenType int
 
 const (
-	libraryView screenType = iota
+	dashboardView screenType = iota
+	libraryView
 	searchView
+	queueView
+	statsView
 	detailsView
+	readerView
 )
 
+// tabbedViews are the views cycled through with tab, all but details and
+// the reader
+const tabbedViews = int(detailsView)
+
 type RootScreen struct {
 	repo       *data.Repository
 	source     sources.Source
 	downloader *services.Downloader
+	queue      *services.DownloadQueue
+	thumbnails *components.ThumbnailStrip
+	graphics   components.GraphicsProtocol
 
 	currentView screenType
+	dashboard   *DashboardScreen
 	library     *LibraryScreen
 	search      *SearchScreen
+	queuePanel  *QueueScreen
+	stats       *StatsScreen
 	details     *DetailsScreen
+	reader      *ReaderScreen
+
+	errors       *components.ErrorCenter
+	showErrors   bool       // Error center is displayed over the active screen
+	palette      *components.Palette
+	showPalette  bool       // Command palette is displayed over the active screen
+	paletteMode  paletteMode
+	showHelp     bool       // Key bindings are displayed over the active screen
+	sourceAlerts chan error // Sources found degraded during the session
+	toasts       *components.Toasts
+	drawn        string // Screen or overlay of the last frame, its images are deleted when it changes
 
 	width  int
 	height int
 }
 
 func NewRootScreen() *RootScreen {
+	return NewRootScreenWithRepository(data.NewDuckDBRepository())
+}
+
+// NewRootScreenWithRepository creates the root screen on a given library,
+// such as one opened with data.OpenDuckDBRepository
+func NewRootScreenWithRepository(repo *data.Repository) *RootScreen {
 	// Initialize dependencies
-	repo := data.NewDuckDBRepository()
-	source := sources.NewMangaDex()
+	source, _ := sources.Get(sources.DefaultSource)
 	
-	homeDir, _ := os.UserHomeDir()
-	downloadDir := filepath.Join(homeDir, ".mangas", "downloads")
+	downloadDir := services.DefaultDownloadDir()
 	
 	downloader := services.NewDownloader(source, repo, downloadDir)
+	downloader.SetChecksumStore(repo)
+	downloader.SetMangaSettings(repo)
+	downloader.SetQuotaStore(repo)
+	thumbnailCache := services.NewThumbnailCache(services.DefaultThumbnailDir())
+	downloader.SetThumbnailCache(thumbnailCache)
+	if templates, err := services.LoadPathTemplates(repo); err == nil {
+		downloader.SetPathTemplates(templates)
+	}
+	if transcode, err := services.LoadTranscodePages(repo); err == nil {
+		downloader.SetTranscode(transcode)
+	}
+	if ocr, err := services.LoadOCR(repo); err == nil {
+		downloader.SetOCR(ocr)
+	} else {
+		log.Warn("text of pages not recognized", "err", err)
+	}
+	if hooks, err := services.LoadWebhooks(repo); err == nil {
+		downloader.SetNotifier(services.NewNotifier(hooks))
+	}
+	queue := services.NewDownloadQueue(repo, repo, downloader)
+
+	// Covers and page thumbnails are drawn inline when the terminal can show
+	// images
+	graphics := components.DetectGraphics()
+	covers := components.NewCoverRenderer(graphics, func(manga *data.Manga) ([]byte, error) {
+		cover, err := downloader.FetchCover(manga)
+		return cover.Content, err
+	})
+	thumbnails := components.NewThumbnailStrip(graphics, thumbnailCache.Get)
 
 	// Create screens
-	library := NewLibraryScreen(repo, downloader)
-	search := NewSearchScreen(source, downloader)
+	dashboard := NewDashboardScreen(repo, downloader, queue)
+	library := NewLibraryScreen(repo, downloader, covers)
+	search := NewSearchScreen(source, downloader, covers)
+	queuePanel := NewQueueScreen(queue)
+	stats := NewStatsScreen(repo)
+
+	// Degraded sources are reported in the error center
+	sourceAlerts := make(chan error, 8)
+	utils.Stats.OnDegraded(func(source, reason string) {
+		select {
+		case sourceAlerts <- fmt.Errorf("source %s is degraded: %s", source, reason):
+		default:
+		}
+	})
 
 	return &RootScreen{
-		repo:        repo,
-		source:      source,
-		downloader:  downloader,
-		currentView: libraryView,
-		library:     library,
-		search:      search,
+		repo:         repo,
+		source:       source,
+		downloader:   downloader,
+		queue:        queue,
+		thumbnails:   thumbnails,
+		graphics:     graphics,
+		currentView:  libraryView,
+		dashboard:    dashboard,
+		library:      library,
+		search:       search,
+		queuePanel:   queuePanel,
+		stats:        stats,
+		errors:       components.NewErrorCenter(50),
+		palette:      components.NewPalette(),
+		sourceAlerts: sourceAlerts,
+		toasts:       components.NewToasts(),
+	}
+}
+
+// sourceAlertMsg reports a source found degraded
+type sourceAlertMsg struct{ err error }
+
+// waitForSourceAlert waits for the next degraded source
+func (r *RootScreen) waitForSourceAlert() tea.Cmd {
+	return func() tea.Msg {
+		return sourceAlertMsg{err: <-r.sourceAlerts}
 	}
 }
 
+// StartOnDashboard makes the home dashboard the first screen shown instead
+// of the library
+func (r *RootScreen) StartOnDashboard() {
+	r.currentView = dashboardView
+}
+
 func (r *RootScreen) Init() tea.Cmd {
-	return r.library.Init()
+	if r.currentView == dashboardView {
+		return tea.Batch(r.dashboard.Init(), r.waitForSourceAlert())
+	}
+	return tea.Batch(r.library.Init(), r.waitForSourceAlert())
 }
 
 func (r *RootScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -70,21 +172,123 @@ func (r *RootScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		r.width = msg.Width
 		r.height = msg.Height
+		r.errors.Width = msg.Width - 4
+		r.errors.Height = msg.Height - 10
+		r.palette.Width = msg.Width - 4
+		r.palette.Height = msg.Height - 12
+		// Opened from the palette too, it needs the size before it is shown
+		r.stats.Update(msg)
+
+	case ErrorMsg:
+		r.errors.Add(msg.Screen, msg.Err, msg.Severity)
+		if r.showErrors {
+			r.errors.MarkRead()
+		}
+		return r, nil
+
+	case ToastMsg:
+		return r, r.toasts.Push(msg.Text)
+
+	case components.ToastExpiredMsg:
+		r.toasts.Expire(msg)
+		return r, nil
+
+	case sourceAlertMsg:
+		r.errors.Add("sources", msg.err, components.SeverityWarning)
+		if r.showErrors {
+			r.errors.MarkRead()
+		}
+		return r, r.waitForSourceAlert()
+
+	case paletteItemsMsg:
+		if msg.mode != r.paletteMode {
+			return r, nil // Loaded for a palette since closed
+		}
+		items := msg.items
+		if msg.mode == paletteCommands {
+			items = append(r.paletteCommands(), items...)
+		}
+		r.palette.SetItems(items)
+		return r, reportError("palette", msg.err, components.SeverityWarning)
+
+	case prefetchedMsg:
+		// Download the chapters ahead without leaving the current screen
+		if msg.queued > 0 {
+			return r, r.queuePanel.Start()
+		}
+		return r, nil
+
+	case queueDoneMsg:
+		// The queue may have been started from another screen
+		newModel, newCmd := r.queuePanel.Update(msg)
+		r.queuePanel = newModel.(*QueueScreen)
+		return r, newCmd
+
+	case services.DownloadProgress:
+		// The details screen follows downloads started before leaving it
+		if r.details != nil {
+			newModel, newCmd := r.details.Update(msg)
+			r.details = newModel.(*DetailsScreen)
+			return r, newCmd
+		}
+		return r, nil
+
+	case dashboardProgressMsg:
+		// The dashboard follows downloads from every screen
+		newModel, newCmd := r.dashboard.Update(msg)
+		r.dashboard = newModel.(*DashboardScreen)
+		return r, newCmd
 
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "q", "ctrl+c":
+		global := keys.Current().Global
+		if r.showErrors {
+			return r.updateErrorCenter(msg)
+		}
+		if r.showPalette {
+			return r.updatePalette(msg)
+		}
+		if r.showHelp {
+			return r.updateHelp(msg)
+		}
+		if key.Matches(msg, global.Palette) {
+			return r, r.openPalette(paletteCommands)
+		}
+		if r.currentView == libraryView && r.library.Filtering() && msg.String() != "ctrl+c" {
+			break // Keys go to the library filter input
+		}
+		if r.currentView == detailsView && r.details != nil && r.details.Editing() && msg.String() != "ctrl+c" {
+			break // Keys go to the details text input
+		}
+		if r.currentView == searchView && r.search.Typing() && key.Matches(msg, global.Help) {
+			break // Typed in the search query
+		}
+		switch {
+		case msg.String() == "ctrl+c", key.Matches(msg, global.Quit):
 			return r, tea.Quit
-		case "tab":
+		case key.Matches(msg, global.Errors):
+			r.showErrors = true
+			r.errors.MarkRead()
+			return r, nil
+		case key.Matches(msg, global.Help):
+			r.showHelp = true
+			return r, nil
+		case key.Matches(msg, global.NextView):
 			// Cycle through views
-			if r.currentView == detailsView {
-				// Can't tab away from details, use esc
+			if r.currentView == detailsView || r.currentView == readerView {
+				// Can't tab away from details or the reader, use esc
 				break
 			}
-			r.currentView = (r.currentView + 1) % 2
-			if r.currentView == searchView {
+			r.currentView = screenType((int(r.currentView) + 1) % tabbedViews)
+			switch r.currentView {
+			case dashboardView:
+				cmd = r.dashboard.Init()
+			case searchView:
 				cmd = r.search.Init()
-			} else {
+			case queueView:
+				cmd = r.queuePanel.Init()
+			case statsView:
+				cmd = r.stats.Init()
+			default:
 				cmd = r.library.Init()
 			}
 			return r, cmd
@@ -93,24 +297,61 @@ func (r *RootScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case SwitchScreenMsg:
 		// Handle screen switching from sub-screens
 		switch msg.Screen {
+		case "home":
+			r.currentView = dashboardView
+			cmd = r.dashboard.Init()
 		case "library":
 			r.currentView = libraryView
 			cmd = r.library.Init()
 		case "search":
 			r.currentView = searchView
 			cmd = r.search.Init()
+		case "queue":
+			r.currentView = queueView
+			cmd = r.queuePanel.Init()
+			if msg.Data == queueStart {
+				cmd = tea.Batch(cmd, r.queuePanel.Start())
+			}
+		case "stats":
+			r.currentView = statsView
+			cmd = r.stats.Init()
 		case "details":
 			if mangaID, ok := msg.Data.(string); ok {
-				r.details = NewDetailsScreen(r.repo, r.downloader, mangaID)
+				if r.currentView == readerView && r.reader != nil {
+					r.reader.Close()
+				}
+				if r.currentView == readerView && r.details != nil && r.details.mangaID == mangaID {
+					// Back from the reader, with the progress made
+					r.currentView = detailsView
+					return r, r.details.loadDetails
+				}
+				if r.details != nil {
+					r.details.Close()
+				}
+				r.details = NewDetailsScreen(r.repo, r.downloader, r.queue, r.thumbnails, mangaID)
 				r.currentView = detailsView
 				cmd = r.details.Init()
 			}
+		case "reader":
+			if target, ok := msg.Data.(readerTarget); ok {
+				if r.reader != nil {
+					r.reader.Close()
+				}
+				r.reader = NewReaderScreen(r.repo, r.graphics, target.mangaID, target.chapterID)
+				r.reader.Update(tea.WindowSizeMsg{Width: r.width, Height: r.height})
+				r.currentView = readerView
+				cmd = r.reader.Init()
+			}
 		}
 		return r, cmd
 	}
 
 	// Forward message to active screen
 	switch r.currentView {
+	case dashboardView:
+		newModel, newCmd := r.dashboard.Update(msg)
+		r.dashboard = newModel.(*DashboardScreen)
+		return r, newCmd
 	case libraryView:
 		newModel, newCmd := r.library.Update(msg)
 		r.library = newModel.(*LibraryScreen)
@@ -119,35 +360,109 @@ func (r *RootScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		newModel, newCmd := r.search.Update(msg)
 		r.search = newModel.(*SearchScreen)
 		return r, newCmd
+	case queueView:
+		newModel, newCmd := r.queuePanel.Update(msg)
+		r.queuePanel = newModel.(*QueueScreen)
+		return r, newCmd
+	case statsView:
+		newModel, newCmd := r.stats.Update(msg)
+		r.stats = newModel.(*StatsScreen)
+		return r, newCmd
 	case detailsView:
 		if r.details != nil {
 			newModel, newCmd := r.details.Update(msg)
 			r.details = newModel.(*DetailsScreen)
 			return r, newCmd
 		}
+	case readerView:
+		if r.reader != nil {
+			newModel, newCmd := r.reader.Update(msg)
+			r.reader = newModel.(*ReaderScreen)
+			return r, newCmd
+		}
 	}
 
 	return r, cmd
 }
 
+// updateErrorCenter handles key presses while the error center is displayed
+func (r *RootScreen) updateErrorCenter(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m := keys.Current()
+	switch {
+	case msg.String() == "ctrl+c", key.Matches(msg, m.Global.Quit):
+		return r, tea.Quit
+	case key.Matches(msg, m.ErrorCenter.Close, m.Global.Errors):
+		r.showErrors = false
+	case key.Matches(msg, m.ErrorCenter.Clear):
+		r.errors.Clear()
+	}
+	return r, nil
+}
+
 func (r *RootScreen) View() string {
+	view, drawn := r.view()
+	if drawn != r.drawn {
+		r.drawn = drawn
+		view = components.ClearImages(r.graphics) + view
+	}
+	return view
+}
+
+// view renders the screen or overlay displayed, named by drawn
+func (r *RootScreen) view() (view, drawn string) {
+	if r.showErrors {
+		m := keys.Current()
+		help := styles.HelpStyle.Render(keys.HelpLine(m.ErrorCenter.Clear, m.ErrorCenter.Close, m.Global.Quit))
+		return fmt.Sprintf("%s\n%s", r.errors.View(), help), "errors"
+	}
+	if r.showPalette {
+		m := keys.Current()
+		help := styles.HelpStyle.Render("↑/↓: select • " + keys.HelpLine(
+			keys.Describe(m.Panel.Confirm, "run"), m.Panel.Close, keys.Describe(m.Global.Palette, "close"),
+		))
+		return fmt.Sprintf("%s\n%s", r.palette.View(), help), "palette"
+	}
+	if r.showHelp {
+		help := styles.HelpStyle.Render("?/esc: close • q: quit")
+		return fmt.Sprintf("%s\n%s", components.KeyHelp(r.helpGroups(), r.width), help), "help"
+	}
+
 	// Render tabs
 	tabs := r.renderTabs()
 
 	// Render active screen
 	var content string
 	switch r.currentView {
+	case dashboardView:
+		content = r.dashboard.View()
 	case libraryView:
 		content = r.library.View()
 	case searchView:
 		content = r.search.View()
+	case queueView:
+		content = r.queuePanel.View()
+	case statsView:
+		content = r.stats.View()
 	case detailsView:
 		if r.details != nil {
 			content = r.details.View()
 		}
+	case readerView:
+		if r.reader != nil {
+			// The reader takes the whole screen
+			return r.withToasts(r.reader.View()), "reader"
+		}
 	}
 
-	return fmt.Sprintf("%s\n\n%s", tabs, content)
+	return r.withToasts(fmt.Sprintf("%s\n\n%s", tabs, content)), fmt.Sprint(r.currentView)
+}
+
+// withToasts shows the notifications still up below a view
+func (r *RootScreen) withToasts(view string) string {
+	if r.toasts.Len() == 0 {
+		return view
+	}
+	return fmt.Sprintf("%s\n%s", view, r.toasts.View())
 }
 
 func (r *RootScreen) renderTabs() string {
@@ -156,17 +471,55 @@ func (r *RootScreen) renderTabs() string {
 		return ""
 	}
 
-	libraryTab := "Library"
-	searchTab := "Search"
+	names := []string{"Home", "Library", "Search", "Queue", "Stats"}
+	tabs := make([]string, len(names))
+	for i, name := range names {
+		if screenType(i) == r.currentView {
+			tabs[i] = styles.ActiveTabStyle.Render(name)
+		} else {
+			tabs[i] = styles.InactiveTabStyle.Render(name)
+		}
+	}
+
+	if badge := r.errors.Badge(); badge != "" {
+		tabs = append(tabs, "  "+badge+styles.MutedStyle.Render(" ctrl+e"))
+	}
 
-	if r.currentView == libraryView {
-		libraryTab = styles.ActiveTabStyle.Render(libraryTab)
-		searchTab = styles.InactiveTabStyle.Render(searchTab)
-	} else {
-		libraryTab = styles.InactiveTabStyle.Render(libraryTab)
-		searchTab = styles.ActiveTabStyle.Render(searchTab)
+	return lipgloss.JoinHorizontal(lipgloss.Top, tabs...)
+}
+
+// viewScreens are the names of the views in key binding overrides
+var viewScreens = map[screenType]string{
+	dashboardView: "home",
+	libraryView:   "library",
+	searchView:    "search",
+	queueView:     "queue",
+	statsView:     "stats",
+	detailsView:   "details",
+	readerView:    "reader",
+}
+
+// helpGroups returns the bindings listed by the help overlay: the ones of
+// the current view, then the global ones
+func (r *RootScreen) helpGroups() []keys.Group {
+	m := keys.Current()
+	global, _ := m.Group("global")
+	if group, ok := m.Group(viewScreens[r.currentView]); ok {
+		if r.currentView == searchView || r.currentView == detailsView {
+			panel, _ := m.Group("panel")
+			return []keys.Group{group, panel, global}
+		}
+		return []keys.Group{group, global}
 	}
+	return []keys.Group{global}
+}
 
-	tabs := lipgloss.JoinHorizontal(lipgloss.Top, libraryTab, searchTab)
-	return tabs
+func (r *RootScreen) updateHelp(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case msg.String() == "ctrl+c", key.Matches(msg, keys.Current().Global.Quit):
+		return r, tea.Quit
+	case msg.String() == "esc", key.Matches(msg, keys.Current().Global.Help):
+		r.showHelp = false
+	}
+	return r, nil
 }
@@ -3,28 +3,36 @@ package screens
 import (
 	"fmt"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/kerbaras/mangas/pkg/app/components"
+	"github.com/kerbaras/mangas/pkg/app/keys"
 	"github.com/kerbaras/mangas/pkg/app/styles"
 	"github.com/kerbaras/mangas/pkg/data"
 	"github.com/kerbaras/mangas/pkg/services"
 	"github.com/kerbaras/mangas/pkg/sources"
+	"github.com/kerbaras/mangas/pkg/utils"
 )
 
 type SearchScreen struct {
 	source     sources.Source
 	downloader *services.Downloader
 	input      textinput.Model
+	filters    *components.SearchFilters
+	covers     *components.CoverRenderer
+	filtering  bool // The filter panel is open and has the focus
 	results    []data.Manga
 	selected   int
 	searching  bool
+	confirm    *components.Confirm // Download waiting for confirmation, nil when none
 	width      int
 	height     int
 	err        error
 }
 
-func NewSearchScreen(source sources.Source, downloader *services.Downloader) *SearchScreen {
+func NewSearchScreen(source sources.Source, downloader *services.Downloader, covers *components.CoverRenderer) *SearchScreen {
 	ti := textinput.New()
 	ti.Placeholder = "Search manga..."
 	ti.Focus()
@@ -35,11 +43,18 @@ func NewSearchScreen(source sources.Source, downloader *services.Downloader) *Se
 		source:     source,
 		downloader: downloader,
 		input:      ti,
+		filters:    components.NewSearchFilters(),
+		covers:     covers,
 		results:    []data.Manga{},
 		selected:   0,
 	}
 }
 
+// Typing reports whether keys go to the search query
+func (s *SearchScreen) Typing() bool {
+	return s.input.Focused()
+}
+
 func (s *SearchScreen) Init() tea.Cmd {
 	return textinput.Blink
 }
@@ -57,9 +72,25 @@ func (s *SearchScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if s.searching {
 			return s, nil
 		}
+		if s.confirm != nil {
+			done, cmd := s.confirm.Update(msg)
+			if done {
+				s.confirm = nil
+			}
+			return s, cmd
+		}
+
+		km := keys.Current().Search
+		if key.Matches(msg, km.Filters) {
+			s.filtering = !s.filtering
+			return s, nil
+		}
+		if s.filtering {
+			return s, s.updateFilters(msg)
+		}
 
-		switch msg.String() {
-		case "enter":
+		switch {
+		case key.Matches(msg, km.Submit):
 			if s.input.Focused() {
 				// Perform search
 				query := s.input.Value()
@@ -68,12 +99,15 @@ func (s *SearchScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return s, s.performSearch(query)
 				}
 			} else if len(s.results) > 0 {
-				// Start download for selected manga
+				// Download the selected manga, once confirmed
 				manga := s.results[s.selected]
-				return s, s.startDownload(manga.ID)
+				s.confirm = components.NewConfirm(
+					fmt.Sprintf("Download every chapter of %s?", manga.Name),
+					s.startDownload(manga.ID),
+				)
 			}
 
-		case "esc":
+		case key.Matches(msg, km.Focus):
 			// Switch focus between input and results
 			if s.input.Focused() {
 				s.input.Blur()
@@ -82,7 +116,7 @@ func (s *SearchScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				cmd = textinput.Blink
 			}
 
-		case "up", "k":
+		case key.Matches(msg, km.Up):
 			if !s.input.Focused() && len(s.results) > 0 {
 				s.selected--
 				if s.selected < 0 {
@@ -90,7 +124,7 @@ func (s *SearchScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			}
 
-		case "down", "j":
+		case key.Matches(msg, km.Down):
 			if !s.input.Focused() && len(s.results) > 0 {
 				s.selected++
 				if s.selected >= len(s.results) {
@@ -107,15 +141,28 @@ func (s *SearchScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if len(s.results) > 0 {
 			s.input.Blur()
 		}
+		cmds := []tea.Cmd{reportError("search", msg.err, components.SeverityWarning)}
+		for i := range s.results {
+			cmds = append(cmds, s.covers.Load(&s.results[i]))
+		}
+		return s, tea.Batch(cmds...)
+
+	case components.CoverLoadedMsg:
+		// Drawn with the next view
+		return s, nil
 
 	case downloadStartedMsg:
 		if msg.err != nil {
 			s.err = msg.err
+			return s, reportError("search", msg.err, components.SeverityWarning)
 		} else {
 			// Switch to library view
-			return s, func() tea.Msg {
-				return SwitchScreenMsg{Screen: "library", Data: nil}
-			}
+			return s, tea.Batch(
+				showToast("Downloading %d chapters of %s", msg.chapters, msg.name),
+				func() tea.Msg {
+					return SwitchScreenMsg{Screen: "library", Data: nil}
+				},
+			)
 		}
 	}
 
@@ -132,7 +179,7 @@ func (s *SearchScreen) View() string {
 		return "Loading..."
 	}
 
-	header := styles.TitleStyle.Render("🔍 Search Manga")
+	header := styles.TitleStyle.Render(utils.IconSearch.String() + " Search Manga")
 
 	// Input field
 	inputStyle := styles.InputStyle
@@ -140,6 +187,11 @@ func (s *SearchScreen) View() string {
 		inputStyle = styles.FocusedInputStyle
 	}
 	inputView := inputStyle.Render(s.input.View())
+	if s.filtering {
+		inputView += "\n\n" + s.filters.View()
+	} else if summary := s.filters.Options().Summary(); summary != "" {
+		inputView += "\n" + styles.MutedStyle.Render("Filters: "+summary)
+	}
 
 	var errorMsg string
 	if s.err != nil {
@@ -156,9 +208,21 @@ func (s *SearchScreen) View() string {
 		resultsView = styles.MutedStyle.Render("No results found")
 	}
 
-	help := styles.HelpStyle.Render(
-		"enter: search/download • esc: switch focus • ↑/k ↓/j: navigate • tab: switch view • q: quit",
-	)
+	km, global := keys.Current().Search, keys.Current().Global
+	help := styles.HelpStyle.Render(keys.HelpLine(
+		km.Submit, km.Focus, km.Up, km.Down, km.Filters, global.NextView, global.Help, global.Quit,
+	))
+	if s.filtering {
+		panel := keys.Current().Panel
+		help = styles.HelpStyle.Render(keys.HelpLine(
+			keys.Describe(panel.Up, "select filter"), keys.Describe(panel.Down, "select filter"), panel.Prev, panel.Next,
+			panel.Reset, keys.Describe(panel.Confirm, "search"), keys.Describe(panel.Close, "close filters"),
+			keys.Describe(km.Filters, "close filters"),
+		))
+	}
+	if s.confirm != nil {
+		help = s.confirm.View()
+	}
 
 	content := fmt.Sprintf("%s\n\n%s\n\n%s%s\n\n%s",
 		header,
@@ -198,6 +262,9 @@ func (s *SearchScreen) renderResults() string {
 			description,
 			source,
 		)
+		if s.covers != nil {
+			cardContent = lipgloss.JoinHorizontal(lipgloss.Top, s.covers.View(&manga), "  ", cardContent)
+		}
 
 		card := cardStyle.Width(s.width - 6).Render(cardContent)
 		result += card + "\n"
@@ -213,7 +280,9 @@ type searchResultMsg struct {
 }
 
 type downloadStartedMsg struct {
-	err error
+	name     string // Manga downloaded
+	chapters int
+	err      error
 }
 
 // Define shared message for screen switching
@@ -222,10 +291,62 @@ type SwitchScreenMsg struct {
 	Data   interface{}
 }
 
+// switchScreen returns a command switching to screen
+func switchScreen(screen string) tea.Cmd {
+	return switchScreenWith(screen, nil)
+}
+
+// switchScreenWith returns a command switching to screen with data
+func switchScreenWith(screen string, data interface{}) tea.Cmd {
+	return func() tea.Msg {
+		return SwitchScreenMsg{Screen: screen, Data: data}
+	}
+}
+
+// ToastMsg shows a transient notification over the active screen
+type ToastMsg struct {
+	Text string
+}
+
+// showToast returns a command showing a notification
+func showToast(format string, args ...any) tea.Cmd {
+	text := fmt.Sprintf(format, args...)
+	return func() tea.Msg {
+		return ToastMsg{Text: text}
+	}
+}
+
+// updateFilters handles the keys of the filter panel
+func (s *SearchScreen) updateFilters(msg tea.KeyMsg) tea.Cmd {
+	km := keys.Current().Panel
+	switch {
+	case key.Matches(msg, km.Up):
+		s.filters.Prev()
+	case key.Matches(msg, km.Down):
+		s.filters.Next()
+	case key.Matches(msg, km.Prev):
+		s.filters.Cycle(-1)
+	case key.Matches(msg, km.Next):
+		s.filters.Cycle(1)
+	case key.Matches(msg, km.Reset):
+		s.filters.Reset()
+	case key.Matches(msg, km.Close):
+		s.filtering = false
+	case key.Matches(msg, km.Confirm):
+		s.filtering = false
+		if query := s.input.Value(); query != "" {
+			s.searching = true
+			return s.performSearch(query)
+		}
+	}
+	return nil
+}
+
 // Commands
 func (s *SearchScreen) performSearch(query string) tea.Cmd {
+	options := s.filters.Options()
 	return func() tea.Msg {
-		results, err := s.source.Search(query)
+		results, err := s.source.Search(query, options)
 		// Convert []*data.Manga to []data.Manga for compatibility
 		var mangaList []data.Manga
 		for _, m := range results {
@@ -253,6 +374,6 @@ func (s *SearchScreen) startDownload(mangaID string) tea.Cmd {
 		
 		// Start download in background
 		go s.downloader.DownloadManga(manga, chapters)
-		return downloadStartedMsg{err: nil}
+		return downloadStartedMsg{name: manga.Name, chapters: len(chapters)}
 	}
 }
@@ -0,0 +1,192 @@
+package screens
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kerbaras/mangas/pkg/app/components"
+	"github.com/kerbaras/mangas/pkg/app/keys"
+	"github.com/kerbaras/mangas/pkg/app/styles"
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/services"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// statsDays is how many days of downloads the stats screen charts
+const statsDays = 30
+
+// StatsScreen shows the library statistics: totals, disk usage per manga
+// and downloads per day
+type StatsScreen struct {
+	repo        *data.Repository
+	stats       *data.LibraryStats
+	readingLeft string // Unread downloaded chapters and the time to read them
+	selected    int
+	width       int
+	height      int
+	err         error
+}
+
+func NewStatsScreen(repo *data.Repository) *StatsScreen {
+	return &StatsScreen{repo: repo}
+}
+
+func (s *StatsScreen) Init() tea.Cmd {
+	return s.load
+}
+
+func (s *StatsScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		s.width = msg.Width
+		s.height = msg.Height
+
+	case tea.KeyMsg:
+		km := keys.Current().Stats
+		switch {
+		case key.Matches(msg, km.Up):
+			if s.selected > 0 {
+				s.selected--
+			}
+		case key.Matches(msg, km.Down):
+			if s.stats != nil && s.selected < len(s.stats.PerManga)-1 {
+				s.selected++
+			}
+		case key.Matches(msg, km.Open):
+			if s.stats != nil && s.selected < len(s.stats.PerManga) {
+				return s, switchScreenWith("details", s.stats.PerManga[s.selected].MangaID)
+			}
+		case key.Matches(msg, km.Refresh):
+			return s, s.load
+		}
+
+	case statsLoadedMsg:
+		s.stats = msg.stats
+		s.readingLeft = msg.readingLeft
+		s.err = msg.err
+		if s.stats != nil && s.selected >= len(s.stats.PerManga) {
+			s.selected = 0
+		}
+		return s, reportError("stats", msg.err, components.SeverityFatal)
+	}
+	return s, nil
+}
+
+func (s *StatsScreen) View() string {
+	if s.width == 0 {
+		return "Loading..."
+	}
+
+	header := styles.TitleStyle.Render(utils.IconStats.String() + " Statistics")
+
+	var errorMsg string
+	if s.err != nil {
+		errorMsg = styles.StatusError.Render(fmt.Sprintf("Error: %s", s.err))
+		errorMsg += "\n\n"
+	}
+
+	var content string
+	if s.stats != nil {
+		content = s.renderTotals() + "\n" + s.renderDownloads() + "\n" + s.renderMangas()
+	}
+
+	km, global := keys.Current().Stats, keys.Current().Global
+	help := styles.HelpStyle.Render(keys.HelpLine(km.Up, km.Down, km.Open, km.Refresh, global.NextView, global.Help, global.Quit))
+
+	return fmt.Sprintf("%s\n\n%s%s\n%s", header, errorMsg, content, help)
+}
+
+func (s *StatsScreen) renderTotals() string {
+	lines := []string{
+		fmt.Sprintf("Manga: %d • Chapters: %d (%d downloaded, %d read)",
+			s.stats.Mangas, s.stats.Chapters, s.stats.Downloaded, s.stats.Read),
+		fmt.Sprintf("Pages read: %d • Disk usage: %s", s.stats.PagesRead, services.FormatBytes(s.stats.DiskUsage)),
+	}
+	if s.readingLeft != "" {
+		lines = append(lines, "To read: "+s.readingLeft)
+	}
+	return styles.TextStyle.Render(strings.Join(lines, "\n")) + "\n"
+}
+
+// renderDownloads charts the chapters downloaded per day
+func (s *StatsScreen) renderDownloads() string {
+	if len(s.stats.Downloads) == 0 {
+		return ""
+	}
+	counts := make([]int, len(s.stats.Downloads))
+	total := 0
+	for i, day := range s.stats.Downloads {
+		counts[i] = day.Count
+		total += day.Count
+	}
+
+	var b strings.Builder
+	b.WriteString(styles.SubtitleStyle.Render(fmt.Sprintf("Downloads, last %d days: %d chapters", len(counts), total)))
+	b.WriteString("\n  ")
+	b.WriteString(styles.StatusDownloading.Render(components.Sparkline(counts)))
+	b.WriteString("\n")
+	first, last := s.stats.Downloads[0].Day, s.stats.Downloads[len(counts)-1].Day
+	b.WriteString(styles.MutedStyle.Render(fmt.Sprintf("  %-*s%s", max(len(counts)-5, 6), first.Format("01-02"), last.Format("01-02"))))
+	b.WriteString("\n")
+	return b.String()
+}
+
+// renderMangas lists the manga by disk usage, as many as fit
+func (s *StatsScreen) renderMangas() string {
+	var b strings.Builder
+	b.WriteString(styles.SubtitleStyle.Render("Disk usage by manga"))
+	b.WriteString("\n")
+
+	rows := max(s.height-18, 3)
+	first := max(0, min(s.selected-rows+1, len(s.stats.PerManga)-rows))
+	last := min(first+rows, len(s.stats.PerManga))
+	nameWidth := max(min(s.width-50, 40), 12)
+	for i := first; i < last; i++ {
+		manga := s.stats.PerManga[i]
+		line := fmt.Sprintf("%-*s %4d/%-4d read %9s %s", nameWidth, truncateName(manga.Name, nameWidth),
+			manga.Read, manga.Chapters, services.FormatBytes(manga.DiskUsage),
+			components.HorizontalBar(manga.DiskUsage, s.stats.DiskUsage, 16))
+		if i == s.selected {
+			b.WriteString(styles.SelectedStyle.Render(line))
+		} else {
+			b.WriteString(styles.TextStyle.Render("  " + line))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// truncateName shortens name to width characters
+func truncateName(name string, width int) string {
+	if runes := []rune(name); len(runes) > width {
+		return string(runes[:width-3]) + "..."
+	}
+	return name
+}
+
+// Messages
+type statsLoadedMsg struct {
+	stats       *data.LibraryStats
+	readingLeft string
+	err         error
+}
+
+// Commands
+func (s *StatsScreen) load() tea.Msg {
+	stats, err := s.repo.GetLibraryStats(statsDays)
+	if err != nil {
+		return statsLoadedMsg{err: err}
+	}
+
+	msg := statsLoadedMsg{stats: stats}
+	if backlog, err := s.repo.GetReadingBacklog(""); err == nil {
+		speed, err := services.LoadReadingSpeed(s.repo)
+		if err != nil {
+			speed = services.DefaultReadingSpeed
+		}
+		msg.readingLeft = services.ReadingSummary(backlog, speed)
+	}
+	return msg
+}
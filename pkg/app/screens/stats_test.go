@@ -0,0 +1,40 @@
+package screens
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+func TestStatsScreen(t *testing.T) {
+	s := NewStatsScreen(nil)
+	s.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	today := time.Now()
+	s.Update(statsLoadedMsg{
+		stats: &data.LibraryStats{
+			Mangas: 2, Chapters: 30, Downloaded: 12, Read: 5, PagesRead: 420, DiskUsage: 3 << 20,
+			PerManga: []*data.MangaStats{
+				{MangaID: "m1", Name: "Big Manga", Chapters: 20, Downloaded: 10, Read: 5, DiskUsage: 2 << 20},
+				{MangaID: "m2", Name: "Small Manga", Chapters: 10, Downloaded: 2, DiskUsage: 1 << 20},
+			},
+			Downloads: []*data.DayCount{{Day: today.AddDate(0, 0, -1), Count: 2}, {Day: today, Count: 10}},
+		},
+		readingLeft: "7 chapters left, about 1h",
+	})
+
+	view := s.View()
+	for _, want := range []string{"12 downloaded, 5 read", "Disk usage: 3.0 MB", "To read: 7 chapters left, about 1h", "last 2 days: 12 chapters", "▂█", "Small Manga"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected the view to show %q, got:\n%s", want, view)
+		}
+	}
+
+	s.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("j")})
+	_, cmd := s.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if msg := cmd().(SwitchScreenMsg); msg.Screen != "details" || msg.Data != "m2" {
+		t.Errorf("Expected the selected manga to open, got %+v", msg)
+	}
+}
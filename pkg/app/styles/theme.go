@@ -75,6 +75,10 @@ var (
 		Foreground(Error).
 		Bold(true)
 	
+	StatusWarning = lipgloss.NewStyle().
+		Foreground(Warning).
+		Bold(true)
+	
 	// Progress bar styles
 	ProgressBarStyle = lipgloss.NewStyle().
 		Foreground(Primary)
@@ -0,0 +1,238 @@
+// Package chapterselect parses the chapter selections given to --chapters
+// and picks the chapters they name. A selection is a comma-separated list
+// of terms, a chapter is selected when any term matches it:
+//
+//	12        chapter 12, decimals too: 12.5
+//	1-10      chapters 1 to 10, bounds included
+//	20-       chapter 20 and later
+//	-5        up to chapter 5
+//	latest:5  the 5 latest chapters
+//	volume:3  the chapters of volume 3, or of volumes 1 to 3 with volume:1-3
+//
+// Chapter numbers compare numerically, so "010" selects chapter 10.
+package chapterselect
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// termKind is what a term of a selection matches
+type termKind int
+
+const (
+	termNumber termKind = iota // A chapter, or a range of chapters
+	termLatest                 // The latest chapters
+	termVolume                 // A volume, or a range of volumes
+	termName                   // A chapter number that is not numeric
+)
+
+// term is a part of a selection between commas
+type term struct {
+	kind     termKind
+	from, to float64 // Bounds of numbers and volumes, infinite when open
+	count    int     // Chapters of termLatest
+	name     string  // Normalized number of termName
+}
+
+// Selection is a parsed chapter selection. The zero Selection selects every
+// chapter.
+type Selection struct {
+	terms []term
+	text  string
+}
+
+// Parse parses a chapter selection, "" selecting every chapter
+func Parse(selection string) (Selection, error) {
+	s := Selection{text: strings.TrimSpace(selection)}
+	if s.text == "" {
+		return s, nil
+	}
+	for _, part := range strings.Split(s.text, ",") {
+		t, err := parseTerm(strings.TrimSpace(part))
+		if err != nil {
+			return Selection{}, err
+		}
+		s.terms = append(s.terms, t)
+	}
+	return s, nil
+}
+
+func parseTerm(part string) (term, error) {
+	if part == "" {
+		return term{}, fmt.Errorf("invalid chapter selection: empty term")
+	}
+	keyword, value, hasKeyword := strings.Cut(part, ":")
+	if hasKeyword {
+		switch strings.ToLower(strings.TrimSpace(keyword)) {
+		case "latest", "last":
+			count, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || count <= 0 {
+				return term{}, fmt.Errorf("invalid chapter selection %q: latest takes a number of chapters", part)
+			}
+			return term{kind: termLatest, count: count}, nil
+		case "volume", "vol", "v":
+			from, to, err := parseBounds(strings.TrimSpace(value))
+			if err != nil {
+				return term{}, fmt.Errorf("invalid chapter selection %q: %w", part, err)
+			}
+			return term{kind: termVolume, from: from, to: to}, nil
+		}
+		return term{}, fmt.Errorf("invalid chapter selection %q: unknown %s, use latest or volume", part, keyword)
+	}
+
+	from, to, err := parseBounds(part)
+	if err == nil {
+		return term{kind: termNumber, from: from, to: to}, nil
+	}
+	if !strings.Contains(part, "-") && !startsWithDigit(part) {
+		// Chapters numbered "Extra" or "Oneshot" are selected by name
+		return term{kind: termName, name: strings.ToLower(utils.NormalizeChapterNumber(part))}, nil
+	}
+	return term{}, fmt.Errorf("invalid chapter selection %q: %w", part, err)
+}
+
+// parseBounds parses "n", "a-b", "a-" or "-b"
+func parseBounds(value string) (from, to float64, err error) {
+	low, high, isRange := strings.Cut(value, "-")
+	if !isRange {
+		n, ok := number(low)
+		if !ok {
+			return 0, 0, fmt.Errorf("%q is not a number", value)
+		}
+		return n, n, nil
+	}
+
+	from, to = math.Inf(-1), math.Inf(1)
+	low, high = strings.TrimSpace(low), strings.TrimSpace(high)
+	if low == "" && high == "" {
+		return 0, 0, fmt.Errorf("a range needs a start or an end")
+	}
+	if low != "" {
+		n, ok := number(low)
+		if !ok {
+			return 0, 0, fmt.Errorf("%q is not a number", low)
+		}
+		from = n
+	}
+	if high != "" {
+		n, ok := number(high)
+		if !ok {
+			return 0, 0, fmt.Errorf("%q is not a number", high)
+		}
+		to = n
+	}
+	if from > to {
+		return 0, 0, fmt.Errorf("range %s starts after it ends", value)
+	}
+	return from, to, nil
+}
+
+// number parses a chapter or volume number, "Ch. 10.5" included
+func number(value string) (float64, bool) {
+	n, err := strconv.ParseFloat(utils.NormalizeChapterNumber(value), 64)
+	return n, err == nil && !math.IsNaN(n) && !math.IsInf(n, 0)
+}
+
+func startsWithDigit(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
+
+// All reports whether the selection selects every chapter
+func (s Selection) All() bool {
+	return len(s.terms) == 0
+}
+
+// String returns the selection as it was written
+func (s Selection) String() string {
+	return s.text
+}
+
+// Filter returns the chapters of the selection, in their order. The latest
+// chapters are the ones with the highest numbers among chapters.
+func (s Selection) Filter(chapters []*data.Chapter) []*data.Chapter {
+	if s.All() {
+		return chapters
+	}
+
+	latest := s.latestNumbers(chapters)
+	var selected []*data.Chapter
+	for _, ch := range chapters {
+		if s.matches(ch, latest) {
+			selected = append(selected, ch)
+		}
+	}
+	return selected
+}
+
+// latestNumbers returns the numbers of the chapters picked by the latest
+// terms; chapters sharing a number, in several languages, count once
+func (s Selection) latestNumbers(chapters []*data.Chapter) map[float64]bool {
+	count := 0
+	for _, t := range s.terms {
+		if t.kind == termLatest && t.count > count {
+			count = t.count
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+
+	seen := make(map[float64]bool)
+	var numbers []float64
+	for _, ch := range chapters {
+		if n, ok := number(ch.Number); ok && !seen[n] {
+			seen[n] = true
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(numbers)))
+	if len(numbers) > count {
+		numbers = numbers[:count]
+	}
+	latest := make(map[float64]bool, len(numbers))
+	for _, n := range numbers {
+		latest[n] = true
+	}
+	return latest
+}
+
+func (s Selection) matches(ch *data.Chapter, latest map[float64]bool) bool {
+	n, numeric := number(ch.Number)
+	for _, t := range s.terms {
+		switch t.kind {
+		case termNumber:
+			if numeric && n >= t.from && n <= t.to {
+				return true
+			}
+		case termLatest:
+			if numeric && latest[n] {
+				return true
+			}
+		case termVolume:
+			if v, ok := number(ch.Volume); ok && v >= t.from && v <= t.to {
+				return true
+			}
+		case termName:
+			if strings.ToLower(utils.NormalizeChapterNumber(ch.Number)) == t.name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Filter parses selection and returns the chapters it selects
+func Filter(selection string, chapters []*data.Chapter) ([]*data.Chapter, error) {
+	s, err := Parse(selection)
+	if err != nil {
+		return nil, err
+	}
+	return s.Filter(chapters), nil
+}
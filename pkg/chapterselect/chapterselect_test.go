@@ -0,0 +1,70 @@
+package chapterselect
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+func ids(chapters []*data.Chapter) string {
+	var out []string
+	for _, ch := range chapters {
+		out = append(out, ch.ID)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestFilter(t *testing.T) {
+	chapters := []*data.Chapter{
+		{ID: "1", Number: "1", Volume: "1"},
+		{ID: "2", Number: "2", Volume: "1"},
+		{ID: "2.5", Number: "2.5", Volume: "1"},
+		{ID: "3", Number: "003", Volume: "2"},
+		{ID: "10", Number: "10", Volume: "2"},
+		{ID: "10-es", Number: "10", Volume: "2"},
+		{ID: "15", Number: "15", Volume: "3"},
+		{ID: "20", Number: "20"},
+		{ID: "extra", Number: "Extra"},
+	}
+
+	tests := []struct {
+		selection string
+		want      string
+	}{
+		{"", "1,2,2.5,3,10,10-es,15,20,extra"},
+		{"3", "3"},
+		{"2.5", "2.5"},
+		{"1-3", "1,2,2.5,3"},
+		{"1-10,15,20-", "1,2,2.5,3,10,10-es,15,20"},
+		{"-2", "1,2"},
+		{"15-", "15,20"},
+		{"latest:2", "15,20"},
+		{"latest:3", "10,10-es,15,20"},
+		{"volume:2", "3,10,10-es"},
+		{"volume:1-2, 20", "1,2,2.5,3,10,10-es,20"},
+		{"Extra", "extra"},
+		{"30-40", ""},
+	}
+	for _, tt := range tests {
+		selected, err := Filter(tt.selection, chapters)
+		if err != nil {
+			t.Errorf("Filter(%q) error = %v", tt.selection, err)
+			continue
+		}
+		if got := ids(selected); got != tt.want {
+			t.Errorf("Filter(%q) = %s, want %s", tt.selection, got, tt.want)
+		}
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, selection := range []string{"1,,2", "5-1", "-", "1-x", "latest:0", "latest:many", "volume:", "season:2", "2x"} {
+		if _, err := Parse(selection); err == nil {
+			t.Errorf("Parse(%q) should fail", selection)
+		}
+	}
+	if s, _ := Parse("  "); !s.All() {
+		t.Error("Expected an empty selection to select every chapter")
+	}
+}
@@ -0,0 +1,49 @@
+package data
+
+import "strings"
+
+// SaveChapterChecksum records the checksums of a downloaded chapter,
+// replacing the previous record
+func (r *Repository) SaveChapterChecksum(sum *ChapterChecksum) error {
+	_, err := r.exec(`INSERT INTO chapter_checksums (chapter_id, path, sha256, size, pages, recorded_at)
+		VALUES (?, ?, ?, ?, ?, now())
+		ON CONFLICT (chapter_id) DO UPDATE SET
+			path = excluded.path,
+			sha256 = excluded.sha256,
+			size = excluded.size,
+			pages = excluded.pages,
+			recorded_at = excluded.recorded_at`,
+		sum.ChapterID, sum.Path, sum.SHA256, sum.Size, strings.Join(sum.Pages, " "))
+	return err
+}
+
+// GetChapterChecksums returns the checksums recorded for the chapters of a
+// manga, by chapter ID
+func (r *Repository) GetChapterChecksums(mangaID string) (map[string]*ChapterChecksum, error) {
+	rows, err := r.db.Query(`SELECT s.chapter_id, s.path, s.sha256, s.size, s.pages, s.recorded_at
+		FROM chapter_checksums s JOIN chapters c ON c.id = s.chapter_id
+		WHERE c.manga_id = ?`, mangaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sums := make(map[string]*ChapterChecksum)
+	for rows.Next() {
+		sum := &ChapterChecksum{}
+		var pages string
+		if err := rows.Scan(&sum.ChapterID, &sum.Path, &sum.SHA256, &sum.Size, &pages, &sum.RecordedAt); err != nil {
+			return nil, err
+		}
+		sum.Pages = strings.Fields(pages)
+		sums[sum.ChapterID] = sum
+	}
+	return sums, rows.Err()
+}
+
+// DeleteChapterChecksum forgets the checksums of a chapter, e.g. once its
+// file is gone
+func (r *Repository) DeleteChapterChecksum(chapterID string) error {
+	_, err := r.exec(`DELETE FROM chapter_checksums WHERE chapter_id = ?`, chapterID)
+	return err
+}
@@ -0,0 +1,157 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// LastVisitKey is the state key holding when the TUI was last opened
+const LastVisitKey = "last_visit"
+
+// GetState returns a value the application keeps between runs, "" when it
+// was never set
+func (r *Repository) GetState(key string) (string, error) {
+	var value string
+	err := r.db.QueryRow(`SELECT value FROM app_state WHERE key = ?`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return value, err
+}
+
+// SetState stores a value the application keeps between runs
+func (r *Repository) SetState(key, value string) error {
+	_, err := r.exec(`INSERT INTO app_state (key, value) VALUES (?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value`, key, value)
+	return err
+}
+
+// ContinueReading returns up to limit mangas being read, most recently read
+// first, each with the chapter to read next. Mangas with every chapter read
+// are left out.
+func (r *Repository) ContinueReading(limit int) ([]*MangaChapter, error) {
+	rows, err := r.db.Query(`SELECT ` + mangaColumns + ` FROM mangas m
+		JOIN (SELECT manga_id, max(read_at) AS last_read FROM chapters WHERE read_at IS NOT NULL GROUP BY manga_id) r
+			ON r.manga_id = m.id
+		ORDER BY r.last_read DESC`)
+	if err != nil {
+		return nil, err
+	}
+	mangas, err := scanMangas(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var reading []*MangaChapter
+	for _, manga := range mangas {
+		if len(reading) == limit {
+			break
+		}
+		chapters, err := r.GetChapters(manga.ID)
+		if err != nil {
+			return nil, err
+		}
+		if next := nextChapter(chapters); next != nil {
+			reading = append(reading, &MangaChapter{Manga: manga, Chapter: next})
+		}
+	}
+	return reading, nil
+}
+
+// nextChapter picks the chapter to read next: the last one read when it was
+// left unfinished, else the first unread one after it, else the first unread
+// one at all
+func nextChapter(chapters []*Chapter) *Chapter {
+	last := -1
+	for i, chapter := range chapters {
+		if !chapter.ReadAt.IsZero() && (last < 0 || chapter.ReadAt.After(chapters[last].ReadAt)) {
+			last = i
+		}
+	}
+	if last >= 0 && !chapters[last].Read {
+		return chapters[last]
+	}
+	for i := last + 1; i < len(chapters); i++ {
+		if !chapters[i].Read {
+			return chapters[i]
+		}
+	}
+	for _, chapter := range chapters {
+		if !chapter.Read {
+			return chapter
+		}
+	}
+	return nil
+}
+
+// RecentlyAddedMangas returns up to limit mangas, the last added to the
+// library first
+func (r *Repository) RecentlyAddedMangas(limit int) ([]*Manga, error) {
+	rows, err := r.db.Query(`SELECT `+mangaColumns+` FROM mangas m
+		WHERE m.added_at IS NOT NULL
+		ORDER BY m.added_at DESC, m.name
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanMangas(rows)
+}
+
+// ListRecent returns up to limit mangas, the last updated first: their
+// details changed, a chapter was found or downloaded
+func (r *Repository) ListRecent(limit int) ([]*Manga, error) {
+	rows, err := r.db.Query(`SELECT `+mangaColumns+` FROM mangas m
+		ORDER BY `+mangaUpdatedAt+` DESC NULLS LAST, m.name
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanMangas(rows)
+}
+
+// NewChaptersSince returns up to limit unread chapters found after since,
+// newest first. Chapters of mangas added after since are left out, they are
+// all new.
+func (r *Repository) NewChaptersSince(since time.Time, limit int) ([]*MangaChapter, error) {
+	rows, err := r.db.Query(`SELECT c.id, c.manga_id, c.title, c.language, c.volume, c.number, c.downloaded, c.file_path
+		FROM chapters c JOIN mangas m ON m.id = c.manga_id
+		WHERE c.added_at > ? AND NOT COALESCE(c.read, false)
+			AND (m.added_at IS NULL OR m.added_at <= ?)
+		ORDER BY c.added_at DESC, c.manga_id
+		LIMIT ?`, since, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	var chapters []*Chapter
+	for rows.Next() {
+		chapter := &Chapter{}
+		if err := rows.Scan(&chapter.ID, &chapter.MangaID, &chapter.Title, &chapter.Language, &chapter.Volume,
+			&chapter.Number, &chapter.Downloaded, &chapter.FilePath); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		chapters = append(chapters, chapter)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	mangas := make(map[string]*Manga)
+	updates := make([]*MangaChapter, 0, len(chapters))
+	for _, chapter := range chapters {
+		manga := mangas[chapter.MangaID]
+		if manga == nil {
+			if manga, err = r.GetManga(chapter.MangaID); err != nil {
+				return nil, err
+			}
+			mangas[chapter.MangaID] = manga
+		}
+		updates = append(updates, &MangaChapter{Manga: manga, Chapter: chapter})
+	}
+	return updates, nil
+}
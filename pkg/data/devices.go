@@ -0,0 +1,35 @@
+package data
+
+// SaveDeviceSync records a chapter copied to a device, replacing the
+// previous record of the chapter on that device
+func (r *Repository) SaveDeviceSync(sync *DeviceSync) error {
+	_, err := r.exec(`INSERT INTO device_syncs (device_id, chapter_id, source_hash, path, size, synced_at)
+		VALUES (?, ?, ?, ?, ?, now())
+		ON CONFLICT (device_id, chapter_id) DO UPDATE SET
+			source_hash = excluded.source_hash,
+			path = excluded.path,
+			size = excluded.size,
+			synced_at = excluded.synced_at`,
+		sync.DeviceID, sync.ChapterID, sync.SourceHash, sync.Path, sync.Size)
+	return err
+}
+
+// GetDeviceSyncs returns the chapters copied to a device, by chapter ID
+func (r *Repository) GetDeviceSyncs(deviceID string) (map[string]*DeviceSync, error) {
+	rows, err := r.db.Query(`SELECT device_id, chapter_id, source_hash, path, size, synced_at
+		FROM device_syncs WHERE device_id = ?`, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	syncs := make(map[string]*DeviceSync)
+	for rows.Next() {
+		sync := &DeviceSync{}
+		if err := rows.Scan(&sync.DeviceID, &sync.ChapterID, &sync.SourceHash, &sync.Path, &sync.Size, &sync.SyncedAt); err != nil {
+			return nil, err
+		}
+		syncs[sync.ChapterID] = sync
+	}
+	return syncs, rows.Err()
+}
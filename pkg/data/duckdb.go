@@ -1,11 +1,15 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	_ "github.com/marcboeker/go-duckdb/v2"
 )
@@ -51,6 +55,96 @@ func createTables(db *sql.DB) error {
 			file_path VARCHAR
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_chapters_manga_id ON chapters(manga_id)`,
+		`CREATE TABLE IF NOT EXISTS manga_relations (
+			manga_id VARCHAR NOT NULL,
+			related_id VARCHAR NOT NULL,
+			name VARCHAR,
+			relation VARCHAR NOT NULL,
+			PRIMARY KEY (manga_id, related_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS collection_mangas (
+			collection VARCHAR NOT NULL,
+			manga_id VARCHAR NOT NULL,
+			PRIMARY KEY (collection, manga_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS manga_tags (
+			manga_id VARCHAR NOT NULL,
+			tag VARCHAR NOT NULL,
+			position INTEGER NOT NULL,
+			PRIMARY KEY (manga_id, tag)
+		)`,
+		`CREATE TABLE IF NOT EXISTS manga_people (
+			manga_id VARCHAR NOT NULL,
+			name VARCHAR NOT NULL,
+			role VARCHAR NOT NULL,
+			position INTEGER NOT NULL,
+			PRIMARY KEY (manga_id, role, name)
+		)`,
+		`CREATE TABLE IF NOT EXISTS blocklist (
+			kind VARCHAR NOT NULL,
+			value VARCHAR NOT NULL,
+			note VARCHAR DEFAULT '',
+			PRIMARY KEY (kind, value)
+		)`,
+		// Sessions of older releases, moved to files on first use
+		`CREATE TABLE IF NOT EXISTS source_sessions (
+			source VARCHAR PRIMARY KEY,
+			session VARCHAR NOT NULL,
+			updated_at TIMESTAMP DEFAULT current_timestamp
+		)`,
+		`CREATE TABLE IF NOT EXISTS download_queue (
+			chapter_id VARCHAR PRIMARY KEY,
+			manga_id VARCHAR NOT NULL,
+			position INTEGER NOT NULL,
+			status VARCHAR NOT NULL DEFAULT 'queued',
+			error VARCHAR DEFAULT '',
+			added_at TIMESTAMP DEFAULT current_timestamp,
+			updated_at TIMESTAMP DEFAULT current_timestamp
+		)`,
+		`CREATE TABLE IF NOT EXISTS device_syncs (
+			device_id VARCHAR NOT NULL,
+			chapter_id VARCHAR NOT NULL,
+			source_hash VARCHAR NOT NULL,
+			path VARCHAR NOT NULL,
+			size BIGINT DEFAULT 0,
+			synced_at TIMESTAMP DEFAULT current_timestamp,
+			PRIMARY KEY (device_id, chapter_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS source_stats (
+			source VARCHAR NOT NULL,
+			day DATE NOT NULL,
+			requests BIGINT DEFAULT 0,
+			failures BIGINT DEFAULT 0,
+			latency_ms BIGINT DEFAULT 0,
+			bytes BIGINT DEFAULT 0,
+			PRIMARY KEY (source, day)
+		)`,
+		`CREATE TABLE IF NOT EXISTS chapter_checksums (
+			chapter_id VARCHAR PRIMARY KEY,
+			path VARCHAR NOT NULL,
+			sha256 VARCHAR NOT NULL,
+			size BIGINT DEFAULT 0,
+			pages VARCHAR DEFAULT '',
+			recorded_at TIMESTAMP DEFAULT current_timestamp
+		)`,
+		`CREATE TABLE IF NOT EXISTS manga_links (
+			manga_id VARCHAR NOT NULL,
+			source VARCHAR NOT NULL,
+			source_id VARCHAR NOT NULL,
+			name VARCHAR DEFAULT '',
+			PRIMARY KEY (source, source_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS manga_settings (
+			manga_id VARCHAR PRIMARY KEY,
+			language VARCHAR DEFAULT '',
+			scan_group VARCHAR DEFAULT '',
+			format VARCHAR DEFAULT '',
+			bundle_mode VARCHAR DEFAULT ''
+		)`,
+		`CREATE TABLE IF NOT EXISTS app_state (
+			key VARCHAR PRIMARY KEY,
+			value VARCHAR NOT NULL
+		)`,
 	}
 
 	for _, query := range queries {
@@ -59,61 +153,300 @@ func createTables(db *sql.DB) error {
 		}
 	}
 
+	// Columns added after the initial schema, applied to existing databases
+	migrations := []string{
+		`ALTER TABLE mangas ADD COLUMN IF NOT EXISTS url VARCHAR DEFAULT ''`,
+		`ALTER TABLE chapters ADD COLUMN IF NOT EXISTS url VARCHAR DEFAULT ''`,
+		`ALTER TABLE chapters ADD COLUMN IF NOT EXISTS read BOOLEAN DEFAULT false`,
+		`ALTER TABLE chapters ADD COLUMN IF NOT EXISTS last_read_page INTEGER DEFAULT 0`,
+		`ALTER TABLE chapters ADD COLUMN IF NOT EXISTS read_at TIMESTAMP`,
+		`ALTER TABLE mangas ADD COLUMN IF NOT EXISTS year INTEGER DEFAULT 0`,
+		`ALTER TABLE mangas ADD COLUMN IF NOT EXISTS publication_status VARCHAR DEFAULT ''`,
+		`ALTER TABLE manga_tags ADD COLUMN IF NOT EXISTS genre BOOLEAN DEFAULT false`,
+		`ALTER TABLE mangas ADD COLUMN IF NOT EXISTS reading_status VARCHAR DEFAULT ''`,
+		`ALTER TABLE chapters ADD COLUMN IF NOT EXISTS number_locked BOOLEAN DEFAULT false`,
+		`ALTER TABLE mangas ADD COLUMN IF NOT EXISTS added_at TIMESTAMP`,
+		`ALTER TABLE chapters ADD COLUMN IF NOT EXISTS added_at TIMESTAMP`,
+		`ALTER TABLE mangas ADD COLUMN IF NOT EXISTS custom_cover VARCHAR DEFAULT ''`,
+		`ALTER TABLE mangas ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP`,
+		`ALTER TABLE chapters ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP`,
+		`ALTER TABLE chapters ADD COLUMN IF NOT EXISTS source VARCHAR DEFAULT ''`,
+		`ALTER TABLE chapters ADD COLUMN IF NOT EXISTS downloaded_at TIMESTAMP`,
+		// Chapters downloaded before downloaded_at was recorded date from
+		// their checksums
+		`UPDATE chapters SET downloaded_at = s.recorded_at
+			FROM chapter_checksums s
+			WHERE s.chapter_id = chapters.id AND chapters.downloaded AND chapters.downloaded_at IS NULL`,
+	}
+
+	for _, query := range migrations {
+		if _, err := db.Exec(query); err != nil {
+			return fmt.Errorf("failed to migrate table: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// Repository is the library stored in DuckDB. It is safe for concurrent use,
+// database/sql pools the connections.
 type Repository struct {
-	db *sql.DB
+	db          *sql.DB
+	owned       bool    // Opened by OpenDuckDBRepository, closed by Close
+	sessionsDir string  // Login sessions, kept out of the database, see SaveSession
+	tx          *sql.Tx // Transaction the writes join, see inTransaction
 }
 
-var duckDB *sql.DB
+// DBPathEnv names the environment variable overriding the location of the
+// library database
+const DBPathEnv = "MANGAS_DB"
+
+var (
+	sharedMu   sync.Mutex // Guards duckDB, duckDBPath and dbPath
+	duckDB     *sql.DB
+	duckDBPath string // Where duckDB was opened
+	dbPath     string // Set by SetDBPath
+)
+
+// SetDBPath makes NewDuckDBRepository use the database at path instead of the
+// default one. A shared database already opened elsewhere is closed, so the
+// repositories created from it must not be used anymore.
+func SetDBPath(path string) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	dbPath = path
+	if duckDB != nil {
+		duckDB.Close()
+		duckDB = nil
+	}
+}
+
+// DefaultDBPath returns the location of the library database: the path given
+// to SetDBPath, then $MANGAS_DB, then ~/.mangas/mangas.db
+func DefaultDBPath() (string, error) {
+	sharedMu.Lock()
+	path := dbPath
+	sharedMu.Unlock()
+	return defaultDBPath(path)
+}
+
+// defaultDBPath resolves the database location with path set by SetDBPath
+func defaultDBPath(dbPath string) (string, error) {
+	if dbPath != "" {
+		return dbPath, nil
+	}
+	if path := os.Getenv(DBPathEnv); path != "" {
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".mangas", "mangas.db"), nil
+}
 
+// NewDuckDBRepository returns a repository on the shared library database,
+// opened at DefaultDBPath on first use. It may be called from any goroutine.
 func NewDuckDBRepository() *Repository {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
 	if duckDB == nil {
-		homeDir, err := os.UserHomeDir()
+		dbPath, err := defaultDBPath(dbPath)
 		if err != nil {
 			log.Fatal(err)
 		}
-		dbPath := filepath.Join(homeDir, ".mangas", "mangas.db")
 
 		db, err := InitDuckDB(dbPath)
 		if err != nil {
 			log.Fatal(err)
 		}
-		duckDB = db
+		duckDB, duckDBPath = db, dbPath
 	}
 
-	return &Repository{db: duckDB}
+	return &Repository{db: duckDB, sessionsDir: sessionsDir(duckDBPath)}
 }
 
-// SaveManga inserts or updates a manga in the database
+// OpenDuckDBRepository opens a repository on its own database at path,
+// independent of the shared one. Close it once done.
+func OpenDuckDBRepository(path string) (*Repository, error) {
+	db, err := InitDuckDB(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+	}
+	return &Repository{db: db, owned: true, sessionsDir: sessionsDir(path)}, nil
+}
+
+// sessionsDir returns where the login sessions of the database at dbPath
+// are stored
+func sessionsDir(dbPath string) string {
+	return filepath.Join(filepath.Dir(dbPath), "sessions")
+}
+
+// Close closes a database opened by OpenDuckDBRepository. The shared database
+// stays open for the other repositories.
+func (r *Repository) Close() error {
+	if !r.owned {
+		return nil
+	}
+	return r.db.Close()
+}
+
+// conflictRetries bounds how many times a write conflicting with a
+// concurrent one is tried again
+const conflictRetries = 5
+
+// isConflict reports whether err is DuckDB aborting a write to rows another
+// transaction changed. DuckDB does not wait for the other transaction to end.
+func isConflict(err error) bool {
+	if err == nil {
+		return false
+	}
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "conflict on") || strings.Contains(message, "write-write conflict")
+}
+
+// retryConflicts runs write again, after a short pause, while it conflicts
+// with concurrent writes
+func retryConflicts(write func() error) error {
+	err := write()
+	for attempt := 1; attempt < conflictRetries && isConflict(err); attempt++ {
+		time.Sleep(time.Duration(attempt) * 10 * time.Millisecond)
+		err = write()
+	}
+	return err
+}
+
+// exec runs a write statement, retrying conflicts with concurrent writes
+func (r *Repository) exec(query string, args ...any) (sql.Result, error) {
+	if r.tx != nil {
+		return r.tx.Exec(query, args...)
+	}
+	var result sql.Result
+	err := retryConflicts(func() (err error) {
+		result, err = r.db.Exec(query, args...)
+		return err
+	})
+	return result, err
+}
+
+// transaction runs write in a transaction, committed when it returns nil,
+// and runs it again when it conflicts with concurrent writes. Within
+// inTransaction, write joins the outer transaction instead.
+func (r *Repository) transaction(write func(tx *sql.Tx) error) error {
+	if r.tx != nil {
+		return write(r.tx)
+	}
+	return retryConflicts(func() error {
+		tx, err := r.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		if err := write(tx); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// inTransaction runs write with a repository whose writes all happen in
+// one transaction, committed when write returns nil and rolled back
+// otherwise. Reads outside of it don't see its writes until committed.
+func (r *Repository) inTransaction(write func(r *Repository) error) error {
+	return r.transaction(func(tx *sql.Tx) error {
+		return write(&Repository{db: r.db, sessionsDir: r.sessionsDir, tx: tx})
+	})
+}
+
+// SaveManga inserts or updates a manga in the database. Metadata the
+// manga comes without (URL, tags, people, year, reading status) keeps its
+// stored value. The time a manga was first saved is kept as when it was
+// added to the library, and the time its details last changed as when it
+// was updated.
 func (r *Repository) SaveManga(manga *Manga) error {
-	query := `INSERT INTO mangas (id, name, description, cover_url, source, status)
-		VALUES (?, ?, ?, ?, ?, ?)
+	query := `INSERT INTO mangas (id, name, description, cover_url, source, status, url, year, publication_status, reading_status, added_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT (id) DO UPDATE SET
+			updated_at = CASE
+				WHEN mangas.name IS DISTINCT FROM excluded.name
+					OR mangas.description IS DISTINCT FROM excluded.description
+					OR mangas.cover_url IS DISTINCT FROM excluded.cover_url
+					OR mangas.status IS DISTINCT FROM excluded.status
+				THEN excluded.updated_at ELSE mangas.updated_at END,
 			name = excluded.name,
 			description = excluded.description,
 			cover_url = excluded.cover_url,
-			status = excluded.status`
+			status = excluded.status,
+			url = CASE WHEN excluded.url = '' THEN mangas.url ELSE excluded.url END,
+			year = CASE WHEN excluded.year = 0 THEN mangas.year ELSE excluded.year END,
+			publication_status = CASE WHEN excluded.publication_status = '' THEN mangas.publication_status ELSE excluded.publication_status END,
+			reading_status = CASE WHEN excluded.reading_status = '' THEN mangas.reading_status ELSE excluded.reading_status END`
 
-	_, err := r.db.Exec(query, manga.ID, manga.Name, manga.Description, manga.CoverURL, manga.Source, manga.Status)
-	return err
+	now := time.Now()
+	_, err := r.exec(query, manga.ID, manga.Name, manga.Description, manga.CoverURL, manga.Source, manga.Status, manga.URL,
+		manga.Year, manga.PublicationStatus, manga.ReadingStatus, now, now)
+	if err != nil {
+		return err
+	}
+
+	// Library entries saved without tags keep the ones fetched earlier
+	if len(manga.Tags) > 0 {
+		if err := r.saveTags(manga.ID, manga.Tags, manga.Genres); err != nil {
+			return err
+		}
+	}
+	if len(manga.Authors) > 0 || len(manga.Artists) > 0 {
+		return r.savePeople(manga.ID, manga.Authors, manga.Artists)
+	}
+	return nil
+}
+
+// saveTags replaces the tags of a manga, flagging the ones that are genres
+func (r *Repository) saveTags(mangaID string, tags, genres []string) error {
+	isGenre := make(map[string]bool, len(genres))
+	for _, genre := range genres {
+		isGenre[genre] = true
+	}
+
+	return r.transaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM manga_tags WHERE manga_id = ?`, mangaID); err != nil {
+			return err
+		}
+		for i, tag := range tags {
+			_, err := tx.Exec(`INSERT INTO manga_tags (manga_id, tag, position, genre) VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+				mangaID, tag, i, isGenre[tag])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// savePeople replaces the authors and artists of a manga
+func (r *Repository) savePeople(mangaID string, authors, artists []string) error {
+	return r.transaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM manga_people WHERE manga_id = ?`, mangaID); err != nil {
+			return err
+		}
+		for role, names := range map[string][]string{"author": authors, "artist": artists} {
+			for i, name := range names {
+				_, err := tx.Exec(`INSERT INTO manga_people (manga_id, name, role, position) VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+					mangaID, name, role, i)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
 }
 
 // GetManga retrieves a manga by ID
 func (r *Repository) GetManga(id string) (*Manga, error) {
-	query := `SELECT id, name, description, cover_url, source, status FROM mangas WHERE id = ?`
-
-	manga := &Manga{}
-	err := r.db.QueryRow(query, id).Scan(
-		&manga.ID,
-		&manga.Name,
-		&manga.Description,
-		&manga.CoverURL,
-		&manga.Source,
-		&manga.Status,
-	)
+	query := `SELECT ` + mangaColumns + ` FROM mangas m WHERE id = ?`
 
+	manga, err := scanManga(r.db.QueryRow(query, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -126,7 +459,7 @@ func (r *Repository) GetManga(id string) (*Manga, error) {
 
 // ListMangas retrieves all mangas from the database
 func (r *Repository) ListMangas() ([]*Manga, error) {
-	query := `SELECT id, name, description, cover_url, source, status FROM mangas ORDER BY name`
+	query := `SELECT ` + mangaColumns + ` FROM mangas m ORDER BY name`
 
 	rows, err := r.db.Query(query)
 	if err != nil {
@@ -134,38 +467,85 @@ func (r *Repository) ListMangas() ([]*Manga, error) {
 	}
 	defer rows.Close()
 
-	var mangas []*Manga
-	for rows.Next() {
-		manga := &Manga{}
-		if err := rows.Scan(
-			&manga.ID,
-			&manga.Name,
-			&manga.Description,
-			&manga.CoverURL,
-			&manga.Source,
-			&manga.Status,
-		); err != nil {
-			return nil, err
-		}
-		mangas = append(mangas, manga)
+	return scanMangas(rows)
+}
+
+// SearchLibrary finds library mangas matching query. Names are matched by
+// substring and by similarity to tolerate typos, descriptions and tags by
+// substring. Results are ordered by relevance, best match first.
+func (r *Repository) SearchLibrary(query string) ([]*Manga, error) {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return r.ListMangas()
 	}
 
-	return mangas, rows.Err()
+	pattern := "%" + escapeLike(query) + "%"
+	sqlQuery := `SELECT ` + mangaColumns + ` FROM (
+			SELECT *,
+				CASE
+					WHEN lower(name) = ? THEN 100
+					WHEN lower(name) LIKE ? ESCAPE '\' THEN 80
+					WHEN lower(name) LIKE ? ESCAPE '\' THEN 60
+					ELSE 0
+				END
+				+ CASE WHEN EXISTS (
+					SELECT 1 FROM manga_tags t WHERE t.manga_id = mangas.id AND lower(t.tag) LIKE ? ESCAPE '\'
+				) THEN 30 ELSE 0 END
+				+ CASE WHEN lower(COALESCE(description, '')) LIKE ? ESCAPE '\' THEN 20 ELSE 0 END
+				+ CASE WHEN jaro_winkler_similarity(lower(name), ?) >= ? THEN 50 ELSE 0 END AS score
+			FROM mangas
+		) m
+		WHERE score > 0
+		ORDER BY score DESC, jaro_winkler_similarity(lower(name), ?) DESC, name`
+
+	rows, err := r.db.Query(sqlQuery,
+		query, escapeLike(query)+"%", pattern, pattern, pattern, query, fuzzyNameThreshold, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanMangas(rows)
+}
+
+// fuzzyNameThreshold is the minimum Jaro-Winkler similarity for a name to
+// match a query it does not contain
+const fuzzyNameThreshold = 0.85
+
+// escapeLike escapes the LIKE wildcards in s
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
 }
 
-// SaveChapter inserts or updates a chapter in the database
+// SaveChapter inserts or updates a chapter in the database, recording when
+// it was first seen, when it was downloaded and when its details or download
+// last changed
 func (r *Repository) SaveChapter(chapter *Chapter) error {
-	query := `INSERT INTO chapters (id, manga_id, title, language, volume, number, downloaded, file_path)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	query := `INSERT INTO chapters (id, manga_id, title, language, volume, number, downloaded, file_path, url, source, added_at, updated_at, downloaded_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CASE WHEN ? THEN ?::TIMESTAMP END)
 		ON CONFLICT (id) DO UPDATE SET
+			downloaded_at = CASE
+				WHEN NOT excluded.downloaded THEN NULL
+				WHEN COALESCE(chapters.downloaded, false) THEN chapters.downloaded_at
+				ELSE excluded.updated_at END,
+			updated_at = CASE
+				WHEN chapters.title IS DISTINCT FROM excluded.title
+					OR chapters.volume IS DISTINCT FROM excluded.volume
+					OR (chapters.number IS DISTINCT FROM excluded.number AND NOT chapters.number_locked)
+					OR chapters.downloaded IS DISTINCT FROM excluded.downloaded
+					OR chapters.file_path IS DISTINCT FROM excluded.file_path
+				THEN excluded.updated_at ELSE chapters.updated_at END,
 			title = excluded.title,
 			language = excluded.language,
 			volume = excluded.volume,
-			number = excluded.number,
+			number = CASE WHEN chapters.number_locked THEN chapters.number ELSE excluded.number END,
 			downloaded = excluded.downloaded,
-			file_path = excluded.file_path`
+			file_path = excluded.file_path,
+			url = CASE WHEN excluded.url = '' THEN chapters.url ELSE excluded.url END,
+			source = CASE WHEN excluded.source = '' THEN chapters.source ELSE excluded.source END`
 
-	_, err := r.db.Exec(query,
+	now := time.Now()
+	_, err := r.exec(query,
 		chapter.ID,
 		chapter.MangaID,
 		chapter.Title,
@@ -174,13 +554,21 @@ func (r *Repository) SaveChapter(chapter *Chapter) error {
 		chapter.Number,
 		chapter.Downloaded,
 		chapter.FilePath,
+		chapter.URL,
+		chapter.Source,
+		now,
+		now,
+		chapter.Downloaded,
+		now,
 	)
 	return err
 }
 
 // GetChapters retrieves all chapters for a manga
 func (r *Repository) GetChapters(mangaID string) ([]*Chapter, error) {
-	query := `SELECT id, manga_id, title, language, volume, number, downloaded, file_path 
+	query := `SELECT id, manga_id, title, language, volume, number, downloaded, file_path, url,
+			COALESCE(read, false), COALESCE(last_read_page, 0), read_at, COALESCE(number_locked, false),
+			added_at, COALESCE(updated_at, added_at), COALESCE(source, '')
 		FROM chapters 
 		WHERE manga_id = ? 
 		ORDER BY CAST(NULLIF(volume, '') AS INTEGER), CAST(NULLIF(number, '') AS DECIMAL)`
@@ -194,6 +582,7 @@ func (r *Repository) GetChapters(mangaID string) ([]*Chapter, error) {
 	var chapters []*Chapter
 	for rows.Next() {
 		chapter := &Chapter{}
+		var readAt, addedAt, updatedAt sql.NullTime
 		if err := rows.Scan(
 			&chapter.ID,
 			&chapter.MangaID,
@@ -203,32 +592,242 @@ func (r *Repository) GetChapters(mangaID string) ([]*Chapter, error) {
 			&chapter.Number,
 			&chapter.Downloaded,
 			&chapter.FilePath,
+			&chapter.URL,
+			&chapter.Read,
+			&chapter.LastReadPage,
+			&readAt,
+			&chapter.NumberLocked,
+			&addedAt,
+			&updatedAt,
+			&chapter.Source,
 		); err != nil {
 			return nil, err
 		}
+		chapter.ReadAt = readAt.Time
+		chapter.AddedAt = addedAt.Time
+		chapter.UpdatedAt = updatedAt.Time
 		chapters = append(chapters, chapter)
 	}
 
 	return chapters, rows.Err()
 }
 
-// UpdateChapterStatus updates the download status of a chapter
+// SetCustomCover sets the cover shown for a manga instead of the one of its
+// source, a local path or URL; "" goes back to the source cover. The custom
+// cover is not touched by SaveManga, so it survives updates.
+func (r *Repository) SetCustomCover(mangaID, cover string) error {
+	result, err := r.exec(`UPDATE mangas SET custom_cover = ? WHERE id = ?`, cover, mangaID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("manga not found: %s", mangaID)
+	}
+	return nil
+}
+
+// SetChapterNumber changes the number of a chapter. A locked number is kept
+// when the chapter is saved again from its source, so manual fixes survive
+// updates; unlocking lets the source overwrite it again.
+func (r *Repository) SetChapterNumber(chapterID, number string, locked bool) error {
+	result, err := r.exec(`UPDATE chapters SET number = ?, number_locked = ? WHERE id = ?`, number, locked, chapterID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("chapter not found: %s", chapterID)
+	}
+	return nil
+}
+
+// UpdateChapterStatus updates the download status of a chapter, recording
+// it as updated and when it became downloaded; a downloaded chapter whose
+// book is moved or written again keeps its download time
 func (r *Repository) UpdateChapterStatus(chapterID string, downloaded bool, filePath string) error {
-	query := `UPDATE chapters SET downloaded = ?, file_path = ? WHERE id = ?`
-	_, err := r.db.Exec(query, downloaded, filePath, chapterID)
+	query := `UPDATE chapters SET
+			downloaded_at = CASE
+				WHEN NOT ? THEN NULL
+				WHEN COALESCE(downloaded, false) THEN downloaded_at
+				ELSE ?::TIMESTAMP END,
+			downloaded = ?, file_path = ?, updated_at = ?
+		WHERE id = ?`
+	now := time.Now()
+	_, err := r.exec(query, downloaded, now, downloaded, filePath, now, chapterID)
 	return err
 }
 
+// MoveChapterFiles records that downloaded books moved, from the keys of
+// paths to their values, for the chapters in them and their checksums, in
+// one transaction. The chapters moved are recorded as updated.
+func (r *Repository) MoveChapterFiles(paths map[string]string) error {
+	now := time.Now()
+	return r.transaction(func(tx *sql.Tx) error {
+		for from, to := range paths {
+			if _, err := tx.Exec(`UPDATE chapters SET file_path = ?, updated_at = ? WHERE file_path = ?`, to, now, from); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`UPDATE chapter_checksums SET path = ? WHERE path = ?`, to, from); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UpgradeLegacyRows fills the text columns left NULL by rows written before
+// they were always set, which the queries read as strings. It returns the
+// number of rows upgraded; timestamps stay NULL, rows without them count as
+// added before the library recorded when.
+func (r *Repository) UpgradeLegacyRows() (int, error) {
+	queries := []string{
+		`UPDATE mangas SET
+			description = COALESCE(description, ''),
+			cover_url = COALESCE(cover_url, ''),
+			status = COALESCE(status, ''),
+			url = COALESCE(url, '')
+		WHERE description IS NULL OR cover_url IS NULL OR status IS NULL OR url IS NULL`,
+		`UPDATE chapters SET
+			title = COALESCE(title, ''),
+			language = COALESCE(language, ''),
+			volume = COALESCE(volume, ''),
+			number = COALESCE(number, ''),
+			downloaded = COALESCE(downloaded, false),
+			file_path = COALESCE(file_path, ''),
+			url = COALESCE(url, '')
+		WHERE title IS NULL OR language IS NULL OR volume IS NULL OR number IS NULL
+			OR downloaded IS NULL OR file_path IS NULL OR url IS NULL`,
+	}
+
+	upgraded := 0
+	err := r.transaction(func(tx *sql.Tx) error {
+		upgraded = 0
+		for _, query := range queries {
+			result, err := tx.Exec(query)
+			if err != nil {
+				return err
+			}
+			rows, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			upgraded += int(rows)
+		}
+		return nil
+	})
+	return upgraded, err
+}
+
+// MarkChapterRead marks a chapter as read, or resets its progress when read is false
+func (r *Repository) MarkChapterRead(chapterID string, read bool) error {
+	if !read {
+		_, err := r.exec(`UPDATE chapters SET read = false, last_read_page = 0, read_at = NULL WHERE id = ?`, chapterID)
+		return err
+	}
+	// The clock of the process orders updates made within the same millisecond
+	_, err := r.exec(`UPDATE chapters SET read = true, read_at = ? WHERE id = ?`, time.Now(), chapterID)
+	return err
+}
+
+// SetReadProgress records the last page read in a chapter
+func (r *Repository) SetReadProgress(chapterID string, page int) error {
+	_, err := r.exec(`UPDATE chapters SET last_read_page = ?, read_at = ? WHERE id = ?`, page, time.Now(), chapterID)
+	return err
+}
+
+// GetReadingProgress summarizes the reading progress of a manga
+func (r *Repository) GetReadingProgress(mangaID string) (*ReadingProgress, error) {
+	chapters, err := r.GetChapters(mangaID)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := &ReadingProgress{Total: len(chapters)}
+	for _, ch := range chapters {
+		if ch.Read {
+			progress.Read++
+		}
+		if !ch.ReadAt.IsZero() && (progress.LastRead == nil || ch.ReadAt.After(progress.LastRead.ReadAt)) {
+			progress.LastRead = ch
+		}
+	}
+	return progress, nil
+}
+
+// GetReadingBacklog counts the unread downloaded chapters of a manga, or of
+// the whole library when mangaID is empty, and the pages left in them
+func (r *Repository) GetReadingBacklog(mangaID string) (*ReadingBacklog, error) {
+	query := `WITH downloaded AS (
+			SELECT c.manga_id, COALESCE(c.read, false) AS read, COALESCE(c.last_read_page, 0) AS last_read_page,
+				` + chapterPages + ` AS pages
+			FROM chapters c LEFT JOIN chapter_checksums s ON s.chapter_id = c.id
+			WHERE c.downloaded
+		)
+		SELECT
+			count(*) FILTER (WHERE NOT read AND (? = '' OR manga_id = ?)),
+			COALESCE(sum(greatest(pages - last_read_page, 0)) FILTER (WHERE NOT read AND (? = '' OR manga_id = ?)), 0),
+			count(*) FILTER (WHERE NOT read AND pages IS NULL AND (? = '' OR manga_id = ?)),
+			COALESCE(avg(pages), 0)
+		FROM downloaded`
+
+	backlog := &ReadingBacklog{}
+	err := r.db.QueryRow(query, mangaID, mangaID, mangaID, mangaID, mangaID, mangaID).Scan(
+		&backlog.Chapters, &backlog.Pages, &backlog.Unknown, &backlog.AveragePages)
+	if err != nil {
+		return nil, err
+	}
+	return backlog, nil
+}
+
 // DeleteManga removes a manga and all its chapters
 func (r *Repository) DeleteManga(id string) error {
+	_, err := r.exec(`DELETE FROM chapter_checksums WHERE chapter_id IN (SELECT id FROM chapters WHERE manga_id = ?)`, id)
+	if err != nil {
+		return err
+	}
+
 	// Delete chapters first (no foreign key constraint from chapters to mangas)
-	_, err := r.db.Exec(`DELETE FROM chapters WHERE manga_id = ?`, id)
+	_, err = r.exec(`DELETE FROM chapters WHERE manga_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.exec(`DELETE FROM manga_relations WHERE manga_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.exec(`DELETE FROM manga_tags WHERE manga_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.exec(`DELETE FROM manga_people WHERE manga_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.exec(`DELETE FROM collection_mangas WHERE manga_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.exec(`DELETE FROM download_queue WHERE manga_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.exec(`DELETE FROM manga_links WHERE manga_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.exec(`DELETE FROM manga_settings WHERE manga_id = ?`, id)
 	if err != nil {
 		return err
 	}
 
 	// Delete manga
-	_, err = r.db.Exec(`DELETE FROM mangas WHERE id = ?`, id)
+	_, err = r.exec(`DELETE FROM mangas WHERE id = ?`, id)
 	if err != nil {
 		return err
 	}
@@ -254,3 +853,506 @@ func (r *Repository) GetMangaWithChapterCount(id string) (*Manga, int, int, erro
 
 	return manga, total, downloaded, nil
 }
+
+// SaveRelations replaces the stored relations of a manga
+func (r *Repository) SaveRelations(mangaID string, relations []*Relation) error {
+	query := `INSERT INTO manga_relations (manga_id, related_id, name, relation)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (manga_id, related_id) DO UPDATE SET
+			name = excluded.name,
+			relation = excluded.relation`
+
+	return r.transaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM manga_relations WHERE manga_id = ?`, mangaID); err != nil {
+			return err
+		}
+		for _, rel := range relations {
+			if _, err := tx.Exec(query, mangaID, rel.RelatedID, rel.Name, rel.Type); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetRelations retrieves the related series of a manga
+func (r *Repository) GetRelations(mangaID string) ([]*Relation, error) {
+	query := `SELECT manga_id, related_id, name, relation
+		FROM manga_relations
+		WHERE manga_id = ?
+		ORDER BY relation, name`
+
+	rows, err := r.db.Query(query, mangaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var relations []*Relation
+	for rows.Next() {
+		rel := &Relation{}
+		if err := rows.Scan(&rel.MangaID, &rel.RelatedID, &rel.Name, &rel.Type); err != nil {
+			return nil, err
+		}
+		relations = append(relations, rel)
+	}
+
+	return relations, rows.Err()
+}
+
+// AddToCollection adds a manga to a named collection, creating it if needed
+func (r *Repository) AddToCollection(collection, mangaID string) error {
+	_, err := r.exec(`INSERT INTO collection_mangas (collection, manga_id)
+		VALUES (?, ?)
+		ON CONFLICT (collection, manga_id) DO NOTHING`, collection, mangaID)
+	return err
+}
+
+// RemoveFromCollection removes a manga from a collection. Empty collections
+// disappear since they only exist through their members.
+func (r *Repository) RemoveFromCollection(collection, mangaID string) error {
+	_, err := r.exec(`DELETE FROM collection_mangas WHERE collection = ? AND manga_id = ?`, collection, mangaID)
+	return err
+}
+
+// ListCollections returns the names of all collections
+func (r *Repository) ListCollections() ([]string, error) {
+	rows, err := r.db.Query(`SELECT DISTINCT collection FROM collection_mangas ORDER BY collection`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var collections []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		collections = append(collections, name)
+	}
+
+	return collections, rows.Err()
+}
+
+// GetCollection retrieves the mangas of a collection, matching its name case-insensitively
+func (r *Repository) GetCollection(collection string) ([]*Manga, error) {
+	query := `SELECT ` + mangaColumns + `
+		FROM mangas m
+		JOIN collection_mangas c ON c.manga_id = m.id
+		WHERE lower(c.collection) = lower(?)
+		ORDER BY m.name`
+
+	rows, err := r.db.Query(query, collection)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanMangas(rows)
+}
+
+// AddToBlocklist blocks groups and uploaders, returning how many were not
+// blocked yet
+func (r *Repository) AddToBlocklist(entries ...BlockedGroup) (int, error) {
+	added := 0
+	for _, entry := range entries {
+		result, err := r.exec(`INSERT INTO blocklist (kind, value, note) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`,
+			entry.Kind, entry.Value, entry.Note)
+		if err != nil {
+			return added, err
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			added++
+		}
+	}
+	return added, nil
+}
+
+// RemoveFromBlocklist unblocks a group or uploader, matching names case-insensitively
+func (r *Repository) RemoveFromBlocklist(kind, value string) (bool, error) {
+	result, err := r.exec(`DELETE FROM blocklist WHERE kind = ? AND lower(value) = lower(?)`, kind, value)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// GetBlocklist returns the blocked groups and uploaders
+func (r *Repository) GetBlocklist() ([]BlockedGroup, error) {
+	rows, err := r.db.Query(`SELECT kind, value, COALESCE(note, '') FROM blocklist ORDER BY kind, lower(value)`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []BlockedGroup
+	for rows.Next() {
+		var entry BlockedGroup
+		if err := rows.Scan(&entry.Kind, &entry.Value, &entry.Note); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// SaveSession stores the login session of a source, replacing the previous
+// one. Sessions hold secrets, so they are written to a file only the user can
+// read rather than to the database, which backups and exports copy around.
+func (r *Repository) SaveSession(source, session string) error {
+	path, err := r.sessionPath(source)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(r.sessionsDir, 0700); err != nil {
+		return fmt.Errorf("failed to create sessions directory: %w", err)
+	}
+	file, err := os.CreateTemp(r.sessionsDir, source+"-*.tmp") // Created 0600
+	if err != nil {
+		return err
+	}
+	_, err = file.WriteString(session)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return r.deleteLegacySession(source)
+}
+
+// GetSession returns the login session of a source, or "" when logged out.
+// A session stored in the database by an older release is moved to its file.
+func (r *Repository) GetSession(source string) (string, error) {
+	path, err := r.sessionPath(source)
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(path)
+	if err == nil {
+		return string(content), nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	var session string
+	err = r.db.QueryRow(`SELECT session FROM source_sessions WHERE source = ?`, source).Scan(&session)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return session, r.SaveSession(source, session)
+}
+
+// DeleteSession forgets the login session of a source
+func (r *Repository) DeleteSession(source string) (bool, error) {
+	path, err := r.sessionPath(source)
+	if err != nil {
+		return false, err
+	}
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	removed := err == nil
+
+	result, err := r.exec(`DELETE FROM source_sessions WHERE source = ?`, source)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return removed || n > 0, err
+}
+
+// sessionPath returns the file holding the session of source
+func (r *Repository) sessionPath(source string) (string, error) {
+	if source == "" || filepath.Base(source) != source || strings.HasPrefix(source, ".") {
+		return "", fmt.Errorf("invalid source name: %q", source)
+	}
+	return filepath.Join(r.sessionsDir, source+".json"), nil
+}
+
+// deleteLegacySession removes a session stored in the database
+func (r *Repository) deleteLegacySession(source string) error {
+	_, err := r.exec(`DELETE FROM source_sessions WHERE source = ?`, source)
+	return err
+}
+
+// EnqueueChapter appends a chapter to the download queue. Chapters already
+// waiting keep their place; finished or failed ones are queued again.
+func (r *Repository) EnqueueChapter(mangaID, chapterID string) error {
+	query := `INSERT INTO download_queue (chapter_id, manga_id, position, status, error)
+		SELECT ?, ?, COALESCE(MAX(position), 0) + 1, 'queued', '' FROM download_queue
+		ON CONFLICT (chapter_id) DO UPDATE SET
+			status = CASE WHEN download_queue.status IN ('done', 'failed') THEN 'queued' ELSE download_queue.status END,
+			error = CASE WHEN download_queue.status IN ('done', 'failed') THEN '' ELSE download_queue.error END,
+			updated_at = now()`
+
+	_, err := r.exec(query, chapterID, mangaID)
+	return err
+}
+
+// ListQueue returns every queue item in processing order
+func (r *Repository) ListQueue() ([]*QueueItem, error) {
+	rows, err := r.db.Query(`SELECT chapter_id, manga_id, position, status, error, added_at, updated_at
+		FROM download_queue
+		ORDER BY position, added_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*QueueItem
+	for rows.Next() {
+		item, err := scanQueueItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// NextQueueItem returns the first queued item, or nil when nothing is waiting
+func (r *Repository) NextQueueItem() (*QueueItem, error) {
+	row := r.db.QueryRow(`SELECT chapter_id, manga_id, position, status, error, added_at, updated_at
+		FROM download_queue
+		WHERE status = 'queued'
+		ORDER BY position, added_at
+		LIMIT 1`)
+
+	item, err := scanQueueItem(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return item, err
+}
+
+// UpdateQueueItem sets the status and error message of a queue item
+func (r *Repository) UpdateQueueItem(chapterID, status, errMsg string) error {
+	_, err := r.exec(`UPDATE download_queue SET status = ?, error = ?, updated_at = now()
+		WHERE chapter_id = ?`, status, errMsg, chapterID)
+	return err
+}
+
+// UpdateQueueStatuses moves every item in one of the from statuses to status
+// to, optionally restricted to one manga. It returns the number of items changed.
+func (r *Repository) UpdateQueueStatuses(mangaID, to string, from ...string) (int64, error) {
+	if len(from) == 0 {
+		return 0, nil
+	}
+
+	query := `UPDATE download_queue SET status = ?, error = '', updated_at = now()
+		WHERE status IN (?` + strings.Repeat(", ?", len(from)-1) + `)`
+	args := []interface{}{to}
+	for _, status := range from {
+		args = append(args, status)
+	}
+	if mangaID != "" {
+		query += ` AND manga_id = ?`
+		args = append(args, mangaID)
+	}
+
+	result, err := r.exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// MoveQueueItem moves a queue item to a 1-based position, shifting the others
+func (r *Repository) MoveQueueItem(chapterID string, position int) error {
+	items, err := r.ListQueue()
+	if err != nil {
+		return err
+	}
+
+	index := -1
+	for i, item := range items {
+		if item.ChapterID == chapterID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("chapter %s is not queued", chapterID)
+	}
+
+	moved := items[index]
+	items = append(items[:index], items[index+1:]...)
+	target := position - 1
+	if target < 0 {
+		target = 0
+	}
+	if target > len(items) {
+		target = len(items)
+	}
+	items = append(items[:target], append([]*QueueItem{moved}, items[target:]...)...)
+
+	return r.transaction(func(tx *sql.Tx) error {
+		for i, item := range items {
+			if item.Position == i+1 {
+				continue
+			}
+			if _, err := tx.Exec(`UPDATE download_queue SET position = ? WHERE chapter_id = ?`, i+1, item.ChapterID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ClearQueue removes the items in the given statuses, or every item when none
+// are given. It returns the number of items removed.
+func (r *Repository) ClearQueue(statuses ...string) (int64, error) {
+	query := `DELETE FROM download_queue`
+	var args []interface{}
+	if len(statuses) > 0 {
+		query += ` WHERE status IN (?` + strings.Repeat(", ?", len(statuses)-1) + `)`
+		for _, status := range statuses {
+			args = append(args, status)
+		}
+	}
+
+	result, err := r.exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Backup writes a consistent copy of the whole library database to path,
+// which must not exist yet
+func (r *Repository) Backup(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+
+	// Attached databases belong to the connection, keep every step on one
+	ctx := context.Background()
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var current string
+	if err := conn.QueryRowContext(ctx, `SELECT current_database()`).Scan(&current); err != nil {
+		return err
+	}
+
+	literal := "'" + strings.ReplaceAll(path, "'", "''") + "'"
+	if _, err := conn.ExecContext(ctx, `ATTACH `+literal+` AS library_backup`); err != nil {
+		return fmt.Errorf("failed to create backup database: %w", err)
+	}
+	defer conn.ExecContext(ctx, `DETACH library_backup`)
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf(`COPY FROM DATABASE %q TO library_backup`, current)); err != nil {
+		return fmt.Errorf("failed to copy library: %w", err)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// mangaColumns selects the columns read by scanManga from a mangas table aliased m.
+// Lists are joined with the unit separator (chr(31)).
+const mangaColumns = `m.id, m.name, m.description, m.cover_url, m.source, m.status, m.url,
+	COALESCE(m.year, 0), COALESCE(m.publication_status, ''), COALESCE(m.reading_status, ''),
+	COALESCE(m.custom_cover, ''),
+	(SELECT string_agg(t.tag, chr(31) ORDER BY t.position) FROM manga_tags t WHERE t.manga_id = m.id),
+	(SELECT string_agg(t.tag, chr(31) ORDER BY t.position) FROM manga_tags t WHERE t.manga_id = m.id AND t.genre),
+	(SELECT string_agg(p.name, chr(31) ORDER BY p.position) FROM manga_people p WHERE p.manga_id = m.id AND p.role = 'author'),
+	(SELECT string_agg(p.name, chr(31) ORDER BY p.position) FROM manga_people p WHERE p.manga_id = m.id AND p.role = 'artist'),
+	m.added_at, ` + mangaUpdatedAt
+
+// mangaUpdatedAt is the last time a manga or one of its chapters changed,
+// rows saved before updates were recorded count from when they were added
+const mangaUpdatedAt = `greatest(COALESCE(m.updated_at, m.added_at),
+	(SELECT max(COALESCE(c.updated_at, c.added_at)) FROM chapters c WHERE c.manga_id = m.id))`
+
+func scanManga(row rowScanner) (*Manga, error) {
+	manga := &Manga{}
+	var tags, genres, authors, artists sql.NullString
+	var addedAt, updatedAt sql.NullTime
+	err := row.Scan(
+		&manga.ID,
+		&manga.Name,
+		&manga.Description,
+		&manga.CoverURL,
+		&manga.Source,
+		&manga.Status,
+		&manga.URL,
+		&manga.Year,
+		&manga.PublicationStatus,
+		&manga.ReadingStatus,
+		&manga.CustomCover,
+		&tags,
+		&genres,
+		&authors,
+		&artists,
+		&addedAt,
+		&updatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	manga.AddedAt = addedAt.Time
+	manga.UpdatedAt = updatedAt.Time
+	manga.Tags = splitList(tags)
+	manga.Genres = splitList(genres)
+	manga.Authors = splitList(authors)
+	manga.Artists = splitList(artists)
+	return manga, nil
+}
+
+// splitList splits a list aggregated by mangaColumns
+func splitList(list sql.NullString) []string {
+	if list.String == "" {
+		return nil
+	}
+	return strings.Split(list.String, "\x1f")
+}
+
+func scanMangas(rows *sql.Rows) ([]*Manga, error) {
+	var mangas []*Manga
+	for rows.Next() {
+		manga, err := scanManga(rows)
+		if err != nil {
+			return nil, err
+		}
+		mangas = append(mangas, manga)
+	}
+	return mangas, rows.Err()
+}
+
+func scanQueueItem(row rowScanner) (*QueueItem, error) {
+	item := &QueueItem{}
+	var errMsg sql.NullString
+	err := row.Scan(
+		&item.ChapterID,
+		&item.MangaID,
+		&item.Position,
+		&item.Status,
+		&errMsg,
+		&item.AddedAt,
+		&item.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	item.Error = errMsg.String
+	return item, nil
+}
@@ -1,9 +1,12 @@
 package data
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func setupTestDB(t *testing.T) (*Repository, func()) {
@@ -22,7 +25,7 @@ func setupTestDB(t *testing.T) (*Repository, func()) {
 		t.Fatalf("Failed to init DB: %v", err)
 	}
 
-	repo := &Repository{db: db}
+	repo := &Repository{db: db, sessionsDir: filepath.Join(tmpDir, "sessions")}
 
 	cleanup := func() {
 		db.Close()
@@ -328,3 +331,932 @@ func TestSaveMangaUpsert(t *testing.T) {
 	}
 }
 
+
+func TestSaveAndGetRelations(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	relations := []*Relation{
+		{RelatedID: "manga-2", Name: "The Sequel", Type: "sequel"},
+		{RelatedID: "manga-3", Name: "The Prequel", Type: "prequel"},
+	}
+	if err := repo.SaveRelations("manga-1", relations); err != nil {
+		t.Fatalf("Failed to save relations: %v", err)
+	}
+
+	retrieved, err := repo.GetRelations("manga-1")
+	if err != nil {
+		t.Fatalf("Failed to get relations: %v", err)
+	}
+	if len(retrieved) != 2 {
+		t.Fatalf("Expected 2 relations, got %d", len(retrieved))
+	}
+	if retrieved[0].Type != "prequel" || retrieved[0].MangaID != "manga-1" {
+		t.Errorf("Unexpected first relation: %+v", retrieved[0])
+	}
+
+	// Saving again replaces the previous set
+	if err := repo.SaveRelations("manga-1", relations[:1]); err != nil {
+		t.Fatalf("Failed to replace relations: %v", err)
+	}
+	retrieved, _ = repo.GetRelations("manga-1")
+	if len(retrieved) != 1 {
+		t.Errorf("Expected 1 relation after replace, got %d", len(retrieved))
+	}
+
+	// Deleting the manga removes its relations
+	repo.DeleteManga("manga-1")
+	retrieved, _ = repo.GetRelations("manga-1")
+	if len(retrieved) != 0 {
+		t.Errorf("Expected 0 relations after delete, got %d", len(retrieved))
+	}
+}
+
+func TestSourceURLs(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	manga := &Manga{ID: "manga-1", Name: "Test", Source: "test", URL: "https://example.com/title/manga-1"}
+	if err := repo.SaveManga(manga); err != nil {
+		t.Fatalf("Failed to save manga: %v", err)
+	}
+	chapter := &Chapter{ID: "ch-1", MangaID: "manga-1", Number: "1", URL: "https://example.com/chapter/ch-1"}
+	if err := repo.SaveChapter(chapter); err != nil {
+		t.Fatalf("Failed to save chapter: %v", err)
+	}
+
+	// Saving without a URL keeps the stored one
+	manga.URL = ""
+	repo.SaveManga(manga)
+	chapter.URL = ""
+	repo.SaveChapter(chapter)
+
+	retrieved, _ := repo.GetManga("manga-1")
+	if retrieved.URL != "https://example.com/title/manga-1" {
+		t.Errorf("Expected manga URL to be kept, got '%s'", retrieved.URL)
+	}
+	chapters, _ := repo.GetChapters("manga-1")
+	if len(chapters) != 1 || chapters[0].URL != "https://example.com/chapter/ch-1" {
+		t.Errorf("Expected chapter URL to be kept, got %+v", chapters)
+	}
+}
+
+func TestCollections(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo.SaveManga(&Manga{ID: "manga-1", Name: "Beta", Source: "test"})
+	repo.SaveManga(&Manga{ID: "manga-2", Name: "Alpha", Source: "test"})
+
+	for _, id := range []string{"manga-1", "manga-2", "manga-1"} {
+		if err := repo.AddToCollection("Favorites", id); err != nil {
+			t.Fatalf("Failed to add to collection: %v", err)
+		}
+	}
+	repo.AddToCollection("Reading", "manga-1")
+
+	collections, err := repo.ListCollections()
+	if err != nil {
+		t.Fatalf("Failed to list collections: %v", err)
+	}
+	if len(collections) != 2 || collections[0] != "Favorites" {
+		t.Errorf("Expected [Favorites Reading], got %v", collections)
+	}
+
+	mangas, err := repo.GetCollection("favorites")
+	if err != nil {
+		t.Fatalf("Failed to get collection: %v", err)
+	}
+	if len(mangas) != 2 || mangas[0].Name != "Alpha" {
+		t.Errorf("Expected 2 mangas sorted by name, got %d", len(mangas))
+	}
+
+	repo.RemoveFromCollection("Favorites", "manga-2")
+	mangas, _ = repo.GetCollection("Favorites")
+	if len(mangas) != 1 {
+		t.Errorf("Expected 1 manga after removal, got %d", len(mangas))
+	}
+
+	// Deleting a manga drops it from its collections
+	repo.DeleteManga("manga-1")
+	collections, _ = repo.ListCollections()
+	if len(collections) != 0 {
+		t.Errorf("Expected no collections left, got %v", collections)
+	}
+}
+
+func TestDownloadQueue(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	for _, id := range []string{"ch-1", "ch-2", "ch-3", "ch-1"} {
+		if err := repo.EnqueueChapter("manga-1", id); err != nil {
+			t.Fatalf("Failed to enqueue chapter: %v", err)
+		}
+	}
+
+	items, err := repo.ListQueue()
+	if err != nil {
+		t.Fatalf("Failed to list queue: %v", err)
+	}
+	if len(items) != 3 || items[0].ChapterID != "ch-1" || items[2].ChapterID != "ch-3" {
+		t.Fatalf("Expected ch-1..ch-3 in order, got %d items", len(items))
+	}
+
+	if err := repo.MoveQueueItem("ch-3", 1); err != nil {
+		t.Fatalf("Failed to move queue item: %v", err)
+	}
+	next, err := repo.NextQueueItem()
+	if err != nil || next == nil || next.ChapterID != "ch-3" {
+		t.Fatalf("Expected ch-3 to be next after moving it, got %v (err %v)", next, err)
+	}
+
+	repo.UpdateQueueItem("ch-3", QueueFailed, "boom")
+	repo.UpdateQueueItem("ch-1", QueueDone, "")
+	changed, err := repo.UpdateQueueStatuses("", QueuePaused, QueueQueued)
+	if err != nil || changed != 1 {
+		t.Errorf("Expected 1 item paused, got %d (err %v)", changed, err)
+	}
+	if next, _ := repo.NextQueueItem(); next != nil {
+		t.Errorf("Expected nothing queued while paused, got %s", next.ChapterID)
+	}
+
+	// Re-adding a failed chapter queues it again without moving it
+	repo.EnqueueChapter("manga-1", "ch-3")
+	items, _ = repo.ListQueue()
+	if items[0].ChapterID != "ch-3" || items[0].Status != QueueQueued || items[0].Error != "" {
+		t.Errorf("Expected ch-3 queued again at the front, got %+v", items[0])
+	}
+
+	removed, err := repo.ClearQueue(QueueDone)
+	if err != nil || removed != 1 {
+		t.Errorf("Expected 1 done item cleared, got %d (err %v)", removed, err)
+	}
+
+	repo.DeleteManga("manga-1")
+	items, _ = repo.ListQueue()
+	if len(items) != 0 {
+		t.Errorf("Expected queue emptied with its manga, got %d items", len(items))
+	}
+}
+
+func TestReadingProgress(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo.SaveManga(&Manga{ID: "manga-1", Name: "Test", Source: "test"})
+	for _, id := range []string{"ch-1", "ch-2", "ch-3"} {
+		repo.SaveChapter(&Chapter{ID: id, MangaID: "manga-1", Number: id[3:]})
+	}
+
+	if err := repo.MarkChapterRead("ch-1", true); err != nil {
+		t.Fatalf("Failed to mark chapter read: %v", err)
+	}
+	if err := repo.SetReadProgress("ch-2", 7); err != nil {
+		t.Fatalf("Failed to set read progress: %v", err)
+	}
+
+	chapters, _ := repo.GetChapters("manga-1")
+	if !chapters[0].Read || chapters[0].ReadAt.IsZero() {
+		t.Errorf("Expected ch-1 read with a timestamp, got %+v", chapters[0])
+	}
+	if chapters[1].Read || chapters[1].LastReadPage != 7 {
+		t.Errorf("Expected ch-2 in progress at page 7, got %+v", chapters[1])
+	}
+	if !chapters[2].ReadAt.IsZero() {
+		t.Error("Expected ch-3 never read")
+	}
+
+	// Saving chapters again (e.g. when syncing) keeps the reading progress
+	repo.SaveChapter(&Chapter{ID: "ch-2", MangaID: "manga-1", Number: "2", Title: "Renamed"})
+
+	progress, err := repo.GetReadingProgress("manga-1")
+	if err != nil {
+		t.Fatalf("Failed to get reading progress: %v", err)
+	}
+	if progress.Read != 1 || progress.Total != 3 {
+		t.Errorf("Expected 1/3 read, got %d/%d", progress.Read, progress.Total)
+	}
+	if progress.LastRead == nil || progress.LastRead.ID != "ch-2" || progress.LastRead.LastReadPage != 7 {
+		t.Errorf("Expected ch-2 as last read chapter, got %+v", progress.LastRead)
+	}
+
+	repo.MarkChapterRead("ch-1", false)
+	chapters, _ = repo.GetChapters("manga-1")
+	if chapters[0].Read || !chapters[0].ReadAt.IsZero() {
+		t.Errorf("Expected ch-1 progress reset, got %+v", chapters[0])
+	}
+}
+
+func TestSearchLibrary(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo.SaveManga(&Manga{ID: "m1", Name: "Naruto", Description: "A ninja story", Source: "mangadex", Tags: []string{"Action", "Adventure"}})
+	repo.SaveManga(&Manga{ID: "m2", Name: "Boruto: Naruto Next Generations", Description: "The next generation", Source: "mangadex"})
+	repo.SaveManga(&Manga{ID: "m3", Name: "Yotsuba&!", Description: "Slice of life with a ninja cameo", Source: "mangadex", Tags: []string{"Comedy", "Slice of Life"}})
+	repo.SaveManga(&Manga{ID: "m4", Name: "100%_Pure", Source: "mangadex"})
+
+	names := func(mangas []*Manga) []string {
+		var result []string
+		for _, m := range mangas {
+			result = append(result, m.Name)
+		}
+		return result
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"naruto", []string{"Naruto", "Boruto: Naruto Next Generations"}},
+		{"NARTO", []string{"Naruto"}},
+		{"ninja", []string{"Naruto", "Yotsuba&!"}},
+		{"slice of life", []string{"Yotsuba&!"}},
+		{"%_", []string{"100%_Pure"}},
+		{"berserk", nil},
+	}
+	for _, tt := range tests {
+		results, err := repo.SearchLibrary(tt.query)
+		if err != nil {
+			t.Fatalf("SearchLibrary(%q) failed: %v", tt.query, err)
+		}
+		got := names(results)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("SearchLibrary(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	// Tags are stored with the manga and kept when it is saved again without them
+	repo.SaveManga(&Manga{ID: "m1", Name: "Naruto", Source: "mangadex"})
+	manga, _ := repo.GetManga("m1")
+	if strings.Join(manga.Tags, ",") != "Action,Adventure" {
+		t.Errorf("Expected tags to be kept, got %v", manga.Tags)
+	}
+}
+
+func TestMangaMetadata(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	manga := &Manga{
+		ID:                "m1",
+		Name:              "Metadata",
+		Source:            "mangadex",
+		Tags:              []string{"Action", "Seinen", "Gore"},
+		Genres:            []string{"Action"},
+		Authors:           []string{"Writer One", "Writer Two"},
+		Artists:           []string{"Illustrator"},
+		Year:              1989,
+		PublicationStatus: "ongoing",
+	}
+	if err := repo.SaveManga(manga); err != nil {
+		t.Fatalf("Failed to save manga: %v", err)
+	}
+
+	// Saving the library entry again without metadata keeps it
+	if err := repo.SaveManga(&Manga{ID: "m1", Name: "Metadata", Source: "mangadex", Status: "completed"}); err != nil {
+		t.Fatalf("Failed to save manga: %v", err)
+	}
+
+	retrieved, err := repo.GetManga("m1")
+	if err != nil {
+		t.Fatalf("Failed to get manga: %v", err)
+	}
+	if strings.Join(retrieved.Tags, ",") != "Action,Seinen,Gore" || strings.Join(retrieved.Genres, ",") != "Action" {
+		t.Errorf("Unexpected tags %v and genres %v", retrieved.Tags, retrieved.Genres)
+	}
+	if strings.Join(retrieved.Authors, ",") != "Writer One,Writer Two" || strings.Join(retrieved.Artists, ",") != "Illustrator" {
+		t.Errorf("Unexpected authors %v and artists %v", retrieved.Authors, retrieved.Artists)
+	}
+	if retrieved.Year != 1989 || retrieved.PublicationStatus != "ongoing" || retrieved.Status != "completed" {
+		t.Errorf("Unexpected year %d, publication status %q, status %q", retrieved.Year, retrieved.PublicationStatus, retrieved.Status)
+	}
+
+	// Deleting the manga removes its metadata
+	repo.DeleteManga("m1")
+	repo.SaveManga(&Manga{ID: "m1", Name: "Metadata", Source: "mangadex"})
+	retrieved, _ = repo.GetManga("m1")
+	if len(retrieved.Tags) != 0 || len(retrieved.Authors) != 0 {
+		t.Errorf("Expected metadata to be deleted with the manga, got %+v", retrieved)
+	}
+}
+
+func TestBlocklist(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	added, err := repo.AddToBlocklist(
+		BlockedGroup{Kind: BlockGroup, Value: "Lazy Scans", Note: "Machine translations"},
+		BlockedGroup{Kind: BlockUploader, Value: "user-1"},
+	)
+	if err != nil || added != 2 {
+		t.Fatalf("AddToBlocklist() = %d, %v, want 2 added", added, err)
+	}
+
+	// Entries already blocked are not counted
+	added, _ = repo.AddToBlocklist(BlockedGroup{Kind: BlockGroup, Value: "Lazy Scans"}, BlockedGroup{Kind: BlockGroup, Value: "group-1"})
+	if added != 1 {
+		t.Errorf("Expected 1 new entry, got %d", added)
+	}
+
+	entries, err := repo.GetBlocklist()
+	if err != nil {
+		t.Fatalf("Failed to get blocklist: %v", err)
+	}
+	if len(entries) != 3 || entries[1].Value != "Lazy Scans" || entries[1].Note != "Machine translations" {
+		t.Errorf("Unexpected blocklist %+v", entries)
+	}
+
+	removed, _ := repo.RemoveFromBlocklist(BlockGroup, "lazy scans")
+	if !removed {
+		t.Error("Expected group to be removed ignoring case")
+	}
+	removed, _ = repo.RemoveFromBlocklist(BlockGroup, "user-1")
+	if removed {
+		t.Error("Uploaders should not be removed as groups")
+	}
+}
+
+func TestBackup(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo.SaveManga(&Manga{ID: "manga-1", Name: "Backed Up", Tags: []string{"Action"}})
+	repo.SaveChapter(&Chapter{ID: "ch-1", MangaID: "manga-1", Number: "1"})
+
+	path := filepath.Join(t.TempDir(), "backup's.db")
+	if err := repo.Backup(path); err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	if err := repo.Backup(path); err == nil {
+		t.Error("Backup() should refuse to overwrite an existing file")
+	}
+
+	db, err := InitDuckDB(path)
+	if err != nil {
+		t.Fatalf("Failed to open backup: %v", err)
+	}
+	defer db.Close()
+	restored := &Repository{db: db}
+
+	manga, err := restored.GetManga("manga-1")
+	if err != nil || manga.Name != "Backed Up" || len(manga.Tags) != 1 {
+		t.Errorf("Backup has manga %+v, %v", manga, err)
+	}
+	chapters, _ := restored.GetChapters("manga-1")
+	if len(chapters) != 1 {
+		t.Errorf("Backup has %d chapters, want 1", len(chapters))
+	}
+}
+
+func TestSourceSessions(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if session, err := repo.GetSession("mangadex"); err != nil || session != "" {
+		t.Fatalf("GetSession() = %q, %v, want no session", session, err)
+	}
+
+	repo.SaveSession("mangadex", `{"username":"old"}`)
+	if err := repo.SaveSession("mangadex", `{"username":"reader"}`); err != nil {
+		t.Fatalf("Failed to save session: %v", err)
+	}
+	if session, _ := repo.GetSession("mangadex"); session != `{"username":"reader"}` {
+		t.Errorf("Expected the session to be replaced, got %q", session)
+	}
+
+	// Secrets stay out of the database, readable by the user only
+	info, err := os.Stat(filepath.Join(repo.sessionsDir, "mangadex.json"))
+	if err != nil {
+		t.Fatalf("Expected the session in its own file: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the session file to be 0600, got %v", info.Mode().Perm())
+	}
+	var rows int
+	repo.db.QueryRow(`SELECT count(*) FROM source_sessions`).Scan(&rows)
+	if rows != 0 {
+		t.Errorf("Expected no session in the database, got %d", rows)
+	}
+
+	if removed, err := repo.DeleteSession("mangadex"); err != nil || !removed {
+		t.Errorf("DeleteSession() = %v, %v, want removed", removed, err)
+	}
+	if removed, _ := repo.DeleteSession("mangadex"); removed {
+		t.Error("Expected nothing to remove once logged out")
+	}
+	if _, err := repo.GetSession("../mangadex"); err == nil {
+		t.Error("Expected a source name outside the sessions directory to be rejected")
+	}
+
+	// Sessions stored in the database by older releases move to their file
+	repo.db.Exec(`INSERT INTO source_sessions (source, session) VALUES ('comick', '{"username":"legacy"}')`)
+	if session, err := repo.GetSession("comick"); err != nil || session != `{"username":"legacy"}` {
+		t.Errorf("GetSession() = %q, %v, want the legacy session", session, err)
+	}
+	repo.db.QueryRow(`SELECT count(*) FROM source_sessions`).Scan(&rows)
+	if rows != 0 {
+		t.Errorf("Expected the legacy session moved out of the database, got %d rows", rows)
+	}
+}
+
+func TestSaveMangaKeepsReadingStatus(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo.SaveManga(&Manga{ID: "m1", Name: "Test", Source: "mangadex", ReadingStatus: "reading"})
+	repo.SaveManga(&Manga{ID: "m1", Name: "Test", Source: "mangadex", Status: "completed"})
+
+	manga, err := repo.GetManga("m1")
+	if err != nil {
+		t.Fatalf("Failed to get manga: %v", err)
+	}
+	if manga.ReadingStatus != "reading" || manga.Status != "completed" {
+		t.Errorf("Expected the reading status to be kept, got %q (status %q)", manga.ReadingStatus, manga.Status)
+	}
+}
+
+func TestSetChapterNumber(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo.SaveManga(&Manga{ID: "m1", Name: "Test", Source: "test"})
+	repo.SaveChapter(&Chapter{ID: "c1", MangaID: "m1", Number: "1", Title: "Ch. 1"})
+
+	if err := repo.SetChapterNumber("c1", "2", true); err != nil {
+		t.Fatalf("SetChapterNumber() error = %v", err)
+	}
+	// A locked number survives the source saving the chapter again
+	repo.SaveChapter(&Chapter{ID: "c1", MangaID: "m1", Number: "1", Title: "Ch. 1 (fixed)"})
+	chapters, _ := repo.GetChapters("m1")
+	if len(chapters) != 1 || chapters[0].Number != "2" || !chapters[0].NumberLocked || chapters[0].Title != "Ch. 1 (fixed)" {
+		t.Errorf("Expected the locked number to be kept, got %+v", chapters[0])
+	}
+
+	repo.SetChapterNumber("c1", "2", false)
+	repo.SaveChapter(&Chapter{ID: "c1", MangaID: "m1", Number: "1"})
+	chapters, _ = repo.GetChapters("m1")
+	if chapters[0].Number != "1" || chapters[0].NumberLocked {
+		t.Errorf("Expected the source number once unlocked, got %+v", chapters[0])
+	}
+
+	if err := repo.SetChapterNumber("missing", "1", true); err == nil {
+		t.Error("Expected an error for an unknown chapter")
+	}
+}
+
+func TestSetCustomCover(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo.SaveManga(&Manga{ID: "m1", Name: "Test", Source: "test", CoverURL: "https://example.com/a.jpg"})
+	if err := repo.SetCustomCover("m1", "/covers/test.png"); err != nil {
+		t.Fatalf("SetCustomCover() error = %v", err)
+	}
+	// The custom cover survives the source saving the manga again
+	repo.SaveManga(&Manga{ID: "m1", Name: "Test", Source: "test", CoverURL: "https://example.com/b.jpg"})
+	manga, _ := repo.GetManga("m1")
+	if manga.CustomCover != "/covers/test.png" || manga.CoverURL != "https://example.com/b.jpg" {
+		t.Errorf("Expected the custom cover kept next to the source one, got %+v", manga)
+	}
+
+	repo.SetCustomCover("m1", "")
+	if manga, _ := repo.GetManga("m1"); manga.CustomCover != "" {
+		t.Errorf("Expected the custom cover reset, got %q", manga.CustomCover)
+	}
+
+	if err := repo.SetCustomCover("missing", "/covers/test.png"); err == nil {
+		t.Error("Expected an error for an unknown manga")
+	}
+}
+
+func TestDeviceSyncs(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if syncs, err := repo.GetDeviceSyncs("kindle-1"); err != nil || len(syncs) != 0 {
+		t.Fatalf("GetDeviceSyncs() = %v, %v, want none", syncs, err)
+	}
+
+	repo.SaveDeviceSync(&DeviceSync{DeviceID: "kindle-1", ChapterID: "c1", SourceHash: "aaa", Path: "documents/mangas/a.epub", Size: 10})
+	repo.SaveDeviceSync(&DeviceSync{DeviceID: "kobo-1", ChapterID: "c1", SourceHash: "aaa", Path: "mangas/a.epub", Size: 10})
+	if err := repo.SaveDeviceSync(&DeviceSync{DeviceID: "kindle-1", ChapterID: "c1", SourceHash: "bbb", Path: "documents/mangas/a.azw3", Size: 20}); err != nil {
+		t.Fatalf("SaveDeviceSync() error = %v", err)
+	}
+
+	syncs, err := repo.GetDeviceSyncs("kindle-1")
+	if err != nil || len(syncs) != 1 {
+		t.Fatalf("GetDeviceSyncs() = %v, %v, want one record", syncs, err)
+	}
+	if sync := syncs["c1"]; sync.SourceHash != "bbb" || sync.Path != "documents/mangas/a.azw3" || sync.Size != 20 || sync.SyncedAt.IsZero() {
+		t.Errorf("Unexpected sync: %+v", sync)
+	}
+}
+
+func TestChapterChecksums(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo.SaveManga(&Manga{ID: "m1", Name: "Manga", Source: "mangadex"})
+	repo.SaveChapter(&Chapter{ID: "c1", MangaID: "m1", Number: "1"})
+	repo.SaveChapter(&Chapter{ID: "c2", MangaID: "m1", Number: "2"})
+
+	repo.SaveChapterChecksum(&ChapterChecksum{ChapterID: "c1", Path: "/a.epub", SHA256: "aaa", Size: 10, Pages: []string{"p1", "p2"}})
+	repo.SaveChapterChecksum(&ChapterChecksum{ChapterID: "c2", Path: "/b.epub", SHA256: "bbb"})
+	if err := repo.SaveChapterChecksum(&ChapterChecksum{ChapterID: "c1", Path: "/a.epub", SHA256: "ccc", Size: 20, Pages: []string{"p3"}}); err != nil {
+		t.Fatalf("SaveChapterChecksum() error = %v", err)
+	}
+
+	sums, err := repo.GetChapterChecksums("m1")
+	if err != nil || len(sums) != 2 {
+		t.Fatalf("GetChapterChecksums() = %v, %v, want 2 records", sums, err)
+	}
+	if sum := sums["c1"]; sum.SHA256 != "ccc" || sum.Size != 20 || len(sum.Pages) != 1 || sum.Pages[0] != "p3" || sum.RecordedAt.IsZero() {
+		t.Errorf("Unexpected checksum: %+v", sum)
+	}
+	if len(sums["c2"].Pages) != 0 {
+		t.Errorf("Expected no page checksums, got %v", sums["c2"].Pages)
+	}
+
+	repo.DeleteChapterChecksum("c2")
+	if err := repo.DeleteManga("m1"); err != nil {
+		t.Fatalf("DeleteManga() error = %v", err)
+	}
+	repo.SaveChapter(&Chapter{ID: "c1", MangaID: "m1", Number: "1"})
+	if sums, _ := repo.GetChapterChecksums("m1"); len(sums) != 0 {
+		t.Errorf("Expected the checksums to go with the manga, got %v", sums)
+	}
+}
+
+func TestSourceStats(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	today := time.Now()
+	lastMonth := today.AddDate(0, -1, 0)
+	for _, stat := range []*SourceStat{
+		{Source: "mangadex", Day: today, Requests: 10, Failures: 1, Latency: 2 * time.Second, Bytes: 1000},
+		{Source: "mangadex", Day: today, Requests: 5, Failures: 4, Latency: time.Second, Bytes: 500},
+		{Source: "mangadex", Day: lastMonth, Requests: 100, Latency: 10 * time.Second},
+		{Source: "comick", Day: today, Requests: 2, Latency: 300 * time.Millisecond, Bytes: 20},
+	} {
+		if err := repo.AddSourceStat(stat); err != nil {
+			t.Fatalf("AddSourceStat() error = %v", err)
+		}
+	}
+
+	recent, err := repo.GetSourceStats(today.AddDate(0, 0, -7))
+	if err != nil {
+		t.Fatalf("GetSourceStats() error = %v", err)
+	}
+	if len(recent) != 2 || recent[0].Source != "comick" || recent[1].Source != "mangadex" {
+		t.Fatalf("Expected comick and mangadex, got %+v", recent)
+	}
+	if got := recent[1]; got.Requests != 15 || got.Failures != 5 || got.Latency != 3*time.Second || got.Bytes != 1500 {
+		t.Errorf("Expected this week's requests to be summed, got %+v", got)
+	}
+
+	all, err := repo.GetSourceStats(time.Time{})
+	if err != nil {
+		t.Fatalf("GetSourceStats() error = %v", err)
+	}
+	if got := all[1]; got.Requests != 115 || got.Day.Month() != lastMonth.Month() {
+		t.Errorf("Expected all-time totals from last month, got %+v", got)
+	}
+}
+
+func TestDashboardQueries(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if value, err := repo.GetState(LastVisitKey); err != nil || value != "" {
+		t.Fatalf("GetState() = %q, %v, want no value", value, err)
+	}
+	repo.SetState(LastVisitKey, "a")
+	if err := repo.SetState(LastVisitKey, "b"); err != nil {
+		t.Fatalf("SetState() error = %v", err)
+	}
+	if value, _ := repo.GetState(LastVisitKey); value != "b" {
+		t.Errorf("GetState() = %q, want b", value)
+	}
+
+	repo.SaveManga(&Manga{ID: "old", Name: "Old Manga", Source: "mangadex"})
+	for _, number := range []string{"1", "2", "3"} {
+		repo.SaveChapter(&Chapter{ID: "old-" + number, MangaID: "old", Number: number})
+	}
+	time.Sleep(2 * time.Millisecond)
+	since := time.Now()
+	time.Sleep(2 * time.Millisecond)
+	repo.SaveChapter(&Chapter{ID: "old-4", MangaID: "old", Number: "4"})
+	repo.SaveManga(&Manga{ID: "new", Name: "New Manga", Source: "mangadex"})
+	repo.SaveChapter(&Chapter{ID: "new-1", MangaID: "new", Number: "1"})
+
+	updates, err := repo.NewChaptersSince(since, 10)
+	if err != nil {
+		t.Fatalf("NewChaptersSince() error = %v", err)
+	}
+	if len(updates) != 1 || updates[0].Chapter.ID != "old-4" || updates[0].Manga.Name != "Old Manga" {
+		t.Errorf("Expected only the new chapter of the old manga, got %+v", updates)
+	}
+
+	added, err := repo.RecentlyAddedMangas(1)
+	if err != nil || len(added) != 1 || added[0].ID != "new" {
+		t.Errorf("RecentlyAddedMangas() = %v, %v, want the new manga", added, err)
+	}
+
+	// Reading the old manga, the new one is finished
+	repo.MarkChapterRead("old-1", true)
+	repo.MarkChapterRead("new-1", true)
+	reading, err := repo.ContinueReading(5)
+	if err != nil {
+		t.Fatalf("ContinueReading() error = %v", err)
+	}
+	if len(reading) != 1 || reading[0].Manga.ID != "old" || reading[0].Chapter.ID != "old-2" {
+		t.Fatalf("Expected to continue with chapter 2 of the old manga, got %+v", reading)
+	}
+
+	repo.SetReadProgress("old-3", 5)
+	if reading, _ := repo.ContinueReading(5); len(reading) != 1 || reading[0].Chapter.ID != "old-3" {
+		t.Errorf("Expected to continue the unfinished chapter 3, got %+v", reading)
+	}
+}
+
+func TestRetryConflicts(t *testing.T) {
+	conflict := errors.New("TransactionContext Error: Conflict on update!")
+
+	attempts := 0
+	err := retryConflicts(func() error {
+		if attempts++; attempts < 3 {
+			return conflict
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Errorf("retryConflicts() = %v after %d attempts, want success after 3", err, attempts)
+	}
+
+	// Other errors are returned at once, conflicts after the last retry
+	attempts = 0
+	other := errors.New("constraint violated")
+	if err := retryConflicts(func() error { attempts++; return other }); err != other || attempts != 1 {
+		t.Errorf("Expected the error without retrying, got %v after %d attempts", err, attempts)
+	}
+	attempts = 0
+	if err := retryConflicts(func() error { attempts++; return conflict }); err != conflict || attempts != conflictRetries {
+		t.Errorf("Expected the conflict after %d attempts, got %v after %d", conflictRetries, err, attempts)
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo.SaveManga(&Manga{ID: "a", Name: "Alpha", Source: "mangadex"})
+	repo.SaveChapter(&Chapter{ID: "a-1", MangaID: "a", Number: "1"})
+	time.Sleep(2 * time.Millisecond)
+	repo.SaveManga(&Manga{ID: "b", Name: "Beta", Source: "mangadex"})
+	time.Sleep(2 * time.Millisecond)
+
+	alpha, _ := repo.GetManga("a")
+	if alpha.AddedAt.IsZero() || alpha.UpdatedAt.Before(alpha.AddedAt) {
+		t.Fatalf("Expected the timestamps recorded, got added %v updated %v", alpha.AddedAt, alpha.UpdatedAt)
+	}
+
+	// Saving unchanged details is not an update, a new chapter is
+	repo.SaveManga(&Manga{ID: "a", Name: "Alpha", Source: "mangadex"})
+	repo.SaveChapter(&Chapter{ID: "a-1", MangaID: "a", Number: "1"})
+	if again, _ := repo.GetManga("a"); !again.UpdatedAt.Equal(alpha.UpdatedAt) {
+		t.Errorf("UpdatedAt moved to %v without changes, want %v", again.UpdatedAt, alpha.UpdatedAt)
+	}
+	recent, err := repo.ListRecent(1)
+	if err != nil || len(recent) != 1 || recent[0].ID != "b" {
+		t.Fatalf("ListRecent() = %v, %v, want Beta", recent, err)
+	}
+
+	repo.SaveChapter(&Chapter{ID: "a-1", MangaID: "a", Number: "1", Downloaded: true, FilePath: "/a-1.epub"})
+	chapters, _ := repo.GetChapters("a")
+	if !chapters[0].UpdatedAt.After(chapters[0].AddedAt) {
+		t.Errorf("Expected the download to update the chapter, got added %v updated %v", chapters[0].AddedAt, chapters[0].UpdatedAt)
+	}
+	if recent, _ := repo.ListRecent(2); len(recent) != 2 || recent[0].ID != "a" {
+		t.Errorf("Expected Alpha updated last, got %v", recent)
+	}
+
+	mangas, _ := repo.ListMangas()
+	SortMangas(mangas, SortAdded)
+	if mangas[0].ID != "b" {
+		t.Errorf("Expected Beta added last, got %s first", mangas[0].Name)
+	}
+	SortMangas(mangas, SortUpdated)
+	if mangas[0].ID != "a" {
+		t.Errorf("Expected Alpha updated last, got %s first", mangas[0].Name)
+	}
+	SortMangas(mangas, SortName)
+	if mangas[0].ID != "a" {
+		t.Errorf("Expected Alpha first by name, got %s", mangas[0].Name)
+	}
+}
+
+func TestTimestamps_DownloadsAndMoves(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo.SaveManga(&Manga{ID: "a", Name: "Alpha", Source: "mangadex"})
+	repo.SaveChapter(&Chapter{ID: "a-1", MangaID: "a", Number: "1"})
+	time.Sleep(2 * time.Millisecond)
+	repo.SaveManga(&Manga{ID: "b", Name: "Beta", Source: "mangadex"})
+	time.Sleep(2 * time.Millisecond)
+
+	updated := func() time.Time {
+		chapters, _ := repo.GetChapters("a")
+		return chapters[0].UpdatedAt
+	}
+	before := updated()
+	if err := repo.UpdateChapterStatus("a-1", true, "/a-1.epub"); err != nil {
+		t.Fatalf("UpdateChapterStatus() error = %v", err)
+	}
+	downloaded := updated()
+	if !downloaded.After(before) {
+		t.Errorf("Expected the download to update the chapter, got %v after %v", downloaded, before)
+	}
+	if recent, _ := repo.ListRecent(1); len(recent) != 1 || recent[0].ID != "a" {
+		t.Errorf("Expected Alpha updated last by its download, got %v", recent)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	if err := repo.MoveChapterFiles(map[string]string{"/a-1.epub": "/Alpha/a-1.epub"}); err != nil {
+		t.Fatalf("MoveChapterFiles() error = %v", err)
+	}
+	if moved := updated(); !moved.After(downloaded) {
+		t.Errorf("Expected the move to update the chapter, got %v after %v", moved, downloaded)
+	}
+}
+
+func TestParseMangaSort(t *testing.T) {
+	for name, want := range map[string]MangaSort{"": SortDefault, "Added": SortAdded, " updated ": SortUpdated, "name": SortName} {
+		if got, err := ParseMangaSort(name); err != nil || got != want {
+			t.Errorf("ParseMangaSort(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseMangaSort("size"); err == nil {
+		t.Error("Expected an error for an unknown sort")
+	}
+}
+
+func TestGetReadingBacklog(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo.SaveManga(&Manga{ID: "a", Name: "Alpha", Source: "mangadex"})
+	repo.SaveManga(&Manga{ID: "b", Name: "Beta", Source: "mangadex"})
+	chapters := []*Chapter{
+		{ID: "a-1", MangaID: "a", Number: "1", Downloaded: true},
+		{ID: "a-2", MangaID: "a", Number: "2", Downloaded: true},
+		{ID: "a-3", MangaID: "a", Number: "3", Downloaded: true},
+		{ID: "a-4", MangaID: "a", Number: "4"},
+		{ID: "b-1", MangaID: "b", Number: "1", Downloaded: true},
+	}
+	for _, ch := range chapters {
+		repo.SaveChapter(ch)
+	}
+	pages := func(n int) []string { return strings.Fields(strings.Repeat("hash ", n)) }
+	repo.SaveChapterChecksum(&ChapterChecksum{ChapterID: "a-1", Path: "a-1.epub", Pages: pages(10)})
+	repo.SaveChapterChecksum(&ChapterChecksum{ChapterID: "a-2", Path: "a-2.epub", Pages: pages(20)})
+	repo.SaveChapterChecksum(&ChapterChecksum{ChapterID: "b-1", Path: "b-1.epub", Pages: pages(30)})
+	repo.MarkChapterRead("a-1", true)
+	repo.SetReadProgress("a-2", 5)
+
+	// a-2 is half read, a-3 has no recorded length
+	backlog, err := repo.GetReadingBacklog("a")
+	if err != nil {
+		t.Fatalf("GetReadingBacklog() error = %v", err)
+	}
+	if backlog.Chapters != 2 || backlog.Pages != 15 || backlog.Unknown != 1 || backlog.AveragePages != 20 {
+		t.Errorf("GetReadingBacklog(a) = %+v, want 2 chapters, 15 pages and 1 of 20 pages on average", backlog)
+	}
+	if got := backlog.EstimatedPages(); got != 35 {
+		t.Errorf("EstimatedPages() = %v, want 35", got)
+	}
+
+	library, err := repo.GetReadingBacklog("")
+	if err != nil || library.Chapters != 3 || library.Pages != 45 {
+		t.Errorf("GetReadingBacklog() = %+v, %v, want 3 chapters and 45 pages", library, err)
+	}
+}
+
+func TestUpgradeLegacyRows(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	// Rows as written by the first releases, most columns left NULL
+	repo.db.Exec(`INSERT INTO mangas (id, name, source) VALUES ('m1', 'Legacy', 'mangadex')`)
+	repo.db.Exec(`INSERT INTO chapters (id, manga_id, number, downloaded) VALUES ('c1', 'm1', '1', NULL)`)
+	repo.db.Exec(`UPDATE mangas SET url = NULL`)
+	if _, err := repo.GetChapters("m1"); err == nil {
+		t.Fatal("Expected legacy rows to be unreadable before the upgrade")
+	}
+
+	upgraded, err := repo.UpgradeLegacyRows()
+	if err != nil || upgraded != 2 {
+		t.Fatalf("UpgradeLegacyRows() = %d, %v, want 2 rows", upgraded, err)
+	}
+	chapters, err := repo.GetChapters("m1")
+	if err != nil || len(chapters) != 1 || chapters[0].Downloaded || chapters[0].FilePath != "" {
+		t.Fatalf("GetChapters() = %+v, %v", chapters, err)
+	}
+	if manga, err := repo.GetManga("m1"); err != nil || manga.URL != "" {
+		t.Errorf("GetManga() = %+v, %v", manga, err)
+	}
+	if upgraded, _ := repo.UpgradeLegacyRows(); upgraded != 0 {
+		t.Errorf("Expected nothing left to upgrade, got %d rows", upgraded)
+	}
+}
+
+func TestLinkManga(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo.SaveManga(&Manga{ID: "md-1", Name: "Same Series", Source: "mangadex"})
+	repo.SaveManga(&Manga{ID: "ck-1", Name: "Same Series", Source: "comick"})
+	repo.SaveChapter(&Chapter{ID: "md-c1", MangaID: "md-1", Number: "1", Language: "en"})
+	repo.SaveChapter(&Chapter{ID: "ck-c2", MangaID: "ck-1", Number: "2", Language: "en"})
+	repo.AddToCollection("reading", "ck-1")
+
+	if err := repo.LinkManga("md-1", &Manga{ID: "md-1", Source: "mangadex"}); err == nil {
+		t.Error("Expected linking a manga to itself to fail")
+	}
+	if err := repo.LinkManga("missing", &Manga{ID: "ck-1", Source: "comick"}); err == nil {
+		t.Error("Expected linking to a missing manga to fail")
+	}
+	if err := repo.LinkManga("md-1", &Manga{ID: "ck-1", Name: "Same Series", Source: "comick"}); err != nil {
+		t.Fatalf("LinkManga() error = %v", err)
+	}
+
+	if manga, _ := repo.GetManga("ck-1"); manga != nil {
+		t.Errorf("Expected the linked entry to leave the library, got %+v", manga)
+	}
+	chapters, err := repo.GetChapters("md-1")
+	if err != nil || len(chapters) != 2 {
+		t.Fatalf("GetChapters() = %d chapters, %v, want 2", len(chapters), err)
+	}
+	for _, ch := range chapters {
+		want := ""
+		if ch.ID == "ck-c2" {
+			want = "comick"
+		}
+		if ch.Source != want {
+			t.Errorf("Chapter %s source = %q, want %q", ch.ID, ch.Source, want)
+		}
+	}
+
+	links, err := repo.GetMangaLinks("md-1")
+	if err != nil || len(links) != 1 || links[0].Source != "comick" || links[0].SourceID != "ck-1" {
+		t.Fatalf("GetMangaLinks() = %+v, %v", links, err)
+	}
+	if link, err := repo.FindMangaLink("comick", "ck-1"); err != nil || link == nil || link.MangaID != "md-1" {
+		t.Errorf("FindMangaLink() = %+v, %v", link, err)
+	}
+	if link, err := repo.FindMangaLink("comick", "other"); err != nil || link != nil {
+		t.Errorf("FindMangaLink() of an unlinked entry = %+v, %v, want nil", link, err)
+	}
+	if mangas, _ := repo.GetCollection("reading"); len(mangas) != 1 || mangas[0].ID != "md-1" {
+		t.Errorf("Expected the collection to hold the manga linked to, got %+v", mangas)
+	}
+
+	// Re-saving a chapter from the manga's own sync keeps its source
+	repo.SaveChapter(&Chapter{ID: "ck-c2", MangaID: "md-1", Number: "2", Language: "en"})
+	if chapters, _ := repo.GetChapters("md-1"); chapters[1].Source != "comick" {
+		t.Errorf("Chapter source = %q after upsert, want comick", chapters[1].Source)
+	}
+}
+
+func TestMangaSettings(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo.SaveManga(&Manga{ID: "manga-1", Name: "Test Manga"})
+	settings, err := repo.GetMangaSettings("manga-1")
+	if err != nil || settings == nil || !settings.IsZero() {
+		t.Fatalf("GetMangaSettings() without settings = %+v, %v, want empty settings", settings, err)
+	}
+
+	want := &MangaSettings{MangaID: "manga-1", Language: "es-la", Group: "Lazy Scans", Format: "cbz", BundleMode: "chapter"}
+	if err := repo.SaveMangaSettings(want); err != nil {
+		t.Fatalf("SaveMangaSettings() error = %v", err)
+	}
+	want.Group = "Other Scans"
+	if err := repo.SaveMangaSettings(want); err != nil {
+		t.Fatalf("SaveMangaSettings() upsert error = %v", err)
+	}
+	if settings, _ := repo.GetMangaSettings("manga-1"); *settings != *want {
+		t.Errorf("GetMangaSettings() = %+v, want %+v", settings, want)
+	}
+
+	if err := repo.DeleteManga("manga-1"); err != nil {
+		t.Fatalf("DeleteManga() error = %v", err)
+	}
+	if settings, _ := repo.GetMangaSettings("manga-1"); !settings.IsZero() {
+		t.Errorf("Expected deleting the manga to drop its settings, got %+v", settings)
+	}
+}
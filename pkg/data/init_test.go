@@ -70,3 +70,61 @@ func TestNewDuckDBRepositorySingleton(t *testing.T) {
 	}
 }
 
+
+func TestDefaultDBPath(t *testing.T) {
+	oldPath := dbPath
+	defer func() { dbPath = oldPath }()
+
+	dbPath = ""
+	t.Setenv(DBPathEnv, "/tmp/env.db")
+	if got, _ := DefaultDBPath(); got != "/tmp/env.db" {
+		t.Errorf("Expected the environment path, got %s", got)
+	}
+
+	dbPath = "/tmp/set.db"
+	if got, _ := DefaultDBPath(); got != "/tmp/set.db" {
+		t.Errorf("Expected the path given to SetDBPath, got %s", got)
+	}
+
+	dbPath = ""
+	t.Setenv(DBPathEnv, "")
+	home, _ := os.UserHomeDir()
+	if got, _ := DefaultDBPath(); got != filepath.Join(home, ".mangas", "mangas.db") {
+		t.Errorf("Expected the home path, got %s", got)
+	}
+}
+
+func TestOpenDuckDBRepository(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "own.db")
+
+	repo, err := OpenDuckDBRepository(dbPath)
+	if err != nil {
+		t.Fatalf("OpenDuckDBRepository() error = %v", err)
+	}
+	if repo.db == NewDuckDBRepository().db {
+		t.Error("Expected a database independent of the shared one")
+	}
+	if err := repo.SaveManga(&Manga{ID: "own", Name: "Own", Source: "mangadex"}); err != nil {
+		t.Fatalf("SaveManga() error = %v", err)
+	}
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// The shared database is left open
+	if err := NewDuckDBRepository().Close(); err != nil {
+		t.Errorf("Close() of the shared repository error = %v", err)
+	}
+	if _, err := NewDuckDBRepository().ListMangas(); err != nil {
+		t.Errorf("Expected the shared database to stay open, got %v", err)
+	}
+
+	repo, err = OpenDuckDBRepository(dbPath)
+	if err != nil {
+		t.Fatalf("OpenDuckDBRepository() error = %v", err)
+	}
+	defer repo.Close()
+	if manga, err := repo.GetManga("own"); err != nil || manga.Name != "Own" {
+		t.Errorf("Expected the manga saved before reopening, got %v, %v", manga, err)
+	}
+}
@@ -0,0 +1,245 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// LibraryExportVersion is the version of the library export format
+const LibraryExportVersion = 1
+
+// LibraryExport is a portable copy of the library: manga with their
+// metadata, collections, chapters, download state and reading progress
+type LibraryExport struct {
+	Version    int            `json:"version"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Mangas     []LibraryManga `json:"mangas"`
+}
+
+// LibraryManga is a manga of a library export
+type LibraryManga struct {
+	ID                string           `json:"id"`
+	Name              string           `json:"name"`
+	Description       string           `json:"description,omitempty"`
+	CoverURL          string           `json:"cover_url,omitempty"`
+	Source            string           `json:"source"`
+	Status            string           `json:"status,omitempty"`
+	URL               string           `json:"url,omitempty"`
+	Tags              []string         `json:"tags,omitempty"`
+	Genres            []string         `json:"genres,omitempty"`
+	Authors           []string         `json:"authors,omitempty"`
+	Artists           []string         `json:"artists,omitempty"`
+	Year              int              `json:"year,omitempty"`
+	PublicationStatus string           `json:"publication_status,omitempty"`
+	ReadingStatus     string           `json:"reading_status,omitempty"`
+	CustomCover       string           `json:"custom_cover,omitempty"`
+	Collections       []string         `json:"collections,omitempty"`
+	Chapters          []LibraryChapter `json:"chapters,omitempty"`
+}
+
+// LibraryChapter is a chapter of a library export
+type LibraryChapter struct {
+	ID           string     `json:"id"`
+	Title        string     `json:"title,omitempty"`
+	Language     string     `json:"language,omitempty"`
+	Volume       string     `json:"volume,omitempty"`
+	Number       string     `json:"number"`
+	NumberLocked bool       `json:"number_locked,omitempty"`
+	URL          string     `json:"url,omitempty"`
+	Downloaded   bool       `json:"downloaded,omitempty"`
+	FilePath     string     `json:"file_path,omitempty"`
+	Read         bool       `json:"read,omitempty"`
+	LastReadPage int        `json:"last_read_page,omitempty"`
+	ReadAt       *time.Time `json:"read_at,omitempty"`
+}
+
+// LibraryImportResult counts what an import wrote to the library
+type LibraryImportResult struct {
+	Mangas   int
+	Chapters int
+}
+
+// ExportLibrary returns the whole library as a LibraryExport
+func (r *Repository) ExportLibrary() (*LibraryExport, error) {
+	mangas, err := r.ListMangas()
+	if err != nil {
+		return nil, err
+	}
+	collections, err := r.mangaCollections()
+	if err != nil {
+		return nil, err
+	}
+
+	export := &LibraryExport{
+		Version:    LibraryExportVersion,
+		ExportedAt: time.Now().UTC(),
+		Mangas:     make([]LibraryManga, 0, len(mangas)),
+	}
+	for _, manga := range mangas {
+		chapters, err := r.GetChapters(manga.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get chapters of %s: %w", manga.Name, err)
+		}
+
+		entry := LibraryManga{
+			ID:                manga.ID,
+			Name:              manga.Name,
+			Description:       manga.Description,
+			CoverURL:          manga.CoverURL,
+			Source:            manga.Source,
+			Status:            manga.Status,
+			URL:               manga.URL,
+			Tags:              manga.Tags,
+			Genres:            manga.Genres,
+			Authors:           manga.Authors,
+			Artists:           manga.Artists,
+			Year:              manga.Year,
+			PublicationStatus: manga.PublicationStatus,
+			ReadingStatus:     manga.ReadingStatus,
+			CustomCover:       manga.CustomCover,
+			Collections:       collections[manga.ID],
+		}
+		for _, ch := range chapters {
+			chapter := LibraryChapter{
+				ID:           ch.ID,
+				Title:        ch.Title,
+				Language:     ch.Language,
+				Volume:       ch.Volume,
+				Number:       ch.Number,
+				NumberLocked: ch.NumberLocked,
+				URL:          ch.URL,
+				Downloaded:   ch.Downloaded,
+				FilePath:     ch.FilePath,
+				Read:         ch.Read,
+				LastReadPage: ch.LastReadPage,
+			}
+			if !ch.ReadAt.IsZero() {
+				readAt := ch.ReadAt
+				chapter.ReadAt = &readAt
+			}
+			entry.Chapters = append(entry.Chapters, chapter)
+		}
+		export.Mangas = append(export.Mangas, entry)
+	}
+	return export, nil
+}
+
+// ImportLibrary adds the manga of an export to the library. Manga and
+// chapters already in the library are updated with the exported data,
+// others are left as they are. The export is imported as a whole: on
+// error the library is left untouched.
+func (r *Repository) ImportLibrary(export *LibraryExport) (*LibraryImportResult, error) {
+	if export.Version > LibraryExportVersion {
+		return nil, fmt.Errorf("unsupported library export version %d", export.Version)
+	}
+	for _, entry := range export.Mangas {
+		if entry.ID == "" || entry.Name == "" {
+			return nil, fmt.Errorf("manga without ID or name in export")
+		}
+	}
+
+	var result *LibraryImportResult
+	err := r.inTransaction(func(r *Repository) error {
+		// Conflicting transactions are run again from scratch
+		result = &LibraryImportResult{}
+		return r.importMangas(export.Mangas, result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// importMangas saves the manga of an export, counting them in result
+func (r *Repository) importMangas(entries []LibraryManga, result *LibraryImportResult) error {
+	for _, entry := range entries {
+		manga := &Manga{
+			ID:                entry.ID,
+			Name:              entry.Name,
+			Description:       entry.Description,
+			CoverURL:          entry.CoverURL,
+			Source:            entry.Source,
+			Status:            entry.Status,
+			URL:               entry.URL,
+			Tags:              entry.Tags,
+			Genres:            entry.Genres,
+			Authors:           entry.Authors,
+			Artists:           entry.Artists,
+			Year:              entry.Year,
+			PublicationStatus: entry.PublicationStatus,
+			ReadingStatus:     entry.ReadingStatus,
+		}
+		if err := r.SaveManga(manga); err != nil {
+			return fmt.Errorf("failed to save %s: %w", entry.Name, err)
+		}
+		if entry.CustomCover != "" {
+			if err := r.SetCustomCover(entry.ID, entry.CustomCover); err != nil {
+				return err
+			}
+		}
+		for _, collection := range entry.Collections {
+			if err := r.AddToCollection(collection, entry.ID); err != nil {
+				return err
+			}
+		}
+
+		for _, ch := range entry.Chapters {
+			chapter := &Chapter{
+				ID:         ch.ID,
+				MangaID:    entry.ID,
+				Title:      ch.Title,
+				Language:   ch.Language,
+				Volume:     ch.Volume,
+				Number:     ch.Number,
+				URL:        ch.URL,
+				Downloaded: ch.Downloaded,
+				FilePath:   ch.FilePath,
+			}
+			if err := r.SaveChapter(chapter); err != nil {
+				return fmt.Errorf("failed to save chapter %s of %s: %w", ch.Number, entry.Name, err)
+			}
+			if err := r.restoreProgress(ch); err != nil {
+				return err
+			}
+			if ch.NumberLocked {
+				if err := r.SetChapterNumber(ch.ID, ch.Number, true); err != nil {
+					return err
+				}
+			}
+			result.Chapters++
+		}
+		result.Mangas++
+	}
+	return nil
+}
+
+// restoreProgress writes the reading progress of an exported chapter
+func (r *Repository) restoreProgress(chapter LibraryChapter) error {
+	var readAt sql.NullTime
+	if chapter.ReadAt != nil {
+		readAt = sql.NullTime{Time: *chapter.ReadAt, Valid: true}
+	}
+	_, err := r.exec(`UPDATE chapters SET read = ?, last_read_page = ?, read_at = ? WHERE id = ?`,
+		chapter.Read, chapter.LastReadPage, readAt, chapter.ID)
+	return err
+}
+
+// mangaCollections returns the collections of every manga, by manga ID
+func (r *Repository) mangaCollections() (map[string][]string, error) {
+	rows, err := r.db.Query(`SELECT manga_id, collection FROM collection_mangas ORDER BY collection`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := make(map[string][]string)
+	for rows.Next() {
+		var mangaID, collection string
+		if err := rows.Scan(&mangaID, &collection); err != nil {
+			return nil, err
+		}
+		collections[mangaID] = append(collections[mangaID], collection)
+	}
+	return collections, rows.Err()
+}
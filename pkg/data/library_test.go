@@ -0,0 +1,105 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestLibraryExportRoundTrip(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo.SaveManga(&Manga{
+		ID: "m1", Name: "First", Source: "mangadex", Status: "completed",
+		Tags: []string{"Action", "Drama"}, Genres: []string{"Action"}, Authors: []string{"Author"},
+		Year: 2001, ReadingStatus: "reading",
+	})
+	repo.SaveManga(&Manga{ID: "m2", Name: "Second", Source: "comick"})
+	repo.SaveChapter(&Chapter{ID: "c1", MangaID: "m1", Number: "1", Language: "en", Downloaded: true, FilePath: "/books/First_ch_1.epub"})
+	repo.SaveChapter(&Chapter{ID: "c2", MangaID: "m1", Number: "2", Language: "en"})
+	repo.MarkChapterRead("c1", true)
+	repo.SetReadProgress("c2", 7)
+	repo.AddToCollection("Favorites", "m1")
+
+	export, err := repo.ExportLibrary()
+	if err != nil {
+		t.Fatalf("ExportLibrary() error = %v", err)
+	}
+	if export.Version != LibraryExportVersion || len(export.Mangas) != 2 {
+		t.Fatalf("Unexpected export: version %d, %d manga", export.Version, len(export.Mangas))
+	}
+
+	// Through JSON, into an empty library
+	encoded, err := json.Marshal(export)
+	if err != nil {
+		t.Fatalf("Failed to encode export: %v", err)
+	}
+	var decoded LibraryExport
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Failed to decode export: %v", err)
+	}
+
+	other, cleanupOther := setupTestDB(t)
+	defer cleanupOther()
+	result, err := other.ImportLibrary(&decoded)
+	if err != nil {
+		t.Fatalf("ImportLibrary() error = %v", err)
+	}
+	if result.Mangas != 2 || result.Chapters != 2 {
+		t.Errorf("Imported %d manga and %d chapters, want 2 and 2", result.Mangas, result.Chapters)
+	}
+
+	manga, _ := other.GetManga("m1")
+	if manga == nil || manga.Status != "completed" || manga.ReadingStatus != "reading" || manga.Year != 2001 ||
+		len(manga.Tags) != 2 || len(manga.Genres) != 1 || len(manga.Authors) != 1 {
+		t.Errorf("Unexpected imported manga: %+v", manga)
+	}
+	chapters, _ := other.GetChapters("m1")
+	if len(chapters) != 2 {
+		t.Fatalf("Expected 2 chapters, got %d", len(chapters))
+	}
+	if !chapters[0].Downloaded || chapters[0].FilePath != "/books/First_ch_1.epub" || !chapters[0].Read || chapters[0].ReadAt.IsZero() {
+		t.Errorf("Unexpected first chapter: %+v", chapters[0])
+	}
+	if chapters[1].Read || chapters[1].LastReadPage != 7 {
+		t.Errorf("Unexpected second chapter: %+v", chapters[1])
+	}
+	if favorites, _ := other.GetCollection("Favorites"); len(favorites) != 1 || favorites[0].ID != "m1" {
+		t.Errorf("Expected the collection to be imported, got %v", favorites)
+	}
+
+	if _, err := other.ImportLibrary(&LibraryExport{Version: LibraryExportVersion + 1}); err == nil {
+		t.Error("Expected an error for a newer export version")
+	}
+}
+
+func TestImportLibrary_AllOrNothing(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	export := &LibraryExport{Version: LibraryExportVersion, Mangas: []LibraryManga{
+		{ID: "m1", Name: "First", Source: "mangadex"},
+		{ID: "m2", Source: "mangadex"},
+	}}
+	if _, err := repo.ImportLibrary(export); err == nil {
+		t.Fatal("Expected an error for a manga without name")
+	}
+	if manga, _ := repo.GetManga("m1"); manga != nil {
+		t.Error("No manga should be imported from an invalid export")
+	}
+
+	// Writes are rolled back when the transaction fails midway
+	err := repo.inTransaction(func(r *Repository) error {
+		if err := r.SaveManga(&Manga{ID: "m1", Name: "First", Source: "mangadex", Tags: []string{"Action"}}); err != nil {
+			return err
+		}
+		return errors.New("failed")
+	})
+	if err == nil {
+		t.Fatal("Expected the error of the transaction")
+	}
+	if manga, _ := repo.GetManga("m1"); manga != nil {
+		t.Errorf("Expected the manga saved in the failed transaction to be rolled back, got %+v", manga)
+	}
+}
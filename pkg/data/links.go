@@ -0,0 +1,90 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// LinkManga rolls the library entry other up under the manga mangaID, as the
+// same series on another source: its chapters, queued downloads and
+// collections move to the manga, which syncs them from other's source from
+// then on, and the entry is removed. Entries linked to other follow it.
+func (r *Repository) LinkManga(mangaID string, other *Manga) error {
+	if mangaID == other.ID {
+		return fmt.Errorf("cannot link a manga to itself")
+	}
+	return r.transaction(func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.QueryRow(`SELECT count(*) > 0 FROM mangas WHERE id = ?`, mangaID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("manga not found: %s", mangaID)
+		}
+
+		statements := []struct {
+			query string
+			args  []any
+		}{
+			{`INSERT INTO manga_links (manga_id, source, source_id, name) VALUES (?, ?, ?, ?)
+				ON CONFLICT (source, source_id) DO UPDATE SET manga_id = excluded.manga_id, name = excluded.name`,
+				[]any{mangaID, other.Source, other.ID, other.Name}},
+			{`UPDATE manga_links SET manga_id = ? WHERE manga_id = ?`, []any{mangaID, other.ID}},
+			// Chapters remember their source, the manga's own one may differ
+			{`UPDATE chapters SET manga_id = ?, source = CASE WHEN COALESCE(source, '') = '' THEN ? ELSE source END
+				WHERE manga_id = ?`, []any{mangaID, other.Source, other.ID}},
+			{`UPDATE download_queue SET manga_id = ? WHERE manga_id = ?`, []any{mangaID, other.ID}},
+			{`INSERT INTO collection_mangas (collection, manga_id)
+				SELECT collection, ? FROM collection_mangas WHERE manga_id = ?
+				ON CONFLICT DO NOTHING`, []any{mangaID, other.ID}},
+			{`DELETE FROM collection_mangas WHERE manga_id = ?`, []any{other.ID}},
+			{`DELETE FROM manga_relations WHERE manga_id = ?`, []any{other.ID}},
+			{`DELETE FROM manga_tags WHERE manga_id = ?`, []any{other.ID}},
+			{`DELETE FROM manga_people WHERE manga_id = ?`, []any{other.ID}},
+			{`DELETE FROM manga_settings WHERE manga_id = ?`, []any{other.ID}},
+			{`DELETE FROM mangas WHERE id = ?`, []any{other.ID}},
+		}
+		for _, statement := range statements {
+			if _, err := tx.Exec(statement.query, statement.args...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetMangaLinks returns the entries linked to a manga
+func (r *Repository) GetMangaLinks(mangaID string) ([]*MangaLink, error) {
+	rows, err := r.db.Query(`SELECT manga_id, source, source_id, COALESCE(name, '')
+		FROM manga_links WHERE manga_id = ? ORDER BY source, source_id`, mangaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []*MangaLink
+	for rows.Next() {
+		link := &MangaLink{}
+		if err := rows.Scan(&link.MangaID, &link.Source, &link.SourceID, &link.Name); err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// FindMangaLink returns the link of the entry sourceID of source, nil when
+// it was not linked to any manga
+func (r *Repository) FindMangaLink(source, sourceID string) (*MangaLink, error) {
+	link := &MangaLink{}
+	err := r.db.QueryRow(`SELECT manga_id, source, source_id, COALESCE(name, '')
+		FROM manga_links WHERE source = ? AND source_id = ?`, source, sourceID).Scan(
+		&link.MangaID, &link.Source, &link.SourceID, &link.Name)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return link, nil
+}
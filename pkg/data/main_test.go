@@ -0,0 +1,25 @@
+package data
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMain points the shared database to a temporary one, so tests never
+// touch the user's library
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "mangas-data-test-*")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create test directory: %v\n", err)
+		os.Exit(1)
+	}
+	SetDBPath(filepath.Join(dir, "mangas.db"))
+
+	code := m.Run()
+
+	SetDBPath("")
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
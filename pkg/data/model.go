@@ -1,12 +1,27 @@
 package data
 
+import "time"
+
 type Manga struct {
 	ID          string
 	Name        string
 	Description string
 	CoverURL    string
 	Source      string
-	Status      string // "downloading", "completed", "error"
+	Status      string   // "downloading", "completed", "error"
+	URL         string   // Canonical page of the manga on its source
+	Tags        []string // All tags, genres included
+
+	Genres            []string
+	Authors           []string
+	Artists           []string
+	Year              int    // Year of first publication, 0 if unknown
+	PublicationStatus string // "ongoing", "completed", "hiatus", "cancelled"
+	ReadingStatus     string // "reading", "plan_to_read", "completed", "on_hold", "dropped", "re_reading"
+	CustomCover       string // Local path or URL of a cover replacing the source one, see SetCustomCover
+
+	AddedAt   time.Time // First saved to the library, zero for mangas saved before it was recorded
+	UpdatedAt time.Time // Last change to the manga or one of its chapters, new chapters included
 }
 
 type Chapter struct {
@@ -18,4 +33,146 @@ type Chapter struct {
 	Number     string
 	Downloaded bool
 	FilePath   string // Path to downloaded images directory
+	URL        string // Canonical page of the chapter on its source
+	Source     string // Source of a chapter of a linked manga, empty for the manga's own source
+
+	NumberLocked bool // Number fixed by hand, kept when the source saves the chapter again
+
+	// Scanlation as reported by the source, not stored in the library
+	Groups     []string // Scanlation group names
+	GroupIDs   []string // Scanlation group IDs on the source
+	UploaderID string
+
+	Read         bool      // Finished reading
+	LastReadPage int       // Last page read, 0 when not started
+	ReadAt       time.Time // Last time the chapter was read, zero if never
+
+	AddedAt   time.Time // First seen on the source, zero for chapters saved before it was recorded
+	UpdatedAt time.Time // Last change of its details or download
+}
+
+// ReadingProgress summarizes how far a manga has been read
+type ReadingProgress struct {
+	Read     int      // Chapters marked as read
+	Total    int      // Chapters in the library
+	LastRead *Chapter // Most recently read chapter, nil if none
+}
+
+// ReadingBacklog counts what is left to read of the downloaded chapters.
+// Page counts come from the checksums recorded at download, chapters
+// downloaded before they were recorded have no known length.
+type ReadingBacklog struct {
+	Chapters     int     // Unread downloaded chapters
+	Pages        int     // Pages left in the unread chapters of known length
+	Unknown      int     // Unread chapters of unknown length
+	AveragePages float64 // Pages per downloaded chapter of known length, library-wide
+}
+
+// EstimatedPages returns the pages left to read, counting the chapters of
+// unknown length as average ones
+func (b *ReadingBacklog) EstimatedPages() float64 {
+	return float64(b.Pages) + float64(b.Unknown)*b.AveragePages
+}
+
+// MangaChapter is a chapter along with its manga, as listed on the home
+// dashboard
+type MangaChapter struct {
+	Manga   *Manga
+	Chapter *Chapter
+}
+
+// MangaLink is a library entry linked to another manga: the same series on
+// another source, whose chapters now roll up under the manga
+type MangaLink struct {
+	MangaID  string // The manga the entry was linked to
+	Source   string
+	SourceID string // ID of the entry on Source
+	Name     string
+}
+
+// MangaSettings are the download defaults of a manga, overriding the global
+// ones. Empty fields fall back to them.
+type MangaSettings struct {
+	MangaID    string
+	Language   string // Preferred chapter language
+	Group      string // Preferred scanlation group, when several translated a chapter
+	Format     string // "epub" or "cbz"
+	BundleMode string // "chapter" or "volume"
+}
+
+// IsZero reports whether no setting is set
+func (s *MangaSettings) IsZero() bool {
+	return s.Language == "" && s.Group == "" && s.Format == "" && s.BundleMode == ""
+}
+
+// Relation links a manga to a related series
+type Relation struct {
+	MangaID   string
+	RelatedID string
+	Name      string
+	Type      string // "sequel", "prequel", "spin_off", "side_story", ...
+}
+
+// Blocklist entry kinds
+const (
+	BlockGroup    = "group"
+	BlockUploader = "uploader"
+)
+
+// BlockedGroup is a scanlation group or uploader whose chapters are hidden
+type BlockedGroup struct {
+	Kind  string // BlockGroup or BlockUploader
+	Value string // Group or uploader ID, or a group name
+	Note  string
+}
+
+// Download queue item statuses
+const (
+	QueueQueued = "queued"
+	QueueActive = "active"
+	QueuePaused = "paused"
+	QueueFailed = "failed"
+	QueueDone   = "done"
+)
+
+// DeviceSync records a chapter copied to an e-reader by 'mangas sync'
+type DeviceSync struct {
+	DeviceID   string
+	ChapterID  string
+	SourceHash string // SHA-256 of the library file when it was copied
+	Path       string // Relative to the device root
+	Size       int64  // Size of the file written to the device
+	SyncedAt   time.Time
+}
+
+// ChapterChecksum records the SHA-256 of a downloaded chapter's file and of
+// its pages, so 'mangas verify' can tell when a file is damaged
+type ChapterChecksum struct {
+	ChapterID  string
+	Path       string
+	SHA256     string   // Of the file, shared by the chapters of a volume
+	Size       int64    // Of the file
+	Pages      []string // SHA-256 of each page image, in reading order
+	RecordedAt time.Time
+}
+
+// SourceStat aggregates the requests made to a source, per day when stored
+type SourceStat struct {
+	Source   string
+	Day      time.Time
+	Requests int64
+	Failures int64
+	Latency  time.Duration // Total time the requests took
+	Bytes    int64
+}
+
+// QueueItem is a chapter waiting in the persistent download queue
+type QueueItem struct {
+	ChapterID string
+	MangaID   string
+	Position  int
+	Status    string // One of the Queue* statuses
+	Error     string // Last error when Status is "failed"
+	AddedAt   time.Time
+	UpdatedAt time.Time
 }
@@ -0,0 +1,37 @@
+package data
+
+import "database/sql"
+
+// SaveMangaSettings replaces the settings of a manga, removing them when
+// none is set
+func (r *Repository) SaveMangaSettings(settings *MangaSettings) error {
+	if settings.IsZero() {
+		_, err := r.exec(`DELETE FROM manga_settings WHERE manga_id = ?`, settings.MangaID)
+		return err
+	}
+	_, err := r.exec(`INSERT INTO manga_settings (manga_id, language, scan_group, format, bundle_mode)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (manga_id) DO UPDATE SET
+			language = excluded.language,
+			scan_group = excluded.scan_group,
+			format = excluded.format,
+			bundle_mode = excluded.bundle_mode`,
+		settings.MangaID, settings.Language, settings.Group, settings.Format, settings.BundleMode)
+	return err
+}
+
+// GetMangaSettings returns the settings of a manga, empty ones when none
+// were saved
+func (r *Repository) GetMangaSettings(mangaID string) (*MangaSettings, error) {
+	settings := &MangaSettings{MangaID: mangaID}
+	err := r.db.QueryRow(`SELECT COALESCE(language, ''), COALESCE(scan_group, ''), COALESCE(format, ''), COALESCE(bundle_mode, '')
+		FROM manga_settings WHERE manga_id = ?`, mangaID).Scan(
+		&settings.Language, &settings.Group, &settings.Format, &settings.BundleMode)
+	if err == sql.ErrNoRows {
+		return settings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
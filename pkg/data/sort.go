@@ -0,0 +1,78 @@
+package data
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MangaSort orders library listings
+type MangaSort string
+
+const (
+	SortDefault MangaSort = ""        // By name, or by relevance when searching
+	SortName    MangaSort = "name"    // Alphabetically
+	SortAdded   MangaSort = "added"   // Last added to the library first
+	SortUpdated MangaSort = "updated" // Last updated first, new chapters included
+)
+
+// MangaSorts lists the orders a listing can be sorted by, in the order the
+// TUI cycles through them
+var MangaSorts = []MangaSort{SortDefault, SortName, SortAdded, SortUpdated}
+
+// ParseMangaSort parses a sort name, "" keeps the default order
+func ParseMangaSort(name string) (MangaSort, error) {
+	sort := MangaSort(strings.ToLower(strings.TrimSpace(name)))
+	if !slices.Contains(MangaSorts, sort) {
+		return "", fmt.Errorf("unknown sort %q (expected name, added or updated)", name)
+	}
+	return sort, nil
+}
+
+// SortMangas orders mangas in place. Mangas without a timestamp go last,
+// ties are broken by name. SortDefault keeps the order they came in.
+func SortMangas(mangas []*Manga, sort MangaSort) {
+	byName := func(a, b *Manga) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	}
+	switch sort {
+	case SortName:
+		slices.SortStableFunc(mangas, byName)
+	case SortAdded, SortUpdated:
+		at := func(m *Manga) time.Time {
+			if sort == SortAdded {
+				return m.AddedAt
+			}
+			return m.UpdatedAt
+		}
+		slices.SortStableFunc(mangas, func(a, b *Manga) int {
+			if c := compareNewest(at(a), at(b)); c != 0 {
+				return c
+			}
+			return byName(a, b)
+		})
+	}
+}
+
+// compareNewest orders times newest first, zero times last
+func compareNewest(a, b time.Time) int {
+	switch {
+	case a.IsZero() || b.IsZero():
+		return compareBool(a.IsZero(), b.IsZero())
+	default:
+		return b.Compare(a)
+	}
+}
+
+// compareBool orders false before true
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
@@ -0,0 +1,44 @@
+package data
+
+import (
+	"time"
+)
+
+// AddSourceStat adds the counters of stat to those stored for its source on
+// its day
+func (r *Repository) AddSourceStat(stat *SourceStat) error {
+	_, err := r.exec(`INSERT INTO source_stats (source, day, requests, failures, latency_ms, bytes)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (source, day) DO UPDATE SET
+			requests = source_stats.requests + excluded.requests,
+			failures = source_stats.failures + excluded.failures,
+			latency_ms = source_stats.latency_ms + excluded.latency_ms,
+			bytes = source_stats.bytes + excluded.bytes`,
+		stat.Source, stat.Day.Format(time.DateOnly), stat.Requests, stat.Failures, stat.Latency.Milliseconds(), stat.Bytes)
+	return err
+}
+
+// GetSourceStats returns the counters of every source summed since a day,
+// or over all time when since is zero, sorted by source. Day is the first
+// day with requests.
+func (r *Repository) GetSourceStats(since time.Time) ([]*SourceStat, error) {
+	rows, err := r.db.Query(`SELECT source, min(day), sum(requests), sum(failures), sum(latency_ms), sum(bytes)
+		FROM source_stats WHERE day >= ?
+		GROUP BY source ORDER BY source`, since.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []*SourceStat
+	for rows.Next() {
+		stat := &SourceStat{}
+		var latencyMs int64
+		if err := rows.Scan(&stat.Source, &stat.Day, &stat.Requests, &stat.Failures, &latencyMs, &stat.Bytes); err != nil {
+			return nil, err
+		}
+		stat.Latency = time.Duration(latencyMs) * time.Millisecond
+		stats = append(stats, stat)
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,125 @@
+package data
+
+import (
+	"time"
+)
+
+// LibraryStats aggregates the library for 'mangas stats' and the stats
+// screen
+type LibraryStats struct {
+	Mangas     int
+	Chapters   int
+	Downloaded int
+	Read       int
+	PagesRead  int   // Pages of the chapters read, and of the ones being read
+	DiskUsage  int64 // Bytes of the downloaded books
+
+	PerManga  []*MangaStats // Largest on disk first
+	Downloads []*DayCount   // Chapters downloaded per day, oldest first
+}
+
+// MangaStats aggregates the chapters of a manga
+type MangaStats struct {
+	MangaID    string
+	Name       string
+	Chapters   int
+	Downloaded int
+	Read       int
+	DiskUsage  int64 // Bytes of its downloaded books
+}
+
+// DayCount counts events of a day
+type DayCount struct {
+	Day   time.Time
+	Count int
+}
+
+// chapterPages is the page count of a chapter recorded with its checksums,
+// NULL when unknown. Used over chapter_checksums aliased s.
+const chapterPages = `CASE WHEN trim(COALESCE(s.pages, '')) = '' THEN NULL
+	ELSE len(string_split(trim(s.pages), ' ')) END`
+
+// GetLibraryStats aggregates the library, with the chapters downloaded on
+// each of the last days. Sizes and page counts come from the checksums
+// recorded at download, the chapters of a volume sharing one book are
+// counted once.
+func (r *Repository) GetLibraryStats(days int) (*LibraryStats, error) {
+	stats := &LibraryStats{}
+	err := r.db.QueryRow(`SELECT
+			(SELECT count(*) FROM mangas),
+			count(*),
+			count(*) FILTER (WHERE c.downloaded),
+			count(*) FILTER (WHERE COALESCE(c.read, false)),
+			COALESCE(sum(CASE WHEN COALESCE(c.read, false) THEN COALESCE(`+chapterPages+`, c.last_read_page, 0)
+				ELSE COALESCE(c.last_read_page, 0) END), 0)
+		FROM chapters c LEFT JOIN chapter_checksums s ON s.chapter_id = c.id`).Scan(
+		&stats.Mangas, &stats.Chapters, &stats.Downloaded, &stats.Read, &stats.PagesRead)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`WITH books AS (
+			SELECT DISTINCT c.manga_id, s.path, s.size
+			FROM chapters c JOIN chapter_checksums s ON s.chapter_id = c.id
+			WHERE c.downloaded
+		), usage AS (
+			SELECT manga_id, sum(size) AS size FROM books GROUP BY manga_id
+		)
+		SELECT m.id, m.name, count(c.id),
+			count(c.id) FILTER (WHERE c.downloaded),
+			count(c.id) FILTER (WHERE COALESCE(c.read, false)),
+			COALESCE(any_value(u.size), 0) AS size
+		FROM mangas m
+		LEFT JOIN chapters c ON c.manga_id = m.id
+		LEFT JOIN usage u ON u.manga_id = m.id
+		GROUP BY m.id, m.name
+		ORDER BY size DESC, m.name`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		manga := &MangaStats{}
+		if err := rows.Scan(&manga.MangaID, &manga.Name, &manga.Chapters, &manga.Downloaded, &manga.Read, &manga.DiskUsage); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats.DiskUsage += manga.DiskUsage
+		stats.PerManga = append(stats.PerManga, manga)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if days > 0 {
+		if stats.Downloads, err = r.downloadsPerDay(days); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+// downloadsPerDay counts the chapters downloaded on each of the last days,
+// today included, days without downloads too
+func (r *Repository) downloadsPerDay(days int) ([]*DayCount, error) {
+	today := time.Now()
+	first := today.AddDate(0, 0, -(days - 1))
+	rows, err := r.db.Query(`SELECT day::DATE, count(c.id)
+		FROM range(?::DATE, ?::DATE + 1, INTERVAL 1 DAY) days(day)
+		LEFT JOIN chapters c ON c.downloaded AND c.downloaded_at::DATE = day::DATE
+		GROUP BY day ORDER BY day`, first.Format(time.DateOnly), today.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []*DayCount
+	for rows.Next() {
+		count := &DayCount{}
+		if err := rows.Scan(&count.Day, &count.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
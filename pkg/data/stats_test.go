@@ -0,0 +1,109 @@
+package data
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetLibraryStats(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo.SaveManga(&Manga{ID: "a", Name: "Alpha", Source: "mangadex"})
+	repo.SaveManga(&Manga{ID: "b", Name: "Beta", Source: "mangadex"})
+	repo.SaveManga(&Manga{ID: "c", Name: "Gamma", Source: "mangadex"})
+	for _, ch := range []*Chapter{
+		{ID: "a-1", MangaID: "a", Number: "1", Downloaded: true},
+		{ID: "a-2", MangaID: "a", Number: "2", Downloaded: true},
+		{ID: "a-3", MangaID: "a", Number: "3"},
+		{ID: "b-1", MangaID: "b", Number: "1", Downloaded: true},
+		{ID: "b-2", MangaID: "b", Number: "2", Downloaded: true},
+	} {
+		repo.SaveChapter(ch)
+	}
+	pages := func(n int) []string { return strings.Fields(strings.Repeat("hash ", n)) }
+	repo.SaveChapterChecksum(&ChapterChecksum{ChapterID: "a-1", Path: "a-1.epub", Size: 1000, Pages: pages(10)})
+	repo.SaveChapterChecksum(&ChapterChecksum{ChapterID: "a-2", Path: "a-2.epub", Size: 2000, Pages: pages(20)})
+	// A volume: one book for two chapters
+	repo.SaveChapterChecksum(&ChapterChecksum{ChapterID: "b-1", Path: "b-v1.epub", Size: 5000, Pages: pages(30)})
+	repo.SaveChapterChecksum(&ChapterChecksum{ChapterID: "b-2", Path: "b-v1.epub", Size: 5000, Pages: pages(25)})
+	repo.MarkChapterRead("a-1", true)
+	repo.MarkChapterRead("b-1", true)
+	repo.SetReadProgress("a-2", 4)
+
+	stats, err := repo.GetLibraryStats(7)
+	if err != nil {
+		t.Fatalf("GetLibraryStats() error = %v", err)
+	}
+	if stats.Mangas != 3 || stats.Chapters != 5 || stats.Downloaded != 4 || stats.Read != 2 {
+		t.Errorf("Unexpected totals: %+v", stats)
+	}
+	if stats.PagesRead != 44 {
+		t.Errorf("PagesRead = %d, want 44", stats.PagesRead)
+	}
+	if stats.DiskUsage != 8000 {
+		t.Errorf("DiskUsage = %d, want 8000 with the volume counted once", stats.DiskUsage)
+	}
+	if len(stats.PerManga) != 3 || stats.PerManga[0].Name != "Beta" || stats.PerManga[1].DiskUsage != 3000 {
+		t.Errorf("Expected Beta, then Alpha with 3000 bytes, got %+v %+v", stats.PerManga[0], stats.PerManga[1])
+	}
+	if gamma := stats.PerManga[2]; gamma.Chapters != 0 || gamma.DiskUsage != 0 {
+		t.Errorf("Expected Gamma empty, got %+v", gamma)
+	}
+
+	if len(stats.Downloads) != 7 {
+		t.Fatalf("Expected 7 days of downloads, got %d", len(stats.Downloads))
+	}
+	today := stats.Downloads[6]
+	if today.Day.Format(time.DateOnly) != time.Now().Format(time.DateOnly) || today.Count != 4 {
+		t.Errorf("Expected 4 downloads today, got %d on %s", today.Count, today.Day.Format(time.DateOnly))
+	}
+	if stats.Downloads[0].Count != 0 {
+		t.Errorf("Expected no downloads a week ago, got %d", stats.Downloads[0].Count)
+	}
+}
+
+func TestGetLibraryStats_DownloadDays(t *testing.T) {
+	repo, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo.SaveManga(&Manga{ID: "a", Name: "Alpha", Source: "mangadex"})
+	repo.SaveChapter(&Chapter{ID: "a-1", MangaID: "a", Number: "1"})
+	repo.SaveChapter(&Chapter{ID: "a-2", MangaID: "a", Number: "2"})
+	if err := repo.UpdateChapterStatus("a-1", true, "/a-1.epub"); err != nil {
+		t.Fatalf("UpdateChapterStatus() error = %v", err)
+	}
+	if err := repo.UpdateChapterStatus("a-2", true, "/a-2.epub"); err != nil {
+		t.Fatalf("UpdateChapterStatus() error = %v", err)
+	}
+	threeDaysAgo := time.Now().AddDate(0, 0, -3)
+	if _, err := repo.db.Exec(`UPDATE chapters SET downloaded_at = ? WHERE id = 'a-1'`, threeDaysAgo); err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify, migrate and repair record checksums and move or rewrite books
+	// today, which are not downloads
+	repo.SaveChapterChecksum(&ChapterChecksum{ChapterID: "a-1", Path: "/a-1.cbz", SHA256: "hash"})
+	if err := repo.UpdateChapterStatus("a-1", true, "/a-1.cbz"); err != nil {
+		t.Fatalf("UpdateChapterStatus() error = %v", err)
+	}
+	if err := repo.MoveChapterFiles(map[string]string{"/a-1.cbz": "/Alpha/a-1.cbz"}); err != nil {
+		t.Fatalf("MoveChapterFiles() error = %v", err)
+	}
+	repo.SaveChapter(&Chapter{ID: "a-1", MangaID: "a", Number: "1", Downloaded: true, FilePath: "/Alpha/a-1.cbz"})
+	// Chapters no longer downloaded are not counted
+	if err := repo.UpdateChapterStatus("a-2", false, ""); err != nil {
+		t.Fatalf("UpdateChapterStatus() error = %v", err)
+	}
+
+	stats, err := repo.GetLibraryStats(7)
+	if err != nil {
+		t.Fatalf("GetLibraryStats() error = %v", err)
+	}
+	for i, want := range []int{0, 0, 0, 1, 0, 0, 0} {
+		if got := stats.Downloads[i].Count; got != want {
+			t.Errorf("Downloads on %s = %d, want %d", stats.Downloads[i].Day.Format(time.DateOnly), got, want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package integrations
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// AltTextMode is how the ALT text of page images is written
+type AltTextMode string
+
+const (
+	AltTextPage        AltTextMode = "page"        // "Page 3", the default
+	AltTextDescriptive AltTextMode = "descriptive" // "Naruto, Chapter 12, page 3 of 20"
+)
+
+// ParseAltTextMode parses an ALT text mode name
+func ParseAltTextMode(name string) (AltTextMode, error) {
+	switch mode := AltTextMode(strings.ToLower(name)); mode {
+	case AltTextPage, AltTextDescriptive:
+		return mode, nil
+	case "":
+		return AltTextPage, nil
+	}
+	return "", fmt.Errorf("unknown ALT text mode %q (use page or descriptive)", name)
+}
+
+// alt returns the ALT text of page (from 1) of total, label being its short
+// name ("Page 3") and book what it belongs to ("Naruto, Chapter 12")
+func (m AltTextMode) alt(label, book string, page, total int) string {
+	if m == AltTextDescriptive {
+		return fmt.Sprintf("%s, page %d of %d", book, page, total)
+	}
+	return label
+}
+
+// accessibilityProperty is a schema.org accessibility declaration of the
+// package document
+type accessibilityProperty struct {
+	Property string
+	Value    string
+}
+
+// accessibilityMetadata returns the accessibility declarations of a book made
+// of page images, as checked by library tools and validators such as Ace.
+// textual is set when the ALT text of the pages holds their recognized text.
+func accessibilityMetadata(textual bool) []accessibilityProperty {
+	properties := []accessibilityProperty{
+		{"schema:accessMode", "visual"},
+		{"schema:accessModeSufficient", "visual"},
+	}
+	summary := "Comic pages as images, each with ALT text naming the page. Reading order follows the pages."
+	if textual {
+		properties = append(properties, accessibilityProperty{"schema:accessModeSufficient", "textual"})
+		summary = "Comic pages as images, each with ALT text holding the text recognized on the page. Reading order follows the pages."
+	}
+	return append(properties,
+		accessibilityProperty{"schema:accessibilityFeature", "alternativeText"},
+		accessibilityProperty{"schema:accessibilityFeature", "readingOrder"},
+		accessibilityProperty{"schema:accessibilityFeature", "tableOfContents"},
+		accessibilityProperty{"schema:accessibilityHazard", "none"},
+		accessibilityProperty{"schema:accessibilitySummary", summary},
+	)
+}
+
+// addAccessibilityMetadata declares the accessibility of an EPUB written by
+// go-epub, which has no way to add custom metadata, in its package document
+func addAccessibilityMetadata(epubPath string, textual bool) error {
+	return addPackageMetadata(epubPath, textual, "")
+}
+
+// addPackageMetadata declares the accessibility of an EPUB written by go-epub
+// in its package document, followed by the extra meta elements
+func addPackageMetadata(epubPath string, textual bool, extra string) error {
+	var meta strings.Builder
+	for _, p := range accessibilityMetadata(textual) {
+		fmt.Fprintf(&meta, "    <meta property=\"%s\">%s</meta>\n", p.Property, xmlEscape(p.Value))
+	}
+	meta.WriteString(extra)
+
+	reader, err := zip.OpenReader(epubPath)
+	if err != nil {
+		return fmt.Errorf("failed to open EPUB: %w", err)
+	}
+	defer reader.Close()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	found := false
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		if err != nil {
+			return err
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+
+		if strings.HasSuffix(file.Name, ".opf") {
+			end := bytes.LastIndex(content, []byte("</metadata>"))
+			if end < 0 {
+				return fmt.Errorf("no metadata in %s", file.Name)
+			}
+			content = append(content[:end:end], append([]byte(meta.String()), content[end:]...)...)
+			found = true
+		}
+
+		// The mimetype stays first and uncompressed
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: file.Name, Method: file.Method, Modified: file.Modified})
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(content); err != nil {
+			return err
+		}
+	}
+	if !found {
+		return fmt.Errorf("no package document in EPUB")
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	tempPath := epubPath + ".tmp"
+	if err := os.WriteFile(tempPath, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, epubPath)
+}
@@ -0,0 +1,80 @@
+package integrations
+
+import (
+	"archive/zip"
+	"strings"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+func TestEPubBuilder_Accessibility(t *testing.T) {
+	dir := t.TempDir()
+	builder := NewEPubBuilder(dir)
+	if err := builder.Init(&data.Manga{ID: "m1", Name: "A11y Manga"}, &data.Chapter{ID: "c1", Number: "12"}); err != nil {
+		t.Fatalf("Init() failed: %v", err)
+	}
+	builder.SetAltText(AltTextDescriptive)
+	for i := 1; i <= 2; i++ {
+		builder.Next(ImageData{Content: createTestPage(t, 10, 20), ContentType: "image/png", Index: i})
+	}
+
+	epubPath, err := builder.Done()
+	if err != nil {
+		t.Fatalf("Done() failed: %v", err)
+	}
+
+	opf := readEPUBFile(t, epubPath, ".opf")
+	for _, want := range []string{
+		`<meta property="schema:accessMode">visual</meta>`,
+		`<meta property="schema:accessModeSufficient">visual</meta>`,
+		`<meta property="schema:accessibilityFeature">alternativeText</meta>`,
+		`<meta property="schema:accessibilityHazard">none</meta>`,
+		`<meta property="schema:accessibilitySummary">`,
+	} {
+		if !strings.Contains(opf, want) {
+			t.Errorf("package document misses %s:\n%s", want, opf)
+		}
+	}
+	if strings.Contains(opf, "textual") {
+		t.Error("Pages without recognized text are not sufficient as text")
+	}
+
+	section := readEPUBFile(t, epubPath, "section0001.xhtml")
+	if !strings.Contains(section, `alt="A11y Manga, Chapter 12, page 2 of 2"`) {
+		t.Errorf("Expected descriptive ALT text, got:\n%s", section)
+	}
+
+	// The archive is still a valid EPUB
+	reader, err := zip.OpenReader(epubPath)
+	if err != nil {
+		t.Fatalf("failed to open EPUB: %v", err)
+	}
+	defer reader.Close()
+	if reader.File[0].Name != "mimetype" || reader.File[0].Method != zip.Store {
+		t.Error("Expected an uncompressed mimetype as the first entry")
+	}
+}
+
+func TestAccessibilityMetadata_Textual(t *testing.T) {
+	var sufficient []string
+	for _, p := range accessibilityMetadata(true) {
+		if p.Property == "schema:accessModeSufficient" {
+			sufficient = append(sufficient, p.Value)
+		}
+	}
+	if strings.Join(sufficient, ",") != "visual,textual" {
+		t.Errorf("accessModeSufficient = %v, want visual and textual", sufficient)
+	}
+}
+
+func TestParseAltTextMode(t *testing.T) {
+	for name, want := range map[string]AltTextMode{"": AltTextPage, "page": AltTextPage, "Descriptive": AltTextDescriptive} {
+		if got, err := ParseAltTextMode(name); err != nil || got != want {
+			t.Errorf("ParseAltTextMode(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseAltTextMode("verbose"); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
@@ -0,0 +1,258 @@
+package integrations
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+// bookIdentifierPrefix starts the identifier of the books written by mangas
+const bookIdentifierPrefix = "urn:mangas:"
+
+var (
+	chapterFilePattern  = regexp.MustCompile(`^(.+)_ch_(.+)$`)
+	volumeFilePattern   = regexp.MustCompile(`^(.+)_vol_(.+)$`)
+	chapterLabelPattern = regexp.MustCompile(`^(?:Vol\. (.+?), )?Chapter (\S+?)(?:: (.*))?$`)
+)
+
+// BookInfo describes a downloaded EPUB or CBZ, as far as the file tells
+type BookInfo struct {
+	Path        string
+	Source      string // Empty for books written before identifiers were embedded
+	MangaID     string // Empty for books written before identifiers were embedded
+	MangaName   string
+	Description string
+	Language    string
+	Chapters    []BookChapter
+}
+
+// BookChapter is a chapter found in a book
+type BookChapter struct {
+	ID     string // Empty when the book does not record it
+	Volume string
+	Number string
+	Title  string
+}
+
+// BookIdentifier returns the identifier embedded in downloaded books so the
+// library can be rebuilt from them. chapter is nil for books holding several
+// chapters.
+func BookIdentifier(manga *data.Manga, chapter *data.Chapter) string {
+	parts := []string{url.QueryEscape(manga.Source), url.QueryEscape(manga.ID)}
+	if chapter != nil {
+		parts = append(parts, url.QueryEscape(chapter.ID))
+	}
+	return bookIdentifierPrefix + strings.Join(parts, ":")
+}
+
+// parseBookIdentifier splits an identifier written by BookIdentifier
+func parseBookIdentifier(identifier string) (source, mangaID, chapterID string, ok bool) {
+	if !strings.HasPrefix(identifier, bookIdentifierPrefix) {
+		return "", "", "", false
+	}
+	parts := strings.Split(strings.TrimPrefix(identifier, bookIdentifierPrefix), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return "", "", "", false
+	}
+	values := make([]string, 3)
+	for i, part := range parts {
+		value, err := url.QueryUnescape(part)
+		if err != nil {
+			return "", "", "", false
+		}
+		values[i] = value
+	}
+	if values[1] == "" {
+		return "", "", "", false
+	}
+	return values[0], values[1], values[2], true
+}
+
+// ReadBookInfo reads the manga and chapters of a downloaded EPUB or CBZ from
+// its embedded metadata, falling back to its filename
+func ReadBookInfo(bookPath string) (*BookInfo, error) {
+	switch strings.ToLower(filepath.Ext(bookPath)) {
+	case ".epub":
+		return readEPUBInfo(bookPath)
+	case ".cbz":
+		return readCBZInfo(bookPath)
+	default:
+		return nil, fmt.Errorf("unsupported book format: %s", filepath.Ext(bookPath))
+	}
+}
+
+type opfPackage struct {
+	Metadata struct {
+		Identifiers []string `xml:"identifier"`
+		Title       string   `xml:"title"`
+		Description string   `xml:"description"`
+		Language    string   `xml:"language"`
+	} `xml:"metadata"`
+}
+
+type ncxDocument struct {
+	NavPoints []struct {
+		Label string `xml:"navLabel>text"`
+	} `xml:"navMap>navPoint"`
+}
+
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+// readEPUBInfo reads a chapter or volume EPUB written by EPubBuilder or
+// VolumeBuilder
+func readEPUBInfo(bookPath string) (*BookInfo, error) {
+	reader, err := zip.OpenReader(bookPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open EPUB: %w", err)
+	}
+	defer reader.Close()
+
+	var container epubContainer
+	if err := readZipXML(&reader.Reader, "META-INF/container.xml", &container); err != nil {
+		return nil, err
+	}
+	if len(container.Rootfiles) == 0 {
+		return nil, fmt.Errorf("EPUB has no package document")
+	}
+	var opf opfPackage
+	if err := readZipXML(&reader.Reader, container.Rootfiles[0].FullPath, &opf); err != nil {
+		return nil, err
+	}
+
+	info := &BookInfo{
+		Path:        bookPath,
+		MangaName:   opf.Metadata.Title,
+		Description: opf.Metadata.Description,
+		Language:    opf.Metadata.Language,
+	}
+	chapterID := ""
+	for _, identifier := range opf.Metadata.Identifiers {
+		if source, mangaID, id, ok := parseBookIdentifier(strings.TrimSpace(identifier)); ok {
+			info.Source, info.MangaID, chapterID = source, mangaID, id
+			break
+		}
+	}
+
+	for _, f := range reader.File {
+		if path.Ext(f.Name) != ".ncx" {
+			continue
+		}
+		var ncx ncxDocument
+		if err := readZipXML(&reader.Reader, f.Name, &ncx); err != nil {
+			return nil, err
+		}
+		for _, point := range ncx.NavPoints {
+			if chapter, ok := parseChapterLabel(strings.TrimSpace(point.Label)); ok {
+				info.Chapters = append(info.Chapters, chapter)
+			}
+		}
+		break
+	}
+
+	base := strings.TrimSuffix(filepath.Base(bookPath), filepath.Ext(bookPath))
+	if m := volumeFilePattern.FindStringSubmatch(base); m != nil {
+		// Volumes are titled "<name> Vol. <volume>"
+		info.MangaName = strings.TrimSuffix(info.MangaName, " Vol. "+m[2])
+		for i := range info.Chapters {
+			if info.Chapters[i].Volume == "" {
+				info.Chapters[i].Volume = m[2]
+			}
+		}
+		if info.MangaName == "" {
+			info.MangaName = m[1]
+		}
+	} else if m := chapterFilePattern.FindStringSubmatch(base); m != nil {
+		if len(info.Chapters) == 0 {
+			info.Chapters = []BookChapter{{Number: m[2]}}
+		}
+		if len(info.Chapters) == 1 {
+			info.Chapters[0].ID = chapterID
+		}
+		if info.MangaName == "" {
+			info.MangaName = m[1]
+		}
+	}
+
+	if info.MangaName == "" || len(info.Chapters) == 0 {
+		return nil, fmt.Errorf("no manga chapters found in %s", filepath.Base(bookPath))
+	}
+	return info, nil
+}
+
+// readCBZInfo reads a chapter CBZ written by ExportCBZ
+func readCBZInfo(bookPath string) (*BookInfo, error) {
+	reader, err := zip.OpenReader(bookPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CBZ: %w", err)
+	}
+	defer reader.Close()
+
+	info := &BookInfo{Path: bookPath}
+	var comic comicInfo
+	if err := readZipXML(&reader.Reader, "ComicInfo.xml", &comic); err == nil {
+		info.MangaName = comic.Series
+		info.Description = comic.Summary
+		chapter := BookChapter{Volume: comic.Volume, Number: comic.Number, Title: comic.Title}
+		if source, mangaID, chapterID, ok := parseBookIdentifier(strings.TrimSpace(comic.Notes)); ok {
+			info.Source, info.MangaID, chapter.ID = source, mangaID, chapterID
+		}
+		if chapter.Number != "" {
+			info.Chapters = []BookChapter{chapter}
+		}
+	}
+
+	base := strings.TrimSuffix(filepath.Base(bookPath), filepath.Ext(bookPath))
+	if m := chapterFilePattern.FindStringSubmatch(base); m != nil {
+		if info.MangaName == "" {
+			info.MangaName = m[1]
+		}
+		if len(info.Chapters) == 0 {
+			info.Chapters = []BookChapter{{Number: m[2]}}
+		}
+	}
+
+	if info.MangaName == "" || len(info.Chapters) == 0 {
+		return nil, fmt.Errorf("no manga chapters found in %s", filepath.Base(bookPath))
+	}
+	return info, nil
+}
+
+// parseChapterLabel parses a table of contents entry such as
+// "Vol. 2, Chapter 10: Title"
+func parseChapterLabel(label string) (BookChapter, bool) {
+	m := chapterLabelPattern.FindStringSubmatch(label)
+	if m == nil {
+		return BookChapter{}, false
+	}
+	return BookChapter{Volume: m[1], Number: m[2], Title: m[3]}, true
+}
+
+// readZipXML decodes an XML file of an archive into v
+func readZipXML(reader *zip.Reader, name string, v any) error {
+	f, err := reader.Open(name)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if err := xml.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return nil
+}
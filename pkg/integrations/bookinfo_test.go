@@ -0,0 +1,114 @@
+package integrations
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+func TestBookIdentifier(t *testing.T) {
+	manga := &data.Manga{ID: "a:b c", Source: "mangadex"}
+	chapter := &data.Chapter{ID: "ch/1"}
+
+	source, mangaID, chapterID, ok := parseBookIdentifier(BookIdentifier(manga, chapter))
+	if !ok || source != "mangadex" || mangaID != "a:b c" || chapterID != "ch/1" {
+		t.Errorf("parseBookIdentifier() = %q, %q, %q, %v", source, mangaID, chapterID, ok)
+	}
+	if _, mangaID, chapterID, ok := parseBookIdentifier(BookIdentifier(manga, nil)); !ok || mangaID != "a:b c" || chapterID != "" {
+		t.Errorf("Expected a manga-only identifier, got %q, %q", mangaID, chapterID)
+	}
+	if _, _, _, ok := parseBookIdentifier("urn:uuid:1234"); ok {
+		t.Error("Expected foreign identifiers to be rejected")
+	}
+}
+
+func TestReadBookInfo(t *testing.T) {
+	dir := t.TempDir()
+	manga := &data.Manga{ID: "manga-1", Name: "Test Manga", Description: "A test", Source: "mangadex"}
+	chapter := &data.Chapter{ID: "ch-3", Number: "3", Volume: "1", Title: "The Third"}
+
+	builder := NewEPubBuilder(dir)
+	builder.Init(manga, chapter)
+	builder.Next(ImageData{Content: createTestPage(t, 10, 10), ContentType: "image/png"})
+	epubPath, err := builder.Done()
+	if err != nil {
+		t.Fatalf("Done() failed: %v", err)
+	}
+
+	info, err := ReadBookInfo(epubPath)
+	if err != nil {
+		t.Fatalf("ReadBookInfo(epub) error = %v", err)
+	}
+	if info.MangaID != "manga-1" || info.Source != "mangadex" || info.MangaName != "Test Manga" || info.Description != "A test" {
+		t.Errorf("Unexpected manga info: %+v", info)
+	}
+	want := BookChapter{ID: "ch-3", Volume: "1", Number: "3", Title: "The Third"}
+	if len(info.Chapters) != 1 || info.Chapters[0] != want {
+		t.Errorf("Chapters = %+v, want %+v", info.Chapters, want)
+	}
+
+	// Volumes list their chapters in the table of contents
+	volume := NewVolumeBuilder(dir)
+	volume.Init(manga, "2")
+	volume.AddChapter(&data.Chapter{ID: "ch-10", Number: "10", Title: "Start"}, []ImageData{{Content: createTestPage(t, 10, 10), ContentType: "image/png"}})
+	volume.AddChapter(&data.Chapter{ID: "ch-11", Number: "11"}, []ImageData{{Content: createTestPage(t, 10, 10), ContentType: "image/png"}})
+	volumePath, err := volume.Done()
+	if err != nil {
+		t.Fatalf("Done() failed: %v", err)
+	}
+	info, err = ReadBookInfo(volumePath)
+	if err != nil {
+		t.Fatalf("ReadBookInfo(volume) error = %v", err)
+	}
+	if info.MangaName != "Test Manga" || info.MangaID != "manga-1" {
+		t.Errorf("Unexpected volume manga: %q (%q)", info.MangaName, info.MangaID)
+	}
+	if len(info.Chapters) != 2 ||
+		info.Chapters[0] != (BookChapter{Volume: "2", Number: "10", Title: "Start"}) ||
+		info.Chapters[1] != (BookChapter{Volume: "2", Number: "11"}) {
+		t.Errorf("Unexpected volume chapters: %+v", info.Chapters)
+	}
+
+	chapter.FilePath = epubPath
+	cbzPath := filepath.Join(dir, CBZFilename(manga, chapter))
+	if err := ExportCBZ(manga, chapter, cbzPath); err != nil {
+		t.Fatalf("ExportCBZ() error = %v", err)
+	}
+	info, err = ReadBookInfo(cbzPath)
+	if err != nil {
+		t.Fatalf("ReadBookInfo(cbz) error = %v", err)
+	}
+	if info.MangaID != "manga-1" || info.MangaName != "Test Manga" || len(info.Chapters) != 1 || info.Chapters[0] != want {
+		t.Errorf("Unexpected CBZ info: %+v", info)
+	}
+
+	other := filepath.Join(dir, "notes.epub")
+	os.WriteFile(other, []byte("not a zip"), 0644)
+	if _, err := ReadBookInfo(other); err == nil {
+		t.Error("Expected an error for an unreadable EPUB")
+	}
+	if _, err := ReadBookInfo(filepath.Join(dir, "cover.png")); err == nil {
+		t.Error("Expected an error for an unsupported format")
+	}
+}
+
+func TestParseChapterLabel(t *testing.T) {
+	tests := []struct {
+		label string
+		want  BookChapter
+		ok    bool
+	}{
+		{"Chapter 5", BookChapter{Number: "5"}, true},
+		{"Chapter 5.5: Extra: Part 2", BookChapter{Number: "5.5", Title: "Extra: Part 2"}, true},
+		{"Vol. 3, Chapter 12", BookChapter{Volume: "3", Number: "12"}, true},
+		{"Cover", BookChapter{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseChapterLabel(tt.label)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseChapterLabel(%q) = %+v, %v, want %+v, %v", tt.label, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package integrations
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// comicInfo is the ComicInfo.xml metadata read by most comic readers
+type comicInfo struct {
+	XMLName   xml.Name `xml:"ComicInfo"`
+	Series    string   `xml:"Series"`
+	Title     string   `xml:"Title,omitempty"`
+	Number    string   `xml:"Number,omitempty"`
+	Volume    string   `xml:"Volume,omitempty"`
+	Summary   string   `xml:"Summary,omitempty"`
+	Notes     string   `xml:"Notes,omitempty"` // BookIdentifier of the chapter
+	PageCount int      `xml:"PageCount"`
+	Manga     string   `xml:"Manga"`
+}
+
+// CBZOptions tunes how chapters are repackaged as CBZ
+type CBZOptions struct {
+	Webtoon *WebtoonOptions // Slice long strips into pages, nil keeps pages as they are
+}
+
+// ExportCBZ repackages a downloaded chapter EPUB, or CBZ, as a CBZ archive
+// with a ComicInfo.xml describing the chapter
+func ExportCBZ(manga *data.Manga, chapter *data.Chapter, outputPath string) error {
+	return ExportCBZWithOptions(manga, chapter, outputPath, CBZOptions{})
+}
+
+// ExportCBZWithOptions is ExportCBZ with the pages processed as set in options
+func ExportCBZWithOptions(manga *data.Manga, chapter *data.Chapter, outputPath string, options CBZOptions) error {
+	if manga == nil || chapter == nil {
+		return fmt.Errorf("manga and chapter are required")
+	}
+
+	pages, err := ReadBookPages(chapter.FilePath)
+	if err != nil {
+		return err
+	}
+	if options.Webtoon != nil {
+		if pages, err = SliceWebtoonPages(pages, *options.Webtoon); err != nil {
+			return err
+		}
+	}
+
+	if err := utils.CheckOutputPath(outputPath); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create CBZ: %w", err)
+	}
+	defer file.Close()
+
+	return writeCBZ(file, manga, chapter, pages)
+}
+
+// WriteCBZ streams a downloaded chapter as a CBZ archive to out
+func WriteCBZ(out io.Writer, manga *data.Manga, chapter *data.Chapter) error {
+	if manga == nil || chapter == nil {
+		return fmt.Errorf("manga and chapter are required")
+	}
+
+	pages, err := ReadBookPages(chapter.FilePath)
+	if err != nil {
+		return err
+	}
+	return writeCBZ(out, manga, chapter, pages)
+}
+
+// RepackEPUBAsCBZ replaces the chapter EPUB at epubPath with a CBZ of the
+// same name and pages, returning the path of the CBZ. The CBZ is written
+// aside first, so a failure leaves the EPUB as it was.
+func RepackEPUBAsCBZ(manga *data.Manga, chapter *data.Chapter, epubPath string) (string, error) {
+	pages, err := ReadEPUBPages(epubPath)
+	if err != nil {
+		return "", err
+	}
+	path := strings.TrimSuffix(epubPath, filepath.Ext(epubPath)) + ".cbz"
+	file, err := os.CreateTemp(filepath.Dir(path), ".cbz-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create CBZ: %w", err)
+	}
+	defer os.Remove(file.Name())
+	err = file.Chmod(0644) // Temp files are private, books are not
+	if err == nil {
+		err = writeCBZ(file, manga, chapter, pages)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to move CBZ in place: %w", err)
+	}
+	if err := os.Remove(epubPath); err != nil {
+		return "", fmt.Errorf("failed to remove EPUB: %w", err)
+	}
+	return path, nil
+}
+
+func writeCBZ(out io.Writer, manga *data.Manga, chapter *data.Chapter, pages [][]byte) error {
+	w := zip.NewWriter(out)
+	for i, page := range pages {
+		ext := getExtensionFromContentType(http.DetectContentType(page))
+		// Images are already compressed, storing them keeps export fast
+		f, err := w.CreateHeader(&zip.FileHeader{
+			Name:   fmt.Sprintf("%04d%s", i+1, ext),
+			Method: zip.Store,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add page %d: %w", i+1, err)
+		}
+		if _, err := f.Write(page); err != nil {
+			return fmt.Errorf("failed to write page %d: %w", i+1, err)
+		}
+	}
+
+	info, err := xml.MarshalIndent(comicInfo{
+		Series:    manga.Name,
+		Title:     chapter.Title,
+		Number:    chapter.Number,
+		Volume:    chapter.Volume,
+		Summary:   manga.Description,
+		Notes:     BookIdentifier(manga, chapter),
+		PageCount: len(pages),
+		Manga:     "YesAndRightToLeft",
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode ComicInfo: %w", err)
+	}
+	f, err := w.Create("ComicInfo.xml")
+	if err != nil {
+		return fmt.Errorf("failed to add ComicInfo: %w", err)
+	}
+	f.Write([]byte(xml.Header))
+	f.Write(info)
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to finalize CBZ: %w", err)
+	}
+	return nil
+}
+
+// CBZFilename returns the default filename of a chapter CBZ
+func CBZFilename(manga *data.Manga, chapter *data.Chapter) string {
+	return fmt.Sprintf("%s_%s.cbz",
+		sanitizeFilename(manga.Name),
+		sanitizeFilename(fmt.Sprintf("ch_%s", chapter.Number)),
+	)
+}
@@ -0,0 +1,93 @@
+package integrations
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+func TestExportCBZ(t *testing.T) {
+	manga := &data.Manga{ID: "manga-1", Name: "Test Manga", Description: "A test"}
+	chapter := &data.Chapter{ID: "ch-1", Number: "3", Title: "The Third"}
+
+	builder := NewEPubBuilder(t.TempDir())
+	if err := builder.Init(manga, chapter); err != nil {
+		t.Fatalf("Init() failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		builder.Next(ImageData{Content: createTestPage(t, 10, 10+i), ContentType: "image/png", Index: i})
+	}
+	epubPath, err := builder.Done()
+	if err != nil {
+		t.Fatalf("Done() failed: %v", err)
+	}
+	chapter.FilePath = epubPath
+
+	outputPath := filepath.Join(t.TempDir(), "series", CBZFilename(manga, chapter))
+	if err := ExportCBZ(manga, chapter, outputPath); err != nil {
+		t.Fatalf("ExportCBZ() error = %v", err)
+	}
+
+	reader, err := zip.OpenReader(outputPath)
+	if err != nil {
+		t.Fatalf("failed to open CBZ: %v", err)
+	}
+	defer reader.Close()
+
+	var names []string
+	var info string
+	for _, f := range reader.File {
+		names = append(names, f.Name)
+		if f.Name == "ComicInfo.xml" {
+			rc, _ := f.Open()
+			content, _ := io.ReadAll(rc)
+			rc.Close()
+			info = string(content)
+		}
+	}
+
+	if strings.Join(names, ",") != "0001.png,0002.png,ComicInfo.xml" {
+		t.Errorf("Unexpected CBZ entries: %v", names)
+	}
+	for _, want := range []string{"<Series>Test Manga</Series>", "<Number>3</Number>", "<PageCount>2</PageCount>"} {
+		if !strings.Contains(info, want) {
+			t.Errorf("ComicInfo.xml missing %q", want)
+		}
+	}
+}
+
+func TestExportManifest_Write(t *testing.T) {
+	dir := t.TempDir()
+	manifest := &ExportManifest{
+		Collection: "Favorites",
+		Format:     "cbz",
+		CreatedAt:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Series: []ManifestSeries{
+			{MangaID: "manga-1", Name: "One/Two", Folder: SeriesFolder("One/Two"), Chapters: []string{"1"}, Files: []string{"One_Two/a.cbz"}},
+		},
+	}
+
+	path, err := manifest.Write(dir)
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	var decoded ExportManifest
+	if err := json.Unmarshal(content, &decoded); err != nil {
+		t.Fatalf("manifest is not valid JSON: %v", err)
+	}
+	if decoded.Collection != "Favorites" || len(decoded.Series) != 1 || decoded.Series[0].Folder != "One_Two" {
+		t.Errorf("Unexpected manifest: %+v", decoded)
+	}
+}
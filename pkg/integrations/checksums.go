@@ -0,0 +1,201 @@
+package integrations
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// ChecksumsFilename is the checksum manifest written at the root of
+	// backups and exports, in the format of sha256sum so it can also be
+	// checked with `sha256sum -c`
+	ChecksumsFilename = "SHA256SUMS"
+	// SignatureFilename holds the hex HMAC-SHA256 of the checksum manifest
+	SignatureFilename = "SHA256SUMS.sig"
+)
+
+// ErrBadSignature is returned when a checksum manifest was not signed with
+// the given key, or was modified after signing
+var ErrBadSignature = errors.New("checksum manifest signature does not match")
+
+// ChecksumReport is the result of verifying a directory against its
+// checksum manifest
+type ChecksumReport struct {
+	Files    int      // Files listed in the manifest
+	Missing  []string // Listed files that no longer exist
+	Corrupt  []string // Listed files whose content changed
+	Unlisted []string // Files added after the manifest was written
+	Signed   bool     // Whether the manifest is signed
+	Verified bool     // Whether the signature was checked against a key
+}
+
+// OK reports whether every listed file is present and intact
+func (r *ChecksumReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Corrupt) == 0
+}
+
+// WriteChecksums hashes every file under dir into its checksum manifest and,
+// when key is not empty, signs the manifest. It returns how many files were
+// listed.
+func WriteChecksums(dir string, key []byte) (int, error) {
+	files, err := checksumFiles(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var manifest bytes.Buffer
+	for _, file := range files {
+		sum, err := FileSHA256(filepath.Join(dir, filepath.FromSlash(file)))
+		if err != nil {
+			return 0, err
+		}
+		fmt.Fprintf(&manifest, "%s  %s\n", sum, file)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ChecksumsFilename), manifest.Bytes(), 0644); err != nil {
+		return 0, fmt.Errorf("failed to write checksums: %w", err)
+	}
+
+	signaturePath := filepath.Join(dir, SignatureFilename)
+	if len(key) == 0 {
+		// A stale signature would fail every later verification
+		os.Remove(signaturePath)
+		return len(files), nil
+	}
+	if err := os.WriteFile(signaturePath, []byte(signChecksums(manifest.Bytes(), key)+"\n"), 0644); err != nil {
+		return 0, fmt.Errorf("failed to write signature: %w", err)
+	}
+	return len(files), nil
+}
+
+// VerifyChecksums checks the files under dir against its checksum manifest.
+// When key is not empty the manifest must be signed with it; without a key
+// the signature, if any, is not checked.
+func VerifyChecksums(dir string, key []byte) (*ChecksumReport, error) {
+	manifest, err := os.ReadFile(filepath.Join(dir, ChecksumsFilename))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checksums: %w", err)
+	}
+
+	report := &ChecksumReport{}
+	signature, err := os.ReadFile(filepath.Join(dir, SignatureFilename))
+	switch {
+	case err == nil:
+		report.Signed = true
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("failed to read signature: %w", err)
+	}
+
+	if len(key) > 0 {
+		if !report.Signed {
+			return nil, fmt.Errorf("checksum manifest is not signed")
+		}
+		expected := signChecksums(manifest, key)
+		if !hmac.Equal([]byte(expected), bytes.TrimSpace(signature)) {
+			return nil, ErrBadSignature
+		}
+		report.Verified = true
+	}
+
+	listed := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(manifest))
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		sum, file, ok := strings.Cut(scanner.Text(), "  ")
+		if !ok || len(sum) != sha256.Size*2 || file == "" {
+			return nil, fmt.Errorf("checksums line %d is malformed", line)
+		}
+		// Manifests only list relative paths inside dir
+		if !filepath.IsLocal(filepath.FromSlash(file)) {
+			return nil, fmt.Errorf("checksums line %d points outside the directory: %s", line, file)
+		}
+		listed[file] = true
+		report.Files++
+
+		actual, err := FileSHA256(filepath.Join(dir, filepath.FromSlash(file)))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			report.Missing = append(report.Missing, file)
+		case err != nil:
+			return nil, err
+		case !strings.EqualFold(actual, sum):
+			report.Corrupt = append(report.Corrupt, file)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read checksums: %w", err)
+	}
+
+	files, err := checksumFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if !listed[file] {
+			report.Unlisted = append(report.Unlisted, file)
+		}
+	}
+	return report, nil
+}
+
+// checksumFiles returns the files under dir as sorted slash-separated
+// relative paths, leaving out the manifest itself
+func checksumFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != ChecksumsFilename && rel != SignatureFilename {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// FileSHA256 returns the hex encoded SHA-256 of a file
+func FileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func signChecksums(manifest, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(manifest)
+	return hex.EncodeToString(mac.Sum(nil))
+}
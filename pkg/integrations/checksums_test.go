@@ -0,0 +1,127 @@
+package integrations
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestChecksums(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"manifest.json":      "{}",
+		"One Piece/ch_1.cbz": "chapter one",
+		"One Piece/ch_2.cbz": "chapter two",
+		"Naruto/ch_1.cbz":    "naruto",
+	})
+
+	count, err := WriteChecksums(dir, nil)
+	if err != nil || count != 4 {
+		t.Fatalf("WriteChecksums() = %d, %v, want 4 files", count, err)
+	}
+	content, _ := os.ReadFile(filepath.Join(dir, ChecksumsFilename))
+	if !strings.Contains(string(content), "  One Piece/ch_1.cbz\n") {
+		t.Errorf("checksums should list slash-separated relative paths:\n%s", content)
+	}
+
+	report, err := VerifyChecksums(dir, nil)
+	if err != nil || !report.OK() || report.Files != 4 || report.Signed {
+		t.Fatalf("VerifyChecksums() = %+v, %v, want 4 intact unsigned files", report, err)
+	}
+
+	writeTestFiles(t, dir, map[string]string{
+		"One Piece/ch_2.cbz": "chapter tw0",
+		"notes.txt":          "added later",
+	})
+	os.Remove(filepath.Join(dir, "Naruto", "ch_1.cbz"))
+
+	report, err = VerifyChecksums(dir, nil)
+	if err != nil {
+		t.Fatalf("VerifyChecksums() error = %v", err)
+	}
+	if report.OK() {
+		t.Error("report should not be OK with missing and corrupt files")
+	}
+	if len(report.Corrupt) != 1 || report.Corrupt[0] != "One Piece/ch_2.cbz" {
+		t.Errorf("Corrupt = %v", report.Corrupt)
+	}
+	if len(report.Missing) != 1 || report.Missing[0] != "Naruto/ch_1.cbz" {
+		t.Errorf("Missing = %v", report.Missing)
+	}
+	if len(report.Unlisted) != 1 || report.Unlisted[0] != "notes.txt" {
+		t.Errorf("Unlisted = %v", report.Unlisted)
+	}
+}
+
+func TestChecksums_Signature(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"mangas.db": "library"})
+	key := []byte("secret")
+
+	if _, err := WriteChecksums(dir, key); err != nil {
+		t.Fatalf("WriteChecksums() error = %v", err)
+	}
+
+	report, err := VerifyChecksums(dir, key)
+	if err != nil || !report.Signed || !report.Verified {
+		t.Fatalf("VerifyChecksums() = %+v, %v, want a verified signature", report, err)
+	}
+	if report, err := VerifyChecksums(dir, nil); err != nil || !report.Signed || report.Verified {
+		t.Errorf("VerifyChecksums() without key = %+v, %v, want signed but unverified", report, err)
+	}
+	if _, err := VerifyChecksums(dir, []byte("other")); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("VerifyChecksums() with wrong key error = %v, want ErrBadSignature", err)
+	}
+
+	// Rewriting a file's checksum does not go unnoticed once signed
+	path := filepath.Join(dir, ChecksumsFilename)
+	os.WriteFile(path, []byte(strings.Repeat("0", 64)+"  mangas.db\n"), 0644)
+	if _, err := VerifyChecksums(dir, key); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("VerifyChecksums() of a modified manifest error = %v, want ErrBadSignature", err)
+	}
+
+	// Unsigned rewrites drop the signature, so signed checks fail cleanly
+	if _, err := WriteChecksums(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, SignatureFilename)); !os.IsNotExist(err) {
+		t.Error("stale signature should be removed")
+	}
+	if _, err := VerifyChecksums(dir, key); err == nil {
+		t.Error("VerifyChecksums() with key should fail on an unsigned manifest")
+	}
+}
+
+func TestChecksums_Malformed(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{ChecksumsFilename: "not a checksum line\n"})
+	if _, err := VerifyChecksums(dir, nil); err == nil {
+		t.Error("VerifyChecksums() should reject malformed lines")
+	}
+
+	writeTestFiles(t, dir, map[string]string{
+		ChecksumsFilename: strings.Repeat("0", 64) + "  ../outside\n",
+	})
+	if _, err := VerifyChecksums(dir, nil); err == nil {
+		t.Error("VerifyChecksums() should reject paths outside the directory")
+	}
+
+	if _, err := VerifyChecksums(t.TempDir(), nil); err == nil {
+		t.Error("VerifyChecksums() should fail without a manifest")
+	}
+}
@@ -0,0 +1,192 @@
+package integrations
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// ContactSheetOptions configures the layout of a contact sheet
+type ContactSheetOptions struct {
+	Columns    int // Thumbnails per row
+	ThumbWidth int // Width of each thumbnail in pixels, height keeps the page ratio
+	Padding    int // Space around thumbnails in pixels
+	Quality    int // JPEG quality (1-100)
+	Labels     bool
+}
+
+// DefaultContactSheetOptions returns sensible defaults for a contact sheet
+func DefaultContactSheetOptions() ContactSheetOptions {
+	return ContactSheetOptions{
+		Columns:    6,
+		ThumbWidth: 200,
+		Padding:    8,
+		Quality:    85,
+		Labels:     true,
+	}
+}
+
+const contactSheetLabelHeight = 16
+
+// BuildContactSheet renders the pages of a chapter as a grid of numbered
+// thumbnails and returns it encoded as JPEG. Pages that fail to decode are
+// drawn as blank cells so numbering still matches the chapter.
+func BuildContactSheet(pages [][]byte, options ContactSheetOptions) ([]byte, error) {
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no pages provided")
+	}
+	if options.Columns <= 0 || options.ThumbWidth <= 0 {
+		return nil, fmt.Errorf("columns and thumbnail width must be positive")
+	}
+	if options.Quality <= 0 || options.Quality > 100 {
+		options.Quality = 85
+	}
+
+	thumbs := make([]image.Image, len(pages))
+	thumbHeight := 0
+	for i, page := range pages {
+		img, _, err := image.Decode(bytes.NewReader(page))
+		if err != nil {
+			continue
+		}
+		bounds := img.Bounds()
+		height := bounds.Dy() * options.ThumbWidth / bounds.Dx()
+		if height <= 0 {
+			continue
+		}
+
+		thumb := image.NewRGBA(image.Rect(0, 0, options.ThumbWidth, height))
+		draw.ApproxBiLinear.Scale(thumb, thumb.Bounds(), img, bounds, draw.Over, nil)
+		thumbs[i] = thumb
+		if height > thumbHeight {
+			thumbHeight = height
+		}
+	}
+	if thumbHeight == 0 {
+		return nil, fmt.Errorf("none of the pages could be decoded")
+	}
+
+	labelHeight := 0
+	if options.Labels {
+		labelHeight = contactSheetLabelHeight
+	}
+
+	columns := options.Columns
+	if len(pages) < columns {
+		columns = len(pages)
+	}
+	rows := (len(pages) + columns - 1) / columns
+	cellWidth := options.ThumbWidth + options.Padding
+	cellHeight := thumbHeight + labelHeight + options.Padding
+
+	sheet := image.NewRGBA(image.Rect(0, 0,
+		columns*cellWidth+options.Padding,
+		rows*cellHeight+options.Padding,
+	))
+	draw.Draw(sheet, sheet.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	for i, thumb := range thumbs {
+		x := options.Padding + (i%columns)*cellWidth
+		y := options.Padding + (i/columns)*cellHeight
+
+		if thumb != nil {
+			// Center shorter pages vertically in their cell
+			offset := (thumbHeight - thumb.Bounds().Dy()) / 2
+			draw.Draw(sheet, thumb.Bounds().Add(image.Pt(x, y+offset)), thumb, image.Point{}, draw.Src)
+		} else {
+			blank := image.Rect(x, y, x+options.ThumbWidth, y+thumbHeight)
+			draw.Draw(sheet, blank, image.NewUniform(color.Gray{Y: 220}), image.Point{}, draw.Src)
+		}
+
+		if options.Labels {
+			drawLabel(sheet, fmt.Sprintf("%d", i+1), x, y+thumbHeight+contactSheetLabelHeight-3)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, sheet, &jpeg.Options{Quality: options.Quality}); err != nil {
+		return nil, fmt.Errorf("failed to encode contact sheet: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// drawLabel writes text with its baseline at (x, y)
+func drawLabel(img draw.Image, text string, x, y int) {
+	drawer := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(color.Black),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+	drawer.DrawString(text)
+}
+
+// ReadEPUBPages returns the page images of a chapter EPUB in reading order,
+// skipping the manga and chapter covers
+func ReadEPUBPages(epubPath string) ([][]byte, error) {
+	reader, err := zip.OpenReader(epubPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open EPUB: %w", err)
+	}
+	defer reader.Close()
+
+	var files []*zip.File
+	for _, file := range reader.File {
+		name := strings.ToLower(filepath.Base(file.Name))
+		if !strings.HasPrefix(name, "page_") {
+			continue
+		}
+		switch filepath.Ext(name) {
+		case ".jpg", ".jpeg", ".png", ".gif", ".webp":
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no pages found in EPUB")
+	}
+
+	// Pages are named after their PageOrder, so natural name order is reading order
+	sort.Slice(files, func(i, j int) bool {
+		return utils.NaturalLess(files[i].Name, files[j].Name)
+	})
+
+	pages := make([][]byte, 0, len(files))
+	for _, file := range files {
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", file.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.Name, err)
+		}
+		pages = append(pages, content)
+	}
+	return pages, nil
+}
+
+// ContactSheetFilename returns the default filename of a chapter's contact sheet
+func ContactSheetFilename(manga *data.Manga, chapter *data.Chapter) string {
+	return fmt.Sprintf("%s_%s_contact.jpg",
+		sanitizeFilename(manga.Name),
+		sanitizeFilename(fmt.Sprintf("ch_%s", chapter.Number)),
+	)
+}
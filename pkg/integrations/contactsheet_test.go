@@ -0,0 +1,110 @@
+package integrations
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+func createTestPage(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("failed to encode test page: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestBuildContactSheet(t *testing.T) {
+	pages := [][]byte{
+		createTestPage(t, 100, 150),
+		createTestPage(t, 100, 150),
+		createTestPage(t, 100, 150),
+		[]byte("not an image"),
+		createTestPage(t, 200, 150), // Double page spread
+	}
+
+	options := ContactSheetOptions{Columns: 3, ThumbWidth: 50, Padding: 5, Quality: 80, Labels: true}
+	sheet, err := BuildContactSheet(pages, options)
+	if err != nil {
+		t.Fatalf("BuildContactSheet() error = %v", err)
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(sheet))
+	if err != nil {
+		t.Fatalf("contact sheet is not a valid JPEG: %v", err)
+	}
+
+	// 3 columns x 2 rows of 50x75 thumbnails with labels and padding
+	wantWidth := 3*(50+5) + 5
+	wantHeight := 2*(75+contactSheetLabelHeight+5) + 5
+	if img.Bounds().Dx() != wantWidth || img.Bounds().Dy() != wantHeight {
+		t.Errorf("sheet size = %dx%d, want %dx%d", img.Bounds().Dx(), img.Bounds().Dy(), wantWidth, wantHeight)
+	}
+}
+
+func TestBuildContactSheet_Errors(t *testing.T) {
+	defaults := DefaultContactSheetOptions()
+
+	if _, err := BuildContactSheet(nil, defaults); err == nil {
+		t.Error("BuildContactSheet() should fail without pages")
+	}
+	if _, err := BuildContactSheet([][]byte{[]byte("junk")}, defaults); err == nil {
+		t.Error("BuildContactSheet() should fail when no page decodes")
+	}
+
+	options := defaults
+	options.Columns = 0
+	if _, err := BuildContactSheet([][]byte{createTestPage(t, 10, 10)}, options); err == nil {
+		t.Error("BuildContactSheet() should fail with zero columns")
+	}
+}
+
+func TestReadEPUBPages(t *testing.T) {
+	builder := NewEPubBuilder(t.TempDir())
+	manga := &data.Manga{ID: "manga-1", Name: "Test Manga"}
+	chapter := &data.Chapter{ID: "ch-1", Number: "1"}
+	if err := builder.Init(manga, chapter); err != nil {
+		t.Fatalf("Init() failed: %v", err)
+	}
+	builder.SetMangaCover(CoverData{Content: createTestPNG(), ContentType: "image/png"})
+
+	first := createTestPage(t, 10, 20)
+	second := createTestPage(t, 30, 40)
+	builder.Next(ImageData{Content: second, ContentType: "image/png", Index: 1})
+	builder.Next(ImageData{Content: first, ContentType: "image/png", Index: 0})
+
+	epubPath, err := builder.Done()
+	if err != nil {
+		t.Fatalf("Done() failed: %v", err)
+	}
+
+	pages, err := ReadEPUBPages(epubPath)
+	if err != nil {
+		t.Fatalf("ReadEPUBPages() error = %v", err)
+	}
+	if len(pages) != 2 {
+		t.Fatalf("expected 2 pages (cover skipped), got %d", len(pages))
+	}
+	if !bytes.Equal(pages[0], first) || !bytes.Equal(pages[1], second) {
+		t.Error("pages are not in reading order")
+	}
+}
+
+func TestContactSheetFilename(t *testing.T) {
+	got := ContactSheetFilename(&data.Manga{Name: "One/Piece"}, &data.Chapter{Number: "12"})
+	if got != "One_Piece_ch_12_contact.jpg" {
+		t.Errorf("ContactSheetFilename() = %q", got)
+	}
+}
@@ -0,0 +1,184 @@
+package integrations
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Generated covers are 2:3, the shape of most manga volumes
+const (
+	generatedCoverWidth  = 1200
+	generatedCoverHeight = 1800
+	generatedCoverMargin = 120
+)
+
+// coverPalettes are the background colours of generated covers, picked from
+// the title so the books of a series share theirs
+var coverPalettes = []struct{ top, bottom, accent color.RGBA }{
+	{color.RGBA{44, 62, 80, 255}, color.RGBA{16, 24, 32, 255}, color.RGBA{231, 76, 60, 255}},
+	{color.RGBA{88, 24, 69, 255}, color.RGBA{33, 9, 26, 255}, color.RGBA{255, 195, 0, 255}},
+	{color.RGBA{22, 96, 86, 255}, color.RGBA{8, 36, 32, 255}, color.RGBA{241, 196, 15, 255}},
+	{color.RGBA{52, 73, 140, 255}, color.RGBA{18, 25, 52, 255}, color.RGBA{236, 112, 99, 255}},
+	{color.RGBA{120, 40, 31, 255}, color.RGBA{45, 14, 11, 255}, color.RGBA{245, 176, 65, 255}},
+	{color.RGBA{60, 60, 60, 255}, color.RGBA{15, 15, 15, 255}, color.RGBA{93, 173, 226, 255}},
+}
+
+// GenerateCover draws a typographic cover with the title and subtitle lines
+// (chapter, volume...) on a gradient background, for books whose source has
+// no cover art. It is encoded as JPEG.
+func GenerateCover(title string, subtitles ...string) (CoverData, error) {
+	boldFont, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		return CoverData{}, fmt.Errorf("failed to load font: %w", err)
+	}
+	regularFont, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return CoverData{}, fmt.Errorf("failed to load font: %w", err)
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(title))
+	palette := coverPalettes[hash.Sum32()%uint32(len(coverPalettes))]
+
+	img := image.NewRGBA(image.Rect(0, 0, generatedCoverWidth, generatedCoverHeight))
+	for y := 0; y < generatedCoverHeight; y++ {
+		c := blend(palette.top, palette.bottom, float64(y)/generatedCoverHeight)
+		for x := 0; x < generatedCoverWidth; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	// Accent bars framing the text
+	fillRect(img, image.Rect(generatedCoverMargin, 420, generatedCoverWidth-generatedCoverMargin, 436), palette.accent)
+	fillRect(img, image.Rect(generatedCoverMargin, 1380, generatedCoverWidth-generatedCoverMargin, 1396), palette.accent)
+
+	textWidth := generatedCoverWidth - 2*generatedCoverMargin
+	white := color.RGBA{255, 255, 255, 255}
+
+	// The title takes the largest size that fits in four lines
+	y := 540
+	if title = strings.TrimSpace(title); title != "" {
+		face, lines, err := fitText(boldFont, title, textWidth, 4, 120, 48)
+		if err != nil {
+			return CoverData{}, err
+		}
+		y = drawLines(img, face, lines, y, white)
+		face.Close()
+	}
+
+	y += 60
+	for _, subtitle := range subtitles {
+		if subtitle = strings.TrimSpace(subtitle); subtitle == "" {
+			continue
+		}
+		face, lines, err := fitText(regularFont, subtitle, textWidth, 2, 64, 36)
+		if err != nil {
+			return CoverData{}, err
+		}
+		y = drawLines(img, face, lines, y, palette.accent) + 20
+		face.Close()
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		return CoverData{}, fmt.Errorf("failed to encode cover: %w", err)
+	}
+	return CoverData{Content: buf.Bytes(), ContentType: "image/jpeg"}, nil
+}
+
+// chapterCoverSubtitles returns the lines shown under the title on the
+// generated cover of a chapter
+func chapterCoverSubtitles(number, volume, title string) []string {
+	chapter := "Chapter " + number
+	if title != "" {
+		chapter += ": " + title
+	}
+	if volume != "" && volume != "0" {
+		return []string{chapter, "Volume " + volume}
+	}
+	return []string{chapter}
+}
+
+// fitText wraps text into at most maxLines lines of width, using the largest
+// font size from size down to minSize that fits. Text too long even at
+// minSize is cut.
+func fitText(f *opentype.Font, text string, width, maxLines int, size, minSize float64) (font.Face, []string, error) {
+	for ; ; size -= 4 {
+		face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create font face: %w", err)
+		}
+		lines := wrapText(face, text, width)
+		if len(lines) <= maxLines {
+			return face, lines, nil
+		}
+		if size-4 < minSize {
+			lines = lines[:maxLines]
+			lines[maxLines-1] += "…"
+			return face, lines, nil
+		}
+		face.Close()
+	}
+}
+
+// wrapText splits text into lines no wider than width, breaking between
+// words. A word wider than width gets a line of its own.
+func wrapText(face font.Face, text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if line != "" && font.MeasureString(face, candidate).Ceil() > width {
+			lines = append(lines, line)
+			line = word
+			continue
+		}
+		line = candidate
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// drawLines draws centered lines from y and returns the y below the last one
+func drawLines(img *image.RGBA, face font.Face, lines []string, y int, c color.Color) int {
+	metrics := face.Metrics()
+	lineHeight := (metrics.Ascent + metrics.Descent).Ceil() * 6 / 5
+	drawer := &font.Drawer{Dst: img, Src: image.NewUniform(c), Face: face}
+	for _, line := range lines {
+		width := drawer.MeasureString(line).Ceil()
+		drawer.Dot = fixed.P((img.Bounds().Dx()-width)/2, y+metrics.Ascent.Ceil())
+		drawer.DrawString(line)
+		y += lineHeight
+	}
+	return y
+}
+
+func blend(from, to color.RGBA, t float64) color.RGBA {
+	mix := func(a, b uint8) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*t)
+	}
+	return color.RGBA{mix(from.R, to.R), mix(from.G, to.G), mix(from.B, to.B), 255}
+}
+
+func fillRect(img *image.RGBA, rect image.Rectangle, c color.RGBA) {
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
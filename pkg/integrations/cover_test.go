@@ -0,0 +1,74 @@
+package integrations
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+)
+
+func TestGenerateCover(t *testing.T) {
+	cover, err := GenerateCover("The Unusually Long Title of a Manga That Goes On and On and On Without End", "Chapter 12: Extra", "Volume 3")
+	if err != nil {
+		t.Fatalf("GenerateCover() error = %v", err)
+	}
+	if cover.ContentType != "image/jpeg" {
+		t.Errorf("ContentType = %s, want image/jpeg", cover.ContentType)
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(cover.Content))
+	if err != nil || format != "jpeg" {
+		t.Fatalf("Expected a JPEG cover, got %s: %v", format, err)
+	}
+	if config.Width != 1200 || config.Height != 1800 {
+		t.Errorf("Cover is %dx%d, want 1200x1800", config.Width, config.Height)
+	}
+
+	// The same title always gets the same cover
+	again, _ := GenerateCover("The Unusually Long Title of a Manga That Goes On and On and On Without End", "Chapter 12: Extra", "Volume 3")
+	if !bytes.Equal(cover.Content, again.Content) {
+		t.Error("Expected generated covers to be deterministic")
+	}
+
+	if _, err := GenerateCover(""); err != nil {
+		t.Errorf("GenerateCover() without title error = %v", err)
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	var face font.Face = basicfont.Face7x13 // 7px per character
+
+	lines := wrapText(face, "one two three four", 70)
+	if strings.Join(lines, "|") != "one two|three four" {
+		t.Errorf("wrapText() = %q", lines)
+	}
+	if lines := wrapText(face, "incomprehensibilities", 70); len(lines) != 1 {
+		t.Errorf("Expected a long word to keep its own line, got %q", lines)
+	}
+}
+
+func TestEPubBuilder_GeneratedCover(t *testing.T) {
+	builder := NewEPubBuilder(t.TempDir())
+	builder.Init(&data.Manga{ID: "m1", Name: "No Cover"}, &data.Chapter{ID: "ch-1", Number: "1"})
+	builder.Next(ImageData{Content: createTestPage(t, 10, 10), ContentType: "image/png"})
+	path, err := builder.Done()
+	if err != nil {
+		t.Fatalf("Done() failed: %v", err)
+	}
+
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("Failed to open EPUB: %v", err)
+	}
+	defer reader.Close()
+	for _, f := range reader.File {
+		if strings.Contains(f.Name, "manga_cover") {
+			return
+		}
+	}
+	t.Error("Expected a generated cover in an EPUB without cover art")
+}
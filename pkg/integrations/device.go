@@ -0,0 +1,107 @@
+package integrations
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DeviceKind is the family of a mounted e-reader
+type DeviceKind string
+
+const (
+	DeviceKindle  DeviceKind = "kindle"
+	DeviceKobo    DeviceKind = "kobo"
+	DeviceGeneric DeviceKind = "generic" // Any mass storage reader
+)
+
+// DeviceMarkerFilename is the file identifying a device synced by mangas,
+// written at its root
+const DeviceMarkerFilename = ".mangas-device.json"
+
+// Device is a mounted e-reader
+type Device struct {
+	Root         string
+	Kind         DeviceKind
+	ID           string // From the marker file, empty until the first sync
+	Model        string // Model found from the USB serial number, empty when unknown
+	ProfileID    string // Export device profile suggested for the model, or the kind
+	DocumentsDir string // Where books are copied
+}
+
+// deviceMarker is the content of the marker file
+type deviceMarker struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DetectDevice recognizes the e-reader mounted at root: Kindles have a
+// documents and a system folder, Kobos a .kobo folder. Other folders are
+// treated as generic readers, with books copied to their root.
+func DetectDevice(root string) (*Device, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("device not mounted: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+
+	device := &Device{Root: root, Kind: DeviceGeneric, ProfileID: "epub3", DocumentsDir: root}
+	switch {
+	case isDir(filepath.Join(root, ".kobo")):
+		device.Kind, device.ProfileID = DeviceKobo, "kobo-clara"
+	case isDir(filepath.Join(root, "documents")) && isDir(filepath.Join(root, "system")):
+		device.Kind, device.ProfileID = DeviceKindle, "kindle-paperwhite3"
+		device.DocumentsDir = filepath.Join(root, "documents")
+	}
+
+	content, err := os.ReadFile(filepath.Join(root, DeviceMarkerFilename))
+	if err == nil {
+		var marker deviceMarker
+		if err := json.Unmarshal(content, &marker); err != nil {
+			return nil, fmt.Errorf("invalid device marker: %w", err)
+		}
+		device.ID = marker.ID
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read device marker: %w", err)
+	}
+	return device, nil
+}
+
+// EnsureID gives the device an ID, writing its marker file on first use, so
+// what was synced can be tracked even when it is mounted elsewhere
+func (d *Device) EnsureID() error {
+	if d.ID != "" {
+		return nil
+	}
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return fmt.Errorf("failed to generate device ID: %w", err)
+	}
+	marker := deviceMarker{ID: string(d.Kind) + "-" + hex.EncodeToString(random), CreatedAt: time.Now().UTC()}
+	content, err := json.MarshalIndent(marker, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(d.Root, DeviceMarkerFilename), content, 0644); err != nil {
+		return fmt.Errorf("failed to write device marker: %w", err)
+	}
+	d.ID = marker.ID
+	return nil
+}
+
+// LibraryDir is the folder of the device holding the synced manga, one
+// subfolder per series
+func (d *Device) LibraryDir() string {
+	return filepath.Join(d.DocumentsDir, "mangas")
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
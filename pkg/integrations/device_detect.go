@@ -0,0 +1,171 @@
+package integrations
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// amazonVendorID is the USB vendor ID of Kindles
+const amazonVendorID = "1949"
+
+// usbDevicesDir lists the connected USB devices on Linux, with their
+// descriptors as files
+var usbDevicesDir = "/sys/bus/usb/devices"
+
+// MountPoints returns the folders removable drives are usually mounted at:
+// the entries of /media/$USER, /run/media/$USER, /media and /mnt on Linux,
+// of /Volumes on macOS and the drive letters from D: on Windows
+func MountPoints() []string {
+	var roots []string
+	switch runtime.GOOS {
+	case "darwin":
+		roots = []string{"/Volumes"}
+	case "windows":
+		var drives []string
+		for letter := 'D'; letter <= 'Z'; letter++ {
+			drive := string(letter) + `:\`
+			if isDir(drive) {
+				drives = append(drives, drive)
+			}
+		}
+		return drives
+	default:
+		if user := os.Getenv("USER"); user != "" {
+			roots = append(roots, filepath.Join("/media", user), filepath.Join("/run/media", user))
+		}
+		roots = append(roots, "/media", "/mnt")
+	}
+
+	var mounts []string
+	for _, root := range roots {
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if path := filepath.Join(root, entry.Name()); isDir(path) {
+				mounts = append(mounts, path)
+			}
+		}
+	}
+	return mounts
+}
+
+// FindDevices returns the Kindles and Kobos among mounts, such as the ones
+// of MountPoints. The model of a Kindle is looked up from its USB serial
+// number when it is the only one connected.
+func FindDevices(mounts []string) []*Device {
+	var devices []*Device
+	for _, mount := range mounts {
+		device, err := DetectDevice(mount)
+		if err != nil || device.Kind == DeviceGeneric {
+			continue
+		}
+		devices = append(devices, device)
+	}
+
+	var kindles []*Device
+	for _, device := range devices {
+		if device.Kind == DeviceKindle {
+			kindles = append(kindles, device)
+		}
+	}
+	if serials := usbSerials(amazonVendorID); len(kindles) == 1 && len(serials) == 1 {
+		if model, profileID, ok := KindleModelForSerial(serials[0]); ok {
+			kindles[0].Model, kindles[0].ProfileID = model, profileID
+		}
+	}
+	return devices
+}
+
+// FindKindle returns the first Kindle mounted at one of MountPoints, nil
+// when none is connected
+func FindKindle() *Device {
+	for _, device := range FindDevices(MountPoints()) {
+		if device.Kind == DeviceKindle {
+			return device
+		}
+	}
+	return nil
+}
+
+// usbSerials returns the serial numbers of the connected USB devices of a
+// vendor. Only Linux exposes them as files; elsewhere there are none.
+func usbSerials(vendorID string) []string {
+	entries, err := os.ReadDir(usbDevicesDir)
+	if err != nil {
+		return nil
+	}
+	var serials []string
+	for _, entry := range entries {
+		dir := filepath.Join(usbDevicesDir, entry.Name())
+		vendor, err := os.ReadFile(filepath.Join(dir, "idVendor"))
+		if err != nil || strings.TrimSpace(string(vendor)) != vendorID {
+			continue
+		}
+		if serial, err := os.ReadFile(filepath.Join(dir, "serial")); err == nil {
+			serials = append(serials, strings.TrimSpace(string(serial)))
+		}
+	}
+	return serials
+}
+
+// kindleModel is a Kindle model and the export profile matching its screen
+type kindleModel struct {
+	name      string
+	profileID string
+}
+
+// kindleSerialPrefixes identifies Kindles by the first four characters of
+// their serial number, used until the Paperwhite 3
+var kindleSerialPrefixes = map[string]kindleModel{}
+
+// kindleSerialCodes identifies later Kindles, whose serial numbers start
+// with G or 0, by their characters 4 to 6
+var kindleSerialCodes = map[string]kindleModel{}
+
+func init() {
+	add := func(table map[string]kindleModel, model kindleModel, codes ...string) {
+		for _, code := range codes {
+			table[code] = model
+		}
+	}
+	add(kindleSerialPrefixes, kindleModel{"Kindle 4", "kindle4"}, "B00E", "B023", "9023")
+	add(kindleSerialPrefixes, kindleModel{"Kindle Touch", "kindle-touch"}, "B00F", "B010", "B011", "B012")
+	add(kindleSerialPrefixes, kindleModel{"Kindle Paperwhite", "kindle-paperwhite"}, "B024", "B01B", "B01C", "B01D", "B01F", "B020")
+	add(kindleSerialPrefixes, kindleModel{"Kindle Paperwhite 2", "kindle-paperwhite"},
+		"B0D4", "90D4", "B0D5", "90D5", "B0D6", "90D6", "B0D7", "90D7", "B0D8", "90D8", "B0F2", "90F2",
+		"B017", "9017", "B060", "9060", "B062", "9062", "B05F", "905F", "B061", "9061")
+	add(kindleSerialPrefixes, kindleModel{"Kindle Voyage", "kindle-voyage"}, "B013", "B054", "B02A", "B02F", "B053")
+	add(kindleSerialPrefixes, kindleModel{"Kindle Basic", "kindle-basic"}, "B0C6", "90C6", "B0DD", "90DD")
+
+	add(kindleSerialCodes, kindleModel{"Kindle Paperwhite 3", "kindle-paperwhite3"},
+		"0G1", "0G2", "0G4", "0G5", "0G6", "0G7", "0KB", "0KC", "0KD", "0KE", "0KF", "0KG", "0LK", "0LL")
+	add(kindleSerialCodes, kindleModel{"Kindle Oasis", "kindle-oasis"}, "0GC", "0GD", "0GR", "0GS", "0GT", "0GU")
+	add(kindleSerialCodes, kindleModel{"Kindle Oasis 2", "kindle-oasis"},
+		"0LM", "0LN", "0LP", "0LQ", "0P1", "0P2", "0P6", "0P7", "0P8", "0S1", "0S2", "0S3", "0S4", "0S7", "0SA")
+	add(kindleSerialCodes, kindleModel{"Kindle Oasis 3", "kindle-oasis3"}, "11L", "0WF", "0WG", "0WH", "0WJ", "0WK", "0WL")
+	add(kindleSerialCodes, kindleModel{"Kindle Paperwhite 4", "kindle-paperwhite3"},
+		"0PP", "0T1", "0T2", "0T3", "0T4", "0T5", "0T6", "0T7", "0TJ", "0TK", "0TL", "0TM", "0TN", "102", "103")
+	add(kindleSerialCodes, kindleModel{"Kindle Scribe", "kindle-scribe"}, "22D", "25T", "23A", "2AQ", "2AP", "1XH", "22C")
+}
+
+// KindleModelForSerial returns the model of a Kindle and the export profile
+// matching its screen from its serial number
+func KindleModelForSerial(serial string) (name, profileID string, ok bool) {
+	serial = strings.ToUpper(strings.ReplaceAll(serial, " ", ""))
+	if len(serial) < 6 {
+		return "", "", false
+	}
+	if model, found := kindleSerialPrefixes[serial[:4]]; found {
+		return model.name, model.profileID, true
+	}
+	if serial[0] == 'G' || serial[0] == '0' {
+		if model, found := kindleSerialCodes[serial[3:6]]; found {
+			return model.name, model.profileID, true
+		}
+	}
+	return "", "", false
+}
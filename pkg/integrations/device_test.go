@@ -0,0 +1,128 @@
+package integrations
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectDevice(t *testing.T) {
+	kindle := t.TempDir()
+	os.MkdirAll(filepath.Join(kindle, "documents"), 0755)
+	os.MkdirAll(filepath.Join(kindle, "system"), 0755)
+	kobo := t.TempDir()
+	os.MkdirAll(filepath.Join(kobo, ".kobo"), 0755)
+	generic := t.TempDir()
+
+	tests := []struct {
+		root      string
+		kind      DeviceKind
+		documents string
+	}{
+		{kindle, DeviceKindle, filepath.Join(kindle, "documents")},
+		{kobo, DeviceKobo, kobo},
+		{generic, DeviceGeneric, generic},
+	}
+	for _, tt := range tests {
+		device, err := DetectDevice(tt.root)
+		if err != nil {
+			t.Fatalf("DetectDevice(%s) error = %v", tt.root, err)
+		}
+		if device.Kind != tt.kind || device.DocumentsDir != tt.documents {
+			t.Errorf("DetectDevice(%s) = %s in %s, want %s in %s", tt.root, device.Kind, device.DocumentsDir, tt.kind, tt.documents)
+		}
+		if _, ok := GetExportDevice(device.ProfileID); !ok {
+			t.Errorf("Unknown profile %s for %s", device.ProfileID, device.Kind)
+		}
+	}
+
+	if _, err := DetectDevice(filepath.Join(generic, "unmounted")); err == nil {
+		t.Error("Expected an error for a missing mount point")
+	}
+}
+
+func TestDeviceEnsureID(t *testing.T) {
+	root := t.TempDir()
+	device, _ := DetectDevice(root)
+	if device.ID != "" {
+		t.Fatalf("Expected no ID before the first sync, got %s", device.ID)
+	}
+	if err := device.EnsureID(); err != nil {
+		t.Fatalf("EnsureID() error = %v", err)
+	}
+
+	// The ID is read back from the marker file
+	again, err := DetectDevice(root)
+	if err != nil || again.ID == "" || again.ID != device.ID {
+		t.Errorf("Expected ID %s to be kept, got %q (%v)", device.ID, again.ID, err)
+	}
+}
+
+func TestKindleModelForSerial(t *testing.T) {
+	tests := []struct {
+		serial  string
+		profile string
+		ok      bool
+	}{
+		{"B024 1234 5678 9ABC", "kindle-paperwhite", true},
+		{"B00E12345678", "kindle4", true},
+		{"G000G1234567", "kindle-paperwhite3", true},
+		{"G0922D123456", "kindle-scribe", true},
+		{"G000WG123456", "kindle-oasis3", true},
+		{"ZZZZ12345678", "", false},
+		{"B02", "", false},
+	}
+	for _, tt := range tests {
+		_, profile, ok := KindleModelForSerial(tt.serial)
+		if ok != tt.ok || profile != tt.profile {
+			t.Errorf("KindleModelForSerial(%s) = %s, %v, want %s, %v", tt.serial, profile, ok, tt.profile, tt.ok)
+		}
+		if ok {
+			if _, known := GetDeviceProfile(profile); !known {
+				t.Errorf("Unknown profile %s for %s", profile, tt.serial)
+			}
+		}
+	}
+}
+
+func TestFindDevices(t *testing.T) {
+	kindle := t.TempDir()
+	os.MkdirAll(filepath.Join(kindle, "documents"), 0755)
+	os.MkdirAll(filepath.Join(kindle, "system"), 0755)
+	kobo := t.TempDir()
+	os.MkdirAll(filepath.Join(kobo, ".kobo"), 0755)
+	usbStick := t.TempDir()
+
+	// A Kindle Oasis 3 plugged in, and an unrelated USB device
+	usb := t.TempDir()
+	for name, files := range map[string]map[string]string{
+		"1-1": {"idVendor": "1949\n", "serial": "G000WG1234567890\n"},
+		"1-2": {"idVendor": "0781\n", "serial": "4C530001\n"},
+	} {
+		os.MkdirAll(filepath.Join(usb, name), 0755)
+		for file, content := range files {
+			os.WriteFile(filepath.Join(usb, name, file), []byte(content), 0644)
+		}
+	}
+	previous := usbDevicesDir
+	usbDevicesDir = usb
+	defer func() { usbDevicesDir = previous }()
+
+	devices := FindDevices([]string{usbStick, kindle, kobo, filepath.Join(usbStick, "missing")})
+	if len(devices) != 2 {
+		t.Fatalf("Expected the Kindle and the Kobo, got %d devices", len(devices))
+	}
+	if devices[0].Kind != DeviceKindle || devices[0].ProfileID != "kindle-oasis3" || devices[0].Model != "Kindle Oasis 3" {
+		t.Errorf("Expected a Kindle Oasis 3, got %s %q (%s)", devices[0].Kind, devices[0].Model, devices[0].ProfileID)
+	}
+	if devices[1].Kind != DeviceKobo {
+		t.Errorf("Expected a Kobo, got %s", devices[1].Kind)
+	}
+
+	// The model is unknown without the USB descriptors
+	usbDevicesDir = filepath.Join(usb, "missing")
+	devices = FindDevices([]string{kindle})
+	if len(devices) != 1 || devices[0].Model != "" || devices[0].ProfileID != "kindle-paperwhite3" {
+		t.Errorf("Expected a Kindle of unknown model, got %+v", devices)
+	}
+}
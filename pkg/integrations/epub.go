@@ -4,13 +4,16 @@ import (
 	"bytes"
 	"fmt"
 	"html/template"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/go-shiori/go-epub"
 	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/utils"
 )
 
 // ImageData represents an image with its content and metadata
@@ -18,6 +21,35 @@ type ImageData struct {
 	Content     []byte
 	ContentType string // e.g., "image/jpeg", "image/png"
 	Index       int    // Page number/order
+	Chapter     string // Chapter sort key in books combining chapters, see PageOrder
+}
+
+// Order returns the position of the image in the book
+func (img ImageData) Order() PageOrder {
+	return PageOrder{Chapter: img.Chapter, Page: img.Index}
+}
+
+// FinalizeStage is a step of writing a book once all its images were added
+type FinalizeStage string
+
+const (
+	FinalizeRecognizing FinalizeStage = "recognizing" // Text is recognized on the images, with OCR on
+	FinalizeStaging     FinalizeStage = "staging"     // Images are written to temp files and added to the book
+	FinalizeWriting     FinalizeStage = "writing"     // The archive is being assembled
+)
+
+// FinalizeProgress reports how far a builder's Done got
+type FinalizeProgress struct {
+	Stage   FinalizeStage
+	Current int // Images staged so far
+	Total   int // Images in the book
+}
+
+// reportFinalize calls fn, if any, with the progress of Done
+func reportFinalize(fn func(FinalizeProgress), stage FinalizeStage, current, total int) {
+	if fn != nil {
+		fn(FinalizeProgress{Stage: stage, Current: current, Total: total})
+	}
 }
 
 // CoverData represents cover image data
@@ -26,17 +58,58 @@ type CoverData struct {
 	ContentType string
 }
 
-// EPubBuilder builds EPUB files by streaming images
+// EPubBuilder builds EPUB files by streaming images: each page is written
+// to the staging directory as it is added, so only one is held in memory
 type EPubBuilder struct {
 	outputDir   string
 	tempDir     string
 	epub        *epub.Epub
 	manga       *data.Manga
 	chapter     *data.Chapter
-	images      []ImageData
+	images      []stagedImage
 	chapterCover *CoverData
 	mangaCover   *CoverData
 	templates   *template.Template
+	onProgress  func(FinalizeProgress)
+	ocr         *OCROptions
+	altText     AltTextMode
+	pathTemplate string
+	series       seriesMetadata
+}
+
+// seriesMetadata groups a book with the others of its series on readers
+type seriesMetadata struct {
+	series    string
+	index     float64 // Position in the series, 0 when unknown
+	titleSort string  // Sort key of the title, empty for the title itself
+}
+
+// meta returns the package document elements of the series, in the EPUB 3
+// form and the calibre one Kindle conversions read
+func (m seriesMetadata) meta() string {
+	var meta strings.Builder
+	if m.series != "" {
+		fmt.Fprintf(&meta, "    <meta property=\"belongs-to-collection\" id=\"series\">%s</meta>\n", xmlEscape(m.series))
+		meta.WriteString("    <meta refines=\"#series\" property=\"collection-type\">series</meta>\n")
+		if m.index > 0 {
+			fmt.Fprintf(&meta, "    <meta refines=\"#series\" property=\"group-position\">%s</meta>\n", strconv.FormatFloat(m.index, 'f', -1, 64))
+		}
+		fmt.Fprintf(&meta, "    <meta name=\"calibre:series\" content=\"%s\"/>\n", xmlEscape(m.series))
+		if m.index > 0 {
+			fmt.Fprintf(&meta, "    <meta name=\"calibre:series_index\" content=\"%s\"/>\n", strconv.FormatFloat(m.index, 'f', -1, 64))
+		}
+	}
+	if m.titleSort != "" {
+		fmt.Fprintf(&meta, "    <meta name=\"calibre:title_sort\" content=\"%s\"/>\n", xmlEscape(m.titleSort))
+	}
+	return meta.String()
+}
+
+// stagedImage is a page written to the staging directory
+type stagedImage struct {
+	path        string
+	contentType string
+	order       PageOrder
 }
 
 // Template data structures
@@ -119,7 +192,7 @@ func NewEPubBuilder(outputDir string) *EPubBuilder {
 
 	return &EPubBuilder{
 		outputDir: outputDir,
-		images:    make([]ImageData, 0),
+		images:    make([]stagedImage, 0),
 		templates: tmpl,
 	}
 }
@@ -134,7 +207,7 @@ func (b *EPubBuilder) Init(manga *data.Manga, chapter *data.Chapter) error {
 	}
 
 	// Create temporary directory for staging images
-	tempDir, err := os.MkdirTemp("", "manga-epub-*")
+	tempDir, err := utils.Temp.MkdirTemp("epub-*")
 	if err != nil {
 		return fmt.Errorf("failed to create temp directory: %w", err)
 	}
@@ -142,28 +215,86 @@ func (b *EPubBuilder) Init(manga *data.Manga, chapter *data.Chapter) error {
 	b.manga = manga
 	b.chapter = chapter
 	b.tempDir = tempDir
-	b.images = make([]ImageData, 0)
+	b.images = make([]stagedImage, 0)
 	b.chapterCover = nil
 	b.mangaCover = nil
+	b.series = seriesMetadata{}
 
 	// Create EPub
 	e, err := epub.NewEpub(manga.Name)
 	if err != nil {
-		os.RemoveAll(tempDir)
+		utils.Temp.Release(tempDir)
 		return fmt.Errorf("failed to create EPub: %w", err)
 	}
 
 	// Set metadata
+	e.SetIdentifier(BookIdentifier(manga, chapter))
 	e.SetAuthor("MangaDex")
 	if manga.Description != "" {
 		e.SetDescription(manga.Description)
 	}
-	e.SetLang("en")
+	language := chapter.Language
+	if language == "" {
+		language = "en"
+	}
+	e.SetLang(language)
 
 	b.epub = e
 	return nil
 }
 
+// SetProgressCallback sets a function called as Done stages the images and
+// writes the archive. It is kept across chapters.
+func (b *EPubBuilder) SetProgressCallback(fn func(FinalizeProgress)) {
+	b.onProgress = fn
+}
+
+// SetOCR recognizes the text of the pages in Done, see OCROptions. nil turns
+// recognition off. It is kept across chapters.
+func (b *EPubBuilder) SetOCR(options *OCROptions) {
+	b.ocr = options
+}
+
+// SetAltText sets how the ALT text of the pages is written, see AltTextMode.
+// It is kept across chapters.
+func (b *EPubBuilder) SetAltText(mode AltTextMode) {
+	b.altText = mode
+}
+
+// SetPathTemplate sets where EPUBs are written under the output directory,
+// see ChapterPath. It is kept across chapters.
+func (b *EPubBuilder) SetPathTemplate(template string) {
+	b.pathTemplate = template
+}
+
+// SetAuthor overrides the default author metadata
+func (b *EPubBuilder) SetAuthor(author string) error {
+	if b.epub == nil {
+		return fmt.Errorf("builder not initialized, call Init first")
+	}
+	b.epub.SetAuthor(author)
+	return nil
+}
+
+// SetSeries sets the series of the book, its position in it when index is
+// above 0 and the sort key of its title when titleSort is set
+func (b *EPubBuilder) SetSeries(series string, index float64, titleSort string) error {
+	if b.epub == nil {
+		return fmt.Errorf("builder not initialized, call Init first")
+	}
+	b.series = seriesMetadata{series: series, index: index, titleSort: titleSort}
+	return nil
+}
+
+// SetRightToLeft sets the page progression direction to right-to-left
+func (b *EPubBuilder) SetRightToLeft() error {
+	if b.epub == nil {
+		return fmt.Errorf("builder not initialized, call Init first")
+	}
+	b.epub.SetPpd("rtl")
+	return nil
+}
+
 // SetMangaCover sets the manga cover image
 func (b *EPubBuilder) SetMangaCover(cover CoverData) error {
 	if b.epub == nil {
@@ -190,20 +321,56 @@ func (b *EPubBuilder) SetChapterCover(cover CoverData) error {
 
 // Next adds an image to the chapter
 func (b *EPubBuilder) Next(image ImageData) error {
+	return b.NextReader(bytes.NewReader(image.Content), image.ContentType, image.Order())
+}
+
+// NextReader adds the image read from r at order in the chapter, copying it
+// straight to the staging directory. Pages can be added in any order.
+func (b *EPubBuilder) NextReader(r io.Reader, contentType string, order PageOrder) error {
 	if b.epub == nil {
 		return fmt.Errorf("builder not initialized, call Init first")
 	}
-	if len(image.Content) == 0 {
-		return fmt.Errorf("image content is empty")
-	}
-	if image.ContentType == "" {
+	if contentType == "" {
 		return fmt.Errorf("image content type is required")
 	}
 
-	b.images = append(b.images, image)
+	path := filepath.Join(b.tempDir, fmt.Sprintf("staged_%d%s", len(b.images), getExtensionFromContentType(contentType)))
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to stage image %d: %w", order.Page, err)
+	}
+	written, err := io.Copy(file, r)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && written == 0 {
+		err = fmt.Errorf("image content is empty")
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to stage image %d: %w", order.Page, err)
+	}
+
+	b.images = append(b.images, stagedImage{path: path, contentType: contentType, order: order})
 	return nil
 }
 
+// Discard drops the chapter being built and its staged images, for when
+// it won't be finished
+func (b *EPubBuilder) Discard() {
+	if b.tempDir != "" {
+		utils.Temp.Release(b.tempDir)
+	}
+	b.epub = nil
+	b.manga = nil
+	b.chapter = nil
+	b.images = nil
+	b.chapterCover = nil
+	b.mangaCover = nil
+	b.series = seriesMetadata{}
+	b.tempDir = ""
+}
+
 // Done finalizes and writes the EPUB file
 func (b *EPubBuilder) Done() (string, error) {
 	if b.epub == nil {
@@ -213,16 +380,12 @@ func (b *EPubBuilder) Done() (string, error) {
 		return "", fmt.Errorf("no images added to chapter")
 	}
 
-	defer func() {
-		// Clean up temp directory
-		if b.tempDir != "" {
-			os.RemoveAll(b.tempDir)
-		}
-	}()
+	// Clean up the staged images, the fields are reset before returning
+	defer utils.Temp.Release(b.tempDir)
 
-	// Sort images by index
-	sort.Slice(b.images, func(i, j int) bool {
-		return b.images[i].Index < b.images[j].Index
+	// Sort images by chapter and index
+	sort.SliceStable(b.images, func(i, j int) bool {
+		return b.images[i].order.Less(b.images[j].order)
 	})
 
 	// Create chapter title
@@ -234,7 +397,13 @@ func (b *EPubBuilder) Done() (string, error) {
 		chapterTitle = fmt.Sprintf("%s: %s", chapterTitle, b.chapter.Title)
 	}
 
-	// Add manga cover if provided
+	// Add manga cover if provided, a generated one otherwise
+	if b.mangaCover == nil {
+		cover, err := GenerateCover(b.manga.Name, chapterCoverSubtitles(b.chapter.Number, b.chapter.Volume, b.chapter.Title)...)
+		if err == nil {
+			b.mangaCover = &cover
+		}
+	}
 	if b.mangaCover != nil {
 		coverPath, err := b.addCoverImage(b.mangaCover, "manga_cover")
 		if err == nil {
@@ -258,28 +427,34 @@ func (b *EPubBuilder) Done() (string, error) {
 		}
 	}
 
-	// Write images to temp directory and add to EPUB
-	for i, img := range b.images {
-		ext := getExtensionFromContentType(img.ContentType)
-		filename := fmt.Sprintf("page_%04d%s", img.Index, ext)
-		
-		// Write image to temp file
-		tempFilePath := filepath.Join(b.tempDir, filename)
-		if err := os.WriteFile(tempFilePath, img.Content, 0644); err != nil {
-			return "", fmt.Errorf("failed to write temp image %d: %w", img.Index, err)
-		}
+	var texts []string
+	if b.ocr != nil {
+		texts = recognizeStaged(b.ocr.Recognizer, b.images, b.onProgress)
+	}
 
-		// Add image from temp file
-		internalPath, err := b.epub.AddImage(tempFilePath, filename)
+	// Add the staged images to the EPUB
+	var sidecar []ocrPage
+	for i, img := range b.images {
+		filename := fmt.Sprintf("page_%s%s", img.order, getExtensionFromContentType(img.contentType))
+		internalPath, err := b.epub.AddImage(img.path, filename)
 		if err != nil {
-			return "", fmt.Errorf("failed to add image %d to EPUB: %w", img.Index, err)
+			return "", fmt.Errorf("failed to add image %d to EPUB: %w", img.order.Page, err)
 		}
 
+		label := fmt.Sprintf("Page %d", i+1)
+		alt := b.altText.alt(label, b.manga.Name+", "+chapterTitle, i+1, len(b.images))
+		if texts != nil {
+			if b.ocr.alt() {
+				alt = pageAlt(alt, texts[i])
+			}
+			sidecar = append(sidecar, ocrPage{Label: label, Text: texts[i]})
+		}
 		pages = append(pages, PageData{
 			Path:  internalPath,
 			Index: i + 1,
-			Alt:   fmt.Sprintf("Page %d", i+1),
+			Alt:   alt,
 		})
+		reportFinalize(b.onProgress, FinalizeStaging, i+1, len(b.images))
 	}
 
 	// Generate HTML content using templates
@@ -302,14 +477,27 @@ func (b *EPubBuilder) Done() (string, error) {
 	}
 
 	// Generate output filename
-	safeTitle := sanitizeFilename(b.manga.Name)
-	safeCh := sanitizeFilename(fmt.Sprintf("ch_%s", b.chapter.Number))
-	outputPath := filepath.Join(b.outputDir, fmt.Sprintf("%s_%s.epub", safeTitle, safeCh))
+	outputPath := filepath.Join(b.outputDir, ChapterPath(b.pathTemplate, b.manga, b.chapter)+".epub")
 
 	// Write EPub file
+	if err := utils.CheckOutputPath(outputPath); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	reportFinalize(b.onProgress, FinalizeWriting, len(b.images), len(b.images))
 	if err := b.epub.Write(outputPath); err != nil {
 		return "", fmt.Errorf("failed to write EPub: %w", err)
 	}
+	if err := addPackageMetadata(outputPath, b.ocr.alt(), b.series.meta()); err != nil {
+		return "", fmt.Errorf("failed to add metadata: %w", err)
+	}
+	if b.ocr.sidecar() {
+		if err := writeOCRSidecar(outputPath, b.manga.Name+" - "+chapterTitle, sidecar); err != nil {
+			return "", err
+		}
+	}
 
 	// Reset for next use
 	b.epub = nil
@@ -368,7 +556,7 @@ func (b *EPubBuilder) generateSimpleHTML(title string, pages []PageData) string
 	for _, page := range pages {
 		html.WriteString(fmt.Sprintf(
 			`<div class="page"><img src="%s" alt="%s" style="width:100%%;height:auto;"/></div>%s`,
-			page.Path, page.Alt, "\n",
+			page.Path, template.HTMLEscapeString(page.Alt), "\n",
 		))
 	}
 
@@ -386,6 +574,8 @@ func getExtensionFromContentType(contentType string) string {
 		return ".gif"
 	case "image/webp":
 		return ".webp"
+	case "image/avif":
+		return ".avif"
 	default:
 		return ".jpg"
 	}
@@ -399,8 +589,17 @@ func sanitizeFilename(name string) string {
 	for _, char := range invalid {
 		result = strings.ReplaceAll(result, char, "_")
 	}
-	// Trim spaces and dots from ends
+	// Trim spaces and dots from ends, so names like ".." cannot climb out
 	result = strings.TrimSpace(result)
 	result = strings.Trim(result, ".")
+	result = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, result)
+	if result == "" {
+		return "_"
+	}
 	return result
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/kerbaras/mangas/pkg/data"
@@ -321,13 +322,13 @@ func TestEPubBuilder_Done(t *testing.T) {
 			t.Fatalf("Next() failed: %v", err)
 		}
 
-		// Before Done(), temp dir should exist but be empty
+		// Next() stages the image right away
 		files, err := os.ReadDir(tempDir)
 		if err != nil {
 			t.Fatalf("Failed to read temp dir: %v", err)
 		}
-		if len(files) != 0 {
-			t.Error("Temp dir should be empty before Done()")
+		if len(files) != 1 {
+			t.Errorf("Expected 1 staged image before Done(), got %d", len(files))
 		}
 
 		_, err = builder.Done()
@@ -336,10 +337,121 @@ func TestEPubBuilder_Done(t *testing.T) {
 		}
 
 		// After Done(), temp dir should be deleted
-		// Note: Cleanup happens in defer, directory should be removed
+		if _, err := os.Stat(tempDir); !os.IsNotExist(err) {
+			t.Error("Temp dir should be removed after Done()")
+		}
+	})
+
+	t.Run("discard removes staged images", func(t *testing.T) {
+		builder := NewEPubBuilder(t.TempDir())
+		if err := builder.Init(&data.Manga{ID: "manga-1", Name: "Test"}, &data.Chapter{ID: "ch-1", Number: "1"}); err != nil {
+			t.Fatalf("Init() failed: %v", err)
+		}
+		tempDir := builder.tempDir
+		if err := builder.Next(ImageData{Content: createTestPNG(), ContentType: "image/png"}); err != nil {
+			t.Fatalf("Next() failed: %v", err)
+		}
+
+		builder.Discard()
+		if _, err := os.Stat(tempDir); !os.IsNotExist(err) {
+			t.Error("Temp dir should be removed by Discard()")
+		}
+		if _, err := builder.Done(); err == nil {
+			t.Error("Done() should fail after Discard()")
+		}
+	})
+
+	t.Run("pages streamed out of order", func(t *testing.T) {
+		builder := NewEPubBuilder(t.TempDir())
+		if err := builder.Init(&data.Manga{ID: "manga-1", Name: "Test"}, &data.Chapter{ID: "ch-1", Number: "1"}); err != nil {
+			t.Fatalf("Init() failed: %v", err)
+		}
+		first, second := createTestPNG(), createTestJPEG(t, 10, 10)
+		if err := builder.NextReader(bytes.NewReader(second), "image/jpeg", PageOrder{Page: 1}); err != nil {
+			t.Fatalf("NextReader() failed: %v", err)
+		}
+		if err := builder.NextReader(bytes.NewReader(first), "image/png", PageOrder{Page: 0}); err != nil {
+			t.Fatalf("NextReader() failed: %v", err)
+		}
+		if err := builder.NextReader(strings.NewReader(""), "image/png", PageOrder{Page: 2}); err == nil {
+			t.Error("NextReader() should fail with empty content")
+		}
+
+		path, err := builder.Done()
+		if err != nil {
+			t.Fatalf("Done() failed: %v", err)
+		}
+		pages, err := ReadEPUBPages(path)
+		if err != nil {
+			t.Fatalf("ReadEPUBPages() failed: %v", err)
+		}
+		if len(pages) != 2 || !bytes.Equal(pages[0], first) || !bytes.Equal(pages[1], second) {
+			t.Errorf("Expected the pages in reading order, got %d pages", len(pages))
+		}
+	})
+
+	t.Run("reports finalization progress", func(t *testing.T) {
+		builder := NewEPubBuilder(t.TempDir())
+		var reports []FinalizeProgress
+		builder.SetProgressCallback(func(p FinalizeProgress) {
+			reports = append(reports, p)
+		})
+		builder.Init(&data.Manga{ID: "manga-1", Name: "Test Manga"}, &data.Chapter{ID: "ch-1", Number: "1"})
+		for i := 0; i < 3; i++ {
+			builder.Next(ImageData{Content: createTestPNG(), ContentType: "image/png", Index: i})
+		}
+
+		if _, err := builder.Done(); err != nil {
+			t.Fatalf("Done() failed: %v", err)
+		}
+
+		want := []FinalizeProgress{
+			{FinalizeStaging, 1, 3},
+			{FinalizeStaging, 2, 3},
+			{FinalizeStaging, 3, 3},
+			{FinalizeWriting, 3, 3},
+		}
+		if len(reports) != len(want) {
+			t.Fatalf("Got %d reports, want %d: %+v", len(reports), len(want), reports)
+		}
+		for i := range want {
+			if reports[i] != want[i] {
+				t.Errorf("report %d = %+v, want %+v", i, reports[i], want[i])
+			}
+		}
 	})
 }
 
+func TestEPubBuilder_Series(t *testing.T) {
+	builder := NewEPubBuilder(t.TempDir())
+	if err := builder.SetSeries("Naruto", 1, ""); err == nil {
+		t.Error("SetSeries() should fail before Init")
+	}
+	if err := builder.Init(&data.Manga{ID: "m1", Name: "Naruto"}, &data.Chapter{ID: "c1", Number: "1"}); err != nil {
+		t.Fatalf("Init() failed: %v", err)
+	}
+	builder.SetSeries("Naruto & Co", 2.5, "Naruto 002.5")
+	builder.Next(ImageData{Content: createTestPage(t, 10, 20), ContentType: "image/png", Index: 1})
+
+	epubPath, err := builder.Done()
+	if err != nil {
+		t.Fatalf("Done() failed: %v", err)
+	}
+
+	opf := readEPUBFile(t, epubPath, ".opf")
+	for _, want := range []string{
+		`<meta property="belongs-to-collection" id="series">Naruto &amp; Co</meta>`,
+		`<meta refines="#series" property="group-position">2.5</meta>`,
+		`<meta name="calibre:series" content="Naruto &amp; Co"/>`,
+		`<meta name="calibre:series_index" content="2.5"/>`,
+		`<meta name="calibre:title_sort" content="Naruto 002.5"/>`,
+	} {
+		if !strings.Contains(opf, want) {
+			t.Errorf("package document misses %s:\n%s", want, opf)
+		}
+	}
+}
+
 func TestEPubBuilder_ContentTypeExtensions(t *testing.T) {
 	tests := []struct {
 		contentType string
@@ -350,6 +462,7 @@ func TestEPubBuilder_ContentTypeExtensions(t *testing.T) {
 		{"image/png", ".png"},
 		{"image/gif", ".gif"},
 		{"image/webp", ".webp"},
+		{"image/avif", ".avif"},
 		{"image/unknown", ".jpg"}, // default
 		{"", ".jpg"},              // default
 	}
@@ -375,6 +488,10 @@ func TestSanitizeFilename(t *testing.T) {
 		{"  Title with spaces  ", "Title with spaces"},
 		{"...Title with dots...", "Title with dots"},
 		{"Title<>|With\"All", "Title___With_All"},
+		{"..", "_"},
+		{"../../etc", "_.._etc"},
+		{"", "_"},
+		{"Tab\tTitle", "TabTitle"},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,126 @@
+package integrations
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Exporter converts downloaded chapter EPUBs into a single book optimized
+// for a reading device
+type Exporter interface {
+	// ConvertChapters exports the chapters and returns the written file
+	ConvertChapters(options ExportOptions) (string, error)
+	// Report returns the report of the last conversion
+	Report() *ConversionReport
+	// ResumedPages returns how many pages were reused from an interrupted run
+	ResumedPages() int
+	// SetFilters sets the plugin filters run on every page
+	SetFilters(specs []ImageFilterSpec) error
+	// SetPreprocess sets the clean-ups run on pages before they are resized
+	SetPreprocess(options PreprocessOptions)
+	Close() error
+}
+
+// ExportStage is the step an export is at, see ExportProgress
+type ExportStage string
+
+const (
+	ExportProcessing ExportStage = "processing" // Pages are extracted and optimized, chapter by chapter
+	ExportWriting    ExportStage = "writing"    // The book is being assembled
+	ExportConverting ExportStage = "converting" // The EPUB is converted to another format by an external tool
+)
+
+// ExportProgress reports how far an export got
+type ExportProgress struct {
+	Stage   ExportStage
+	Current int // Chapters processed so far
+	Total   int // Chapters in the export
+}
+
+// reportExport calls the OnProgress of options, if any
+func reportExport(options ExportOptions, stage ExportStage, current int) {
+	if options.OnProgress != nil {
+		options.OnProgress(ExportProgress{Stage: stage, Current: current, Total: len(options.Chapters)})
+	}
+}
+
+// FixedLayoutDevices are the non-Kindle devices, exported as EPUB3
+// fixed-layout books
+var FixedLayoutDevices = map[string]KindleDevice{
+	"kobo-clara": {
+		Name:        "Kobo Clara HD/2E/BW",
+		Model:       "KoboClara",
+		Width:       1072,
+		Height:      1448,
+		DPI:         300,
+		Grayscale:   true,
+		Orientation: "portrait",
+	},
+	"kobo-libra": {
+		Name:        "Kobo Libra H2O/2",
+		Model:       "KoboLibra",
+		Width:       1264,
+		Height:      1680,
+		DPI:         300,
+		Grayscale:   true,
+		Orientation: "both",
+	},
+	"kobo-elipsa": {
+		Name:        "Kobo Elipsa/2E",
+		Model:       "KoboElipsa",
+		Width:       1404,
+		Height:      1872,
+		DPI:         227,
+		Grayscale:   true,
+		Orientation: "both",
+	},
+	// Any EPUB3 reader, keeps colors for tablets and phones
+	"epub3": {
+		Name:        "Generic EPUB3 reader",
+		Model:       "EPUB3",
+		Width:       1536,
+		Height:      2048,
+		DPI:         264,
+		Grayscale:   false,
+		Orientation: "both",
+	},
+}
+
+// GetExportDevice returns the profile of a Kindle or fixed-layout device
+func GetExportDevice(deviceID string) (KindleDevice, bool) {
+	if device, ok := KindleDevices[deviceID]; ok {
+		return device, true
+	}
+	device, ok := FixedLayoutDevices[deviceID]
+	return device, ok
+}
+
+// IsFixedLayoutDevice reports whether a device is exported as an EPUB3
+// fixed-layout book rather than through the Kindle pipeline
+func IsFixedLayoutDevice(deviceID string) bool {
+	_, ok := FixedLayoutDevices[deviceID]
+	return ok
+}
+
+// ListExportDevices returns the IDs and names of every device supported by
+// NewExporter, sorted by ID
+func ListExportDevices() []string {
+	devices := ListDevices()
+	for id, device := range FixedLayoutDevices {
+		devices = append(devices, id+": "+device.Name)
+	}
+	sort.Strings(devices)
+	return devices
+}
+
+// NewExporter creates the exporter for a device: Kindle devices go through
+// KindleConverter, the others are written as EPUB3 fixed-layout books
+func NewExporter(deviceID string) (Exporter, error) {
+	if IsFixedLayoutDevice(deviceID) {
+		return NewFixedLayoutConverter(deviceID)
+	}
+	if _, ok := KindleDevices[deviceID]; ok {
+		return NewKindleConverter(deviceID)
+	}
+	return nil, fmt.Errorf("unknown device: %s", deviceID)
+}
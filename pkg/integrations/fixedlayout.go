@@ -0,0 +1,545 @@
+package integrations
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+const (
+	FormatEPUB  KindleFormat = "epub"  // EPUB3 fixed-layout
+	FormatKEPUB KindleFormat = "kepub" // EPUB3 fixed-layout named for Kobo's kepub renderer
+)
+
+// FixedLayoutConverter exports chapters as an EPUB3 fixed-layout book for
+// Kobo and other EPUB3 readers. Pages go through the same optimization and
+// resumable cache as Kindle exports; each page is its own pre-paginated
+// document sized after its image.
+type FixedLayoutConverter struct {
+	*KindleConverter
+}
+
+// NewFixedLayoutConverter creates a converter for one of FixedLayoutDevices
+func NewFixedLayoutConverter(deviceID string) (*FixedLayoutConverter, error) {
+	device, ok := FixedLayoutDevices[deviceID]
+	if !ok {
+		return nil, fmt.Errorf("unknown device: %s", deviceID)
+	}
+	converter, err := newKindleConverter(deviceID, device, "mangas-fixed-layout")
+	if err != nil {
+		return nil, err
+	}
+	return &FixedLayoutConverter{KindleConverter: converter}, nil
+}
+
+// FixedLayoutPath returns the path of an export for format, replacing the
+// extension of outputPath: ".kepub.epub" for FormatKEPUB and ".epub" otherwise
+func FixedLayoutPath(outputPath string, format KindleFormat) string {
+	base := strings.TrimSuffix(outputPath, ".kepub.epub")
+	if base == outputPath {
+		base = strings.TrimSuffix(outputPath, filepath.Ext(outputPath))
+	}
+	if format == FormatKEPUB {
+		return base + ".kepub.epub"
+	}
+	return base + ".epub"
+}
+
+// ConvertChapters converts multiple chapter EPUBs into a single fixed-layout
+// EPUB. A report of the conversion is stored next to the output.
+func (c *FixedLayoutConverter) ConvertChapters(options ExportOptions) (outputPath string, err error) {
+	if len(options.Chapters) == 0 {
+		return "", fmt.Errorf("no chapters provided")
+	}
+	switch options.Format {
+	case "", FormatEPUB, FormatKEPUB:
+	default:
+		return "", fmt.Errorf("unsupported format for %s: %s (use epub or kepub)", c.device.Name, options.Format)
+	}
+
+	finish := c.beginConversion(options)
+	defer func() { finish(outputPath, err) }()
+
+	outputPath = FixedLayoutPath(options.OutputPath, options.Format)
+	if err := utils.CheckOutputPath(outputPath); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	images, _, err := c.processChapters(options)
+	if err != nil {
+		return "", err
+	}
+	if len(images) == 0 {
+		return "", fmt.Errorf("no pages found in chapters")
+	}
+
+	var cover []byte
+	if options.CoverImage != "" {
+		if cover, err = os.ReadFile(options.CoverImage); err != nil {
+			return "", fmt.Errorf("failed to read cover image: %w", err)
+		}
+	}
+
+	reportExport(options, ExportWriting, len(options.Chapters))
+	book, err := c.newFixedLayoutBook(images, cover, options)
+	if err != nil {
+		return "", err
+	}
+	if err := book.write(outputPath); err != nil {
+		return "", fmt.Errorf("failed to write EPUB: %w", err)
+	}
+
+	c.clearCachedPages()
+	return outputPath, nil
+}
+
+// fixedLayoutBook is the content of a fixed-layout EPUB
+type fixedLayoutBook struct {
+	ID          string
+	Title       string
+	TitleSort   string // Sort key of the title, empty for the title itself
+	Author      string
+	Series      string
+	SeriesIndex string
+	Language    string
+	Modified    string
+	Orientation string // rendition:orientation
+	Spread      string // rendition:spread
+	Direction   string // Spine page-progression-direction
+	PanelView   bool   // Kindle region magnification, see addPanelView
+	Resolution  string // Screen size the pages were made for, WIDTHxHEIGHT
+	Cover       *fixedLayoutImage
+	Pages       []fixedLayoutPage
+	Chapters    []fixedLayoutChapter
+}
+
+type fixedLayoutImage struct {
+	Href      string
+	MediaType string
+	Data      []byte
+	Path      string // Read when Data is nil, see ProcessedImage
+}
+
+// read returns the image, from its file when not in memory
+func (img fixedLayoutImage) read() ([]byte, error) {
+	return ProcessedImage{Data: img.Data, Path: img.Path}.bytes()
+}
+
+type fixedLayoutPage struct {
+	ID     string
+	Href   string
+	Number int
+	Width  int
+	Height int
+	Image  fixedLayoutImage
+	Panels []fixedLayoutPanel
+}
+
+// fixedLayoutChapter is a table of contents entry
+type fixedLayoutChapter struct {
+	Title string
+	Href  string // First page of the chapter
+}
+
+func (c *KindleConverter) newFixedLayoutBook(images []ProcessedImage, cover []byte, options ExportOptions) (*fixedLayoutBook, error) {
+	sort.SliceStable(images, func(i, j int) bool {
+		a := PageOrder{Chapter: images[i].ChapterKey, Page: images[i].PageIndex}
+		return a.Less(PageOrder{Chapter: images[j].ChapterKey, Page: images[j].PageIndex})
+	})
+
+	// The same export gets the same identifier, so readers replace the
+	// previous copy instead of adding a duplicate
+	id := sha256.New()
+	fmt.Fprintln(id, options.Title)
+	for _, chapter := range options.Chapters {
+		fmt.Fprintln(id, chapter)
+	}
+
+	book := &fixedLayoutBook{
+		ID:          "urn:mangas:" + hex.EncodeToString(id.Sum(nil))[:32],
+		Title:       options.Title,
+		TitleSort:   options.TitleSort,
+		Author:      options.Author,
+		Series:      options.Series,
+		Language:    options.Language,
+		Modified:    time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		Orientation: "portrait",
+		Spread:      "none",
+		Direction:   "ltr",
+		Resolution:  fmt.Sprintf("%dx%d", c.device.Width, c.device.Height),
+	}
+	if book.Title == "" {
+		book.Title = "Manga"
+	}
+	if book.Language == "" {
+		book.Language = "en"
+	}
+	if options.SeriesIndex > 0 {
+		book.SeriesIndex = strconv.FormatFloat(options.SeriesIndex, 'f', -1, 64)
+	}
+	if c.device.Orientation == "both" {
+		book.Orientation = "auto"
+		book.Spread = "landscape"
+	}
+	if options.RightToLeft {
+		book.Direction = "rtl"
+	}
+
+	if len(cover) > 0 {
+		mediaType := http.DetectContentType(cover)
+		if strings.HasPrefix(mediaType, "image/") {
+			book.Cover = &fixedLayoutImage{
+				Href:      "images/cover" + getExtensionFromContentType(mediaType),
+				MediaType: mediaType,
+				Data:      cover,
+			}
+		}
+	}
+
+	chapterKey := ""
+	for i, img := range images {
+		number := i + 1
+		// Pages are read once for their size here, and again when written
+		data, err := img.bytes()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read page %d: %w", number, err)
+		}
+		mediaType := http.DetectContentType(data)
+		page := fixedLayoutPage{
+			ID:     fmt.Sprintf("page_%04d", number),
+			Href:   fmt.Sprintf("pages/page_%04d.xhtml", number),
+			Number: number,
+			Width:  c.device.Width,
+			Height: c.device.Height,
+			Image: fixedLayoutImage{
+				Href:      fmt.Sprintf("images/page_%04d%s", number, getExtensionFromContentType(mediaType)),
+				MediaType: mediaType,
+				Data:      img.Data,
+				Path:      img.Path,
+			},
+		}
+		if config, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+			page.Width, page.Height = config.Width, config.Height
+		}
+		book.Pages = append(book.Pages, page)
+
+		if i == 0 || img.ChapterKey != chapterKey {
+			chapterKey = img.ChapterKey
+			book.Chapters = append(book.Chapters, fixedLayoutChapter{
+				Title: fixedLayoutChapterTitle(options, img.ChapterIndex),
+				Href:  page.Href,
+			})
+		}
+	}
+	return book, nil
+}
+
+func fixedLayoutChapterTitle(options ExportOptions, index int) string {
+	if index < len(options.ChapterNumbers) && options.ChapterNumbers[index] != "" {
+		return "Chapter " + options.ChapterNumbers[index]
+	}
+	return fmt.Sprintf("Chapter %d", index+1)
+}
+
+// write stores the book as an EPUB at path, replacing it only once complete.
+// Pages are streamed to the file one at a time.
+func (b *fixedLayoutBook) write(path string) (err error) {
+	tempPath := path + ".tmp"
+	file, err := os.Create(tempPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(tempPath)
+		}
+	}()
+	w := zip.NewWriter(file)
+
+	// The mimetype must come first and uncompressed
+	mimetype, err := w.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := mimetype.Write([]byte("application/epub+zip")); err != nil {
+		return err
+	}
+
+	for _, file := range [][2]string{
+		{"META-INF/container.xml", "container"},
+		{"OEBPS/content.opf", "opf"},
+		{"OEBPS/nav.xhtml", "nav"},
+		{"OEBPS/toc.ncx", "ncx"},
+		{"OEBPS/style.css", "css"},
+	} {
+		if err := writeFixedLayoutFile(w, file[0], file[1], b); err != nil {
+			return err
+		}
+	}
+	for _, page := range b.Pages {
+		data := struct {
+			Book *fixedLayoutBook
+			Page fixedLayoutPage
+		}{b, page}
+		if err := writeFixedLayoutFile(w, "OEBPS/"+page.Href, "page", data); err != nil {
+			return err
+		}
+	}
+
+	images := make([]fixedLayoutImage, 0, len(b.Pages)+1)
+	if b.Cover != nil {
+		images = append(images, *b.Cover)
+	}
+	for _, page := range b.Pages {
+		images = append(images, page.Image)
+	}
+	for _, img := range images {
+		// Images are already compressed
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: "OEBPS/" + img.Href, Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		data, err := img.read()
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(data); err != nil {
+			return err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+// writeFixedLayoutFile renders one of fixedLayoutTemplates into the archive
+func writeFixedLayoutFile(w *zip.Writer, name, tmpl string, data interface{}) error {
+	fw, err := w.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := fixedLayoutTemplates.ExecuteTemplate(fw, tmpl, data); err != nil {
+		return fmt.Errorf("failed to render %s: %w", name, err)
+	}
+	return nil
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+var fixedLayoutTemplates = template.Must(template.New("fixed-layout").Funcs(template.FuncMap{
+	"xml":           xmlEscape,
+	"inc":           func(i int) int { return i + 1 },
+	"accessibility": accessibilityMetadata,
+}).Parse(`
+{{- define "container" -}}
+<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+{{end}}
+
+{{- define "opf" -}}
+<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="BookID" prefix="rendition: http://www.idpf.org/vocab/rendition/#">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="BookID">{{xml .ID}}</dc:identifier>
+    <dc:title id="title">{{xml .Title}}</dc:title>
+    {{- if .TitleSort}}
+    <meta refines="#title" property="file-as">{{xml .TitleSort}}</meta>
+    <meta name="calibre:title_sort" content="{{xml .TitleSort}}"/>
+    {{- end}}
+    {{- if .Author}}
+    <dc:creator>{{xml .Author}}</dc:creator>
+    {{- end}}
+    <dc:language>{{xml .Language}}</dc:language>
+    <meta property="dcterms:modified">{{.Modified}}</meta>
+    <meta property="rendition:layout">pre-paginated</meta>
+    <meta property="rendition:orientation">{{.Orientation}}</meta>
+    <meta property="rendition:spread">{{.Spread}}</meta>
+    {{- if .PanelView}}
+    <meta name="book-type" content="comic"/>
+    <meta name="fixed-layout" content="true"/>
+    <meta name="region-mag" content="true"/>
+    <meta name="zero-gutter" content="true"/>
+    <meta name="zero-margin" content="true"/>
+    <meta name="orientation-lock" content="{{.Orientation}}"/>
+    <meta name="original-resolution" content="{{.Resolution}}"/>
+    {{- if eq .Direction "rtl"}}
+    <meta name="primary-writing-mode" content="horizontal-rl"/>
+    {{- end}}
+    {{- end}}
+    {{- range accessibility false}}
+    <meta property="{{.Property}}">{{xml .Value}}</meta>
+    {{- end}}
+    {{- if .Cover}}
+    <meta name="cover" content="cover-image"/>
+    {{- end}}
+    {{- if .Series}}
+    <meta property="belongs-to-collection" id="series">{{xml .Series}}</meta>
+    <meta refines="#series" property="collection-type">series</meta>
+    {{- if .SeriesIndex}}
+    <meta refines="#series" property="group-position">{{.SeriesIndex}}</meta>
+    {{- end}}
+    <meta name="calibre:series" content="{{xml .Series}}"/>
+    {{- if .SeriesIndex}}
+    <meta name="calibre:series_index" content="{{.SeriesIndex}}"/>
+    {{- end}}
+    {{- end}}
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="css" href="style.css" media-type="text/css"/>
+    {{- with .Cover}}
+    <item id="cover-image" href="{{.Href}}" media-type="{{.MediaType}}" properties="cover-image"/>
+    {{- end}}
+    {{- range .Pages}}
+    <item id="{{.ID}}" href="{{.Href}}" media-type="application/xhtml+xml"/>
+    <item id="{{.ID}}_image" href="{{.Image.Href}}" media-type="{{.Image.MediaType}}"/>
+    {{- end}}
+  </manifest>
+  <spine toc="ncx" page-progression-direction="{{.Direction}}">
+    {{- range .Pages}}
+    <itemref idref="{{.ID}}"/>
+    {{- end}}
+  </spine>
+</package>
+{{end}}
+
+{{- define "nav" -}}
+<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head>
+  <title>{{xml .Title}}</title>
+</head>
+<body>
+  <nav epub:type="toc" id="toc">
+    <ol>
+      {{- range .Chapters}}
+      <li><a href="{{.Href}}">{{xml .Title}}</a></li>
+      {{- end}}
+    </ol>
+  </nav>
+</body>
+</html>
+{{end}}
+
+{{- define "ncx" -}}
+<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <head>
+    <meta name="dtb:uid" content="{{xml .ID}}"/>
+  </head>
+  <docTitle><text>{{xml .Title}}</text></docTitle>
+  <navMap>
+    {{- range $i, $chapter := .Chapters}}
+    <navPoint id="chapter_{{inc $i}}" playOrder="{{inc $i}}">
+      <navLabel><text>{{xml $chapter.Title}}</text></navLabel>
+      <content src="{{$chapter.Href}}"/>
+    </navPoint>
+    {{- end}}
+  </navMap>
+</ncx>
+{{end}}
+
+{{- define "css" -}}
+html, body {
+  margin: 0;
+  padding: 0;
+  width: 100%;
+  height: 100%;
+}
+img {
+  display: block;
+  width: 100%;
+  height: 100%;
+}
+{{- if .PanelView}}
+#PV {
+  position: absolute;
+  top: 0;
+  left: 0;
+  width: 100%;
+  height: 100%;
+}
+.PV-R {
+  position: absolute;
+}
+.PV-R a {
+  display: block;
+  width: 100%;
+  height: 100%;
+}
+.PV-P {
+  position: absolute;
+  top: 0;
+  left: 0;
+  width: 100%;
+  height: 100%;
+  overflow: hidden;
+  display: none;
+}
+.PV-P img {
+  position: absolute;
+}
+{{- end}}
+{{end}}
+
+{{- define "page" -}}
+<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head>
+  <title>{{xml .Book.Title}} - {{.Page.Number}}</title>
+  <meta name="viewport" content="width={{.Page.Width}}, height={{.Page.Height}}"/>
+  <link rel="stylesheet" type="text/css" href="../style.css"/>
+</head>
+<body>
+  <img src="../{{.Page.Image.Href}}" alt="Page {{.Page.Number}}"/>
+  {{- if .Page.Panels}}
+  <div id="PV">
+    {{- range .Page.Panels}}
+    <div class="PV-R" id="{{.ID}}" style="left:{{.Left}};top:{{.Top}};width:{{.Width}};height:{{.Height}};">
+      <a class="app-amzn-magnify" data-app-amzn-magnify='{"targetId":"{{.ID}}-P", "ordinal":{{.Ordinal}}}'></a>
+    </div>
+    {{- end}}
+  </div>
+  {{- range .Page.Panels}}
+  <div class="PV-P" id="{{.ID}}-P">
+    <img src="../{{$.Page.Image.Href}}" style="left:{{.ImageLeft}}px;top:{{.ImageTop}}px;width:{{.ImageWidth}}px;height:{{.ImageHeight}}px;" alt=""/>
+  </div>
+  {{- end}}
+  {{- end}}
+</body>
+</html>
+{{end}}`))
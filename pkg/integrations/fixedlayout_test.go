@@ -0,0 +1,239 @@
+package integrations
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+func TestNewExporter(t *testing.T) {
+	kindle, err := NewExporter("kindle-paperwhite3")
+	if err != nil {
+		t.Fatalf("NewExporter(kindle) error = %v", err)
+	}
+	defer kindle.Close()
+	if _, ok := kindle.(*KindleConverter); !ok {
+		t.Errorf("Expected a KindleConverter, got %T", kindle)
+	}
+
+	kobo, err := NewExporter("kobo-libra")
+	if err != nil {
+		t.Fatalf("NewExporter(kobo) error = %v", err)
+	}
+	defer kobo.Close()
+	if _, ok := kobo.(*FixedLayoutConverter); !ok {
+		t.Errorf("Expected a FixedLayoutConverter, got %T", kobo)
+	}
+
+	if _, err := NewExporter("nook"); err == nil {
+		t.Error("Expected an error for an unknown device")
+	}
+}
+
+func TestFixedLayoutPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		format KindleFormat
+		want   string
+	}{
+		{"out/book.mobi", FormatEPUB, "out/book.epub"},
+		{"out/book", "", "out/book.epub"},
+		{"out/book.epub", FormatKEPUB, "out/book.kepub.epub"},
+		{"out/book.kepub.epub", FormatEPUB, "out/book.epub"},
+	}
+	for _, tt := range tests {
+		if got := FixedLayoutPath(tt.path, tt.format); got != tt.want {
+			t.Errorf("FixedLayoutPath(%q, %q) = %q, want %q", tt.path, tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestFixedLayoutConverter(t *testing.T) {
+	// Two chapters, given out of order
+	chapter := func(number string, sizes ...int) string {
+		builder := NewEPubBuilder(t.TempDir())
+		if err := builder.Init(&data.Manga{ID: "m", Name: "Fixed"}, &data.Chapter{ID: number, Number: number}); err != nil {
+			t.Fatalf("Init() failed: %v", err)
+		}
+		for i, width := range sizes {
+			builder.Next(ImageData{Content: createTestPage(t, width, 80), ContentType: "image/png", Index: i})
+		}
+		path, err := builder.Done()
+		if err != nil {
+			t.Fatalf("Done() failed: %v", err)
+		}
+		return path
+	}
+	second := chapter("2", 30)
+	first := chapter("1", 60, 40)
+
+	coverPath := filepath.Join(t.TempDir(), "cover.png")
+	os.WriteFile(coverPath, createTestPNG(), 0644)
+
+	converter, err := NewFixedLayoutConverter("kobo-clara")
+	if err != nil {
+		t.Fatalf("NewFixedLayoutConverter() error = %v", err)
+	}
+	converter.tempDir = t.TempDir()
+	defer converter.Close()
+
+	var updates []ExportProgress
+	outputPath, err := converter.ConvertChapters(ExportOptions{
+		Title:          "Fixed & Friends",
+		Author:         "Someone",
+		Format:         FormatKEPUB,
+		Chapters:       []string{second, first},
+		ChapterNumbers: []string{"2", "1"},
+		OutputPath:     filepath.Join(t.TempDir(), "fixed.epub"),
+		RightToLeft:    true,
+		CoverImage:     coverPath,
+		Series:         "Fixed",
+		SeriesIndex:    1,
+		TitleSort:      "Fixed and Friends",
+		OnProgress:     func(progress ExportProgress) { updates = append(updates, progress) },
+	})
+	if err != nil {
+		t.Fatalf("ConvertChapters() error = %v", err)
+	}
+	want := []ExportProgress{{ExportProcessing, 1, 2}, {ExportProcessing, 2, 2}, {ExportWriting, 2, 2}}
+	if !slices.Equal(updates, want) {
+		t.Errorf("Expected progress %v, got %v", want, updates)
+	}
+	if !strings.HasSuffix(outputPath, "fixed.kepub.epub") {
+		t.Errorf("Expected a kepub output, got %s", outputPath)
+	}
+	if converter.Report().PagesProcessed != 3 {
+		t.Errorf("Expected 3 processed pages, got %d", converter.Report().PagesProcessed)
+	}
+
+	reader, err := zip.OpenReader(outputPath)
+	if err != nil {
+		t.Fatalf("failed to open EPUB: %v", err)
+	}
+	defer reader.Close()
+
+	if reader.File[0].Name != "mimetype" || reader.File[0].Method != zip.Store {
+		t.Error("Expected an uncompressed mimetype as the first entry")
+	}
+	files := make(map[string]string)
+	for _, f := range reader.File {
+		rc, _ := f.Open()
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(content)
+	}
+
+	opf := files["OEBPS/content.opf"]
+	for _, want := range []string{
+		`<meta property="rendition:layout">pre-paginated</meta>`,
+		`<meta property="rendition:orientation">portrait</meta>`,
+		`<meta property="rendition:spread">none</meta>`,
+		`page-progression-direction="rtl"`,
+		`<dc:title id="title">Fixed &amp; Friends</dc:title>`,
+		`<meta refines="#title" property="file-as">Fixed and Friends</meta>`,
+		`properties="cover-image"`,
+		`<meta refines="#series" property="group-position">1</meta>`,
+		`<meta property="schema:accessMode">visual</meta>`,
+	} {
+		if !strings.Contains(opf, want) {
+			t.Errorf("package document misses %s:\n%s", want, opf)
+		}
+	}
+	if strings.Count(opf, "<itemref") != 3 {
+		t.Errorf("Expected 3 pages in the spine:\n%s", opf)
+	}
+
+	// Pages are sized after their image and ordered by chapter
+	if !strings.Contains(files["OEBPS/pages/page_0001.xhtml"], `content="width=60, height=80"`) {
+		t.Errorf("Expected the first page of chapter 1 first:\n%s", files["OEBPS/pages/page_0001.xhtml"])
+	}
+	if !strings.Contains(files["OEBPS/pages/page_0003.xhtml"], `content="width=30, height=80"`) {
+		t.Errorf("Expected chapter 2 last:\n%s", files["OEBPS/pages/page_0003.xhtml"])
+	}
+
+	nav := files["OEBPS/nav.xhtml"]
+	one := strings.Index(nav, `<a href="pages/page_0001.xhtml">Chapter 1</a>`)
+	two := strings.Index(nav, `<a href="pages/page_0003.xhtml">Chapter 2</a>`)
+	if one < 0 || two < one {
+		t.Errorf("Expected a table of contents entry per chapter:\n%s", nav)
+	}
+
+	if _, err := converter.ConvertChapters(ExportOptions{Chapters: []string{first}, Format: FormatMOBI, OutputPath: outputPath}); err == nil {
+		t.Error("Expected an error for a Kindle-only format")
+	}
+}
+
+func TestFixedLayoutConverter_SameNumber(t *testing.T) {
+	// Two releases of chapter 1, in Japanese
+	chapter := func(id string, sizes ...int) string {
+		builder := NewEPubBuilder(t.TempDir())
+		if err := builder.Init(&data.Manga{ID: "m", Name: "Fixed"}, &data.Chapter{ID: id, Number: "1", Language: "ja"}); err != nil {
+			t.Fatalf("Init() failed: %v", err)
+		}
+		for i, width := range sizes {
+			builder.Next(ImageData{Content: createTestPage(t, width, 80), ContentType: "image/png", Index: i})
+		}
+		path, err := builder.Done()
+		if err != nil {
+			t.Fatalf("Done() failed: %v", err)
+		}
+		return path
+	}
+	first := chapter("a", 60, 50)
+	second := chapter("b", 40, 30)
+
+	converter, err := NewFixedLayoutConverter("kobo-clara")
+	if err != nil {
+		t.Fatalf("NewFixedLayoutConverter() error = %v", err)
+	}
+	converter.tempDir = t.TempDir()
+	defer converter.Close()
+
+	outputPath, err := converter.ConvertChapters(ExportOptions{
+		Title:          "Fixed",
+		Chapters:       []string{first, second},
+		ChapterNumbers: []string{"1", "1"},
+		OutputPath:     filepath.Join(t.TempDir(), "fixed.epub"),
+		Language:       "ja",
+	})
+	if err != nil {
+		t.Fatalf("ConvertChapters() error = %v", err)
+	}
+	if _, err := os.Stat(outputPath + ".tmp"); !os.IsNotExist(err) {
+		t.Error("Expected no temporary file left")
+	}
+
+	reader, err := zip.OpenReader(outputPath)
+	if err != nil {
+		t.Fatalf("failed to open EPUB: %v", err)
+	}
+	defer reader.Close()
+	files := make(map[string]string)
+	for _, f := range reader.File {
+		rc, _ := f.Open()
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(content)
+	}
+
+	if !strings.Contains(files["OEBPS/content.opf"], "<dc:language>ja</dc:language>") {
+		t.Errorf("Expected the language of the chapters:\n%s", files["OEBPS/content.opf"])
+	}
+	// The pages of each release stay together
+	for i, width := range []int{60, 50, 40, 30} {
+		page := files[fmt.Sprintf("OEBPS/pages/page_%04d.xhtml", i+1)]
+		if !strings.Contains(page, fmt.Sprintf(`content="width=%d, height=80"`, width)) {
+			t.Errorf("Expected page %d %d pixels wide:\n%s", i+1, width, page)
+		}
+	}
+	if nav := files["OEBPS/nav.xhtml"]; strings.Count(nav, "<li>") != 2 || !strings.Contains(nav, `<a href="pages/page_0003.xhtml">`) {
+		t.Errorf("Expected a table of contents entry per release:\n%s", nav)
+	}
+}
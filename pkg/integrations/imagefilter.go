@@ -0,0 +1,214 @@
+package integrations
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ImageFilter is an extra transform of the pages of an export, such as
+// watermark removal or a custom levels curve. Filters run on every page
+// after it is resized and before the e-ink adjustments and encoding. Pages
+// are processed in parallel, so a filter must be safe for concurrent use.
+type ImageFilter interface {
+	// Filter returns the filtered page. It may change img in place and
+	// return it.
+	Filter(img *image.RGBA) (image.Image, error)
+}
+
+// versionedFilter is a filter whose output can change while its spec stays
+// the same, such as a script being edited. Its version is part of the cache
+// key of processed pages.
+type versionedFilter interface {
+	Version() string
+}
+
+// ImageFilterFunc adapts a function to ImageFilter
+type ImageFilterFunc func(img *image.RGBA) (image.Image, error)
+
+// Filter calls f(img)
+func (f ImageFilterFunc) Filter(img *image.RGBA) (image.Image, error) {
+	return f(img)
+}
+
+// ImageFilterFactory creates a filter from the arguments given in its spec
+type ImageFilterFactory func(args string) (ImageFilter, error)
+
+// ImageFilterSpec names a registered filter and its arguments, written
+// "name" or "name=args"
+type ImageFilterSpec struct {
+	Name string
+	Args string
+}
+
+// ParseImageFilterSpec parses a spec written "name" or "name=args"
+func ParseImageFilterSpec(spec string) (ImageFilterSpec, error) {
+	name, args, _ := strings.Cut(spec, "=")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ImageFilterSpec{}, fmt.Errorf("invalid image filter %q, use name or name=args", spec)
+	}
+	return ImageFilterSpec{Name: name, Args: strings.TrimSpace(args)}, nil
+}
+
+func (s ImageFilterSpec) String() string {
+	if s.Args == "" {
+		return s.Name
+	}
+	return s.Name + "=" + s.Args
+}
+
+// imageFilterEntry is a registered filter
+type imageFilterEntry struct {
+	description string
+	factory     ImageFilterFactory
+}
+
+var (
+	imageFiltersMu sync.RWMutex
+	imageFilters   = make(map[string]imageFilterEntry)
+)
+
+// RegisterImageFilter makes a filter available to export profiles under
+// name, replacing any previous one. Plugins call it from their init.
+func RegisterImageFilter(name, description string, factory ImageFilterFactory) {
+	imageFiltersMu.Lock()
+	defer imageFiltersMu.Unlock()
+	imageFilters[name] = imageFilterEntry{description: description, factory: factory}
+}
+
+// ListImageFilters returns the names and descriptions of the registered
+// filters, sorted by name
+func ListImageFilters() []string {
+	imageFiltersMu.RLock()
+	defer imageFiltersMu.RUnlock()
+	filters := make([]string, 0, len(imageFilters))
+	for name, entry := range imageFilters {
+		filters = append(filters, name+": "+entry.description)
+	}
+	sort.Strings(filters)
+	return filters
+}
+
+// NewImageFilter creates the registered filter a spec names
+func NewImageFilter(spec ImageFilterSpec) (ImageFilter, error) {
+	imageFiltersMu.RLock()
+	entry, ok := imageFilters[spec.Name]
+	imageFiltersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown image filter: %s", spec.Name)
+	}
+	filter, err := entry.factory(spec.Args)
+	if err != nil {
+		return nil, fmt.Errorf("image filter %s: %w", spec.Name, err)
+	}
+	return filter, nil
+}
+
+// NewImageFilters creates the filters of specs, in order
+func NewImageFilters(specs []ImageFilterSpec) ([]ImageFilter, error) {
+	filters := make([]ImageFilter, len(specs))
+	for i, spec := range specs {
+		filter, err := NewImageFilter(spec)
+		if err != nil {
+			return nil, err
+		}
+		filters[i] = filter
+	}
+	return filters, nil
+}
+
+func init() {
+	RegisterImageFilter("levels", "map black,white[,gamma] input levels to the full range, e.g. levels=20,235", newLevelsFilter)
+	RegisterImageFilter("command", "pipe each page as PNG through a script printing the filtered image, e.g. command=./clean.sh", newCommandFilter)
+}
+
+// newLevelsFilter creates a levels adjustment from "black,white[,gamma]":
+// inputs at or under black become black, at or over white become white
+func newLevelsFilter(args string) (ImageFilter, error) {
+	parts := strings.Split(args, ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("expected black,white[,gamma], got %q", args)
+	}
+	black, err1 := strconv.Atoi(strings.TrimSpace(parts[0]))
+	white, err2 := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err1 != nil || err2 != nil || black < 0 || white > 255 || black >= white {
+		return nil, fmt.Errorf("levels must be 0 <= black < white <= 255, got %q", args)
+	}
+	gamma := 1.0
+	if len(parts) == 3 {
+		if gamma, err1 = strconv.ParseFloat(strings.TrimSpace(parts[2]), 64); err1 != nil || gamma <= 0 {
+			return nil, fmt.Errorf("invalid gamma %q", parts[2])
+		}
+	}
+
+	curve := levelsCurve(black, white, gamma)
+	return ImageFilterFunc(func(img *image.RGBA) (image.Image, error) {
+		applyCurve(img, curve)
+		return img, nil
+	}), nil
+}
+
+// levelsCurve returns the lookup table mapping the black to white input
+// levels to the full range, with a gamma correction
+func levelsCurve(black, white int, gamma float64) *[256]uint8 {
+	var curve [256]uint8
+	for i := range curve {
+		value := math.Max(0, math.Min(1, float64(i-black)/float64(white-black)))
+		curve[i] = uint8(math.Round(255 * math.Pow(value, 1/gamma)))
+	}
+	return &curve
+}
+
+// commandFilter runs an external program on every page, given as PNG on
+// its standard input; it prints the filtered image in any decodable format
+type commandFilter struct {
+	path string // Resolved program
+	args []string
+}
+
+func newCommandFilter(args string) (ImageFilter, error) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("expected a command, e.g. command=./clean.sh")
+	}
+	path, err := exec.LookPath(fields[0])
+	if err != nil {
+		return nil, err
+	}
+	return &commandFilter{path: path, args: fields}, nil
+}
+
+// Version changes whenever the program is modified
+func (f *commandFilter) Version() string {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return f.path
+	}
+	return fmt.Sprintf("%s:%d:%d", f.path, info.Size(), info.ModTime().UnixNano())
+}
+
+func (f *commandFilter) Filter(img *image.RGBA) (image.Image, error) {
+	var input, output, stderr bytes.Buffer
+	if err := png.Encode(&input, img); err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(f.path, f.args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = &input, &output, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s failed: %w: %s", f.args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	filtered, _, err := image.Decode(&output)
+	if err != nil {
+		return nil, fmt.Errorf("%s printed no image: %w", f.args[0], err)
+	}
+	return filtered, nil
+}
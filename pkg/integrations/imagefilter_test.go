@@ -0,0 +1,135 @@
+package integrations
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os/exec"
+	"testing"
+)
+
+func TestParseImageFilterSpec(t *testing.T) {
+	tests := []struct {
+		spec string
+		want ImageFilterSpec
+	}{
+		{"levels=16,240", ImageFilterSpec{Name: "levels", Args: "16,240"}},
+		{"command=./clean.sh --strength 2", ImageFilterSpec{Name: "command", Args: "./clean.sh --strength 2"}},
+		{"despeckle", ImageFilterSpec{Name: "despeckle"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseImageFilterSpec(tt.spec)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseImageFilterSpec(%q) = %+v, %v, want %+v", tt.spec, got, err, tt.want)
+		}
+		if got.String() != tt.spec {
+			t.Errorf("String() = %q, want %q", got.String(), tt.spec)
+		}
+	}
+	if _, err := ParseImageFilterSpec("=16,240"); err == nil {
+		t.Error("Expected an error for a spec without a name")
+	}
+}
+
+func TestLevelsFilter(t *testing.T) {
+	filter, err := NewImageFilter(ImageFilterSpec{Name: "levels", Args: "50,200"})
+	if err != nil {
+		t.Fatalf("NewImageFilter() error = %v", err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 3, 1))
+	img.Set(0, 0, color.RGBA{40, 40, 40, 255})
+	img.Set(1, 0, color.RGBA{125, 125, 125, 255})
+	img.Set(2, 0, color.RGBA{210, 210, 210, 255})
+
+	filtered, err := filter.Filter(img)
+	if err != nil {
+		t.Fatalf("Filter() error = %v", err)
+	}
+	for x, want := range []uint8{0, 128, 255} {
+		if got := filtered.(*image.RGBA).RGBAAt(x, 0).R; got != want {
+			t.Errorf("Pixel %d = %d, want %d", x, got, want)
+		}
+	}
+
+	for _, args := range []string{"", "200,50", "0,300", "10,20,-1"} {
+		if _, err := NewImageFilter(ImageFilterSpec{Name: "levels", Args: args}); err == nil {
+			t.Errorf("Expected an error for levels=%s", args)
+		}
+	}
+	if _, err := NewImageFilter(ImageFilterSpec{Name: "no-such-filter"}); err == nil {
+		t.Error("Expected an error for an unknown filter")
+	}
+}
+
+func TestCommandFilter(t *testing.T) {
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("cat not available")
+	}
+	// cat prints the page it is given back
+	filter, err := NewImageFilter(ImageFilterSpec{Name: "command", Args: "cat"})
+	if err != nil {
+		t.Fatalf("NewImageFilter() error = %v", err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	img.Set(1, 2, color.RGBA{10, 20, 30, 255})
+	filtered, err := filter.Filter(img)
+	if err != nil {
+		t.Fatalf("Filter() error = %v", err)
+	}
+	if r, g, b, _ := filtered.At(1, 2).RGBA(); r>>8 != 10 || g>>8 != 20 || b>>8 != 30 {
+		t.Errorf("Expected the page back, got %d,%d,%d", r>>8, g>>8, b>>8)
+	}
+	if filter.(versionedFilter).Version() == "" {
+		t.Error("Expected the command to have a version")
+	}
+
+	if _, err := NewImageFilter(ImageFilterSpec{Name: "command", Args: "mangas-no-such-command"}); err == nil {
+		t.Error("Expected an error for a missing command")
+	}
+}
+
+func TestImageProcessor_Filters(t *testing.T) {
+	// A plugin filter inverting pages, run on the resized page
+	var size image.Point
+	RegisterImageFilter("test-invert", "invert colors", func(args string) (ImageFilter, error) {
+		return ImageFilterFunc(func(img *image.RGBA) (image.Image, error) {
+			size = img.Bounds().Size()
+			for i := 0; i < len(img.Pix); i += 4 {
+				img.Pix[i], img.Pix[i+1], img.Pix[i+2] = 255-img.Pix[i], 255-img.Pix[i+1], 255-img.Pix[i+2]
+			}
+			return img, nil
+		}), nil
+	})
+
+	converter, err := NewKindleConverter("kindle-paperwhite3")
+	if err != nil {
+		t.Fatalf("NewKindleConverter() error = %v", err)
+	}
+	defer converter.Close()
+	if err := converter.SetFilters([]ImageFilterSpec{{Name: "test-invert"}}); err != nil {
+		t.Fatalf("SetFilters() error = %v", err)
+	}
+
+	page := image.NewGray(image.Rect(0, 0, 2000, 3000))
+	var buf bytes.Buffer
+	png.Encode(&buf, page)
+	processed, err := converter.processor.ProcessImageData(buf.Bytes())
+	if err != nil {
+		t.Fatalf("ProcessImageData() error = %v", err)
+	}
+	if size.X > converter.settings.MaxWidth || size.Y > converter.settings.MaxHeight {
+		t.Errorf("Expected the filter to get the resized page, got %v", size)
+	}
+	img, _, err := image.Decode(bytes.NewReader(processed))
+	if err != nil {
+		t.Fatalf("Failed to decode processed page: %v", err)
+	}
+	if r, _, _, _ := img.At(10, 10).RGBA(); r>>8 < 200 {
+		t.Errorf("Expected a black page turned white, got %d", r>>8)
+	}
+
+	if err := converter.SetFilters([]ImageFilterSpec{{Name: "no-such-filter"}}); err == nil {
+		t.Error("Expected an error for an unknown filter")
+	}
+}
@@ -0,0 +1,246 @@
+package integrations
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/utils"
+	_ "golang.org/x/image/webp"
+)
+
+// ImageCheck is how thoroughly downloaded pages are checked before they are
+// added to a book
+type ImageCheck string
+
+const (
+	ImageCheckOff    ImageCheck = "off"    // Pages are used as received
+	ImageCheckMagic  ImageCheck = "magic"  // The file signature must be an image matching the Content-Type
+	ImageCheckDecode ImageCheck = "decode" // The image must also decode completely
+)
+
+// ParseImageCheck parses an image check name
+func ParseImageCheck(name string) (ImageCheck, error) {
+	switch check := ImageCheck(strings.ToLower(name)); check {
+	case ImageCheckOff, ImageCheckMagic, ImageCheckDecode:
+		return check, nil
+	case "":
+		return ImageCheckOff, nil
+	}
+	return "", fmt.Errorf("unknown image check %q (use off, magic or decode)", name)
+}
+
+// CheckImage reports whether content is an intact image of contentType, as
+// thoroughly as check asks. An empty or non-image contentType only requires
+// content to be some image.
+func CheckImage(content []byte, contentType string, check ImageCheck) error {
+	if check == ImageCheckOff || check == "" {
+		return nil
+	}
+	if len(content) == 0 {
+		return fmt.Errorf("image is empty")
+	}
+
+	sniffed := sniffImage(content)
+	if sniffed == "" {
+		return fmt.Errorf("not an image (%s)", http.DetectContentType(content))
+	}
+	declared := normalizeImageType(contentType)
+	if strings.HasPrefix(declared, "image/") && declared != sniffed {
+		return fmt.Errorf("content is %s, not %s", sniffed, declared)
+	}
+
+	// AVIF only decodes with an external decoder, without one its signature
+	// is all that can be checked
+	if check == ImageCheckDecode && (sniffed != "image/avif" || AVIFDecoder() != "") {
+		if _, _, err := image.Decode(bytes.NewReader(content)); err != nil {
+			return fmt.Errorf("%s does not decode: %w", sniffed, err)
+		}
+	}
+	return nil
+}
+
+// sniffImage returns the type of image content starts with, "" when it is
+// not an image
+func sniffImage(content []byte) string {
+	if len(content) >= 12 && string(content[4:8]) == "ftyp" {
+		switch string(content[8:12]) {
+		case "avif", "avis":
+			return "image/avif"
+		}
+	}
+	switch detected := http.DetectContentType(content); detected {
+	case "image/jpeg", "image/png", "image/gif", "image/webp", "image/bmp":
+		return detected
+	}
+	return ""
+}
+
+// normalizeImageType lowercases a Content-Type without its parameters,
+// folding aliases servers use
+func normalizeImageType(contentType string) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	switch mediaType {
+	case "image/jpg", "image/pjpeg":
+		return "image/jpeg"
+	case "image/x-png":
+		return "image/png"
+	case "image/x-ms-bmp":
+		return "image/bmp"
+	}
+	return mediaType
+}
+
+// HashPages returns the hex encoded SHA-256 of each image, in order
+func HashPages(images []ImageData) []string {
+	sums := make([]string, len(images))
+	for i, img := range images {
+		sums[i] = HashPage(img.Content)
+	}
+	return sums
+}
+
+// HashPage returns the SHA-256 checksum of a page image, as HashPages
+func HashPage(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// ReadBookPages returns the page images of a downloaded EPUB or CBZ in
+// reading order. Reading every entry also checks the CRC of the archive.
+func ReadBookPages(bookPath string) ([][]byte, error) {
+	if strings.EqualFold(filepath.Ext(bookPath), ".epub") {
+		return ReadEPUBPages(bookPath)
+	}
+
+	book, err := OpenBook(bookPath)
+	if err != nil {
+		return nil, err
+	}
+	defer book.Close()
+
+	pages := make([][]byte, 0, book.Len())
+	for i := range book.Len() {
+		content, err := book.Page(i)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, content)
+	}
+	return pages, nil
+}
+
+// Book is a downloaded EPUB or CBZ opened to read its pages one at a time,
+// without holding all of them
+type Book struct {
+	archive *zip.ReadCloser
+	pages   []*zip.File // In reading order
+}
+
+// OpenBook opens a downloaded EPUB or CBZ and lists its pages, the covers
+// of an EPUB left out. The Book must be closed.
+func OpenBook(bookPath string) (*Book, error) {
+	epub := strings.EqualFold(filepath.Ext(bookPath), ".epub")
+	archive, err := zip.OpenReader(bookPath)
+	if err != nil {
+		if epub {
+			return nil, fmt.Errorf("failed to open EPUB: %w", err)
+		}
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	var files []*zip.File
+	for _, file := range archive.File {
+		name := strings.ToLower(filepath.Base(file.Name))
+		if epub && !strings.HasPrefix(name, "page_") {
+			continue
+		}
+		switch filepath.Ext(name) {
+		case ".jpg", ".jpeg", ".png", ".gif", ".webp", ".avif":
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		archive.Close()
+		return nil, fmt.Errorf("no pages found in %s", filepath.Base(bookPath))
+	}
+	// EPUB pages are named after their PageOrder, so natural name order is
+	// reading order
+	sort.Slice(files, func(i, j int) bool {
+		return utils.NaturalLess(files[i].Name, files[j].Name)
+	})
+	return &Book{archive: archive, pages: files}, nil
+}
+
+// Len returns the number of pages of the book
+func (b *Book) Len() int {
+	return len(b.pages)
+}
+
+// Page reads the page at index, from 0
+func (b *Book) Page(index int) ([]byte, error) {
+	if index < 0 || index >= len(b.pages) {
+		return nil, fmt.Errorf("no page %d", index+1)
+	}
+	file := b.pages[index]
+	rc, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", file.Name, err)
+	}
+	defer rc.Close()
+	content, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file.Name, err)
+	}
+	return content, nil
+}
+
+// volumePagePattern matches the pages of a volume EPUB, named after their
+// PageOrder with the ChapterKey of their chapter
+var volumePagePattern = regexp.MustCompile(`^page_(.+)_\d{3}_\d+\.`)
+
+// ChapterPages returns the indexes of the pages of a chapter, told apart by
+// their names in a volume. Books of one chapter have all their pages.
+func (b *Book) ChapterPages(number string) ([]int, error) {
+	key := utils.ChapterSortKey(number)
+	var pages []int
+	volume := false
+	for i, file := range b.pages {
+		m := volumePagePattern.FindStringSubmatch(strings.ToLower(filepath.Base(file.Name)))
+		if m == nil {
+			continue
+		}
+		volume = true
+		if m[1] == strings.ToLower(key) {
+			pages = append(pages, i)
+		}
+	}
+	if !volume {
+		pages = make([]int, len(b.pages))
+		for i := range pages {
+			pages[i] = i
+		}
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no pages of chapter %s in the book", number)
+	}
+	return pages, nil
+}
+
+// Close closes the book's archive
+func (b *Book) Close() error {
+	return b.archive.Close()
+}
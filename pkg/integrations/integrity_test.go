@@ -0,0 +1,104 @@
+package integrations
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCheckImage(t *testing.T) {
+	page := createTestPage(t, 10, 20)
+	avif := append([]byte{0, 0, 0, 0x1c}, []byte("ftypavif")...)
+
+	tests := []struct {
+		name        string
+		content     []byte
+		contentType string
+		check       ImageCheck
+		wantErr     bool
+	}{
+		{"off accepts anything", []byte("<html>"), "image/png", ImageCheckOff, false},
+		{"matching type", page, "image/png", ImageCheckMagic, false},
+		{"type parameters and case", page, "Image/PNG; charset=binary", ImageCheckMagic, false},
+		{"no declared type", page, "", ImageCheckDecode, false},
+		{"non-image declared type", page, "application/octet-stream", ImageCheckDecode, false},
+		{"not an image", []byte("<html>Service unavailable</html>"), "image/png", ImageCheckMagic, true},
+		{"empty", nil, "image/png", ImageCheckMagic, true},
+		{"mismatched type", page, "image/jpeg", ImageCheckMagic, true},
+		{"truncated passes magic", page[:len(page)/2], "image/png", ImageCheckMagic, false},
+		{"truncated fails decode", page[:len(page)/2], "image/png", ImageCheckDecode, true},
+		{"avif signature", avif, "image/avif", ImageCheckDecode, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckImage(tt.content, tt.contentType, tt.check)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckImage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseImageCheck(t *testing.T) {
+	for name, want := range map[string]ImageCheck{"": ImageCheckOff, "magic": ImageCheckMagic, "Decode": ImageCheckDecode} {
+		if got, err := ParseImageCheck(name); err != nil || got != want {
+			t.Errorf("ParseImageCheck(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseImageCheck("strict"); err == nil {
+		t.Error("Expected an error for an unknown check")
+	}
+}
+
+func TestHashPages(t *testing.T) {
+	sums := HashPages([]ImageData{{Content: []byte("a")}, {Content: []byte("a")}, {Content: []byte("b")}})
+	if len(sums) != 3 || sums[0] != sums[1] || sums[0] == sums[2] || len(sums[0]) != 64 {
+		t.Errorf("HashPages() = %v", sums)
+	}
+}
+
+func TestBookChapterPages(t *testing.T) {
+	write := func(names ...string) string {
+		path := filepath.Join(t.TempDir(), "book.epub")
+		file, _ := os.Create(path)
+		w := zip.NewWriter(file)
+		for _, name := range names {
+			f, _ := w.Create("EPUB/images/" + name)
+			f.Write([]byte("page"))
+		}
+		w.Close()
+		file.Close()
+		return path
+	}
+
+	volume := write(
+		"page_00000001.0000_000_0001.png", "page_00000001.0000_000_0002.png",
+		"page_00000002.0000_001_0001.png", "page_00000002.0000_001_0002.png",
+		"page_00000002.5000_002_0001.png",
+	)
+	book, err := OpenBook(volume)
+	if err != nil {
+		t.Fatalf("OpenBook() error = %v", err)
+	}
+	defer book.Close()
+	for number, want := range map[string][]int{"1": {0, 1}, "2": {2, 3}, "2.5": {4}} {
+		if got, err := book.ChapterPages(number); err != nil || !slices.Equal(got, want) {
+			t.Errorf("ChapterPages(%s) = %v, %v, want %v", number, got, err, want)
+		}
+	}
+	if _, err := book.ChapterPages("3"); err == nil {
+		t.Error("Expected an error for a chapter not in the volume")
+	}
+
+	// A book of one chapter is all its pages
+	chapter, err := OpenBook(write("page_0001.png", "page_0002.png"))
+	if err != nil {
+		t.Fatalf("OpenBook() error = %v", err)
+	}
+	defer chapter.Close()
+	if got, _ := chapter.ChapterPages("7"); !slices.Equal(got, []int{0, 1}) {
+		t.Errorf("ChapterPages() = %v, want every page", got)
+	}
+}
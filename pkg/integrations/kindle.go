@@ -203,6 +203,9 @@ type ImageOptimizationSettings struct {
 	Gamma         float64 // Gamma correction for e-ink
 	Format        string  // Output format: "jpeg" or "png"
 	StripMetadata bool    // Remove EXIF data to reduce size
+
+	Preprocess PreprocessOptions // Clean-ups run before resizing
+	Filters    []ImageFilterSpec // Plugin filters run after resizing, see ImageFilter
 }
 
 // GetOptimizationSettings returns recommended settings for a device
@@ -248,9 +251,15 @@ type ExportOptions struct {
 	Title        string
 	Author       string
 	Chapters     []string // Chapter IDs or file paths
+	ChapterNumbers []string // Numbers of Chapters, used to order them; defaults to their position
 	OutputPath   string
 	Optimize     bool // Apply image optimization
 	PanelView    bool // Enable panel view mode
 	RightToLeft  bool // For manga reading direction
 	CoverImage   string // Path to custom cover image
+	Series       string  // Series name used to group volumes on the device
+	SeriesIndex  float64 // Position of this export in the series
+	TitleSort    string  // Sort key for the title, defaults to Title
+	Language     string  // Language of the chapters, e.g. "ja"; defaults to "en"
+	OnProgress   func(ExportProgress) // Called as the export goes, if set
 }
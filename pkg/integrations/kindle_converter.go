@@ -3,23 +3,40 @@ package integrations
 import (
 	"archive/zip"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"io"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/utils"
 )
 
-// KindleConverter converts manga EPUBs to Kindle-optimized format
+// KindleConverter converts manga EPUBs to Kindle-optimized format.
+// Processed pages are cached in tempDir keyed by a hash of the source image and
+// the optimization settings, so an interrupted export resumes where it stopped.
 type KindleConverter struct {
 	device    KindleDevice
 	processor *ImageProcessor
+	settings  ImageOptimizationSettings
+	filterKey string // Versions of the filters, part of the cache key
 	tempDir   string
+
+	mu          sync.Mutex // Guards cachedPages and resumed, pages are processed in parallel
+	cachedPages []string   // Cache files used by the current conversion
+	resumed     int        // Pages reused from a previous run
+	report      *ConversionReport
 }
 
 // NewKindleConverter creates a new Kindle converter for the specified device
@@ -28,73 +45,280 @@ func NewKindleConverter(deviceID string) (*KindleConverter, error) {
 	if !ok {
 		return nil, fmt.Errorf("unknown device: %s", deviceID)
 	}
+	return newKindleConverter(deviceID, device, "mangas-kindle")
+}
 
+// newKindleConverter creates a converter optimizing pages for device, caching
+// them under cacheDir in the cache of utils.Temp
+func newKindleConverter(deviceID string, device KindleDevice, cacheDir string) (*KindleConverter, error) {
 	settings := device.GetOptimizationSettings()
 	processor := NewImageProcessor(settings)
 
-	tempDir, err := os.MkdirTemp("", "kindle-convert-*")
+	// A stable directory per device lets the next run find processed pages
+	tempDir, err := utils.Temp.CacheDir(filepath.Join(cacheDir, deviceID))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+		return nil, err
 	}
 
 	return &KindleConverter{
 		device:    device,
 		processor: processor,
+		settings:  settings,
 		tempDir:   tempDir,
+		report:    newConversionReport(device, 0),
 	}, nil
 }
 
-// ConvertChapters converts multiple chapter EPUBs into a single Kindle-optimized file
-func (c *KindleConverter) ConvertChapters(options ExportOptions) (string, error) {
+// SetFilters sets the plugin filters run on every page, in order. Pages
+// processed with other filters, or other versions of their scripts, are not
+// reused from the cache.
+func (c *KindleConverter) SetFilters(specs []ImageFilterSpec) error {
+	filters, err := NewImageFilters(specs)
+	if err != nil {
+		return err
+	}
+	var versions []string
+	for _, filter := range filters {
+		if versioned, ok := filter.(versionedFilter); ok {
+			versions = append(versions, versioned.Version())
+		}
+	}
+	c.settings.Filters = specs
+	c.filterKey = strings.Join(versions, "\n")
+	c.processor = NewImageProcessor(c.settings)
+	c.processor.filters = filters
+	return nil
+}
+
+// SetPreprocess sets the clean-ups run on pages before they are resized
+func (c *KindleConverter) SetPreprocess(options PreprocessOptions) {
+	c.settings.Preprocess = options
+	filters := c.processor.filters
+	c.processor = NewImageProcessor(c.settings)
+	c.processor.filters = filters
+}
+
+// ResumedPages returns how many pages of the last conversion were reused
+// from an interrupted run instead of being processed again
+func (c *KindleConverter) ResumedPages() int {
+	return c.resumed
+}
+
+// Report returns the report of the last conversion
+func (c *KindleConverter) Report() *ConversionReport {
+	return c.report
+}
+
+// ConvertChapters converts multiple chapter EPUBs into a single Kindle-optimized file.
+// A report of the conversion is stored next to the output, see ReportPath.
+func (c *KindleConverter) ConvertChapters(options ExportOptions) (outputPath string, err error) {
 	if len(options.Chapters) == 0 {
 		return "", fmt.Errorf("no chapters provided")
 	}
 
+	finish := c.beginConversion(options)
+	defer func() { finish(outputPath, err) }()
+
 	// Create output directory if needed
 	outputDir := filepath.Dir(options.OutputPath)
+	if err := utils.CheckOutputPath(options.OutputPath); err != nil {
+		return "", err
+	}
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create output directory: %w", err)
 	}
 
 	// Extract and process all chapter images
+	allImages, chapterTitles, err := c.processChapters(options)
+	if err != nil {
+		return "", err
+	}
+
+	// Generate Kindle-optimized EPUB, fixed-layout with Virtual Panels when
+	// the device supports them
+	reportExport(options, ExportWriting, len(options.Chapters))
+	var epubPath string
+	panelView := options.PanelView && c.device.PanelView
+	if panelView {
+		epubPath, err = c.generatePanelViewEPUB(allImages, options)
+	} else {
+		epubPath, err = c.generateOptimizedEPUB(allImages, chapterTitles, options)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to generate EPUB: %w", err)
+	}
+
+	// Convert to requested format if not EPUB
+	if options.Format != "epub" && options.Format != "" {
+		reportExport(options, ExportConverting, len(options.Chapters))
+		convertedPath, err := c.convertFormat(epubPath, options, panelView)
+		if err != nil {
+			return "", fmt.Errorf("failed to convert format: %w", err)
+		}
+		c.clearCachedPages()
+		return convertedPath, nil
+	}
+
+	c.clearCachedPages()
+	return epubPath, nil
+}
+
+// beginConversion resets the converter for a new export and returns the
+// function recording its outcome in a report stored next to the output
+func (c *KindleConverter) beginConversion(options ExportOptions) func(outputPath string, err error) {
+	c.report = newConversionReport(c.device, len(options.Chapters))
+	c.cachedPages = nil
+	c.resumed = 0
+
+	return func(outputPath string, err error) {
+		format := string(options.Format)
+		if format == "" {
+			format = "epub"
+		}
+		if outputPath == "" {
+			outputPath = options.OutputPath
+		}
+		c.report.PagesResumed = c.resumed
+		c.report.finish(format, outputPath, err)
+		c.report.Write() // Best effort, the report is only for troubleshooting
+	}
+}
+
+// processChapters extracts and optimizes the pages of every chapter of an
+// export, returning them with the title of each chapter
+func (c *KindleConverter) processChapters(options ExportOptions) ([]ProcessedImage, []string, error) {
 	allImages := make([]ProcessedImage, 0)
 	chapterTitles := make([]string, 0)
 
 	for i, chapterPath := range options.Chapters {
 		images, title, err := c.extractAndProcessChapter(chapterPath, i)
 		if err != nil {
-			return "", fmt.Errorf("failed to process chapter %s: %w", chapterPath, err)
+			return nil, nil, fmt.Errorf("failed to process chapter %s: %w", chapterPath, err)
+		}
+		chapterKey := chapterSortKey(options, i)
+		for j := range images {
+			images[j].ChapterKey = chapterKey
 		}
 		allImages = append(allImages, images...)
 		chapterTitles = append(chapterTitles, title)
+		reportExport(options, ExportProcessing, i+1)
+	}
+	return allImages, chapterTitles, nil
+}
+
+// processCached returns the processed version of a page, reusing the cached
+// output of a previous run when available
+func (c *KindleConverter) processCached(imageData []byte) (processed []byte, cachePath string, err error) {
+	hash := sha256.New()
+	hash.Write(c.cacheKey())
+	hash.Write(imageData)
+	cachePath = filepath.Join(c.tempDir, hex.EncodeToString(hash.Sum(nil))+"."+c.settings.Format)
+
+	if cached, err := os.ReadFile(cachePath); err == nil {
+		c.mu.Lock()
+		c.cachedPages = append(c.cachedPages, cachePath)
+		c.resumed++
+		c.mu.Unlock()
+		return cached, cachePath, nil
 	}
 
-	// Generate Kindle-optimized EPUB
-	epubPath, err := c.generateOptimizedEPUB(allImages, chapterTitles, options)
+	processed, err = c.processor.ProcessImageData(imageData)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate EPUB: %w", err)
+		return nil, "", err
 	}
 
-	// Convert to requested format if not EPUB
-	if options.Format != "epub" && options.Format != "" {
-		convertedPath, err := c.convertFormat(epubPath, options)
-		if err != nil {
-			return "", fmt.Errorf("failed to convert format: %w", err)
-		}
-		return convertedPath, nil
+	// Write then rename so an interrupted write never leaves a truncated page
+	tempPath := cachePath + ".tmp"
+	if err := os.WriteFile(tempPath, processed, 0644); err != nil {
+		return processed, "", nil
 	}
+	if err := os.Rename(tempPath, cachePath); err != nil {
+		os.Remove(tempPath)
+		return processed, "", nil
+	}
+	c.mu.Lock()
+	c.cachedPages = append(c.cachedPages, cachePath)
+	c.mu.Unlock()
+	return processed, cachePath, nil
+}
 
-	return epubPath, nil
+// cacheKey identifies the settings pages are processed with, the same from
+// one run to the next so an interrupted export finds its pages again
+func (c *KindleConverter) cacheKey() []byte {
+	settings, _ := json.Marshal(c.settings)
+	return fmt.Appendf(settings, "\n%s\n", c.filterKey)
+}
+
+// clearCachedPages removes the cached pages of a finished conversion
+func (c *KindleConverter) clearCachedPages() {
+	for _, path := range c.cachedPages {
+		os.Remove(path)
+	}
+	c.cachedPages = nil
 }
 
 // ProcessedImage represents a processed manga page
 type ProcessedImage struct {
-	Data         []byte
+	Data         []byte // The page, nil when it is read from Path
+	Path         string // Cached page, so books are written without holding every page
 	ChapterIndex int
+	ChapterKey   string // Orders the chapter in the book, see PageOrder
 	PageIndex    int
 	Filename     string
 }
 
+// bytes returns the page, read from its cached file when not in memory
+func (img ProcessedImage) bytes() ([]byte, error) {
+	if img.Data != nil || img.Path == "" {
+		return img.Data, nil
+	}
+	return os.ReadFile(img.Path)
+}
+
+// chapterSortKey returns the sort key of the i-th chapter of an export, from
+// its number when known and its position otherwise, see ChapterKey
+func chapterSortKey(options ExportOptions, i int) string {
+	if i < len(options.ChapterNumbers) && options.ChapterNumbers[i] != "" {
+		return ChapterKey(options.ChapterNumbers[i], i)
+	}
+	return ChapterKey(strconv.Itoa(i+1), i)
+}
+
+// pageJob is a page of a chapter going through processPages
+type pageJob struct {
+	name      string
+	data      []byte
+	processed []byte
+	path      string // Cached processed page, if it could be written
+	err       error
+}
+
+// processPages processes pages on a pool of one worker per CPU, filling in
+// their processed data or error
+func (c *KindleConverter) processPages(pages []pageJob) {
+	workers := runtime.NumCPU()
+	if workers > len(pages) {
+		workers = len(pages)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				pages[i].processed, pages[i].path, pages[i].err = c.processCached(pages[i].data)
+			}
+		}()
+	}
+	for i := range pages {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
 // extractAndProcessChapter extracts images from an EPUB and processes them
 func (c *KindleConverter) extractAndProcessChapter(epubPath string, chapterIndex int) ([]ProcessedImage, string, error) {
 	// Open EPUB as ZIP
@@ -106,6 +330,7 @@ func (c *KindleConverter) extractAndProcessChapter(epubPath string, chapterIndex
 
 	images := make([]ProcessedImage, 0)
 	chapterTitle := fmt.Sprintf("Chapter %d", chapterIndex+1)
+	var pages []pageJob
 
 	// Find all images in the EPUB
 	for _, file := range reader.File {
@@ -121,36 +346,58 @@ func (c *KindleConverter) extractAndProcessChapter(epubPath string, chapterIndex
 			continue
 		}
 
+		// Pages are cached in the temp dir; entries must not name a place
+		// outside of it (zip-slip)
+		if _, err := utils.SafeJoin(c.tempDir, file.Name); err != nil || filepath.IsAbs(file.Name) {
+			c.report.skipPage(epubPath, file.Name, fmt.Errorf("unsafe entry name: %w", utils.ErrPathTraversal))
+			continue
+		}
+
 		// Extract image
 		rc, err := file.Open()
 		if err != nil {
+			c.report.skipPage(epubPath, file.Name, err)
 			continue
 		}
 
 		imageData, err := io.ReadAll(rc)
 		rc.Close()
 		if err != nil {
+			c.report.skipPage(epubPath, file.Name, err)
 			continue
 		}
 
-		// Process image for Kindle
-		processed, err := c.processor.ProcessImageData(imageData)
-		if err != nil {
-			// Log error but continue with other images
+		pages = append(pages, pageJob{name: file.Name, data: imageData})
+	}
+
+	// Process images for Kindle, or reuse them from an interrupted run
+	c.processPages(pages)
+
+	for _, page := range pages {
+		if page.err != nil {
+			// Record the error but continue with other images
+			c.report.skipPage(epubPath, page.name, page.err)
 			continue
 		}
+		c.report.addPage(epubPath, page.name, page.data, page.processed)
 
+		// Cached pages are read again when the book is written
+		processed := page.processed
+		if page.path != "" {
+			processed = nil
+		}
 		images = append(images, ProcessedImage{
 			Data:         processed,
+			Path:         page.path,
 			ChapterIndex: chapterIndex,
 			PageIndex:    len(images),
-			Filename:     filepath.Base(file.Name),
+			Filename:     filepath.Base(page.name),
 		})
 	}
 
 	// Sort images by filename to maintain order
 	sort.Slice(images, func(i, j int) bool {
-		return images[i].Filename < images[j].Filename
+		return utils.NaturalLess(images[i].Filename, images[j].Filename)
 	})
 
 	// Update page indices after sorting
@@ -174,22 +421,47 @@ func (c *KindleConverter) generateOptimizedEPUB(images []ProcessedImage, chapter
 
 	// Create a synthetic chapter
 	chapter := &data.Chapter{
-		ID:      "combined",
-		MangaID: "kindle-export",
-		Number:  "1",
-		Title:   "Complete Volume",
+		ID:       "combined",
+		MangaID:  "kindle-export",
+		Number:   "1",
+		Title:    "Complete Volume",
+		Language: options.Language,
 	}
 
 	if err := epubBuilder.Init(manga, chapter); err != nil {
 		return "", err
 	}
 
+	// Metadata read by kindlegen and carried over by ebook-convert
+	if options.Author != "" {
+		epubBuilder.SetAuthor(options.Author)
+	}
+	if options.RightToLeft {
+		epubBuilder.SetRightToLeft()
+	}
+	epubBuilder.SetSeries(options.Series, options.SeriesIndex, options.TitleSort)
+	if options.CoverImage != "" {
+		cover, err := os.ReadFile(options.CoverImage)
+		if err != nil {
+			return "", fmt.Errorf("failed to read cover image: %w", err)
+		}
+		epubBuilder.SetMangaCover(CoverData{
+			Content:     cover,
+			ContentType: http.DetectContentType(cover),
+		})
+	}
+
 	// Add all processed images
 	for _, img := range images {
+		content, err := img.bytes()
+		if err != nil {
+			return "", fmt.Errorf("failed to read page: %w", err)
+		}
 		imageData := ImageData{
-			Content:     img.Data,
+			Content:     content,
 			ContentType: "image/jpeg",
-			Index:       img.ChapterIndex*1000 + img.PageIndex,
+			Index:       img.PageIndex,
+			Chapter:     img.ChapterKey,
 		}
 		if err := epubBuilder.Next(imageData); err != nil {
 			return "", err
@@ -205,19 +477,58 @@ func (c *KindleConverter) generateOptimizedEPUB(images []ProcessedImage, chapter
 	return epubPath, nil
 }
 
-// convertFormat converts EPUB to MOBI or other Kindle formats
-func (c *KindleConverter) convertFormat(epubPath string, options ExportOptions) (string, error) {
+// generatePanelViewEPUB creates a fixed-layout EPUB with a page per
+// document and the panels of each page marked for Kindle Virtual Panels
+func (c *KindleConverter) generatePanelViewEPUB(images []ProcessedImage, options ExportOptions) (string, error) {
+	if len(images) == 0 {
+		return "", fmt.Errorf("no pages found in chapters")
+	}
+	var cover []byte
+	if options.CoverImage != "" {
+		var err error
+		if cover, err = os.ReadFile(options.CoverImage); err != nil {
+			return "", fmt.Errorf("failed to read cover image: %w", err)
+		}
+	}
+
+	book, err := c.newFixedLayoutBook(images, cover, options)
+	if err != nil {
+		return "", err
+	}
+	book.addPanelView(options.RightToLeft)
+	epubPath := FixedLayoutPath(options.OutputPath, FormatEPUB)
+	if err := book.write(epubPath); err != nil {
+		return "", err
+	}
+	return epubPath, nil
+}
+
+// convertFormat converts EPUB to MOBI or other Kindle formats. A Panel View
+// book goes to kindlegen first, ebook-convert drops the region magnification
+// markup of its panels.
+func (c *KindleConverter) convertFormat(epubPath string, options ExportOptions, panelView bool) (string, error) {
 	// Determine output filename
 	ext := string(options.Format)
 	outputPath := strings.TrimSuffix(options.OutputPath, filepath.Ext(options.OutputPath)) + "." + ext
 
+	// Try using kindlegen (Amazon's tool, deprecated but still works)
+	kindlegen := options.Format == FormatMOBI
+	if kindlegen && panelView {
+		if err := c.convertWithKindlegen(epubPath, outputPath); err == nil {
+			return outputPath, nil
+		}
+		kindlegen = false
+	}
+
 	// Try using ebook-convert from Calibre (most common)
 	if err := c.convertWithCalibre(epubPath, outputPath, options); err == nil {
+		if panelView {
+			c.report.warn(epubPath, "", "converted with ebook-convert, which drops Virtual Panels: install kindlegen, export to epub or use --no-panel-view")
+		}
 		return outputPath, nil
 	}
 
-	// Try using kindlegen (Amazon's tool, deprecated but still works)
-	if options.Format == FormatMOBI {
+	if kindlegen {
 		if err := c.convertWithKindlegen(epubPath, outputPath); err == nil {
 			return outputPath, nil
 		}
@@ -229,6 +540,17 @@ func (c *KindleConverter) convertFormat(epubPath string, options ExportOptions)
 
 // convertWithCalibre uses Calibre's ebook-convert tool
 func (c *KindleConverter) convertWithCalibre(input, output string, options ExportOptions) error {
+	cmd := exec.Command("ebook-convert", calibreArgs(input, output, options)...)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ebook-convert failed: %w", err)
+	}
+
+	return nil
+}
+
+// calibreArgs builds the ebook-convert arguments, embedding the cover and the
+// series metadata Kindle uses to group sideloaded books
+func calibreArgs(input, output string, options ExportOptions) []string {
 	args := []string{
 		input,
 		output,
@@ -243,18 +565,29 @@ func (c *KindleConverter) convertWithCalibre(input, output string, options Expor
 	if options.Author != "" {
 		args = append(args, "--authors", options.Author)
 	}
+	titleSort := options.TitleSort
+	if titleSort == "" {
+		titleSort = options.Title
+	}
+	if titleSort != "" {
+		args = append(args, "--title-sort", titleSort)
+	}
+	if options.Series != "" {
+		args = append(args, "--series", options.Series)
+		if options.SeriesIndex > 0 {
+			args = append(args, "--series-index", strconv.FormatFloat(options.SeriesIndex, 'f', -1, 64))
+		}
+	}
+	if options.CoverImage != "" {
+		args = append(args, "--cover", options.CoverImage)
+	}
 
 	// Right-to-left for manga
 	if options.RightToLeft {
 		args = append(args, "--page-progression-direction", "rtl")
 	}
 
-	cmd := exec.Command("ebook-convert", args...)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("ebook-convert failed: %w", err)
-	}
-
-	return nil
+	return args
 }
 
 // convertWithKindlegen uses Amazon's kindlegen tool
@@ -277,12 +610,17 @@ func (c *KindleConverter) convertWithKindlegen(input, output string) error {
 	return nil
 }
 
-// Close cleans up temporary files
+// Close cleans up temporary files. Cached pages of an unfinished conversion
+// are kept so the next run can resume, otherwise the cache directory is
+// removed with whatever pages older runs left behind.
 func (c *KindleConverter) Close() error {
-	if c.tempDir != "" {
-		return os.RemoveAll(c.tempDir)
+	c.mu.Lock()
+	unfinished := len(c.cachedPages) > 0
+	c.mu.Unlock()
+	if c.tempDir == "" || unfinished {
+		return nil
 	}
-	return nil
+	return os.RemoveAll(c.tempDir)
 }
 
 // Kindle-optimized HTML template for manga
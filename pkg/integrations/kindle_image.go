@@ -4,10 +4,10 @@ import (
 	"bytes"
 	"fmt"
 	"image"
-	"image/color"
 	"image/jpeg"
 	"image/png"
 	"io"
+	"math"
 
 	"golang.org/x/image/draw"
 )
@@ -15,6 +15,7 @@ import (
 // ImageProcessor handles image optimization for Kindle devices
 type ImageProcessor struct {
 	settings ImageOptimizationSettings
+	filters  []ImageFilter // Created from settings.Filters
 }
 
 // NewImageProcessor creates a new image processor with the given settings
@@ -24,14 +25,18 @@ func NewImageProcessor(settings ImageOptimizationSettings) *ImageProcessor {
 	}
 }
 
-// ProcessImage optimizes an image for Kindle display
+// ProcessImage optimizes an image for Kindle display. Pixels are worked on
+// in place in the Pix slice of a single Gray or RGBA buffer.
 func (p *ImageProcessor) ProcessImage(input io.Reader) ([]byte, error) {
 	// Decode image
-	img, format, err := image.Decode(input)
+	img, _, err := image.Decode(input)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode image: %w", err)
 	}
 
+	// Margins and levels are found on the full-size scan
+	img = Preprocess(img, p.settings.Preprocess)
+
 	// Get original dimensions
 	bounds := img.Bounds()
 	origWidth := bounds.Dx()
@@ -46,19 +51,23 @@ func (p *ImageProcessor) ProcessImage(input io.Reader) ([]byte, error) {
 		processed = p.resize(img, newWidth, newHeight)
 	}
 
-	// Convert to grayscale if needed
-	if p.settings.Grayscale && format != "gray" {
-		processed = p.toGrayscale(processed)
+	// Plugin filters get the resized page in color
+	for _, filter := range p.filters {
+		if processed, err = filter.Filter(toRGBA(processed)); err != nil {
+			return nil, err
+		}
 	}
 
-	// Apply contrast adjustment if needed
-	if p.settings.Contrast != 1.0 {
-		processed = p.adjustContrast(processed, p.settings.Contrast)
+	// Convert to grayscale if needed, e-ink pages get a single channel
+	if p.settings.Grayscale {
+		processed = toGray(processed)
+	} else {
+		processed = toRGBA(processed)
 	}
 
-	// Apply gamma correction if needed
-	if p.settings.Gamma != 1.0 {
-		processed = p.adjustGamma(processed, p.settings.Gamma)
+	// Contrast and gamma are one lookup per channel
+	if curve := toneCurve(p.settings.Contrast, p.settings.Gamma); curve != nil {
+		applyCurve(processed, curve)
 	}
 
 	// Apply sharpening for e-ink if enabled
@@ -95,182 +104,174 @@ func (p *ImageProcessor) calculateDimensions(width, height int) (int, int) {
 // resize resizes an image using high-quality interpolation
 func (p *ImageProcessor) resize(img image.Image, width, height int) image.Image {
 	dst := image.NewRGBA(image.Rect(0, 0, width, height))
-	
+
 	// Use CatmullRom for high-quality downscaling
-	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Over, nil)
-	
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+
 	return dst
 }
 
 // toGrayscale converts an image to grayscale
 func (p *ImageProcessor) toGrayscale(img image.Image) image.Image {
-	bounds := img.Bounds()
-	gray := image.NewGray(bounds)
+	return toGray(img)
+}
 
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			gray.Set(x, y, img.At(x, y))
+// toGray returns img as a Gray image, converting it when needed. The luma
+// of JPEG pages is copied from their Y plane.
+func toGray(img image.Image) *image.Gray {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	switch src := img.(type) {
+	case *image.Gray:
+		return src
+	case *image.YCbCr:
+		gray := image.NewGray(bounds)
+		for y := 0; y < height; y++ {
+			offset := src.YOffset(bounds.Min.X, bounds.Min.Y+y)
+			copy(gray.Pix[y*gray.Stride:y*gray.Stride+width], src.Y[offset:offset+width])
 		}
+		return gray
 	}
 
+	rgba := toRGBA(img)
+	gray := image.NewGray(bounds)
+	for y := 0; y < height; y++ {
+		in := rgba.Pix[y*rgba.Stride : y*rgba.Stride+4*width]
+		out := gray.Pix[y*gray.Stride : y*gray.Stride+width]
+		for x := range out {
+			r, g, b := uint32(in[4*x]), uint32(in[4*x+1]), uint32(in[4*x+2])
+			// Same weights as color.GrayModel, on 8-bit channels
+			out[x] = uint8((19595*r + 38470*g + 7471*b + 1<<15) >> 16)
+		}
+	}
 	return gray
 }
 
-// adjustContrast adjusts the contrast of an image
-func (p *ImageProcessor) adjustContrast(img image.Image, factor float64) image.Image {
-	bounds := img.Bounds()
-	adjusted := image.NewRGBA(bounds)
-
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			r, g, b, a := img.At(x, y).RGBA()
-			
-			// Convert to 0-255 range
-			r8 := uint8(r >> 8)
-			g8 := uint8(g >> 8)
-			b8 := uint8(b >> 8)
-			a8 := uint8(a >> 8)
-
-			// Apply contrast adjustment
-			r8 = p.adjustChannel(r8, factor)
-			g8 = p.adjustChannel(g8, factor)
-			b8 = p.adjustChannel(b8, factor)
-
-			adjusted.SetRGBA(x, y, color.RGBA{r8, g8, b8, a8})
-		}
+// toRGBA returns img as an RGBA image, converting it when needed
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
 	}
-
-	return adjusted
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(bounds)
+	draw.Draw(rgba, bounds, img, bounds.Min, draw.Src)
+	return rgba
 }
 
-// adjustChannel adjusts a single color channel
-func (p *ImageProcessor) adjustChannel(value uint8, factor float64) uint8 {
-	// Center around 128 (middle gray)
-	adjusted := float64(value-128)*factor + 128
-	
-	if adjusted < 0 {
-		return 0
+// toneCurve returns the lookup table applying a contrast factor, then a
+// gamma correction, to a channel. It is nil when both are neutral; 0 counts
+// as unset.
+func toneCurve(contrast, gamma float64) *[256]uint8 {
+	useContrast := contrast != 0 && contrast != 1
+	useGamma := gamma > 0 && gamma != 1
+	if !useContrast && !useGamma {
+		return nil
 	}
-	if adjusted > 255 {
-		return 255
-	}
-	
-	return uint8(adjusted)
-}
 
-// adjustGamma applies gamma correction to an image
-func (p *ImageProcessor) adjustGamma(img image.Image, gamma float64) image.Image {
-	bounds := img.Bounds()
-	adjusted := image.NewRGBA(bounds)
-
-	// Build gamma lookup table
-	gammaTable := make([]uint8, 256)
-	for i := 0; i < 256; i++ {
-		normalized := float64(i) / 255.0
-		corrected := 255.0 * pow(normalized, 1.0/gamma)
-		if corrected > 255 {
-			corrected = 255
+	var curve [256]uint8
+	for i := range curve {
+		value := float64(i)
+		if useContrast {
+			// Center around 128 (middle gray)
+			value = math.Max(0, math.Min(255, (value-128)*contrast+128))
+		}
+		if useGamma {
+			value = 255 * math.Pow(value/255, 1/gamma)
 		}
-		gammaTable[i] = uint8(corrected)
+		curve[i] = uint8(math.Round(math.Max(0, math.Min(255, value))))
 	}
+	return &curve
+}
 
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			r, g, b, a := img.At(x, y).RGBA()
-			
-			r8 := gammaTable[uint8(r>>8)]
-			g8 := gammaTable[uint8(g>>8)]
-			b8 := gammaTable[uint8(b>>8)]
-			a8 := uint8(a >> 8)
-
-			adjusted.SetRGBA(x, y, color.RGBA{r8, g8, b8, a8})
+// applyCurve maps the color channels of a Gray or RGBA image through curve,
+// in place. Alpha is left alone.
+func applyCurve(img image.Image, curve *[256]uint8) {
+	switch img := img.(type) {
+	case *image.Gray:
+		for i, v := range img.Pix {
+			img.Pix[i] = curve[v]
+		}
+	case *image.RGBA:
+		pix := img.Pix
+		for i := 0; i+3 < len(pix); i += 4 {
+			pix[i] = curve[pix[i]]
+			pix[i+1] = curve[pix[i+1]]
+			pix[i+2] = curve[pix[i+2]]
 		}
 	}
+}
 
-	return adjusted
+// adjustContrast adjusts the contrast of an image
+func (p *ImageProcessor) adjustContrast(img image.Image, factor float64) image.Image {
+	return adjustCopy(img, toneCurve(factor, 1))
 }
 
-// pow is a simple power function for gamma calculation
-func pow(x, y float64) float64 {
-	if y == 1.0 {
-		return x
-	}
-	if y == 2.0 {
-		return x * x
-	}
-	// For other values, use a simple approximation
-	// This is sufficient for gamma correction
-	result := 1.0
-	absY := y
-	if y < 0 {
-		absY = -y
-	}
-	
-	for i := 0; i < int(absY*10); i++ {
-		result *= x
+// adjustGamma applies gamma correction to an image
+func (p *ImageProcessor) adjustGamma(img image.Image, gamma float64) image.Image {
+	return adjustCopy(img, toneCurve(1, gamma))
+}
+
+// adjustCopy returns a copy of img, as RGBA unless it is Gray, with curve
+// applied
+func adjustCopy(img image.Image, curve *[256]uint8) image.Image {
+	var adjusted image.Image
+	if gray, ok := img.(*image.Gray); ok {
+		adjusted = &image.Gray{Pix: append([]uint8(nil), gray.Pix...), Stride: gray.Stride, Rect: gray.Rect}
+	} else {
+		bounds := img.Bounds()
+		rgba := image.NewRGBA(bounds)
+		draw.Draw(rgba, bounds, img, bounds.Min, draw.Src)
+		adjusted = rgba
 	}
-	
-	if y < 0 {
-		return 1.0 / result
+	if curve != nil {
+		applyCurve(adjusted, curve)
 	}
-	return result
+	return adjusted
 }
 
-// sharpen applies a simple sharpening filter for e-ink displays
+// sharpen applies a simple sharpening filter for e-ink displays. Gray
+// images stay Gray, others come back as RGBA.
 func (p *ImageProcessor) sharpen(img image.Image) image.Image {
-	bounds := img.Bounds()
-	sharpened := image.NewRGBA(bounds)
-
 	// Simple 3x3 sharpening kernel
 	// [ -1 -1 -1 ]
 	// [ -1  9 -1 ]
 	// [ -1 -1 -1 ]
-	
-	for y := bounds.Min.Y + 1; y < bounds.Max.Y-1; y++ {
-		for x := bounds.Min.X + 1; x < bounds.Max.X-1; x++ {
-			// Get surrounding pixels
-			var rSum, gSum, bSum int32
-			
-			// Center pixel (weight: 9)
-			r, g, b, a := img.At(x, y).RGBA()
-			rSum += int32(r>>8) * 9
-			gSum += int32(g>>8) * 9
-			bSum += int32(b>>8) * 9
-
-			// Surrounding pixels (weight: -1 each)
-			for dy := -1; dy <= 1; dy++ {
-				for dx := -1; dx <= 1; dx++ {
-					if dx == 0 && dy == 0 {
-						continue
-					}
-					r, g, b, _ := img.At(x+dx, y+dy).RGBA()
-					rSum -= int32(r >> 8)
-					gSum -= int32(g >> 8)
-					bSum -= int32(b >> 8)
-				}
-			}
-
-			// Clamp values
-			r8 := clamp(rSum)
-			g8 := clamp(gSum)
-			b8 := clamp(bSum)
-			a8 := uint8(a >> 8)
-
-			sharpened.SetRGBA(x, y, color.RGBA{r8, g8, b8, a8})
+	if gray, ok := img.(*image.Gray); ok {
+		return &image.Gray{
+			Pix:    sharpenPix(gray.Pix, gray.Stride, gray.Rect.Dx(), gray.Rect.Dy(), 1, 1),
+			Stride: gray.Stride,
+			Rect:   gray.Rect,
 		}
 	}
-
-	// Copy edges as-is
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		sharpened.Set(bounds.Min.X, y, img.At(bounds.Min.X, y))
-		sharpened.Set(bounds.Max.X-1, y, img.At(bounds.Max.X-1, y))
-	}
-	for x := bounds.Min.X; x < bounds.Max.X; x++ {
-		sharpened.Set(x, bounds.Min.Y, img.At(x, bounds.Min.Y))
-		sharpened.Set(x, bounds.Max.Y-1, img.At(x, bounds.Max.Y-1))
+	rgba := toRGBA(img)
+	return &image.RGBA{
+		Pix:    sharpenPix(rgba.Pix, rgba.Stride, rgba.Rect.Dx(), rgba.Rect.Dy(), 4, 3),
+		Stride: rgba.Stride,
+		Rect:   rgba.Rect,
 	}
+}
 
-	return sharpened
+// sharpenPix runs the sharpening kernel over the first channels of every
+// pixel of bpp bytes. Edge pixels and the other channels are copied as-is.
+func sharpenPix(pix []uint8, stride, width, height, bpp, channels int) []uint8 {
+	out := make([]uint8, len(pix))
+	copy(out, pix)
+	for y := 1; y < height-1; y++ {
+		row := y * stride
+		for x := 1; x < width-1; x++ {
+			for c := 0; c < channels; c++ {
+				i := row + x*bpp + c
+				above, below := i-stride, i+stride
+				sum := 9*int32(pix[i]) -
+					int32(pix[above-bpp]) - int32(pix[above]) - int32(pix[above+bpp]) -
+					int32(pix[i-bpp]) - int32(pix[i+bpp]) -
+					int32(pix[below-bpp]) - int32(pix[below]) - int32(pix[below+bpp])
+				out[i] = clamp(sum)
+			}
+		}
+	}
+	return out
 }
 
 // clamp restricts a value to the 0-255 range
@@ -0,0 +1,151 @@
+package integrations
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ConversionReport summarizes a Kindle conversion for troubleshooting
+type ConversionReport struct {
+	Device           string         `json:"device"`
+	Format           string         `json:"format"`
+	Output           string         `json:"output"`
+	CreatedAt        time.Time      `json:"created_at"`
+	Duration         string         `json:"duration"`
+	Chapters         int            `json:"chapters"`
+	PagesProcessed   int            `json:"pages_processed"`
+	PagesResumed     int            `json:"pages_resumed"` // Reused from an interrupted run
+	PagesSkipped     int            `json:"pages_skipped"`
+	OriginalBytes    int64          `json:"original_bytes"`
+	OptimizedBytes   int64          `json:"optimized_bytes"`
+	AverageReduction float64        `json:"average_reduction_percent"` // Mean per-page size reduction
+	Dimensions       map[string]int `json:"dimensions"`                // Final page sizes ("WxH") and their counts
+	Warnings         []PageWarning  `json:"warnings,omitempty"`
+	Error            string         `json:"error,omitempty"`
+
+	reductions float64
+	started    time.Time
+}
+
+// PageWarning explains why a page was skipped or needs attention
+type PageWarning struct {
+	Chapter string `json:"chapter"`
+	Page    string `json:"page"`
+	Reason  string `json:"reason"`
+}
+
+func newConversionReport(device KindleDevice, chapters int) *ConversionReport {
+	return &ConversionReport{
+		Device:     device.Name,
+		CreatedAt:  time.Now(),
+		Chapters:   chapters,
+		Dimensions: make(map[string]int),
+		started:    time.Now(),
+	}
+}
+
+// addPage records a processed page
+func (r *ConversionReport) addPage(chapter, page string, original, optimized []byte) {
+	r.PagesProcessed++
+	if len(optimized) > len(original) {
+		r.warn(chapter, page, fmt.Sprintf("optimized page is larger than the original (%d > %d bytes)", len(optimized), len(original)))
+	}
+	r.OriginalBytes += int64(len(original))
+	r.OptimizedBytes += int64(len(optimized))
+	if len(original) > 0 {
+		r.reductions += 100 * (1 - float64(len(optimized))/float64(len(original)))
+		r.AverageReduction = r.reductions / float64(r.PagesProcessed)
+	}
+
+	if config, _, err := image.DecodeConfig(bytes.NewReader(optimized)); err == nil {
+		r.Dimensions[fmt.Sprintf("%dx%d", config.Width, config.Height)]++
+	}
+}
+
+// skipPage records a page left out of the conversion
+func (r *ConversionReport) skipPage(chapter, page string, err error) {
+	r.PagesSkipped++
+	r.warn(chapter, page, err.Error())
+}
+
+func (r *ConversionReport) warn(chapter, page, reason string) {
+	r.Warnings = append(r.Warnings, PageWarning{
+		Chapter: filepath.Base(chapter),
+		Page:    page,
+		Reason:  reason,
+	})
+}
+
+// finish records the outcome of the conversion
+func (r *ConversionReport) finish(format, output string, err error) {
+	r.Format = format
+	r.Output = output
+	r.Duration = time.Since(r.started).Round(time.Millisecond).String()
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+// Summary returns a human readable multi-line summary
+func (r *ConversionReport) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Pages: %d processed", r.PagesProcessed)
+	if r.PagesResumed > 0 {
+		fmt.Fprintf(&b, " (%d resumed)", r.PagesResumed)
+	}
+	fmt.Fprintf(&b, ", %d skipped\n", r.PagesSkipped)
+	fmt.Fprintf(&b, "Size: %.1f MB -> %.1f MB (average reduction %.0f%%)\n",
+		float64(r.OriginalBytes)/(1<<20), float64(r.OptimizedBytes)/(1<<20), r.AverageReduction)
+
+	dimensions := make([]string, 0, len(r.Dimensions))
+	for size := range r.Dimensions {
+		dimensions = append(dimensions, size)
+	}
+	sort.Slice(dimensions, func(i, j int) bool {
+		if r.Dimensions[dimensions[i]] != r.Dimensions[dimensions[j]] {
+			return r.Dimensions[dimensions[i]] > r.Dimensions[dimensions[j]]
+		}
+		return dimensions[i] < dimensions[j]
+	})
+	for i, size := range dimensions {
+		dimensions[i] = fmt.Sprintf("%s (%d)", size, r.Dimensions[size])
+	}
+	if len(dimensions) > 0 {
+		fmt.Fprintf(&b, "Dimensions: %s\n", strings.Join(dimensions, ", "))
+	}
+
+	for _, warning := range r.Warnings {
+		if warning.Page == "" {
+			fmt.Fprintf(&b, "Warning: %s: %s\n", warning.Chapter, warning.Reason)
+			continue
+		}
+		fmt.Fprintf(&b, "Warning: %s %s: %s\n", warning.Chapter, warning.Page, warning.Reason)
+	}
+	return b.String()
+}
+
+// ReportPath returns where the report of a conversion to outputPath is stored
+func ReportPath(outputPath string) string {
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".report.json"
+}
+
+// Write saves the report as indented JSON next to the converted file
+func (r *ConversionReport) Write() (string, error) {
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode report: %w", err)
+	}
+
+	path := ReportPath(r.Output)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return "", fmt.Errorf("failed to write report: %w", err)
+	}
+	return path, nil
+}
@@ -1,11 +1,19 @@
 package integrations
 
 import (
+	"archive/zip"
 	"bytes"
 	"image"
 	"image/color"
 	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
 )
 
 func TestGetDeviceProfile(t *testing.T) {
@@ -217,6 +225,82 @@ func TestImageProcessor_Clamp(t *testing.T) {
 	}
 }
 
+func TestToneCurve(t *testing.T) {
+	if toneCurve(0, 0) != nil || toneCurve(1, 1) != nil {
+		t.Error("Neutral settings should not build a curve")
+	}
+
+	// Fractional gammas follow math.Pow
+	curve := toneCurve(1, 2.2)
+	for _, v := range []int{0, 32, 64, 128, 200, 255} {
+		want := uint8(math.Round(255 * math.Pow(float64(v)/255, 1/2.2)))
+		if curve[v] != want {
+			t.Errorf("gamma 2.2 of %d = %d, want %d", v, curve[v], want)
+		}
+	}
+
+	// Contrast pushes values away from middle gray without wrapping around
+	curve = toneCurve(1.5, 1)
+	if curve[128] != 128 || curve[0] != 0 || curve[10] != 0 || curve[250] != 255 {
+		t.Errorf("Unexpected contrast curve: 0→%d 10→%d 128→%d 250→%d", curve[0], curve[10], curve[128], curve[250])
+	}
+	if curve[100] >= 100 || curve[160] <= 160 {
+		t.Errorf("Contrast should darken 100 and lighten 160, got %d and %d", curve[100], curve[160])
+	}
+}
+
+func TestToGray(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7)
+	}
+	gray := toGray(img)
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			// Same luma as color.GrayModel, give or take rounding
+			want := color.GrayModel.Convert(img.At(x, y)).(color.Gray)
+			if got := gray.GrayAt(x, y); int(got.Y)-int(want.Y) > 1 || int(want.Y)-int(got.Y) > 1 {
+				t.Errorf("Pixel (%d, %d) = %d, want %d", x, y, got.Y, want.Y)
+			}
+		}
+	}
+
+	// JPEG pages take their Y plane as is
+	ycbcr := image.NewYCbCr(image.Rect(0, 0, 3, 2), image.YCbCrSubsampleRatio420)
+	for i := range ycbcr.Y {
+		ycbcr.Y[i] = uint8(40 * i)
+	}
+	gray = toGray(ycbcr)
+	if gray.GrayAt(2, 1).Y != ycbcr.YCbCrAt(2, 1).Y {
+		t.Errorf("Expected the luma of the YCbCr image, got %d", gray.GrayAt(2, 1).Y)
+	}
+}
+
+func TestImageProcessor_Sharpen(t *testing.T) {
+	processor := &ImageProcessor{}
+
+	// A flat image has no edges to enhance
+	flat := image.NewGray(image.Rect(0, 0, 5, 5))
+	for i := range flat.Pix {
+		flat.Pix[i] = 90
+	}
+	sharpened := processor.sharpen(flat).(*image.Gray)
+	if !bytes.Equal(sharpened.Pix, flat.Pix) {
+		t.Error("Sharpening a flat image should leave it unchanged")
+	}
+
+	// A dot stands out more, its alpha untouched
+	dot := image.NewRGBA(image.Rect(0, 0, 3, 3))
+	for i := range dot.Pix {
+		dot.Pix[i] = 100
+	}
+	dot.SetRGBA(1, 1, color.RGBA{120, 120, 120, 100})
+	got := processor.sharpen(dot).(*image.RGBA).RGBAAt(1, 1)
+	if got != (color.RGBA{255, 255, 255, 100}) {
+		t.Errorf("Expected the dot brightened, got %+v", got)
+	}
+}
+
 func TestKindleConverter_New(t *testing.T) {
 	t.Run("valid device", func(t *testing.T) {
 		converter, err := NewKindleConverter("kindle-paperwhite3")
@@ -322,3 +406,363 @@ func BenchmarkImageProcessor_ProcessImage(b *testing.B) {
 		processor.ProcessImageData(imageData)
 	}
 }
+
+// benchmarkPage is a colored page decoded once for the benchmarks
+func benchmarkPage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 1072, 1448))
+	for y := 0; y < 1448; y++ {
+		for x := 0; x < 1072; x++ {
+			img.SetRGBA(x, y, color.RGBA{uint8(x % 256), uint8(y % 256), 128, 255})
+		}
+	}
+	return img
+}
+
+func BenchmarkToGray(b *testing.B) {
+	img := benchmarkPage()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		toGray(img)
+	}
+}
+
+func BenchmarkApplyCurve(b *testing.B) {
+	gray := toGray(benchmarkPage())
+	curve := toneCurve(1.2, 2.2)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		applyCurve(gray, curve)
+	}
+}
+
+func BenchmarkImageProcessor_Sharpen(b *testing.B) {
+	gray := toGray(benchmarkPage())
+	processor := &ImageProcessor{}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		processor.sharpen(gray)
+	}
+}
+
+func BenchmarkKindleConverter_ProcessChapter(b *testing.B) {
+	builder := NewEPubBuilder(b.TempDir())
+	if err := builder.Init(&data.Manga{ID: "m", Name: "Bench"}, &data.Chapter{ID: "c", Number: "1"}); err != nil {
+		b.Fatalf("Init() failed: %v", err)
+	}
+	page := benchmarkPage()
+	for i := 0; i < 8; i++ {
+		page.SetRGBA(i, i, color.RGBA{A: 255}) // Distinct pages, nothing shared in the cache
+		var buf bytes.Buffer
+		png.Encode(&buf, page)
+		builder.Next(ImageData{Content: buf.Bytes(), ContentType: "image/png", Index: i})
+	}
+	epubPath, err := builder.Done()
+	if err != nil {
+		b.Fatalf("Done() failed: %v", err)
+	}
+
+	converter, err := NewKindleConverter("kindle-paperwhite3")
+	if err != nil {
+		b.Fatalf("NewKindleConverter() error = %v", err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		converter.tempDir = b.TempDir() // Don't resume from the previous iteration
+		if _, _, err := converter.extractAndProcessChapter(epubPath, 0); err != nil {
+			b.Fatalf("extractAndProcessChapter() error = %v", err)
+		}
+	}
+}
+
+func TestKindleConverter_Resume(t *testing.T) {
+	// Build a chapter EPUB with a few pages
+	builder := NewEPubBuilder(t.TempDir())
+	if err := builder.Init(&data.Manga{ID: "m", Name: "Resume"}, &data.Chapter{ID: "c", Number: "1"}); err != nil {
+		t.Fatalf("Init() failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		img := image.NewRGBA(image.Rect(0, 0, 40, 60))
+		img.Set(i, i, color.RGBA{R: 255, A: 255}) // Make every page distinct
+		var buf bytes.Buffer
+		png.Encode(&buf, img)
+		builder.Next(ImageData{Content: buf.Bytes(), ContentType: "image/png", Index: i})
+	}
+	epubPath, err := builder.Done()
+	if err != nil {
+		t.Fatalf("Done() failed: %v", err)
+	}
+
+	converter, err := NewKindleConverter("kindle-paperwhite3")
+	if err != nil {
+		t.Fatalf("NewKindleConverter() error = %v", err)
+	}
+	converter.tempDir = t.TempDir()
+
+	// First run is interrupted after processing the chapter
+	if _, _, err := converter.extractAndProcessChapter(epubPath, 0); err != nil {
+		t.Fatalf("extractAndProcessChapter() error = %v", err)
+	}
+	cached, _ := filepath.Glob(filepath.Join(converter.tempDir, "*.jpeg"))
+	if len(cached) != 3 {
+		t.Fatalf("Expected 3 cached pages, got %d", len(cached))
+	}
+
+	// Next run reuses the processed pages and clears them once done
+	outputPath := filepath.Join(t.TempDir(), "out.epub")
+	_, err = converter.ConvertChapters(ExportOptions{
+		Title:      "Resume",
+		Chapters:   []string{epubPath},
+		OutputPath: outputPath,
+		Format:     "epub",
+	})
+	if err != nil {
+		t.Fatalf("ConvertChapters() error = %v", err)
+	}
+	if converter.ResumedPages() != 3 {
+		t.Errorf("Expected 3 resumed pages, got %d", converter.ResumedPages())
+	}
+	cached, _ = filepath.Glob(filepath.Join(converter.tempDir, "*"))
+	if len(cached) != 0 {
+		t.Errorf("Expected cache to be cleared after export, found %v", cached)
+	}
+
+	converter.Close()
+	if _, err := os.Stat(converter.tempDir); !os.IsNotExist(err) {
+		t.Error("Expected empty cache directory to be removed on Close")
+	}
+}
+
+func TestKindleConverter_CacheKey(t *testing.T) {
+	first, err := NewKindleConverter("kindle-paperwhite3")
+	if err != nil {
+		t.Fatalf("NewKindleConverter() error = %v", err)
+	}
+	defer first.Close()
+	second, _ := NewKindleConverter("kindle-paperwhite3")
+	defer second.Close()
+
+	if !bytes.Equal(first.cacheKey(), second.cacheKey()) {
+		t.Errorf("Expected the same key for the same settings, got %s and %s", first.cacheKey(), second.cacheKey())
+	}
+	second.settings.Quality--
+	if bytes.Equal(first.cacheKey(), second.cacheKey()) {
+		t.Error("Expected another key once the settings change")
+	}
+}
+
+func TestKindleConverter_SameNumberChapters(t *testing.T) {
+	// Releases of chapter 5 in two languages, a page each
+	chapter := func(shade uint8) string {
+		builder := NewEPubBuilder(t.TempDir())
+		if err := builder.Init(&data.Manga{ID: "m", Name: "Twins"}, &data.Chapter{ID: "c", Number: "5"}); err != nil {
+			t.Fatalf("Init() failed: %v", err)
+		}
+		img := image.NewRGBA(image.Rect(0, 0, 40, 60))
+		img.Set(0, 0, color.RGBA{R: shade, A: 255})
+		var buf bytes.Buffer
+		png.Encode(&buf, img)
+		builder.Next(ImageData{Content: buf.Bytes(), ContentType: "image/png", Index: 0})
+		path, err := builder.Done()
+		if err != nil {
+			t.Fatalf("Done() failed: %v", err)
+		}
+		return path
+	}
+
+	converter, err := NewKindleConverter("kindle-paperwhite3")
+	if err != nil {
+		t.Fatalf("NewKindleConverter() error = %v", err)
+	}
+	converter.tempDir = t.TempDir()
+	defer converter.Close()
+
+	outputPath, err := converter.ConvertChapters(ExportOptions{
+		Title:          "Twins",
+		Chapters:       []string{chapter(10), chapter(200)},
+		ChapterNumbers: []string{"5", "5"},
+		OutputPath:     filepath.Join(t.TempDir(), "twins.epub"),
+		Format:         "epub",
+	})
+	if err != nil {
+		t.Fatalf("ConvertChapters() error = %v", err)
+	}
+	pages, err := ReadEPUBPages(outputPath)
+	if err != nil {
+		t.Fatalf("ReadEPUBPages() error = %v", err)
+	}
+	if len(pages) != 2 {
+		t.Errorf("Expected a page of each chapter 5, got %d pages", len(pages))
+	}
+}
+
+func TestCalibreArgs(t *testing.T) {
+	args := calibreArgs("in.epub", "out.mobi", ExportOptions{
+		Title:       "One Piece",
+		Author:      "Oda",
+		Series:      "One Piece",
+		SeriesIndex: 12.5,
+		CoverImage:  "/tmp/cover.jpg",
+		RightToLeft: true,
+	})
+	joined := strings.Join(args, " ")
+
+	for _, want := range []string{
+		"--title One Piece",
+		"--authors Oda",
+		"--title-sort One Piece", // Defaults to the title
+		"--series One Piece",
+		"--series-index 12.5",
+		"--cover /tmp/cover.jpg",
+		"--page-progression-direction rtl",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("calibreArgs() missing %q in %q", want, joined)
+		}
+	}
+
+	args = calibreArgs("in.epub", "out.mobi", ExportOptions{})
+	if len(args) != 5 {
+		t.Errorf("Expected only base args without metadata, got %v", args)
+	}
+}
+
+func TestKindleConverter_EmbedsCoverAndDirection(t *testing.T) {
+	converter, err := NewKindleConverter("kindle-paperwhite3")
+	if err != nil {
+		t.Fatalf("NewKindleConverter() error = %v", err)
+	}
+	converter.tempDir = t.TempDir()
+	defer converter.Close()
+
+	coverPath := filepath.Join(t.TempDir(), "cover.png")
+	os.WriteFile(coverPath, createTestPNG(), 0644)
+
+	epubPath, err := converter.generateOptimizedEPUB(
+		[]ProcessedImage{{Data: createTestPNG(), Filename: "page_0000.png"}},
+		[]string{"Chapter 1"},
+		ExportOptions{
+			Title:       "Covered",
+			Author:      "Someone",
+			OutputPath:  filepath.Join(t.TempDir(), "out.epub"),
+			CoverImage:  coverPath,
+			RightToLeft: true,
+		},
+	)
+	if err != nil {
+		t.Fatalf("generateOptimizedEPUB() error = %v", err)
+	}
+
+	reader, err := zip.OpenReader(epubPath)
+	if err != nil {
+		t.Fatalf("failed to open EPUB: %v", err)
+	}
+	defer reader.Close()
+
+	var opf string
+	for _, f := range reader.File {
+		if strings.HasSuffix(f.Name, ".opf") {
+			rc, _ := f.Open()
+			content, _ := io.ReadAll(rc)
+			rc.Close()
+			opf = string(content)
+		}
+	}
+	if !strings.Contains(opf, `page-progression-direction="rtl"`) {
+		t.Error("Expected right-to-left page progression in package document")
+	}
+	if !strings.Contains(opf, "Someone") {
+		t.Error("Expected author in package document")
+	}
+	if !strings.Contains(opf, `name="cover"`) {
+		t.Error("Expected cover metadata in package document")
+	}
+}
+
+func TestKindleConverter_Report(t *testing.T) {
+	builder := NewEPubBuilder(t.TempDir())
+	if err := builder.Init(&data.Manga{ID: "m", Name: "Report"}, &data.Chapter{ID: "c", Number: "1"}); err != nil {
+		t.Fatalf("Init() failed: %v", err)
+	}
+	builder.Next(ImageData{Content: createTestPage(t, 60, 80), ContentType: "image/png", Index: 0})
+	builder.Next(ImageData{Content: []byte("corrupted page"), ContentType: "image/png", Index: 1})
+	builder.Next(ImageData{Content: createTestPage(t, 40, 80), ContentType: "image/png", Index: 2})
+	epubPath, err := builder.Done()
+	if err != nil {
+		t.Fatalf("Done() failed: %v", err)
+	}
+
+	converter, err := NewKindleConverter("kindle-paperwhite3")
+	if err != nil {
+		t.Fatalf("NewKindleConverter() error = %v", err)
+	}
+	converter.tempDir = t.TempDir()
+
+	outputPath, err := converter.ConvertChapters(ExportOptions{
+		Title:      "Report",
+		Chapters:   []string{epubPath},
+		OutputPath: filepath.Join(t.TempDir(), "out.epub"),
+	})
+	if err != nil {
+		t.Fatalf("ConvertChapters() error = %v", err)
+	}
+
+	report := converter.Report()
+	if report.PagesProcessed != 2 || report.PagesSkipped != 1 {
+		t.Errorf("Expected 2 processed and 1 skipped page, got %d and %d", report.PagesProcessed, report.PagesSkipped)
+	}
+	if len(report.Warnings) == 0 || !strings.Contains(report.Warnings[0].Page, "page_") {
+		t.Errorf("Expected a warning naming the corrupted page, got %+v", report.Warnings)
+	}
+	if report.Dimensions["60x80"] != 1 || report.Dimensions["40x80"] != 1 {
+		t.Errorf("Expected one page of 60x80 and one of 40x80, got %v", report.Dimensions)
+	}
+	if report.Format != "epub" || report.OriginalBytes == 0 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	if !strings.Contains(report.Summary(), "2 processed, 1 skipped") {
+		t.Errorf("Unexpected summary:\n%s", report.Summary())
+	}
+
+	content, err := os.ReadFile(ReportPath(outputPath))
+	if err != nil {
+		t.Fatalf("Expected report next to the output: %v", err)
+	}
+	if !strings.Contains(string(content), `"pages_skipped": 1`) {
+		t.Errorf("Unexpected report file:\n%s", content)
+	}
+}
+
+func TestKindleConverter_RejectsZipSlip(t *testing.T) {
+	epubPath := filepath.Join(t.TempDir(), "slip.epub")
+	file, err := os.Create(epubPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := zip.NewWriter(file)
+	for _, name := range []string{"OEBPS/images/page_0.png", "../../escaped_page.png", "/abs/page_1.png"} {
+		w, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(createTestPage(t, 40, 60))
+	}
+	writer.Close()
+	file.Close()
+
+	converter, err := NewKindleConverter("kindle-paperwhite3")
+	if err != nil {
+		t.Fatalf("NewKindleConverter() error = %v", err)
+	}
+	converter.tempDir = t.TempDir()
+
+	images, _, err := converter.extractAndProcessChapter(epubPath, 0)
+	if err != nil {
+		t.Fatalf("extractAndProcessChapter() error = %v", err)
+	}
+	if len(images) != 1 {
+		t.Errorf("Expected only the safe page to be processed, got %d", len(images))
+	}
+	if converter.Report().PagesSkipped != 2 {
+		t.Errorf("Expected 2 unsafe entries skipped, got %d", converter.Report().PagesSkipped)
+	}
+}
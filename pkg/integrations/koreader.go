@@ -0,0 +1,67 @@
+package integrations
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// KOReaderFinishedPercent is how far into a book KOReader must be for it to
+// count as read when it wasn't marked as finished
+const KOReaderFinishedPercent = 0.98
+
+// KOReaderProgress is the reading state KOReader keeps for a book in its
+// sidecar folder
+type KOReaderProgress struct {
+	Percent float64 // Part of the book read, from 0 to 1
+	Status  string  // "reading", "complete" or "abandoned", "" when never set
+}
+
+// Finished reports whether the book was read to the end
+func (p *KOReaderProgress) Finished() bool {
+	return p.Status == "complete" || p.Percent >= KOReaderFinishedPercent
+}
+
+// KOReaderSidecar returns the metadata file KOReader keeps next to a book:
+// book.epub has its state in book.sdr/metadata.epub.lua
+func KOReaderSidecar(bookPath string) string {
+	ext := filepath.Ext(bookPath)
+	return filepath.Join(strings.TrimSuffix(bookPath, ext)+".sdr",
+		"metadata"+strings.ToLower(ext)+".lua")
+}
+
+// The metadata file is a Lua table written by KOReader, only the fields
+// telling how far the book was read are needed
+var (
+	koreaderPercent = regexp.MustCompile(`\["percent_finished"\]\s*=\s*([0-9.eE+-]+)`)
+	koreaderStatus  = regexp.MustCompile(`\["summary"\]\s*=\s*\{[^}]*\["status"\]\s*=\s*"([a-z_]+)"`)
+)
+
+// ReadKOReaderProgress reads the reading state of a book from its KOReader
+// sidecar, nil when the book was never opened in KOReader
+func ReadKOReaderProgress(bookPath string) (*KOReaderProgress, error) {
+	content, err := os.ReadFile(KOReaderSidecar(bookPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	progress := &KOReaderProgress{}
+	if match := koreaderPercent.FindSubmatch(content); match != nil {
+		percent, err := strconv.ParseFloat(string(match[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid percent_finished in %s: %w", KOReaderSidecar(bookPath), err)
+		}
+		progress.Percent = min(max(percent, 0), 1)
+	}
+	if match := koreaderStatus.FindSubmatch(content); match != nil {
+		progress.Status = string(match[1])
+	}
+	return progress, nil
+}
@@ -0,0 +1,58 @@
+package integrations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// koreaderMetadata is a trimmed sidecar as written by KOReader
+const koreaderMetadata = `-- we can read Lua syntax here!
+return {
+    ["cre_dom_version"] = 20240114,
+    ["doc_pages"] = 42,
+    ["last_xpointer"] = "/body/DocFragment[12]/body/div/img",
+    ["percent_finished"] = %s,
+    ["summary"] = {
+        ["modified"] = "2026-10-01",
+        ["status"] = "%s",
+    },
+}
+`
+
+func TestReadKOReaderProgress(t *testing.T) {
+	dir := t.TempDir()
+	book := filepath.Join(dir, "One Piece - c1.epub")
+
+	if progress, err := ReadKOReaderProgress(book); progress != nil || err != nil {
+		t.Fatalf("ReadKOReaderProgress() = %+v, %v, want nothing for a book never opened", progress, err)
+	}
+
+	sidecar := KOReaderSidecar(book)
+	if want := filepath.Join(dir, "One Piece - c1.sdr", "metadata.epub.lua"); sidecar != want {
+		t.Fatalf("KOReaderSidecar() = %s, want %s", sidecar, want)
+	}
+	os.MkdirAll(filepath.Dir(sidecar), 0755)
+
+	tests := []struct {
+		percent, status string
+		want            float64
+		finished        bool
+	}{
+		{"0.5", "reading", 0.5, false},
+		{"0.99", "reading", 0.99, true},
+		{"0.3", "complete", 0.3, true},
+		{"1.2e-1", "abandoned", 0.12, false},
+	}
+	for _, tt := range tests {
+		os.WriteFile(sidecar, []byte(fmt.Sprintf(koreaderMetadata, tt.percent, tt.status)), 0644)
+		progress, err := ReadKOReaderProgress(book)
+		if err != nil {
+			t.Fatalf("ReadKOReaderProgress() error = %v", err)
+		}
+		if progress.Percent != tt.want || progress.Status != tt.status || progress.Finished() != tt.finished {
+			t.Errorf("ReadKOReaderProgress(%s, %s) = %+v, finished %v", tt.percent, tt.status, progress, progress.Finished())
+		}
+	}
+}
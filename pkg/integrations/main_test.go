@@ -0,0 +1,15 @@
+package integrations
+
+import (
+	"os"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// TestMain removes the temp workspace the builders used
+func TestMain(m *testing.M) {
+	code := m.Run()
+	utils.Temp.Cleanup()
+	os.Exit(code)
+}
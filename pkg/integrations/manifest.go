@@ -0,0 +1,55 @@
+package integrations
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// ManifestFilename is the name of the manifest written at the root of a
+// collection export
+const ManifestFilename = "manifest.json"
+
+// ExportManifest describes the files produced by a collection export
+type ExportManifest struct {
+	Collection string           `json:"collection"`
+	Format     string           `json:"format"`
+	CreatedAt  time.Time        `json:"created_at"`
+	Series     []ManifestSeries `json:"series"`
+}
+
+// ManifestSeries lists the exported files of one manga
+type ManifestSeries struct {
+	MangaID  string   `json:"manga_id"`
+	Name     string   `json:"name"`
+	Folder   string   `json:"folder"`
+	Chapters []string `json:"chapters"`
+	Files    []string `json:"files"` // Relative to the export root
+	Error    string   `json:"error,omitempty"`
+}
+
+// SeriesFolder returns the per-series folder name used in collection exports
+func SeriesFolder(name string) string {
+	return sanitizeFilename(name)
+}
+
+// Write saves the manifest as indented JSON in dir
+func (m *ExportManifest) Write(dir string) (string, error) {
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	path := filepath.Join(dir, ManifestFilename)
+	if err := utils.CheckOutputPath(path); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return "", fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return path, nil
+}
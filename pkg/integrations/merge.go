@@ -0,0 +1,150 @@
+package integrations
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// MergeChapterBooks combines the books of chapters released as parts of one
+// chapter, e.g. 12.1 and 12.2, into a single book for chapter written to
+// outputDir: a CBZ when the parts are all CBZs, an EPUB otherwise. Pages
+// follow the order of paths; the manga cover of the first EPUB is kept. The
+// book is written aside and only then moved in place, so a part it replaces
+// is read whole first. The parts are left untouched.
+func MergeChapterBooks(manga *data.Manga, chapter *data.Chapter, paths []string, outputDir string) (string, error) {
+	if len(paths) < 2 {
+		return "", fmt.Errorf("at least 2 chapters are needed to merge")
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	stage, err := os.MkdirTemp(outputDir, ".merge-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(stage)
+
+	var staged, path string
+	if allCBZ(paths) {
+		path = filepath.Join(outputDir, CBZFilename(manga, chapter))
+		staged = filepath.Join(stage, filepath.Base(path))
+		err = mergeCBZ(manga, chapter, paths, staged)
+	} else {
+		staged, err = mergeEPUB(manga, chapter, paths, stage)
+		if err == nil {
+			rel, _ := filepath.Rel(stage, staged)
+			path = filepath.Join(outputDir, rel)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := utils.CheckOutputPath(path); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := os.Rename(staged, path); err != nil {
+		return "", fmt.Errorf("failed to move merged chapter in place: %w", err)
+	}
+	return path, nil
+}
+
+// allCBZ reports whether every book of paths is a CBZ
+func allCBZ(paths []string) bool {
+	for _, path := range paths {
+		if !strings.EqualFold(filepath.Ext(path), ".cbz") {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeEPUB writes the pages of the books of paths to an EPUB in dir
+func mergeEPUB(manga *data.Manga, chapter *data.Chapter, paths []string, dir string) (string, error) {
+	builder := NewEPubBuilder(dir)
+	if err := builder.Init(manga, chapter); err != nil {
+		return "", err
+	}
+	for _, path := range paths {
+		if cover, err := readEPUBCover(path); err == nil {
+			builder.SetMangaCover(cover)
+			break
+		}
+	}
+
+	index := 0
+	for _, path := range paths {
+		pages, err := ReadBookPages(path)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		for _, page := range pages {
+			index++
+			image := ImageData{Content: page, ContentType: http.DetectContentType(page), Index: index}
+			if err := builder.Next(image); err != nil {
+				return "", fmt.Errorf("failed to add page %d: %w", index, err)
+			}
+		}
+	}
+	return builder.Done()
+}
+
+// mergeCBZ writes the pages of the CBZs of paths to a CBZ at outputPath
+func mergeCBZ(manga *data.Manga, chapter *data.Chapter, paths []string, outputPath string) error {
+	var pages [][]byte
+	for _, path := range paths {
+		part, err := ReadBookPages(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		pages = append(pages, part...)
+	}
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create CBZ: %w", err)
+	}
+	if err := writeCBZ(file, manga, chapter, pages); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// readEPUBCover returns the manga cover stored in an EPUB written by
+// EPubBuilder
+func readEPUBCover(epubPath string) (CoverData, error) {
+	reader, err := zip.OpenReader(epubPath)
+	if err != nil {
+		return CoverData{}, fmt.Errorf("failed to open EPUB: %w", err)
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		if !strings.HasPrefix(filepath.Base(file.Name), "manga_cover") {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return CoverData{}, err
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return CoverData{}, err
+		}
+		return CoverData{Content: content, ContentType: http.DetectContentType(content)}, nil
+	}
+	return CoverData{}, fmt.Errorf("no cover found in EPUB")
+}
@@ -0,0 +1,153 @@
+package integrations
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+func TestMergeChapterBooks(t *testing.T) {
+	manga := &data.Manga{ID: "manga-1", Name: "Test Manga"}
+	dir := t.TempDir()
+
+	// Parts with distinct page sizes, to check the merged order
+	var paths []string
+	var want [][]byte
+	for i, number := range []string{"12.1", "12.2"} {
+		builder := NewEPubBuilder(dir)
+		if err := builder.Init(manga, &data.Chapter{ID: "part-" + number, Number: number}); err != nil {
+			t.Fatalf("Init() failed: %v", err)
+		}
+		for page := 0; page < 2; page++ {
+			content := createTestPage(t, 10, 10+i*2+page)
+			want = append(want, content)
+			builder.Next(ImageData{Content: content, ContentType: "image/png", Index: page})
+		}
+		path, err := builder.Done()
+		if err != nil {
+			t.Fatalf("Done() failed: %v", err)
+		}
+		paths = append(paths, path)
+	}
+
+	merged, err := MergeChapterBooks(manga, &data.Chapter{ID: "part-12.1", Number: "12"}, paths, dir)
+	if err != nil {
+		t.Fatalf("MergeChapterBooks() error = %v", err)
+	}
+	if filepath.Base(merged) != "Test Manga_ch_12.epub" {
+		t.Errorf("Merged into %s", filepath.Base(merged))
+	}
+
+	pages, err := ReadEPUBPages(merged)
+	if err != nil {
+		t.Fatalf("ReadEPUBPages() error = %v", err)
+	}
+	if len(pages) != len(want) {
+		t.Fatalf("Merged %d pages, want %d", len(pages), len(want))
+	}
+	for i := range want {
+		if !bytes.Equal(pages[i], want[i]) {
+			t.Errorf("Page %d is out of order", i+1)
+		}
+	}
+	if _, err := readEPUBCover(merged); err != nil {
+		t.Errorf("Expected the merged EPUB to have a cover: %v", err)
+	}
+
+	if _, err := MergeChapterBooks(manga, &data.Chapter{Number: "12"}, paths[:1], dir); err == nil {
+		t.Error("Expected an error merging a single chapter")
+	}
+}
+
+// mergePart writes a part of chapter number with pages to dir, as an EPUB or
+// a CBZ
+func mergePart(t *testing.T, manga *data.Manga, dir, number string, cbz bool, pages [][]byte) string {
+	t.Helper()
+	chapter := &data.Chapter{ID: "part-" + number, Number: number}
+	if cbz {
+		path := filepath.Join(dir, CBZFilename(manga, chapter))
+		file, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		if err := writeCBZ(file, manga, chapter, pages); err != nil {
+			t.Fatalf("writeCBZ() error = %v", err)
+		}
+		return path
+	}
+	builder := NewEPubBuilder(dir)
+	if err := builder.Init(manga, chapter); err != nil {
+		t.Fatalf("Init() failed: %v", err)
+	}
+	for i, page := range pages {
+		builder.Next(ImageData{Content: page, ContentType: "image/png", Index: i})
+	}
+	path, err := builder.Done()
+	if err != nil {
+		t.Fatalf("Done() failed: %v", err)
+	}
+	return path
+}
+
+func TestMergeChapterBooks_CBZ(t *testing.T) {
+	manga := &data.Manga{ID: "manga-1", Name: "Test Manga"}
+	dir := t.TempDir()
+	want := [][]byte{createTestPage(t, 10, 10), createTestPage(t, 10, 11), createTestPage(t, 10, 12)}
+	paths := []string{
+		mergePart(t, manga, dir, "3.1", true, want[:2]),
+		mergePart(t, manga, dir, "3.2", true, want[2:]),
+	}
+
+	merged, err := MergeChapterBooks(manga, &data.Chapter{ID: "part-3.1", Number: "3"}, paths, dir)
+	if err != nil {
+		t.Fatalf("MergeChapterBooks() error = %v", err)
+	}
+	if filepath.Base(merged) != "Test Manga_ch_3.cbz" {
+		t.Errorf("Merged CBZ parts into %s, want a CBZ", filepath.Base(merged))
+	}
+	pages, err := ReadBookPages(merged)
+	if err != nil {
+		t.Fatalf("ReadBookPages() error = %v", err)
+	}
+	if len(pages) != len(want) || !bytes.Equal(pages[2], want[2]) {
+		t.Errorf("Merged %d pages, want %d in order", len(pages), len(want))
+	}
+}
+
+func TestMergeChapterBooks_ReplacesPart(t *testing.T) {
+	manga := &data.Manga{ID: "manga-1", Name: "Test Manga"}
+	dir := t.TempDir()
+	want := [][]byte{createTestPage(t, 10, 10), createTestPage(t, 10, 11), createTestPage(t, 10, 12)}
+	paths := []string{
+		mergePart(t, manga, dir, "12.5", false, want[:1]),
+		mergePart(t, manga, dir, "12.6", true, want[1:]),
+	}
+
+	// Merged under the first part's number, into its file
+	merged, err := MergeChapterBooks(manga, &data.Chapter{ID: "part-12.5", Number: "12.5"}, paths, dir)
+	if err != nil {
+		t.Fatalf("MergeChapterBooks() error = %v", err)
+	}
+	if merged != paths[0] {
+		t.Errorf("Merged into %s, want the first part's file %s", merged, paths[0])
+	}
+	pages, err := ReadEPUBPages(merged)
+	if err != nil {
+		t.Fatalf("ReadEPUBPages() error = %v", err)
+	}
+	if len(pages) != len(want) {
+		t.Fatalf("Merged %d pages, want %d", len(pages), len(want))
+	}
+	for i := range want {
+		if !bytes.Equal(pages[i], want[i]) {
+			t.Errorf("Page %d is out of order", i+1)
+		}
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, ".merge-*")); len(leftovers) != 0 {
+		t.Errorf("Staging directories left behind: %v", leftovers)
+	}
+}
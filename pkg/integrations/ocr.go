@@ -0,0 +1,151 @@
+package integrations
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// TextRecognizer extracts the text of a page image
+type TextRecognizer interface {
+	Recognize(image ImageData) (string, error)
+}
+
+// OCRMode is where recognized text is written
+type OCRMode string
+
+const (
+	OCRAlt     OCRMode = "alt"     // As ALT text of the page images
+	OCRSidecar OCRMode = "sidecar" // In a .txt file next to the EPUB
+	OCRBoth    OCRMode = "both"
+)
+
+// ParseOCRMode parses an OCR mode name
+func ParseOCRMode(name string) (OCRMode, error) {
+	switch mode := OCRMode(strings.ToLower(name)); mode {
+	case OCRAlt, OCRSidecar, OCRBoth:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown OCR mode %q (use alt, sidecar or both)", name)
+}
+
+// OCROptions turns on the text recognition of pages when building EPUBs, so
+// readers supporting it can search downloaded chapters
+type OCROptions struct {
+	Recognizer TextRecognizer
+	Mode       OCRMode
+}
+
+func (o *OCROptions) alt() bool {
+	return o != nil && (o.Mode == OCRAlt || o.Mode == OCRBoth || o.Mode == "")
+}
+
+func (o *OCROptions) sidecar() bool {
+	return o != nil && (o.Mode == OCRSidecar || o.Mode == OCRBoth)
+}
+
+// Tesseract recognizes text with the tesseract command line tool
+type Tesseract struct {
+	Path      string
+	Languages string // Tesseract language codes, e.g. "eng" or "jpn+eng"
+}
+
+// NewTesseract finds the tesseract binary in PATH
+func NewTesseract(languages string) (*Tesseract, error) {
+	path, err := exec.LookPath("tesseract")
+	if err != nil {
+		return nil, fmt.Errorf("tesseract not found, install it to enable OCR: %w", err)
+	}
+	if languages == "" {
+		languages = "eng"
+	}
+	return &Tesseract{Path: path, Languages: languages}, nil
+}
+
+// Recognize runs tesseract on the image and returns the text found
+func (t *Tesseract) Recognize(image ImageData) (string, error) {
+	// Not every tesseract build reads images from stdin
+	tempDir, err := utils.Temp.MkdirTemp("ocr-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer utils.Temp.Release(tempDir)
+
+	imagePath := filepath.Join(tempDir, "page"+getExtensionFromContentType(image.ContentType))
+	if err := os.WriteFile(imagePath, image.Content, 0644); err != nil {
+		return "", fmt.Errorf("failed to write page: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(t.Path, imagePath, "stdout", "-l", t.Languages)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("tesseract failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// recognizeStaged runs OCR on pages staged on disk, reading them back one
+// at a time, reporting FinalizeRecognizing
+func recognizeStaged(recognizer TextRecognizer, images []stagedImage, onProgress func(FinalizeProgress)) []string {
+	texts := make([]string, len(images))
+	for i, img := range images {
+		if content, err := os.ReadFile(img.path); err == nil {
+			image := ImageData{Content: content, ContentType: img.contentType, Index: img.order.Page, Chapter: img.order.Chapter}
+			if text, err := recognizer.Recognize(image); err == nil {
+				texts[i] = cleanOCRText(text)
+			}
+		}
+		reportFinalize(onProgress, FinalizeRecognizing, i+1, len(images))
+	}
+	return texts
+}
+
+// cleanOCRText joins the lines of recognized text and drops the blank ones
+func cleanOCRText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// pageAlt returns the ALT text of a page, with its recognized text if any
+func pageAlt(label, text string) string {
+	if text == "" {
+		return label
+	}
+	return label + ": " + text
+}
+
+// ocrPage is a page of an OCR sidecar file
+type ocrPage struct {
+	Label string
+	Text  string
+}
+
+// OCRSidecarPath returns the path of the text file written next to an EPUB
+func OCRSidecarPath(epubPath string) string {
+	return strings.TrimSuffix(epubPath, filepath.Ext(epubPath)) + ".txt"
+}
+
+// writeOCRSidecar writes the recognized text of the pages of an EPUB to its
+// sidecar file, one section per page
+func writeOCRSidecar(epubPath, title string, pages []ocrPage) error {
+	var b strings.Builder
+	b.WriteString(title)
+	b.WriteString("\n")
+	for _, page := range pages {
+		fmt.Fprintf(&b, "\n[%s]\n", page.Label)
+		if page.Text != "" {
+			b.WriteString(page.Text)
+			b.WriteString("\n")
+		}
+	}
+	if err := os.WriteFile(OCRSidecarPath(epubPath), []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write OCR text: %w", err)
+	}
+	return nil
+}
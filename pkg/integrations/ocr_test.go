@@ -0,0 +1,92 @@
+package integrations
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+// fakeRecognizer returns messy text with the page index, and fails on page 2
+type fakeRecognizer struct{}
+
+func (fakeRecognizer) Recognize(image ImageData) (string, error) {
+	if image.Index == 2 {
+		return "", fmt.Errorf("unreadable")
+	}
+	return fmt.Sprintf("  Hello <page>\n\n %d  ", image.Index), nil
+}
+
+func TestEPubBuilder_OCR(t *testing.T) {
+	dir := t.TempDir()
+	builder := NewEPubBuilder(dir)
+	if err := builder.Init(&data.Manga{ID: "m1", Name: "OCR Manga"}, &data.Chapter{ID: "c1", Number: "1"}); err != nil {
+		t.Fatalf("Init() failed: %v", err)
+	}
+	builder.SetOCR(&OCROptions{Recognizer: fakeRecognizer{}, Mode: OCRBoth})
+
+	var stages []FinalizeStage
+	builder.SetProgressCallback(func(p FinalizeProgress) { stages = append(stages, p.Stage) })
+	for i := 1; i <= 2; i++ {
+		builder.Next(ImageData{Content: createTestPage(t, 10, 20), ContentType: "image/png", Index: i})
+	}
+
+	epubPath, err := builder.Done()
+	if err != nil {
+		t.Fatalf("Done() failed: %v", err)
+	}
+	if len(stages) == 0 || stages[0] != FinalizeRecognizing {
+		t.Errorf("Expected text recognition to be reported first, got %v", stages)
+	}
+
+	section := readEPUBFile(t, epubPath, "section0001.xhtml")
+	if !strings.Contains(section, `alt="Page 1: Hello &lt;page&gt; 1"`) {
+		t.Errorf("Expected the recognized text as ALT text, got:\n%s", section)
+	}
+	if !strings.Contains(section, `alt="Page 2"`) {
+		t.Errorf("Expected a plain ALT text for the failed page, got:\n%s", section)
+	}
+
+	sidecar, err := os.ReadFile(OCRSidecarPath(epubPath))
+	if err != nil {
+		t.Fatalf("Expected a sidecar file: %v", err)
+	}
+	if !strings.Contains(string(sidecar), "[Page 1]\nHello <page> 1\n") || !strings.Contains(string(sidecar), "[Page 2]") {
+		t.Errorf("Unexpected sidecar:\n%s", sidecar)
+	}
+}
+
+func TestEPubBuilder_OCRAltOnly(t *testing.T) {
+	dir := t.TempDir()
+	builder := NewEPubBuilder(dir)
+	builder.Init(&data.Manga{ID: "m1", Name: "OCR Manga"}, &data.Chapter{ID: "c1", Number: "1"})
+	builder.SetOCR(&OCROptions{Recognizer: fakeRecognizer{}, Mode: OCRAlt})
+	builder.Next(ImageData{Content: createTestPage(t, 10, 20), ContentType: "image/png", Index: 1})
+
+	epubPath, err := builder.Done()
+	if err != nil {
+		t.Fatalf("Done() failed: %v", err)
+	}
+	if _, err := os.Stat(OCRSidecarPath(epubPath)); !os.IsNotExist(err) {
+		t.Error("Expected no sidecar file in alt mode")
+	}
+}
+
+func TestParseOCRMode(t *testing.T) {
+	for _, name := range []string{"alt", "Sidecar", "both"} {
+		if _, err := ParseOCRMode(name); err != nil {
+			t.Errorf("ParseOCRMode(%q) error = %v", name, err)
+		}
+	}
+	if _, err := ParseOCRMode("hidden"); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
+
+func TestOCRSidecarPath(t *testing.T) {
+	if got := OCRSidecarPath("/books/Manga_ch_1.epub"); got != "/books/Manga_ch_1.txt" {
+		t.Errorf("OCRSidecarPath() = %q", got)
+	}
+}
@@ -0,0 +1,94 @@
+package integrations
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// OPDS link relations and media types used by the library catalog
+const (
+	OPDSNavigationType  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	OPDSAcquisitionType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	OPDSRelAcquisition  = "http://opds-spec.org/acquisition"
+	OPDSRelImage        = "http://opds-spec.org/image"
+	OPDSRelThumbnail    = "http://opds-spec.org/image/thumbnail"
+	OPDSRelSubsection   = "subsection"
+	OPDSRelStart        = "start"
+	OPDSRelSelf         = "self"
+	OPDSRelUp           = "up"
+
+	EPUBMediaType = "application/epub+zip"
+	CBZMediaType  = "application/vnd.comicbook+zip"
+)
+
+// OPDSFeed is an OPDS 1.2 catalog feed (an Atom feed)
+type OPDSFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  *OPDSAuthor `xml:"author,omitempty"`
+	Links   []OPDSLink  `xml:"link"`
+	Entries []OPDSEntry `xml:"entry"`
+}
+
+// OPDSAuthor names the author of a feed or entry
+type OPDSAuthor struct {
+	Name string `xml:"name"`
+}
+
+// OPDSEntry is a catalog entry: a manga in navigation feeds, a chapter in
+// acquisition feeds
+type OPDSEntry struct {
+	ID      string       `xml:"id"`
+	Title   string       `xml:"title"`
+	Updated string       `xml:"updated"`
+	Author  *OPDSAuthor  `xml:"author,omitempty"`
+	Content *OPDSContent `xml:"content,omitempty"`
+	Links   []OPDSLink   `xml:"link"`
+}
+
+// OPDSContent is the text description of an entry
+type OPDSContent struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+// OPDSLink points to a feed, an image or a downloadable file
+type OPDSLink struct {
+	Rel   string `xml:"rel,attr"`
+	Href  string `xml:"href,attr"`
+	Type  string `xml:"type,attr,omitempty"`
+	Title string `xml:"title,attr,omitempty"`
+}
+
+// NewOPDSFeed creates an empty feed updated at the given time
+func NewOPDSFeed(id, title string, updated time.Time) *OPDSFeed {
+	return &OPDSFeed{
+		Xmlns:   "http://www.w3.org/2005/Atom",
+		ID:      id,
+		Title:   title,
+		Updated: OPDSTime(updated),
+	}
+}
+
+// OPDSTime formats a timestamp the way Atom expects
+func OPDSTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Encode writes the feed as XML
+func (f *OPDSFeed) Encode(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(f); err != nil {
+		return fmt.Errorf("failed to encode OPDS feed: %w", err)
+	}
+	return nil
+}
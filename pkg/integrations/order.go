@@ -0,0 +1,45 @@
+package integrations
+
+import (
+	"fmt"
+
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// PageOrder is the position of a page in a book that may combine several
+// chapters: chapters are ordered by their sort key, pages by their index.
+// Unlike index arithmetic it stays correct for chapters like 10.5 and for
+// chapters with any number of pages.
+type PageOrder struct {
+	Chapter string // utils.ChapterSortKey of the chapter, empty in single-chapter books
+	Page    int
+}
+
+// NewPageOrder returns the order of page in the chapter numbered chapterNumber
+func NewPageOrder(chapterNumber string, page int) PageOrder {
+	return PageOrder{Chapter: utils.ChapterSortKey(chapterNumber), Page: page}
+}
+
+// ChapterKey returns the sort key of a chapter in a book combining several:
+// its number's, then its position in the book, so chapters sharing a number
+// (releases in other languages or by other groups) keep apart
+func ChapterKey(chapterNumber string, position int) string {
+	return fmt.Sprintf("%s_%03d", utils.ChapterSortKey(chapterNumber), position)
+}
+
+// Less reports whether o comes before other
+func (o PageOrder) Less(other PageOrder) bool {
+	if o.Chapter != other.Chapter {
+		return o.Chapter < other.Chapter
+	}
+	return o.Page < other.Page
+}
+
+// String returns the order as a filename fragment that sorts like Less
+// (naturally, once pages go past 9999)
+func (o PageOrder) String() string {
+	if o.Chapter == "" {
+		return fmt.Sprintf("%04d", o.Page)
+	}
+	return fmt.Sprintf("%s_%04d", o.Chapter, o.Page)
+}
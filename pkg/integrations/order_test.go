@@ -0,0 +1,75 @@
+package integrations
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+func TestPageOrder(t *testing.T) {
+	tests := []struct {
+		a, b PageOrder
+		want bool
+	}{
+		{NewPageOrder("10", 999), NewPageOrder("10.5", 0), true},
+		{NewPageOrder("10.5", 0), NewPageOrder("11", 0), true},
+		{NewPageOrder("2", 1500), NewPageOrder("10", 0), true},
+		{NewPageOrder("010", 3), NewPageOrder("10", 2), false},
+		{PageOrder{Page: 9}, PageOrder{Page: 10}, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.a.Less(tt.b); got != tt.want {
+			t.Errorf("%v.Less(%v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	keys := []string{ChapterKey("5", 2), ChapterKey("5", 3), ChapterKey("5.5", 0), ChapterKey("10", 1)}
+	for i := 1; i < len(keys); i++ {
+		if keys[i-1] >= keys[i] {
+			t.Errorf("ChapterKey %q should sort before %q", keys[i-1], keys[i])
+		}
+	}
+
+	if got := (PageOrder{Page: 7}).String(); got != "0007" {
+		t.Errorf("single chapter String() = %q, want 0007", got)
+	}
+}
+
+func TestEPubBuilder_MultiChapterOrder(t *testing.T) {
+	builder := NewEPubBuilder(t.TempDir())
+	if err := builder.Init(&data.Manga{ID: "m", Name: "Order"}, &data.Chapter{ID: "c", Number: "1"}); err != nil {
+		t.Fatalf("Init() failed: %v", err)
+	}
+
+	// Added out of order, with a decimal chapter and a long chapter
+	want := [][]byte{
+		createTestPage(t, 10, 1), // Ch. 10, page 0
+		createTestPage(t, 10, 2), // Ch. 10, page 1000
+		createTestPage(t, 10, 3), // Ch. 10.5, page 0
+		createTestPage(t, 10, 4), // Ch. 100, page 0
+	}
+	builder.Next(ImageData{Content: want[3], ContentType: "image/png", Chapter: NewPageOrder("100", 0).Chapter, Index: 0})
+	builder.Next(ImageData{Content: want[2], ContentType: "image/png", Chapter: NewPageOrder("10.5", 0).Chapter, Index: 0})
+	builder.Next(ImageData{Content: want[1], ContentType: "image/png", Chapter: NewPageOrder("10", 0).Chapter, Index: 1000})
+	builder.Next(ImageData{Content: want[0], ContentType: "image/png", Chapter: NewPageOrder("10", 0).Chapter, Index: 0})
+
+	epubPath, err := builder.Done()
+	if err != nil {
+		t.Fatalf("Done() failed: %v", err)
+	}
+
+	pages, err := ReadEPUBPages(epubPath)
+	if err != nil {
+		t.Fatalf("ReadEPUBPages() error = %v", err)
+	}
+	if len(pages) != len(want) {
+		t.Fatalf("expected %d pages, got %d", len(want), len(pages))
+	}
+	for i := range want {
+		if !bytes.Equal(pages[i], want[i]) {
+			t.Errorf("page %d is out of order", i)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package integrations
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+)
+
+// PanelRegion is a panel of a page, as fractions of the page size
+type PanelRegion struct {
+	X, Y          float64
+	Width, Height float64
+}
+
+// Panel detection thresholds
+const (
+	panelInk       = 200  // Pixels darker than this are ink
+	panelBlankInk  = 0.01 // Lines with less ink than this are gutters
+	panelMinGutter = 0.01 // Gutters thinner than this fraction of the page are ignored
+	panelMinSize   = 0.08 // Panels smaller than this fraction of the page are dropped
+	panelMax       = 9    // Pages cut in more panels fall back to quadrants
+)
+
+// DetectPanels finds the panels of a manga page, split by the light gutters
+// between them: first into rows, then each row into panels. They come in
+// reading order, right to left within a row for manga. Pages without clear
+// gutters, such as splash pages, are split in quadrants.
+func DetectPanels(img image.Image, rightToLeft bool) []PanelRegion {
+	gray := toGray(img)
+	bounds := gray.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return quadrantPanels(rightToLeft)
+	}
+
+	// The share of dark pixels of a row or column of the page
+	inkRow := func(y, x0, x1 int) float64 {
+		row := gray.Pix[y*gray.Stride:]
+		dark := 0
+		for x := x0; x < x1; x++ {
+			if row[x] < panelInk {
+				dark++
+			}
+		}
+		return float64(dark) / float64(x1-x0)
+	}
+	inkColumn := func(x, y0, y1 int) float64 {
+		dark := 0
+		for y := y0; y < y1; y++ {
+			if gray.Pix[y*gray.Stride+x] < panelInk {
+				dark++
+			}
+		}
+		return float64(dark) / float64(y1-y0)
+	}
+
+	var panels []PanelRegion
+	rows := splitGutters(height, func(y int) bool { return inkRow(y, 0, width) < panelBlankInk })
+	for _, row := range rows {
+		columns := splitGutters(width, func(x int) bool { return inkColumn(x, row[0], row[1]) < panelBlankInk })
+		if rightToLeft {
+			for i, j := 0, len(columns)-1; i < j; i, j = i+1, j-1 {
+				columns[i], columns[j] = columns[j], columns[i]
+			}
+		}
+		for _, column := range columns {
+			panels = append(panels, PanelRegion{
+				X:      float64(column[0]) / float64(width),
+				Y:      float64(row[0]) / float64(height),
+				Width:  float64(column[1]-column[0]) / float64(width),
+				Height: float64(row[1]-row[0]) / float64(height),
+			})
+		}
+	}
+
+	if len(panels) < 2 || len(panels) > panelMax {
+		return quadrantPanels(rightToLeft)
+	}
+	return panels
+}
+
+// splitGutters returns the [start, end) spans of length separated by blank
+// lines, ignoring thin gutters and dropping spans too small to be panels
+func splitGutters(length int, blank func(i int) bool) [][2]int {
+	minGutter := max(1, int(float64(length)*panelMinGutter))
+	minSize := int(float64(length) * panelMinSize)
+
+	var spans [][2]int
+	start, gap := -1, 0
+	for i := 0; i < length; i++ {
+		if !blank(i) {
+			if start < 0 {
+				start = i
+			}
+			gap = 0
+			continue
+		}
+		if start < 0 {
+			continue
+		}
+		if gap++; gap >= minGutter {
+			spans = append(spans, [2]int{start, i - gap + 1})
+			start, gap = -1, 0
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, length - gap})
+	}
+
+	kept := spans[:0]
+	for _, span := range spans {
+		if span[1]-span[0] >= minSize {
+			kept = append(kept, span)
+		}
+	}
+	return kept
+}
+
+// quadrantPanels splits a page in four, the way Kindle Comic Creator does
+// for pages without panels
+func quadrantPanels(rightToLeft bool) []PanelRegion {
+	left, right := PanelRegion{X: 0, Width: 0.5, Height: 0.5}, PanelRegion{X: 0.5, Width: 0.5, Height: 0.5}
+	first, second := left, right
+	if rightToLeft {
+		first, second = right, left
+	}
+	bottomFirst, bottomSecond := first, second
+	bottomFirst.Y, bottomSecond.Y = 0.5, 0.5
+	return []PanelRegion{first, second, bottomFirst, bottomSecond}
+}
+
+// addPanelView turns on Kindle Virtual Panels: every page gets its panels
+// as region magnification targets, so small screens can zoom on them one at
+// a time
+func (b *fixedLayoutBook) addPanelView(rightToLeft bool) {
+	b.PanelView = true
+	for i := range b.Pages {
+		page := &b.Pages[i]
+		regions := quadrantPanels(rightToLeft)
+		if data, err := page.Image.read(); err == nil {
+			if img, _, err := image.Decode(bytes.NewReader(data)); err == nil {
+				regions = DetectPanels(img, rightToLeft)
+			}
+		}
+		page.Panels = panelViewPanels(regions, page.Width, page.Height)
+	}
+}
+
+// fixedLayoutPanel is a panel of a page in Kindle region magnification
+// markup: a tap target over the panel and the page magnified to show it
+type fixedLayoutPanel struct {
+	ID      string
+	Ordinal int
+	Left    string // Tap target, percentages of the page
+	Top     string
+	Width   string
+	Height  string
+
+	ImageLeft   int // Magnified page, pixels of the viewport
+	ImageTop    int
+	ImageWidth  int
+	ImageHeight int
+}
+
+// panelViewPanels lays out the magnified views of the panels of a page of
+// width by height pixels: each panel is scaled to fill the screen, at most
+// twice its size, and centered
+func panelViewPanels(regions []PanelRegion, width, height int) []fixedLayoutPanel {
+	panels := make([]fixedLayoutPanel, len(regions))
+	for i, region := range regions {
+		scale := min(1/region.Width, 1/region.Height, 2)
+		imageWidth := int(float64(width) * scale)
+		imageHeight := int(float64(height) * scale)
+		centerX := (region.X + region.Width/2) * float64(imageWidth)
+		centerY := (region.Y + region.Height/2) * float64(imageHeight)
+
+		panels[i] = fixedLayoutPanel{
+			ID:          fmt.Sprintf("PV-%d", i+1),
+			Ordinal:     i + 1,
+			Left:        percent(region.X),
+			Top:         percent(region.Y),
+			Width:       percent(region.Width),
+			Height:      percent(region.Height),
+			ImageLeft:   clampOffset(float64(width)/2-centerX, width-imageWidth),
+			ImageTop:    clampOffset(float64(height)/2-centerY, height-imageHeight),
+			ImageWidth:  imageWidth,
+			ImageHeight: imageHeight,
+		}
+	}
+	return panels
+}
+
+// clampOffset keeps a magnified page covering the screen: its offset stays
+// between lowest, where its far edge meets the screen edge, and 0
+func clampOffset(offset float64, lowest int) int {
+	return int(min(0, max(float64(lowest), offset)))
+}
+
+func percent(fraction float64) string {
+	return fmt.Sprintf("%.2f%%", fraction*100)
+}
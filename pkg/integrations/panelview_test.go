@@ -0,0 +1,149 @@
+package integrations
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// createPanelPage draws a white page with dark panels at the given pixel
+// rectangles
+func createPanelPage(width, height int, panels ...image.Rectangle) *image.Gray {
+	page := image.NewGray(image.Rect(0, 0, width, height))
+	draw.Draw(page, page.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	for _, panel := range panels {
+		draw.Draw(page, panel, image.NewUniform(color.Gray{Y: 60}), image.Point{}, draw.Src)
+	}
+	return page
+}
+
+func TestDetectPanels(t *testing.T) {
+	// Two panels side by side on top, a wide one at the bottom
+	page := createPanelPage(400, 600,
+		image.Rect(20, 20, 190, 290),
+		image.Rect(210, 20, 380, 290),
+		image.Rect(20, 310, 380, 580),
+	)
+
+	panels := DetectPanels(page, true)
+	if len(panels) != 3 {
+		t.Fatalf("Expected 3 panels, got %v", panels)
+	}
+	// Right to left: the top right panel is read first
+	if panels[0].X < 0.5 || panels[1].X > 0.1 || panels[2].Y < 0.5 {
+		t.Errorf("Expected top right, top left then bottom, got %v", panels)
+	}
+	if w := panels[2].Width; w < 0.85 || w > 0.95 {
+		t.Errorf("Expected the bottom panel about 90%% wide, got %.2f", w)
+	}
+
+	if panels := DetectPanels(page, false); panels[0].X > 0.1 {
+		t.Errorf("Expected the top left panel first left to right, got %v", panels[0])
+	}
+
+	// A splash page has no gutters
+	splash := createPanelPage(400, 600, image.Rect(0, 0, 400, 600))
+	if panels := DetectPanels(splash, true); len(panels) != 4 || panels[0] != (PanelRegion{X: 0.5, Width: 0.5, Height: 0.5}) {
+		t.Errorf("Expected quadrants from the top right, got %v", panels)
+	}
+}
+
+func TestPanelViewPanels(t *testing.T) {
+	panels := panelViewPanels(quadrantPanels(false), 1000, 1500)
+	if len(panels) != 4 {
+		t.Fatalf("Expected 4 panels, got %d", len(panels))
+	}
+
+	// Quadrants are shown at twice the size, aligned with their corner
+	bottomRight := panels[3]
+	if bottomRight.ImageWidth != 2000 || bottomRight.ImageHeight != 3000 {
+		t.Errorf("Expected a page magnified twice, got %dx%d", bottomRight.ImageWidth, bottomRight.ImageHeight)
+	}
+	if bottomRight.ImageLeft != -1000 || bottomRight.ImageTop != -1500 {
+		t.Errorf("Expected the bottom right quadrant on screen, got offset %d,%d", bottomRight.ImageLeft, bottomRight.ImageTop)
+	}
+	if bottomRight.Left != "50.00%" || bottomRight.Width != "50.00%" || bottomRight.ID != "PV-4" || bottomRight.Ordinal != 4 {
+		t.Errorf("Unexpected tap target %+v", bottomRight)
+	}
+
+	// A wide panel is only magnified as much as it fits
+	wide := panelViewPanels([]PanelRegion{{X: 0, Y: 0.5, Width: 1, Height: 0.5}}, 1000, 1500)[0]
+	if wide.ImageWidth != 1000 || wide.ImageTop != 0 {
+		t.Errorf("Expected a full width panel left as is, got %+v", wide)
+	}
+}
+
+func TestKindleConverter_PanelView(t *testing.T) {
+	converter, err := NewKindleConverter("kindle-paperwhite3")
+	if err != nil {
+		t.Fatalf("NewKindleConverter() error = %v", err)
+	}
+	converter.tempDir = t.TempDir()
+	defer converter.Close()
+
+	var page bytes.Buffer
+	jpeg.Encode(&page, createPanelPage(400, 600,
+		image.Rect(20, 20, 190, 290),
+		image.Rect(210, 20, 380, 290),
+		image.Rect(20, 310, 380, 580),
+	), nil)
+
+	epubPath, err := converter.generatePanelViewEPUB(
+		[]ProcessedImage{{Data: page.Bytes()}},
+		ExportOptions{
+			Title:       "Panels",
+			OutputPath:  filepath.Join(t.TempDir(), "panels.mobi"),
+			RightToLeft: true,
+			PanelView:   true,
+		},
+	)
+	if err != nil {
+		t.Fatalf("generatePanelViewEPUB() error = %v", err)
+	}
+	if !strings.HasSuffix(epubPath, "panels.epub") {
+		t.Errorf("Expected an EPUB next to the output, got %s", epubPath)
+	}
+
+	reader, err := zip.OpenReader(epubPath)
+	if err != nil {
+		t.Fatalf("failed to open EPUB: %v", err)
+	}
+	defer reader.Close()
+	files := make(map[string]string)
+	for _, f := range reader.File {
+		rc, _ := f.Open()
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(content)
+	}
+
+	opf := files["OEBPS/content.opf"]
+	for _, want := range []string{
+		`<meta name="region-mag" content="true"/>`,
+		`<meta name="book-type" content="comic"/>`,
+		`<meta name="original-resolution" content="1072x1448"/>`,
+		`<meta name="primary-writing-mode" content="horizontal-rl"/>`,
+	} {
+		if !strings.Contains(opf, want) {
+			t.Errorf("package document misses %s:\n%s", want, opf)
+		}
+	}
+
+	xhtml := files["OEBPS/pages/page_0001.xhtml"]
+	if n := strings.Count(xhtml, `class="app-amzn-magnify"`); n != 3 {
+		t.Errorf("Expected 3 magnified panels, got %d:\n%s", n, xhtml)
+	}
+	if !strings.Contains(xhtml, `data-app-amzn-magnify='{"targetId":"PV-1-P", "ordinal":1}'`) || !strings.Contains(xhtml, `id="PV-1-P"`) {
+		t.Errorf("Expected the first panel to target its magnified view:\n%s", xhtml)
+	}
+	if !strings.Contains(files["OEBPS/style.css"], ".PV-P") {
+		t.Error("Expected the panel styles in the stylesheet")
+	}
+}
@@ -0,0 +1,121 @@
+package integrations
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+// Path templates lay out downloads under the download directory. They are
+// relative paths without extension where placeholders are replaced by the
+// book's metadata, and "/" starts a directory:
+//
+//	{manga}/{manga} - c{number}
+//
+// Placeholders: {manga}, {source}, {volume}, {number}, {title} and
+// {language}; volume templates only know the first three. Directories left
+// empty by a missing value are dropped.
+const (
+	DefaultChapterPathTemplate = "{manga}_ch_{number}"
+	DefaultVolumePathTemplate  = "{manga}_vol_{volume}"
+)
+
+var pathPlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// chapterPlaceholders and volumePlaceholders are the placeholders known to
+// chapter and volume templates
+var (
+	chapterPlaceholders = []string{"{manga}", "{source}", "{volume}", "{number}", "{title}", "{language}"}
+	volumePlaceholders  = []string{"{manga}", "{source}", "{volume}"}
+)
+
+// ValidateChapterPathTemplate checks a chapter path template is relative and
+// only uses known placeholders
+func ValidateChapterPathTemplate(template string) error {
+	return validatePathTemplate(template, chapterPlaceholders)
+}
+
+// ValidateVolumePathTemplate checks a volume path template is relative and
+// only uses known placeholders
+func ValidateVolumePathTemplate(template string) error {
+	return validatePathTemplate(template, volumePlaceholders)
+}
+
+func validatePathTemplate(template string, known []string) error {
+	if strings.TrimSpace(template) == "" {
+		return fmt.Errorf("empty path template")
+	}
+	if path.IsAbs(template) || filepath.IsAbs(template) {
+		return fmt.Errorf("path template %q must be relative to the download directory", template)
+	}
+	for _, segment := range strings.Split(template, "/") {
+		if segment == ".." {
+			return fmt.Errorf("path template %q leaves the download directory", template)
+		}
+	}
+	for _, placeholder := range pathPlaceholder.FindAllString(template, -1) {
+		if !slices.Contains(known, placeholder) {
+			return fmt.Errorf("unknown placeholder %s in path template, use %s", placeholder, strings.Join(known, ", "))
+		}
+	}
+	return nil
+}
+
+// ChapterPath returns the path of the EPUB of a chapter relative to the
+// download directory, following template or DefaultChapterPathTemplate when
+// empty, without extension
+func ChapterPath(template string, manga *data.Manga, chapter *data.Chapter) string {
+	if template == "" {
+		template = DefaultChapterPathTemplate
+	}
+	return expandPathTemplate(template, map[string]string{
+		"{manga}":    manga.Name,
+		"{source}":   manga.Source,
+		"{volume}":   chapter.Volume,
+		"{number}":   chapter.Number,
+		"{title}":    chapter.Title,
+		"{language}": chapter.Language,
+	})
+}
+
+// VolumePath returns the path of the EPUB of a volume relative to the
+// download directory, following template or DefaultVolumePathTemplate when
+// empty, without extension
+func VolumePath(template string, manga *data.Manga, volume string) string {
+	if template == "" {
+		template = DefaultVolumePathTemplate
+	}
+	return expandPathTemplate(template, map[string]string{
+		"{manga}":  manga.Name,
+		"{source}": manga.Source,
+		"{volume}": volume,
+	})
+}
+
+// expandPathTemplate replaces the placeholders of template with values made
+// safe for file names, so values never add directories
+func expandPathTemplate(template string, values map[string]string) string {
+	var segments []string
+	for _, segment := range strings.Split(template, "/") {
+		expanded := pathPlaceholder.ReplaceAllStringFunc(segment, func(placeholder string) string {
+			value, ok := values[placeholder]
+			if !ok || value == "" {
+				return ""
+			}
+			return sanitizeFilename(value)
+		})
+		if strings.TrimSpace(expanded) == "" {
+			continue
+		}
+		segments = append(segments, sanitizeFilename(expanded))
+	}
+	if len(segments) == 0 {
+		return "_"
+	}
+	return filepath.Join(segments...)
+}
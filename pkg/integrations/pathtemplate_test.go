@@ -0,0 +1,49 @@
+package integrations
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+func TestChapterPath(t *testing.T) {
+	manga := &data.Manga{Name: "One Piece: Strong/World", Source: "mangadex"}
+	chapter := &data.Chapter{Number: "12.5", Volume: "2", Title: "The Duel", Language: "en"}
+
+	tests := []struct {
+		template string
+		chapter  *data.Chapter
+		want     string
+	}{
+		// The default keeps the flat layout of older downloads
+		{"", chapter, "One Piece_ Strong_World_ch_12.5"},
+		{"{manga}/{manga} - c{number} [{language}]", chapter, filepath.Join("One Piece_ Strong_World", "One Piece_ Strong_World - c12.5 [en]")},
+		{"{source}/{manga}/v{volume}/{number} {title}", chapter, filepath.Join("mangadex", "One Piece_ Strong_World", "v2", "12.5 The Duel")},
+		// Directories of missing values are dropped
+		{"{manga}/{volume}/c{number}", &data.Chapter{Number: "3"}, filepath.Join("One Piece_ Strong_World", "c3")},
+	}
+	for _, tt := range tests {
+		if got := ChapterPath(tt.template, manga, tt.chapter); got != tt.want {
+			t.Errorf("ChapterPath(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+
+	if got := VolumePath("", manga, "3"); got != "One Piece_ Strong_World_vol_3" {
+		t.Errorf("VolumePath() = %q, want the flat default", got)
+	}
+}
+
+func TestValidatePathTemplate(t *testing.T) {
+	if err := ValidateChapterPathTemplate("{manga}/{manga} - c{number}"); err != nil {
+		t.Errorf("ValidateChapterPathTemplate() error = %v", err)
+	}
+	for _, template := range []string{"", "/srv/{manga}", "../{manga}", "{manga}/{chapter}"} {
+		if err := ValidateChapterPathTemplate(template); err == nil {
+			t.Errorf("Expected an error for %q", template)
+		}
+	}
+	if err := ValidateVolumePathTemplate("{manga}/v{volume} {number}"); err == nil {
+		t.Error("Expected volume templates to reject {number}")
+	}
+}
@@ -0,0 +1,346 @@
+package integrations
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+// Field numbers of the Tachiyomi/Mihon backup protobuf messages
+const (
+	tachiBackupManga    = 1
+	tachiBackupCategory = 2
+	tachiBackupSource   = 101
+
+	tachiMangaSource       = 1
+	tachiMangaURL          = 2
+	tachiMangaTitle        = 3
+	tachiMangaArtist       = 4
+	tachiMangaAuthor       = 5
+	tachiMangaDescription  = 6
+	tachiMangaGenre        = 7
+	tachiMangaStatus       = 8
+	tachiMangaThumbnailURL = 9
+	tachiMangaChapters     = 16
+	tachiMangaCategories   = 17
+	tachiMangaFavorite     = 100
+
+	tachiChapterURL          = 1
+	tachiChapterName         = 2
+	tachiChapterRead         = 4
+	tachiChapterLastPageRead = 6
+	tachiChapterNumber       = 9
+
+	tachiCategoryName  = 1
+	tachiCategoryOrder = 2
+
+	tachiSourceName = 1
+	tachiSourceID   = 2
+)
+
+// tachiPublicationStatus maps Tachiyomi manga statuses to publication statuses
+var tachiPublicationStatus = map[uint64]string{
+	1: "ongoing",
+	2: "completed",
+	4: "completed", // Publishing finished
+	5: "cancelled",
+	6: "hiatus",
+}
+
+// TachiyomiReport lists the manga of a backup that could not be imported
+type TachiyomiReport struct {
+	Skipped []string // Titles from sources mangas does not support
+}
+
+// ReadTachiyomiBackup converts a Tachiyomi or Mihon backup (.tachibk or
+// .proto.gz, gzipped or not) into a library export. Library entries whose
+// extension matches one of sourceNames are kept, with IDs taken from the
+// last part of their URLs; categories become collections.
+func ReadTachiyomiBackup(r io.Reader, sourceNames []string) (*data.LibraryExport, *TachiyomiReport, error) {
+	raw, err := readMaybeGzipped(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+	backup, err := readProtoFields(raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid backup: %w", err)
+	}
+
+	known := make(map[string]string, len(sourceNames))
+	for _, name := range sourceNames {
+		known[normalizeSourceName(name)] = name
+	}
+
+	extensions := make(map[uint64]string) // Source ID to name
+	categories := make(map[uint64]string) // Category order to name
+	for _, field := range backup {
+		switch field.num {
+		case tachiBackupSource:
+			source, err := readProtoFields(field.bytes)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid source: %w", err)
+			}
+			extensions[protoVarint(source, tachiSourceID)] = protoString(source, tachiSourceName)
+		case tachiBackupCategory:
+			category, err := readProtoFields(field.bytes)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid category: %w", err)
+			}
+			categories[protoVarint(category, tachiCategoryOrder)] = protoString(category, tachiCategoryName)
+		}
+	}
+
+	export := &data.LibraryExport{Version: data.LibraryExportVersion, ExportedAt: time.Now().UTC()}
+	report := &TachiyomiReport{}
+	for _, field := range backup {
+		if field.num != tachiBackupManga {
+			continue
+		}
+		fields, err := readProtoFields(field.bytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid manga: %w", err)
+		}
+		// Entries with history but not in the library are not favorites;
+		// favorite is true unless written
+		if favorite, ok := protoField(fields, tachiMangaFavorite); ok && favorite.varint == 0 {
+			continue
+		}
+
+		title := protoString(fields, tachiMangaTitle)
+		source, ok := known[normalizeSourceName(extensions[protoVarint(fields, tachiMangaSource)])]
+		if !ok {
+			report.Skipped = append(report.Skipped, title)
+			continue
+		}
+		manga, err := tachiManga(fields, source, categories)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid manga %s: %w", title, err)
+		}
+		if manga.ID == "" || manga.Name == "" {
+			report.Skipped = append(report.Skipped, title)
+			continue
+		}
+		export.Mangas = append(export.Mangas, manga)
+	}
+	return export, report, nil
+}
+
+// tachiManga converts a backup manga of a supported source
+func tachiManga(fields []protoFieldValue, source string, categories map[uint64]string) (data.LibraryManga, error) {
+	manga := data.LibraryManga{
+		ID:                idFromURL(protoString(fields, tachiMangaURL)),
+		Name:              protoString(fields, tachiMangaTitle),
+		Description:       protoString(fields, tachiMangaDescription),
+		CoverURL:          protoString(fields, tachiMangaThumbnailURL),
+		Source:            source,
+		PublicationStatus: tachiPublicationStatus[protoVarint(fields, tachiMangaStatus)],
+	}
+	if author := protoString(fields, tachiMangaAuthor); author != "" {
+		manga.Authors = splitPeople(author)
+	}
+	if artist := protoString(fields, tachiMangaArtist); artist != "" {
+		manga.Artists = splitPeople(artist)
+	}
+
+	for _, field := range fields {
+		switch field.num {
+		case tachiMangaGenre:
+			manga.Tags = append(manga.Tags, string(field.bytes))
+		case tachiMangaCategories:
+			// Written one by one or packed
+			orders := []uint64{field.varint}
+			if field.wire == protoWireBytes {
+				orders = readPackedVarints(field.bytes)
+			}
+			for _, order := range orders {
+				if name, ok := categories[order]; ok {
+					manga.Collections = append(manga.Collections, name)
+				}
+			}
+		case tachiMangaChapters:
+			chapter, err := readProtoFields(field.bytes)
+			if err != nil {
+				return manga, err
+			}
+			if chapter := tachiChapter(chapter); chapter.ID != "" {
+				manga.Chapters = append(manga.Chapters, chapter)
+			}
+		}
+	}
+	return manga, nil
+}
+
+// tachiChapter converts a backup chapter
+func tachiChapter(fields []protoFieldValue) data.LibraryChapter {
+	chapter := data.LibraryChapter{
+		ID:    idFromURL(protoString(fields, tachiChapterURL)),
+		Title: protoString(fields, tachiChapterName),
+		Read:  protoVarint(fields, tachiChapterRead) != 0,
+	}
+	if number, ok := protoField(fields, tachiChapterNumber); ok {
+		if value := math.Float32frombits(uint32(number.varint)); value >= 0 {
+			chapter.Number = strconv.FormatFloat(float64(value), 'f', -1, 32)
+		}
+	}
+	// Tachiyomi counts pages from 0
+	if page := protoVarint(fields, tachiChapterLastPageRead); page > 0 && !chapter.Read {
+		chapter.LastReadPage = int(page) + 1
+	}
+	return chapter
+}
+
+// idFromURL returns the last part of a source URL, the ID of the manga or
+// chapter for the supported sources
+func idFromURL(url string) string {
+	url = strings.TrimRight(strings.SplitN(url, "?", 2)[0], "/#")
+	if i := strings.LastIndex(url, "/"); i >= 0 {
+		url = url[i+1:]
+	}
+	return strings.TrimRight(url, "#")
+}
+
+// normalizeSourceName lets "MangaDex" or "Comick (Unoriginal)" match the
+// "mangadex" and "comick" sources
+func normalizeSourceName(name string) string {
+	if i := strings.Index(name, "("); i >= 0 {
+		name = name[:i]
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// splitPeople splits a comma separated list of authors or artists
+func splitPeople(list string) []string {
+	var people []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			people = append(people, name)
+		}
+	}
+	return people
+}
+
+// readMaybeGzipped reads r, decompressing it when it is gzipped
+func readMaybeGzipped(r io.Reader) ([]byte, error) {
+	buffered := bufio.NewReader(r)
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		return io.ReadAll(gz)
+	}
+	return io.ReadAll(buffered)
+}
+
+// Protobuf wire types
+const (
+	protoWireVarint  = 0
+	protoWireFixed64 = 1
+	protoWireBytes   = 2
+	protoWireFixed32 = 5
+)
+
+// protoFieldValue is a decoded protobuf field. Varint and fixed-size values
+// are in varint, length-delimited ones in bytes.
+type protoFieldValue struct {
+	num    int
+	wire   int
+	varint uint64
+	bytes  []byte
+}
+
+// readProtoFields decodes the fields of a protobuf message, without schema
+func readProtoFields(b []byte) ([]protoFieldValue, error) {
+	var fields []protoFieldValue
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid field key")
+		}
+		b = b[n:]
+
+		field := protoFieldValue{num: int(key >> 3), wire: int(key & 7)}
+		switch field.wire {
+		case protoWireVarint:
+			field.varint, n = binary.Uvarint(b)
+			if n <= 0 {
+				return nil, fmt.Errorf("invalid varint in field %d", field.num)
+			}
+			b = b[n:]
+		case protoWireFixed64:
+			if len(b) < 8 {
+				return nil, fmt.Errorf("truncated field %d", field.num)
+			}
+			field.varint = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case protoWireFixed32:
+			if len(b) < 4 {
+				return nil, fmt.Errorf("truncated field %d", field.num)
+			}
+			field.varint = uint64(binary.LittleEndian.Uint32(b))
+			b = b[4:]
+		case protoWireBytes:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < length {
+				return nil, fmt.Errorf("truncated field %d", field.num)
+			}
+			field.bytes = b[n : n+int(length)]
+			b = b[n+int(length):]
+		default:
+			return nil, fmt.Errorf("unsupported wire type %d in field %d", field.wire, field.num)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// readPackedVarints decodes a packed repeated varint field
+func readPackedVarints(b []byte) []uint64 {
+	var values []uint64
+	for len(b) > 0 {
+		value, n := binary.Uvarint(b)
+		if n <= 0 {
+			break
+		}
+		values = append(values, value)
+		b = b[n:]
+	}
+	return values
+}
+
+// protoField returns the last occurrence of a field, as protobuf does for
+// singular fields
+func protoField(fields []protoFieldValue, num int) (protoFieldValue, bool) {
+	for i := len(fields) - 1; i >= 0; i-- {
+		if fields[i].num == num {
+			return fields[i], true
+		}
+	}
+	return protoFieldValue{}, false
+}
+
+func protoString(fields []protoFieldValue, num int) string {
+	field, _ := protoField(fields, num)
+	return string(bytes.TrimSpace(field.bytes))
+}
+
+func protoVarint(fields []protoFieldValue, num int) uint64 {
+	field, _ := protoField(fields, num)
+	return field.varint
+}
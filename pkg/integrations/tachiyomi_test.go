@@ -0,0 +1,110 @@
+package integrations
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// protoMessage builds protobuf messages for tests
+type protoMessage []byte
+
+func (m protoMessage) varint(num int, value uint64) protoMessage {
+	m = binary.AppendUvarint(m, uint64(num)<<3|protoWireVarint)
+	return binary.AppendUvarint(m, value)
+}
+
+func (m protoMessage) bytes(num int, value []byte) protoMessage {
+	m = binary.AppendUvarint(m, uint64(num)<<3|protoWireBytes)
+	m = binary.AppendUvarint(m, uint64(len(value)))
+	return append(m, value...)
+}
+
+func (m protoMessage) str(num int, value string) protoMessage {
+	return m.bytes(num, []byte(value))
+}
+
+func (m protoMessage) float(num int, value float32) protoMessage {
+	m = binary.AppendUvarint(m, uint64(num)<<3|protoWireFixed32)
+	return binary.LittleEndian.AppendUint32(m, math.Float32bits(value))
+}
+
+func TestReadTachiyomiBackup(t *testing.T) {
+	chapter1 := protoMessage{}.str(tachiChapterURL, "/chapter/c-1").str(tachiChapterName, "Vol.1 Ch.1").
+		varint(tachiChapterRead, 1).float(tachiChapterNumber, 1)
+	chapter2 := protoMessage{}.str(tachiChapterURL, "/chapter/c-2").varint(tachiChapterLastPageRead, 4).
+		float(tachiChapterNumber, 2.5)
+
+	followed := protoMessage{}.varint(tachiMangaSource, 2499283573021220255).
+		str(tachiMangaURL, "/manga/m-1").str(tachiMangaTitle, "Followed").
+		str(tachiMangaAuthor, "Author A, Author B").str(tachiMangaGenre, "Action").str(tachiMangaGenre, "Drama").
+		varint(tachiMangaStatus, 2).str(tachiMangaThumbnailURL, "https://example.com/cover.jpg").
+		bytes(tachiMangaChapters, chapter1).bytes(tachiMangaChapters, chapter2).
+		bytes(tachiMangaCategories, binary.AppendUvarint(nil, 1)) // Packed
+	history := protoMessage{}.varint(tachiMangaSource, 2499283573021220255).
+		str(tachiMangaURL, "/manga/m-2").str(tachiMangaTitle, "Only Read Once").varint(tachiMangaFavorite, 0)
+	unsupported := protoMessage{}.varint(tachiMangaSource, 42).
+		str(tachiMangaURL, "/series/other").str(tachiMangaTitle, "Elsewhere")
+
+	backup := protoMessage{}.
+		bytes(tachiBackupManga, followed).bytes(tachiBackupManga, history).bytes(tachiBackupManga, unsupported).
+		bytes(tachiBackupCategory, protoMessage{}.str(tachiCategoryName, "Favorites").varint(tachiCategoryOrder, 1)).
+		bytes(tachiBackupSource, protoMessage{}.str(tachiSourceName, "MangaDex").varint(tachiSourceID, 2499283573021220255)).
+		bytes(tachiBackupSource, protoMessage{}.str(tachiSourceName, "Some Site").varint(tachiSourceID, 42))
+
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write(backup)
+	gz.Close()
+
+	export, report, err := ReadTachiyomiBackup(&gzipped, []string{"comick", "mangadex"})
+	if err != nil {
+		t.Fatalf("ReadTachiyomiBackup() error = %v", err)
+	}
+	if len(report.Skipped) != 1 || report.Skipped[0] != "Elsewhere" {
+		t.Errorf("Skipped = %v, want [Elsewhere]", report.Skipped)
+	}
+	if len(export.Mangas) != 1 {
+		t.Fatalf("Got %d manga, want only the followed one", len(export.Mangas))
+	}
+
+	manga := export.Mangas[0]
+	if manga.ID != "m-1" || manga.Source != "mangadex" || manga.Name != "Followed" || manga.PublicationStatus != "completed" {
+		t.Errorf("Unexpected manga: %+v", manga)
+	}
+	if len(manga.Authors) != 2 || len(manga.Tags) != 2 || manga.CoverURL == "" {
+		t.Errorf("Unexpected metadata: %+v", manga)
+	}
+	if len(manga.Collections) != 1 || manga.Collections[0] != "Favorites" {
+		t.Errorf("Collections = %v, want [Favorites]", manga.Collections)
+	}
+	if len(manga.Chapters) != 2 {
+		t.Fatalf("Got %d chapters, want 2", len(manga.Chapters))
+	}
+	if ch := manga.Chapters[0]; ch.ID != "c-1" || ch.Number != "1" || !ch.Read || ch.Title != "Vol.1 Ch.1" {
+		t.Errorf("Unexpected first chapter: %+v", ch)
+	}
+	if ch := manga.Chapters[1]; ch.Number != "2.5" || ch.Read || ch.LastReadPage != 5 {
+		t.Errorf("Unexpected second chapter: %+v", ch)
+	}
+
+	if _, _, err := ReadTachiyomiBackup(bytes.NewReader([]byte{0x0a, 0x10}), nil); err == nil {
+		t.Error("Expected an error for a truncated backup")
+	}
+}
+
+func TestIDFromURL(t *testing.T) {
+	tests := map[string]string{
+		"/manga/a1b2":             "a1b2",
+		"/chapter/c3/":            "c3",
+		"/comic/xyz#":             "xyz",
+		"https://x.org/m/1?ln=en": "1",
+	}
+	for url, want := range tests {
+		if got := idFromURL(url); got != want {
+			t.Errorf("idFromURL(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
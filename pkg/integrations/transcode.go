@@ -0,0 +1,111 @@
+package integrations
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// AVIF has no decoder in our dependencies: pages are decoded with avifdec
+// (libavif) or ImageMagick when one is installed, so image.Decode reads them
+// like any other format
+func init() {
+	image.RegisterFormat("avif", "????ftypavif", decodeAVIF, decodeAVIFConfig)
+	image.RegisterFormat("avif", "????ftypavis", decodeAVIF, decodeAVIFConfig)
+}
+
+// avifDecoders are the programs tried to decode AVIF, in order
+var avifDecoders = []string{"avifdec", "magick"}
+
+// AVIFDecoder returns the program decoding AVIF pages, "" when none is
+// installed
+func AVIFDecoder() string {
+	for _, candidate := range avifDecoders {
+		if path, err := exec.LookPath(candidate); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+func decodeAVIF(r io.Reader) (image.Image, error) {
+	tool := AVIFDecoder()
+	if tool == "" {
+		return nil, fmt.Errorf("no AVIF decoder available (install %s)", strings.Join(avifDecoders, " or "))
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// avifdec only works on files
+	tempDir, err := utils.Temp.MkdirTemp("avif-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer utils.Temp.Release(tempDir)
+	input, output := filepath.Join(tempDir, "page.avif"), filepath.Join(tempDir, "page.png")
+	if err := os.WriteFile(input, content, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write page: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(tool, input, output)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s failed: %w: %s", filepath.Base(tool), err, strings.TrimSpace(stderr.String()))
+	}
+	decoded, err := os.ReadFile(output)
+	if err != nil {
+		return nil, fmt.Errorf("%s wrote no image: %w", filepath.Base(tool), err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(decoded))
+	return img, err
+}
+
+func decodeAVIFConfig(r io.Reader) (image.Config, error) {
+	img, err := decodeAVIF(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: img.ColorModel(), Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}, nil
+}
+
+// TranscodePage converts a page in a format many readers can't render,
+// such as WebP, AVIF or GIF, to JPEG at quality 90. Transparent areas
+// become white. JPEG and PNG pages, and content that isn't an image, are
+// returned as they are.
+func TranscodePage(page ImageData) (ImageData, error) {
+	switch format := sniffImage(page.Content); format {
+	case "", "image/jpeg", "image/png":
+		return page, nil
+	default:
+		img, _, err := image.Decode(bytes.NewReader(page.Content))
+		if err != nil {
+			return page, fmt.Errorf("failed to decode %s page: %w", format, err)
+		}
+
+		// JPEG has no alpha: flatten on white paper
+		flat := image.NewRGBA(img.Bounds())
+		draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: 90}); err != nil {
+			return page, err
+		}
+		page.Content = buf.Bytes()
+		page.ContentType = "image/jpeg"
+		return page, nil
+	}
+}
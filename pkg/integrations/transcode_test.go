@@ -0,0 +1,84 @@
+package integrations
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestTranscodePage(t *testing.T) {
+	var buf bytes.Buffer
+	frame := image.NewPaletted(image.Rect(0, 0, 40, 60), color.Palette{color.Transparent, color.Black})
+	frame.SetColorIndex(10, 10, 1)
+	gif.Encode(&buf, frame, nil)
+	page := ImageData{Content: buf.Bytes(), ContentType: "image/gif", Index: 2}
+
+	transcoded, err := TranscodePage(page)
+	if err != nil {
+		t.Fatalf("TranscodePage() error = %v", err)
+	}
+	img, format, err := image.Decode(bytes.NewReader(transcoded.Content))
+	if err != nil || format != "jpeg" || transcoded.ContentType != "image/jpeg" || transcoded.Index != 2 {
+		t.Fatalf("Expected a JPEG page, got %s (%s), %v", format, transcoded.ContentType, err)
+	}
+	if img.Bounds().Dx() != 40 || img.Bounds().Dy() != 60 {
+		t.Errorf("Expected the size kept, got %v", img.Bounds())
+	}
+	if r, _, _, _ := img.At(30, 50).RGBA(); r>>8 < 240 {
+		t.Errorf("Expected transparent areas white, got %d", r>>8)
+	}
+
+	// JPEG pages, and anything that isn't an image, are kept as they are
+	buf.Reset()
+	jpeg.Encode(&buf, frame, nil)
+	for _, content := range [][]byte{buf.Bytes(), []byte("not an image")} {
+		kept, err := TranscodePage(ImageData{Content: content, ContentType: "image/jpeg"})
+		if err != nil || !bytes.Equal(kept.Content, content) {
+			t.Errorf("Expected the page kept, got %v", err)
+		}
+	}
+}
+
+func TestTranscodePage_AVIF(t *testing.T) {
+	avif := append([]byte{0, 0, 0, 0x1c}, []byte("ftypavif")...)
+	avif = append(avif, make([]byte, 32)...)
+	page := ImageData{Content: avif, ContentType: "image/avif"}
+
+	path := os.Getenv("PATH")
+	dir := t.TempDir()
+	t.Setenv("PATH", dir)
+	if AVIFDecoder() != "" {
+		t.Fatal("Expected no AVIF decoder")
+	}
+	if _, err := TranscodePage(page); err == nil {
+		t.Error("Expected an error without an AVIF decoder")
+	}
+
+	if runtime.GOOS == "windows" {
+		t.Skip("fake decoder is a shell script")
+	}
+	// A fake avifdec writing a fixed image
+	var decoded bytes.Buffer
+	jpeg.Encode(&decoded, createPanelPage(30, 20), nil)
+	os.WriteFile(filepath.Join(dir, "page.jpg"), decoded.Bytes(), 0644)
+	os.WriteFile(filepath.Join(dir, "avifdec"), []byte("#!/bin/sh\ncp \""+filepath.Join(dir, "page.jpg")+"\" \"$2\"\n"), 0755)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+
+	transcoded, err := TranscodePage(page)
+	if err != nil {
+		t.Fatalf("TranscodePage() error = %v", err)
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(transcoded.Content))
+	if err != nil || format != "jpeg" || config.Width != 30 || config.Height != 20 {
+		t.Errorf("Expected a 30x20 JPEG, got %s %dx%d, %v", format, config.Width, config.Height, err)
+	}
+	if err := CheckImage(avif, "image/avif", ImageCheckDecode); err != nil {
+		t.Errorf("Expected AVIF pages decoded with the decoder, got %v", err)
+	}
+}
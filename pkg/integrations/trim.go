@@ -0,0 +1,179 @@
+package integrations
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+)
+
+// PreprocessOptions are the clean-ups run on scans at full size, before
+// they are resized
+type PreprocessOptions struct {
+	TrimMargins bool `json:"trim_margins,omitempty"` // Crop the uniform white or black borders
+	AutoLevel   bool `json:"autolevel,omitempty"`    // Stretch the levels so the darkest ink is black and the paper white
+}
+
+// Enabled reports whether any clean-up is on
+func (o PreprocessOptions) Enabled() bool {
+	return o.TrimMargins || o.AutoLevel
+}
+
+// Margin trimming and auto-level thresholds
+const (
+	trimTolerance = 24    // Pixels this close to the border color are margin
+	trimNoise     = 0.005 // Lines with fewer other pixels than this are still margin, e.g. specks of dust
+	trimMaxCrop   = 0.25  // At most this fraction of a side is cropped
+	trimPadding   = 0.005 // Margin kept around the content, so it doesn't touch the screen edge
+	autoLevelClip = 0.005 // Share of the darkest and of the lightest pixels clipped by AutoLevel
+	autoLevelMin  = 64    // Pages using a narrower range of levels are left alone, e.g. blank ones
+)
+
+// Preprocess runs the clean-ups of options on img
+func Preprocess(img image.Image, options PreprocessOptions) image.Image {
+	if options.TrimMargins {
+		img = TrimMargins(img)
+	}
+	if options.AutoLevel {
+		img = AutoLevel(img)
+	}
+	return img
+}
+
+// TrimMargins crops the uniform white or black borders of a scan, so the
+// content fills more of small screens. Each side is cropped while its lines
+// keep the color of its outermost line, up to a quarter of the page.
+func TrimMargins(img image.Image) image.Image {
+	gray := toGray(img)
+	bounds := gray.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return img
+	}
+
+	pixel := func(x, y int) uint8 {
+		return gray.Pix[y*gray.Stride+x]
+	}
+	row := func(y int) func(i int) uint8 { return func(x int) uint8 { return pixel(x, y) } }
+	column := func(x int) func(i int) uint8 { return func(y int) uint8 { return pixel(x, y) } }
+
+	top := marginLines(height, width, row, func(i int) int { return i })
+	bottom := marginLines(height, width, row, func(i int) int { return height - 1 - i })
+	left := marginLines(width, height, column, func(i int) int { return i })
+	right := marginLines(width, height, column, func(i int) int { return width - 1 - i })
+
+	// Pages margin all the way through are blank, or nearly: left as they are
+	limitX, limitY := int(float64(width)*trimMaxCrop), int(float64(height)*trimMaxCrop)
+	if (top == limitY && bottom == limitY) || (left == limitX && right == limitX) {
+		return img
+	}
+
+	padX, padY := int(float64(width)*trimPadding), int(float64(height)*trimPadding)
+	crop := image.Rect(
+		bounds.Min.X+max(0, left-padX),
+		bounds.Min.Y+max(0, top-padY),
+		bounds.Max.X-max(0, right-padX),
+		bounds.Min.Y+height-max(0, bottom-padY),
+	)
+	if crop == bounds {
+		return img
+	}
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(crop)
+	}
+	return toRGBA(img).SubImage(crop)
+}
+
+// marginLines counts the margin lines of a side of a page, lines of span
+// pixels read with line, the i-th from the side being at(i)
+func marginLines(length, span int, line func(int) func(int) uint8, at func(int) int) int {
+	// The outermost line gives the color of the margin
+	var sum int
+	outer := line(at(0))
+	for i := 0; i < span; i++ {
+		sum += int(outer(i))
+	}
+	color := sum / span
+	if color > trimTolerance && color < 255-trimTolerance {
+		return 0 // Neither white nor black, the content starts at the edge
+	}
+
+	allowed := int(float64(span) * trimNoise)
+	limit := int(float64(length) * trimMaxCrop)
+	for n := 0; n < limit; n++ {
+		pixels, other := line(at(n)), 0
+		for i := 0; i < span; i++ {
+			diff := int(pixels(i)) - color
+			if diff > trimTolerance || diff < -trimTolerance {
+				if other++; other > allowed {
+					return n
+				}
+			}
+		}
+	}
+	return limit
+}
+
+// AutoLevel stretches the levels of a scan so its darkest ink becomes black
+// and its paper white, making faded and yellowed scans crisp on e-ink. The
+// few darkest and lightest pixels are clipped so specks don't decide the
+// range. Gray images stay Gray, others come back as RGBA.
+func AutoLevel(img image.Image) image.Image {
+	gray := toGray(img)
+	bounds := gray.Bounds()
+	var histogram [256]int
+	for y := 0; y < bounds.Dy(); y++ {
+		for _, v := range gray.Pix[y*gray.Stride : y*gray.Stride+bounds.Dx()] {
+			histogram[v]++
+		}
+	}
+
+	clip := int(float64(bounds.Dx()*bounds.Dy()) * autoLevelClip)
+	black, white := 0, 255
+	for count := 0; black < 255; black++ {
+		if count += histogram[black]; count > clip {
+			break
+		}
+	}
+	for count := 0; white > 0; white-- {
+		if count += histogram[white]; count > clip {
+			break
+		}
+	}
+	if white-black < autoLevelMin || (black == 0 && white == 255) {
+		return img
+	}
+	return adjustCopy(img, levelsCurve(black, white, 1))
+}
+
+// PreprocessPage runs the clean-ups of options on a downloaded page and
+// encodes it back in its format: JPEG pages at quality 90, others as PNG.
+// Pages that can't be decoded are returned as they are.
+func PreprocessPage(page ImageData, options PreprocessOptions) (ImageData, error) {
+	if !options.Enabled() {
+		return page, nil
+	}
+	img, format, err := image.Decode(bytes.NewReader(page.Content))
+	if err != nil {
+		return page, nil
+	}
+	processed := Preprocess(img, options)
+	if processed == img {
+		return page, nil
+	}
+
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, processed, &jpeg.Options{Quality: 90})
+	} else {
+		err = png.Encode(&buf, processed)
+		page.ContentType = "image/png"
+	}
+	if err != nil {
+		return page, err
+	}
+	page.Content = buf.Bytes()
+	return page, nil
+}
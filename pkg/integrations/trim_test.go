@@ -0,0 +1,83 @@
+package integrations
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"testing"
+)
+
+func TestTrimMargins(t *testing.T) {
+	// A dark panel in the middle of a white page, a speck of dust in the margin
+	page := createPanelPage(400, 600, image.Rect(50, 100, 350, 500))
+	page.SetGray(10, 10, color.Gray{Y: 0})
+
+	trimmed := TrimMargins(page).Bounds()
+	if trimmed.Dx() < 300 || trimmed.Dx() > 310 || trimmed.Dy() < 400 || trimmed.Dy() > 410 {
+		t.Errorf("Expected the page cropped to the panel with a little padding, got %v", trimmed)
+	}
+
+	// Black borders are trimmed too
+	black := image.NewGray(image.Rect(0, 0, 400, 600))
+	draw.Draw(black, image.Rect(20, 0, 380, 600), image.NewUniform(color.Gray{Y: 128}), image.Point{}, draw.Src)
+	if got := TrimMargins(black).Bounds(); got.Min.X < 15 || got.Max.X > 385 || got.Dy() != 600 {
+		t.Errorf("Expected the black side borders cropped, got %v", got)
+	}
+
+	// Blank pages are left alone
+	blank := createPanelPage(400, 600)
+	if got := TrimMargins(blank).Bounds(); got != blank.Bounds() {
+		t.Errorf("Expected a blank page untouched, got %v", got)
+	}
+}
+
+func TestAutoLevel(t *testing.T) {
+	// A faded scan: gray ink on yellowed paper
+	faded := image.NewGray(image.Rect(0, 0, 100, 100))
+	draw.Draw(faded, faded.Bounds(), image.NewUniform(color.Gray{Y: 200}), image.Point{}, draw.Src)
+	draw.Draw(faded, image.Rect(20, 20, 80, 80), image.NewUniform(color.Gray{Y: 70}), image.Point{}, draw.Src)
+
+	leveled := AutoLevel(faded).(*image.Gray)
+	if ink, paper := leveled.GrayAt(50, 50).Y, leveled.GrayAt(5, 5).Y; ink != 0 || paper != 255 {
+		t.Errorf("Expected black ink on white paper, got %d on %d", ink, paper)
+	}
+	if faded.GrayAt(50, 50).Y != 70 {
+		t.Error("Expected the original page left untouched")
+	}
+
+	// Pages already using the full range are left alone
+	if got := AutoLevel(createPanelPage(100, 100, image.Rect(10, 10, 90, 90))); got == nil {
+		t.Error("Expected a page back")
+	}
+}
+
+func TestPreprocessPage(t *testing.T) {
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, createPanelPage(400, 600, image.Rect(50, 100, 350, 500)), nil)
+	page := ImageData{Content: buf.Bytes(), ContentType: "image/jpeg", Index: 3}
+
+	same, err := PreprocessPage(page, PreprocessOptions{})
+	if err != nil || !bytes.Equal(same.Content, page.Content) {
+		t.Errorf("Expected the page untouched without clean-ups, got %v", err)
+	}
+
+	trimmed, err := PreprocessPage(page, PreprocessOptions{TrimMargins: true})
+	if err != nil {
+		t.Fatalf("PreprocessPage() error = %v", err)
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(trimmed.Content))
+	if err != nil || format != "jpeg" || trimmed.ContentType != "image/jpeg" || trimmed.Index != 3 {
+		t.Fatalf("Expected a JPEG page back, got %s (%s), %v", format, trimmed.ContentType, err)
+	}
+	if config.Width > 320 || config.Height > 420 {
+		t.Errorf("Expected the margins cropped, got %dx%d", config.Width, config.Height)
+	}
+
+	// Pages that can't be decoded are kept as they are
+	broken := ImageData{Content: []byte("not an image"), ContentType: "image/jpeg"}
+	if got, err := PreprocessPage(broken, PreprocessOptions{TrimMargins: true}); err != nil || string(got.Content) != "not an image" {
+		t.Errorf("Expected the broken page kept, got %v", err)
+	}
+}
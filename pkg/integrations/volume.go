@@ -0,0 +1,344 @@
+package integrations
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-shiori/go-epub"
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// VolumeBuilder builds a single EPUB out of all the chapters of a volume,
+// with one table of contents entry per chapter
+type VolumeBuilder struct {
+	outputDir    string
+	manga        *data.Manga
+	volume       string
+	chapters     []volumeChapter
+	mangaCover   *CoverData
+	rtl          bool
+	templates    *template.Template
+	onProgress   func(FinalizeProgress)
+	ocr          *OCROptions
+	altText      AltTextMode
+	pathTemplate string
+	tempDir      string // Staged pages, created by the first one
+	staged       int    // Pages staged so far, naming the files
+}
+
+// volumeChapter is a chapter of the volume and its pages staged on disk
+type volumeChapter struct {
+	chapter *data.Chapter
+	pages   []stagedImage
+}
+
+// NewVolumeBuilder creates a new VolumeBuilder writing to outputDir
+func NewVolumeBuilder(outputDir string) *VolumeBuilder {
+	tmpl, err := template.New("chapter").Parse(chapterTemplate)
+	if err != nil {
+		tmpl = nil
+	}
+	return &VolumeBuilder{outputDir: outputDir, templates: tmpl}
+}
+
+// Init starts a new volume of manga
+func (b *VolumeBuilder) Init(manga *data.Manga, volume string) error {
+	if manga == nil {
+		return fmt.Errorf("manga cannot be nil")
+	}
+	if volume == "" {
+		return fmt.Errorf("volume cannot be empty")
+	}
+
+	b.Discard()
+	b.manga = manga
+	b.volume = volume
+	return nil
+}
+
+// Discard drops the volume being built and its staged pages, for when it
+// won't be finished
+func (b *VolumeBuilder) Discard() {
+	if b.tempDir != "" {
+		utils.Temp.Release(b.tempDir)
+	}
+	b.manga = nil
+	b.chapters = nil
+	b.mangaCover = nil
+	b.rtl = false
+	b.tempDir = ""
+	b.staged = 0
+}
+
+// SetMangaCover sets the cover of the volume
+func (b *VolumeBuilder) SetMangaCover(cover CoverData) error {
+	if b.manga == nil {
+		return fmt.Errorf("builder not initialized, call Init first")
+	}
+	if len(cover.Content) == 0 {
+		return fmt.Errorf("cover content is empty")
+	}
+	b.mangaCover = &cover
+	return nil
+}
+
+// SetRightToLeft sets the page progression direction to right-to-left
+func (b *VolumeBuilder) SetRightToLeft() {
+	b.rtl = true
+}
+
+// SetProgressCallback sets a function called as Done stages the images and
+// writes the archive. It is kept across volumes.
+func (b *VolumeBuilder) SetProgressCallback(fn func(FinalizeProgress)) {
+	b.onProgress = fn
+}
+
+// SetOCR recognizes the text of the pages in Done, see OCROptions. nil turns
+// recognition off. It is kept across volumes.
+func (b *VolumeBuilder) SetOCR(options *OCROptions) {
+	b.ocr = options
+}
+
+// SetAltText sets how the ALT text of the pages is written, see AltTextMode.
+// It is kept across volumes.
+func (b *VolumeBuilder) SetAltText(mode AltTextMode) {
+	b.altText = mode
+}
+
+// SetPathTemplate sets where EPUBs are written under the output directory,
+// see VolumePath. It is kept across volumes.
+func (b *VolumeBuilder) SetPathTemplate(template string) {
+	b.pathTemplate = template
+}
+
+// AddChapter adds a chapter and its pages to the volume. Chapters can be
+// added in any order, they are sorted by number when the volume is written.
+func (b *VolumeBuilder) AddChapter(chapter *data.Chapter, images []ImageData) error {
+	if b.manga == nil {
+		return fmt.Errorf("builder not initialized, call Init first")
+	}
+	if chapter == nil {
+		return fmt.Errorf("chapter cannot be nil")
+	}
+	if len(images) == 0 {
+		return fmt.Errorf("chapter %s has no pages", chapter.Number)
+	}
+	for _, image := range images {
+		if len(image.Content) == 0 || image.ContentType == "" {
+			return fmt.Errorf("chapter %s has an empty page", chapter.Number)
+		}
+	}
+	for _, image := range images {
+		if err := b.NextReader(chapter, bytes.NewReader(image.Content), image.ContentType, image.Index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NextReader stages the page at index of chapter, so pages are added as
+// they are downloaded without holding the volume in memory. Chapters can
+// be added in any order, pages are sorted by index when the volume is
+// written.
+func (b *VolumeBuilder) NextReader(chapter *data.Chapter, r io.Reader, contentType string, index int) error {
+	if b.manga == nil {
+		return fmt.Errorf("builder not initialized, call Init first")
+	}
+	if chapter == nil {
+		return fmt.Errorf("chapter cannot be nil")
+	}
+	if contentType == "" {
+		return fmt.Errorf("chapter %s: image content type is required", chapter.Number)
+	}
+	if b.tempDir == "" {
+		dir, err := utils.Temp.MkdirTemp("volume-*")
+		if err != nil {
+			return fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		b.tempDir = dir
+	}
+
+	path := filepath.Join(b.tempDir, fmt.Sprintf("staged_%d%s", b.staged, getExtensionFromContentType(contentType)))
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to stage page %d of chapter %s: %w", index, chapter.Number, err)
+	}
+	written, err := io.Copy(file, r)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && written == 0 {
+		err = fmt.Errorf("image content is empty")
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to stage page %d of chapter %s: %w", index, chapter.Number, err)
+	}
+	b.staged++
+
+	page := stagedImage{path: path, contentType: contentType, order: PageOrder{Page: index}}
+	for i := range b.chapters {
+		if b.chapters[i].chapter == chapter {
+			b.chapters[i].pages = append(b.chapters[i].pages, page)
+			return nil
+		}
+	}
+	b.chapters = append(b.chapters, volumeChapter{chapter: chapter, pages: []stagedImage{page}})
+	return nil
+}
+
+// Done writes the volume EPUB and returns its path
+func (b *VolumeBuilder) Done() (string, error) {
+	if b.manga == nil {
+		return "", fmt.Errorf("builder not initialized, call Init first")
+	}
+	if len(b.chapters) == 0 {
+		return "", fmt.Errorf("no chapters added to volume")
+	}
+
+	// Clean up the staged pages, the fields are reset before returning
+	defer b.Discard()
+	tempDir := b.tempDir
+
+	title := fmt.Sprintf("%s Vol. %s", b.manga.Name, b.volume)
+	e, err := epub.NewEpub(title)
+	if err != nil {
+		return "", fmt.Errorf("failed to create EPub: %w", err)
+	}
+	e.SetIdentifier(BookIdentifier(b.manga, nil))
+	e.SetAuthor("MangaDex")
+	if b.manga.Description != "" {
+		e.SetDescription(b.manga.Description)
+	}
+	e.SetLang("en")
+	if b.rtl {
+		e.SetPpd("rtl")
+	}
+
+	if b.mangaCover == nil {
+		cover, err := GenerateCover(b.manga.Name, "Volume "+b.volume)
+		if err == nil {
+			b.mangaCover = &cover
+		}
+	}
+	if b.mangaCover != nil {
+		coverPath, err := addImageFile(e, tempDir, "manga_cover"+getExtensionFromContentType(b.mangaCover.ContentType), b.mangaCover.Content)
+		if err == nil {
+			e.SetCover(coverPath, "")
+		}
+	}
+
+	sort.SliceStable(b.chapters, func(i, j int) bool {
+		return utils.ChapterSortKey(b.chapters[i].chapter.Number) < utils.ChapterSortKey(b.chapters[j].chapter.Number)
+	})
+
+	total := 0
+	for _, vc := range b.chapters {
+		total += len(vc.pages)
+	}
+	staged, recognized := 0, 0
+	var sidecar []ocrPage
+	for position, vc := range b.chapters {
+		chapterTitle := fmt.Sprintf("Chapter %s", vc.chapter.Number)
+		if vc.chapter.Title != "" {
+			chapterTitle = fmt.Sprintf("%s: %s", chapterTitle, vc.chapter.Title)
+		}
+
+		images := vc.pages
+		sort.SliceStable(images, func(i, j int) bool {
+			return images[i].order.Page < images[j].order.Page
+		})
+
+		var texts []string
+		if b.ocr != nil {
+			texts = recognizeStaged(b.ocr.Recognizer, images, func(p FinalizeProgress) {
+				reportFinalize(b.onProgress, p.Stage, recognized+p.Current, total)
+			})
+			recognized += len(images)
+		}
+
+		pages := make([]PageData, 0, len(images))
+		for i, img := range images {
+			order := PageOrder{Chapter: ChapterKey(vc.chapter.Number, position), Page: img.order.Page}
+			filename := fmt.Sprintf("page_%s%s", order, getExtensionFromContentType(img.contentType))
+			internalPath, err := e.AddImage(img.path, filename)
+			if err != nil {
+				return "", fmt.Errorf("failed to add page %d of chapter %s: %w", img.order.Page, vc.chapter.Number, err)
+			}
+			label := fmt.Sprintf("Chapter %s, page %d", vc.chapter.Number, i+1)
+			alt := b.altText.alt(label, title+", "+chapterTitle, i+1, len(images))
+			if texts != nil {
+				if b.ocr.alt() {
+					alt = pageAlt(alt, texts[i])
+				}
+				sidecar = append(sidecar, ocrPage{Label: label, Text: texts[i]})
+			}
+			pages = append(pages, PageData{
+				Path:  internalPath,
+				Index: i + 1,
+				Alt:   alt,
+			})
+			staged++
+			reportFinalize(b.onProgress, FinalizeStaging, staged, total)
+		}
+
+		if _, err := e.AddSection(b.renderChapter(vc.chapter, chapterTitle, pages), chapterTitle, "", ""); err != nil {
+			return "", fmt.Errorf("failed to add chapter %s: %w", vc.chapter.Number, err)
+		}
+	}
+
+	outputPath := filepath.Join(b.outputDir, VolumePath(b.pathTemplate, b.manga, b.volume)+".epub")
+	if err := utils.CheckOutputPath(outputPath); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	reportFinalize(b.onProgress, FinalizeWriting, total, total)
+	if err := e.Write(outputPath); err != nil {
+		return "", fmt.Errorf("failed to write EPub: %w", err)
+	}
+	if err := addAccessibilityMetadata(outputPath, b.ocr.alt()); err != nil {
+		return "", fmt.Errorf("failed to add accessibility metadata: %w", err)
+	}
+	if b.ocr.sidecar() {
+		if err := writeOCRSidecar(outputPath, title, sidecar); err != nil {
+			return "", err
+		}
+	}
+
+	return outputPath, nil
+}
+
+// renderChapter renders the HTML section of a chapter
+func (b *VolumeBuilder) renderChapter(chapter *data.Chapter, title string, pages []PageData) string {
+	if b.templates != nil {
+		var buf bytes.Buffer
+		err := b.templates.Execute(&buf, ChapterTemplateData{
+			Title:        title,
+			Volume:       chapter.Volume,
+			Number:       chapter.Number,
+			ChapterTitle: chapter.Title,
+			Pages:        pages,
+		})
+		if err == nil {
+			return buf.String()
+		}
+	}
+	return (&EPubBuilder{}).generateSimpleHTML(title, pages)
+}
+
+// addImageFile stages an image in dir and adds it to the EPUB
+func addImageFile(e *epub.Epub, dir, filename string, content []byte) (string, error) {
+	path := filepath.Join(dir, filename)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return "", fmt.Errorf("failed to write temp image: %w", err)
+	}
+	return e.AddImage(path, filename)
+}
@@ -0,0 +1,119 @@
+package integrations
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+func TestVolumeBuilder(t *testing.T) {
+	dir := t.TempDir()
+	builder := NewVolumeBuilder(dir)
+	manga := &data.Manga{ID: "manga-1", Name: "Test Manga"}
+
+	if err := builder.AddChapter(&data.Chapter{Number: "1"}, nil); err == nil {
+		t.Error("AddChapter() before Init should fail")
+	}
+	if err := builder.Init(manga, ""); err == nil {
+		t.Error("Init() without a volume should fail")
+	}
+	if err := builder.Init(manga, "2"); err != nil {
+		t.Fatalf("Init() failed: %v", err)
+	}
+	if _, err := builder.Done(); err == nil {
+		t.Error("Done() without chapters should fail")
+	}
+	builder.Init(manga, "2")
+
+	pages := make([][]byte, 5)
+	for i := range pages {
+		pages[i] = createTestPage(t, 10+i, 20)
+	}
+	page := func(i, index int) ImageData {
+		return ImageData{Content: pages[i], ContentType: "image/png", Index: index}
+	}
+
+	// Added out of order, 10.5 sorts between 10 and 11
+	chapters := []struct {
+		chapter *data.Chapter
+		images  []ImageData
+	}{
+		{&data.Chapter{ID: "ch-11", Number: "11", Volume: "2"}, []ImageData{page(4, 0)}},
+		{&data.Chapter{ID: "ch-10", Number: "10", Volume: "2", Title: "Start"}, []ImageData{page(1, 1), page(0, 0)}},
+		{&data.Chapter{ID: "ch-10.5", Number: "10.5", Volume: "2"}, []ImageData{page(2, 0), page(3, 1)}},
+	}
+	for _, c := range chapters {
+		if err := builder.AddChapter(c.chapter, c.images); err != nil {
+			t.Fatalf("AddChapter(%s) failed: %v", c.chapter.Number, err)
+		}
+	}
+	if err := builder.AddChapter(&data.Chapter{Number: "12"}, nil); err == nil {
+		t.Error("AddChapter() without pages should fail")
+	}
+
+	epubPath, err := builder.Done()
+	if err != nil {
+		t.Fatalf("Done() failed: %v", err)
+	}
+	if want := filepath.Join(dir, "Test Manga_vol_2.epub"); epubPath != want {
+		t.Errorf("Done() path = %q, want %q", epubPath, want)
+	}
+
+	got, err := ReadEPUBPages(epubPath)
+	if err != nil {
+		t.Fatalf("ReadEPUBPages() error = %v", err)
+	}
+	if len(got) != len(pages) {
+		t.Fatalf("expected %d pages, got %d", len(pages), len(got))
+	}
+	for i := range pages {
+		if !bytes.Equal(got[i], pages[i]) {
+			t.Errorf("page %d is out of reading order", i)
+		}
+	}
+
+	toc := readEPUBFile(t, epubPath, "toc.ncx")
+	first := strings.Index(toc, "Chapter 10: Start")
+	second := strings.Index(toc, "Chapter 10.5")
+	third := strings.Index(toc, "Chapter 11")
+	if first < 0 || second < 0 || third < 0 {
+		t.Fatalf("table of contents misses chapters:\n%s", toc)
+	}
+	if !(first < second && second < third) {
+		t.Error("table of contents is not in chapter order")
+	}
+}
+
+// readEPUBFile returns the content of the first file in the EPUB whose name
+// ends with suffix
+func readEPUBFile(t *testing.T, epubPath, suffix string) string {
+	t.Helper()
+	reader, err := zip.OpenReader(epubPath)
+	if err != nil {
+		t.Fatalf("failed to open EPUB: %v", err)
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		if !strings.HasSuffix(file.Name, suffix) {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", file.Name, err)
+		}
+		defer rc.Close()
+		content, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", file.Name, err)
+		}
+		return string(content)
+	}
+	t.Fatalf("no %s in EPUB", suffix)
+	return ""
+}
@@ -0,0 +1,138 @@
+package integrations
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+// WebReaderOptions configures a web reader page
+type WebReaderOptions struct {
+	Title       string
+	RightToLeft bool // Manga reading direction: left arrow and right swipe go forward
+	MaxPages    int  // Pages included, 0 for all, e.g. to share a preview
+}
+
+// webReaderTemplate is a self-contained reader: pages are inlined as data
+// URIs and the script only uses the browser's own APIs
+const webReaderTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+html, body { margin: 0; height: 100%; background: #111; color: #ddd; font-family: sans-serif; }
+#pages { height: 100%; display: flex; align-items: center; justify-content: center; user-select: none; }
+#pages img { display: none; max-width: 100%; max-height: 100%; object-fit: contain; }
+#pages img.current { display: block; }
+body.scroll #pages { display: block; height: auto; }
+body.scroll #pages img { display: block; margin: 0 auto; max-height: none; }
+#bar { position: fixed; bottom: 0; left: 0; right: 0; padding: 6px 12px; background: rgba(0,0,0,.6); font-size: 14px; display: flex; justify-content: space-between; }
+#bar.hidden { display: none; }
+</style>
+</head>
+<body>
+<div id="pages">
+{{- range $i, $page := .Pages}}
+<img src="{{$page}}" alt="Page {{inc $i}}"{{if eq $i 0}} class="current"{{end}}>
+{{- end}}
+</div>
+<div id="bar"><span>{{.Title}}</span><span id="counter"></span><span>←/→ swipe or tap · s: scroll · h: hide</span></div>
+<script>
+(function () {
+  var rtl = {{.RightToLeft}};
+  var pages = document.querySelectorAll("#pages img");
+  var counter = document.getElementById("counter");
+  var current = 0;
+
+  function show(index) {
+    if (index < 0 || index >= pages.length) return;
+    pages[current].classList.remove("current");
+    current = index;
+    pages[current].classList.add("current");
+    counter.textContent = (current + 1) + " / " + pages.length;
+    if (document.body.classList.contains("scroll")) pages[current].scrollIntoView();
+    location.hash = current + 1;
+  }
+  // forward is the reading direction: right to left for manga
+  function turn(forward) { show(current + (forward ? 1 : -1)); }
+
+  document.addEventListener("keydown", function (e) {
+    switch (e.key) {
+      case "ArrowRight": turn(!rtl); break;
+      case "ArrowLeft": turn(rtl); break;
+      case " ": case "PageDown": turn(true); break;
+      case "PageUp": turn(false); break;
+      case "Home": show(0); break;
+      case "End": show(pages.length - 1); break;
+      case "s": document.body.classList.toggle("scroll"); pages[current].scrollIntoView(); break;
+      case "h": document.getElementById("bar").classList.toggle("hidden"); break;
+      default: return;
+    }
+    e.preventDefault();
+  });
+
+  var startX = null;
+  document.addEventListener("touchstart", function (e) { startX = e.touches[0].clientX; });
+  document.addEventListener("touchend", function (e) {
+    if (startX === null || document.body.classList.contains("scroll")) return;
+    var dx = e.changedTouches[0].clientX - startX;
+    startX = null;
+    if (Math.abs(dx) > 40) turn(rtl ? dx > 0 : dx < 0);
+  });
+  document.getElementById("pages").addEventListener("click", function (e) {
+    if (document.body.classList.contains("scroll")) return;
+    var left = e.clientX < window.innerWidth / 2;
+    turn(rtl ? left : !left);
+  });
+
+  show(Math.min(Math.max(parseInt(location.hash.slice(1), 10) - 1 || 0, 0), pages.length - 1));
+})();
+</script>
+</body>
+</html>
+`
+
+var webReader = template.Must(template.New("reader").Funcs(template.FuncMap{
+	"inc": func(i int) int { return i + 1 },
+}).Parse(webReaderTemplate))
+
+// BuildWebReader packages page images into a single HTML file readable in
+// any browser, with keyboard, swipe and tap navigation
+func BuildWebReader(pages [][]byte, options WebReaderOptions) ([]byte, error) {
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no pages provided")
+	}
+	if options.MaxPages > 0 && len(pages) > options.MaxPages {
+		pages = pages[:options.MaxPages]
+	}
+
+	uris := make([]template.URL, len(pages))
+	for i, page := range pages {
+		uris[i] = template.URL("data:" + http.DetectContentType(page) + ";base64," + base64.StdEncoding.EncodeToString(page))
+	}
+
+	var buf bytes.Buffer
+	err := webReader.Execute(&buf, struct {
+		Title       string
+		RightToLeft bool
+		Pages       []template.URL
+	}{options.Title, options.RightToLeft, uris})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render web reader: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// WebReaderFilename returns the default name of the web reader of a chapter
+func WebReaderFilename(manga *data.Manga, chapter *data.Chapter) string {
+	return fmt.Sprintf("%s_%s.html",
+		sanitizeFilename(manga.Name),
+		sanitizeFilename(fmt.Sprintf("ch_%s", chapter.Number)),
+	)
+}
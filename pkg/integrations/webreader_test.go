@@ -0,0 +1,64 @@
+package integrations
+
+import (
+	"encoding/base64"
+	"html"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+func TestBuildWebReader(t *testing.T) {
+	png := createTestPNG()
+	jpeg := createTestJPEG(t, 10, 10)
+
+	out, err := BuildWebReader([][]byte{png, jpeg}, WebReaderOptions{Title: "Manga <Ch. 1>", RightToLeft: true})
+	if err != nil {
+		t.Fatalf("BuildWebReader() error = %v", err)
+	}
+	page := html.UnescapeString(string(out))
+
+	for _, want := range []string{
+		"data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+		"data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(jpeg),
+		`alt="Page 2"`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("Expected the page to contain %.60q", want)
+		}
+	}
+	if !strings.Contains(string(out), "<title>Manga &lt;Ch. 1&gt;</title>") {
+		t.Error("Expected the title to be escaped")
+	}
+	if !regexp.MustCompile(`var rtl = +true *;`).MatchString(page) {
+		t.Error("Expected the reader to go right to left")
+	}
+	if strings.Contains(page, "http://") || strings.Contains(page, "https://") {
+		t.Error("The page should not load anything from the network")
+	}
+
+	t.Run("preview", func(t *testing.T) {
+		out, err := BuildWebReader([][]byte{png, jpeg, png}, WebReaderOptions{MaxPages: 1})
+		if err != nil {
+			t.Fatalf("BuildWebReader() error = %v", err)
+		}
+		if got := strings.Count(string(out), "<img "); got != 1 {
+			t.Errorf("Expected 1 page in the preview, got %d", got)
+		}
+	})
+
+	t.Run("no pages", func(t *testing.T) {
+		if _, err := BuildWebReader(nil, WebReaderOptions{}); err == nil {
+			t.Error("BuildWebReader() should fail without pages")
+		}
+	})
+}
+
+func TestWebReaderFilename(t *testing.T) {
+	got := WebReaderFilename(&data.Manga{Name: "One/Piece"}, &data.Chapter{Number: "1.5"})
+	if got != "One_Piece_ch_1.5.html" {
+		t.Errorf("WebReaderFilename() = %q", got)
+	}
+}
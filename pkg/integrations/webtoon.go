@@ -0,0 +1,143 @@
+package integrations
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"sort"
+
+	"golang.org/x/image/draw"
+)
+
+// WebtoonOptions configures how long vertical strips are sliced into pages
+type WebtoonOptions struct {
+	PageAspect float64 // Height of a slice relative to the strip width
+	Overlap    int     // Pixels repeated at the top of the next slice
+	MinAspect  float64 // Images at least this many times taller than wide are sliced
+	Quality    int     // JPEG quality (1-100) of slices cut from JPEG strips
+}
+
+// DefaultWebtoonOptions returns slices about the shape of an e-reader screen
+func DefaultWebtoonOptions() WebtoonOptions {
+	return WebtoonOptions{
+		PageAspect: 1.5,
+		Overlap:    40,
+		MinAspect:  2.5,
+		Quality:    90,
+	}
+}
+
+// SliceWebtoon cuts the images that are long strips into screen-height pages,
+// keeping the others as they are. Pages are renumbered so slices keep the
+// reading order. Images that fail to decode are kept whole.
+func SliceWebtoon(images []ImageData, options WebtoonOptions) ([]ImageData, error) {
+	if options.PageAspect <= 0 || options.MinAspect <= 0 {
+		return nil, fmt.Errorf("page and minimum aspect must be positive")
+	}
+	if options.Quality <= 0 || options.Quality > 100 {
+		options.Quality = 90
+	}
+
+	sorted := make([]ImageData, len(images))
+	copy(sorted, images)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Order().Less(sorted[j].Order())
+	})
+
+	next := make(map[string]int) // Next index per chapter
+	result := make([]ImageData, 0, len(sorted))
+	for _, img := range sorted {
+		slices, contentType, err := sliceStrip(img.Content, img.ContentType, options)
+		if err != nil || slices == nil {
+			slices, contentType = [][]byte{img.Content}, img.ContentType
+		}
+		for _, content := range slices {
+			result = append(result, ImageData{
+				Content:     content,
+				ContentType: contentType,
+				Index:       next[img.Chapter],
+				Chapter:     img.Chapter,
+			})
+			next[img.Chapter]++
+		}
+	}
+	return result, nil
+}
+
+// SliceWebtoonPages is SliceWebtoon for raw page contents, as read from EPUBs
+func SliceWebtoonPages(pages [][]byte, options WebtoonOptions) ([][]byte, error) {
+	images := make([]ImageData, len(pages))
+	for i, page := range pages {
+		images[i] = ImageData{Content: page, ContentType: http.DetectContentType(page), Index: i}
+	}
+	sliced, err := SliceWebtoon(images, options)
+	if err != nil {
+		return nil, err
+	}
+	result := make([][]byte, len(sliced))
+	for i, img := range sliced {
+		result[i] = img.Content
+	}
+	return result, nil
+}
+
+// sliceStrip cuts a strip into slices and returns them with their content
+// type, or nil when the image is not tall enough to be sliced
+func sliceStrip(content []byte, contentType string, options WebtoonOptions) ([][]byte, string, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	width, height := config.Width, config.Height
+	if width == 0 || float64(height) < float64(width)*options.MinAspect {
+		return nil, "", nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	bounds := img.Bounds()
+
+	sliceHeight := int(float64(width) * options.PageAspect)
+	step := sliceHeight - options.Overlap
+	if step <= 0 {
+		step = sliceHeight
+	}
+
+	var slices [][]byte
+	for y := 0; ; y += step {
+		end := y + sliceHeight
+		// Merge a thin remainder into the last slice instead of a page of its own
+		if end >= height || height-end < sliceHeight/4 {
+			end = height
+		}
+
+		rect := image.Rect(bounds.Min.X, bounds.Min.Y+y, bounds.Max.X, bounds.Min.Y+end)
+		slice := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+		draw.Draw(slice, slice.Bounds(), img, rect.Min, draw.Src)
+
+		var buf bytes.Buffer
+		if contentType == "image/jpeg" {
+			err = jpeg.Encode(&buf, slice, &jpeg.Options{Quality: options.Quality})
+		} else {
+			err = png.Encode(&buf, slice)
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to encode slice: %w", err)
+		}
+		slices = append(slices, buf.Bytes())
+
+		if end == height {
+			break
+		}
+	}
+
+	if contentType != "image/jpeg" {
+		contentType = "image/png"
+	}
+	return slices, contentType, nil
+}
@@ -0,0 +1,91 @@
+package integrations
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+func createTestJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(y), 0, 0, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("failed to encode JPEG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func imageSize(t *testing.T, content []byte) (int, int) {
+	t.Helper()
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("failed to decode slice: %v", err)
+	}
+	return config.Width, config.Height
+}
+
+func TestSliceWebtoon(t *testing.T) {
+	options := WebtoonOptions{PageAspect: 1.5, Overlap: 10, MinAspect: 2.5}
+
+	// A 100x590 strip between two regular pages, given out of order
+	images := []ImageData{
+		{Content: createTestPage(t, 100, 140), ContentType: "image/png", Index: 2},
+		{Content: createTestJPEG(t, 100, 590), ContentType: "image/jpeg", Index: 1},
+		{Content: createTestPage(t, 100, 150), ContentType: "image/png", Index: 0},
+	}
+
+	sliced, err := SliceWebtoon(images, options)
+	if err != nil {
+		t.Fatalf("SliceWebtoon() error = %v", err)
+	}
+
+	// Slices of 150px every 140px: 0-150, 140-290, 280-430 and 420-590, the
+	// 20px left after 570 being merged into the last slice
+	wantHeights := []int{150, 150, 150, 150, 170, 140}
+	if len(sliced) != len(wantHeights) {
+		t.Fatalf("Got %d pages, want %d", len(sliced), len(wantHeights))
+	}
+	for i, img := range sliced {
+		if img.Index != i {
+			t.Errorf("page %d has index %d", i, img.Index)
+		}
+		width, height := imageSize(t, img.Content)
+		if width != 100 || height != wantHeights[i] {
+			t.Errorf("page %d is %dx%d, want 100x%d", i, width, height, wantHeights[i])
+		}
+	}
+	if sliced[1].ContentType != "image/jpeg" || sliced[0].ContentType != "image/png" {
+		t.Errorf("Expected slices to keep the strip format, got %s and %s", sliced[1].ContentType, sliced[0].ContentType)
+	}
+	if !bytes.Equal(sliced[0].Content, images[2].Content) {
+		t.Error("Expected regular pages to be kept as they are")
+	}
+
+	if _, err := SliceWebtoon(images, WebtoonOptions{}); err == nil {
+		t.Error("Expected an error for empty options")
+	}
+}
+
+func TestSliceWebtoonPages(t *testing.T) {
+	pages := [][]byte{createTestPage(t, 50, 300), []byte("not an image")}
+
+	sliced, err := SliceWebtoonPages(pages, DefaultWebtoonOptions())
+	if err != nil {
+		t.Fatalf("SliceWebtoonPages() error = %v", err)
+	}
+	// 300px strip, 75px slices every 35px
+	if len(sliced) != 8 {
+		t.Fatalf("Got %d pages, want 8", len(sliced))
+	}
+	if !bytes.Equal(sliced[7], pages[1]) {
+		t.Error("Expected undecodable pages to be kept whole")
+	}
+}
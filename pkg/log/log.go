@@ -0,0 +1,210 @@
+// Package log is the structured logging of mangas, built on log/slog.
+//
+// Records go to a rotating file under ~/.mangas/logs, so failed downloads
+// can be diagnosed after the fact, and to the console from the warning
+// level up (info with --verbose, debug with --debug).
+package log
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Filename is the name of the current log file in the log directory
+const Filename = "mangas.log"
+
+// Options configures Setup
+type Options struct {
+	Verbose bool      // Show info records on the console
+	Debug   bool      // Record and show debug records
+	Dir     string    // Log directory, DefaultDir when empty
+	Console io.Writer // Console output, os.Stderr when nil
+}
+
+var (
+	mu      sync.Mutex
+	logger  = slog.New(slog.DiscardHandler)
+	file    *rotatingFile
+	handler struct{ file, console slog.Handler }
+)
+
+// DefaultDir returns the log directory, ~/.mangas/logs
+func DefaultDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".mangas", "logs"), nil
+}
+
+// Setup routes the records to the log file and the console. Until it is
+// called, records are discarded. When the log file cannot be opened, the
+// console is still set up and the error returned.
+func Setup(options Options) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	fileLevel, consoleLevel := slog.LevelInfo, slog.LevelWarn
+	if options.Verbose {
+		consoleLevel = slog.LevelInfo
+	}
+	if options.Debug {
+		fileLevel, consoleLevel = slog.LevelDebug, slog.LevelDebug
+	}
+
+	console := options.Console
+	if console == nil {
+		console = os.Stderr
+	}
+	handler.console = slog.NewTextHandler(console, &slog.HandlerOptions{
+		Level: consoleLevel,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			// The console is read as it happens, timestamps are noise there
+			if len(groups) == 0 && attr.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return attr
+		},
+	})
+
+	closeFile()
+	handler.file = nil
+	dir := options.Dir
+	var err error
+	if dir == "" {
+		dir, err = DefaultDir()
+	}
+	if err == nil {
+		file, err = openRotatingFile(filepath.Join(dir, Filename), MaxFileSize, MaxBackups)
+	}
+	if err == nil {
+		handler.file = slog.NewTextHandler(file, &slog.HandlerOptions{Level: fileLevel})
+	}
+
+	apply()
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	return nil
+}
+
+// Quiet stops logging to the console, for the TUI which owns the terminal.
+// Records still go to the log file.
+func Quiet() {
+	mu.Lock()
+	defer mu.Unlock()
+	handler.console = nil
+	apply()
+}
+
+// Close flushes and closes the log file, records are discarded afterwards
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	err := closeFile()
+	handler.file, handler.console = nil, nil
+	apply()
+	return err
+}
+
+// Path returns the current log file, empty when not logging to a file
+func Path() string {
+	mu.Lock()
+	defer mu.Unlock()
+	if file == nil {
+		return ""
+	}
+	return file.path
+}
+
+// Logger returns the logger set up by Setup
+func Logger() *slog.Logger {
+	mu.Lock()
+	defer mu.Unlock()
+	return logger
+}
+
+// With returns a logger adding args to every record, e.g. the manga and
+// chapter of a download
+func With(args ...any) *slog.Logger {
+	return Logger().With(args...)
+}
+
+func Debug(msg string, args ...any) { Logger().Debug(msg, args...) }
+func Info(msg string, args ...any)  { Logger().Info(msg, args...) }
+func Warn(msg string, args ...any)  { Logger().Warn(msg, args...) }
+func Error(msg string, args ...any) { Logger().Error(msg, args...) }
+
+// apply rebuilds the logger from the handlers, mu must be held
+func apply() {
+	var handlers fanout
+	for _, h := range []slog.Handler{handler.file, handler.console} {
+		if h != nil {
+			handlers = append(handlers, h)
+		}
+	}
+	switch len(handlers) {
+	case 0:
+		logger = slog.New(slog.DiscardHandler)
+	case 1:
+		logger = slog.New(handlers[0])
+	default:
+		logger = slog.New(handlers)
+	}
+}
+
+// closeFile closes the log file, mu must be held
+func closeFile() error {
+	if file == nil {
+		return nil
+	}
+	err := file.Close()
+	file = nil
+	return err
+}
+
+// fanout sends records to several handlers
+type fanout []slog.Handler
+
+func (f fanout) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanout) Handle(ctx context.Context, record slog.Record) error {
+	var firstErr error
+	for _, h := range f {
+		if !h.Enabled(ctx, record.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, record.Clone()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (f fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanout, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (f fanout) WithGroup(name string) slog.Handler {
+	handlers := make(fanout, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
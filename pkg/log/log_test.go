@@ -0,0 +1,109 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetupLevels(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     Options
+		wantConsole []string
+		wantFile    []string
+	}{
+		{"default", Options{}, []string{"warn record"}, []string{"info record", "warn record"}},
+		{"verbose", Options{Verbose: true}, []string{"info record", "warn record"}, []string{"info record", "warn record"}},
+		{"debug", Options{Debug: true}, []string{"debug record", "info record"}, []string{"debug record", "info record"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var console bytes.Buffer
+			tt.options.Console = &console
+			tt.options.Dir = t.TempDir()
+			if err := Setup(tt.options); err != nil {
+				t.Fatalf("Setup() error = %v", err)
+			}
+			Debug("debug record")
+			Info("info record")
+			Warn("warn record")
+			Close()
+
+			content, err := os.ReadFile(filepath.Join(tt.options.Dir, Filename))
+			if err != nil {
+				t.Fatalf("Expected a log file: %v", err)
+			}
+			for _, want := range tt.wantConsole {
+				if !strings.Contains(console.String(), want) {
+					t.Errorf("Expected %q on the console, got:\n%s", want, console.String())
+				}
+			}
+			for _, want := range tt.wantFile {
+				if !strings.Contains(string(content), want) {
+					t.Errorf("Expected %q in the log file, got:\n%s", want, content)
+				}
+			}
+			if !tt.options.Verbose && !tt.options.Debug && strings.Contains(console.String(), "info record") {
+				t.Errorf("Info records should not reach the console by default:\n%s", console.String())
+			}
+		})
+	}
+}
+
+func TestWithAndQuiet(t *testing.T) {
+	var console bytes.Buffer
+	dir := t.TempDir()
+	if err := Setup(Options{Console: &console, Dir: dir}); err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	defer Close()
+
+	With("manga_id", "m1", "chapter", "12").Warn("cover download failed")
+	if !strings.Contains(console.String(), "manga_id=m1 chapter=12") {
+		t.Errorf("Expected the record context on the console, got:\n%s", console.String())
+	}
+
+	console.Reset()
+	Quiet()
+	Error("after quiet")
+	if console.Len() != 0 {
+		t.Errorf("Expected nothing on the console after Quiet, got:\n%s", console.String())
+	}
+	content, _ := os.ReadFile(Path())
+	if !strings.Contains(string(content), "after quiet") {
+		t.Errorf("Expected records to still reach the file, got:\n%s", content)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", Filename)
+	f, err := openRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("openRotatingFile() error = %v", err)
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	f.Close()
+
+	want := map[string]string{
+		path:                "fourth\n",
+		backupPath(path, 1): "third\n",
+		backupPath(path, 2): "second\n",
+	}
+	for file, content := range want {
+		got, err := os.ReadFile(file)
+		if err != nil || string(got) != content {
+			t.Errorf("%s = %q, %v, want %q", filepath.Base(file), got, err, content)
+		}
+	}
+	if _, err := os.Stat(backupPath(path, 3)); !os.IsNotExist(err) {
+		t.Error("Expected only 2 backups to be kept")
+	}
+}
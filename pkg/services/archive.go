@@ -0,0 +1,303 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// ArchiveFormat identifies a chapter bundle format
+type ArchiveFormat string
+
+const (
+	ArchiveNone ArchiveFormat = ""
+	ArchiveZip  ArchiveFormat = "zip"
+	ArchiveRar  ArchiveFormat = "rar"
+	Archive7z   ArchiveFormat = "7z"
+)
+
+// ErrArchivePassword is returned when none of the known passwords opens an archive
+var ErrArchivePassword = errors.New("archive is password protected and no password matched")
+
+var archiveSignatures = []struct {
+	format ArchiveFormat
+	magic  []byte
+}{
+	{ArchiveZip, []byte("PK\x03\x04")},
+	{ArchiveRar, []byte("Rar!\x1a\x07")},
+	{Archive7z, []byte("7z\xbc\xaf\x27\x1c")},
+}
+
+// DetectArchive returns the archive format of content, or ArchiveNone when it
+// is not a supported archive
+func DetectArchive(content []byte) ArchiveFormat {
+	for _, sig := range archiveSignatures {
+		if bytes.HasPrefix(content, sig.magic) {
+			return sig.format
+		}
+	}
+	return ArchiveNone
+}
+
+// ExtractArchive unpacks the images of a chapter bundle in reading order.
+// Passwords are tried in order; an empty password is always tried first.
+func ExtractArchive(content []byte, passwords []string) ([]integrations.ImageData, error) {
+	candidates := append([]string{""}, passwords...)
+
+	switch DetectArchive(content) {
+	case ArchiveZip:
+		return extractZip(content, candidates)
+	case ArchiveRar, Archive7z:
+		return extractWithTool(content, candidates)
+	default:
+		return nil, fmt.Errorf("unsupported archive format")
+	}
+}
+
+// extractZip reads a zip bundle, decrypting ZipCrypto entries when needed
+func extractZip(content []byte, passwords []string) ([]integrations.ImageData, error) {
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip: %w", err)
+	}
+
+	files := make(map[string][]byte)
+	for _, f := range reader.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		var fileData []byte
+		if f.Flags&0x1 != 0 {
+			fileData, err = readEncryptedZipFile(f, passwords)
+		} else {
+			fileData, err = readZipFile(f)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+		}
+		files[f.Name] = fileData
+	}
+
+	return archiveImages(files)
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// readEncryptedZipFile decrypts a traditional PKWARE encrypted entry. AES
+// encrypted entries are not supported by archive/zip and fail to open.
+func readEncryptedZipFile(f *zip.File, passwords []string) ([]byte, error) {
+	raw, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := io.ReadAll(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(encrypted) < zipCryptoHeaderLen {
+		return nil, fmt.Errorf("encrypted entry too short")
+	}
+
+	// The last header byte is checked against the CRC, or the modification
+	// time when the CRC is stored in a trailing data descriptor
+	check := byte(f.CRC32 >> 24)
+	if f.Flags&0x8 != 0 {
+		check = byte(f.ModifiedTime >> 8)
+	}
+
+	for _, password := range passwords {
+		cipher := newZipCrypto(password)
+		plain := cipher.decrypt(encrypted)
+		if plain[zipCryptoHeaderLen-1] != check {
+			continue
+		}
+
+		fileData, err := decompressZipEntry(f.Method, plain[zipCryptoHeaderLen:])
+		if err != nil || crc32.ChecksumIEEE(fileData) != f.CRC32 {
+			continue
+		}
+		return fileData, nil
+	}
+
+	return nil, ErrArchivePassword
+}
+
+func decompressZipEntry(method uint16, compressed []byte) ([]byte, error) {
+	switch method {
+	case zip.Store:
+		return compressed, nil
+	case zip.Deflate:
+		rc := flate.NewReader(bytes.NewReader(compressed))
+		defer rc.Close()
+		return io.ReadAll(rc)
+	default:
+		return nil, fmt.Errorf("unsupported compression method %d", method)
+	}
+}
+
+const zipCryptoHeaderLen = 12
+
+// zipCrypto implements the traditional PKWARE stream cipher
+type zipCrypto struct {
+	keys [3]uint32
+}
+
+func newZipCrypto(password string) *zipCrypto {
+	z := &zipCrypto{keys: [3]uint32{0x12345678, 0x23456789, 0x34567890}}
+	for i := 0; i < len(password); i++ {
+		z.update(password[i])
+	}
+	return z
+}
+
+func (z *zipCrypto) update(b byte) {
+	z.keys[0] = crc32.IEEETable[byte(z.keys[0])^b] ^ (z.keys[0] >> 8)
+	z.keys[1] = (z.keys[1]+(z.keys[0]&0xff))*134775813 + 1
+	z.keys[2] = crc32.IEEETable[byte(z.keys[2])^byte(z.keys[1]>>24)] ^ (z.keys[2] >> 8)
+}
+
+func (z *zipCrypto) stream() byte {
+	temp := uint16(z.keys[2]) | 2
+	return byte((uint32(temp) * uint32(temp^1)) >> 8)
+}
+
+func (z *zipCrypto) decrypt(data []byte) []byte {
+	plain := make([]byte, len(data))
+	for i, c := range data {
+		p := c ^ z.stream()
+		z.update(p)
+		plain[i] = p
+	}
+	return plain
+}
+
+// extractWithTool unpacks rar and 7z bundles with an external 7z or unrar
+// binary, since neither format has a pure Go reader in our dependencies
+func extractWithTool(content []byte, passwords []string) ([]integrations.ImageData, error) {
+	tempDir, err := utils.Temp.MkdirTemp("archive-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer utils.Temp.Release(tempDir)
+
+	archivePath := filepath.Join(tempDir, "chapter.archive")
+	if err := os.WriteFile(archivePath, content, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	tool := ""
+	for _, candidate := range []string{"7z", "7za", "unrar"} {
+		if _, err := exec.LookPath(candidate); err == nil {
+			tool = candidate
+			break
+		}
+	}
+	if tool == "" {
+		return nil, fmt.Errorf("no extraction tool available (tried 7z, 7za, unrar)")
+	}
+	if tool == "unrar" && DetectArchive(content) != ArchiveRar {
+		return nil, fmt.Errorf("7z archives need the 7z tool")
+	}
+
+	outDir := filepath.Join(tempDir, "out")
+	for _, password := range passwords {
+		os.RemoveAll(outDir)
+		if err := os.MkdirAll(outDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create output dir: %w", err)
+		}
+
+		var cmd *exec.Cmd
+		if tool == "unrar" {
+			pass := "-p-"
+			if password != "" {
+				pass = "-p" + password
+			}
+			cmd = exec.Command(tool, "x", "-y", pass, archivePath, outDir+string(filepath.Separator))
+		} else {
+			// -p is always passed so 7z never prompts for a password
+			cmd = exec.Command(tool, "x", "-y", "-p"+password, "-o"+outDir, archivePath)
+		}
+		if err := cmd.Run(); err != nil {
+			continue
+		}
+
+		files := make(map[string][]byte)
+		err := filepath.WalkDir(outDir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			// Archives may hold symlinks, reading through them would pull
+			// in files from outside the archive
+			if !entry.Type().IsRegular() {
+				return nil
+			}
+			fileData, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(outDir, path)
+			files[filepath.ToSlash(rel)] = fileData
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read extracted files: %w", err)
+		}
+		return archiveImages(files)
+	}
+
+	return nil, ErrArchivePassword
+}
+
+// archiveImages keeps the image entries of an archive, ordered naturally by
+// name so page2 comes before page10
+func archiveImages(files map[string][]byte) ([]integrations.ImageData, error) {
+	var names []string
+	for name := range files {
+		base := filepath.Base(name)
+		if strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(base, ".") {
+			continue
+		}
+		if strings.HasPrefix(http.DetectContentType(files[name]), "image/") {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("archive contains no images")
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		return utils.NaturalLess(names[i], names[j])
+	})
+
+	images := make([]integrations.ImageData, len(names))
+	for i, name := range names {
+		images[i] = integrations.ImageData{
+			Content:     files[name],
+			ContentType: http.DetectContentType(files[name]),
+			Index:       i,
+		}
+	}
+	return images, nil
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+// createTestZip builds a zip with the given entries, encrypting them with
+// ZipCrypto when password is not empty
+func createTestZip(t *testing.T, entries map[string][]byte, order []string, password string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range order {
+		content := entries[name]
+		if password == "" {
+			f, err := w.Create(name)
+			if err != nil {
+				t.Fatalf("failed to create entry: %v", err)
+			}
+			f.Write(content)
+			continue
+		}
+
+		crc := crc32.ChecksumIEEE(content)
+		header := make([]byte, zipCryptoHeaderLen)
+		header[zipCryptoHeaderLen-1] = byte(crc >> 24)
+
+		cipher := newZipCrypto(password)
+		encrypted := make([]byte, 0, len(header)+len(content))
+		for _, p := range append(header, content...) {
+			encrypted = append(encrypted, p^cipher.stream())
+			cipher.update(p)
+		}
+
+		f, err := w.CreateRaw(&zip.FileHeader{
+			Name:               name,
+			Method:             zip.Store,
+			Flags:              0x1,
+			CRC32:              crc,
+			CompressedSize64:   uint64(len(encrypted)),
+			UncompressedSize64: uint64(len(content)),
+		})
+		if err != nil {
+			t.Fatalf("failed to create raw entry: %v", err)
+		}
+		f.Write(encrypted)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDetectArchive(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		want    ArchiveFormat
+	}{
+		{"zip", []byte("PK\x03\x04rest"), ArchiveZip},
+		{"rar", []byte("Rar!\x1a\x07\x01\x00"), ArchiveRar},
+		{"7z", []byte("7z\xbc\xaf\x27\x1crest"), Archive7z},
+		{"png", createTestPNG(), ArchiveNone},
+		{"empty", nil, ArchiveNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectArchive(tt.content); got != tt.want {
+				t.Errorf("DetectArchive() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractArchive_Zip(t *testing.T) {
+	png := createTestPNG()
+	entries := map[string][]byte{
+		"page10.png":           png,
+		"page2.png":            png,
+		"page1.png":            png,
+		"ComicInfo.xml":        []byte("<ComicInfo></ComicInfo>"),
+		"__MACOSX/._page1.png": png,
+	}
+	order := []string{"page10.png", "page2.png", "page1.png", "ComicInfo.xml", "__MACOSX/._page1.png"}
+
+	t.Run("plain", func(t *testing.T) {
+		images, err := ExtractArchive(createTestZip(t, entries, order, ""), nil)
+		if err != nil {
+			t.Fatalf("ExtractArchive() error = %v", err)
+		}
+		if len(images) != 3 {
+			t.Fatalf("expected 3 images, got %d", len(images))
+		}
+		for i, img := range images {
+			if img.Index != i {
+				t.Errorf("image %d has index %d", i, img.Index)
+			}
+			if img.ContentType != "image/png" {
+				t.Errorf("image %d has content type %q", i, img.ContentType)
+			}
+		}
+	})
+
+	t.Run("encrypted", func(t *testing.T) {
+		archive := createTestZip(t, entries, order, "secret")
+
+		images, err := ExtractArchive(archive, []string{"wrong", "secret"})
+		if err != nil {
+			t.Fatalf("ExtractArchive() error = %v", err)
+		}
+		if len(images) != 3 {
+			t.Errorf("expected 3 images, got %d", len(images))
+		}
+
+		_, err = ExtractArchive(archive, []string{"wrong"})
+		if !errors.Is(err, ErrArchivePassword) {
+			t.Errorf("expected ErrArchivePassword, got %v", err)
+		}
+	})
+}
+
+type mockArchiveSource struct {
+	mockSource
+	passwords []string
+}
+
+func (m *mockArchiveSource) ArchivePasswords(manga *data.Manga, chapter *data.Chapter) []string {
+	return m.passwords
+}
+
+func TestDownloader_DownloadChapterArchive(t *testing.T) {
+	png := createTestPNG()
+	archive := createTestZip(t,
+		map[string][]byte{"001.png": png, "002.png": png},
+		[]string{"001.png", "002.png"},
+		"from-source",
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chapter.zip" {
+			w.Header().Set("Content-Type", "application/zip")
+			w.Write(archive)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(png)
+	}))
+	defer server.Close()
+
+	source := &mockArchiveSource{passwords: []string{"from-source"}}
+	source.getPagesFunc = func(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+		return []string{server.URL + "/chapter.zip", server.URL + "/extra.png"}, nil
+	}
+
+	downloader := NewDownloader(source, &mockRepository{}, t.TempDir())
+	defer downloader.Close()
+
+	manga := &data.Manga{ID: "manga-1", Name: "Test Manga"}
+	chapter := &data.Chapter{ID: "ch-1", MangaID: "manga-1", Number: "1"}
+
+	if err := downloader.DownloadChapter(manga, chapter); err != nil {
+		t.Fatalf("DownloadChapter() error = %v", err)
+	}
+	if !chapter.Downloaded {
+		t.Error("Chapter should be marked as downloaded")
+	}
+
+	// Without the password the archive cannot be opened
+	source.passwords = nil
+	chapter = &data.Chapter{ID: "ch-2", MangaID: "manga-1", Number: "2"}
+	if err := downloader.DownloadChapter(manga, chapter); !errors.Is(err, ErrArchivePassword) {
+		t.Errorf("expected ErrArchivePassword, got %v", err)
+	}
+
+	// Passwords configured on the downloader are tried too
+	downloader.SetArchivePasswords("from-source")
+	chapter = &data.Chapter{ID: "ch-3", MangaID: "manga-1", Number: "3"}
+	if err := downloader.DownloadChapter(manga, chapter); err != nil {
+		t.Errorf("DownloadChapter() with configured password error = %v", err)
+	}
+}
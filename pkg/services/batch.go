@@ -0,0 +1,247 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/sources"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats a batch entry can be downloaded as
+const (
+	BatchEPUB = "epub"
+	BatchCBZ  = "cbz" // The chapter EPUBs, repackaged as CBZ
+)
+
+// BatchEntry is a manga to download, read from a batch file
+type BatchEntry struct {
+	Manga    string `yaml:"manga"`    // Library name or ID, or ID on the source
+	Source   string `yaml:"source"`   // Source of an ID not in the library, the default source when empty
+	Language string `yaml:"language"` // The manga's setting, or "en", when empty
+	Chapters string `yaml:"chapters"` // Chapter selection, see chapterselect; every chapter when empty
+	Format   string `yaml:"format"`   // The manga's setting, or BatchEPUB, when empty
+	Bundle   string `yaml:"bundle"`   // See ParseBundleMode, the manga's setting when empty
+}
+
+// Validate checks the format, bundle mode and source of the entry
+func (e BatchEntry) Validate() error {
+	if e.Manga == "" {
+		return fmt.Errorf("manga is required")
+	}
+	if e.Format != "" && e.Format != BatchEPUB && e.Format != BatchCBZ {
+		return fmt.Errorf("%s: unknown format %q, expected epub or cbz", e.Manga, e.Format)
+	}
+	mode, err := ParseBundleMode(e.Bundle)
+	if err != nil {
+		return fmt.Errorf("%s: %w", e.Manga, err)
+	}
+	if e.Format == BatchCBZ && mode != BundleChapter {
+		return fmt.Errorf("%s: cbz is written per chapter, it can't be bundled by %s", e.Manga, mode)
+	}
+	if e.Source != "" {
+		if _, err := sources.Get(e.Source); err != nil {
+			return fmt.Errorf("%s: %w", e.Manga, err)
+		}
+	}
+	return nil
+}
+
+// batchFile is a batch file listing its entries under "mangas"
+type batchFile struct {
+	Mangas []BatchEntry `yaml:"mangas"`
+}
+
+// ReadBatch reads a batch file: a YAML or JSON list of entries, or an object
+// listing them under "mangas". Unknown fields are errors, so typos don't
+// silently download every chapter.
+func ReadBatch(r io.Reader) ([]BatchEntry, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return nil, fmt.Errorf("invalid batch file: %w", err)
+	}
+	var entries []BatchEntry
+	if len(root.Content) > 0 && root.Content[0].Kind == yaml.MappingNode {
+		var file batchFile
+		err = decodeBatch(content, &file)
+		entries = file.Mangas
+	} else {
+		err = decodeBatch(content, &entries)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid batch file: %w", err)
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no manga in batch file")
+	}
+	for i, entry := range entries {
+		if err := entry.Validate(); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+	}
+	return entries, nil
+}
+
+func decodeBatch(content []byte, v any) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	return decoder.Decode(v)
+}
+
+// BatchOptions tunes RunBatch
+type BatchOptions struct {
+	CBZDir   string                    // Where CBZ entries are written, the working directory when empty
+	Force    bool                      // Downloads chapters already downloaded again
+	OnStart  func(entry BatchEntry)    // Called before an entry is processed
+	OnResult func(result *BatchResult) // Called once an entry is processed
+}
+
+// BatchResult is the outcome of a batch entry
+type BatchResult struct {
+	Entry      BatchEntry
+	Manga      *data.Manga // Nil when it was not found
+	Downloaded int         // Chapters downloaded
+	Failed     int         // Chapters that failed to download or convert
+	Files      []string    // CBZ files written
+	Err        error       // Why the entry was not processed
+}
+
+// BatchReport summarizes RunBatch
+type BatchReport struct {
+	Results     []*BatchResult
+	Downloaded  int
+	Failed      int
+	Errors      int  // Entries not processed
+	Interrupted bool // The batch stopped on an interrupted download, see Downloader.SetContext
+}
+
+// RunBatch downloads the entries one after the other. An entry failing does
+// not stop the batch, its error is recorded in its result; an interrupted
+// download does, the entries left are not in the report.
+func (c *MangaController) RunBatch(entries []BatchEntry, options BatchOptions) *BatchReport {
+	report := &BatchReport{}
+	for _, entry := range entries {
+		if options.OnStart != nil {
+			options.OnStart(entry)
+		}
+		result := c.runBatchEntry(entry, options)
+		if errors.Is(result.Err, context.Canceled) {
+			report.Interrupted = true
+			return report
+		}
+		report.Results = append(report.Results, result)
+		report.Downloaded += result.Downloaded
+		report.Failed += result.Failed
+		if result.Err != nil {
+			report.Errors++
+		}
+		if options.OnResult != nil {
+			options.OnResult(result)
+		}
+	}
+	return report
+}
+
+func (c *MangaController) runBatchEntry(entry BatchEntry, options BatchOptions) *BatchResult {
+	result := &BatchResult{Entry: entry}
+	if result.Err = entry.Validate(); result.Err != nil {
+		return result
+	}
+	if result.Manga, result.Err = c.findBatchManga(entry); result.Err != nil {
+		return result
+	}
+
+	// Fields left empty are taken from the manga's settings
+	settings, err := c.GetMangaSettings(result.Manga.ID)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if entry.Language == "" {
+		entry.Language = settings.Language
+	}
+	if entry.Language == "" {
+		entry.Language = "en"
+	}
+	if entry.Format == "" {
+		entry.Format = settings.Format
+	}
+	if entry.Bundle == "" && entry.Format != BatchCBZ {
+		entry.Bundle = settings.BundleMode
+	}
+	if result.Err = entry.Validate(); result.Err != nil {
+		return result
+	}
+
+	// CBZ entries are converted below, into CBZDir
+	mode, _ := ParseBundleMode(entry.Bundle)
+	chapters, err := c.downloadManga(result.Manga, DownloadOptions{
+		Language:     entry.Language,
+		ChapterRange: entry.Chapters,
+		BundleMode:   mode,
+		Format:       BatchEPUB,
+		Force:        options.Force,
+	})
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	for _, chapter := range chapters {
+		if !chapter.Downloaded {
+			result.Failed++
+			continue
+		}
+		if entry.Format == BatchCBZ {
+			path := filepath.Join(options.CBZDir, integrations.CBZFilename(result.Manga, chapter))
+			if err := integrations.ExportCBZ(result.Manga, chapter, path); err != nil {
+				result.Failed++
+				continue
+			}
+			result.Files = append(result.Files, path)
+		}
+		result.Downloaded++
+	}
+	return result
+}
+
+// findBatchManga finds the manga of an entry by library ID, then library
+// name, then ID on its source
+func (c *MangaController) findBatchManga(entry BatchEntry) (*data.Manga, error) {
+	if manga, err := c.repo.GetManga(entry.Manga); err == nil && manga != nil {
+		return manga, nil
+	}
+	manga, libraryErr := c.FindMangaByName(entry.Manga)
+	if libraryErr == nil {
+		return manga, nil
+	}
+	var ambiguous *AmbiguousMangaError
+	if errors.As(libraryErr, &ambiguous) && ambiguous.Exact {
+		return nil, libraryErr
+	}
+
+	source := c.source
+	if entry.Source != "" {
+		source, _ = sources.Get(entry.Source)
+	}
+	manga, err := source.GetManga(entry.Manga)
+	if err != nil {
+		if ambiguous != nil {
+			return nil, libraryErr
+		}
+		return nil, fmt.Errorf("manga not found: %w", err)
+	}
+	return manga, nil
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+func TestReadBatch(t *testing.T) {
+	yamlBatch := `
+mangas:
+  - manga: Naruto
+    chapters: 1-10
+    format: cbz
+  - manga: a1b2c3
+    source: mangadex
+    language: es
+    chapters: 5
+`
+	entries, err := ReadBatch(strings.NewReader(yamlBatch))
+	if err != nil {
+		t.Fatalf("ReadBatch(yaml) error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].Format != BatchCBZ || entries[1].Language != "es" || entries[1].Chapters != "5" {
+		t.Errorf("ReadBatch(yaml) = %+v", entries)
+	}
+
+	jsonBatch := `[{"manga": "Naruto", "bundle": "volume"}]`
+	entries, err = ReadBatch(strings.NewReader(jsonBatch))
+	if err != nil || len(entries) != 1 || entries[0].Bundle != "volume" {
+		t.Errorf("ReadBatch(json) = %+v, %v", entries, err)
+	}
+
+	invalid := []string{
+		``,
+		`[{"chapters": "1-2"}]`,
+		`[{"manga": "Naruto", "chapter": "1-2"}]`,
+		`[{"manga": "Naruto", "format": "pdf"}]`,
+		`[{"manga": "Naruto", "format": "cbz", "bundle": "volume"}]`,
+		`[{"manga": "Naruto", "source": "nowhere"}]`,
+	}
+	for _, batch := range invalid {
+		if _, err := ReadBatch(strings.NewReader(batch)); err == nil {
+			t.Errorf("ReadBatch(%q) should fail", batch)
+		}
+	}
+}
+
+func TestControllerRunBatch(t *testing.T) {
+	pngData := createTestPNG()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngData)
+	}))
+	defer server.Close()
+
+	source := &mockSource{
+		getMangaFunc: func(id string) (*data.Manga, error) {
+			if id != "m1" {
+				return nil, fmt.Errorf("not found")
+			}
+			return &data.Manga{ID: "m1", Name: "Batch Manga"}, nil
+		},
+		getChaptersFunc: func(manga *data.Manga) ([]*data.Chapter, error) {
+			return []*data.Chapter{
+				{ID: "c1", MangaID: "m1", Number: "1", Language: "en"},
+				{ID: "c2", MangaID: "m1", Number: "2", Language: "en"},
+				{ID: "c3", MangaID: "m1", Number: "3", Language: "en"},
+			}, nil
+		},
+		getPagesFunc: func(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+			return []string{server.URL + "/page.png"}, nil
+		},
+	}
+	controller := &MangaController{source: source, repo: &mockRepository{}, downloadDir: t.TempDir()}
+	controller.downloader = NewDownloader(source, controller.repo, controller.downloadDir)
+	defer controller.Close()
+
+	cbzDir := t.TempDir()
+	var started []string
+	report := controller.RunBatch([]BatchEntry{
+		{Manga: "m1", Chapters: "1-2", Format: BatchCBZ},
+		{Manga: "missing"},
+	}, BatchOptions{CBZDir: cbzDir, OnStart: func(entry BatchEntry) { started = append(started, entry.Manga) }})
+
+	if len(started) != 2 || len(report.Results) != 2 {
+		t.Fatalf("Expected both entries processed, got %v", started)
+	}
+	first := report.Results[0]
+	if first.Err != nil || first.Downloaded != 2 || first.Failed != 0 || len(first.Files) != 2 {
+		t.Fatalf("Unexpected first result: %+v", first)
+	}
+	for _, path := range first.Files {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("CBZ %s not written: %v", path, err)
+		}
+	}
+	if report.Results[1].Err == nil || report.Errors != 1 || report.Downloaded != 2 {
+		t.Errorf("Unexpected report: %+v, %+v", report, report.Results[1])
+	}
+}
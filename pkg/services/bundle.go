@@ -0,0 +1,296 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/log"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// BundleMode selects how downloaded chapters are grouped into EPUBs
+type BundleMode string
+
+const (
+	BundleChapter BundleMode = "chapter" // One EPUB per chapter
+	BundleVolume  BundleMode = "volume"  // One EPUB per volume, chapters without a volume get their own
+)
+
+// ParseBundleMode parses a bundle mode, defaulting to BundleChapter
+func ParseBundleMode(value string) (BundleMode, error) {
+	switch BundleMode(value) {
+	case "", BundleChapter:
+		return BundleChapter, nil
+	case BundleVolume:
+		return BundleVolume, nil
+	}
+	return "", fmt.Errorf("unknown bundle mode %q (use chapter or volume)", value)
+}
+
+// chapterBundle is a set of chapters written to the same EPUB
+type chapterBundle struct {
+	volume   string // Empty for a single chapter
+	chapters []*data.Chapter
+}
+
+// hasVolume reports whether a chapter belongs to a numbered volume
+func hasVolume(chapter *data.Chapter) bool {
+	return chapter.Volume != "" && chapter.Volume != "0"
+}
+
+// bundleChapters groups chapters into EPUBs, keeping the order in which
+// each volume first appears
+func bundleChapters(chapters []*data.Chapter, mode BundleMode) []chapterBundle {
+	var bundles []chapterBundle
+	volumes := make(map[string]int)
+	for _, chapter := range chapters {
+		if mode != BundleVolume || !hasVolume(chapter) {
+			bundles = append(bundles, chapterBundle{chapters: []*data.Chapter{chapter}})
+			continue
+		}
+		if i, ok := volumes[chapter.Volume]; ok {
+			bundles[i].chapters = append(bundles[i].chapters, chapter)
+			continue
+		}
+		volumes[chapter.Volume] = len(bundles)
+		bundles = append(bundles, chapterBundle{volume: chapter.Volume, chapters: []*data.Chapter{chapter}})
+	}
+	return bundles
+}
+
+// ChapterBooks returns the books of downloaded chapters in order, with the
+// number of the first chapter of each. The chapters of a volume, or merged
+// into one, share a book that is listed once.
+func ChapterBooks(chapters []*data.Chapter) (paths, numbers []string) {
+	seen := make(map[string]bool, len(chapters))
+	for _, chapter := range chapters {
+		if seen[chapter.FilePath] {
+			continue
+		}
+		seen[chapter.FilePath] = true
+		paths = append(paths, chapter.FilePath)
+		numbers = append(numbers, chapter.Number)
+	}
+	return paths, numbers
+}
+
+// longStripTag marks webtoons on MangaDex, read left to right unlike manga
+const longStripTag = "Long Strip"
+
+// RightToLeft reports whether the pages of a manga turn right to left, as
+// in Japanese manga. Long strips read left to right.
+func RightToLeft(manga *data.Manga) bool {
+	for _, tag := range manga.Tags {
+		if strings.EqualFold(tag, longStripTag) {
+			return false
+		}
+	}
+	return true
+}
+
+// DownloadVolume downloads the chapters of a volume into a single EPUB with
+// one table of contents entry per chapter. The volume is only written when
+// every chapter downloaded; all of them then point to the same file. The
+// chapters downloaded to the volume's file before are downloaded again with
+// them, so writing it again keeps them.
+func (d *Downloader) DownloadVolume(manga *data.Manga, volume string, chapters []*data.Chapter) error {
+	if manga == nil {
+		return fmt.Errorf("manga cannot be nil")
+	}
+	if len(chapters) == 0 {
+		return fmt.Errorf("no chapters in volume %s", volume)
+	}
+	return d.downloadVolume(manga, volume, chapters, d.settings())
+}
+
+// downloadVolume downloads the chapters of a volume into a single EPUB with
+// settings
+func (d *Downloader) downloadVolume(manga *data.Manga, volume string, chapters []*data.Chapter, settings downloaderSettings) error {
+
+	// Webhooks hear of the chapters asked for, not those written again
+	requested := chapters
+	chapters = d.withVolumeChapters(manga, d.volumePath(manga, volume, settings), chapters)
+	sort.SliceStable(chapters, func(i, j int) bool {
+		return utils.ChapterSortKey(chapters[i].Number) < utils.ChapterSortKey(chapters[j].Number)
+	})
+
+	logger := log.With("manga_id", manga.ID, "manga", manga.Name, "volume", volume)
+	logger.Info("downloading volume", "chapters", len(chapters))
+	fail := func(err error) error {
+		if errors.Is(err, context.Canceled) {
+			logger.Info("volume download interrupted")
+			return err
+		}
+		logger.Error("volume download failed", "err", err)
+		for _, chapter := range chapters {
+			d.sendChapterError(manga, chapter, err)
+		}
+		for _, chapter := range requested {
+			event := newNotifyEvent(EventError, manga, chapter)
+			event.Error = err.Error()
+			settings.notifier.Notify(event)
+		}
+		return err
+	}
+
+	source := d.sourceFor(manga)
+	builder := integrations.NewVolumeBuilder(d.downloadDir)
+	if err := builder.Init(manga, volume); err != nil {
+		return fail(fmt.Errorf("failed to initialize volume builder: %w", err))
+	}
+	defer builder.Discard()
+	builder.SetOCR(settings.ocr)
+	builder.SetAltText(settings.altText)
+	builder.SetPathTemplate(settings.paths.Volume)
+	builder.SetProgressCallback(func(p integrations.FinalizeProgress) {
+		for _, chapter := range chapters {
+			d.sendProgress(DownloadProgress{
+				MangaID:       manga.ID,
+				ChapterID:     chapter.ID,
+				ChapterNumber: chapter.Number,
+				CurrentPage:   p.Current,
+				TotalPages:    p.Total,
+				Status:        "processing",
+				Stage:         string(p.Stage),
+			})
+		}
+	})
+
+	// Download and set manga cover
+	coverURL, err := MangaCoverURL(source, manga)
+	if err == nil && coverURL != "" {
+		coverData, err := d.mangaCover(manga, coverURL)
+		if err == nil {
+			builder.SetMangaCover(coverData)
+		} else {
+			// Non-fatal error, continue even if cover download fails
+			logger.Warn("manga cover download failed", "url", coverURL, "err", err)
+		}
+	}
+
+	totals := make(map[string]int, len(chapters))
+	sums := make(map[string][]string, len(chapters))
+	thumbnails := make(map[string][][]byte, len(chapters))
+	for _, chapter := range chapters {
+		d.rateLimiter.Wait() // Rate limiting
+		d.sendProgress(DownloadProgress{
+			MangaID:       manga.ID,
+			ChapterID:     chapter.ID,
+			ChapterNumber: chapter.Number,
+			Status:        "downloading",
+		})
+
+		chapterSource := d.sourceForChapter(manga, chapter)
+		pages, err := chapterSource.GetPages(manga, chapter)
+		if err != nil {
+			return fail(fmt.Errorf("chapter %s: failed to get pages: %w", chapter.Number, err))
+		}
+		if len(pages) == 0 {
+			return fail(fmt.Errorf("chapter %s: no pages found for chapter", chapter.Number))
+		}
+		totals[chapter.ID] = len(pages)
+
+		d.sendProgress(DownloadProgress{
+			MangaID:       manga.ID,
+			ChapterID:     chapter.ID,
+			ChapterNumber: chapter.Number,
+			TotalPages:    len(pages),
+			Status:        "downloading",
+		})
+
+		// Pages are staged by the builder as they arrive
+		addPage := func(image integrations.ImageData) error {
+			sums[chapter.ID] = append(sums[chapter.ID], integrations.HashPage(image.Content))
+			if settings.thumbnails != nil && len(thumbnails[chapter.ID]) < thumbnailPages {
+				thumbnails[chapter.ID] = append(thumbnails[chapter.ID], image.Content)
+			}
+			if err := builder.NextReader(chapter, bytes.NewReader(image.Content), image.ContentType, image.Index); err != nil {
+				return fmt.Errorf("failed to add page %d to volume: %w", image.Index, err)
+			}
+			return nil
+		}
+		if err := d.streamPages(chapterSource, manga, chapter, pages, settings.pages(addPage)); err != nil {
+			return fail(fmt.Errorf("chapter %s: %w", chapter.Number, err))
+		}
+	}
+
+	// Finalize EPUB, unless interrupted while the pages were downloaded
+	if err := settings.context().Err(); err != nil {
+		return fail(err)
+	}
+	for _, chapter := range chapters {
+		d.sendProgress(DownloadProgress{
+			MangaID:       manga.ID,
+			ChapterID:     chapter.ID,
+			ChapterNumber: chapter.Number,
+			TotalPages:    totals[chapter.ID],
+			Status:        "processing",
+		})
+	}
+
+	epubPath, err := builder.Done()
+	if err != nil {
+		return fail(fmt.Errorf("failed to finalize EPUB: %w", err))
+	}
+
+	for _, chapter := range chapters {
+		chapter.Downloaded = true
+		chapter.FilePath = epubPath
+		if err := d.repo.UpdateChapterStatus(chapter.ID, true, epubPath); err != nil {
+			return fail(fmt.Errorf("failed to update chapter status: %w", err))
+		}
+	}
+	d.recordChecksums(epubPath, chapters, sums)
+	for _, chapter := range chapters {
+		d.saveThumbnails(chapter, thumbnails[chapter.ID])
+	}
+
+	for _, chapter := range chapters {
+		d.sendProgress(DownloadProgress{
+			MangaID:       manga.ID,
+			ChapterID:     chapter.ID,
+			ChapterNumber: chapter.Number,
+			TotalPages:    totals[chapter.ID],
+			Status:        "complete",
+		})
+	}
+	logger.Info("volume downloaded", "chapters", len(chapters), "path", epubPath)
+	for _, chapter := range requested {
+		settings.notifier.Notify(newNotifyEvent(EventChapter, manga, chapter))
+	}
+
+	return nil
+}
+
+// volumePath returns the file the volume of manga is written to
+func (d *Downloader) volumePath(manga *data.Manga, volume string, settings downloaderSettings) string {
+	return filepath.Join(d.downloadDir, integrations.VolumePath(settings.paths.Volume, manga, volume)+".epub")
+}
+
+// withVolumeChapters returns chapters with the library chapters already
+// downloaded to the volume file at path, which writing it again would lose
+func (d *Downloader) withVolumeChapters(manga *data.Manga, path string, chapters []*data.Chapter) []*data.Chapter {
+	chapters = append([]*data.Chapter(nil), chapters...)
+	library, err := d.repo.GetChapters(manga.ID)
+	if err != nil {
+		log.Warn("failed to get library chapters", "manga_id", manga.ID, "err", err)
+		return chapters
+	}
+	selected := make(map[string]bool, len(chapters))
+	for _, chapter := range chapters {
+		selected[chapter.ID] = true
+	}
+	for _, chapter := range library {
+		if chapter.Downloaded && chapter.FilePath == path && !selected[chapter.ID] {
+			chapters = append(chapters, chapter)
+		}
+	}
+	return chapters
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+)
+
+func TestParseBundleMode(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    BundleMode
+		wantErr bool
+	}{
+		{"", BundleChapter, false},
+		{"chapter", BundleChapter, false},
+		{"volume", BundleVolume, false},
+		{"series", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseBundleMode(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseBundleMode(%q) = %q, %v, want %q (error %v)", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestBundleChapters(t *testing.T) {
+	chapters := []*data.Chapter{
+		{ID: "a", Volume: "1"},
+		{ID: "b", Volume: ""},
+		{ID: "c", Volume: "2"},
+		{ID: "d", Volume: "1"},
+		{ID: "e", Volume: "0"},
+	}
+
+	if bundles := bundleChapters(chapters, BundleChapter); len(bundles) != len(chapters) {
+		t.Errorf("chapter mode made %d bundles, want %d", len(bundles), len(chapters))
+	}
+
+	bundles := bundleChapters(chapters, BundleVolume)
+	want := []struct {
+		volume string
+		ids    []string
+	}{
+		{"1", []string{"a", "d"}},
+		{"", []string{"b"}},
+		{"2", []string{"c"}},
+		{"", []string{"e"}},
+	}
+	if len(bundles) != len(want) {
+		t.Fatalf("volume mode made %d bundles, want %d", len(bundles), len(want))
+	}
+	for i, w := range want {
+		if bundles[i].volume != w.volume || len(bundles[i].chapters) != len(w.ids) {
+			t.Errorf("bundle %d = volume %q with %d chapters, want volume %q with %d", i, bundles[i].volume, len(bundles[i].chapters), w.volume, len(w.ids))
+			continue
+		}
+		for j, id := range w.ids {
+			if bundles[i].chapters[j].ID != id {
+				t.Errorf("bundle %d chapter %d = %s, want %s", i, j, bundles[i].chapters[j].ID, id)
+			}
+		}
+	}
+}
+
+func TestDownloader_DownloadMangaBundled(t *testing.T) {
+	pngData := createTestPNG()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngData)
+	}))
+	defer server.Close()
+
+	source := &mockSource{
+		getPagesFunc: func(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+			return []string{server.URL + "/" + chapter.ID + ".png"}, nil
+		},
+	}
+
+	var mu sync.Mutex
+	paths := make(map[string]string)
+	repo := &mockRepository{
+		updateChapterStatusFunc: func(chapterID string, downloaded bool, filePath string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			paths[chapterID] = filePath
+			return nil
+		},
+	}
+
+	dir := t.TempDir()
+	options := DefaultDownloaderOptions()
+	options.RequestsPerSecond = 0
+	downloader := NewDownloaderWithOptions(source, repo, dir, options)
+	defer downloader.Close()
+
+	manga := &data.Manga{ID: "manga-1", Name: "Test Manga"}
+	chapters := []*data.Chapter{
+		{ID: "ch-2", MangaID: "manga-1", Number: "2", Volume: "1"},
+		{ID: "ch-1", MangaID: "manga-1", Number: "1", Volume: "1"},
+		{ID: "ch-3", MangaID: "manga-1", Number: "3"},
+	}
+	if err := downloader.DownloadMangaBundled(manga, chapters, BundleVolume, nil); err != nil {
+		t.Fatalf("DownloadMangaBundled() error = %v", err)
+	}
+	if manga.Status != "completed" {
+		t.Errorf("manga status = %q, want completed", manga.Status)
+	}
+
+	volume := filepath.Join(dir, "Test Manga_vol_1.epub")
+	if paths["ch-1"] != volume || paths["ch-2"] != volume {
+		t.Errorf("volume chapters saved as %q and %q, want %q", paths["ch-1"], paths["ch-2"], volume)
+	}
+	if paths["ch-3"] == "" || paths["ch-3"] == volume {
+		t.Errorf("chapter without volume saved as %q, want its own EPUB", paths["ch-3"])
+	}
+	for _, ch := range chapters {
+		if !ch.Downloaded || ch.FilePath != paths[ch.ID] {
+			t.Errorf("chapter %s not marked as downloaded to %q", ch.Number, paths[ch.ID])
+		}
+	}
+}
+
+// volumeDownloader returns a downloader to dir whose source serves one page
+// per chapter, recording where chapters are saved in paths
+func volumeDownloader(t *testing.T, dir string, library []*data.Chapter) (*Downloader, map[string]string) {
+	t.Helper()
+	pngData := createTestPNG()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngData)
+	}))
+	t.Cleanup(server.Close)
+
+	source := &mockSource{
+		getPagesFunc: func(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+			return []string{server.URL + "/" + chapter.ID + ".png"}, nil
+		},
+	}
+	var mu sync.Mutex
+	paths := make(map[string]string)
+	repo := &mockRepository{
+		getChaptersFunc: func(mangaID string) ([]*data.Chapter, error) {
+			return library, nil
+		},
+		updateChapterStatusFunc: func(chapterID string, downloaded bool, filePath string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			paths[chapterID] = filePath
+			return nil
+		},
+	}
+	options := DefaultDownloaderOptions()
+	options.RequestsPerSecond = 0
+	downloader := NewDownloaderWithOptions(source, repo, dir, options)
+	t.Cleanup(downloader.Close)
+	return downloader, paths
+}
+
+func TestDownloader_DownloadVolumeKeepsEarlierChapters(t *testing.T) {
+	dir := t.TempDir()
+	volume := filepath.Join(dir, "Test Manga_vol_1.epub")
+	earlier := &data.Chapter{ID: "ch-1", MangaID: "manga-1", Number: "1", Volume: "1", Downloaded: true, FilePath: volume}
+	downloader, paths := volumeDownloader(t, dir, []*data.Chapter{
+		earlier,
+		{ID: "ch-9", MangaID: "manga-1", Number: "9", Volume: "2", Downloaded: true, FilePath: filepath.Join(dir, "other.epub")},
+	})
+
+	manga := &data.Manga{ID: "manga-1", Name: "Test Manga"}
+	later := &data.Chapter{ID: "ch-2", MangaID: "manga-1", Number: "2", Volume: "1"}
+	if err := downloader.DownloadMangaBundled(manga, []*data.Chapter{later}, BundleVolume, nil); err != nil {
+		t.Fatalf("DownloadMangaBundled() error = %v", err)
+	}
+
+	if paths["ch-1"] != volume || paths["ch-2"] != volume {
+		t.Errorf("chapters saved as %q and %q, want both in %q", paths["ch-1"], paths["ch-2"], volume)
+	}
+	if _, ok := paths["ch-9"]; ok {
+		t.Error("a chapter of another book was downloaded again")
+	}
+	pages, err := integrations.ReadEPUBPages(volume)
+	if err != nil {
+		t.Fatalf("ReadEPUBPages() error = %v", err)
+	}
+	if len(pages) != 2 {
+		t.Errorf("volume has %d pages, want the earlier chapter's kept", len(pages))
+	}
+}
+
+func TestDownloader_DownloadVolumeSameNumber(t *testing.T) {
+	dir := t.TempDir()
+	downloader, _ := volumeDownloader(t, dir, nil)
+
+	// The same chapter released in two languages
+	manga := &data.Manga{ID: "manga-1", Name: "Test Manga"}
+	chapters := []*data.Chapter{
+		{ID: "ch-en", MangaID: "manga-1", Number: "1", Volume: "1", Language: "en"},
+		{ID: "ch-es", MangaID: "manga-1", Number: "1", Volume: "1", Language: "es"},
+	}
+	if err := downloader.DownloadMangaBundled(manga, chapters, BundleVolume, nil); err != nil {
+		t.Fatalf("DownloadMangaBundled() error = %v", err)
+	}
+	pages, err := integrations.ReadEPUBPages(filepath.Join(dir, "Test Manga_vol_1.epub"))
+	if err != nil {
+		t.Fatalf("ReadEPUBPages() error = %v", err)
+	}
+	if len(pages) != 2 {
+		t.Errorf("volume has %d pages, want one per release", len(pages))
+	}
+}
+
+func TestRightToLeft(t *testing.T) {
+	if !RightToLeft(&data.Manga{Tags: []string{"Action"}}) {
+		t.Error("manga should read right to left")
+	}
+	if RightToLeft(&data.Manga{Tags: []string{"Action", "long strip"}}) {
+		t.Error("long strips should read left to right")
+	}
+}
+
+func TestChapterBooks(t *testing.T) {
+	paths, numbers := ChapterBooks([]*data.Chapter{
+		{Number: "1", FilePath: "vol_1.epub"},
+		{Number: "2", FilePath: "vol_1.epub"},
+		{Number: "3", FilePath: "ch_3.epub"},
+		{Number: "4", FilePath: "vol_1.epub"},
+	})
+	if want := []string{"vol_1.epub", "ch_3.epub"}; !slices.Equal(paths, want) {
+		t.Errorf("ChapterBooks() paths = %v, want %v", paths, want)
+	}
+	if want := []string{"1", "3"}; !slices.Equal(numbers, want) {
+		t.Errorf("ChapterBooks() numbers = %v, want %v", numbers, want)
+	}
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// DefaultDeviceKey is the config key holding the export device profile
+// used when no device is given or detected
+const DefaultDeviceKey = "default_device"
+
+// Config keys holding the path templates of downloads, see PathTemplates
+const (
+	ChapterPathTemplateKey = "chapter_path_template"
+	VolumePathTemplateKey  = "volume_path_template"
+)
+
+// TranscodePagesKey is the config key turning on the transcoding of WebP,
+// AVIF and GIF pages to JPEG when they are downloaded
+const TranscodePagesKey = "transcode_pages"
+
+// OCRLanguagesKey is the config key holding the tesseract languages the
+// text of the pages downloaded from the TUI is recognized in, no text is
+// recognized when unset
+const OCRLanguagesKey = "ocr_languages"
+
+// ReadingSpeedKey is the config key holding how many pages are read per
+// minute, used to estimate reading times
+const ReadingSpeedKey = "reading_speed"
+
+// ReaderCommandKey is the config key holding the program the TUI reader
+// hands chapters to, see ReaderCommand
+const ReaderCommandKey = "reader_command"
+
+// IconsKey is the config key choosing the icons of the CLI and TUI output,
+// see utils.SetIconSet
+const IconsKey = "icons"
+
+// Config keys remapping the keys of the TUI. The TUI validates their
+// values, see SetConfigValidator.
+const (
+	KeyStyleKey    = "key_style"
+	KeyBindingsKey = "keybindings"
+)
+
+// ConfigKey is a setting that can be changed with 'mangas config'
+type ConfigKey struct {
+	Name        string
+	Description string
+	Validate    func(value string) error
+}
+
+// configKeys are the known config keys, by name
+var configKeys = map[string]ConfigKey{
+	DefaultDeviceKey: {
+		Name:        DefaultDeviceKey,
+		Description: "Kindle device profile used when --device is omitted and none is detected",
+		Validate: func(value string) error {
+			if _, ok := integrations.GetDeviceProfile(value); !ok {
+				return fmt.Errorf("unknown device %q, use 'mangas kindle --list-devices' to see available options", value)
+			}
+			return nil
+		},
+	},
+	ChapterPathTemplateKey: {
+		Name:        ChapterPathTemplateKey,
+		Description: "Where chapters are downloaded, e.g. {manga}/{manga} - c{number} (default " + integrations.DefaultChapterPathTemplate + ")",
+		Validate:    integrations.ValidateChapterPathTemplate,
+	},
+	VolumePathTemplateKey: {
+		Name:        VolumePathTemplateKey,
+		Description: "Where volume bundles are downloaded, e.g. {manga}/{manga} - v{volume} (default " + integrations.DefaultVolumePathTemplate + ")",
+		Validate:    integrations.ValidateVolumePathTemplate,
+	},
+	TranscodePagesKey: {
+		Name:        TranscodePagesKey,
+		Description: "Convert WebP, AVIF and GIF pages to JPEG before they go into books, true or false (default false)",
+		Validate: func(value string) error {
+			_, err := strconv.ParseBool(value)
+			return err
+		},
+	},
+	OCRLanguagesKey: {
+		Name:        OCRLanguagesKey,
+		Description: "Recognize the text of the pages downloaded from the TUI with tesseract in these languages, e.g. eng or jpn+eng, as their ALT text (default: unset, no OCR)",
+		Validate: func(value string) error {
+			if strings.TrimSpace(value) == "" {
+				return fmt.Errorf("empty OCR languages")
+			}
+			return nil
+		},
+	},
+	ReadingSpeedKey: {
+		Name:        ReadingSpeedKey,
+		Description: fmt.Sprintf("Pages read per minute, used to estimate the time left to read (default %g)", DefaultReadingSpeed),
+		Validate: func(value string) error {
+			_, err := parseReadingSpeed(value)
+			return err
+		},
+	},
+	ReaderCommandKey: {
+		Name:        ReaderCommandKey,
+		Description: "Program the TUI reader opens chapters with (o), e.g. zathura or 'feh --fullscreen {path}'; the book's path replaces {path} or is appended (default: the system's application for the file)",
+		Validate: func(value string) error {
+			if len(strings.Fields(value)) == 0 {
+				return fmt.Errorf("empty reader command")
+			}
+			return nil
+		},
+	},
+	IconsKey: {
+		Name:        IconsKey,
+		Description: "Icons of the output: emoji, nerd (Nerd Font glyphs), ascii, or auto to detect them from the terminal (default auto, " + utils.IconsEnv + " overrides it)",
+		Validate:    utils.ValidateIconSet,
+	},
+	KeyStyleKey: {
+		Name:        KeyStyleKey,
+		Description: "Navigation keys of the TUI: all (arrows and h/j/k/l), vim or arrows (default all)",
+	},
+	KeyBindingsKey: {
+		Name:        KeyBindingsKey,
+		Description: "TUI keys replacing the defaults, as screen.action=key,key entries, e.g. 'details.download=D library.delete=x,delete'",
+	},
+}
+
+// ConfigKeys returns the known config keys, sorted by name
+func ConfigKeys() []ConfigKey {
+	keys := make([]ConfigKey, 0, len(configKeys))
+	for _, key := range configKeys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+	return keys
+}
+
+// SetConfigValidator sets how the values of a config key are validated,
+// for keys only known to the packages using them, such as the TUI keys
+func SetConfigValidator(name string, validate func(value string) error) {
+	key, ok := configKeys[name]
+	if !ok {
+		panic(fmt.Sprintf("unknown config key %q", name))
+	}
+	key.Validate = validate
+	configKeys[name] = key
+}
+
+func lookupConfigKey(name string) (ConfigKey, error) {
+	key, ok := configKeys[name]
+	if !ok {
+		return ConfigKey{}, fmt.Errorf("unknown config key %q", name)
+	}
+	return key, nil
+}
+
+// GetConfig returns the value of a config key, empty when unset
+func GetConfig(store StateStore, name string) (string, error) {
+	if _, err := lookupConfigKey(name); err != nil {
+		return "", err
+	}
+	return store.GetState(name)
+}
+
+// SetConfig validates and saves the value of a config key
+func SetConfig(store StateStore, name, value string) error {
+	key, err := lookupConfigKey(name)
+	if err != nil {
+		return err
+	}
+	if key.Validate != nil {
+		if err := key.Validate(value); err != nil {
+			return err
+		}
+	}
+	return store.SetState(name, value)
+}
+
+// UnsetConfig clears a config key
+func UnsetConfig(store StateStore, name string) error {
+	if _, err := lookupConfigKey(name); err != nil {
+		return err
+	}
+	return store.SetState(name, "")
+}
+
+// LoadTranscodePages reports whether downloaded pages are transcoded to
+// JPEG, see TranscodePagesKey
+func LoadTranscodePages(store StateStore) (bool, error) {
+	value, err := store.GetState(TranscodePagesKey)
+	if err != nil || value == "" {
+		return false, err
+	}
+	return strconv.ParseBool(value)
+}
+
+// LoadOCR returns the OCR options of the languages set with
+// OCRLanguagesKey, nil when unset
+func LoadOCR(store StateStore) (*integrations.OCROptions, error) {
+	languages, err := store.GetState(OCRLanguagesKey)
+	if err != nil || languages == "" {
+		return nil, err
+	}
+	tesseract, err := integrations.NewTesseract(languages)
+	if err != nil {
+		return nil, err
+	}
+	return &integrations.OCROptions{Recognizer: tesseract, Mode: integrations.OCRAlt}, nil
+}
+
+// LoadReadingSpeed returns the pages read per minute, see ReadingSpeedKey
+func LoadReadingSpeed(store StateStore) (float64, error) {
+	value, err := store.GetState(ReadingSpeedKey)
+	if err != nil || value == "" {
+		return DefaultReadingSpeed, err
+	}
+	return parseReadingSpeed(value)
+}
+
+// LoadIconSet returns the icon set chosen with IconsKey, utils.IconSetAuto
+// when unset
+func LoadIconSet(store StateStore) (string, error) {
+	value, err := store.GetState(IconsKey)
+	if err != nil || value == "" {
+		return utils.IconSetAuto, err
+	}
+	return value, nil
+}
+
+// LoadKeyBindings returns the key style and binding overrides of the TUI,
+// see KeyStyleKey and KeyBindingsKey
+func LoadKeyBindings(store StateStore) (style, overrides string, err error) {
+	if style, err = store.GetState(KeyStyleKey); err != nil {
+		return "", "", err
+	}
+	overrides, err = store.GetState(KeyBindingsKey)
+	return style, overrides, err
+}
+
+// ReaderCommand returns the command opening the book at path with the
+// program of ReaderCommandKey, nil when none is set
+func ReaderCommand(store StateStore, path string) (*exec.Cmd, error) {
+	value, err := store.GetState(ReaderCommandKey)
+	if err != nil {
+		return nil, err
+	}
+	args := strings.Fields(value)
+	if len(args) == 0 {
+		return nil, nil
+	}
+	substituted := false
+	for i, arg := range args {
+		if strings.Contains(arg, "{path}") {
+			args[i] = strings.ReplaceAll(arg, "{path}", path)
+			substituted = true
+		}
+	}
+	if !substituted {
+		args = append(args, path)
+	}
+	return exec.Command(args[0], args[1:]...), nil
+}
+
+func parseReadingSpeed(value string) (float64, error) {
+	speed, err := strconv.ParseFloat(value, 64)
+	if err != nil || speed <= 0 {
+		return 0, fmt.Errorf("invalid reading speed %q, expected a positive number of pages per minute", value)
+	}
+	return speed, nil
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/integrations"
+)
+
+func TestConfig_SetGetUnset(t *testing.T) {
+	store := &mockQuotaStore{state: make(map[string]string)}
+
+	if value, err := GetConfig(store, DefaultDeviceKey); err != nil || value != "" {
+		t.Fatalf("GetConfig() = %q, %v, want unset", value, err)
+	}
+	if err := SetConfig(store, DefaultDeviceKey, "kindle-oasis3"); err != nil {
+		t.Fatalf("SetConfig() error = %v", err)
+	}
+	if value, _ := GetConfig(store, DefaultDeviceKey); value != "kindle-oasis3" {
+		t.Errorf("GetConfig() = %q, want kindle-oasis3", value)
+	}
+	if err := UnsetConfig(store, DefaultDeviceKey); err != nil {
+		t.Fatalf("UnsetConfig() error = %v", err)
+	}
+	if value, _ := GetConfig(store, DefaultDeviceKey); value != "" {
+		t.Errorf("Expected %s unset, got %q", DefaultDeviceKey, value)
+	}
+}
+
+func TestConfig_Validation(t *testing.T) {
+	store := &mockQuotaStore{state: make(map[string]string)}
+
+	if err := SetConfig(store, DefaultDeviceKey, "kindle-unknown"); err == nil {
+		t.Error("Expected an error for an unknown device")
+	}
+	if err := SetConfig(store, "no_such_key", "value"); err == nil {
+		t.Error("Expected an error for an unknown key")
+	}
+	SetConfigValidator(KeyBindingsKey, func(value string) error {
+		return fmt.Errorf("unknown action in %q", value)
+	})
+	defer SetConfigValidator(KeyBindingsKey, nil)
+	if err := SetConfig(store, KeyBindingsKey, "details.no_such_action=x"); err == nil {
+		t.Error("Expected an error from the validator of the key bindings")
+	}
+	if _, err := GetConfig(store, "no_such_key"); err == nil {
+		t.Error("Expected an error reading an unknown key")
+	}
+	if len(store.state) != 0 {
+		t.Errorf("Expected nothing saved, got %v", store.state)
+	}
+}
+
+func TestLoadTranscodePages(t *testing.T) {
+	store := &mockQuotaStore{state: make(map[string]string)}
+
+	if transcode, err := LoadTranscodePages(store); err != nil || transcode {
+		t.Fatalf("LoadTranscodePages() = %v, %v, want off by default", transcode, err)
+	}
+	if err := SetConfig(store, TranscodePagesKey, "maybe"); err == nil {
+		t.Error("Expected an error for a value that isn't a boolean")
+	}
+	if err := SetConfig(store, TranscodePagesKey, "true"); err != nil {
+		t.Fatalf("SetConfig() error = %v", err)
+	}
+	if transcode, err := LoadTranscodePages(store); err != nil || !transcode {
+		t.Errorf("LoadTranscodePages() = %v, %v, want on", transcode, err)
+	}
+}
+
+func TestLoadOCR(t *testing.T) {
+	store := &mockQuotaStore{state: make(map[string]string)}
+
+	if ocr, err := LoadOCR(store); err != nil || ocr != nil {
+		t.Fatalf("LoadOCR() = %v, %v, want no OCR by default", ocr, err)
+	}
+	if err := SetConfig(store, OCRLanguagesKey, " "); err == nil {
+		t.Error("Expected an error for empty languages")
+	}
+	if err := SetConfig(store, OCRLanguagesKey, "jpn+eng"); err != nil {
+		t.Fatalf("SetConfig() error = %v", err)
+	}
+	ocr, err := LoadOCR(store)
+	if _, lookErr := exec.LookPath("tesseract"); lookErr != nil {
+		if err == nil {
+			t.Error("Expected an error without tesseract")
+		}
+		return
+	}
+	if err != nil || ocr.Recognizer.(*integrations.Tesseract).Languages != "jpn+eng" || ocr.Mode != integrations.OCRAlt {
+		t.Errorf("LoadOCR() = %+v, %v", ocr, err)
+	}
+}
+
+func TestReaderCommand(t *testing.T) {
+	store := &mockQuotaStore{state: make(map[string]string)}
+
+	if cmd, err := ReaderCommand(store, "/books/ch1.epub"); err != nil || cmd != nil {
+		t.Fatalf("ReaderCommand() = %v, %v, want none by default", cmd, err)
+	}
+	if err := SetConfig(store, ReaderCommandKey, "  "); err == nil {
+		t.Error("Expected an error for an empty command")
+	}
+
+	SetConfig(store, ReaderCommandKey, "zathura --fork")
+	if cmd, _ := ReaderCommand(store, "/books/ch1.epub"); cmd == nil || strings.Join(cmd.Args, " ") != "zathura --fork /books/ch1.epub" {
+		t.Errorf("ReaderCommand() = %v, want the path appended", cmd)
+	}
+	SetConfig(store, ReaderCommandKey, "viewer --file={path} --fullscreen")
+	if cmd, _ := ReaderCommand(store, "/books/ch1.epub"); cmd == nil || strings.Join(cmd.Args, " ") != "viewer --file=/books/ch1.epub --fullscreen" {
+		t.Errorf("ReaderCommand() = %v, want {path} replaced", cmd)
+	}
+}
@@ -1,79 +1,148 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strconv"
+	"sort"
 	"strings"
+	"unicode"
 
+	"github.com/kerbaras/mangas/pkg/chapterselect"
 	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/log"
 	"github.com/kerbaras/mangas/pkg/sources"
+	"github.com/kerbaras/mangas/pkg/utils"
 )
 
 // MangaController orchestrates interactions between sources, repositories, and downloaders
 // It provides a clean API for both CLI and TUI to use without duplicating logic
+//
+// A MangaController is safe for concurrent use: its fields are set once by
+// the constructor, and the source, repository, downloader and queue it wraps
+// synchronize themselves. Every call returns mangas and chapters of its own;
+// the ones passed to the Download methods are updated as chapters complete,
+// so other goroutines must not read them until the download returns.
 type MangaController struct {
 	source      sources.Source
 	repo        Repository
 	downloader  *Downloader
+	queue       *DownloadQueue
+	links       LinkStore // Manga linked across sources, none when nil
+	settings    SettingsStore // Download settings of each manga, none when nil
 	downloadDir string
+	db          *data.Repository // Database opened for ControllerConfig.DBPath
+}
+
+// DownloadDirEnv overrides the directory books are downloaded to
+const DownloadDirEnv = "MANGAS_DOWNLOAD_DIR"
+
+// DefaultDownloadDir returns where books are downloaded: $MANGAS_DOWNLOAD_DIR
+// or ~/.mangas/downloads
+func DefaultDownloadDir() string {
+	if dir := os.Getenv(DownloadDirEnv); dir != "" {
+		return dir
+	}
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".mangas", "downloads")
 }
 
 // ControllerConfig holds configuration for creating a controller
 type ControllerConfig struct {
-	SourceType  string // "mangadex", etc.
-	DownloadDir string // If empty, uses ~/.mangas/downloads
+	SourceType  string // Registered source name ("mangadex", "comick", ...)
+	DownloadDir string // If empty, uses DefaultDownloadDir
+	DBPath      string // Own library database, if empty uses the shared one (see data.DefaultDBPath)
+	Downloader  *DownloaderOptions // If nil, uses DefaultDownloaderOptions()
 }
 
 // NewMangaController creates a new controller with default configuration
 func NewMangaController() *MangaController {
-	return NewMangaControllerWithConfig(ControllerConfig{
+	// Only opening an own database can fail
+	controller, _ := NewMangaControllerWithConfig(ControllerConfig{
 		SourceType: "mangadex",
 	})
+	return controller
 }
 
-// NewMangaControllerWithConfig creates a controller with custom configuration
-func NewMangaControllerWithConfig(config ControllerConfig) *MangaController {
+// NewMangaControllerWithConfig creates a controller with custom
+// configuration, failing when the database at DBPath can't be opened
+func NewMangaControllerWithConfig(config ControllerConfig) (*MangaController, error) {
 	// Initialize source based on type
-	var source sources.Source
-	switch config.SourceType {
-	case "mangadex", "":
-		source = sources.NewMangaDex()
-	default:
-		source = sources.NewMangaDex() // Default fallback
+	source, err := sources.Get(config.SourceType)
+	if err != nil {
+		source, _ = sources.Get(sources.DefaultSource) // Default fallback
 	}
 
 	// Initialize repository
-	repo := data.NewDuckDBRepository()
+	var repo, db *data.Repository
+	if config.DBPath != "" {
+		if db, err = data.OpenDuckDBRepository(config.DBPath); err != nil {
+			return nil, fmt.Errorf("failed to open library %s: %w", config.DBPath, err)
+		}
+		repo = db
+	} else {
+		repo = data.NewDuckDBRepository()
+	}
 
 	// Determine download directory
 	downloadDir := config.DownloadDir
 	if downloadDir == "" {
-		homeDir, _ := os.UserHomeDir()
-		downloadDir = filepath.Join(homeDir, ".mangas", "downloads")
+		downloadDir = DefaultDownloadDir()
 	}
 
 	// Ensure download directory exists
 	os.MkdirAll(downloadDir, 0755)
 
 	// Initialize downloader
-	downloader := NewDownloader(source, repo, downloadDir)
+	options := DefaultDownloaderOptions()
+	if config.Downloader != nil {
+		options = *config.Downloader
+	}
+	downloader := NewDownloaderWithOptions(source, repo, downloadDir, options)
+	downloader.SetChecksumStore(repo)
+	downloader.SetMangaSettings(repo)
+	downloader.SetQuotaStore(repo)
+	downloader.SetThumbnailCache(NewThumbnailCache(DefaultThumbnailDir()))
+	if templates, err := LoadPathTemplates(repo); err == nil {
+		downloader.SetPathTemplates(templates)
+	} else {
+		log.Warn("failed to load path templates", "err", err)
+	}
+	if transcode, err := LoadTranscodePages(repo); err == nil {
+		downloader.SetTranscode(transcode)
+	} else {
+		log.Warn("failed to load transcode_pages", "err", err)
+	}
+	if hooks, err := LoadWebhooks(repo); err == nil {
+		downloader.SetNotifier(NewNotifier(hooks))
+	} else {
+		log.Warn("failed to load webhooks", "err", err)
+	}
 
 	return &MangaController{
 		source:      source,
 		repo:        repo,
 		downloader:  downloader,
+		queue:       NewDownloadQueue(repo, repo, downloader),
+		links:       repo,
+		settings:    repo,
 		downloadDir: downloadDir,
-	}
+		db:          db,
+	}, nil
 }
 
-// SearchManga searches for manga by query string
-func (c *MangaController) SearchManga(query string) ([]*data.Manga, error) {
+// SearchManga searches for manga by query string, narrowed down by options
+func (c *MangaController) SearchManga(query string, options sources.SearchOptions) ([]*data.Manga, error) {
 	if query == "" {
 		return nil, fmt.Errorf("search query cannot be empty")
 	}
-	return c.source.Search(query)
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	return c.source.Search(query, options)
 }
 
 // GetManga retrieves a manga by ID from source
@@ -92,24 +161,112 @@ func (c *MangaController) GetMangaFromLibrary(mangaID string) (*data.Manga, erro
 	return c.repo.GetManga(mangaID)
 }
 
-// FindMangaByName searches for a manga in the library by name (case-insensitive)
+// ErrMangaNotFound is returned by FindMangaByName when no manga of the
+// library has the name or a similar one
+var ErrMangaNotFound = errors.New("manga not found in library")
+
+// AmbiguousMangaError is returned by FindMangaByName when the name does not
+// identify a single manga: several share it, or only similar names exist
+type AmbiguousMangaError struct {
+	Name       string
+	Candidates []*data.Manga
+	Exact      bool // Candidates all have the name, they only differ by source or ID
+}
+
+func (e *AmbiguousMangaError) Error() string {
+	names := make([]string, len(e.Candidates))
+	for i, m := range e.Candidates {
+		names[i] = fmt.Sprintf("%s (%s, %s)", m.Name, m.Source, m.ID)
+	}
+	if e.Exact {
+		return fmt.Sprintf("%d manga in the library are named %s: %s", len(e.Candidates), e.Name, strings.Join(names, "; "))
+	}
+	return fmt.Sprintf("manga not found in library: %s, did you mean: %s", e.Name, strings.Join(names, "; "))
+}
+
+// maxFuzzyCandidates caps the similar names suggested by FindMangaCandidates
+const maxFuzzyCandidates = 10
+
+// FindMangaByName searches for a manga in the library by name (case-insensitive).
+// When several manga have the name, or none has it but some have a similar
+// one, it returns an *AmbiguousMangaError listing them.
 func (c *MangaController) FindMangaByName(name string) (*data.Manga, error) {
+	candidates, exact, err := c.FindMangaCandidates(name)
+	if err != nil {
+		return nil, err
+	}
+	if exact && len(candidates) == 1 {
+		return candidates[0], nil
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrMangaNotFound, name)
+	}
+	return nil, &AmbiguousMangaError{Name: name, Candidates: candidates, Exact: exact}
+}
+
+// FindMangaCandidates returns the library manga named name, case-insensitive,
+// with exact set. Without any, it returns the manga whose names contain name,
+// or all its words, instead.
+func (c *MangaController) FindMangaCandidates(name string) (candidates []*data.Manga, exact bool, err error) {
 	if name == "" {
-		return nil, fmt.Errorf("manga name cannot be empty")
+		return nil, false, fmt.Errorf("manga name cannot be empty")
 	}
 
 	mangas, err := c.repo.ListMangas()
 	if err != nil {
-		return nil, fmt.Errorf("failed to list mangas: %w", err)
+		return nil, false, fmt.Errorf("failed to list mangas: %w", err)
 	}
 
 	for _, m := range mangas {
 		if strings.EqualFold(m.Name, name) {
-			return m, nil
+			candidates = append(candidates, m)
 		}
 	}
+	if len(candidates) > 0 {
+		return candidates, true, nil
+	}
 
-	return nil, fmt.Errorf("manga not found in library: %s", name)
+	for _, m := range mangas {
+		if similarName(m.Name, name) {
+			candidates = append(candidates, m)
+			if len(candidates) == maxFuzzyCandidates {
+				break
+			}
+		}
+	}
+	return candidates, false, nil
+}
+
+// similarName reports whether a manga name contains the query, ignoring case
+// and punctuation, or every word of it
+func similarName(name, query string) bool {
+	nameWords, queryWords := nameWords(name), nameWords(query)
+	if len(queryWords) == 0 {
+		return false
+	}
+	if strings.Contains(strings.Join(nameWords, ""), strings.Join(queryWords, "")) {
+		return true
+	}
+	for _, word := range queryWords {
+		found := false
+		for _, candidate := range nameWords {
+			if strings.HasPrefix(candidate, word) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// nameWords splits a name into lower case words of letters and digits
+func nameWords(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
 }
 
 // GetChapters retrieves chapters for a manga from source
@@ -117,7 +274,7 @@ func (c *MangaController) GetChapters(manga *data.Manga) ([]*data.Chapter, error
 	if manga == nil {
 		return nil, fmt.Errorf("manga cannot be nil")
 	}
-	return c.source.GetChapters(manga)
+	return c.sourceFor(manga).GetChapters(manga)
 }
 
 // GetChaptersFromLibrary retrieves chapters for a manga from the local library
@@ -140,7 +297,7 @@ func (c *MangaController) AddMangaToLibrary(manga *data.Manga) error {
 	}
 
 	// Get and save chapters
-	chapters, err := c.source.GetChapters(manga)
+	chapters, err := c.sourceFor(manga).GetChapters(manga)
 	if err != nil {
 		return fmt.Errorf("failed to get chapters: %w", err)
 	}
@@ -149,13 +306,47 @@ func (c *MangaController) AddMangaToLibrary(manga *data.Manga) error {
 		chapter.MangaID = manga.ID
 		if err := c.repo.SaveChapter(chapter); err != nil {
 			// Log but don't fail on individual chapter errors
-			continue
+			log.Warn("failed to save chapter", "manga_id", manga.ID, "chapter", chapter.Number, "err", err)
 		}
 	}
 
+	// Related series are nice to have, don't fail the add without them
+	if _, err := c.RefreshRelations(manga); err != nil {
+		log.Warn("failed to refresh related series", "manga_id", manga.ID, "err", err)
+	}
+
 	return nil
 }
 
+// RefreshRelations fetches the related series of a manga from its source and stores them
+func (c *MangaController) RefreshRelations(manga *data.Manga) ([]*data.Relation, error) {
+	if manga == nil {
+		return nil, fmt.Errorf("manga cannot be nil")
+	}
+
+	provider, ok := c.sourceFor(manga).(sources.RelationProvider)
+	if !ok {
+		return nil, nil
+	}
+
+	relations, err := provider.GetRelations(manga)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get relations: %w", err)
+	}
+	if err := c.repo.SaveRelations(manga.ID, relations); err != nil {
+		return nil, fmt.Errorf("failed to save relations: %w", err)
+	}
+	return relations, nil
+}
+
+// GetRelations retrieves the stored related series of a manga
+func (c *MangaController) GetRelations(mangaID string) ([]*data.Relation, error) {
+	if mangaID == "" {
+		return nil, fmt.Errorf("manga ID cannot be empty")
+	}
+	return c.repo.GetRelations(mangaID)
+}
+
 // ListLibraryMangas lists all mangas in the library
 func (c *MangaController) ListLibraryMangas() ([]*data.Manga, error) {
 	return c.repo.ListMangas()
@@ -169,35 +360,323 @@ func (c *MangaController) DeleteMangaFromLibrary(mangaID string) error {
 	return c.repo.DeleteManga(mangaID)
 }
 
+// SyncResult reports the chapters found for a library manga during a sync
+type SyncResult struct {
+	Manga       *data.Manga
+	NewChapters []*data.Chapter
+	Err         error // Set when the manga could not be synced
+}
+
+// SyncLibrary re-fetches the chapters of every manga in the library and stores
+// the ones not seen before. A failing manga is reported in its result and does
+// not stop the others.
+func (c *MangaController) SyncLibrary() ([]*SyncResult, error) {
+	mangas, err := c.repo.ListMangas()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mangas: %w", err)
+	}
+
+	results := make([]*SyncResult, 0, len(mangas))
+	for _, manga := range mangas {
+		result, err := c.SyncManga(manga)
+		if err != nil {
+			result = &SyncResult{Manga: manga, Err: err}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// SyncManga re-fetches the chapters of a library manga and stores the new ones
+func (c *MangaController) SyncManga(manga *data.Manga) (*SyncResult, error) {
+	if manga == nil {
+		return nil, fmt.Errorf("manga cannot be nil")
+	}
+
+	known, err := c.repo.GetChapters(manga.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get library chapters: %w", err)
+	}
+	knownIDs := make(map[string]bool, len(known))
+	for _, ch := range known {
+		knownIDs[ch.ID] = true
+	}
+
+	remote, err := c.sourceFor(manga).GetChapters(manga)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chapters: %w", err)
+	}
+	remote, err = WithLinkedChapters(c.links, manga, remote)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SyncResult{Manga: manga}
+	for _, chapter := range remote {
+		if knownIDs[chapter.ID] {
+			continue
+		}
+		chapter.MangaID = manga.ID
+		if err := c.repo.SaveChapter(chapter); err != nil {
+			return nil, fmt.Errorf("failed to save chapter %s: %w", chapter.Number, err)
+		}
+		result.NewChapters = append(result.NewChapters, chapter)
+	}
+	return result, nil
+}
+
+// Discover recommends popular manga from the source that share tags with the
+// most-read entries in the library, excluding titles already in the library
+func (c *MangaController) Discover(limit int) ([]*data.Manga, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive")
+	}
+
+	recommender, ok := c.source.(sources.Recommender)
+	if !ok {
+		return nil, fmt.Errorf("source does not support recommendations")
+	}
+
+	library, err := c.repo.ListMangas()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mangas: %w", err)
+	}
+	if len(library) == 0 {
+		return nil, fmt.Errorf("library is empty, add some manga first")
+	}
+
+	tags, err := c.topLibraryTags(library, discoverSeedCount, discoverTagCount)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("no tags found for library entries")
+	}
+
+	inLibrary := make(map[string]bool, len(library))
+	for _, m := range library {
+		inLibrary[m.ID] = true
+	}
+
+	candidates, err := recommender.Popular(tags, limit+len(library))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch popular manga: %w", err)
+	}
+
+	var recommendations []*data.Manga
+	for _, m := range candidates {
+		if inLibrary[m.ID] {
+			continue
+		}
+		recommendations = append(recommendations, m)
+		if len(recommendations) == limit {
+			break
+		}
+	}
+
+	return recommendations, nil
+}
+
+const (
+	discoverSeedCount = 5 // Library entries used as recommendation seeds
+	discoverTagCount  = 3 // Most common tags used to query the source
+)
+
+// topLibraryTags returns the most common tags among the most-read library entries.
+// Entries are ranked by the number of downloaded chapters.
+func (c *MangaController) topLibraryTags(library []*data.Manga, seeds, limit int) ([]string, error) {
+	type ranked struct {
+		manga      *data.Manga
+		downloaded int
+	}
+
+	entries := make([]ranked, 0, len(library))
+	for _, m := range library {
+		chapters, err := c.repo.GetChapters(m.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get chapters: %w", err)
+		}
+		downloaded := 0
+		for _, ch := range chapters {
+			if ch.Downloaded {
+				downloaded++
+			}
+		}
+		entries = append(entries, ranked{manga: m, downloaded: downloaded})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].downloaded > entries[j].downloaded
+	})
+	if len(entries) > seeds {
+		entries = entries[:seeds]
+	}
+
+	counts := make(map[string]int)
+	var order []string
+	for _, entry := range entries {
+		tags := entry.manga.Tags
+		if len(tags) == 0 {
+			// Library entries don't carry tags, ask the source
+			remote, err := c.sourceFor(entry.manga).GetManga(entry.manga.ID)
+			if err != nil || remote == nil {
+				continue
+			}
+			tags = remote.Tags
+		}
+		for _, tag := range tags {
+			if counts[tag] == 0 {
+				order = append(order, tag)
+			}
+			counts[tag]++
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return counts[order[i]] > counts[order[j]]
+	})
+	if len(order) > limit {
+		order = order[:limit]
+	}
+	return order, nil
+}
+
 // DownloadOptions specifies options for downloading manga chapters
 type DownloadOptions struct {
 	Language      string   // Language code (e.g., "en", "ja")
-	ChapterRange  string   // Chapter range (e.g., "1-10")
+	FallbackLanguages []string // Languages used, in order, for chapter numbers missing in Language
+	ChapterRange  string   // Chapter selection (e.g., "1-10,15", "latest:5"), see chapterselect
 	ChapterIDs    []string // Specific chapter IDs to download
+	Group         string   // Scanlation group preferred when several translated a chapter
 	ProgressChan  chan<- DownloadProgress // Optional progress channel
+	BundleMode    BundleMode               // How chapters are grouped into EPUBs, per chapter by default
+	Format        string                   // Chapters are written as BatchEPUB or BatchCBZ, EPUB by default
+	Webtoon       *integrations.WebtoonOptions // Slices long strips into pages when set
+	OCR           *integrations.OCROptions     // Recognizes the text of the pages when set
+	AltText       integrations.AltTextMode     // How the ALT text of the pages is worded
+	Force         bool                         // Downloads chapters already downloaded again
 }
 
-// DownloadManga downloads manga chapters with the specified options
+// DownloadManga downloads manga chapters with the specified options. The
+// options left empty are taken from the manga's settings.
 func (c *MangaController) DownloadManga(manga *data.Manga, options DownloadOptions) error {
+	_, err := c.downloadManga(manga, options)
+	return err
+}
+
+// downloadManga is DownloadManga returning the chapters it tried to
+// download, marked Downloaded when they were
+func (c *MangaController) downloadManga(manga *data.Manga, options DownloadOptions) ([]*data.Chapter, error) {
 	if manga == nil {
-		return fmt.Errorf("manga cannot be nil")
+		return nil, fmt.Errorf("manga cannot be nil")
+	}
+	if _, err := chapterselect.Parse(options.ChapterRange); err != nil {
+		return nil, err
+	}
+	options, err := c.withMangaSettings(manga, options)
+	if err != nil {
+		return nil, err
+	}
+	if options.Format == BatchCBZ && options.BundleMode == BundleVolume {
+		return nil, fmt.Errorf("cbz is written per chapter, it can't be bundled by %s", options.BundleMode)
 	}
 
-	// Get all chapters
-	chapters, err := c.source.GetChapters(manga)
+	// Get the chapters, in the language when the source can filter them
+	var languages []string
+	if options.Language != "" {
+		languages = append([]string{options.Language}, options.FallbackLanguages...)
+	}
+	chapters, err := sources.GetChaptersIn(c.sourceFor(manga), manga, languages...)
 	if err != nil {
-		return fmt.Errorf("failed to get chapters: %w", err)
+		return nil, fmt.Errorf("failed to get chapters: %w", err)
+	}
+	chapters, err = WithLinkedChapters(c.links, manga, chapters, languages...)
+	if err != nil {
+		return nil, err
 	}
 
 	// Filter chapters based on options
 	filteredChapters := c.filterChapters(chapters, options)
 
 	if len(filteredChapters) == 0 {
-		return fmt.Errorf("no chapters to download after applying filters")
+		return nil, fmt.Errorf("no chapters to download after applying filters")
 	}
 
 	// Start download
-	return c.downloader.DownloadManga(manga, filteredChapters)
+	download := &DownloadSettings{
+		Webtoon: options.Webtoon,
+		OCR:     options.OCR,
+		AltText: options.AltText,
+		Force:   options.Force,
+		Format:  options.Format,
+	}
+	if err := c.downloader.DownloadMangaBundled(manga, filteredChapters, options.BundleMode, download); err != nil {
+		return filteredChapters, err
+	}
+	return filteredChapters, nil
+}
+
+// QueueDownloads stores the source's new chapters of a library manga and adds
+// the ones matching options that are not downloaded yet to the download
+// queue. A language left empty is taken from the manga's settings.
+func (c *MangaController) QueueDownloads(manga *data.Manga, options DownloadOptions) ([]*data.Chapter, error) {
+	if manga == nil {
+		return nil, fmt.Errorf("manga cannot be nil")
+	}
+	if _, err := chapterselect.Parse(options.ChapterRange); err != nil {
+		return nil, err
+	}
+	options, err := c.withMangaSettings(manga, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := c.SyncManga(manga); err != nil {
+		return nil, err
+	}
+
+	chapters, err := c.repo.GetChapters(manga.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get library chapters: %w", err)
+	}
+
+	var queued []*data.Chapter
+	for _, ch := range c.filterChapters(preferOwnChapters(chapters), options) {
+		if !ch.Downloaded {
+			queued = append(queued, ch)
+		}
+	}
+	if len(queued) == 0 {
+		return nil, fmt.Errorf("no chapters to queue after applying filters")
+	}
+
+	if err := c.queue.Add(manga, queued...); err != nil {
+		return nil, err
+	}
+	return queued, nil
+}
+
+// SetContext stops the downloads of the controller when ctx is cancelled,
+// see Downloader.SetContext
+func (c *MangaController) SetContext(ctx context.Context) {
+	c.downloader.SetContext(ctx)
+}
+
+// SetAltText sets how the ALT text of the pages of the queue's downloads is
+// worded, see Downloader.SetAltText. DownloadManga takes it from its options.
+func (c *MangaController) SetAltText(mode integrations.AltTextMode) {
+	c.downloader.SetAltText(mode)
+}
+
+// SetOCR recognizes the text of the pages of the queue's downloads, see
+// Downloader.SetOCR. DownloadManga takes it from its options.
+func (c *MangaController) SetOCR(options *integrations.OCROptions) {
+	c.downloader.SetOCR(options)
+}
+
+// OnEviction reports the chapters the controller's downloads evicted to get
+// under the storage quota, see Downloader.OnEviction
+func (c *MangaController) OnEviction(report func(*EvictionReport, error)) {
+	c.downloader.OnEviction(report)
 }
 
 // DownloadChapter downloads a single chapter
@@ -211,11 +690,49 @@ func (c *MangaController) DownloadChapter(manga *data.Manga, chapter *data.Chapt
 	return c.downloader.DownloadChapter(manga, chapter)
 }
 
+// DownloadChapterPages builds the book of a chapter from a list of page
+// image URLs or local files instead of its source's pages
+func (c *MangaController) DownloadChapterPages(manga *data.Manga, chapter *data.Chapter, pages []string) error {
+	return c.downloader.DownloadChapterPages(manga, chapter, pages)
+}
+
+// ChapterPages returns the page images of a chapter, read from its EPUB when
+// downloaded or fetched from the source otherwise
+func (c *MangaController) ChapterPages(manga *data.Manga, chapter *data.Chapter) ([][]byte, error) {
+	if manga == nil {
+		return nil, fmt.Errorf("manga cannot be nil")
+	}
+	if chapter == nil {
+		return nil, fmt.Errorf("chapter cannot be nil")
+	}
+
+	if chapter.Downloaded && chapter.FilePath != "" {
+		if pages, err := integrations.ReadEPUBPages(chapter.FilePath); err == nil {
+			return pages, nil
+		}
+		// EPUB moved or unreadable, fall back to the source
+	}
+
+	return c.downloader.FetchPages(manga, chapter)
+}
+
 // GetProgressChannel returns the channel for receiving download progress updates
 func (c *MangaController) GetProgressChannel() <-chan DownloadProgress {
 	return c.downloader.GetProgressChannel()
 }
 
+// SubscribeProgress returns an independent channel of download progress
+// updates and a function to unsubscribe
+func (c *MangaController) SubscribeProgress() (<-chan DownloadProgress, func()) {
+	return c.downloader.SubscribeProgress()
+}
+
+// Queue returns the persistent download queue, processed with the
+// controller's downloader
+func (c *MangaController) Queue() *DownloadQueue {
+	return c.queue
+}
+
 // GetDownloadDirectory returns the configured download directory
 func (c *MangaController) GetDownloadDirectory() string {
 	return c.downloadDir
@@ -224,26 +741,34 @@ func (c *MangaController) GetDownloadDirectory() string {
 // Close cleans up controller resources
 func (c *MangaController) Close() error {
 	c.downloader.Close()
+	if c.db != nil {
+		return c.db.Close()
+	}
 	return nil
 }
 
 // Helper methods
 
+// sourceFor returns the source a manga belongs to, so library entries added
+// from other sources are routed back to the right implementation
+func (c *MangaController) sourceFor(manga *data.Manga) sources.Source {
+	return sources.ForManga(manga, c.source)
+}
+
 // filterChapters filters chapters based on download options
 func (c *MangaController) filterChapters(chapters []*data.Chapter, options DownloadOptions) []*data.Chapter {
 	var filtered []*data.Chapter
 
 	// Filter by language
 	if options.Language != "" {
-		for _, ch := range chapters {
-			if ch.Language == options.Language {
-				filtered = append(filtered, ch)
-			}
-		}
+		filtered, _ = ApplyLanguageFallback(chapters, options.Language, options.FallbackLanguages)
 	} else {
 		filtered = chapters
 	}
 
+	// Keep the preferred group's version of the chapters it translated
+	filtered = PreferGroup(filtered, options.Group)
+
 	// Filter by specific chapter IDs
 	if len(options.ChapterIDs) > 0 {
 		idMap := make(map[string]bool)
@@ -268,31 +793,54 @@ func (c *MangaController) filterChapters(chapters []*data.Chapter, options Downl
 	return filtered
 }
 
-// filterByRange filters chapters by a range string (e.g., "1-10")
-func (c *MangaController) filterByRange(chapters []*data.Chapter, rangeStr string) []*data.Chapter {
-	parts := strings.Split(rangeStr, "-")
-	if len(parts) != 2 {
-		return chapters // Invalid range, return all
+// ApplyLanguageFallback keeps the chapters in language and, for the chapter
+// numbers it lacks, the first version found in the fallback languages, tried
+// in order. The substituted chapters are returned too, in feed order.
+func ApplyLanguageFallback(chapters []*data.Chapter, language string, fallbacks []string) (selected, substituted []*data.Chapter) {
+	covered := make(map[string]bool)
+	for _, ch := range chapters {
+		if ch.Language == language {
+			covered[utils.NormalizeChapterNumber(ch.Number)] = true
+		}
 	}
 
-	start, err1 := strconv.ParseFloat(parts[0], 64)
-	end, err2 := strconv.ParseFloat(parts[1], 64)
-	if err1 != nil || err2 != nil {
-		return chapters // Invalid range, return all
+	chosen := make(map[*data.Chapter]bool)
+	for _, fallback := range fallbacks {
+		found := make(map[string]bool)
+		for _, ch := range chapters {
+			number := utils.NormalizeChapterNumber(ch.Number)
+			if ch.Language != fallback || covered[number] || found[number] {
+				continue
+			}
+			chosen[ch] = true
+			found[number] = true
+		}
+		for number := range found {
+			covered[number] = true
+		}
 	}
 
-	var filtered []*data.Chapter
 	for _, ch := range chapters {
-		chNum, err := strconv.ParseFloat(ch.Number, 64)
-		if err != nil {
-			continue
-		}
-		if chNum >= start && chNum <= end {
-			filtered = append(filtered, ch)
+		switch {
+		case ch.Language == language:
+			selected = append(selected, ch)
+		case chosen[ch]:
+			selected = append(selected, ch)
+			substituted = append(substituted, ch)
 		}
 	}
+	return selected, substituted
+}
 
-	return filtered
+// filterByRange filters chapters by a chapter selection (e.g., "1-10,15,20-"),
+// see chapterselect. Invalid selections are rejected before filtering, they
+// select every chapter here.
+func (c *MangaController) filterByRange(chapters []*data.Chapter, rangeStr string) []*data.Chapter {
+	selection, err := chapterselect.Parse(rangeStr)
+	if err != nil {
+		return chapters
+	}
+	return selection.Filter(chapters)
 }
 
 // UpdateChapterStatus updates the download status of a chapter
@@ -1,12 +1,15 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"path/filepath"
 	"testing"
 
 	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/sources"
 )
 
 func TestNewMangaController(t *testing.T) {
@@ -39,12 +42,11 @@ func TestNewMangaControllerWithConfig(t *testing.T) {
 		DownloadDir: tempDir,
 	}
 	
-	controller := NewMangaControllerWithConfig(config)
-	defer controller.Close()
-	
-	if controller == nil {
-		t.Fatal("NewMangaControllerWithConfig() returned nil")
+	controller, err := NewMangaControllerWithConfig(config)
+	if err != nil {
+		t.Fatalf("NewMangaControllerWithConfig() error = %v", err)
 	}
+	defer controller.Close()
 	if controller.downloadDir != tempDir {
 		t.Errorf("Expected downloadDir %s, got %s", tempDir, controller.downloadDir)
 	}
@@ -55,10 +57,47 @@ func TestNewMangaControllerWithConfig(t *testing.T) {
 	}
 }
 
+func TestNewMangaControllerWithDBPath(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "library.db")
+
+	controller, err := NewMangaControllerWithConfig(ControllerConfig{
+		DownloadDir: t.TempDir(),
+		DBPath:      dbPath,
+	})
+	if err != nil {
+		t.Fatalf("NewMangaControllerWithConfig() error = %v", err)
+	}
+	if err := controller.SaveManga(&data.Manga{ID: "own", Name: "Own Library", Source: "mangadex"}); err != nil {
+		t.Fatalf("SaveManga() error = %v", err)
+	}
+	if err := controller.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if manga, _ := data.NewDuckDBRepository().GetManga("own"); manga != nil {
+		t.Error("Expected the shared library to be left alone")
+	}
+	repo, err := data.OpenDuckDBRepository(dbPath)
+	if err != nil {
+		t.Fatalf("OpenDuckDBRepository() error = %v", err)
+	}
+	defer repo.Close()
+	if manga, err := repo.GetManga("own"); err != nil || manga == nil {
+		t.Errorf("Expected the manga in the configured library, got %v", err)
+	}
+
+	// A library that can't be opened is reported, not fatal
+	notDir := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(notDir, nil, 0o644)
+	if _, err := NewMangaControllerWithConfig(ControllerConfig{DownloadDir: t.TempDir(), DBPath: filepath.Join(notDir, "library.db")}); err == nil {
+		t.Error("Expected an error for a library that can't be opened")
+	}
+}
+
 func TestControllerSearchManga(t *testing.T) {
 	controller := &MangaController{
 		source: &mockSource{
-			searchFunc: func(query string) ([]*data.Manga, error) {
+			searchFunc: func(query string, options sources.SearchOptions) ([]*data.Manga, error) {
 				if query == "" {
 					return nil, fmt.Errorf("empty query")
 				}
@@ -70,7 +109,7 @@ func TestControllerSearchManga(t *testing.T) {
 	}
 	
 	t.Run("successful search", func(t *testing.T) {
-		results, err := controller.SearchManga("test")
+		results, err := controller.SearchManga("test", sources.SearchOptions{})
 		if err != nil {
 			t.Errorf("SearchManga() error = %v, want nil", err)
 		}
@@ -80,7 +119,7 @@ func TestControllerSearchManga(t *testing.T) {
 	})
 	
 	t.Run("empty query", func(t *testing.T) {
-		_, err := controller.SearchManga("")
+		_, err := controller.SearchManga("", sources.SearchOptions{})
 		if err == nil {
 			t.Error("SearchManga() should fail with empty query")
 		}
@@ -205,8 +244,8 @@ func TestControllerFindMangaByName(t *testing.T) {
 	
 	t.Run("not found", func(t *testing.T) {
 		_, err := controller.FindMangaByName("Nonexistent Manga")
-		if err == nil {
-			t.Error("FindMangaByName() should fail when manga not found")
+		if !errors.Is(err, ErrMangaNotFound) {
+			t.Errorf("FindMangaByName() error = %v, want ErrMangaNotFound", err)
 		}
 	})
 	
@@ -218,6 +257,43 @@ func TestControllerFindMangaByName(t *testing.T) {
 	})
 }
 
+func TestControllerFindMangaByNameAmbiguous(t *testing.T) {
+	library := []*data.Manga{
+		{ID: "md-1", Name: "Monster", Source: "mangadex"},
+		{ID: "ck-1", Name: "monster", Source: "comick"},
+		{ID: "md-2", Name: "One Piece", Source: "mangadex"},
+		{ID: "md-3", Name: "One-Punch Man", Source: "mangadex"},
+		{ID: "md-4", Name: "Vinland Saga", Source: "mangadex"},
+	}
+	controller := &MangaController{repo: &mockRepository{
+		listMangasFunc: func() ([]*data.Manga, error) { return library, nil },
+	}}
+
+	_, err := controller.FindMangaByName("Monster")
+	var ambiguous *AmbiguousMangaError
+	if !errors.As(err, &ambiguous) || !ambiguous.Exact || len(ambiguous.Candidates) != 2 {
+		t.Fatalf("Expected both Monster entries as candidates, got %v", err)
+	}
+
+	_, err = controller.FindMangaByName("one p")
+	if !errors.As(err, &ambiguous) || ambiguous.Exact || len(ambiguous.Candidates) != 2 {
+		t.Fatalf("Expected the similar names as candidates, got %v", err)
+	}
+	if ambiguous.Candidates[0].ID != "md-2" || ambiguous.Candidates[1].ID != "md-3" {
+		t.Errorf("Unexpected candidates: %v, %v", ambiguous.Candidates[0].Name, ambiguous.Candidates[1].Name)
+	}
+
+	if _, err := controller.FindMangaByName("vinland"); !errors.As(err, &ambiguous) || len(ambiguous.Candidates) != 1 {
+		t.Errorf("Expected a single fuzzy match to still be confirmed, got %v", err)
+	}
+	if _, err := controller.FindMangaByName("Berserk"); err == nil || errors.As(err, &ambiguous) {
+		t.Errorf("Expected a plain not found error, got %v", err)
+	}
+	if manga, err := controller.FindMangaByName("ONE PIECE"); err != nil || manga.ID != "md-2" {
+		t.Errorf("FindMangaByName() = %v, %v, want md-2", manga, err)
+	}
+}
+
 func TestControllerGetChapters(t *testing.T) {
 	controller := &MangaController{
 		source: &mockSource{
@@ -347,6 +423,44 @@ func TestControllerFilterChapters(t *testing.T) {
 			t.Errorf("Expected all %d chapters, got %d", len(chapters), len(filtered))
 		}
 	})
+
+	t.Run("fallback language", func(t *testing.T) {
+		options := DownloadOptions{Language: "ja", FallbackLanguages: []string{"en"}, ChapterRange: "1-3"}
+		filtered := controller.filterChapters(chapters, options)
+		if len(filtered) != 3 || filtered[0].ID != "2" || filtered[2].ID != "4" {
+			t.Errorf("Expected chapters 2 and 3 in English with 1 in Japanese, got %+v", filtered)
+		}
+	})
+}
+
+func TestApplyLanguageFallback(t *testing.T) {
+	chapters := []*data.Chapter{
+		{ID: "en-1", Number: "1", Language: "en"},
+		{ID: "es-2", Number: "2", Language: "es"},
+		{ID: "fr-2", Number: "2", Language: "fr"},
+		{ID: "es-2b", Number: "2.0", Language: "es"},
+		{ID: "fr-3", Number: "3", Language: "fr"},
+		{ID: "es-1", Number: "1", Language: "es"},
+		{ID: "de-4", Number: "4", Language: "de"},
+	}
+
+	selected, substituted := ApplyLanguageFallback(chapters, "en", []string{"es", "fr"})
+
+	var ids []string
+	for _, ch := range selected {
+		ids = append(ids, ch.ID)
+	}
+	if want := "en-1,es-2,fr-3"; strings.Join(ids, ",") != want {
+		t.Errorf("selected = %s, want %s", strings.Join(ids, ","), want)
+	}
+	if len(substituted) != 2 || substituted[0].ID != "es-2" || substituted[1].ID != "fr-3" {
+		t.Errorf("Unexpected substituted chapters: %+v", substituted)
+	}
+
+	selected, substituted = ApplyLanguageFallback(chapters, "en", nil)
+	if len(selected) != 1 || len(substituted) != 0 {
+		t.Errorf("Expected only the English chapter without fallbacks, got %d and %d", len(selected), len(substituted))
+	}
 }
 
 func TestControllerFilterByRange(t *testing.T) {
@@ -368,8 +482,11 @@ func TestControllerFilterByRange(t *testing.T) {
 		{"range 1-3", "1-3", 3},
 		{"range 2-5", "2-5", 3},
 		{"range 5-10", "5-10", 2},
-		{"invalid range", "invalid", 5}, // Should return all
-		{"single number", "5", 5},       // Should return all
+		{"invalid range", "1-x", 5}, // Should return all
+		{"single number", "5", 1},
+		{"list and open range", "1,3-", 4},
+		{"decimal", "2.5", 1},
+		{"latest", "latest:2", 2},
 	}
 	
 	for _, tt := range tests {
@@ -594,3 +711,237 @@ func TestControllerUpdateChapterStatus(t *testing.T) {
 		}
 	})
 }
+
+type mockRecommenderSource struct {
+	mockSource
+	popularFunc func(tags []string, limit int) ([]*data.Manga, error)
+}
+
+func (m *mockRecommenderSource) Popular(tags []string, limit int) ([]*data.Manga, error) {
+	return m.popularFunc(tags, limit)
+}
+
+func TestControllerDiscover(t *testing.T) {
+	var requestedTags []string
+
+	source := &mockRecommenderSource{
+		mockSource: mockSource{
+			getMangaFunc: func(id string) (*data.Manga, error) {
+				switch id {
+				case "read":
+					return &data.Manga{ID: id, Tags: []string{"Action", "Drama"}}, nil
+				default:
+					return &data.Manga{ID: id, Tags: []string{"Action", "Comedy"}}, nil
+				}
+			},
+		},
+		popularFunc: func(tags []string, limit int) ([]*data.Manga, error) {
+			requestedTags = tags
+			return []*data.Manga{
+				{ID: "read", Name: "Already Read"},
+				{ID: "new-1", Name: "New One"},
+				{ID: "new-2", Name: "New Two"},
+				{ID: "new-3", Name: "New Three"},
+			}, nil
+		},
+	}
+
+	controller := &MangaController{
+		source: source,
+		repo: &mockRepository{
+			listMangasFunc: func() ([]*data.Manga, error) {
+				return []*data.Manga{{ID: "unread"}, {ID: "read"}}, nil
+			},
+			getChaptersFunc: func(mangaID string) ([]*data.Chapter, error) {
+				if mangaID == "read" {
+					return []*data.Chapter{{ID: "c1", Downloaded: true}}, nil
+				}
+				return nil, nil
+			},
+		},
+	}
+
+	t.Run("excludes library entries", func(t *testing.T) {
+		results, err := controller.Discover(2)
+		if err != nil {
+			t.Fatalf("Discover() error = %v, want nil", err)
+		}
+		if len(results) != 2 {
+			t.Fatalf("Expected 2 recommendations, got %d", len(results))
+		}
+		if results[0].ID != "new-1" || results[1].ID != "new-2" {
+			t.Errorf("Unexpected recommendations: %s, %s", results[0].ID, results[1].ID)
+		}
+		if len(requestedTags) == 0 || requestedTags[0] != "Action" {
+			t.Errorf("Expected most common tag first, got %v", requestedTags)
+		}
+	})
+
+	t.Run("invalid limit", func(t *testing.T) {
+		if _, err := controller.Discover(0); err == nil {
+			t.Error("Discover() should fail with zero limit")
+		}
+	})
+
+	t.Run("unsupported source", func(t *testing.T) {
+		c := &MangaController{source: &mockSource{}, repo: &mockRepository{}}
+		if _, err := c.Discover(5); err == nil {
+			t.Error("Discover() should fail when source cannot recommend")
+		}
+	})
+}
+
+type mockRelationSource struct {
+	mockSource
+	relations []*data.Relation
+}
+
+func (m *mockRelationSource) GetRelations(manga *data.Manga) ([]*data.Relation, error) {
+	return m.relations, nil
+}
+
+func TestControllerRefreshRelations(t *testing.T) {
+	var savedFor string
+	var saved []*data.Relation
+
+	controller := &MangaController{
+		source: &mockRelationSource{
+			relations: []*data.Relation{
+				{MangaID: "manga-1", RelatedID: "manga-2", Name: "Sequel", Type: "sequel"},
+			},
+		},
+		repo: &mockRepository{
+			saveRelationsFunc: func(mangaID string, relations []*data.Relation) error {
+				savedFor = mangaID
+				saved = relations
+				return nil
+			},
+		},
+	}
+
+	t.Run("stores relations", func(t *testing.T) {
+		relations, err := controller.RefreshRelations(&data.Manga{ID: "manga-1"})
+		if err != nil {
+			t.Fatalf("RefreshRelations() error = %v, want nil", err)
+		}
+		if len(relations) != 1 || savedFor != "manga-1" || len(saved) != 1 {
+			t.Errorf("Expected relations to be saved for manga-1, got %q with %d", savedFor, len(saved))
+		}
+	})
+
+	t.Run("unsupported source", func(t *testing.T) {
+		c := &MangaController{source: &mockSource{}, repo: &mockRepository{}}
+		relations, err := c.RefreshRelations(&data.Manga{ID: "manga-1"})
+		if err != nil || relations != nil {
+			t.Errorf("Expected no relations and no error, got %v, %v", relations, err)
+		}
+	})
+
+	t.Run("nil manga", func(t *testing.T) {
+		if _, err := controller.RefreshRelations(nil); err == nil {
+			t.Error("RefreshRelations() should fail with nil manga")
+		}
+	})
+}
+
+func TestControllerSyncLibrary(t *testing.T) {
+	var saved []string
+
+	source := &mockSource{
+		getChaptersFunc: func(manga *data.Manga) ([]*data.Chapter, error) {
+			if manga.ID == "broken" {
+				return nil, fmt.Errorf("source error")
+			}
+			return []*data.Chapter{{ID: "c1", Number: "1"}, {ID: "c2", Number: "2"}, {ID: "c3", Number: "3"}}, nil
+		},
+	}
+
+	controller := &MangaController{
+		source: source,
+		repo: &mockRepository{
+			listMangasFunc: func() ([]*data.Manga, error) {
+				return []*data.Manga{{ID: "manga-1", Name: "One"}, {ID: "broken", Name: "Broken"}}, nil
+			},
+			getChaptersFunc: func(mangaID string) ([]*data.Chapter, error) {
+				return []*data.Chapter{{ID: "c1", Downloaded: true}}, nil
+			},
+			saveChapterFunc: func(chapter *data.Chapter) error {
+				saved = append(saved, chapter.ID)
+				return nil
+			},
+		},
+	}
+
+	results, err := controller.SyncLibrary()
+	if err != nil {
+		t.Fatalf("SyncLibrary() error = %v, want nil", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	if results[0].Err != nil || len(results[0].NewChapters) != 2 {
+		t.Errorf("Expected 2 new chapters for manga-1, got %d (err %v)", len(results[0].NewChapters), results[0].Err)
+	}
+	if len(saved) != 2 || saved[0] != "c2" || saved[1] != "c3" {
+		t.Errorf("Expected only new chapters to be saved, got %v", saved)
+	}
+	if results[0].NewChapters[0].MangaID != "manga-1" {
+		t.Errorf("Expected new chapters to belong to manga-1, got %q", results[0].NewChapters[0].MangaID)
+	}
+
+	if results[1].Err == nil {
+		t.Error("Expected error result for broken manga")
+	}
+}
+
+func TestControllerQueueDownloads(t *testing.T) {
+	library := []*data.Chapter{
+		{ID: "c1", MangaID: "manga-1", Number: "1", Language: "en", Downloaded: true},
+		{ID: "c2", MangaID: "manga-1", Number: "2", Language: "en"},
+		{ID: "c2-es", MangaID: "manga-1", Number: "2", Language: "es"},
+	}
+	repo := &mockRepository{
+		getChaptersFunc: func(mangaID string) ([]*data.Chapter, error) {
+			return library, nil
+		},
+		saveChapterFunc: func(chapter *data.Chapter) error {
+			library = append(library, chapter)
+			return nil
+		},
+	}
+	source := &mockSource{
+		getChaptersFunc: func(manga *data.Manga) ([]*data.Chapter, error) {
+			return []*data.Chapter{
+				{ID: "c1", Number: "1", Language: "en"},
+				{ID: "c3", Number: "3", Language: "en"},
+			}, nil
+		},
+	}
+
+	downloader := NewDownloader(source, repo, t.TempDir())
+	defer downloader.Close()
+	store := &memoryQueueStore{}
+	controller := &MangaController{
+		source:     source,
+		repo:       repo,
+		downloader: downloader,
+		queue:      NewDownloadQueue(repo, store, downloader),
+	}
+
+	manga := &data.Manga{ID: "manga-1", Name: "One"}
+	queued, err := controller.QueueDownloads(manga, DownloadOptions{Language: "en"})
+	if err != nil {
+		t.Fatalf("QueueDownloads() error = %v, want nil", err)
+	}
+	if len(queued) != 2 || queued[0].ID != "c2" || queued[1].ID != "c3" {
+		t.Errorf("Expected new and undownloaded chapters c2 and c3 queued, got %d", len(queued))
+	}
+	if len(store.items) != 2 {
+		t.Errorf("Expected 2 items in the queue store, got %d", len(store.items))
+	}
+
+	if _, err := controller.QueueDownloads(manga, DownloadOptions{Language: "ja"}); err == nil {
+		t.Error("QueueDownloads() should fail when no chapter matches")
+	}
+}
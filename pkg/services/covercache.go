@@ -0,0 +1,335 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/log"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// DefaultCoverMaxAge is how long a cover is used without asking the server
+// again, when the server doesn't say
+const DefaultCoverMaxAge = 24 * time.Hour
+
+// coverCacheDir is set by SetCoverCacheDir
+var coverCacheDir string
+
+// SetCoverCacheDir overrides where covers are cached, "" restores the default
+func SetCoverCacheDir(dir string) {
+	coverCacheDir = dir
+}
+
+// DefaultCoverCacheDir returns where covers are cached: the directory given
+// to SetCoverCacheDir, or ~/.mangas/covers
+func DefaultCoverCacheDir() string {
+	if coverCacheDir != "" {
+		return coverCacheDir
+	}
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".mangas", "covers")
+}
+
+// coverEntry describes a cached cover, stored next to it
+type coverEntry struct {
+	URL          string    `json:"url"`
+	ContentType  string    `json:"content_type"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Expires      time.Time `json:"expires"`
+}
+
+// CoverCache stores manga covers on disk, keyed by manga ID, so they are
+// downloaded once instead of for every chapter. A cover is used as is until
+// its max-age runs out, then revalidated with its ETag or Last-Modified
+// date; a stale cover is still used when the server can't be reached.
+type CoverCache struct {
+	dir string
+	now func() time.Time
+
+	// BeforeRequest is called before each request, e.g. to rate limit
+	BeforeRequest func(url string)
+	// Retry controls how failed requests are retried
+	Retry RetryPolicy
+
+	mu sync.Mutex // Guards the files, not held during requests
+}
+
+// NewCoverCache returns a cache storing covers in dir
+func NewCoverCache(dir string) *CoverCache {
+	return &CoverCache{dir: dir, now: time.Now, Retry: DefaultRetryPolicy()}
+}
+
+// Dir returns the directory covers are stored in
+func (c *CoverCache) Dir() string {
+	return c.dir
+}
+
+// Get returns the cover of a manga at url, a URL or a local path, from the
+// cache when it is fresh.
+// Requests are counted in utils.Stats under source, unless empty.
+func (c *CoverCache) Get(source, mangaID, url string) (integrations.CoverData, error) {
+	c.mu.Lock()
+	entry, content := c.read(mangaID)
+	c.mu.Unlock()
+	if entry != nil && entry.URL != url {
+		entry, content = nil, nil // The manga has a new cover
+	}
+	if entry != nil && c.now().Before(entry.Expires) {
+		return integrations.CoverData{Content: content, ContentType: entry.ContentType}, nil
+	}
+
+	// Other covers are served while this one downloads
+	fetched, err := c.fetch(source, url, entry)
+	if err != nil {
+		if entry != nil {
+			log.Debug("using stale cover", "manga_id", mangaID, "err", err)
+			return integrations.CoverData{Content: content, ContentType: entry.ContentType}, nil
+		}
+		return integrations.CoverData{}, fmt.Errorf("failed to fetch cover image: %w", err)
+	}
+	if fetched.content == nil {
+		// Not modified
+		fetched.content = content
+	}
+	c.mu.Lock()
+	err = c.write(mangaID, fetched.entry, fetched.content)
+	c.mu.Unlock()
+	if err != nil {
+		log.Warn("failed to cache cover", "manga_id", mangaID, "err", err)
+	}
+	return integrations.CoverData{Content: fetched.content, ContentType: fetched.entry.ContentType}, nil
+}
+
+// fetchedCover is the answer of the server, content is nil when the cached
+// cover was not modified
+type fetchedCover struct {
+	entry   *coverEntry
+	content []byte
+}
+
+// fetch downloads a cover, or revalidates cached when not nil, retrying
+// network errors and retryable statuses according to the retry policy.
+// Local covers are read again every time, so edits to the file show up, with
+// the cached copy standing in when the file is gone.
+func (c *CoverCache) fetch(source, url string, cached *coverEntry) (fetchedCover, error) {
+	if isLocalCover(url) {
+		cover, err := readCoverFile(url)
+		if err != nil {
+			return fetchedCover{}, err
+		}
+		return fetchedCover{
+			entry:   &coverEntry{URL: url, ContentType: cover.ContentType, Expires: c.now()},
+			content: cover.Content,
+		}, nil
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < max(c.Retry.Attempts, 1); attempt++ {
+		if attempt > 0 {
+			time.Sleep(c.Retry.delay(attempt))
+		}
+		fetched, retryable, err := c.fetchOnce(source, url, cached)
+		if err == nil {
+			return fetched, nil
+		}
+		lastErr = err
+		if !retryable {
+			break
+		}
+		log.Debug("cover request failed, retrying", "url", url, "attempt", attempt+1, "err", err)
+	}
+	return fetchedCover{}, lastErr
+}
+
+// fetchOnce performs a single request for a cover, reporting whether a
+// failure is worth retrying
+func (c *CoverCache) fetchOnce(source, url string, cached *coverEntry) (fetched fetchedCover, retryable bool, err error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return fetched, false, err
+	}
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	if c.BeforeRequest != nil {
+		c.BeforeRequest(url)
+	}
+	start := time.Now()
+	if source != "" {
+		defer func() {
+			utils.Stats.Record(source, time.Since(start), int64(len(fetched.content)), err)
+		}()
+	}
+
+	resp, err := utils.HTTPClient(source).Do(req)
+	if err != nil {
+		return fetched, true, err
+	}
+	defer resp.Body.Close()
+	utils.SharedLimiter.Observe(resp)
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		entry := *cached
+		entry.Expires = c.expires(resp.Header)
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			entry.ETag = etag
+		}
+		return fetchedCover{entry: &entry}, false, nil
+	case resp.StatusCode != http.StatusOK:
+		return fetched, c.Retry.shouldRetry(resp.StatusCode), fmt.Errorf("bad status: %s", resp.Status)
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fetched, true, err
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(content)
+	}
+	return fetchedCover{
+		entry: &coverEntry{
+			URL:          url,
+			ContentType:  contentType,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Expires:      c.expires(resp.Header),
+		},
+		content: content,
+	}, false, nil
+}
+
+// expires returns until when a response may be used, from its Cache-Control
+// max-age, DefaultCoverMaxAge when missing. no-cache and no-store responses
+// are revalidated every time.
+func (c *CoverCache) expires(header http.Header) time.Time {
+	maxAge := DefaultCoverMaxAge
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-cache" || directive == "no-store":
+			return c.now()
+		case strings.HasPrefix(directive, "max-age="):
+			if seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age=")); err == nil {
+				maxAge = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return c.now().Add(maxAge)
+}
+
+// paths returns the files of the cover of a manga and of its description
+func (c *CoverCache) paths(mangaID string) (string, string) {
+	name := strings.NewReplacer("/", "_", `\`, "_", "..", "_").Replace(mangaID)
+	base := filepath.Join(c.dir, name)
+	return base + ".img", base + ".json"
+}
+
+// read returns the cached cover of a manga, nil when there is none
+func (c *CoverCache) read(mangaID string) (*coverEntry, []byte) {
+	imagePath, entryPath := c.paths(mangaID)
+	raw, err := os.ReadFile(entryPath)
+	if err != nil {
+		return nil, nil
+	}
+	var entry coverEntry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return nil, nil
+	}
+	content, err := os.ReadFile(imagePath)
+	if err != nil || len(content) == 0 {
+		return nil, nil
+	}
+	return &entry, content
+}
+
+// write stores the cover of a manga, the image first so a description never
+// points to a missing image
+func (c *CoverCache) write(mangaID string, entry *coverEntry, content []byte) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	imagePath, entryPath := c.paths(mangaID)
+	if err := writeFileAtomic(imagePath, content); err != nil {
+		return err
+	}
+	return writeFileAtomic(entryPath, raw)
+}
+
+// Clean removes the cached covers, or only the expired ones, and returns
+// how many were removed and the bytes freed
+func (c *CoverCache) Clean(expiredOnly bool) (int, int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+
+	removed, freed := 0, int64(0)
+	for _, file := range entries {
+		name := file.Name()
+		if file.IsDir() || filepath.Ext(name) != ".img" {
+			continue
+		}
+		mangaID := strings.TrimSuffix(name, ".img")
+		if expiredOnly {
+			if entry, _ := c.read(mangaID); entry != nil && c.now().Before(entry.Expires) {
+				continue
+			}
+		}
+		imagePath, entryPath := c.paths(mangaID)
+		if info, err := os.Stat(imagePath); err == nil {
+			freed += info.Size()
+		}
+		if err := os.Remove(imagePath); err != nil {
+			return removed, freed, err
+		}
+		os.Remove(entryPath)
+		removed++
+	}
+	return removed, freed, nil
+}
+
+// writeFileAtomic writes a file through a temporary one, so readers never
+// see it half written
+func writeFileAtomic(path string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCoverCache(t *testing.T) {
+	cover := createTestPNG()
+	var requests, revalidations int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			revalidations++
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(cover)
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	cache := NewCoverCache(t.TempDir())
+	cache.now = func() time.Time { return now }
+	cache.Retry.Backoff = time.Millisecond
+
+	get := func() {
+		t.Helper()
+		data, err := cache.Get("", "manga-1", server.URL+"/cover.png")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if len(data.Content) != len(cover) || data.ContentType != "image/png" {
+			t.Fatalf("Get() = %d bytes of %s, want the cover", len(data.Content), data.ContentType)
+		}
+	}
+
+	get()
+	get()
+	if requests != 1 {
+		t.Errorf("Fresh covers should come from the cache, %d requests made", requests)
+	}
+
+	// Once expired, the cover is revalidated with its ETag
+	now = now.Add(2 * time.Minute)
+	get()
+	if requests != 2 || revalidations != 1 {
+		t.Errorf("Expected a revalidation, got %d requests, %d revalidations", requests, revalidations)
+	}
+	get()
+	if requests != 2 {
+		t.Errorf("A revalidated cover should be fresh again, %d requests made", requests)
+	}
+
+	// A stale cover is better than none when the server is gone
+	now = now.Add(2 * time.Minute)
+	server.Close()
+	get()
+
+	// A new cover URL is not served from the cache
+	if _, err := cache.Get("", "manga-1", server.URL+"/new.png"); err == nil {
+		t.Error("Expected the new cover to be fetched")
+	}
+}
+
+func TestCoverCache_Retry(t *testing.T) {
+	cover := createTestPNG()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(cover)
+	}))
+	defer server.Close()
+
+	cache := NewCoverCache(t.TempDir())
+	cache.Retry.Backoff = time.Millisecond
+	data, err := cache.Get("", "manga-1", server.URL+"/cover.png")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if requests != 2 || len(data.Content) != len(cover) {
+		t.Errorf("Expected the cover after a retry, got %d bytes in %d requests", len(data.Content), requests)
+	}
+
+	// Statuses not worth retrying fail right away
+	requests = 0
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+	})
+	if _, err := cache.Get("", "manga-2", server.URL+"/missing.png"); err == nil || requests != 1 {
+		t.Errorf("Expected a single failed request, got %d (err = %v)", requests, err)
+	}
+}
+
+func TestCoverCache_CacheControl(t *testing.T) {
+	now := time.Now()
+	cache := NewCoverCache(t.TempDir())
+	cache.now = func() time.Time { return now }
+
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", DefaultCoverMaxAge},
+		{"max-age=3600", time.Hour},
+		{"public, max-age=120, immutable", 2 * time.Minute},
+		{"no-cache", 0},
+		{"no-store, max-age=60", 0},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		header.Set("Cache-Control", tt.header)
+		if got := cache.expires(header).Sub(now); got != tt.want {
+			t.Errorf("expires(%q) = now + %s, want now + %s", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestCoverCache_Clean(t *testing.T) {
+	now := time.Now()
+	cache := NewCoverCache(t.TempDir())
+	cache.now = func() time.Time { return now }
+
+	content := createTestPNG()
+	cache.write("fresh", &coverEntry{URL: "a", Expires: now.Add(time.Hour)}, content)
+	cache.write("expired", &coverEntry{URL: "b", Expires: now.Add(-time.Hour)}, content)
+
+	removed, freed, err := cache.Clean(true)
+	if err != nil || removed != 1 || freed != int64(len(content)) {
+		t.Errorf("Clean(expired) = %d, %d, %v, want 1 cover of %d bytes", removed, freed, err, len(content))
+	}
+	if entry, _ := cache.read("fresh"); entry == nil {
+		t.Error("The fresh cover should be kept")
+	}
+
+	if removed, _, err := cache.Clean(false); err != nil || removed != 1 {
+		t.Errorf("Clean() = %d, %v, want 1", removed, err)
+	}
+	if entry, _ := cache.read("fresh"); entry != nil {
+		t.Error("Every cover should be removed")
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/sources"
+)
+
+// MangaCoverURL returns where the cover of a manga is: its custom cover, a
+// local path or URL, when set, the cover of its source otherwise
+func MangaCoverURL(source sources.Source, manga *data.Manga) (string, error) {
+	if manga.CustomCover != "" {
+		return manga.CustomCover, nil
+	}
+	return source.GetMangaCoverURL(manga)
+}
+
+// LoadCustomCover checks a custom cover for a manga, a local path or an
+// http(s) URL, is an image and stores it in covers. It returns the cover to
+// save with data.Repository.SetCustomCover: URLs as they are, paths made
+// absolute so they don't depend on where mangas runs.
+func LoadCustomCover(covers *CoverCache, manga *data.Manga, cover string) (string, error) {
+	if isLocalCover(cover) {
+		path, err := filepath.Abs(cover)
+		if err != nil {
+			return "", err
+		}
+		cover = path
+
+		// Checked before caching, not to replace the cached cover with junk
+		file, err := readCoverFile(cover)
+		if err != nil {
+			return "", err
+		}
+		if err := checkCoverImage(cover, file); err != nil {
+			return "", err
+		}
+	}
+
+	loaded, err := covers.Get(statsSource(manga), manga.ID, cover)
+	if err != nil {
+		return "", err
+	}
+	if err := checkCoverImage(cover, loaded); err != nil {
+		return "", err
+	}
+	return cover, nil
+}
+
+// checkCoverImage returns an error when a cover is not an image
+func checkCoverImage(cover string, image integrations.CoverData) error {
+	if !strings.HasPrefix(image.ContentType, "image/") {
+		return fmt.Errorf("%s is not an image (%s)", cover, image.ContentType)
+	}
+	return nil
+}
+
+// isLocalCover reports whether a cover is a file rather than a URL
+func isLocalCover(cover string) bool {
+	return !strings.HasPrefix(cover, "http://") && !strings.HasPrefix(cover, "https://")
+}
+
+// readCoverFile reads a cover image from disk
+func readCoverFile(path string) (integrations.CoverData, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return integrations.CoverData{}, err
+	}
+	return integrations.CoverData{
+		Content:     content,
+		ContentType: http.DetectContentType(content),
+	}, nil
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+func TestMangaCoverURL(t *testing.T) {
+	source := &mockSource{
+		getMangaCoverURLFunc: func(manga *data.Manga) (string, error) {
+			return "https://example.com/cover.jpg", nil
+		},
+	}
+
+	manga := &data.Manga{ID: "manga-1"}
+	if url, _ := MangaCoverURL(source, manga); url != "https://example.com/cover.jpg" {
+		t.Errorf("MangaCoverURL() = %q, want the source cover", url)
+	}
+	manga.CustomCover = "/covers/manga-1.png"
+	if url, _ := MangaCoverURL(source, manga); url != "/covers/manga-1.png" {
+		t.Errorf("MangaCoverURL() = %q, want the custom cover", url)
+	}
+}
+
+func TestLoadCustomCover(t *testing.T) {
+	dir := t.TempDir()
+	coverPath := filepath.Join(dir, "cover.png")
+	os.WriteFile(coverPath, createTestPNG(), 0644)
+	textPath := filepath.Join(dir, "notes.txt")
+	os.WriteFile(textPath, []byte("not a cover"), 0644)
+
+	cache := NewCoverCache(filepath.Join(dir, "covers"))
+	manga := &data.Manga{ID: "manga-1", Source: "test"}
+
+	t.Chdir(dir)
+	cover, err := LoadCustomCover(cache, manga, "cover.png")
+	if err != nil {
+		t.Fatalf("LoadCustomCover() error = %v", err)
+	}
+	if cover != coverPath {
+		t.Errorf("LoadCustomCover() = %q, want the absolute path %q", cover, coverPath)
+	}
+
+	// The cached copy stands in once the file is gone
+	os.Remove(coverPath)
+	data, err := cache.Get("", manga.ID, cover)
+	if err != nil || data.ContentType != "image/png" {
+		t.Errorf("Get() = %s, %v, want the cached cover", data.ContentType, err)
+	}
+
+	if _, err := LoadCustomCover(cache, manga, textPath); err == nil {
+		t.Error("Expected an error for a file that is not an image")
+	}
+	if _, err := LoadCustomCover(cache, manga, filepath.Join(dir, "missing.png")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
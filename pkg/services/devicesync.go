@@ -0,0 +1,266 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// SyncStore records the chapters copied to devices
+type SyncStore interface {
+	GetDeviceSyncs(deviceID string) (map[string]*data.DeviceSync, error)
+	SaveDeviceSync(sync *data.DeviceSync) error
+}
+
+// ChapterConverter converts a downloaded book for a device, writing it to
+// outputDir, and returns the path of the converted file. chapters are the
+// chapters in the book, several for volume bundles.
+type ChapterConverter func(manga *data.Manga, chapters []*data.Chapter, outputDir string) (string, error)
+
+// DeviceSyncAction tells why a book is copied to a device
+type DeviceSyncAction string
+
+const (
+	DeviceSyncNew      DeviceSyncAction = "new"      // Never copied to the device
+	DeviceSyncUpdated  DeviceSyncAction = "updated"  // The library file changed since it was copied
+	DeviceSyncRestored DeviceSyncAction = "restored" // Copied before, but missing or damaged on the device
+)
+
+// DeviceSyncOptions tunes SyncDevice
+type DeviceSyncOptions struct {
+	MangaIDs []string         // Manga to sync, the whole library when empty
+	Convert  ChapterConverter // Converts chapters before copying them, nil copies the EPUBs as they are
+	DryRun   bool             // Only report what would be copied
+	OnFile   func(DeviceSyncFile)
+}
+
+// DeviceSyncFile is a book copied, or to copy, to a device
+type DeviceSyncFile struct {
+	Manga    *data.Manga
+	Chapters []*data.Chapter // Several when chapters were bundled in a volume
+	Action   DeviceSyncAction
+	Path     string // Relative to the device root
+	Err      error
+}
+
+// DeviceSyncReport summarizes a sync
+type DeviceSyncReport struct {
+	Files    []DeviceSyncFile
+	UpToDate int // Books already on the device
+	Failed   int
+}
+
+// SyncDevice copies the downloaded chapters that are new or updated since
+// the last sync to the device, one folder per series under its library
+// folder. Chapters are compared by the SHA-256 of their library file, and
+// what was copied is recorded in store under the ID of the device.
+func SyncDevice(repo Repository, store SyncStore, device *integrations.Device, options DeviceSyncOptions) (*DeviceSyncReport, error) {
+	if !options.DryRun {
+		if err := device.EnsureID(); err != nil {
+			return nil, err
+		}
+	}
+	records := make(map[string]*data.DeviceSync)
+	if device.ID != "" {
+		var err error
+		if records, err = store.GetDeviceSyncs(device.ID); err != nil {
+			return nil, fmt.Errorf("failed to get synced chapters: %w", err)
+		}
+	}
+
+	mangas, err := syncedMangas(repo, options.MangaIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DeviceSyncReport{}
+	for _, manga := range mangas {
+		chapters, err := repo.GetChapters(manga.ID)
+		if err != nil {
+			return report, fmt.Errorf("failed to get chapters of %s: %w", manga.Name, err)
+		}
+		for _, book := range downloadedBooks(chapters) {
+			file, err := syncBook(store, device, records, manga, book, options)
+			if file == nil && err == nil {
+				report.UpToDate++
+				continue
+			}
+			if file == nil {
+				file = &DeviceSyncFile{Manga: manga, Chapters: book}
+			}
+			file.Err = err
+			if err != nil {
+				report.Failed++
+			}
+			report.Files = append(report.Files, *file)
+			if options.OnFile != nil {
+				options.OnFile(*file)
+			}
+		}
+	}
+	return report, nil
+}
+
+// downloadedBooks groups the downloaded chapters by file, in order, since
+// the chapters of a volume share their EPUB
+func downloadedBooks(chapters []*data.Chapter) [][]*data.Chapter {
+	var books [][]*data.Chapter
+	index := make(map[string]int)
+	for _, chapter := range chapters {
+		if !chapter.Downloaded || chapter.FilePath == "" {
+			continue
+		}
+		if _, err := os.Stat(chapter.FilePath); err != nil {
+			continue
+		}
+		if i, ok := index[chapter.FilePath]; ok {
+			books[i] = append(books[i], chapter)
+			continue
+		}
+		index[chapter.FilePath] = len(books)
+		books = append(books, []*data.Chapter{chapter})
+	}
+	return books
+}
+
+// syncBook copies the book of chapters to the device when needed. It returns
+// nil when the device already has it.
+func syncBook(store SyncStore, device *integrations.Device, records map[string]*data.DeviceSync, manga *data.Manga, chapters []*data.Chapter, options DeviceSyncOptions) (*DeviceSyncFile, error) {
+	hash, err := integrations.FileSHA256(chapters[0].FilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// The chapters of a book are recorded together, any of them tells where
+	// the book is on the device
+	var record *data.DeviceSync
+	missing, changed := 0, false
+	for _, chapter := range chapters {
+		r := records[chapter.ID]
+		if r == nil {
+			missing++
+			continue
+		}
+		record = r
+		changed = changed || r.SourceHash != hash
+	}
+
+	file := &DeviceSyncFile{Manga: manga, Chapters: chapters}
+	switch {
+	case record == nil:
+		file.Action = DeviceSyncNew
+	case missing > 0 || changed:
+		file.Action = DeviceSyncUpdated
+	default:
+		info, err := os.Stat(filepath.Join(device.Root, record.Path))
+		if err == nil && info.Size() == record.Size {
+			return nil, nil
+		}
+		file.Action = DeviceSyncRestored
+	}
+
+	source := chapters[0].FilePath
+	if options.Convert != nil && !options.DryRun {
+		tempDir, err := utils.Temp.MkdirTemp("sync-*")
+		if err != nil {
+			return file, fmt.Errorf("failed to create temp dir: %w", err)
+		}
+		defer utils.Temp.Release(tempDir)
+		if source, err = options.Convert(manga, chapters, tempDir); err != nil {
+			return file, fmt.Errorf("conversion failed: %w", err)
+		}
+	}
+
+	dest, err := utils.SafeJoin(device.LibraryDir(), integrations.SeriesFolder(manga.Name), filepath.Base(source))
+	if err != nil {
+		return file, err
+	}
+	if file.Path, err = filepath.Rel(device.Root, dest); err != nil {
+		return file, err
+	}
+	if options.DryRun {
+		return file, nil
+	}
+
+	size, err := copyFileAtomic(source, dest)
+	if err != nil {
+		return file, err
+	}
+	// A conversion to another format leaves the previous file behind
+	if record != nil && record.Path != file.Path {
+		os.Remove(filepath.Join(device.Root, record.Path))
+	}
+	for _, chapter := range chapters {
+		err := store.SaveDeviceSync(&data.DeviceSync{
+			DeviceID:   device.ID,
+			ChapterID:  chapter.ID,
+			SourceHash: hash,
+			Path:       file.Path,
+			Size:       size,
+		})
+		if err != nil {
+			return file, fmt.Errorf("failed to record sync: %w", err)
+		}
+	}
+	return file, nil
+}
+
+// syncedMangas returns the library manga with the given IDs, or all of them
+func syncedMangas(repo Repository, ids []string) ([]*data.Manga, error) {
+	if len(ids) == 0 {
+		mangas, err := repo.ListMangas()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list mangas: %w", err)
+		}
+		return mangas, nil
+	}
+	mangas := make([]*data.Manga, 0, len(ids))
+	for _, id := range ids {
+		manga, err := repo.GetManga(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get manga %s: %w", id, err)
+		}
+		mangas = append(mangas, manga)
+	}
+	return mangas, nil
+}
+
+// copyFileAtomic copies src to dest through a temporary file, so an
+// unplugged device never keeps a truncated book, and returns its size
+func copyFileAtomic(src, dest string) (int64, error) {
+	if err := utils.CheckOutputPath(dest); err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create folder: %w", err)
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	partial := dest + ".part"
+	out, err := os.Create(partial)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s: %w", partial, err)
+	}
+	size, err := io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(partial)
+		return 0, fmt.Errorf("failed to copy %s: %w", filepath.Base(src), err)
+	}
+	if err := os.Rename(partial, dest); err != nil {
+		os.Remove(partial)
+		return 0, fmt.Errorf("failed to move %s into place: %w", filepath.Base(dest), err)
+	}
+	return size, nil
+}
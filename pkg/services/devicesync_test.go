@@ -0,0 +1,161 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+)
+
+// memorySyncStore keeps device syncs in memory
+type memorySyncStore map[string]map[string]*data.DeviceSync
+
+func (s memorySyncStore) GetDeviceSyncs(deviceID string) (map[string]*data.DeviceSync, error) {
+	syncs := make(map[string]*data.DeviceSync)
+	for id, sync := range s[deviceID] {
+		syncs[id] = sync
+	}
+	return syncs, nil
+}
+
+func (s memorySyncStore) SaveDeviceSync(sync *data.DeviceSync) error {
+	if s[sync.DeviceID] == nil {
+		s[sync.DeviceID] = make(map[string]*data.DeviceSync)
+	}
+	s[sync.DeviceID][sync.ChapterID] = sync
+	return nil
+}
+
+func TestSyncDevice(t *testing.T) {
+	library := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(library, name)
+		os.WriteFile(path, []byte(content), 0644)
+		return path
+	}
+
+	manga := &data.Manga{ID: "m1", Name: "Sync: Manga"}
+	chapters := []*data.Chapter{
+		{ID: "c1", Number: "1", Downloaded: true, FilePath: write("Sync_ch_1.epub", "chapter one")},
+		{ID: "c2", Number: "2", Downloaded: true, FilePath: write("Sync_vol_1.epub", "volume one")},
+		{ID: "c3", Number: "3", Downloaded: true, FilePath: filepath.Join(library, "Sync_vol_1.epub")},
+		{ID: "c4", Number: "4"}, // Not downloaded
+		{ID: "c5", Number: "5", Downloaded: true, FilePath: filepath.Join(library, "missing.epub")},
+	}
+	repo := &mockRepository{
+		listMangasFunc:  func() ([]*data.Manga, error) { return []*data.Manga{manga}, nil },
+		getChaptersFunc: func(string) ([]*data.Chapter, error) { return chapters, nil },
+	}
+	store := memorySyncStore{}
+
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "documents"), 0755)
+	os.MkdirAll(filepath.Join(root, "system"), 0755)
+	device, err := integrations.DetectDevice(root)
+	if err != nil {
+		t.Fatalf("DetectDevice() error = %v", err)
+	}
+
+	// Dry runs leave the device untouched
+	report, err := SyncDevice(repo, store, device, DeviceSyncOptions{DryRun: true})
+	if err != nil || len(report.Files) != 2 {
+		t.Fatalf("Dry run = %+v, %v, want 2 books to copy", report, err)
+	}
+	if device.ID != "" || len(store) != 0 {
+		t.Error("Dry run should not mark the device nor record syncs")
+	}
+
+	report, err = SyncDevice(repo, store, device, DeviceSyncOptions{})
+	if err != nil {
+		t.Fatalf("SyncDevice() error = %v", err)
+	}
+	if len(report.Files) != 2 || report.Failed != 0 || report.Files[0].Action != DeviceSyncNew {
+		t.Fatalf("Unexpected first sync: %+v", report)
+	}
+	copied := filepath.Join(root, report.Files[0].Path)
+	if content, err := os.ReadFile(copied); err != nil || string(content) != "chapter one" {
+		t.Fatalf("Expected the chapter on the device at %s: %v", copied, err)
+	}
+	if filepath.Dir(copied) != filepath.Join(root, "documents", "mangas", integrations.SeriesFolder(manga.Name)) {
+		t.Errorf("Unexpected device folder: %s", copied)
+	}
+	if len(store[device.ID]) != 3 || len(report.Files[1].Chapters) != 2 {
+		t.Errorf("Expected the volume chapters to be recorded together, got %d records", len(store[device.ID]))
+	}
+
+	// Nothing changed
+	report, _ = SyncDevice(repo, store, device, DeviceSyncOptions{})
+	if len(report.Files) != 0 || report.UpToDate != 2 {
+		t.Errorf("Expected everything up to date, got %+v", report)
+	}
+
+	// An updated library file, and a book deleted from the device
+	write("Sync_vol_1.epub", "volume one, fixed")
+	os.Remove(copied)
+	again, _ := integrations.DetectDevice(root)
+	report, _ = SyncDevice(repo, store, again, DeviceSyncOptions{})
+	actions := map[DeviceSyncAction]int{}
+	for _, file := range report.Files {
+		actions[file.Action]++
+	}
+	if actions[DeviceSyncRestored] != 1 || actions[DeviceSyncUpdated] != 1 {
+		t.Errorf("Expected a restored and an updated book, got %v", actions)
+	}
+
+	// Converted books replace the previous copies
+	convert := func(manga *data.Manga, chapters []*data.Chapter, outputDir string) (string, error) {
+		path := filepath.Join(outputDir, "converted_"+chapters[0].ID+".kepub.epub")
+		return path, os.WriteFile(path, []byte("converted"), 0644)
+	}
+	write("Sync_ch_1.epub", "chapter one, fixed")
+	report, _ = SyncDevice(repo, store, again, DeviceSyncOptions{Convert: convert})
+	if len(report.Files) != 1 || filepath.Base(report.Files[0].Path) != "converted_c1.kepub.epub" {
+		t.Fatalf("Unexpected converted sync: %+v", report)
+	}
+	if _, err := os.Stat(copied); !os.IsNotExist(err) {
+		t.Error("Expected the previous copy to be removed")
+	}
+}
+
+func TestSyncDevice_ConvertsVolumesOnce(t *testing.T) {
+	library := t.TempDir()
+	volume := filepath.Join(library, "Sync_vol_1.epub")
+	os.WriteFile(volume, []byte("volume one"), 0644)
+
+	manga := &data.Manga{ID: "m1", Name: "Sync"}
+	chapters := []*data.Chapter{
+		{ID: "c1", Number: "1", Volume: "1", Downloaded: true, FilePath: volume},
+		{ID: "c2", Number: "2", Volume: "1", Downloaded: true, FilePath: volume},
+		{ID: "c3", Number: "3", Volume: "1", Downloaded: true, FilePath: volume},
+	}
+	repo := &mockRepository{
+		listMangasFunc:  func() ([]*data.Manga, error) { return []*data.Manga{manga}, nil },
+		getChaptersFunc: func(string) ([]*data.Chapter, error) { return chapters, nil },
+	}
+
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "documents"), 0755)
+	os.MkdirAll(filepath.Join(root, "system"), 0755)
+	device, err := integrations.DetectDevice(root)
+	if err != nil {
+		t.Fatalf("DetectDevice() error = %v", err)
+	}
+
+	// Converts the books of the chapters, as 'mangas sync --convert' does
+	var converted [][]string
+	convert := func(manga *data.Manga, chapters []*data.Chapter, outputDir string) (string, error) {
+		books, _ := ChapterBooks(chapters)
+		converted = append(converted, books)
+		path := filepath.Join(outputDir, "Sync_vol_1.kepub.epub")
+		return path, os.WriteFile(path, []byte("converted"), 0644)
+	}
+	report, err := SyncDevice(repo, memorySyncStore{}, device, DeviceSyncOptions{Convert: convert})
+	if err != nil {
+		t.Fatalf("SyncDevice() error = %v", err)
+	}
+	if len(report.Files) != 1 || len(converted) != 1 || len(converted[0]) != 1 || converted[0][0] != volume {
+		t.Errorf("Expected the volume converted once from its book, got %d files and conversions %v", len(report.Files), converted)
+	}
+}
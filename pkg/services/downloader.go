@@ -1,15 +1,23 @@
 package services
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kerbaras/mangas/pkg/data"
 	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/log"
 	"github.com/kerbaras/mangas/pkg/sources"
+	"github.com/kerbaras/mangas/pkg/utils"
 )
 
 // DownloadProgress represents the progress of a download operation
@@ -19,8 +27,20 @@ type DownloadProgress struct {
 	CurrentPage   int
 	TotalPages    int
 	Status        string // "downloading", "processing", "complete", "error"
+	Stage         string // Finalization step while "processing", see integrations.FinalizeStage
 	Error         error
 	ChapterNumber string
+	Retries       int   // Retries needed for the current page
+	Bytes         int64 // Bytes of the chapter downloaded so far
+
+	// Speed and ETA cover the whole manga download when several chapters are
+	// downloaded, the chapter otherwise
+	Speed float64       // Bytes per second
+	ETA   time.Duration // Estimated time left, 0 when unknown
+
+	// Aggregate of the manga download, when several chapters are downloaded
+	MangaChapters  int // Chapters to download
+	MangaCompleted int // Chapters finished, downloaded or failed
 }
 
 // Repository interface needed by downloader
@@ -32,40 +52,401 @@ type Repository interface {
 	UpdateChapterStatus(chapterID string, downloaded bool, filePath string) error
 	ListMangas() ([]*data.Manga, error)
 	DeleteManga(mangaID string) error
+	SaveRelations(mangaID string, relations []*data.Relation) error
+	GetRelations(mangaID string) ([]*data.Relation, error)
 }
 
-// Downloader orchestrates manga downloads as a streaming pipeline
+// DownloaderOptions tunes download throughput
+type DownloaderOptions struct {
+	MaxConcurrentChapters int                // Chapters downloaded in parallel
+	MaxConcurrentPages    int                // Pages downloaded in parallel within a chapter
+	RequestsPerSecond     float64            // Global request rate, 0 disables limiting
+	PerHostLimits         map[string]float64 // Requests per second for specific hosts
+	Retry                 RetryPolicy        // Retries for image and cover downloads
+}
+
+// DefaultDownloaderOptions returns the polite defaults used by NewDownloader
+func DefaultDownloaderOptions() DownloaderOptions {
+	return DownloaderOptions{
+		MaxConcurrentChapters: 3,
+		MaxConcurrentPages:    1,
+		RequestsPerSecond:     2,
+		Retry:                 DefaultRetryPolicy(),
+	}
+}
+
+// Downloader orchestrates manga downloads as a streaming pipeline.
+//
+// A Downloader is safe for concurrent use: chapters may be downloaded from
+// several goroutines, e.g. the queue and the TUI, and the Set methods may be
+// called while they are. A chapter uses the settings current when it starts.
 type Downloader struct {
 	source       sources.Source
 	repo         Repository
 	downloadDir  string
-	client       *http.Client
-	rateLimiter  *time.Ticker
-	progressChan chan DownloadProgress
+	client       *http.Client // Requests of no particular source, see utils.HTTPClient
+	options      DownloaderOptions
+	rateLimiter  *rateLimiter
+	hostLimiters *hostLimiters
+	limiter      *utils.HostLimiter // Shared with the sources, honours 429 backoffs
+	progress     *progressHub
+	progressOnce sync.Once               // Subscribes progressChan on first use
+	progressChan <-chan DownloadProgress // Subscription returned by GetProgressChannel
+	transfers    *transfers
+
+	mu      sync.RWMutex
+	set     downloaderSettings // Guarded by mu, see settings
+	quotaMu sync.Mutex         // Held while the storage quota is enforced
+}
+
+// downloaderSettings are the Downloader settings changed by its Set methods
+type downloaderSettings struct {
+	covers     *CoverCache     // Manga covers, nil downloads them every time
+	thumbnails *ThumbnailCache // Page thumbnails of the chapters written, if set
+	checksums  ChecksumStore   // Records the checksums of the books written, if set
+	notifier   *Notifier       // Posts download events to webhooks, if set
+	manga      SettingsStore   // Download settings of each manga, if set
+	quota      QuotaStore      // Storage quota enforced after downloads, if set
+	onEvict    func(*EvictionReport, error)
+
+	archivePasswords []string
+	webtoon          *integrations.WebtoonOptions
+	ocr              *integrations.OCROptions
+	altText          integrations.AltTextMode
+	imageCheck       integrations.ImageCheck
+	paths            PathTemplates
+	preprocess       integrations.PreprocessOptions
+	transcode        bool
+	force            bool            // Download chapters again even when already downloaded
+	format           string          // Chapter book format, the manga's setting when empty, see DownloadSettings
+	ctx              context.Context // Downloads stop when it is cancelled, see SetContext
+}
+
+// settings returns a copy of the current settings
+func (d *Downloader) settings() downloaderSettings {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.set
+}
+
+// DownloadSettings are the settings of a single DownloadMangaBundled call,
+// used in place of the ones set on the Downloader so concurrent downloads
+// don't change each other's
+type DownloadSettings struct {
+	Webtoon *integrations.WebtoonOptions // Slices long strips into pages when set
+	OCR     *integrations.OCROptions     // Recognizes the text of the pages when set
+	AltText integrations.AltTextMode     // How the ALT text of the pages is worded
+	Force   bool                         // Downloads chapters already downloaded again
+	Format  string                       // BatchEPUB or BatchCBZ, the manga's setting when empty
+}
+
+// with returns the settings with the ones of a single download in place of
+// theirs, the settings themselves when download is nil
+func (s downloaderSettings) with(download *DownloadSettings) downloaderSettings {
+	if download == nil {
+		return s
+	}
+	s.webtoon = download.Webtoon
+	s.ocr = download.OCR
+	s.altText = download.AltText
+	s.force = download.Force
+	s.format = download.Format
+	return s
 }
 
-// NewDownloader creates a new Downloader instance
+// context returns the context downloads stop on, never nil
+func (s downloaderSettings) context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// mangaSettings returns the download settings of manga, empty ones when
+// there are none
+func (s downloaderSettings) mangaSettings(manga *data.Manga) *data.MangaSettings {
+	if s.manga == nil {
+		return &data.MangaSettings{MangaID: manga.ID}
+	}
+	settings, err := s.manga.GetMangaSettings(manga.ID)
+	if err != nil {
+		log.Warn("failed to get manga settings", "manga_id", manga.ID, "err", err)
+		return &data.MangaSettings{MangaID: manga.ID}
+	}
+	return settings
+}
+
+// chapterFormat returns the format chapters of manga are written in
+func (s downloaderSettings) chapterFormat(manga *data.Manga) string {
+	if s.format != "" {
+		return s.format
+	}
+	return s.mangaSettings(manga).Format
+}
+
+// cleanPage transcodes then preprocesses a downloaded page, as the settings
+// ask
+func (s downloaderSettings) cleanPage(page integrations.ImageData) (integrations.ImageData, error) {
+	if s.transcode {
+		var err error
+		if page, err = integrations.TranscodePage(page); err != nil {
+			return page, err
+		}
+	}
+	return integrations.PreprocessPage(page, s.preprocess)
+}
+
+// pages returns the emit function of streamPages passing the pages of a
+// chapter to add in reading order: webtoon strips are sliced into pages
+// when on, then every page is cleaned up and renumbered
+func (s downloaderSettings) pages(add func(integrations.ImageData) error) func(integrations.ImageData) error {
+	index := 0
+	return func(image integrations.ImageData) error {
+		images, err := sliceWebtoon([]integrations.ImageData{image}, s.webtoon)
+		if err != nil {
+			return err
+		}
+		for _, page := range images {
+			page.Index = index
+			index++
+			if page, err = s.cleanPage(page); err != nil {
+				return fmt.Errorf("failed to clean up page %d: %w", page.Index, err)
+			}
+			if err := add(page); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// NewDownloader creates a new Downloader instance with default options
 func NewDownloader(source sources.Source, repo Repository, downloadDir string) *Downloader {
-	return &Downloader{
+	return NewDownloaderWithOptions(source, repo, downloadDir, DefaultDownloaderOptions())
+}
+
+// NewDownloaderWithOptions creates a Downloader with custom concurrency and rate limits.
+// Non-positive concurrency values fall back to the defaults.
+func NewDownloaderWithOptions(source sources.Source, repo Repository, downloadDir string, options DownloaderOptions) *Downloader {
+	defaults := DefaultDownloaderOptions()
+	if options.MaxConcurrentChapters <= 0 {
+		options.MaxConcurrentChapters = defaults.MaxConcurrentChapters
+	}
+	if options.MaxConcurrentPages <= 0 {
+		options.MaxConcurrentPages = defaults.MaxConcurrentPages
+	}
+	if options.Retry.Attempts <= 0 {
+		options.Retry.Attempts = 1
+	}
+
+	progress := newProgressHub()
+
+	d := &Downloader{
 		source:       source,
 		repo:         repo,
 		downloadDir:  downloadDir,
-		client:       http.DefaultClient,
-		rateLimiter:  time.NewTicker(500 * time.Millisecond), // 2 req/sec
-		progressChan: make(chan DownloadProgress, 100),
+		client:       utils.HTTPClient(""),
+		options:      options,
+		rateLimiter:  newRateLimiter(options.RequestsPerSecond),
+		hostLimiters: newHostLimiters(options.PerHostLimits),
+		limiter:      utils.SharedLimiter,
+		progress:     progress,
+		transfers:    newTransfers(),
+	}
+	covers := NewCoverCache(DefaultCoverCacheDir())
+	covers.Retry = options.Retry
+	d.SetCoverCache(covers)
+	return d
+}
+
+// SetCoverCache sets where manga covers are cached, nil downloads them for
+// every chapter
+func (d *Downloader) SetCoverCache(cache *CoverCache) {
+	if cache != nil {
+		cache.BeforeRequest = d.throttle
 	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.set.covers = cache
 }
 
-// GetProgressChannel returns the channel for receiving download progress updates
+// SetThumbnailCache keeps a strip of page thumbnails of every chapter
+// written in cache, nil keeps none
+func (d *Downloader) SetThumbnailCache(cache *ThumbnailCache) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.set.thumbnails = cache
+}
+
+// SetChecksumStore records the SHA-256 of every book written, and of its
+// pages, in store so 'mangas verify' can find damaged files
+func (d *Downloader) SetChecksumStore(store ChecksumStore) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.set.checksums = store
+}
+
+// SetImageCheck sets how downloaded pages are checked, see
+// integrations.ImageCheck. Damaged pages are downloaded again.
+func (d *Downloader) SetImageCheck(check integrations.ImageCheck) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.set.imageCheck = check
+}
+
+// SetArchivePasswords sets the passwords tried when a source delivers a
+// protected chapter archive, after the ones the source provides itself
+func (d *Downloader) SetArchivePasswords(passwords ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.set.archivePasswords = passwords
+}
+
+// SetWebtoon slices long vertical strips into screen-height pages before they
+// are added to EPUBs. nil turns slicing off.
+func (d *Downloader) SetWebtoon(options *integrations.WebtoonOptions) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.set.webtoon = options
+}
+
+// SetOCR recognizes the text of the pages of the EPUBs written, making them
+// searchable. nil turns recognition off.
+func (d *Downloader) SetOCR(options *integrations.OCROptions) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.set.ocr = options
+}
+
+// SetAltText sets how the ALT text of the pages of the EPUBs written is
+// worded, see integrations.AltTextMode
+func (d *Downloader) SetAltText(mode integrations.AltTextMode) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.set.altText = mode
+}
+
+// SetPreprocess sets the clean-ups run on downloaded pages, such as margin
+// trimming, before they are added to EPUBs
+func (d *Downloader) SetPreprocess(options integrations.PreprocessOptions) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.set.preprocess = options
+}
+
+// SetTranscode converts pages in formats many readers can't render, such as
+// WebP and AVIF, to JPEG before they are added to EPUBs
+func (d *Downloader) SetTranscode(transcode bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.set.transcode = transcode
+}
+
+// SetContext stops the downloads when ctx is cancelled: requests in flight
+// are aborted, chapters not finished are discarded along with their temp
+// files and chapters not started are left alone, to be downloaded later.
+// Interrupted chapters are not reported as failed.
+func (d *Downloader) SetContext(ctx context.Context) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.set.ctx = ctx
+}
+
+// SetForce makes DownloadManga download chapters again even when the
+// library has them downloaded, instead of skipping them
+func (d *Downloader) SetForce(force bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.set.force = force
+}
+
+// SetMangaSettings makes downloads follow the settings of each manga kept
+// in store: its chapters are written in its format, and DownloadManga
+// bundles them as it is set to
+func (d *Downloader) SetMangaSettings(store SettingsStore) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.set.manga = store
+}
+
+// SetQuotaStore enforces the storage quota kept in store after downloads,
+// evicting chapters of the download directory but never the ones just
+// downloaded
+func (d *Downloader) SetQuotaStore(store QuotaStore) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.set.quota = store
+}
+
+// OnEviction calls report after downloads evicted chapters to get under the
+// storage quota, left the downloads over it or failed to enforce it
+func (d *Downloader) OnEviction(report func(*EvictionReport, error)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.set.onEvict = report
+}
+
+// SetPathTemplates sets where books are written under the download
+// directory. Empty templates keep the default layout.
+func (d *Downloader) SetPathTemplates(templates PathTemplates) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.set.paths = templates
+}
+
+// SetNotifier posts the chapters downloaded, the manga downloads finished
+// and the chapters failing to webhooks, nil notifies nobody
+func (d *Downloader) SetNotifier(notifier *Notifier) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.set.notifier = notifier
+}
+
+// GetProgressChannel returns the shared channel for receiving download progress updates.
+// Updates are buffered from the first call until read; use SubscribeProgress for an
+// independent reader that can unsubscribe.
 func (d *Downloader) GetProgressChannel() <-chan DownloadProgress {
+	d.progressOnce.Do(func() {
+		d.progressChan, _ = d.progress.subscribe()
+	})
 	return d.progressChan
 }
 
-// DownloadManga downloads all chapters of a manga
+// SubscribeProgress returns a new channel receiving download progress updates
+// and a function to unsubscribe. Page updates a slow reader has not received
+// yet are coalesced, state changes are always delivered.
+func (d *Downloader) SubscribeProgress() (<-chan DownloadProgress, func()) {
+	return d.progress.subscribe()
+}
+
+// DownloadManga downloads all chapters of a manga, bundled as the manga is
+// set to, one book per chapter by default
 func (d *Downloader) DownloadManga(manga *data.Manga, chapters []*data.Chapter) error {
 	if manga == nil {
 		return fmt.Errorf("manga cannot be nil")
 	}
+	return d.DownloadMangaBundled(manga, chapters, d.bundleMode(manga), nil)
+}
+
+// bundleMode returns how the chapters of manga are bundled as it is set to,
+// per chapter by default
+func (d *Downloader) bundleMode(manga *data.Manga) BundleMode {
+	settings := d.settings().mangaSettings(manga)
+	mode, err := ParseBundleMode(settings.BundleMode)
+	if err != nil || settings.Format == BatchCBZ {
+		return BundleChapter
+	}
+	return mode
+}
+
+// DownloadMangaBundled downloads all chapters of a manga, grouping them into
+// EPUBs according to mode. The chapters are downloaded with download's
+// settings when it is set, the Downloader's otherwise.
+func (d *Downloader) DownloadMangaBundled(manga *data.Manga, chapters []*data.Chapter, mode BundleMode, download *DownloadSettings) error {
+	if manga == nil {
+		return fmt.Errorf("manga cannot be nil")
+	}
+	settings := d.settings().with(download)
 
 	// Save manga to database
 	manga.Status = "downloading"
@@ -76,35 +457,58 @@ func (d *Downloader) DownloadManga(manga *data.Manga, chapters []*data.Chapter)
 	// Get chapters if not provided
 	if len(chapters) == 0 {
 		var err error
-		chapters, err = d.source.GetChapters(manga)
+		chapters, err = d.sourceFor(manga).GetChapters(manga)
 		if err != nil {
 			return fmt.Errorf("failed to get chapters: %w", err)
 		}
 	}
 
-	// Download chapters with concurrency control
+	// Download chapters, or whole volumes, with concurrency control
+	bundles := bundleChapters(chapters, mode)
+	if !settings.force {
+		bundles = d.skipDownloaded(manga, bundles)
+	}
+	downloading := 0
+	for _, bundle := range bundles {
+		downloading += len(bundle.chapters)
+	}
+	defer d.transfers.start(manga.ID, downloading)()
+	ctx := settings.context()
 	var wg sync.WaitGroup
-	semaphore := make(chan struct{}, 3) // Max 3 concurrent downloads
-	errorChan := make(chan error, len(chapters))
+	semaphore := make(chan struct{}, d.options.MaxConcurrentChapters)
+	errorChan := make(chan error, len(bundles))
+	// Chapters of the bundles downloaded, or failed, this run
+	var downloaded, failed atomic.Int32
 
-	for _, chapter := range chapters {
+	for _, bundle := range bundles {
 		wg.Add(1)
-		go func(chapter *data.Chapter) {
+		go func(bundle chapterBundle) {
 			defer wg.Done()
 			semaphore <- struct{}{}
 			defer func() { <-semaphore }()
+			if ctx.Err() != nil {
+				return // Interrupted, the chapter stays to download
+			}
 
-			if err := d.DownloadChapter(manga, chapter); err != nil {
+			if bundle.volume != "" {
+				if err := d.downloadVolume(manga, bundle.volume, bundle.chapters, settings); err != nil {
+					errorChan <- fmt.Errorf("volume %s: %w", bundle.volume, err)
+					failed.Add(int32(len(bundle.chapters)))
+				} else {
+					downloaded.Add(int32(len(bundle.chapters)))
+				}
+				return
+			}
+
+			chapter := bundle.chapters[0]
+			if err := d.downloadChapter(d.sourceForChapter(manga, chapter), manga, chapter, settings); err != nil {
 				errorChan <- fmt.Errorf("chapter %s: %w", chapter.Number, err)
-				d.sendProgress(DownloadProgress{
-					MangaID:       manga.ID,
-					ChapterID:     chapter.ID,
-					ChapterNumber: chapter.Number,
-					Status:        "error",
-					Error:         err,
-				})
+				d.sendChapterError(manga, chapter, err)
+				failed.Add(1)
+			} else {
+				downloaded.Add(1)
 			}
-		}(chapter)
+		}(bundle)
 	}
 
 	wg.Wait()
@@ -116,16 +520,101 @@ func (d *Downloader) DownloadManga(manga *data.Manga, chapters []*data.Chapter)
 		downloadErrors = append(downloadErrors, err)
 	}
 
-	if len(downloadErrors) > 0 {
+	if downloaded.Load() > 0 {
+		var done []*data.Chapter
+		for _, bundle := range bundles {
+			done = append(done, bundle.chapters...)
+		}
+		d.enforceQuota(done)
+	}
+
+	interrupted := ctx.Err()
+	if len(downloadErrors) > 0 || interrupted != nil {
 		manga.Status = "partial"
 	} else {
 		manga.Status = "completed"
 	}
-	d.repo.SaveManga(manga)
+	if err := d.repo.SaveManga(manga); err != nil {
+		log.Warn("failed to save manga status", "manga_id", manga.ID, "status", manga.Status, "err", err)
+	}
+	if interrupted != nil {
+		return interrupted
+	}
+
+	// Nothing was left to download, so there is nothing to report
+	if downloaded.Load()+failed.Load() > 0 {
+		event := newNotifyEvent(EventManga, manga, nil)
+		event.Downloaded, event.Failed = int(downloaded.Load()), int(failed.Load())
+		settings.notifier.Notify(event)
+	}
 
 	return nil
 }
 
+// AlreadyDownloaded returns the chapters the library has downloaded, their
+// file still on disk, and marks them Downloaded with their file path
+func (d *Downloader) AlreadyDownloaded(manga *data.Manga, chapters []*data.Chapter) []*data.Chapter {
+	library, err := d.repo.GetChapters(manga.ID)
+	if err != nil {
+		log.Warn("failed to get library chapters", "manga_id", manga.ID, "err", err)
+		return nil
+	}
+	stored := make(map[string]*data.Chapter, len(library))
+	for _, chapter := range library {
+		stored[chapter.ID] = chapter
+	}
+
+	var downloaded []*data.Chapter
+	for _, chapter := range chapters {
+		known := stored[chapter.ID]
+		if known == nil || !known.Downloaded || known.FilePath == "" {
+			continue
+		}
+		if _, err := os.Stat(known.FilePath); err != nil {
+			continue
+		}
+		chapter.Downloaded = true
+		chapter.FilePath = known.FilePath
+		downloaded = append(downloaded, chapter)
+	}
+	return downloaded
+}
+
+// skipDownloaded drops the bundles whose chapters are all already
+// downloaded. A volume missing a chapter is downloaded whole, as its EPUB is
+// written again.
+func (d *Downloader) skipDownloaded(manga *data.Manga, bundles []chapterBundle) []chapterBundle {
+	var chapters []*data.Chapter
+	for _, bundle := range bundles {
+		chapters = append(chapters, bundle.chapters...)
+	}
+	downloaded := make(map[*data.Chapter]bool)
+	for _, chapter := range d.AlreadyDownloaded(manga, chapters) {
+		downloaded[chapter] = true
+	}
+
+	var kept []chapterBundle
+	for _, bundle := range bundles {
+		skip := true
+		for _, chapter := range bundle.chapters {
+			skip = skip && downloaded[chapter]
+		}
+		if !skip {
+			kept = append(kept, bundle)
+			continue
+		}
+		for _, chapter := range bundle.chapters {
+			chapterLogger(manga, chapter).Info("chapter already downloaded, skipped", "path", chapter.FilePath)
+		}
+	}
+	return kept
+}
+
+// chapterLogger returns a logger recording the manga and chapter downloaded
+func chapterLogger(manga *data.Manga, chapter *data.Chapter) *slog.Logger {
+	return log.With("manga_id", manga.ID, "manga", manga.Name, "chapter_id", chapter.ID, "chapter", chapter.Number)
+}
+
 // DownloadChapter downloads a single chapter and streams it to an EPUB
 func (d *Downloader) DownloadChapter(manga *data.Manga, chapter *data.Chapter) error {
 	if manga == nil {
@@ -134,8 +623,28 @@ func (d *Downloader) DownloadChapter(manga *data.Manga, chapter *data.Chapter) e
 	if chapter == nil {
 		return fmt.Errorf("chapter cannot be nil")
 	}
+	return d.downloadChapter(d.sourceForChapter(manga, chapter), manga, chapter, d.settings())
+}
+
+// downloadChapter downloads a chapter with the pages listed by source
+func (d *Downloader) downloadChapter(source sources.Source, manga *data.Manga, chapter *data.Chapter, settings downloaderSettings) (err error) {
+	logger := chapterLogger(manga, chapter)
+	logger.Info("downloading chapter")
+	defer func() {
+		if errors.Is(err, context.Canceled) {
+			logger.Info("chapter download interrupted")
+		} else if err != nil {
+			logger.Error("chapter download failed", "err", err)
+			event := newNotifyEvent(EventError, manga, chapter)
+			event.Error = err.Error()
+			settings.notifier.Notify(event)
+		}
+	}()
 
-	<-d.rateLimiter.C // Rate limiting
+	d.rateLimiter.Wait() // Rate limiting
+	if err := settings.context().Err(); err != nil {
+		return err
+	}
 
 	d.sendProgress(DownloadProgress{
 		MangaID:       manga.ID,
@@ -145,7 +654,7 @@ func (d *Downloader) DownloadChapter(manga *data.Manga, chapter *data.Chapter) e
 	})
 
 	// Get page URLs
-	pages, err := d.source.GetPages(manga, chapter)
+	pages, err := source.GetPages(manga, chapter)
 	if err != nil {
 		return fmt.Errorf("failed to get pages: %w", err)
 	}
@@ -159,27 +668,48 @@ func (d *Downloader) DownloadChapter(manga *data.Manga, chapter *data.Chapter) e
 	if err := builder.Init(manga, chapter); err != nil {
 		return fmt.Errorf("failed to initialize EPUB builder: %w", err)
 	}
+	defer func() {
+		if err != nil {
+			builder.Discard()
+		}
+	}()
+	builder.SetOCR(settings.ocr)
+	builder.SetAltText(settings.altText)
+	builder.SetPathTemplate(settings.paths.Chapter)
+	builder.SetProgressCallback(func(p integrations.FinalizeProgress) {
+		d.sendProgress(DownloadProgress{
+			MangaID:       manga.ID,
+			ChapterID:     chapter.ID,
+			ChapterNumber: chapter.Number,
+			CurrentPage:   p.Current,
+			TotalPages:    p.Total,
+			Status:        "processing",
+			Stage:         string(p.Stage),
+		})
+	})
 
 	// Download and set manga cover
-	mangaCoverURL, err := d.source.GetMangaCoverURL(manga)
+	mangaCoverURL, err := MangaCoverURL(source, manga)
 	if err == nil && mangaCoverURL != "" {
-		coverData, err := d.downloadCoverImage(mangaCoverURL)
+		coverData, err := d.mangaCover(manga, mangaCoverURL)
 		if err == nil {
 			builder.SetMangaCover(coverData)
+		} else {
+			// Non-fatal error, continue even if cover download fails
+			logger.Warn("manga cover download failed", "url", mangaCoverURL, "err", err)
 		}
-		// Non-fatal error, continue even if cover download fails
-		<-d.rateLimiter.C // Rate limiting
 	}
 
 	// Download and set chapter cover (if different from manga cover)
-	chapterCoverURL, err := d.source.GetChapterCoverURL(manga, chapter)
+	chapterCoverURL, err := source.GetChapterCoverURL(manga, chapter)
 	if err == nil && chapterCoverURL != "" && chapterCoverURL != mangaCoverURL {
-		coverData, err := d.downloadCoverImage(chapterCoverURL)
+		coverData, err := d.downloadCoverImage(statsSource(manga), chapterCoverURL)
 		if err == nil {
 			builder.SetChapterCover(coverData)
+		} else {
+			// Non-fatal error, continue even if cover download fails
+			logger.Warn("chapter cover download failed", "url", chapterCoverURL, "err", err)
 		}
-		// Non-fatal error, continue even if cover download fails
-		<-d.rateLimiter.C // Rate limiting
 	}
 
 	d.sendProgress(DownloadProgress{
@@ -190,31 +720,27 @@ func (d *Downloader) DownloadChapter(manga *data.Manga, chapter *data.Chapter) e
 		Status:        "downloading",
 	})
 
-	// Stream images to EPUB builder
-	for i, pageURL := range pages {
-		d.sendProgress(DownloadProgress{
-			MangaID:       manga.ID,
-			ChapterID:     chapter.ID,
-			ChapterNumber: chapter.Number,
-			CurrentPage:   i + 1,
-			TotalPages:    len(pages),
-			Status:        "downloading",
-		})
-
-		imageData, err := d.downloadImage(pageURL, i)
-		if err != nil {
-			return fmt.Errorf("failed to download page %d: %w", i, err)
+	// Stream images to EPUB builder as they arrive
+	var sums []string
+	var thumbnails [][]byte
+	addPage := func(image integrations.ImageData) error {
+		sums = append(sums, integrations.HashPage(image.Content))
+		if settings.thumbnails != nil && len(thumbnails) < thumbnailPages {
+			thumbnails = append(thumbnails, image.Content)
 		}
-
-		// Stream image to builder
-		if err := builder.Next(imageData); err != nil {
-			return fmt.Errorf("failed to add page %d to EPUB: %w", i, err)
+		if err := builder.NextReader(bytes.NewReader(image.Content), image.ContentType, image.Order()); err != nil {
+			return fmt.Errorf("failed to add page %d to EPUB: %w", image.Index, err)
 		}
-
-		<-d.rateLimiter.C // Rate limiting between pages
+		return nil
+	}
+	if err := d.streamPages(source, manga, chapter, pages, settings.pages(addPage)); err != nil {
+		return err
 	}
 
-	// Finalize EPUB
+	// Finalize EPUB, unless interrupted while the pages were downloaded
+	if err := settings.context().Err(); err != nil {
+		return err
+	}
 	d.sendProgress(DownloadProgress{
 		MangaID:       manga.ID,
 		ChapterID:     chapter.ID,
@@ -227,6 +753,11 @@ func (d *Downloader) DownloadChapter(manga *data.Manga, chapter *data.Chapter) e
 	if err != nil {
 		return fmt.Errorf("failed to finalize EPUB: %w", err)
 	}
+	if settings.chapterFormat(manga) == BatchCBZ {
+		if epubPath, err = integrations.RepackEPUBAsCBZ(manga, chapter, epubPath); err != nil {
+			return fmt.Errorf("failed to write CBZ: %w", err)
+		}
+	}
 
 	// Update chapter status
 	chapter.Downloaded = true
@@ -234,6 +765,8 @@ func (d *Downloader) DownloadChapter(manga *data.Manga, chapter *data.Chapter) e
 	if err := d.repo.UpdateChapterStatus(chapter.ID, true, epubPath); err != nil {
 		return fmt.Errorf("failed to update chapter status: %w", err)
 	}
+	d.recordChecksums(epubPath, []*data.Chapter{chapter}, map[string][]string{chapter.ID: sums})
+	d.saveThumbnails(chapter, thumbnails)
 
 	d.sendProgress(DownloadProgress{
 		MangaID:       manga.ID,
@@ -242,89 +775,393 @@ func (d *Downloader) DownloadChapter(manga *data.Manga, chapter *data.Chapter) e
 		TotalPages:    len(pages),
 		Status:        "complete",
 	})
+	logger.Info("chapter downloaded", "pages", len(pages), "path", epubPath)
+	settings.notifier.Notify(newNotifyEvent(EventChapter, manga, chapter))
 
 	return nil
 }
 
-// downloadImage downloads a single image and returns its data
-func (d *Downloader) downloadImage(url string, index int) (integrations.ImageData, error) {
-	resp, err := d.client.Get(url)
+// FetchPages downloads the page images of a chapter without building an EPUB
+func (d *Downloader) FetchPages(manga *data.Manga, chapter *data.Chapter) ([][]byte, error) {
+	source := d.sourceForChapter(manga, chapter)
+
+	d.rateLimiter.Wait() // Rate limiting
+	urls, err := source.GetPages(manga, chapter)
 	if err != nil {
-		return integrations.ImageData{}, fmt.Errorf("failed to fetch image: %w", err)
+		return nil, fmt.Errorf("failed to get pages: %w", err)
+	}
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("no pages found for chapter")
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return integrations.ImageData{}, fmt.Errorf("bad status: %s", resp.Status)
+	images, err := d.downloadPages(source, manga, chapter, urls)
+	if err != nil {
+		return nil, err
 	}
+	pages := make([][]byte, len(images))
+	for i, image := range images {
+		pages[i] = image.Content
+	}
+	return pages, nil
+}
 
-	// Read image content into memory
-	content, err := io.ReadAll(resp.Body)
+// FetchCover downloads the cover image of a manga, its custom cover when
+// set, from its source otherwise
+func (d *Downloader) FetchCover(manga *data.Manga) (integrations.CoverData, error) {
+	url, err := MangaCoverURL(d.sourceFor(manga), manga)
 	if err != nil {
-		return integrations.ImageData{}, fmt.Errorf("failed to read image content: %w", err)
+		return integrations.CoverData{}, err
+	}
+	if url == "" {
+		return integrations.CoverData{}, fmt.Errorf("no cover for manga %s", manga.ID)
 	}
+	return d.mangaCover(manga, url)
+}
 
-	// Determine content type
-	contentType := resp.Header.Get("Content-Type")
-	if contentType == "" {
-		contentType = "image/jpeg" // Default to JPEG
+// downloadPages downloads page URLs with up to MaxConcurrentPages workers and
+// returns the images in reading order, see streamPages
+func (d *Downloader) downloadPages(source sources.Source, manga *data.Manga, chapter *data.Chapter, urls []string) ([]integrations.ImageData, error) {
+	var images []integrations.ImageData
+	err := d.streamPages(source, manga, chapter, urls, func(image integrations.ImageData) error {
+		images = append(images, image)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// streamPages downloads page URLs with up to MaxConcurrentPages workers and
+// passes the images to emit in reading order, as soon as the pages before
+// them are done. Pages are not started further than twice
+// MaxConcurrentPages ahead of the first one not emitted, so a slow page
+// never has the rest of the chapter pile up in memory behind it.
+// Archive bundles expand into their pages, so image indexes are renumbered
+// as they are emitted. emit is never called concurrently.
+func (d *Downloader) streamPages(source sources.Source, manga *data.Manga, chapter *data.Chapter, urls []string, emit func(integrations.ImageData) error) error {
+	results := make([][]integrations.ImageData, len(urls))
+	done := make([]bool, len(urls))
+	errs := make([]error, len(urls))
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		completed int
+		bytes     int64
+		failed    bool
+		next      int // First page not emitted yet
+		emitted   int // Images emitted so far
+	)
+	ahead := sync.NewCond(&mu) // Signaled as next moves on or a page fails
+	window := 2 * d.options.MaxConcurrentPages
+	// flush emits the pages done in a row after the last emitted one, with
+	// mu held
+	flush := func() error {
+		for ; next < len(urls) && done[next]; next++ {
+			for _, image := range results[next] {
+				image.Index = emitted
+				emitted++
+				if err := emit(image); err != nil {
+					return err
+				}
+			}
+			results[next] = nil
+		}
+		return nil
+	}
+	started := time.Now()
+	semaphore := make(chan struct{}, d.options.MaxConcurrentPages)
+
+	for i, pageURL := range urls {
+		wg.Add(1)
+		go func(i int, pageURL string) {
+			defer wg.Done()
+
+			// Wait for the pages before to be emitted
+			mu.Lock()
+			for !failed && i >= next+window {
+				ahead.Wait()
+			}
+			mu.Unlock()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			// Stop early once a page failed, the chapter is lost anyway
+			mu.Lock()
+			stop := failed
+			mu.Unlock()
+			if stop {
+				return
+			}
+
+			fail := func(err error) {
+				mu.Lock()
+				failed = true
+				errs[i] = err
+				ahead.Broadcast()
+				mu.Unlock()
+			}
+
+			imageData, retries, err := d.pageImage(source, manga, pageURL, i)
+			if err != nil {
+				fail(fmt.Errorf("failed to download page %d after %d retries: %w", i, retries, err))
+				return
+			}
+
+			images := []integrations.ImageData{imageData}
+			if DetectArchive(imageData.Content) != ArchiveNone {
+				images, err = ExtractArchive(imageData.Content, d.archivePasswordsFor(source, manga, chapter))
+				if err != nil {
+					fail(fmt.Errorf("failed to extract page %d: %w", i, err))
+					return
+				}
+			}
+			d.transfers.addBytes(manga.ID, len(imageData.Content))
+
+			mu.Lock()
+			results[i], done[i] = images, true
+			if err := flush(); err != nil {
+				failed = true
+				errs[i] = err
+			}
+			ahead.Broadcast()
+			completed++
+			bytes += int64(len(imageData.Content))
+			current, received := completed, bytes
+			mu.Unlock()
+
+			elapsed := time.Since(started)
+			d.sendProgress(DownloadProgress{
+				MangaID:       manga.ID,
+				ChapterID:     chapter.ID,
+				ChapterNumber: chapter.Number,
+				CurrentPage:   current,
+				TotalPages:    len(urls),
+				Status:        "downloading",
+				Retries:       retries,
+				Bytes:         received,
+				Speed:         transferSpeed(received, elapsed),
+				ETA:           EstimateETA(elapsed, float64(current), float64(len(urls))),
+			})
+		}(i, pageURL)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// downloadImage downloads a single image and returns its data
+func (d *Downloader) downloadImage(url string, index int) (integrations.ImageData, error) {
+	image, _, err := d.downloadImageWithRetries("", url, index)
+	return image, err
+}
+
+// downloadImageWithRetries downloads a single image from source, also
+// returning how many retries it took
+func (d *Downloader) downloadImageWithRetries(source, url string, index int) (integrations.ImageData, int, error) {
+	content, contentType, retries, err := d.fetch(source, url)
+	if err != nil {
+		return integrations.ImageData{}, retries, fmt.Errorf("failed to fetch image: %w", err)
 	}
 
 	return integrations.ImageData{
 		Content:     content,
 		ContentType: contentType,
 		Index:       index,
-	}, nil
+	}, retries, nil
 }
 
-// downloadCoverImage downloads a cover image and returns its data
-func (d *Downloader) downloadCoverImage(url string) (integrations.CoverData, error) {
-	resp, err := d.client.Get(url)
+// mangaCover returns the cover of a manga at url, from the cover cache
+func (d *Downloader) mangaCover(manga *data.Manga, url string) (integrations.CoverData, error) {
+	covers := d.settings().covers
+	if covers == nil {
+		if isLocalCover(url) {
+			return readCoverFile(url)
+		}
+		return d.downloadCoverImage(statsSource(manga), url)
+	}
+	return covers.Get(statsSource(manga), manga.ID, url)
+}
+
+// downloadCoverImage downloads a cover image from source and returns its data
+func (d *Downloader) downloadCoverImage(source, url string) (integrations.CoverData, error) {
+	content, contentType, _, err := d.fetch(source, url)
 	if err != nil {
 		return integrations.CoverData{}, fmt.Errorf("failed to fetch cover image: %w", err)
 	}
+
+	return integrations.CoverData{
+		Content:     content,
+		ContentType: contentType,
+	}, nil
+}
+
+// fetch downloads an image, retrying network errors and retryable statuses
+// according to the retry policy. Requests are counted in utils.Stats under
+// source, unless empty.
+func (d *Downloader) fetch(source, url string) ([]byte, string, int, error) {
+	policy := d.options.Retry
+	ctx := d.settings().context()
+
+	var lastErr error
+	for attempt := 0; attempt < policy.Attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(policy.delay(attempt)):
+			case <-ctx.Done():
+				return nil, "", attempt, ctx.Err()
+			}
+		}
+
+		content, contentType, retryable, err := d.fetchOnce(ctx, source, url)
+		if err == nil {
+			return content, contentType, attempt, nil
+		}
+		lastErr = err
+		if !retryable || ctx.Err() != nil {
+			return nil, "", attempt, err
+		}
+		log.Debug("request failed, retrying", "url", url, "attempt", attempt+1, "err", err)
+	}
+	return nil, "", policy.Attempts - 1, lastErr
+}
+
+// fetchOnce performs a single rate-limited GET, reporting whether a failure
+// is worth retrying
+func (d *Downloader) fetchOnce(ctx context.Context, source, url string) (content []byte, contentType string, retryable bool, err error) {
+	d.throttle(url)
+	start := time.Now()
+	if source != "" {
+		defer func() {
+			utils.Stats.Record(source, time.Since(start), int64(len(content)), err)
+		}()
+	}
+
+	client := d.client
+	if source != "" {
+		client = utils.HTTPClient(source)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", false, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", true, err
+	}
 	defer resp.Body.Close()
+	d.limiter.Observe(resp)
 
 	if resp.StatusCode != http.StatusOK {
-		return integrations.CoverData{}, fmt.Errorf("bad status for cover image: %s", resp.Status)
+		return nil, "", d.options.Retry.shouldRetry(resp.StatusCode), fmt.Errorf("bad status: %s", resp.Status)
 	}
 
 	// Read image content into memory
-	content, err := io.ReadAll(resp.Body)
+	content, err = io.ReadAll(resp.Body)
 	if err != nil {
-		return integrations.CoverData{}, fmt.Errorf("failed to read cover image content: %w", err)
+		return nil, "", true, fmt.Errorf("failed to read image content: %w", err)
+	}
+
+	// Damaged images are downloaded again, archives are unpacked later
+	contentType = resp.Header.Get("Content-Type")
+	if DetectArchive(content) == ArchiveNone {
+		if err := integrations.CheckImage(content, contentType, d.settings().imageCheck); err != nil {
+			return nil, "", true, fmt.Errorf("damaged image: %w", err)
+		}
 	}
 
 	// Determine content type
-	contentType := resp.Header.Get("Content-Type")
 	if contentType == "" {
 		contentType = "image/jpeg" // Default to JPEG
 	}
 
-	return integrations.CoverData{
-		Content:     content,
-		ContentType: contentType,
-	}, nil
+	return content, contentType, false, nil
+}
+
+// throttle waits for the global and per-host rate limits, and any backoff
+// a server asked for, before a request
+func (d *Downloader) throttle(url string) {
+	d.rateLimiter.Wait()
+	d.hostLimiters.Wait(url)
+	d.limiter.Wait(url)
+}
+
+// statsSource returns the name the requests for a manga are counted under
+func statsSource(manga *data.Manga) string {
+	if manga.Source != "" {
+		return manga.Source
+	}
+	return sources.DefaultSource
+}
+
+// sourceFor returns the source the manga was added from, defaulting to the
+// downloader's source
+func (d *Downloader) sourceFor(manga *data.Manga) sources.Source {
+	return sources.ForManga(manga, d.source)
+}
+
+// sourceForChapter returns the source of a chapter, which is not its manga's
+// one when it was synced from a manga linked from another source
+func (d *Downloader) sourceForChapter(manga *data.Manga, chapter *data.Chapter) sources.Source {
+	if chapter.Source != "" && chapter.Source != manga.Source {
+		if source, err := sources.Get(chapter.Source); err == nil {
+			return source
+		}
+	}
+	return d.sourceFor(manga)
 }
 
-// sendProgress sends a progress update (non-blocking)
+// archivePasswordsFor returns the passwords to try for a chapter archive
+func (d *Downloader) archivePasswordsFor(source sources.Source, manga *data.Manga, chapter *data.Chapter) []string {
+	var passwords []string
+	if provider, ok := source.(sources.ArchivePasswordProvider); ok {
+		passwords = append(passwords, provider.ArchivePasswords(manga, chapter)...)
+	}
+	return append(passwords, d.settings().archivePasswords...)
+}
+
+// sendProgress publishes a progress update to every subscriber (non-blocking)
 func (d *Downloader) sendProgress(progress DownloadProgress) {
-	select {
-	case d.progressChan <- progress:
-	default:
-		// Channel full, skip this update
+	d.transfers.annotate(&progress)
+	d.progress.publish(progress)
+}
+
+// sliceWebtoon slices the strips among images when webtoon mode is on
+func sliceWebtoon(images []integrations.ImageData, options *integrations.WebtoonOptions) ([]integrations.ImageData, error) {
+	if options == nil {
+		return images, nil
+	}
+	sliced, err := integrations.SliceWebtoon(images, *options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to slice webtoon pages: %w", err)
 	}
+	return sliced, nil
 }
 
-// Close cleans up resources
-func (d *Downloader) Close() {
-	d.rateLimiter.Stop()
-	
-	// Close progress channel safely
-	select {
-	case <-d.progressChan:
-		// Already closed
-	default:
-		close(d.progressChan)
+// sendChapterError publishes the failure of a chapter
+func (d *Downloader) sendChapterError(manga *data.Manga, chapter *data.Chapter, err error) {
+	if errors.Is(err, context.Canceled) {
+		return // Interrupted, not failed
 	}
+	d.sendProgress(DownloadProgress{
+		MangaID:       manga.ID,
+		ChapterID:     chapter.ID,
+		ChapterNumber: chapter.Number,
+		Status:        "error",
+		Error:         err,
+	})
+}
+
+// Close waits for the webhook notifications still being sent, then cleans
+// up resources. Progress channels are closed once their pending updates
+// have been read.
+func (d *Downloader) Close() {
+	d.settings().notifier.Wait()
+	d.progress.close()
 }
@@ -2,20 +2,30 @@ package services
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/sources"
 )
 
 // Mock implementations for testing
 
 type mockSource struct {
-	searchFunc            func(query string) ([]*data.Manga, error)
+	searchFunc            func(query string, options sources.SearchOptions) ([]*data.Manga, error)
 	getMangaFunc          func(id string) (*data.Manga, error)
 	getChaptersFunc       func(manga *data.Manga) ([]*data.Chapter, error)
 	getPagesFunc          func(manga *data.Manga, chapter *data.Chapter) ([]string, error)
@@ -23,9 +33,9 @@ type mockSource struct {
 	getChapterCoverURLFunc func(manga *data.Manga, chapter *data.Chapter) (string, error)
 }
 
-func (m *mockSource) Search(query string) ([]*data.Manga, error) {
+func (m *mockSource) Search(query string, options sources.SearchOptions) ([]*data.Manga, error) {
 	if m.searchFunc != nil {
-		return m.searchFunc(query)
+		return m.searchFunc(query, options)
 	}
 	return nil, nil
 }
@@ -73,6 +83,8 @@ type mockRepository struct {
 	updateChapterStatusFunc func(chapterID string, downloaded bool, filePath string) error
 	listMangasFunc          func() ([]*data.Manga, error)
 	deleteMangaFunc         func(mangaID string) error
+	saveRelationsFunc       func(mangaID string, relations []*data.Relation) error
+	getRelationsFunc        func(mangaID string) ([]*data.Relation, error)
 }
 
 func (m *mockRepository) SaveManga(manga *data.Manga) error {
@@ -124,6 +136,20 @@ func (m *mockRepository) DeleteManga(mangaID string) error {
 	return nil
 }
 
+func (m *mockRepository) SaveRelations(mangaID string, relations []*data.Relation) error {
+	if m.saveRelationsFunc != nil {
+		return m.saveRelationsFunc(mangaID, relations)
+	}
+	return nil
+}
+
+func (m *mockRepository) GetRelations(mangaID string) ([]*data.Relation, error) {
+	if m.getRelationsFunc != nil {
+		return m.getRelationsFunc(mangaID)
+	}
+	return nil, nil
+}
+
 // Test helpers
 
 func createTestPNG() []byte {
@@ -177,8 +203,8 @@ func TestNewDownloader(t *testing.T) {
 	if downloader.rateLimiter == nil {
 		t.Error("Downloader rateLimiter not initialized")
 	}
-	if downloader.progressChan == nil {
-		t.Error("Downloader progressChan not initialized")
+	if downloader.progress == nil {
+		t.Error("Downloader progress not initialized")
 	}
 
 	downloader.Close()
@@ -386,6 +412,112 @@ func TestDownloader_DownloadManga(t *testing.T) {
 		}
 	})
 
+	t.Run("skips downloaded chapters unless forced", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(pngData)
+		}))
+		defer server.Close()
+
+		dir := t.TempDir()
+		existing := filepath.Join(dir, "existing.epub")
+		os.WriteFile(existing, []byte("epub"), 0644)
+
+		var fetched []string
+		source := &mockSource{
+			getPagesFunc: func(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+				fetched = append(fetched, chapter.ID)
+				return []string{server.URL + "/page1.png"}, nil
+			},
+		}
+		repo := &mockRepository{
+			getChaptersFunc: func(mangaID string) ([]*data.Chapter, error) {
+				return []*data.Chapter{
+					{ID: "ch-1", Downloaded: true, FilePath: existing},
+					{ID: "ch-2", Downloaded: true, FilePath: filepath.Join(dir, "deleted.epub")},
+				}, nil
+			},
+		}
+
+		downloader := NewDownloader(source, repo, dir)
+		defer downloader.Close()
+		manga := &data.Manga{ID: "manga-1", Name: "Test Manga"}
+		chapters := []*data.Chapter{
+			{ID: "ch-1", MangaID: "manga-1", Number: "1"},
+			{ID: "ch-2", MangaID: "manga-1", Number: "2"},
+		}
+
+		if err := downloader.DownloadManga(manga, chapters); err != nil {
+			t.Fatalf("DownloadManga() error = %v", err)
+		}
+		if len(fetched) != 1 || fetched[0] != "ch-2" {
+			t.Errorf("Fetched %v, want only the chapter whose file is gone", fetched)
+		}
+		if !chapters[0].Downloaded || chapters[0].FilePath != existing {
+			t.Errorf("Skipped chapter = %+v, want it downloaded at %s", chapters[0], existing)
+		}
+
+		fetched = nil
+		downloader.SetForce(true)
+		if err := downloader.DownloadManga(manga, chapters); err != nil {
+			t.Fatalf("DownloadManga() error = %v", err)
+		}
+		if len(fetched) != 2 {
+			t.Errorf("Fetched %v with force, want every chapter", fetched)
+		}
+	})
+
+	t.Run("interrupted download", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(pngData)
+		}))
+		defer server.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		source := &mockSource{
+			getPagesFunc: func(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+				cancel() // Ctrl-C while the pages are listed
+				return []string{server.URL + "/page1.png"}, nil
+			},
+		}
+		updated := false
+		repo := &mockRepository{
+			updateChapterStatusFunc: func(chapterID string, downloaded bool, filePath string) error {
+				updated = true
+				return nil
+			},
+		}
+
+		downloader := NewDownloaderWithOptions(source, repo, t.TempDir(), DownloaderOptions{MaxConcurrentChapters: 1, MaxConcurrentPages: 1, Retry: DefaultRetryPolicy()})
+		downloader.SetContext(ctx)
+		progress, unsubscribe := downloader.SubscribeProgress()
+		defer unsubscribe()
+
+		manga := &data.Manga{ID: "manga-1", Name: "Test Manga"}
+		chapters := []*data.Chapter{
+			{ID: "ch-1", MangaID: "manga-1", Number: "1"},
+			{ID: "ch-2", MangaID: "manga-1", Number: "2"},
+		}
+		err := downloader.DownloadManga(manga, chapters)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("DownloadManga() error = %v, want context.Canceled", err)
+		}
+		if updated || chapters[0].Downloaded || chapters[1].Downloaded {
+			t.Error("Expected no chapter to be marked downloaded")
+		}
+		if manga.Status != "partial" {
+			t.Errorf("Expected status 'partial', got %q", manga.Status)
+		}
+		downloader.Close()
+		for p := range progress {
+			if p.Status == "error" {
+				t.Errorf("Interrupted chapter %s reported as failed: %v", p.ChapterNumber, p.Error)
+			}
+		}
+	})
+
 	t.Run("nil manga", func(t *testing.T) {
 		downloader := NewDownloader(&mockSource{}, &mockRepository{}, t.TempDir())
 		defer downloader.Close()
@@ -612,10 +744,11 @@ func TestDownloader_sendProgress(t *testing.T) {
 		Status:    "downloading",
 	}
 
+	updates := downloader.GetProgressChannel()
 	downloader.sendProgress(progress)
 
 	select {
-	case received := <-downloader.GetProgressChannel():
+	case received := <-updates:
 		if received.MangaID != progress.MangaID {
 			t.Error("Received progress doesn't match sent progress")
 		}
@@ -682,8 +815,9 @@ func TestDownloader_Integration(t *testing.T) {
 	// Monitor progress in background
 	progressUpdates := []DownloadProgress{}
 	done := make(chan struct{})
+	updates := downloader.GetProgressChannel()
 	go func() {
-		for progress := range downloader.GetProgressChannel() {
+		for progress := range updates {
 			progressUpdates = append(progressUpdates, progress)
 		}
 		close(done)
@@ -708,6 +842,27 @@ func TestDownloader_Integration(t *testing.T) {
 		t.Error("Expected progress updates, got none")
 	}
 
+	// Completions carry the aggregate of the manga download
+	completed, bytes := 0, int64(0)
+	for _, progress := range progressUpdates {
+		if progress.Bytes > bytes {
+			bytes = progress.Bytes
+		}
+		if progress.Status != "complete" {
+			continue
+		}
+		completed++
+		if progress.MangaChapters != 2 || progress.MangaCompleted != completed {
+			t.Errorf("Expected chapter %d of 2 done, got %d of %d", completed, progress.MangaCompleted, progress.MangaChapters)
+		}
+	}
+	if completed != 2 {
+		t.Errorf("Expected 2 completed chapters, got %d", completed)
+	}
+	if bytes < int64(len(pngData)) {
+		t.Errorf("Expected downloaded bytes to be reported, got %d", bytes)
+	}
+
 	t.Logf("Received %d progress updates", len(progressUpdates))
 }
 
@@ -733,3 +888,153 @@ func BenchmarkDownloader_downloadImage(b *testing.B) {
 		}
 	}
 }
+
+func TestNewDownloaderWithOptions(t *testing.T) {
+	t.Run("custom options", func(t *testing.T) {
+		options := DownloaderOptions{
+			MaxConcurrentChapters: 5,
+			MaxConcurrentPages:    4,
+			RequestsPerSecond:     10,
+			PerHostLimits:         map[string]float64{"example.com": 1},
+		}
+		downloader := NewDownloaderWithOptions(&mockSource{}, &mockRepository{}, t.TempDir(), options)
+		defer downloader.Close()
+
+		if downloader.options.MaxConcurrentChapters != 5 || downloader.options.MaxConcurrentPages != 4 {
+			t.Errorf("Unexpected options: %+v", downloader.options)
+		}
+		if downloader.rateLimiter.interval != 100*time.Millisecond {
+			t.Errorf("Expected 100ms between requests, got %v", downloader.rateLimiter.interval)
+		}
+		if _, ok := downloader.hostLimiters.limiters["example.com"]; !ok {
+			t.Error("Expected per-host limiter for example.com")
+		}
+	})
+
+	t.Run("invalid concurrency falls back to defaults", func(t *testing.T) {
+		downloader := NewDownloaderWithOptions(&mockSource{}, &mockRepository{}, t.TempDir(), DownloaderOptions{})
+		defer downloader.Close()
+
+		defaults := DefaultDownloaderOptions()
+		if downloader.options.MaxConcurrentChapters != defaults.MaxConcurrentChapters {
+			t.Errorf("Expected %d concurrent chapters, got %d", defaults.MaxConcurrentChapters, downloader.options.MaxConcurrentChapters)
+		}
+		if downloader.options.MaxConcurrentPages != defaults.MaxConcurrentPages {
+			t.Errorf("Expected %d concurrent pages, got %d", defaults.MaxConcurrentPages, downloader.options.MaxConcurrentPages)
+		}
+	})
+}
+
+func TestDownloader_downloadPagesConcurrent(t *testing.T) {
+	pngData := createTestPNG()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Later pages respond faster, so completion order differs from page order
+		if r.URL.Path == "/page0.png" {
+			time.Sleep(30 * time.Millisecond)
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngData)
+	}))
+	defer server.Close()
+
+	downloader := NewDownloaderWithOptions(&mockSource{}, &mockRepository{}, t.TempDir(), DownloaderOptions{
+		MaxConcurrentPages: 4,
+	})
+	defer downloader.Close()
+
+	urls := []string{server.URL + "/page0.png", server.URL + "/page1.png", server.URL + "/page2.png"}
+	images, err := downloader.downloadPages(&mockSource{}, &data.Manga{ID: "m"}, &data.Chapter{ID: "c"}, urls)
+	if err != nil {
+		t.Fatalf("downloadPages() error = %v", err)
+	}
+	if len(images) != 3 {
+		t.Fatalf("Expected 3 images, got %d", len(images))
+	}
+	for i, img := range images {
+		if img.Index != i {
+			t.Errorf("Image %d has index %d", i, img.Index)
+		}
+	}
+}
+
+func TestDownloader_streamPages(t *testing.T) {
+	pngData := createTestPNG()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first page is the slowest, nothing can be emitted before it
+		if r.URL.Path == "/page0.png" {
+			time.Sleep(30 * time.Millisecond)
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(append([]byte(nil), pngData...))
+	}))
+	defer server.Close()
+
+	downloader := NewDownloaderWithOptions(&mockSource{}, &mockRepository{}, t.TempDir(), DownloaderOptions{
+		MaxConcurrentPages: 4,
+	})
+	defer downloader.Close()
+
+	urls := []string{server.URL + "/page0.png", server.URL + "/page1.png", server.URL + "/page2.png", server.URL + "/page3.png"}
+
+	t.Run("emits pages in reading order", func(t *testing.T) {
+		var indexes []int
+		err := downloader.streamPages(&mockSource{}, &data.Manga{ID: "m"}, &data.Chapter{ID: "c"}, urls, func(image integrations.ImageData) error {
+			indexes = append(indexes, image.Index)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("streamPages() error = %v", err)
+		}
+		if !reflect.DeepEqual(indexes, []int{0, 1, 2, 3}) {
+			t.Errorf("Expected pages emitted in order, got %v", indexes)
+		}
+	})
+
+	t.Run("stops when emit fails", func(t *testing.T) {
+		err := downloader.streamPages(&mockSource{}, &data.Manga{ID: "m"}, &data.Chapter{ID: "c"}, urls, func(image integrations.ImageData) error {
+			return fmt.Errorf("disk full")
+		})
+		if err == nil || !strings.Contains(err.Error(), "disk full") {
+			t.Errorf("streamPages() error = %v, want the emit error", err)
+		}
+	})
+
+	t.Run("does not run ahead of a slow page", func(t *testing.T) {
+		var mu sync.Mutex
+		var requested []string
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/page0.png" {
+				time.Sleep(100 * time.Millisecond)
+			}
+			mu.Lock()
+			requested = append(requested, r.URL.Path)
+			mu.Unlock()
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(append([]byte(nil), pngData...))
+		}))
+		defer slow.Close()
+
+		small := NewDownloaderWithOptions(&mockSource{}, &mockRepository{}, t.TempDir(), DownloaderOptions{
+			MaxConcurrentPages: 2,
+		})
+		defer small.Close()
+		var pages []string
+		for i := 0; i < 20; i++ {
+			pages = append(pages, fmt.Sprintf("%s/page%d.png", slow.URL, i))
+		}
+		err := small.streamPages(&mockSource{}, &data.Manga{ID: "m"}, &data.Chapter{ID: "c"}, pages, func(image integrations.ImageData) error {
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("streamPages() error = %v", err)
+		}
+		// Only the pages within twice the concurrency of page 0 are fetched
+		// while it is slow
+		if first := slices.Index(requested, "/page0.png"); first != 3 {
+			t.Errorf("Expected 3 pages fetched ahead of page 0, got %v", requested)
+		}
+		if len(requested) != len(pages) {
+			t.Errorf("Expected every page fetched, got %d", len(requested))
+		}
+	})
+}
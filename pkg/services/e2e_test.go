@@ -61,7 +61,10 @@ func TestE2E_FullDownloadPipeline(t *testing.T) {
 	config := ControllerConfig{
 		DownloadDir: downloadDir,
 	}
-	controller := NewMangaControllerWithConfig(config)
+	controller, err := NewMangaControllerWithConfig(config)
+	if err != nil {
+		t.Fatalf("NewMangaControllerWithConfig() error = %v", err)
+	}
 	controller.source = source
 	controller.repo = repo
 	controller.downloader = NewDownloader(source, repo, downloadDir)
@@ -101,11 +104,15 @@ func TestE2E_FullDownloadPipeline(t *testing.T) {
 	})
 
 	// Monitor progress
+	var progressMu sync.Mutex
 	progressUpdates := []DownloadProgress{}
 	done := make(chan struct{})
+	updates := controller.GetProgressChannel()
 	go func() {
-		for progress := range controller.GetProgressChannel() {
+		for progress := range updates {
+			progressMu.Lock()
 			progressUpdates = append(progressUpdates, progress)
+			progressMu.Unlock()
 		}
 		close(done)
 	}()
@@ -123,6 +130,8 @@ func TestE2E_FullDownloadPipeline(t *testing.T) {
 
 		// Wait a bit for progress updates
 		time.Sleep(100 * time.Millisecond)
+		progressMu.Lock()
+		defer progressMu.Unlock()
 
 		// Verify progress updates were sent
 		if len(progressUpdates) == 0 {
@@ -234,7 +243,10 @@ func TestE2E_DownloadWithChapterRange(t *testing.T) {
 	}
 
 	config := ControllerConfig{DownloadDir: testDir}
-	controller := NewMangaControllerWithConfig(config)
+	controller, err := NewMangaControllerWithConfig(config)
+	if err != nil {
+		t.Fatalf("NewMangaControllerWithConfig() error = %v", err)
+	}
 	controller.source = source
 	controller.repo = data.NewDuckDBRepository()
 	controller.downloader = NewDownloader(source, controller.repo, testDir)
@@ -248,7 +260,7 @@ func TestE2E_DownloadWithChapterRange(t *testing.T) {
 		ChapterRange: "2-3",
 	}
 
-	err := controller.DownloadManga(manga, options)
+	err = controller.DownloadManga(manga, options)
 	if err != nil {
 		t.Fatalf("Download failed: %v", err)
 	}
@@ -292,7 +304,10 @@ func TestE2E_DownloadWithLanguageFilter(t *testing.T) {
 	}
 
 	config := ControllerConfig{DownloadDir: testDir}
-	controller := NewMangaControllerWithConfig(config)
+	controller, err := NewMangaControllerWithConfig(config)
+	if err != nil {
+		t.Fatalf("NewMangaControllerWithConfig() error = %v", err)
+	}
 	controller.source = source
 	controller.repo = data.NewDuckDBRepository()
 	controller.downloader = NewDownloader(source, controller.repo, testDir)
@@ -305,7 +320,7 @@ func TestE2E_DownloadWithLanguageFilter(t *testing.T) {
 		Language: "en",
 	}
 
-	err := controller.DownloadManga(manga, options)
+	err = controller.DownloadManga(manga, options)
 	if err != nil {
 		t.Fatalf("Download failed: %v", err)
 	}
@@ -348,7 +363,10 @@ func TestE2E_DownloadWithSpecificChapters(t *testing.T) {
 	}
 
 	config := ControllerConfig{DownloadDir: testDir}
-	controller := NewMangaControllerWithConfig(config)
+	controller, err := NewMangaControllerWithConfig(config)
+	if err != nil {
+		t.Fatalf("NewMangaControllerWithConfig() error = %v", err)
+	}
 	controller.source = source
 	controller.repo = data.NewDuckDBRepository()
 	controller.downloader = NewDownloader(source, controller.repo, testDir)
@@ -362,7 +380,7 @@ func TestE2E_DownloadWithSpecificChapters(t *testing.T) {
 		ChapterIDs: []string{"ch1", "ch3"},
 	}
 
-	err := controller.DownloadManga(manga, options)
+	err = controller.DownloadManga(manga, options)
 	if err != nil {
 		t.Fatalf("Download failed: %v", err)
 	}
@@ -409,7 +427,10 @@ func TestE2E_DownloadWithErrors(t *testing.T) {
 	}
 
 	config := ControllerConfig{DownloadDir: testDir}
-	controller := NewMangaControllerWithConfig(config)
+	controller, err := NewMangaControllerWithConfig(config)
+	if err != nil {
+		t.Fatalf("NewMangaControllerWithConfig() error = %v", err)
+	}
 	controller.source = source
 	controller.repo = data.NewDuckDBRepository()
 	controller.downloader = NewDownloader(source, controller.repo, testDir)
@@ -420,7 +441,7 @@ func TestE2E_DownloadWithErrors(t *testing.T) {
 	options := DownloadOptions{Language: "en"}
 
 	// Download should complete but with errors
-	err := controller.DownloadManga(manga, options)
+	err = controller.DownloadManga(manga, options)
 	if err != nil {
 		t.Logf("Download completed with errors: %v", err)
 	}
@@ -476,7 +497,10 @@ func TestE2E_ConcurrentDownloads(t *testing.T) {
 	}
 
 	config := ControllerConfig{DownloadDir: testDir}
-	controller := NewMangaControllerWithConfig(config)
+	controller, err := NewMangaControllerWithConfig(config)
+	if err != nil {
+		t.Fatalf("NewMangaControllerWithConfig() error = %v", err)
+	}
 	controller.source = source
 	controller.repo = data.NewDuckDBRepository()
 	controller.downloader = NewDownloader(source, controller.repo, testDir)
@@ -486,7 +510,7 @@ func TestE2E_ConcurrentDownloads(t *testing.T) {
 
 	startTime := time.Now()
 	
-	err := controller.DownloadManga(manga, DownloadOptions{Language: "en"})
+	err = controller.DownloadManga(manga, DownloadOptions{Language: "en"})
 	if err != nil {
 		t.Fatalf("Download failed: %v", err)
 	}
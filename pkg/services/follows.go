@@ -0,0 +1,52 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/sources"
+)
+
+// FollowsReport summarizes an import of followed manga
+type FollowsReport struct {
+	Follows []*data.Manga // Manga followed on the source
+	Added   int           // New library entries
+	Updated int           // Library entries whose reading status changed
+}
+
+// ImportFollows adds the manga followed on a source account to the library
+// and records their reading status. Manga already in the library keep their
+// data, only their reading status is updated. Chapter lists are left to
+// SyncManga, as fetching them for every follow would take long.
+func (c *MangaController) ImportFollows(provider sources.FollowsProvider) (*FollowsReport, error) {
+	follows, err := provider.Follows()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &FollowsReport{Follows: follows}
+	for _, follow := range follows {
+		existing, err := c.repo.GetManga(follow.ID)
+		if err != nil {
+			return report, fmt.Errorf("failed to read library: %w", err)
+		}
+
+		if existing == nil {
+			if err := c.repo.SaveManga(follow); err != nil {
+				return report, fmt.Errorf("failed to save %s: %w", follow.Name, err)
+			}
+			report.Added++
+			continue
+		}
+
+		if follow.ReadingStatus == "" || follow.ReadingStatus == existing.ReadingStatus {
+			continue
+		}
+		existing.ReadingStatus = follow.ReadingStatus
+		if err := c.repo.SaveManga(existing); err != nil {
+			return report, fmt.Errorf("failed to save %s: %w", existing.Name, err)
+		}
+		report.Updated++
+	}
+	return report, nil
+}
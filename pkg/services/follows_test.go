@@ -0,0 +1,61 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+type mockFollows struct {
+	follows []*data.Manga
+	err     error
+}
+
+func (m *mockFollows) Follows() ([]*data.Manga, error) {
+	return m.follows, m.err
+}
+
+func TestControllerImportFollows(t *testing.T) {
+	library := map[string]*data.Manga{
+		"known":     {ID: "known", Name: "Known", Status: "completed", ReadingStatus: "reading"},
+		"unchanged": {ID: "unchanged", Name: "Unchanged", ReadingStatus: "dropped"},
+	}
+	repo := &mockRepository{
+		getMangaFunc: func(id string) (*data.Manga, error) {
+			if manga, ok := library[id]; ok {
+				copy := *manga
+				return &copy, nil
+			}
+			return nil, nil
+		},
+		saveMangaFunc: func(manga *data.Manga) error {
+			library[manga.ID] = manga
+			return nil
+		},
+	}
+	controller := &MangaController{repo: repo}
+
+	report, err := controller.ImportFollows(&mockFollows{follows: []*data.Manga{
+		{ID: "new", Name: "New", Source: "mangadex", ReadingStatus: "plan_to_read"},
+		{ID: "known", Name: "Known (renamed)", ReadingStatus: "completed"},
+		{ID: "unchanged", Name: "Unchanged", ReadingStatus: "dropped"},
+	}})
+	if err != nil {
+		t.Fatalf("ImportFollows() error = %v", err)
+	}
+	if len(report.Follows) != 3 || report.Added != 1 || report.Updated != 1 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+
+	if m := library["new"]; m == nil || m.ReadingStatus != "plan_to_read" {
+		t.Errorf("Expected the new follow to be added, got %+v", m)
+	}
+	if m := library["known"]; m.Name != "Known" || m.Status != "completed" || m.ReadingStatus != "completed" {
+		t.Errorf("Expected only the reading status to change, got %+v", m)
+	}
+
+	if _, err := controller.ImportFollows(&mockFollows{err: fmt.Errorf("not logged in")}); err == nil {
+		t.Error("Expected the source error to be returned")
+	}
+}
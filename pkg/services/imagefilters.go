@@ -0,0 +1,88 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/kerbaras/mangas/pkg/integrations"
+)
+
+// imageFiltersKeyPrefix and preprocessKeyPrefix prefix the state keys
+// holding the image filters and the clean-ups of each export profile
+const (
+	imageFiltersKeyPrefix = "image_filters:"
+	preprocessKeyPrefix   = "preprocess:"
+)
+
+// LoadImageFilters returns the image filters configured for an export
+// profile, none when unset
+func LoadImageFilters(store StateStore, deviceID string) ([]integrations.ImageFilterSpec, error) {
+	value, err := store.GetState(imageFiltersKeyPrefix + deviceID)
+	if err != nil || value == "" {
+		return nil, err
+	}
+	var specs []string
+	if err := json.Unmarshal([]byte(value), &specs); err != nil {
+		return nil, fmt.Errorf("invalid image filters for %s: %w", deviceID, err)
+	}
+	filters := make([]integrations.ImageFilterSpec, len(specs))
+	for i, spec := range specs {
+		if filters[i], err = integrations.ParseImageFilterSpec(spec); err != nil {
+			return nil, err
+		}
+	}
+	return filters, nil
+}
+
+// SaveImageFilters keeps the image filters run, in order, on the pages
+// exported for a profile. The filters are checked first; none clears them.
+func SaveImageFilters(store StateStore, deviceID string, filters []integrations.ImageFilterSpec) error {
+	if _, ok := integrations.GetExportDevice(deviceID); !ok {
+		return fmt.Errorf("unknown device: %s", deviceID)
+	}
+	if len(filters) == 0 {
+		return store.SetState(imageFiltersKeyPrefix+deviceID, "")
+	}
+	if _, err := integrations.NewImageFilters(filters); err != nil {
+		return err
+	}
+	specs := make([]string, len(filters))
+	for i, filter := range filters {
+		specs[i] = filter.String()
+	}
+	value, err := json.Marshal(specs)
+	if err != nil {
+		return err
+	}
+	return store.SetState(imageFiltersKeyPrefix+deviceID, string(value))
+}
+
+// LoadPreprocess returns the clean-ups run on the pages exported for a
+// profile before they are resized, none when unset
+func LoadPreprocess(store StateStore, deviceID string) (integrations.PreprocessOptions, error) {
+	var options integrations.PreprocessOptions
+	value, err := store.GetState(preprocessKeyPrefix + deviceID)
+	if err != nil || value == "" {
+		return options, err
+	}
+	if err := json.Unmarshal([]byte(value), &options); err != nil {
+		return options, fmt.Errorf("invalid clean-ups for %s: %w", deviceID, err)
+	}
+	return options, nil
+}
+
+// SavePreprocess keeps the clean-ups run on the pages exported for a
+// profile before they are resized
+func SavePreprocess(store StateStore, deviceID string, options integrations.PreprocessOptions) error {
+	if _, ok := integrations.GetExportDevice(deviceID); !ok {
+		return fmt.Errorf("unknown device: %s", deviceID)
+	}
+	if !options.Enabled() {
+		return store.SetState(preprocessKeyPrefix+deviceID, "")
+	}
+	value, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+	return store.SetState(preprocessKeyPrefix+deviceID, string(value))
+}
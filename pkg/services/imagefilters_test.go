@@ -0,0 +1,67 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/integrations"
+)
+
+func TestImageFilters_SaveAndLoad(t *testing.T) {
+	store := &mockQuotaStore{state: make(map[string]string)}
+
+	if filters, err := LoadImageFilters(store, "kindle-oasis3"); err != nil || len(filters) != 0 {
+		t.Fatalf("LoadImageFilters() = %v, %v, want none", filters, err)
+	}
+	filters := []integrations.ImageFilterSpec{{Name: "levels", Args: "16,240"}, {Name: "levels", Args: "0,255,1.2"}}
+	if err := SaveImageFilters(store, "kindle-oasis3", filters); err != nil {
+		t.Fatalf("SaveImageFilters() error = %v", err)
+	}
+	loaded, err := LoadImageFilters(store, "kindle-oasis3")
+	if err != nil || len(loaded) != 2 || loaded[0] != filters[0] || loaded[1] != filters[1] {
+		t.Errorf("LoadImageFilters() = %v, %v, want %v", loaded, err, filters)
+	}
+	if other, _ := LoadImageFilters(store, "kobo-libra"); len(other) != 0 {
+		t.Errorf("Expected filters per profile, got %v for kobo-libra", other)
+	}
+
+	if err := SaveImageFilters(store, "kindle-oasis3", []integrations.ImageFilterSpec{{Name: "no-such-filter"}}); err == nil {
+		t.Error("Expected an error for an unknown filter")
+	}
+	if err := SaveImageFilters(store, "no-such-device", filters); err == nil {
+		t.Error("Expected an error for an unknown device")
+	}
+	if err := SaveImageFilters(store, "kindle-oasis3", nil); err != nil {
+		t.Fatalf("SaveImageFilters(nil) error = %v", err)
+	}
+	if loaded, _ := LoadImageFilters(store, "kindle-oasis3"); len(loaded) != 0 {
+		t.Errorf("Expected the filters cleared, got %v", loaded)
+	}
+}
+
+func TestPreprocess_SaveAndLoad(t *testing.T) {
+	store := &mockQuotaStore{state: make(map[string]string)}
+
+	if options, err := LoadPreprocess(store, "kindle-paperwhite3"); err != nil || options.Enabled() {
+		t.Fatalf("LoadPreprocess() = %+v, %v, want no clean-ups", options, err)
+	}
+	options := integrations.PreprocessOptions{TrimMargins: true, AutoLevel: true}
+	if err := SavePreprocess(store, "kindle-paperwhite3", options); err != nil {
+		t.Fatalf("SavePreprocess() error = %v", err)
+	}
+	if loaded, err := LoadPreprocess(store, "kindle-paperwhite3"); err != nil || loaded != options {
+		t.Errorf("LoadPreprocess() = %+v, %v, want %+v", loaded, err, options)
+	}
+	if other, _ := LoadPreprocess(store, "kobo-libra"); other.Enabled() {
+		t.Errorf("Expected clean-ups per profile, got %+v for kobo-libra", other)
+	}
+
+	if err := SavePreprocess(store, "no-such-device", options); err == nil {
+		t.Error("Expected an error for an unknown device")
+	}
+	if err := SavePreprocess(store, "kindle-paperwhite3", integrations.PreprocessOptions{}); err != nil {
+		t.Fatalf("SavePreprocess() error = %v", err)
+	}
+	if store.state["preprocess:kindle-paperwhite3"] != "" {
+		t.Errorf("Expected the clean-ups cleared, got %q", store.state["preprocess:kindle-paperwhite3"])
+	}
+}
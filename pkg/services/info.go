@@ -0,0 +1,62 @@
+package services
+
+import (
+	"os"
+	"sort"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+// ChapterSummary sums up the chapters of a manga for 'mangas info'
+type ChapterSummary struct {
+	Chapters   int
+	Downloaded int
+	Read       int
+	Languages  []string // Languages of the chapters, the most common first
+	DiskUsage  int64    // Bytes of the downloaded books found on disk
+	Files      []string // Downloaded books in chapter order, a volume shared by its chapters listed once
+	Missing    []string // Downloaded books no longer on disk
+}
+
+// SummarizeChapters counts the chapters of a manga by language and
+// download, and measures their books on disk
+func SummarizeChapters(chapters []*data.Chapter) *ChapterSummary {
+	summary := &ChapterSummary{Chapters: len(chapters)}
+	languages := make(map[string]int)
+	seen := make(map[string]bool)
+	for _, ch := range chapters {
+		if ch.Language != "" {
+			if languages[ch.Language] == 0 {
+				summary.Languages = append(summary.Languages, ch.Language)
+			}
+			languages[ch.Language]++
+		}
+		if ch.Read {
+			summary.Read++
+		}
+		if !ch.Downloaded {
+			continue
+		}
+		summary.Downloaded++
+		if ch.FilePath == "" || seen[ch.FilePath] {
+			continue
+		}
+		seen[ch.FilePath] = true
+		info, err := os.Stat(ch.FilePath)
+		if err != nil {
+			summary.Missing = append(summary.Missing, ch.FilePath)
+			continue
+		}
+		summary.Files = append(summary.Files, ch.FilePath)
+		if info.IsDir() {
+			size, _ := DirSize(ch.FilePath)
+			summary.DiskUsage += size
+		} else {
+			summary.DiskUsage += info.Size()
+		}
+	}
+	sort.SliceStable(summary.Languages, func(i, j int) bool {
+		return languages[summary.Languages[i]] > languages[summary.Languages[j]]
+	})
+	return summary
+}
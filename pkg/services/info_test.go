@@ -0,0 +1,47 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+func TestSummarizeChapters(t *testing.T) {
+	dir := t.TempDir()
+	volume := filepath.Join(dir, "Vol. 1.epub")
+	single := filepath.Join(dir, "Ch. 3.epub")
+	gone := filepath.Join(dir, "Ch. 4.epub")
+	if err := os.WriteFile(volume, make([]byte, 300), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(single, make([]byte, 50), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	summary := SummarizeChapters([]*data.Chapter{
+		{Number: "1", Language: "es", Downloaded: true, FilePath: volume, Read: true},
+		{Number: "1", Language: "en"},
+		{Number: "2", Language: "en", Downloaded: true, FilePath: volume},
+		{Number: "3", Language: "en", Downloaded: true, FilePath: single},
+		{Number: "4", Language: "en", Downloaded: true, FilePath: gone},
+	})
+
+	if summary.Chapters != 5 || summary.Downloaded != 4 || summary.Read != 1 {
+		t.Errorf("Counts = %d/%d/%d, want 5 chapters, 4 downloaded, 1 read", summary.Chapters, summary.Downloaded, summary.Read)
+	}
+	if want := []string{"en", "es"}; !reflect.DeepEqual(summary.Languages, want) {
+		t.Errorf("Languages = %v, want %v", summary.Languages, want)
+	}
+	if summary.DiskUsage != 350 {
+		t.Errorf("DiskUsage = %d, want 350 with the shared volume counted once", summary.DiskUsage)
+	}
+	if want := []string{volume, single}; !reflect.DeepEqual(summary.Files, want) {
+		t.Errorf("Files = %v, want %v", summary.Files, want)
+	}
+	if want := []string{gone}; !reflect.DeepEqual(summary.Missing, want) {
+		t.Errorf("Missing = %v, want %v", summary.Missing, want)
+	}
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/log"
+	"github.com/kerbaras/mangas/pkg/sources"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// LinkStore keeps the library entries rolled up under a manga as the same
+// series on another source
+type LinkStore interface {
+	LinkManga(mangaID string, other *data.Manga) error
+	GetMangaLinks(mangaID string) ([]*data.MangaLink, error)
+	FindMangaLink(source, sourceID string) (*data.MangaLink, error)
+}
+
+// FindDuplicates returns the manga of library that look like the same series
+// as manga: their names match, ignoring case and punctuation
+func FindDuplicates(library []*data.Manga, manga *data.Manga) []*data.Manga {
+	name := strings.Join(nameWords(manga.Name), " ")
+	if name == "" {
+		return nil
+	}
+	var duplicates []*data.Manga
+	for _, candidate := range library {
+		if candidate.ID == manga.ID {
+			continue
+		}
+		if strings.Join(nameWords(candidate.Name), " ") == name {
+			duplicates = append(duplicates, candidate)
+		}
+	}
+	return duplicates
+}
+
+// FindDuplicates returns the library manga that look like the same series as
+// manga, so adding it from another source can be linked instead
+func (c *MangaController) FindDuplicates(manga *data.Manga) ([]*data.Manga, error) {
+	if manga == nil {
+		return nil, fmt.Errorf("manga cannot be nil")
+	}
+	library, err := c.repo.ListMangas()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mangas: %w", err)
+	}
+	return FindDuplicates(library, manga), nil
+}
+
+// LinkManga rolls the library entry other up under manga: its chapters move
+// to manga, and syncing manga fetches the chapters of other's source too
+func (c *MangaController) LinkManga(manga, other *data.Manga) error {
+	if manga == nil || other == nil {
+		return fmt.Errorf("manga cannot be nil")
+	}
+	if c.links == nil {
+		return fmt.Errorf("this library can't link manga")
+	}
+	if manga.ID == other.ID {
+		return fmt.Errorf("cannot link %s to itself", manga.Name)
+	}
+	linked := *other
+	if linked.Source == "" {
+		linked.Source = sources.DefaultSource
+	}
+	return c.links.LinkManga(manga.ID, &linked)
+}
+
+// GetMangaLinks returns the entries linked to a manga
+func (c *MangaController) GetMangaLinks(mangaID string) ([]*data.MangaLink, error) {
+	if c.links == nil {
+		return nil, nil
+	}
+	return c.links.GetMangaLinks(mangaID)
+}
+
+// FindMangaLink returns the link of a manga of a source rolled up under a
+// library manga, nil when it is not linked
+func (c *MangaController) FindMangaLink(manga *data.Manga) (*data.MangaLink, error) {
+	if c.links == nil || manga == nil {
+		return nil, nil
+	}
+	source := manga.Source
+	if source == "" {
+		source = sources.DefaultSource
+	}
+	return c.links.FindMangaLink(source, manga.ID)
+}
+
+// WithLinkedChapters adds to chapters, those of manga's own source, the
+// chapters of the entries linked to manga in links that fill their gaps, see
+// preferOwnChapters. The linked ones are fetched in languages when their
+// sources can filter them. links may be nil.
+func WithLinkedChapters(links LinkStore, manga *data.Manga, chapters []*data.Chapter, languages ...string) ([]*data.Chapter, error) {
+	linked, err := linkedChapters(links, manga, languages...)
+	if err != nil {
+		return nil, err
+	}
+	return preferOwnChapters(append(chapters, linked...)), nil
+}
+
+// linkedChapters fetches the chapters of the entries linked to manga from
+// their sources, in languages when the sources can filter them. They belong
+// to manga and remember the source they come from. Linked sources failing
+// are logged and skipped.
+func linkedChapters(store LinkStore, manga *data.Manga, languages ...string) ([]*data.Chapter, error) {
+	if store == nil {
+		return nil, nil
+	}
+	links, err := store.GetMangaLinks(manga.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get linked manga: %w", err)
+	}
+
+	var chapters []*data.Chapter
+	for _, link := range links {
+		// A failing linked source only loses the gaps it fills
+		logger := log.With("manga_id", manga.ID, "linked", link.Name, "source", link.Source)
+		source, err := sources.Get(link.Source)
+		if err != nil {
+			logger.Warn("linked source unavailable, skipped", "err", err)
+			continue
+		}
+		linked := &data.Manga{ID: link.SourceID, Name: link.Name, Source: link.Source}
+		remote, err := sources.GetChaptersIn(source, linked, languages...)
+		if err != nil {
+			logger.Warn("failed to get chapters of linked manga, skipped", "err", err)
+			continue
+		}
+		for _, chapter := range remote {
+			chapter.MangaID = manga.ID
+			chapter.Source = link.Source
+			chapters = append(chapters, chapter)
+		}
+	}
+	return chapters, nil
+}
+
+// preferOwnChapters drops the chapters of linked sources that the manga's own
+// source has too, in the same language, so linked sources only fill the gaps.
+// Among linked sources, the first one having a chapter wins.
+func preferOwnChapters(chapters []*data.Chapter) []*data.Chapter {
+	key := func(ch *data.Chapter) string {
+		return ch.Language + "/" + utils.NormalizeChapterNumber(ch.Number)
+	}
+	own := make(map[string]bool)
+	for _, ch := range chapters {
+		if ch.Source == "" {
+			own[key(ch)] = true
+		}
+	}
+
+	var kept []*data.Chapter
+	taken := make(map[string]bool)
+	for _, ch := range chapters {
+		if ch.Source != "" {
+			k := key(ch)
+			if own[k] || taken[k] {
+				continue
+			}
+			taken[k] = true
+		}
+		kept = append(kept, ch)
+	}
+	return kept
+}
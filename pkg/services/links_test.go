@@ -0,0 +1,257 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/sources"
+)
+
+// memoryLinkStore is a LinkStore keeping the links in memory
+type memoryLinkStore struct {
+	links []*data.MangaLink
+}
+
+func (m *memoryLinkStore) LinkManga(mangaID string, other *data.Manga) error {
+	m.links = append(m.links, &data.MangaLink{MangaID: mangaID, Source: other.Source, SourceID: other.ID, Name: other.Name})
+	return nil
+}
+
+func (m *memoryLinkStore) GetMangaLinks(mangaID string) ([]*data.MangaLink, error) {
+	var links []*data.MangaLink
+	for _, link := range m.links {
+		if link.MangaID == mangaID {
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+func (m *memoryLinkStore) FindMangaLink(source, sourceID string) (*data.MangaLink, error) {
+	for _, link := range m.links {
+		if link.Source == source && link.SourceID == sourceID {
+			return link, nil
+		}
+	}
+	return nil, nil
+}
+
+func TestFindDuplicates(t *testing.T) {
+	library := []*data.Manga{
+		{ID: "1", Name: "Kaguya-sama: Love Is War"},
+		{ID: "2", Name: "kaguya sama love is war"},
+		{ID: "3", Name: "Kaguya-sama"},
+	}
+	duplicates := FindDuplicates(library, &data.Manga{ID: "new", Name: "Kaguya-sama - Love is War!"})
+	if len(duplicates) != 2 || duplicates[0].ID != "1" || duplicates[1].ID != "2" {
+		t.Errorf("FindDuplicates() = %+v, want 1 and 2", duplicates)
+	}
+	if duplicates := FindDuplicates(library, library[0]); len(duplicates) != 1 || duplicates[0].ID != "2" {
+		t.Errorf("Expected a manga not to duplicate itself, got %+v", duplicates)
+	}
+}
+
+func TestPreferOwnChapters(t *testing.T) {
+	chapters := []*data.Chapter{
+		{ID: "own-1", Number: "1", Language: "en"},
+		{ID: "linked-1", Number: "1", Language: "en", Source: "other"},
+		{ID: "linked-1-es", Number: "1", Language: "es", Source: "other"},
+		{ID: "linked-2", Number: "2", Language: "en", Source: "other"},
+		{ID: "third-2", Number: "02", Language: "en", Source: "third"},
+	}
+	kept := preferOwnChapters(chapters)
+	var ids []string
+	for _, ch := range kept {
+		ids = append(ids, ch.ID)
+	}
+	want := []string{"own-1", "linked-1-es", "linked-2"}
+	if len(ids) != len(want) {
+		t.Fatalf("preferOwnChapters() = %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("preferOwnChapters() = %v, want %v", ids, want)
+		}
+	}
+}
+
+func TestSyncMangaFetchesLinkedChapters(t *testing.T) {
+	sources.Register("test-linked", func() sources.Source {
+		return &mockSource{
+			getChaptersFunc: func(manga *data.Manga) ([]*data.Chapter, error) {
+				if manga.ID != "other-id" {
+					t.Errorf("Linked source asked for manga %q, want other-id", manga.ID)
+				}
+				return []*data.Chapter{{ID: "linked-2", Number: "2", Language: "en"}}, nil
+			},
+		}
+	})
+
+	var saved []*data.Chapter
+	links := &memoryLinkStore{}
+	controller := &MangaController{
+		source: &mockSource{
+			getChaptersFunc: func(manga *data.Manga) ([]*data.Chapter, error) {
+				return []*data.Chapter{{ID: "own-1", Number: "1", Language: "en"}}, nil
+			},
+		},
+		repo: &mockRepository{
+			saveChapterFunc: func(chapter *data.Chapter) error {
+				saved = append(saved, chapter)
+				return nil
+			},
+		},
+		links: links,
+	}
+
+	manga := &data.Manga{ID: "manga-id", Name: "Linked"}
+	if err := controller.LinkManga(manga, manga); err == nil {
+		t.Error("Expected linking a manga to itself to fail")
+	}
+	if err := controller.LinkManga(manga, &data.Manga{ID: "other-id", Name: "Linked", Source: "test-linked"}); err != nil {
+		t.Fatalf("LinkManga() error = %v", err)
+	}
+
+	result, err := controller.SyncManga(manga)
+	if err != nil {
+		t.Fatalf("SyncManga() error = %v", err)
+	}
+	if len(result.NewChapters) != 2 || len(saved) != 2 {
+		t.Fatalf("Expected the chapters of both sources, got %d new, %d saved", len(result.NewChapters), len(saved))
+	}
+	linked := saved[1]
+	if linked.ID != "linked-2" || linked.MangaID != "manga-id" || linked.Source != "test-linked" {
+		t.Errorf("Linked chapter = %+v, want it under manga-id from test-linked", linked)
+	}
+
+	downloader := NewDownloader(controller.source, controller.repo, t.TempDir())
+	if source, _ := sources.Get("test-linked"); downloader.sourceForChapter(manga, linked) != source {
+		t.Error("Expected the linked chapter to download from its own source")
+	}
+	if downloader.sourceForChapter(manga, saved[0]) != controller.source {
+		t.Error("Expected the manga's chapter to download from the manga's source")
+	}
+}
+
+func TestSyncMangaSkipsFailingAndDuplicateLinkedChapters(t *testing.T) {
+	sources.Register("test-linked-broken", func() sources.Source {
+		return &mockSource{
+			getChaptersFunc: func(manga *data.Manga) ([]*data.Chapter, error) {
+				return nil, fmt.Errorf("site is down")
+			},
+		}
+	})
+	sources.Register("test-linked-dup", func() sources.Source {
+		return &mockSource{
+			getChaptersFunc: func(manga *data.Manga) ([]*data.Chapter, error) {
+				return []*data.Chapter{
+					{ID: "dup-1", Number: "1", Language: "en"},
+					{ID: "dup-3", Number: "3", Language: "en"},
+				}, nil
+			},
+		}
+	})
+
+	var saved []string
+	links := &memoryLinkStore{}
+	controller := &MangaController{
+		source: &mockSource{
+			getChaptersFunc: func(manga *data.Manga) ([]*data.Chapter, error) {
+				return []*data.Chapter{{ID: "own-1", Number: "1", Language: "en"}}, nil
+			},
+		},
+		repo: &mockRepository{
+			saveChapterFunc: func(chapter *data.Chapter) error {
+				saved = append(saved, chapter.ID)
+				return nil
+			},
+		},
+		links: links,
+	}
+	manga := &data.Manga{ID: "manga-id", Name: "Linked"}
+	controller.LinkManga(manga, &data.Manga{ID: "broken-id", Name: "Linked", Source: "test-linked-broken"})
+	controller.LinkManga(manga, &data.Manga{ID: "dup-id", Name: "Linked", Source: "test-linked-dup"})
+
+	result, err := controller.SyncManga(manga)
+	if err != nil {
+		t.Fatalf("SyncManga() error = %v, want the broken linked source skipped", err)
+	}
+	if want := []string{"own-1", "dup-3"}; !slices.Equal(saved, want) || len(result.NewChapters) != len(want) {
+		t.Errorf("Saved %v, want %v without the linked copy of chapter 1", saved, want)
+	}
+}
+
+func TestWithLinkedChaptersDownloadsLinkedOnlyChapter(t *testing.T) {
+	pngData := createTestPNG()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngData)
+	}))
+	defer server.Close()
+
+	var linkedPages []string
+	sources.Register("test-linked-download", func() sources.Source {
+		return &mockSource{
+			getChaptersFunc: func(manga *data.Manga) ([]*data.Chapter, error) {
+				return []*data.Chapter{
+					{ID: "linked-1", Number: "1", Language: "en"},
+					{ID: "linked-2", Number: "2", Language: "en"},
+				}, nil
+			},
+			getPagesFunc: func(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+				linkedPages = append(linkedPages, chapter.ID)
+				return []string{server.URL + "/1.png"}, nil
+			},
+		}
+	})
+	source := &mockSource{
+		getChaptersFunc: func(manga *data.Manga) ([]*data.Chapter, error) {
+			return []*data.Chapter{{ID: "own-1", Number: "1", Language: "en"}}, nil
+		},
+		getPagesFunc: func(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+			return []string{server.URL + "/1.png"}, nil
+		},
+	}
+
+	links := &memoryLinkStore{}
+	manga := &data.Manga{ID: "manga-id", Name: "Linked"}
+	links.LinkManga(manga.ID, &data.Manga{ID: "other-id", Name: "Linked", Source: "test-linked-download"})
+
+	// The way 'mangas download' gets its chapters
+	own, err := source.GetChapters(manga)
+	if err != nil {
+		t.Fatalf("GetChapters() error = %v", err)
+	}
+	chapters, err := WithLinkedChapters(links, manga, own, "en")
+	if err != nil {
+		t.Fatalf("WithLinkedChapters() error = %v", err)
+	}
+	var ids []string
+	for _, chapter := range chapters {
+		ids = append(ids, chapter.ID)
+	}
+	if want := []string{"own-1", "linked-2"}; !slices.Equal(ids, want) {
+		t.Fatalf("WithLinkedChapters() = %v, want %v", ids, want)
+	}
+
+	downloader := NewDownloaderWithOptions(source, &mockRepository{}, t.TempDir(), DownloaderOptions{MaxConcurrentChapters: 1, MaxConcurrentPages: 1, RequestsPerSecond: 1000})
+	defer downloader.Close()
+	if err := downloader.DownloadMangaBundled(manga, chapters, BundleChapter, nil); err != nil {
+		t.Fatalf("DownloadMangaBundled() error = %v", err)
+	}
+	linked := chapters[1]
+	if !linked.Downloaded {
+		t.Fatal("Expected the chapter only the linked source has to be downloaded")
+	}
+	if _, err := os.Stat(linked.FilePath); err != nil {
+		t.Errorf("Linked chapter file missing: %v", err)
+	}
+	if !slices.Equal(linkedPages, []string{"linked-2"}) {
+		t.Errorf("Linked source listed the pages of %v, want only linked-2", linkedPages)
+	}
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// TestMain points the shared database and the cover cache to temporary
+// ones, so tests never touch the user's library
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "mangas-services-test-*")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create test directory: %v\n", err)
+		os.Exit(1)
+	}
+	data.SetDBPath(filepath.Join(dir, "mangas.db"))
+	SetCoverCacheDir(filepath.Join(dir, "covers"))
+	SetThumbnailDir(filepath.Join(dir, "thumbnails"))
+
+	code := m.Run()
+
+	data.SetDBPath("")
+	SetCoverCacheDir("")
+	SetThumbnailDir("")
+	os.RemoveAll(dir)
+	utils.Temp.Cleanup()
+	os.Exit(code)
+}
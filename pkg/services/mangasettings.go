@@ -0,0 +1,118 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// SettingsStore keeps the download settings of each manga
+type SettingsStore interface {
+	GetMangaSettings(mangaID string) (*data.MangaSettings, error)
+	SaveMangaSettings(settings *data.MangaSettings) error
+}
+
+// ValidateMangaSettings checks the format and bundle mode of settings, and
+// that they can be combined
+func ValidateMangaSettings(settings *data.MangaSettings) error {
+	if settings.Format != "" && settings.Format != BatchEPUB && settings.Format != BatchCBZ {
+		return fmt.Errorf("unknown format %q, expected epub or cbz", settings.Format)
+	}
+	mode, err := ParseBundleMode(settings.BundleMode)
+	if err != nil {
+		return err
+	}
+	if settings.Format == BatchCBZ && mode != BundleChapter {
+		return fmt.Errorf("cbz is written per chapter, it can't be bundled by %s", mode)
+	}
+	return nil
+}
+
+// GetMangaSettings returns the download settings of a manga, empty ones when
+// it has none
+func (c *MangaController) GetMangaSettings(mangaID string) (*data.MangaSettings, error) {
+	if c.settings == nil {
+		return &data.MangaSettings{MangaID: mangaID}, nil
+	}
+	return c.settings.GetMangaSettings(mangaID)
+}
+
+// SaveMangaSettings validates and saves the download settings of a manga
+func (c *MangaController) SaveMangaSettings(settings *data.MangaSettings) error {
+	if settings == nil || settings.MangaID == "" {
+		return fmt.Errorf("manga ID cannot be empty")
+	}
+	if c.settings == nil {
+		return fmt.Errorf("this library can't keep manga settings")
+	}
+	settings.Language = strings.TrimSpace(settings.Language)
+	settings.Group = strings.TrimSpace(settings.Group)
+	if err := ValidateMangaSettings(settings); err != nil {
+		return err
+	}
+	return c.settings.SaveMangaSettings(settings)
+}
+
+// withMangaSettings fills the options left empty with the settings of manga
+func (c *MangaController) withMangaSettings(manga *data.Manga, options DownloadOptions) (DownloadOptions, error) {
+	settings, err := c.GetMangaSettings(manga.ID)
+	if err != nil {
+		return options, fmt.Errorf("failed to get the settings of %s: %w", manga.Name, err)
+	}
+	if options.Language == "" {
+		options.Language = settings.Language
+	}
+	if options.Group == "" {
+		options.Group = settings.Group
+	}
+	if options.Format == "" {
+		options.Format = settings.Format
+	}
+	if options.BundleMode == "" {
+		options.BundleMode = BundleMode(settings.BundleMode)
+	}
+	return options, nil
+}
+
+// PreferGroup keeps, for each chapter number and language translated by
+// group, only group's version. Chapters group didn't translate are kept as
+// they are.
+func PreferGroup(chapters []*data.Chapter, group string) []*data.Chapter {
+	if group == "" {
+		return chapters
+	}
+	key := func(ch *data.Chapter) string {
+		return ch.Language + "/" + utils.NormalizeChapterNumber(ch.Number)
+	}
+	preferred := make(map[string]bool)
+	for _, ch := range chapters {
+		if inGroup(ch, group) {
+			preferred[key(ch)] = true
+		}
+	}
+
+	var kept []*data.Chapter
+	for _, ch := range chapters {
+		if !preferred[key(ch)] || inGroup(ch, group) {
+			kept = append(kept, ch)
+		}
+	}
+	return kept
+}
+
+// inGroup reports whether group, by name or ID, translated a chapter
+func inGroup(chapter *data.Chapter, group string) bool {
+	for _, name := range chapter.Groups {
+		if strings.EqualFold(name, group) {
+			return true
+		}
+	}
+	for _, id := range chapter.GroupIDs {
+		if id == group {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+)
+
+// memorySettings is a SettingsStore kept in memory
+type memorySettings map[string]*data.MangaSettings
+
+func (m memorySettings) GetMangaSettings(mangaID string) (*data.MangaSettings, error) {
+	if settings, ok := m[mangaID]; ok {
+		copied := *settings
+		return &copied, nil
+	}
+	return &data.MangaSettings{MangaID: mangaID}, nil
+}
+
+func (m memorySettings) SaveMangaSettings(settings *data.MangaSettings) error {
+	copied := *settings
+	m[settings.MangaID] = &copied
+	return nil
+}
+
+func TestValidateMangaSettings(t *testing.T) {
+	valid := []data.MangaSettings{
+		{},
+		{Language: "es", Format: BatchCBZ, BundleMode: "chapter"},
+		{Format: BatchEPUB, BundleMode: "volume"},
+	}
+	for _, settings := range valid {
+		if err := ValidateMangaSettings(&settings); err != nil {
+			t.Errorf("ValidateMangaSettings(%+v) error = %v", settings, err)
+		}
+	}
+
+	invalid := []data.MangaSettings{
+		{Format: "pdf"},
+		{BundleMode: "series"},
+		{Format: BatchCBZ, BundleMode: "volume"},
+	}
+	for _, settings := range invalid {
+		if err := ValidateMangaSettings(&settings); err == nil {
+			t.Errorf("ValidateMangaSettings(%+v) should fail", settings)
+		}
+	}
+}
+
+func TestPreferGroup(t *testing.T) {
+	chapters := []*data.Chapter{
+		{ID: "1a", Number: "1", Language: "en", Groups: []string{"Alpha"}},
+		{ID: "1b", Number: "1", Language: "en", Groups: []string{"Beta Scans"}},
+		{ID: "1c", Number: "1", Language: "es", Groups: []string{"Gamma"}},
+		{ID: "2a", Number: "2", Language: "en", Groups: []string{"Alpha"}},
+		{ID: "3b", Number: "3", Language: "en", GroupIDs: []string{"beta-id"}},
+		{ID: "3a", Number: "3", Language: "en", Groups: []string{"Alpha"}},
+	}
+
+	ids := func(chapters []*data.Chapter) []string {
+		var ids []string
+		for _, ch := range chapters {
+			ids = append(ids, ch.ID)
+		}
+		return ids
+	}
+	if got := ids(PreferGroup(chapters, "beta scans")); len(got) != 5 || got[0] != "1b" {
+		t.Errorf("PreferGroup(name) = %v, want the other version of chapter 1 dropped", got)
+	}
+	if got := ids(PreferGroup(chapters, "beta-id")); len(got) != 5 || got[4] != "3b" {
+		t.Errorf("PreferGroup(ID) = %v, want the other version of chapter 3 dropped", got)
+	}
+	if got := PreferGroup(chapters, ""); len(got) != len(chapters) {
+		t.Errorf("PreferGroup(\"\") kept %d chapters, want all %d", len(got), len(chapters))
+	}
+}
+
+func TestDownloadManga_MangaSettings(t *testing.T) {
+	pngData := createTestPNG()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngData)
+	}))
+	defer server.Close()
+
+	source := &mockSource{
+		getChaptersFunc: func(manga *data.Manga) ([]*data.Chapter, error) {
+			return []*data.Chapter{
+				{ID: "en-1", MangaID: "m1", Number: "1", Language: "en"},
+				{ID: "es-1a", MangaID: "m1", Number: "1", Language: "es", Groups: []string{"Alpha"}},
+				{ID: "es-1b", MangaID: "m1", Number: "1", Language: "es", Groups: []string{"Beta"}},
+				{ID: "es-2a", MangaID: "m1", Number: "2", Language: "es", Groups: []string{"Alpha"}},
+			}, nil
+		},
+		getPagesFunc: func(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+			return []string{server.URL + "/page.png"}, nil
+		},
+	}
+	settings := memorySettings{}
+	controller := &MangaController{source: source, repo: &mockRepository{}, settings: settings, downloadDir: t.TempDir()}
+	controller.downloader = NewDownloader(source, controller.repo, controller.downloadDir)
+	defer controller.Close()
+
+	manga := &data.Manga{ID: "m1", Name: "Settings Manga"}
+	if err := controller.SaveMangaSettings(&data.MangaSettings{MangaID: "m1", Format: BatchCBZ, BundleMode: "volume"}); err == nil {
+		t.Error("Expected saving cbz bundled by volume to fail")
+	}
+	if err := controller.SaveMangaSettings(&data.MangaSettings{MangaID: "m1", Language: " es ", Group: "Beta", Format: BatchCBZ}); err != nil {
+		t.Fatalf("SaveMangaSettings() error = %v", err)
+	}
+
+	chapters, err := controller.downloadManga(manga, DownloadOptions{})
+	if err != nil {
+		t.Fatalf("downloadManga() error = %v", err)
+	}
+	if len(chapters) != 2 || chapters[0].ID != "es-1b" || chapters[1].ID != "es-2a" {
+		t.Fatalf("Expected the Spanish chapters, by Beta when it translated them, got %+v", chapters)
+	}
+	// The CBZ is the chapter's book, no EPUB is left next to it
+	for _, ch := range chapters {
+		if filepath.Ext(ch.FilePath) != ".cbz" {
+			t.Errorf("Chapter %s written to %s, want a CBZ", ch.ID, ch.FilePath)
+		}
+		if _, err := integrations.ReadBookPages(ch.FilePath); err != nil {
+			t.Errorf("CBZ of chapter %s not written: %v", ch.ID, err)
+		}
+		if epubs, _ := filepath.Glob(filepath.Join(filepath.Dir(ch.FilePath), "*.epub")); len(epubs) != 0 {
+			t.Errorf("EPUBs left next to the CBZs: %v", epubs)
+		}
+	}
+
+	// Options set by the caller win over the settings
+	chapters, err = controller.downloadManga(manga, DownloadOptions{Language: "en", Force: true})
+	if err != nil || len(chapters) != 1 || chapters[0].ID != "en-1" {
+		t.Errorf("downloadManga(en) = %+v, %v, want the English chapter", chapters, err)
+	}
+	if _, err := controller.downloadManga(manga, DownloadOptions{BundleMode: BundleVolume}); err == nil {
+		t.Error("Expected bundling the cbz format by volume to fail")
+	}
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+)
+
+// legacyMigratedKey is the state key recording when MigrateLegacy last ran
+const legacyMigratedKey = "legacy_migrated_at"
+
+// MigrateStore is the library upgraded by MigrateLegacy
+type MigrateStore interface {
+	Repository
+	StateStore
+	OrganizeStore
+	ChecksumStore
+	UpgradeLegacyRows() (int, error)
+}
+
+// MigrateReport summarizes MigrateLegacy
+type MigrateReport struct {
+	RowsUpgraded int           // Library rows written by old releases
+	LegacyFiles  int           // Flat "{manga}_ch_{number}.epub" files found
+	Relinked     int           // Legacy files linked back to their chapter
+	Unmatched    []string      // Legacy files of no chapter in the library
+	Organize     *OrganizePlan // Books moved to follow the path templates
+	Recorded     int           // Books whose size and checksums were recorded
+	Damaged      []VerifyResult
+}
+
+// LegacyMigratedAt returns when MigrateLegacy last ran, the zero time when
+// it never did
+func LegacyMigratedAt(store StateStore) (time.Time, error) {
+	value, err := store.GetState(legacyMigratedKey)
+	if err != nil || value == "" {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// MigrateLegacy upgrades a library and download directory written by old
+// releases, in order:
+//
+//  1. library rows missing columns added since are filled in
+//  2. flat "{manga}_ch_{number}.epub" files at the root of dir the library
+//     lost track of are linked back to their chapter
+//  3. downloads are moved to follow templates, see PlanOrganize
+//  4. books without recorded checksums get their size and checksums
+//     recorded, see VerifyDownloads
+//
+// Running it again only does what is left to do.
+func MigrateLegacy(store MigrateStore, dir string, templates PathTemplates) (*MigrateReport, error) {
+	report := &MigrateReport{}
+	var err error
+	if report.RowsUpgraded, err = store.UpgradeLegacyRows(); err != nil {
+		return nil, fmt.Errorf("failed to upgrade the library: %w", err)
+	}
+
+	mangas, err := store.ListMangas()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mangas: %w", err)
+	}
+	if err := relinkLegacyFiles(store, dir, mangas, report); err != nil {
+		return nil, err
+	}
+
+	if report.Organize, err = PlanOrganize(store, dir, templates); err != nil {
+		return nil, err
+	}
+	if err := ApplyOrganize(store, report.Organize); err != nil {
+		return nil, err
+	}
+
+	options := VerifyOptions{OnFile: func(result VerifyResult) {
+		if result.Status == VerifyRecorded {
+			report.Recorded++
+		}
+	}}
+	verified, err := VerifyDownloads(store, store, mangas, options)
+	if err != nil {
+		return nil, err
+	}
+	report.Damaged = verified.Damaged
+
+	if err := store.SetState(legacyMigratedKey, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// relinkLegacyFiles marks the chapters whose flat file sits at the root of
+// dir as downloaded there, unless they are downloaded to a file still on disk
+func relinkLegacyFiles(store MigrateStore, dir string, mangas []*data.Manga, report *MigrateReport) error {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	// Chapters by the name of their legacy file, and the files in use
+	byName := make(map[string]*data.Chapter)
+	tracked := make(map[string]bool)
+	for _, manga := range mangas {
+		chapters, err := store.GetChapters(manga.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get chapters of %s: %w", manga.Name, err)
+		}
+		for _, chapter := range chapters {
+			if chapter.Downloaded && fileExists(chapter.FilePath) {
+				tracked[chapter.FilePath] = true
+				continue
+			}
+			name := integrations.ChapterPath(integrations.DefaultChapterPathTemplate, manga, chapter) + ".epub"
+			if _, taken := byName[name]; !taken {
+				byName[name] = chapter
+			}
+		}
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".epub") || !strings.Contains(name, "_ch_") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		report.LegacyFiles++
+		if tracked[path] {
+			continue
+		}
+		chapter := byName[name]
+		if chapter == nil {
+			report.Unmatched = append(report.Unmatched, path)
+			continue
+		}
+		if err := store.UpdateChapterStatus(chapter.ID, true, path); err != nil {
+			return fmt.Errorf("failed to relink %s: %w", path, err)
+		}
+		chapter.Downloaded, chapter.FilePath = true, path
+		tracked[path] = true
+		report.Relinked++
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+)
+
+func TestMigrateLegacy(t *testing.T) {
+	repo, err := data.OpenDuckDBRepository(filepath.Join(t.TempDir(), "library.db"))
+	if err != nil {
+		t.Fatalf("OpenDuckDBRepository() error = %v", err)
+	}
+	defer repo.Close()
+
+	// A flat download the library lost track of, and one of no chapter
+	dir := t.TempDir()
+	manga := &data.Manga{ID: "m1", Name: "Legacy", Source: "test"}
+	builder := integrations.NewEPubBuilder(dir)
+	builder.Init(manga, &data.Chapter{ID: "c1", Number: "1"})
+	builder.Next(integrations.ImageData{Content: encodeTestPage(t, 4), ContentType: "image/png", Index: 0})
+	legacy, err := builder.Done()
+	if err != nil {
+		t.Fatalf("Done() failed: %v", err)
+	}
+	if filepath.Base(legacy) != "Legacy_ch_1.epub" {
+		t.Fatalf("Expected a flat legacy file, got %s", legacy)
+	}
+	os.WriteFile(filepath.Join(dir, "Other_ch_9.epub"), []byte("epub"), 0644)
+
+	repo.SaveManga(manga)
+	repo.SaveChapter(&data.Chapter{ID: "c1", MangaID: "m1", Number: "1"})
+	repo.SaveChapter(&data.Chapter{ID: "c2", MangaID: "m1", Number: "2"})
+
+	if at, _ := LegacyMigratedAt(repo); !at.IsZero() {
+		t.Fatalf("Expected no migration recorded, got %v", at)
+	}
+	report, err := MigrateLegacy(repo, dir, PathTemplates{Chapter: "{manga}/c{number}"})
+	if err != nil {
+		t.Fatalf("MigrateLegacy() error = %v", err)
+	}
+	if report.LegacyFiles != 2 || report.Relinked != 1 || len(report.Unmatched) != 1 {
+		t.Errorf("Unexpected legacy files report: %+v", report)
+	}
+	if len(report.Organize.Moves) != 1 || report.Recorded != 1 || len(report.Damaged) != 0 {
+		t.Errorf("Unexpected organize and checksums report: %+v", report)
+	}
+
+	moved := filepath.Join(dir, "Legacy", "c1.epub")
+	chapters, _ := repo.GetChapters("m1")
+	if !chapters[0].Downloaded || chapters[0].FilePath != moved || chapters[1].Downloaded {
+		t.Errorf("Unexpected chapters after migration: %+v, %+v", chapters[0], chapters[1])
+	}
+	if sums, _ := repo.GetChapterChecksums("m1"); sums["c1"] == nil || sums["c1"].Path != moved || sums["c1"].Size == 0 {
+		t.Errorf("Expected the checksums of the moved book recorded, got %+v", sums["c1"])
+	}
+	if at, _ := LegacyMigratedAt(repo); at.IsZero() {
+		t.Error("Expected the migration recorded")
+	}
+
+	// Nothing left to do the second time
+	report, err = MigrateLegacy(repo, dir, PathTemplates{Chapter: "{manga}/c{number}"})
+	if err != nil || report.Relinked != 0 || len(report.Organize.Moves) != 0 || report.Recorded != 0 {
+		t.Errorf("Expected nothing left to migrate, got %+v, %v", report, err)
+	}
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/log"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// webhooksKey is the state key holding the webhooks, as JSON
+const webhooksKey = "webhooks"
+
+// webhookTimeout bounds a webhook request, a slow server must not hold
+// downloads back
+const webhookTimeout = 10 * time.Second
+
+// Download events sent to webhooks
+const (
+	EventChapter = "chapter" // A chapter finished downloading
+	EventManga   = "manga"   // A manga download finished
+	EventError   = "error"   // A chapter failed to download
+)
+
+// NotifyEvents are the events webhooks can subscribe to
+var NotifyEvents = []string{EventChapter, EventManga, EventError}
+
+// Webhook kinds, deciding the payload sent
+const (
+	WebhookDiscord = "discord"
+	WebhookSlack   = "slack"
+	WebhookGotify  = "gotify"
+	WebhookNtfy    = "ntfy"
+	WebhookJSON    = "json" // The event as JSON, for custom receivers
+)
+
+// WebhookKinds are the payloads a webhook can be sent
+var WebhookKinds = []string{WebhookDiscord, WebhookSlack, WebhookGotify, WebhookNtfy, WebhookJSON}
+
+// DefaultNotifyTemplates word the messages of events whose webhook has no
+// template of its own. They are text/template templates of a NotifyEvent.
+var DefaultNotifyTemplates = map[string]string{
+	EventChapter: "{{.Manga}}: chapter {{.Chapter}} downloaded",
+	EventManga:   "{{.Manga}}: {{.Downloaded}} chapter(s) downloaded{{if .Failed}}, {{.Failed}} failed{{end}}",
+	EventError:   "{{.Manga}}: chapter {{.Chapter}} failed to download: {{.Error}}",
+}
+
+// Webhook is an endpoint notified of download events
+type Webhook struct {
+	Name      string            `json:"name"`
+	URL       string            `json:"url"`
+	Kind      string            `json:"kind"`
+	Events    []string          `json:"events,omitempty"`    // All events when empty
+	Templates map[string]string `json:"templates,omitempty"` // Message template by event
+}
+
+// Wants reports whether the webhook subscribed to an event
+func (w Webhook) Wants(event string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, event)
+}
+
+// Validate checks the URL, kind, events and templates of the webhook
+func (w Webhook) Validate() error {
+	if w.Name == "" {
+		return fmt.Errorf("webhook name cannot be empty")
+	}
+	if u, err := url.Parse(w.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL %q, expected an http or https URL", w.URL)
+	}
+	if !slices.Contains(WebhookKinds, w.Kind) {
+		return fmt.Errorf("unknown webhook kind %q, expected one of %s", w.Kind, strings.Join(WebhookKinds, ", "))
+	}
+	for _, event := range w.Events {
+		if !slices.Contains(NotifyEvents, event) {
+			return fmt.Errorf("unknown event %q, expected one of %s", event, strings.Join(NotifyEvents, ", "))
+		}
+	}
+	for event, text := range w.Templates {
+		if !slices.Contains(NotifyEvents, event) {
+			return fmt.Errorf("template for unknown event %q", event)
+		}
+		if _, err := template.New(event).Parse(text); err != nil {
+			return fmt.Errorf("invalid %s template: %w", event, err)
+		}
+	}
+	return nil
+}
+
+// DetectWebhookKind guesses the kind of a webhook from its URL, WebhookJSON
+// when it isn't a known service
+func DetectWebhookKind(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return WebhookJSON
+	}
+	host := strings.ToLower(u.Hostname())
+	switch {
+	case (host == "discord.com" || host == "discordapp.com") && strings.HasPrefix(u.Path, "/api/webhooks/"):
+		return WebhookDiscord
+	case host == "hooks.slack.com":
+		return WebhookSlack
+	case host == "ntfy.sh":
+		return WebhookNtfy
+	case strings.HasSuffix(u.Path, "/message") && u.Query().Get("token") != "":
+		return WebhookGotify
+	}
+	return WebhookJSON
+}
+
+// LoadWebhooks returns the webhooks notified of download events
+func LoadWebhooks(store StateStore) ([]Webhook, error) {
+	value, err := store.GetState(webhooksKey)
+	if err != nil || value == "" {
+		return nil, err
+	}
+	var hooks []Webhook
+	if err := json.Unmarshal([]byte(value), &hooks); err != nil {
+		return nil, fmt.Errorf("invalid webhooks: %w", err)
+	}
+	return hooks, nil
+}
+
+// SaveWebhook adds a webhook, replacing the one with the same name
+func SaveWebhook(store StateStore, hook Webhook) error {
+	if err := hook.Validate(); err != nil {
+		return err
+	}
+	hooks, err := LoadWebhooks(store)
+	if err != nil {
+		return err
+	}
+	hooks = slices.DeleteFunc(hooks, func(h Webhook) bool { return h.Name == hook.Name })
+	return saveWebhooks(store, append(hooks, hook))
+}
+
+// RemoveWebhook removes the webhook with the given name
+func RemoveWebhook(store StateStore, name string) error {
+	hooks, err := LoadWebhooks(store)
+	if err != nil {
+		return err
+	}
+	kept := slices.DeleteFunc(slices.Clone(hooks), func(h Webhook) bool { return h.Name == name })
+	if len(kept) == len(hooks) {
+		return fmt.Errorf("no webhook named %q", name)
+	}
+	return saveWebhooks(store, kept)
+}
+
+func saveWebhooks(store StateStore, hooks []Webhook) error {
+	if len(hooks) == 0 {
+		return store.SetState(webhooksKey, "")
+	}
+	value, err := json.Marshal(hooks)
+	if err != nil {
+		return err
+	}
+	return store.SetState(webhooksKey, string(value))
+}
+
+// NotifyEvent is a download event, the data of the message templates
+type NotifyEvent struct {
+	Event        string `json:"event"`
+	Manga        string `json:"manga"`
+	MangaID      string `json:"manga_id"`
+	Chapter      string `json:"chapter,omitempty"`
+	ChapterTitle string `json:"chapter_title,omitempty"`
+	Path         string `json:"path,omitempty"`
+	Error        string `json:"error,omitempty"`
+
+	// Manga events
+	Downloaded int `json:"downloaded,omitempty"`
+	Failed     int `json:"failed,omitempty"`
+}
+
+func newNotifyEvent(event string, manga *data.Manga, chapter *data.Chapter) NotifyEvent {
+	e := NotifyEvent{Event: event, Manga: manga.Name, MangaID: manga.ID}
+	if chapter != nil {
+		e.Chapter, e.ChapterTitle, e.Path = chapter.Number, chapter.Title, chapter.FilePath
+	}
+	return e
+}
+
+// Message renders the message of an event for a webhook
+func (w Webhook) Message(event NotifyEvent) (string, error) {
+	text, ok := w.Templates[event.Event]
+	if !ok {
+		text = DefaultNotifyTemplates[event.Event]
+	}
+	tmpl, err := template.New(event.Event).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template: %w", event.Event, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, event); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", event.Event, err)
+	}
+	return b.String(), nil
+}
+
+// Notifier posts download events to webhooks in the background. A nil
+// Notifier notifies nobody.
+type Notifier struct {
+	hooks []Webhook
+	wg    sync.WaitGroup
+}
+
+// NewNotifier returns a notifier of hooks, nil when there are none
+func NewNotifier(hooks []Webhook) *Notifier {
+	if len(hooks) == 0 {
+		return nil
+	}
+	return &Notifier{hooks: hooks}
+}
+
+// Notify posts an event to the webhooks subscribed to it without waiting.
+// Failures are logged, they never affect downloads.
+func (n *Notifier) Notify(event NotifyEvent) {
+	if n == nil {
+		return
+	}
+	for _, hook := range n.hooks {
+		if !hook.Wants(event.Event) {
+			continue
+		}
+		n.wg.Add(1)
+		go func(hook Webhook) {
+			defer n.wg.Done()
+			if err := SendWebhook(hook, event); err != nil {
+				log.Warn("webhook failed", "webhook", hook.Name, "event", event.Event, "err", err)
+			}
+		}(hook)
+	}
+}
+
+// Wait blocks until the events notified so far are sent
+func (n *Notifier) Wait() {
+	if n != nil {
+		n.wg.Wait()
+	}
+}
+
+// SendWebhook posts an event to a webhook in the payload of its kind
+func SendWebhook(hook Webhook, event NotifyEvent) error {
+	message, err := hook.Message(event)
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	contentType := "application/json"
+	switch hook.Kind {
+	case WebhookDiscord:
+		body, err = json.Marshal(map[string]string{"content": message})
+	case WebhookSlack:
+		body, err = json.Marshal(map[string]string{"text": message})
+	case WebhookGotify:
+		body, err = json.Marshal(map[string]string{"title": "mangas", "message": message})
+	case WebhookNtfy:
+		body, contentType = []byte(message), "text/plain; charset=utf-8"
+	default:
+		body, err = json.Marshal(struct {
+			NotifyEvent
+			Message string `json:"message"`
+		}{event, message})
+	}
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if hook.Kind == WebhookNtfy {
+		req.Header.Set("Title", "mangas")
+	}
+	resp, err := utils.HTTPClient("").Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("bad status: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+// webhookRecorder is a webhook endpoint keeping the bodies posted to it
+type webhookRecorder struct {
+	mu     sync.Mutex
+	bodies []string
+	types  []string
+}
+
+func (r *webhookRecorder) server(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		r.bodies = append(r.bodies, string(body))
+		r.types = append(r.types, req.Header.Get("Content-Type"))
+		r.mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSendWebhook_Payloads(t *testing.T) {
+	event := NotifyEvent{Event: EventChapter, Manga: "Naruto", MangaID: "m1", Chapter: "12"}
+	tests := []struct {
+		kind string
+		want string
+	}{
+		{WebhookDiscord, `{"content":"Naruto: chapter 12 downloaded"}`},
+		{WebhookSlack, `{"text":"Naruto: chapter 12 downloaded"}`},
+		{WebhookGotify, `{"message":"Naruto: chapter 12 downloaded","title":"mangas"}`},
+		{WebhookNtfy, `Naruto: chapter 12 downloaded`},
+		{WebhookJSON, `{"event":"chapter","manga":"Naruto","manga_id":"m1","chapter":"12","message":"Naruto: chapter 12 downloaded"}`},
+	}
+
+	for _, tt := range tests {
+		recorder := &webhookRecorder{}
+		server := recorder.server(t)
+		if err := SendWebhook(Webhook{Name: tt.kind, URL: server.URL, Kind: tt.kind}, event); err != nil {
+			t.Fatalf("SendWebhook(%s) failed: %v", tt.kind, err)
+		}
+		if len(recorder.bodies) != 1 || recorder.bodies[0] != tt.want {
+			t.Errorf("%s payload = %q, want %q", tt.kind, recorder.bodies, tt.want)
+		}
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer failing.Close()
+	if err := SendWebhook(Webhook{URL: failing.URL, Kind: WebhookSlack}, event); err == nil {
+		t.Error("expected an error for a rejected webhook")
+	}
+}
+
+func TestWebhook_Message(t *testing.T) {
+	hook := Webhook{Templates: map[string]string{EventError: "{{.Manga}} #{{.Chapter}}: {{.Error}}"}}
+	message, err := hook.Message(NotifyEvent{Event: EventError, Manga: "Naruto", Chapter: "3", Error: "timeout"})
+	if err != nil || message != "Naruto #3: timeout" {
+		t.Errorf("Message() = %q, %v", message, err)
+	}
+
+	message, _ = hook.Message(NotifyEvent{Event: EventManga, Manga: "Naruto", Downloaded: 4, Failed: 1})
+	if message != "Naruto: 4 chapter(s) downloaded, 1 failed" {
+		t.Errorf("default manga message = %q", message)
+	}
+}
+
+func TestDetectWebhookKind(t *testing.T) {
+	tests := map[string]string{
+		"https://discord.com/api/webhooks/1/abc":         WebhookDiscord,
+		"https://hooks.slack.com/services/T/B/x":         WebhookSlack,
+		"https://ntfy.sh/my-topic":                       WebhookNtfy,
+		"https://gotify.example.com/message?token=Abc12": WebhookGotify,
+		"https://example.com/hook":                       WebhookJSON,
+	}
+	for url, want := range tests {
+		if got := DetectWebhookKind(url); got != want {
+			t.Errorf("DetectWebhookKind(%s) = %s, want %s", url, got, want)
+		}
+	}
+}
+
+func TestWebhooks_SaveRemove(t *testing.T) {
+	store := &mockQuotaStore{state: make(map[string]string)}
+
+	invalid := []Webhook{
+		{Name: "a", URL: "ftp://example.com", Kind: WebhookJSON},
+		{Name: "a", URL: "https://example.com", Kind: "pager"},
+		{Name: "a", URL: "https://example.com", Kind: WebhookJSON, Events: []string{"deleted"}},
+		{Name: "a", URL: "https://example.com", Kind: WebhookJSON, Templates: map[string]string{EventChapter: "{{.Manga"}},
+	}
+	for _, hook := range invalid {
+		if err := SaveWebhook(store, hook); err == nil {
+			t.Errorf("SaveWebhook(%+v) should fail", hook)
+		}
+	}
+
+	if err := SaveWebhook(store, Webhook{Name: "phone", URL: "https://ntfy.sh/a", Kind: WebhookNtfy}); err != nil {
+		t.Fatalf("SaveWebhook failed: %v", err)
+	}
+	if err := SaveWebhook(store, Webhook{Name: "phone", URL: "https://ntfy.sh/b", Kind: WebhookNtfy, Events: []string{EventError}}); err != nil {
+		t.Fatalf("SaveWebhook failed: %v", err)
+	}
+	hooks, err := LoadWebhooks(store)
+	if err != nil || len(hooks) != 1 || hooks[0].URL != "https://ntfy.sh/b" {
+		t.Fatalf("LoadWebhooks() = %+v, %v, want the replaced webhook", hooks, err)
+	}
+	if hooks[0].Wants(EventChapter) || !hooks[0].Wants(EventError) {
+		t.Error("webhook events not honoured")
+	}
+
+	if err := RemoveWebhook(store, "tablet"); err == nil {
+		t.Error("expected an error removing an unknown webhook")
+	}
+	if err := RemoveWebhook(store, "phone"); err != nil {
+		t.Fatalf("RemoveWebhook failed: %v", err)
+	}
+	if hooks, _ := LoadWebhooks(store); len(hooks) != 0 {
+		t.Errorf("webhooks left: %+v", hooks)
+	}
+}
+
+func TestDownloader_Notifies(t *testing.T) {
+	pngData := createTestPNG()
+	pages := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "missing") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngData)
+	}))
+	defer pages.Close()
+
+	source := &mockSource{
+		getPagesFunc: func(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+			if chapter.Number == "2" {
+				return []string{pages.URL + "/missing.png"}, nil
+			}
+			return []string{pages.URL + "/page.png"}, nil
+		},
+	}
+	recorder := &webhookRecorder{}
+	hook := recorder.server(t)
+
+	downloader := NewDownloader(source, &mockRepository{}, t.TempDir())
+	downloader.SetNotifier(NewNotifier([]Webhook{{Name: "test", URL: hook.URL, Kind: WebhookJSON}}))
+
+	manga := &data.Manga{ID: "m1", Name: "Naruto"}
+	chapters := []*data.Chapter{
+		{ID: "c1", MangaID: "m1", Number: "1"},
+		{ID: "c2", MangaID: "m1", Number: "2"},
+	}
+	if err := downloader.DownloadManga(manga, chapters); err != nil {
+		t.Fatalf("DownloadManga failed: %v", err)
+	}
+	downloader.Close()
+
+	events := make(map[string]NotifyEvent)
+	for _, body := range recorder.bodies {
+		var event NotifyEvent
+		if err := json.Unmarshal([]byte(body), &event); err != nil {
+			t.Fatalf("invalid payload %q: %v", body, err)
+		}
+		events[event.Event] = event
+	}
+	if len(recorder.bodies) != 3 {
+		t.Fatalf("got %d notifications, want 3: %q", len(recorder.bodies), recorder.bodies)
+	}
+	if events[EventChapter].Chapter != "1" || events[EventChapter].Path == "" {
+		t.Errorf("chapter event = %+v", events[EventChapter])
+	}
+	if events[EventError].Chapter != "2" || events[EventError].Error == "" {
+		t.Errorf("error event = %+v", events[EventError])
+	}
+	if manga := events[EventManga]; manga.Downloaded != 1 || manga.Failed != 1 {
+		t.Errorf("manga event = %+v", manga)
+	}
+}
+
+func TestDownloader_NotifiesBundled(t *testing.T) {
+	dir := t.TempDir()
+	// Chapter 1 is on disk already and skipped; volume 2 is downloaded
+	done := filepath.Join(dir, "done.epub")
+	if err := os.WriteFile(done, []byte("epub"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	downloader, _ := volumeDownloader(t, dir, []*data.Chapter{
+		{ID: "ch-1", MangaID: "manga-1", Number: "1", Downloaded: true, FilePath: done},
+	})
+	recorder := &webhookRecorder{}
+	hook := recorder.server(t)
+	downloader.SetNotifier(NewNotifier([]Webhook{{Name: "test", URL: hook.URL, Kind: WebhookJSON}}))
+
+	manga := &data.Manga{ID: "manga-1", Name: "Test Manga"}
+	chapters := []*data.Chapter{
+		{ID: "ch-1", MangaID: "manga-1", Number: "1"},
+		{ID: "ch-2", MangaID: "manga-1", Number: "2", Volume: "2"},
+		{ID: "ch-3", MangaID: "manga-1", Number: "3", Volume: "2"},
+	}
+	if err := downloader.DownloadMangaBundled(manga, chapters, BundleVolume, nil); err != nil {
+		t.Fatalf("DownloadMangaBundled failed: %v", err)
+	}
+	// Nothing left to download, nothing to report
+	if err := downloader.DownloadMangaBundled(manga, chapters[:1], BundleVolume, nil); err != nil {
+		t.Fatalf("DownloadMangaBundled failed: %v", err)
+	}
+	downloader.Close()
+
+	var downloaded []string
+	var summaries []NotifyEvent
+	for _, body := range recorder.bodies {
+		var event NotifyEvent
+		if err := json.Unmarshal([]byte(body), &event); err != nil {
+			t.Fatalf("invalid payload %q: %v", body, err)
+		}
+		switch event.Event {
+		case EventChapter:
+			downloaded = append(downloaded, event.Chapter)
+		case EventManga:
+			summaries = append(summaries, event)
+		}
+	}
+	slices.Sort(downloaded)
+	if !slices.Equal(downloaded, []string{"2", "3"}) {
+		t.Errorf("chapter events for %v, want the volume's chapters 2 and 3", downloaded)
+	}
+	if len(summaries) != 1 || summaries[0].Downloaded != 2 || summaries[0].Failed != 0 {
+		t.Errorf("manga events = %+v, want one for the 2 chapters downloaded", summaries)
+	}
+}
+
+func TestDownloader_NotifiesVolumeErrors(t *testing.T) {
+	source := &mockSource{
+		getPagesFunc: func(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+			return nil, fmt.Errorf("gone")
+		},
+	}
+	recorder := &webhookRecorder{}
+	hook := recorder.server(t)
+	downloader := NewDownloader(source, &mockRepository{}, t.TempDir())
+	downloader.SetNotifier(NewNotifier([]Webhook{{Name: "test", URL: hook.URL, Kind: WebhookJSON}}))
+
+	manga := &data.Manga{ID: "manga-1", Name: "Test Manga"}
+	chapters := []*data.Chapter{
+		{ID: "ch-1", MangaID: "manga-1", Number: "1", Volume: "1"},
+		{ID: "ch-2", MangaID: "manga-1", Number: "2", Volume: "1"},
+	}
+	if err := downloader.DownloadVolume(manga, "1", chapters); err == nil {
+		t.Fatal("DownloadVolume succeeded without pages")
+	}
+	downloader.Close()
+
+	var failed []string
+	for _, body := range recorder.bodies {
+		var event NotifyEvent
+		if err := json.Unmarshal([]byte(body), &event); err != nil {
+			t.Fatalf("invalid payload %q: %v", body, err)
+		}
+		if event.Event == EventError && event.Error != "" {
+			failed = append(failed, event.Chapter)
+		}
+	}
+	slices.Sort(failed)
+	if !slices.Equal(failed, []string{"1", "2"}) {
+		t.Errorf("error events for %v, want chapters 1 and 2", failed)
+	}
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+)
+
+// PathTemplates lay out the books written to the download directory, see
+// integrations.ChapterPath and integrations.VolumePath. Empty templates are
+// the defaults.
+type PathTemplates struct {
+	Chapter string
+	Volume  string
+}
+
+// LoadPathTemplates returns the path templates set with 'mangas config'
+func LoadPathTemplates(store StateStore) (PathTemplates, error) {
+	chapter, err := store.GetState(ChapterPathTemplateKey)
+	if err != nil {
+		return PathTemplates{}, err
+	}
+	volume, err := store.GetState(VolumePathTemplateKey)
+	if err != nil {
+		return PathTemplates{}, err
+	}
+	return PathTemplates{Chapter: chapter, Volume: volume}, nil
+}
+
+// OrganizeStore records the new paths of the books moved by ApplyOrganize
+type OrganizeStore interface {
+	MoveChapterFiles(paths map[string]string) error
+}
+
+// OrganizeMove is a downloaded book moved to follow the path templates
+type OrganizeMove struct {
+	Manga    *data.Manga
+	Chapters []*data.Chapter // Chapters in the book, several for a volume
+	From     string
+	To       string
+}
+
+// OrganizePlan lists the books to move so downloads follow the path
+// templates
+type OrganizePlan struct {
+	Dir     string // Download directory
+	Moves   []OrganizeMove
+	Skipped []string // Books left where they are, with the reason
+}
+
+// PlanOrganize works out where the books downloaded to dir go with
+// templates. Books holding a whole volume follow the volume template, and
+// chapters merged with 'mangas merge' the chapter template of the first.
+// Books outside dir are left alone, and so are books whose new path is
+// taken, by another book or a file already there: nothing is overwritten.
+func PlanOrganize(repo Repository, dir string, templates PathTemplates) (*OrganizePlan, error) {
+	mangas, err := repo.ListMangas()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mangas: %w", err)
+	}
+
+	plan := &OrganizePlan{Dir: dir}
+	taken := make(map[string]string) // New paths, to the book moved there
+	for _, manga := range mangas {
+		chapters, err := repo.GetChapters(manga.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get chapters of %s: %w", manga.Name, err)
+		}
+
+		// Chapters bundled in a volume share their book
+		var paths []string
+		books := make(map[string][]*data.Chapter)
+		for _, chapter := range chapters {
+			if !chapter.Downloaded || chapter.FilePath == "" {
+				continue
+			}
+			if books[chapter.FilePath] == nil {
+				paths = append(paths, chapter.FilePath)
+			}
+			books[chapter.FilePath] = append(books[chapter.FilePath], chapter)
+		}
+
+		for _, from := range paths {
+			if !insideDir(dir, from) {
+				continue
+			}
+			inBook := books[from]
+			var path string
+			if volumeBook(inBook, books) {
+				path = integrations.VolumePath(templates.Volume, manga, inBook[0].Volume)
+			} else {
+				path = integrations.ChapterPath(templates.Chapter, manga, inBook[0])
+			}
+			to := filepath.Join(dir, path+filepath.Ext(from))
+			if to == from {
+				continue
+			}
+
+			if _, err := os.Stat(from); err != nil {
+				plan.Skipped = append(plan.Skipped, fmt.Sprintf("%s: file is missing, run 'mangas repair'", from))
+				continue
+			}
+			if other, ok := taken[to]; ok {
+				plan.Skipped = append(plan.Skipped, fmt.Sprintf("%s: %s goes to the same path, make the template tell them apart", from, other))
+				continue
+			}
+			if _, err := os.Lstat(to); err == nil {
+				plan.Skipped = append(plan.Skipped, fmt.Sprintf("%s: %s already exists", from, to))
+				continue
+			}
+			taken[to] = from
+			plan.Moves = append(plan.Moves, OrganizeMove{Manga: manga, Chapters: inBook, From: from, To: to})
+		}
+	}
+	return plan, nil
+}
+
+// volumeBook reports whether the chapters of a book were bundled as their
+// volume: they share one, and no downloaded chapter of it is in another
+// book. Otherwise they were merged, see integrations.MergeChapterBooks.
+func volumeBook(inBook []*data.Chapter, books map[string][]*data.Chapter) bool {
+	volume := inBook[0].Volume
+	if len(inBook) < 2 || volume == "" {
+		return false
+	}
+	for _, chapter := range inBook {
+		if chapter.Volume != volume {
+			return false
+		}
+	}
+	for path, chapters := range books {
+		if path == inBook[0].FilePath {
+			continue
+		}
+		for _, chapter := range chapters {
+			if chapter.Volume == volume {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ApplyOrganize moves the books of plan, with their OCR sidecars, then
+// records their new paths in the library in one transaction. When the
+// library can't be updated the books are moved back, so the files and the
+// library never disagree. Directories left empty are removed.
+func ApplyOrganize(store OrganizeStore, plan *OrganizePlan) error {
+	var moved []OrganizeMove
+	undo := func() {
+		for i := len(moved) - 1; i >= 0; i-- {
+			moveBook(moved[i].To, moved[i].From)
+		}
+	}
+
+	for _, move := range plan.Moves {
+		if err := moveBook(move.From, move.To); err != nil {
+			undo()
+			return fmt.Errorf("failed to move %s: %w", move.From, err)
+		}
+		moved = append(moved, move)
+	}
+
+	paths := make(map[string]string, len(plan.Moves))
+	for _, move := range plan.Moves {
+		paths[move.From] = move.To
+	}
+	if err := store.MoveChapterFiles(paths); err != nil {
+		undo()
+		return fmt.Errorf("failed to update the library: %w", err)
+	}
+
+	for _, move := range plan.Moves {
+		removeEmptyDirs(plan.Dir, filepath.Dir(move.From))
+	}
+	return nil
+}
+
+// moveBook moves a book and its OCR sidecar, if any
+func moveBook(from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(from, to); err != nil {
+		return err
+	}
+	sidecar := integrations.OCRSidecarPath(from)
+	if _, err := os.Stat(sidecar); err == nil {
+		return os.Rename(sidecar, integrations.OCRSidecarPath(to))
+	}
+	return nil
+}
+
+// removeEmptyDirs removes dir and its parents while they are empty, up to
+// root excluded
+func removeEmptyDirs(root, dir string) {
+	for insideDir(root, dir) && filepath.Clean(dir) != filepath.Clean(root) {
+		if err := os.Remove(dir); err != nil {
+			return // Not empty
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// insideDir reports whether path is in dir or below
+func insideDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+)
+
+func TestOrganize(t *testing.T) {
+	repo, err := data.OpenDuckDBRepository(filepath.Join(t.TempDir(), "library.db"))
+	if err != nil {
+		t.Fatalf("OpenDuckDBRepository() error = %v", err)
+	}
+	defer repo.Close()
+
+	dir := t.TempDir()
+	book := func(name string) string {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte("epub"), 0644)
+		return path
+	}
+	manga := &data.Manga{ID: "m1", Name: "Test", Source: "test"}
+	repo.SaveManga(manga)
+	chapter := book("Test_ch_1.epub")
+	os.WriteFile(integrations.OCRSidecarPath(chapter), []byte("text"), 0644)
+	volume := book("Test_vol_1.epub")
+	repo.SaveChapter(&data.Chapter{ID: "c1", MangaID: "m1", Number: "1", Downloaded: true, FilePath: chapter})
+	repo.SaveChapter(&data.Chapter{ID: "c2", MangaID: "m1", Number: "2", Volume: "1", Downloaded: true, FilePath: volume})
+	repo.SaveChapter(&data.Chapter{ID: "c3", MangaID: "m1", Number: "3", Volume: "1", Downloaded: true, FilePath: volume})
+	repo.SaveChapter(&data.Chapter{ID: "c4", MangaID: "m1", Number: "4", Downloaded: true, FilePath: "/elsewhere/Test_ch_4.epub"})
+	merged := book("Test_ch_5.epub")
+	repo.SaveChapter(&data.Chapter{ID: "c5", MangaID: "m1", Number: "5", Volume: "2", Downloaded: true, FilePath: merged})
+	repo.SaveChapter(&data.Chapter{ID: "c6", MangaID: "m1", Number: "6", Downloaded: true, FilePath: merged})
+	repo.SaveChapterChecksum(&data.ChapterChecksum{ChapterID: "c1", Path: chapter, SHA256: "abc"})
+
+	templates := PathTemplates{Chapter: "{manga}/c{number}", Volume: "{manga}/v{volume}"}
+	plan, err := PlanOrganize(repo, dir, templates)
+	if err != nil {
+		t.Fatalf("PlanOrganize() error = %v", err)
+	}
+	if len(plan.Moves) != 3 || len(plan.Skipped) != 0 {
+		t.Fatalf("Expected the chapters and the volume to move, got %+v", plan)
+	}
+
+	if err := ApplyOrganize(repo, plan); err != nil {
+		t.Fatalf("ApplyOrganize() error = %v", err)
+	}
+	chapters, _ := repo.GetChapters("m1")
+	want := map[string]string{
+		"c1": filepath.Join(dir, "Test", "c1.epub"),
+		"c2": filepath.Join(dir, "Test", "v1.epub"),
+		"c3": filepath.Join(dir, "Test", "v1.epub"),
+		"c4": "/elsewhere/Test_ch_4.epub",
+		// Merged chapters are named after the first, not as a volume
+		"c5": filepath.Join(dir, "Test", "c5.epub"),
+		"c6": filepath.Join(dir, "Test", "c5.epub"),
+	}
+	for _, ch := range chapters {
+		if ch.FilePath != want[ch.ID] {
+			t.Errorf("Chapter %s at %s, want %s", ch.ID, ch.FilePath, want[ch.ID])
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Test", "c1.txt")); err != nil {
+		t.Error("Expected the OCR sidecar to follow its book")
+	}
+	if sums, _ := repo.GetChapterChecksums("m1"); sums["c1"].Path != want["c1"] {
+		t.Errorf("Expected the checksum path updated, got %s", sums["c1"].Path)
+	}
+
+	// Back to the default flat layout, the emptied directory goes away
+	plan, _ = PlanOrganize(repo, dir, PathTemplates{})
+	if err := ApplyOrganize(repo, plan); err != nil {
+		t.Fatalf("ApplyOrganize() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Test")); !os.IsNotExist(err) {
+		t.Error("Expected the emptied manga directory removed")
+	}
+	if plan, _ := PlanOrganize(repo, dir, PathTemplates{}); len(plan.Moves) != 0 {
+		t.Errorf("Expected nothing left to move, got %+v", plan.Moves)
+	}
+}
+
+func TestPlanOrganize_Conflicts(t *testing.T) {
+	dir := t.TempDir()
+	en := filepath.Join(dir, "a.epub")
+	es := filepath.Join(dir, "b.epub")
+	os.WriteFile(en, []byte("epub"), 0644)
+	os.WriteFile(es, []byte("epub"), 0644)
+	os.WriteFile(filepath.Join(dir, "Test - c2.epub"), []byte("someone else's"), 0644)
+
+	repo := &mockRepository{
+		listMangasFunc: func() ([]*data.Manga, error) {
+			return []*data.Manga{{ID: "m1", Name: "Test"}}, nil
+		},
+		getChaptersFunc: func(mangaID string) ([]*data.Chapter, error) {
+			return []*data.Chapter{
+				{ID: "c1", Number: "1", Language: "en", Downloaded: true, FilePath: en},
+				{ID: "c1-es", Number: "1", Language: "es", Downloaded: true, FilePath: es},
+				{ID: "c2", Number: "2", Downloaded: true, FilePath: filepath.Join(dir, "c.epub")},
+			}, nil
+		},
+	}
+
+	plan, err := PlanOrganize(repo, dir, PathTemplates{Chapter: "{manga} - c{number}"})
+	if err != nil {
+		t.Fatalf("PlanOrganize() error = %v", err)
+	}
+	// Both languages map to the same path, the missing file is left alone
+	if len(plan.Moves) != 1 || plan.Moves[0].From != en || len(plan.Skipped) != 2 {
+		t.Errorf("Expected one move and two skipped books, got %+v", plan)
+	}
+
+	// The existing file is never overwritten
+	os.WriteFile(filepath.Join(dir, "c.epub"), []byte("epub"), 0644)
+	plan, _ = PlanOrganize(repo, dir, PathTemplates{Chapter: "{manga} - c{number}"})
+	if len(plan.Skipped) != 2 {
+		t.Errorf("Expected the taken path skipped, got %v", plan.Skipped)
+	}
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/sources"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// ReadPageList reads a list of page images, one URL or local file per line
+// in reading order. Blank lines and lines starting with # are skipped.
+func ReadPageList(r io.Reader) ([]string, error) {
+	var pages []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pages = append(pages, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read page list: %w", err)
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("the page list is empty")
+	}
+	return pages, nil
+}
+
+// pageListSource lists the pages given by the user instead of the ones of
+// its source, which still provides the covers. Only its pages may be local
+// files: sources can't make the downloader read from disk.
+type pageListSource struct {
+	sources.Source
+	pages []string
+}
+
+func (s *pageListSource) GetPages(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+	return s.pages, nil
+}
+
+// localPagePath returns the file of a page given as a path or file:// URL
+func localPagePath(page string) (string, bool) {
+	if path, ok := strings.CutPrefix(page, "file://"); ok {
+		return path, true
+	}
+	return page, !strings.Contains(page, "://")
+}
+
+// DownloadChapterPages builds the book of a chapter from a list of page
+// image URLs or local files, see ReadPageList, instead of the pages its
+// source lists, e.g. when the source is broken but the pages are known.
+// Pages go through the same processing as any download.
+func (d *Downloader) DownloadChapterPages(manga *data.Manga, chapter *data.Chapter, pages []string) error {
+	if manga == nil {
+		return fmt.Errorf("manga cannot be nil")
+	}
+	if chapter == nil {
+		return fmt.Errorf("chapter cannot be nil")
+	}
+	if len(pages) == 0 {
+		return fmt.Errorf("no pages given for chapter")
+	}
+	return d.downloadChapter(&pageListSource{Source: d.sourceForChapter(manga, chapter), pages: pages}, manga, chapter, d.settings())
+}
+
+// pageImage downloads a page, or reads it from disk when it is a local file
+// of a page list, or a file:// page under the root of a local source
+func (d *Downloader) pageImage(source sources.Source, manga *data.Manga, page string, index int) (integrations.ImageData, int, error) {
+	switch source := source.(type) {
+	case *pageListSource:
+		if path, local := localPagePath(page); local {
+			image, err := d.readPageFile(path, index)
+			return image, 0, err
+		}
+	case sources.LocalSource:
+		if path, local := strings.CutPrefix(page, "file://"); local {
+			path = filepath.FromSlash(path)
+			if !utils.IsWithin(source.Root(), path) {
+				return integrations.ImageData{}, 0, fmt.Errorf("%w: %s", utils.ErrPathTraversal, path)
+			}
+			image, err := d.readPageFile(path, index)
+			return image, 0, err
+		}
+	}
+	return d.downloadImageWithRetries(statsSource(manga), page, index)
+}
+
+// readPageFile reads a page image from disk, checking it like downloaded
+// ones
+func (d *Downloader) readPageFile(path string, index int) (integrations.ImageData, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return integrations.ImageData{}, err
+	}
+	contentType := http.DetectContentType(content)
+	if DetectArchive(content) == ArchiveNone {
+		if err := integrations.CheckImage(content, contentType, d.settings().imageCheck); err != nil {
+			return integrations.ImageData{}, fmt.Errorf("damaged image %s: %w", path, err)
+		}
+	}
+	return integrations.ImageData{Content: content, ContentType: contentType, Index: index}, nil
+}
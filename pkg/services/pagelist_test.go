@@ -0,0 +1,162 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/sources"
+)
+
+func TestReadPageList(t *testing.T) {
+	pages, err := ReadPageList(strings.NewReader("# Chapter 5\nhttps://example.com/1.png\n\n  pages/2.png  \nfile:///tmp/3.png\n"))
+	if err != nil {
+		t.Fatalf("ReadPageList failed: %v", err)
+	}
+	want := []string{"https://example.com/1.png", "pages/2.png", "file:///tmp/3.png"}
+	if fmt.Sprint(pages) != fmt.Sprint(want) {
+		t.Errorf("pages = %q, want %q", pages, want)
+	}
+
+	if _, err := ReadPageList(strings.NewReader("# nothing\n\n")); err == nil {
+		t.Error("expected an error for an empty list")
+	}
+}
+
+func TestDownloader_DownloadChapterPages(t *testing.T) {
+	pngData := createTestPNG()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngData)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	local := filepath.Join(dir, "page2.png")
+	if err := os.WriteFile(local, pngData, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source := &mockSource{
+		getPagesFunc: func(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+			return nil, fmt.Errorf("source is broken")
+		},
+	}
+	var savedPath string
+	repo := &mockRepository{
+		updateChapterStatusFunc: func(chapterID string, downloaded bool, filePath string) error {
+			savedPath = filePath
+			return nil
+		},
+	}
+	downloader := NewDownloader(source, repo, filepath.Join(dir, "downloads"))
+	defer downloader.Close()
+
+	manga := &data.Manga{ID: "manga-1", Name: "Test Manga"}
+	chapter := &data.Chapter{ID: "ch-5", MangaID: "manga-1", Number: "5"}
+	pages := []string{server.URL + "/page1.png", local, "file://" + local}
+	if err := downloader.DownloadChapterPages(manga, chapter, pages); err != nil {
+		t.Fatalf("DownloadChapterPages failed: %v", err)
+	}
+	if !chapter.Downloaded || savedPath == "" || savedPath != chapter.FilePath {
+		t.Fatalf("chapter not recorded as downloaded: %+v, saved %q", chapter, savedPath)
+	}
+	read, err := integrations.ReadEPUBPages(chapter.FilePath)
+	if err != nil {
+		t.Fatalf("ReadEPUBPages failed: %v", err)
+	}
+	if len(read) != 3 {
+		t.Errorf("book has %d pages, want 3", len(read))
+	}
+
+	if err := downloader.DownloadChapterPages(manga, chapter, nil); err == nil {
+		t.Error("expected an error without pages")
+	}
+}
+
+func TestDownloader_SourcePagesNeverReadFromDisk(t *testing.T) {
+	local := filepath.Join(t.TempDir(), "page.png")
+	if err := os.WriteFile(local, createTestPNG(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	source := &mockSource{
+		getPagesFunc: func(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+			return []string{local}, nil
+		},
+	}
+	options := DefaultDownloaderOptions()
+	options.Retry.Attempts = 1
+	downloader := NewDownloaderWithOptions(source, &mockRepository{}, t.TempDir(), options)
+	defer downloader.Close()
+
+	err := downloader.DownloadChapter(&data.Manga{ID: "manga-1"}, &data.Chapter{ID: "ch-1", Number: "1"})
+	if err == nil {
+		t.Error("a page listed by a source was read from disk")
+	}
+}
+
+// escapingLocalSource is a local source listing a page outside its root
+type escapingLocalSource struct {
+	*sources.Local
+	page string
+}
+
+func (s *escapingLocalSource) GetPages(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+	return []string{s.page}, nil
+}
+
+func TestDownloader_LocalSource(t *testing.T) {
+	root := t.TempDir()
+	chapterDir := filepath.Join(root, "Scans", "Chapter 3")
+	if err := os.MkdirAll(chapterDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"1.png", "2.png"} {
+		if err := os.WriteFile(filepath.Join(chapterDir, name), createTestPNG(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	local := sources.NewLocalAt(root)
+	sources.Register("local", func() sources.Source { return local })
+	t.Cleanup(func() { sources.Register("local", sources.NewLocal) })
+	manga, err := local.GetManga("Scans")
+	if err != nil {
+		t.Fatalf("GetManga failed: %v", err)
+	}
+	chapters, err := local.GetChapters(manga)
+	if err != nil || len(chapters) != 1 {
+		t.Fatalf("GetChapters = %v, %v, want one chapter", chapters, err)
+	}
+
+	options := DefaultDownloaderOptions()
+	options.Retry.Attempts = 1
+	downloader := NewDownloaderWithOptions(local, &mockRepository{}, filepath.Join(t.TempDir(), "downloads"), options)
+	defer downloader.Close()
+	if err := downloader.DownloadChapter(manga, chapters[0]); err != nil {
+		t.Fatalf("DownloadChapter failed: %v", err)
+	}
+	read, err := integrations.ReadEPUBPages(chapters[0].FilePath)
+	if err != nil {
+		t.Fatalf("ReadEPUBPages failed: %v", err)
+	}
+	if len(read) != 2 {
+		t.Errorf("book has %d pages, want 2", len(read))
+	}
+
+	outside := filepath.Join(t.TempDir(), "page.png")
+	if err := os.WriteFile(outside, createTestPNG(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	escaping := &escapingLocalSource{Local: local, page: "file://" + filepath.ToSlash(outside)}
+	downloader = NewDownloaderWithOptions(escaping, &mockRepository{}, t.TempDir(), options)
+	defer downloader.Close()
+	if err := downloader.DownloadChapter(&data.Manga{ID: "Scans"}, &data.Chapter{ID: "Scans/other", Number: "4"}); err == nil {
+		t.Error("a page outside the local source's root was read")
+	}
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// prefetchKey is the state key holding how many chapters are prefetched
+const prefetchKey = "prefetch_chapters"
+
+// StateStore keeps settings between runs
+type StateStore interface {
+	GetState(key string) (string, error)
+	SetState(key, value string) error
+}
+
+// LoadPrefetch returns how many chapters to prefetch when one is opened,
+// 0 when prefetching is off
+func LoadPrefetch(store StateStore) (int, error) {
+	value, err := store.GetState(prefetchKey)
+	if err != nil || value == "" {
+		return 0, err
+	}
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 0 {
+		return 0, fmt.Errorf("invalid prefetch setting %q", value)
+	}
+	return count, nil
+}
+
+// SavePrefetch keeps how many chapters to prefetch, 0 turns it off
+func SavePrefetch(store StateStore, count int) error {
+	if count <= 0 {
+		return store.SetState(prefetchKey, "")
+	}
+	return store.SetState(prefetchKey, strconv.Itoa(count))
+}
+
+// NextChapters returns up to count chapters following chapter in reading
+// order, in its language. When several chapters share a number, the
+// downloaded one is preferred.
+func NextChapters(chapters []*data.Chapter, chapter *data.Chapter, count int) []*data.Chapter {
+	current := utils.ChapterSortKey(chapter.Number)
+	byNumber := make(map[string]*data.Chapter)
+	var keys []string
+	for _, ch := range chapters {
+		if ch.Language != chapter.Language {
+			continue
+		}
+		key := utils.ChapterSortKey(ch.Number)
+		if key <= current {
+			continue
+		}
+		if existing, ok := byNumber[key]; !ok {
+			keys = append(keys, key)
+			byNumber[key] = ch
+		} else if ch.Downloaded && !existing.Downloaded {
+			byNumber[key] = ch
+		}
+	}
+	sort.Strings(keys)
+
+	if len(keys) > count {
+		keys = keys[:count]
+	}
+	next := make([]*data.Chapter, len(keys))
+	for i, key := range keys {
+		next[i] = byNumber[key]
+	}
+	return next
+}
+
+// Prefetch queues the count chapters following chapter that are not
+// downloaded yet, so they are ready when the reader gets there. It returns
+// the chapters queued; running the queue is up to the caller.
+func (q *DownloadQueue) Prefetch(manga *data.Manga, chapter *data.Chapter, count int) ([]*data.Chapter, error) {
+	if count <= 0 {
+		return nil, nil
+	}
+	chapters, err := q.repo.GetChapters(manga.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chapters: %w", err)
+	}
+
+	var missing []*data.Chapter
+	for _, next := range NextChapters(chapters, chapter, count) {
+		if !next.Downloaded {
+			missing = append(missing, next)
+		}
+	}
+	if err := q.Add(manga, missing...); err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
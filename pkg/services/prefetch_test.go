@@ -0,0 +1,90 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+func TestPrefetch_SaveAndLoad(t *testing.T) {
+	store := &mockQuotaStore{state: make(map[string]string)}
+
+	if count, err := LoadPrefetch(store); err != nil || count != 0 {
+		t.Fatalf("LoadPrefetch() = %d, %v, want prefetching off", count, err)
+	}
+	if err := SavePrefetch(store, 3); err != nil {
+		t.Fatalf("SavePrefetch() error = %v", err)
+	}
+	if count, err := LoadPrefetch(store); err != nil || count != 3 {
+		t.Errorf("LoadPrefetch() = %d, %v, want 3", count, err)
+	}
+	if err := SavePrefetch(store, 0); err != nil {
+		t.Fatalf("SavePrefetch() error = %v", err)
+	}
+	if count, _ := LoadPrefetch(store); count != 0 {
+		t.Errorf("Expected prefetching off, got %d", count)
+	}
+}
+
+func TestNextChapters(t *testing.T) {
+	chapters := []*data.Chapter{
+		{ID: "1", Number: "1", Language: "en"},
+		{ID: "2", Number: "2", Language: "en"},
+		{ID: "2es", Number: "2", Language: "es"},
+		{ID: "2.5", Number: "2.5", Language: "en"},
+		{ID: "10", Number: "10", Language: "en"},
+		{ID: "3a", Number: "3", Language: "en"},
+		{ID: "3b", Number: "3", Language: "en", Downloaded: true},
+	}
+	ids := func(chapters []*data.Chapter) string {
+		var ids []string
+		for _, ch := range chapters {
+			ids = append(ids, ch.ID)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	if got := ids(NextChapters(chapters, chapters[1], 3)); got != "2.5,3b,10" {
+		t.Errorf("NextChapters() = %s, want 2.5,3b,10", got)
+	}
+	if got := ids(NextChapters(chapters, chapters[4], 3)); got != "" {
+		t.Errorf("Expected nothing after the last chapter, got %s", got)
+	}
+	if got := ids(NextChapters(chapters, chapters[2], 3)); got != "" {
+		t.Errorf("Expected no chapter in another language, got %s", got)
+	}
+}
+
+func TestDownloadQueue_Prefetch(t *testing.T) {
+	manga := &data.Manga{ID: "m", Name: "Prefetch"}
+	chapters := []*data.Chapter{
+		{ID: "c1", Number: "1", Language: "en", Downloaded: true},
+		{ID: "c2", Number: "2", Language: "en", Downloaded: true},
+		{ID: "c3", Number: "3", Language: "en"},
+		{ID: "c4", Number: "4", Language: "en"},
+		{ID: "c5", Number: "5", Language: "en"},
+	}
+	repo := &mockRepository{
+		getChaptersFunc: func(mangaID string) ([]*data.Chapter, error) { return chapters, nil },
+	}
+	store := &memoryQueueStore{}
+	queue := NewDownloadQueue(repo, store, nil)
+
+	// Chapter 2 is downloaded already, only 3 and 4 need fetching
+	queued, err := queue.Prefetch(manga, chapters[0], 3)
+	if err != nil {
+		t.Fatalf("Prefetch() error = %v", err)
+	}
+	if len(queued) != 2 || queued[0].ID != "c3" || queued[1].ID != "c4" {
+		t.Errorf("Expected chapters 3 and 4 queued, got %v", queued)
+	}
+	if len(store.items) != 2 || store.items[0].ChapterID != "c3" {
+		t.Errorf("Expected 2 items in queue order, got %v", store.items)
+	}
+
+	// Nothing is queued when prefetching is off
+	if queued, err := queue.Prefetch(manga, chapters[2], 0); err != nil || len(queued) != 0 {
+		t.Errorf("Prefetch(0) = %v, %v, want nothing", queued, err)
+	}
+}
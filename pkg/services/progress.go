@@ -0,0 +1,188 @@
+package services
+
+import (
+	"sync"
+
+	"github.com/kerbaras/mangas/pkg/integrations"
+)
+
+// progressHub fans download progress out to any number of subscribers.
+//
+// Every subscriber has its own unbounded buffer, so a slow reader never
+// blocks the download or the other readers. Page updates of a chapter that
+// are still waiting to be delivered are coalesced into the most recent one,
+// while state transitions ("downloading", "processing", "complete", "error")
+// are always delivered, in the order they were published.
+type progressHub struct {
+	mu          sync.Mutex
+	subscribers map[*progressSubscriber]struct{}
+	closed      bool
+}
+
+func newProgressHub() *progressHub {
+	return &progressHub{subscribers: make(map[*progressSubscriber]struct{})}
+}
+
+// subscribe returns a channel receiving every update published from now on
+// and a function to stop receiving them. The channel is closed once the hub
+// is closed and the pending updates were delivered, or when unsubscribing.
+func (h *progressHub) subscribe() (<-chan DownloadProgress, func()) {
+	sub := &progressSubscriber{
+		out:  make(chan DownloadProgress),
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		close(sub.out)
+		return sub.out, func() {}
+	}
+	h.subscribers[sub] = struct{}{}
+	h.mu.Unlock()
+
+	go sub.run()
+
+	var once sync.Once
+	return sub.out, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers, sub)
+			h.mu.Unlock()
+			close(sub.done)
+		})
+	}
+}
+
+// publish queues an update for every subscriber without blocking
+func (h *progressHub) publish(progress DownloadProgress) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	for sub := range h.subscribers {
+		sub.push(progress)
+	}
+}
+
+// close stops accepting updates; subscribers are closed after draining
+func (h *progressHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for sub := range h.subscribers {
+		sub.finish()
+	}
+}
+
+type pendingProgress struct {
+	seq      uint64 // Changes when a newer page update replaces this one
+	progress DownloadProgress
+}
+
+type progressSubscriber struct {
+	out  chan DownloadProgress
+	wake chan struct{} // Signals new or replaced pending updates
+	done chan struct{} // Closed when unsubscribing
+
+	mu      sync.Mutex
+	pending []pendingProgress
+	seq     uint64
+	closing bool
+}
+
+// isPageUpdate reports whether progress only advances the page count of a
+// chapter being downloaded or staged into its EPUB, and can be replaced by a
+// later one
+func isPageUpdate(progress DownloadProgress) bool {
+	switch progress.Status {
+	case "downloading":
+		return progress.CurrentPage > 0
+	case "processing":
+		return progress.Stage == string(integrations.FinalizeStaging) && progress.CurrentPage > 0
+	}
+	return false
+}
+
+func (s *progressSubscriber) push(progress DownloadProgress) {
+	s.mu.Lock()
+	s.seq++
+	replaced := false
+	if isPageUpdate(progress) {
+		// Replace the latest pending update of the chapter if it is a page
+		// update too, so transitions keep their place in the order
+		for i := len(s.pending) - 1; i >= 0; i-- {
+			p := s.pending[i].progress
+			if p.MangaID != progress.MangaID || p.ChapterID != progress.ChapterID {
+				continue
+			}
+			if isPageUpdate(p) && p.Status == progress.Status {
+				s.pending[i] = pendingProgress{seq: s.seq, progress: progress}
+				replaced = true
+			}
+			break
+		}
+	}
+	if !replaced {
+		s.pending = append(s.pending, pendingProgress{seq: s.seq, progress: progress})
+	}
+	s.mu.Unlock()
+	s.notify()
+}
+
+func (s *progressSubscriber) finish() {
+	s.mu.Lock()
+	s.closing = true
+	s.mu.Unlock()
+	s.notify()
+}
+
+func (s *progressSubscriber) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers pending updates to the subscriber until it unsubscribes, or
+// the hub is closed and nothing is left to deliver
+func (s *progressSubscriber) run() {
+	defer close(s.out)
+	for {
+		s.mu.Lock()
+		if len(s.pending) == 0 {
+			closing := s.closing
+			s.mu.Unlock()
+			if closing {
+				return
+			}
+			select {
+			case <-s.wake:
+				continue
+			case <-s.done:
+				return
+			}
+		}
+		next := s.pending[0]
+		s.mu.Unlock()
+
+		select {
+		case s.out <- next.progress:
+			s.mu.Lock()
+			// A newer page update may have replaced the one just sent
+			if len(s.pending) > 0 && s.pending[0].seq == next.seq {
+				s.pending = s.pending[1:]
+			}
+			s.mu.Unlock()
+		case <-s.wake:
+			// The head may have been coalesced, send the latest version
+		case <-s.done:
+			return
+		}
+	}
+}
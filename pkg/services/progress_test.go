@@ -0,0 +1,108 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProgressHub_SlowSubscriber(t *testing.T) {
+	hub := newProgressHub()
+	updates, unsubscribe := hub.subscribe()
+	defer unsubscribe()
+
+	// Publish a whole chapter before the subscriber reads anything
+	hub.publish(DownloadProgress{ChapterID: "ch-1", Status: "downloading"})
+	for page := 1; page <= 500; page++ {
+		hub.publish(DownloadProgress{ChapterID: "ch-1", Status: "downloading", CurrentPage: page, TotalPages: 500})
+		hub.publish(DownloadProgress{ChapterID: "ch-2", Status: "downloading", CurrentPage: page, TotalPages: 500})
+	}
+	hub.publish(DownloadProgress{ChapterID: "ch-1", Status: "processing"})
+	for image := 1; image <= 500; image++ {
+		hub.publish(DownloadProgress{ChapterID: "ch-1", Status: "processing", Stage: "staging", CurrentPage: image, TotalPages: 500})
+	}
+	hub.publish(DownloadProgress{ChapterID: "ch-1", Status: "processing", Stage: "writing", CurrentPage: 500, TotalPages: 500})
+	hub.publish(DownloadProgress{ChapterID: "ch-1", Status: "complete"})
+	hub.publish(DownloadProgress{ChapterID: "ch-2", Status: "error"})
+	hub.close()
+
+	var received []DownloadProgress
+	for progress := range updates {
+		received = append(received, progress)
+	}
+
+	want := []DownloadProgress{
+		{ChapterID: "ch-1", Status: "downloading"},
+		{ChapterID: "ch-1", Status: "downloading", CurrentPage: 500, TotalPages: 500},
+		{ChapterID: "ch-2", Status: "downloading", CurrentPage: 500, TotalPages: 500},
+		{ChapterID: "ch-1", Status: "processing"},
+		{ChapterID: "ch-1", Status: "processing", Stage: "staging", CurrentPage: 500, TotalPages: 500},
+		{ChapterID: "ch-1", Status: "processing", Stage: "writing", CurrentPage: 500, TotalPages: 500},
+		{ChapterID: "ch-1", Status: "complete"},
+		{ChapterID: "ch-2", Status: "error"},
+	}
+	if len(received) != len(want) {
+		t.Fatalf("received %d updates, want %d: %+v", len(received), len(want), received)
+	}
+	for i := range want {
+		if received[i] != want[i] {
+			t.Errorf("update %d = %+v, want %+v", i, received[i], want[i])
+		}
+	}
+}
+
+func TestProgressHub_Subscribers(t *testing.T) {
+	hub := newProgressHub()
+	first, unsubscribeFirst := hub.subscribe()
+	second, unsubscribeSecond := hub.subscribe()
+	defer unsubscribeSecond()
+
+	// An unsubscribed reader is closed and doesn't hold back the others
+	unsubscribeFirst()
+	if _, ok := <-first; ok {
+		t.Error("unsubscribed channel should be closed")
+	}
+
+	hub.publish(DownloadProgress{ChapterID: "ch-1", Status: "complete"})
+	select {
+	case progress := <-second:
+		if progress.Status != "complete" {
+			t.Errorf("Status = %q, want complete", progress.Status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for progress")
+	}
+
+	hub.close()
+	if _, ok := <-second; ok {
+		t.Error("channel should be closed after the hub is closed")
+	}
+
+	late, _ := hub.subscribe()
+	if _, ok := <-late; ok {
+		t.Error("subscribing to a closed hub should return a closed channel")
+	}
+}
+
+func TestDownloader_ProgressChannelSubscribedOnUse(t *testing.T) {
+	d := NewDownloader(nil, &mockRepository{}, t.TempDir())
+	defer d.Close()
+	subscribers := func() int {
+		d.progress.mu.Lock()
+		defer d.progress.mu.Unlock()
+		return len(d.progress.subscribers)
+	}
+
+	// Updates nobody reads are not buffered
+	if n := subscribers(); n != 0 {
+		t.Fatalf("Expected no subscriber before the channel is used, got %d", n)
+	}
+	if d.GetProgressChannel() != d.GetProgressChannel() || subscribers() != 1 {
+		t.Errorf("Expected a single shared subscription, got %d subscribers", subscribers())
+	}
+
+	_, unsubscribe := d.SubscribeProgress()
+	unsubscribe()
+	if n := subscribers(); n != 1 {
+		t.Errorf("Expected unsubscribed readers to be dropped, got %d subscribers", n)
+	}
+}
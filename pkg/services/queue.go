@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+// QueueStore persists the download queue
+type QueueStore interface {
+	EnqueueChapter(mangaID, chapterID string) error
+	ListQueue() ([]*data.QueueItem, error)
+	NextQueueItem() (*data.QueueItem, error)
+	UpdateQueueItem(chapterID, status, errMsg string) error
+	UpdateQueueStatuses(mangaID, to string, from ...string) (int64, error)
+	MoveQueueItem(chapterID string, position int) error
+	ClearQueue(statuses ...string) (int64, error)
+}
+
+// QueueEntry is a queue item together with its manga and chapter
+type QueueEntry struct {
+	*data.QueueItem
+	Manga   *data.Manga
+	Chapter *data.Chapter
+}
+
+// DownloadQueue processes queued chapters one at a time with a Downloader,
+// the chapters of a manga bundled by volume together with the rest of their
+// volume still queued. The queue lives in the store, so it survives
+// restarts. Its methods are safe for concurrent use, only one Run processes
+// the queue at a time.
+type DownloadQueue struct {
+	repo       Repository
+	store      QueueStore
+	downloader *Downloader
+
+	mu      sync.Mutex
+	running bool
+	stop    context.CancelFunc // Cancels the running Run
+}
+
+// NewDownloadQueue creates a queue that downloads with downloader
+func NewDownloadQueue(repo Repository, store QueueStore, downloader *Downloader) *DownloadQueue {
+	return &DownloadQueue{
+		repo:       repo,
+		store:      store,
+		downloader: downloader,
+	}
+}
+
+// Add queues chapters of a manga in the given order
+func (q *DownloadQueue) Add(manga *data.Manga, chapters ...*data.Chapter) error {
+	for _, chapter := range chapters {
+		if err := q.store.EnqueueChapter(manga.ID, chapter.ID); err != nil {
+			return fmt.Errorf("failed to queue chapter %s: %w", chapter.Number, err)
+		}
+	}
+	return nil
+}
+
+// List returns the queue in processing order. Items whose manga or chapter
+// left the library are returned without them.
+func (q *DownloadQueue) List() ([]*QueueEntry, error) {
+	items, err := q.store.ListQueue()
+	if err != nil {
+		return nil, err
+	}
+
+	mangas := make(map[string]*data.Manga)
+	chapters := make(map[string]*data.Chapter)
+	entries := make([]*QueueEntry, 0, len(items))
+	for _, item := range items {
+		if _, ok := mangas[item.MangaID]; !ok {
+			manga, _ := q.repo.GetManga(item.MangaID)
+			mangas[item.MangaID] = manga
+			mangaChapters, _ := q.repo.GetChapters(item.MangaID)
+			for _, ch := range mangaChapters {
+				chapters[ch.ID] = ch
+			}
+		}
+		entries = append(entries, &QueueEntry{
+			QueueItem: item,
+			Manga:     mangas[item.MangaID],
+			Chapter:   chapters[item.ChapterID],
+		})
+	}
+	return entries, nil
+}
+
+// Pause holds the waiting chapters of a manga, or of the whole queue when
+// mangaID is empty. A chapter already downloading is allowed to finish.
+func (q *DownloadQueue) Pause(mangaID string) (int64, error) {
+	return q.store.UpdateQueueStatuses(mangaID, data.QueuePaused, data.QueueQueued)
+}
+
+// Resume queues paused and failed chapters again
+func (q *DownloadQueue) Resume(mangaID string) (int64, error) {
+	return q.store.UpdateQueueStatuses(mangaID, data.QueueQueued, data.QueuePaused, data.QueueFailed)
+}
+
+// Move puts a chapter at a 1-based position in the queue
+func (q *DownloadQueue) Move(chapterID string, position int) error {
+	return q.store.MoveQueueItem(chapterID, position)
+}
+
+// Clear removes the items in the given statuses, or the whole queue
+func (q *DownloadQueue) Clear(statuses ...string) (int64, error) {
+	return q.store.ClearQueue(statuses...)
+}
+
+// Running reports whether Run is processing the queue
+func (q *DownloadQueue) Running() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.running
+}
+
+// Stop ends the running Run, if any, once the chapter downloading finishes.
+// The chapters left stay queued.
+func (q *DownloadQueue) Stop() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.stop != nil {
+		q.stop()
+	}
+}
+
+// Run downloads queued chapters until none are left or ctx is cancelled.
+// Chapters left active by an interrupted run are picked up again first.
+// A failing chapter is marked failed and does not stop the queue.
+func (q *DownloadQueue) Run(ctx context.Context) error {
+	q.mu.Lock()
+	if q.running {
+		q.mu.Unlock()
+		return fmt.Errorf("download queue is already running")
+	}
+	ctx, stop := context.WithCancel(ctx)
+	q.running, q.stop = true, stop
+	q.mu.Unlock()
+
+	defer func() {
+		q.mu.Lock()
+		q.running, q.stop = false, nil
+		q.mu.Unlock()
+		stop()
+	}()
+
+	if _, err := q.store.UpdateQueueStatuses("", data.QueueQueued, data.QueueActive); err != nil {
+		return fmt.Errorf("failed to recover interrupted downloads: %w", err)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		item, err := q.store.NextQueueItem()
+		if err != nil {
+			return fmt.Errorf("failed to read queue: %w", err)
+		}
+		if item == nil {
+			return nil
+		}
+
+		if err := q.store.UpdateQueueItem(item.ChapterID, data.QueueActive, ""); err != nil {
+			return fmt.Errorf("failed to update queue: %w", err)
+		}
+
+		status, errMsg := data.QueueDone, ""
+		if err := q.process(item); errors.Is(err, context.Canceled) {
+			status = data.QueueQueued // Interrupted, downloaded by the next run
+		} else if err != nil {
+			status, errMsg = data.QueueFailed, err.Error()
+		}
+		if err := q.store.UpdateQueueItem(item.ChapterID, status, errMsg); err != nil {
+			return fmt.Errorf("failed to update queue: %w", err)
+		}
+	}
+}
+
+// process downloads the chapter of a queue item
+func (q *DownloadQueue) process(item *data.QueueItem) error {
+	manga, err := q.repo.GetManga(item.MangaID)
+	if err != nil || manga == nil {
+		return fmt.Errorf("manga %s is not in the library", item.MangaID)
+	}
+
+	chapters, err := q.repo.GetChapters(item.MangaID)
+	if err != nil {
+		return fmt.Errorf("failed to get chapters: %w", err)
+	}
+	for _, chapter := range chapters {
+		if chapter.ID != item.ChapterID {
+			continue
+		}
+		if q.downloader.bundleMode(manga) == BundleVolume && hasVolume(chapter) {
+			return q.processVolume(manga, chapter, chapters)
+		}
+		if err := q.downloader.DownloadChapter(manga, chapter); err != nil {
+			return err
+		}
+		q.downloader.enforceQuota([]*data.Chapter{chapter})
+		return nil
+	}
+	return fmt.Errorf("chapter %s is not in the library", item.ChapterID)
+}
+
+// processVolume downloads the volume of chapter, of a manga bundled by
+// volume, together with the chapters of the volume still queued, which are
+// then done too
+func (q *DownloadQueue) processVolume(manga *data.Manga, chapter *data.Chapter, chapters []*data.Chapter) error {
+	items, err := q.store.ListQueue()
+	if err != nil {
+		return fmt.Errorf("failed to read queue: %w", err)
+	}
+	queued := make(map[string]bool)
+	for _, item := range items {
+		if item.MangaID == manga.ID && item.Status == data.QueueQueued {
+			queued[item.ChapterID] = true
+		}
+	}
+
+	volume := []*data.Chapter{chapter}
+	var others []*data.Chapter
+	for _, other := range chapters {
+		if other.ID != chapter.ID && other.Volume == chapter.Volume && queued[other.ID] {
+			volume = append(volume, other)
+			others = append(others, other)
+		}
+	}
+	if err := q.downloader.DownloadVolume(manga, chapter.Volume, volume); err != nil {
+		return err
+	}
+	q.downloader.enforceQuota(volume)
+	for _, other := range others {
+		if err := q.store.UpdateQueueItem(other.ID, data.QueueDone, ""); err != nil {
+			return fmt.Errorf("failed to update queue: %w", err)
+		}
+	}
+	return nil
+}
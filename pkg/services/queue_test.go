@@ -0,0 +1,328 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+// memoryQueueStore is an in-memory QueueStore
+type memoryQueueStore struct {
+	items []*data.QueueItem
+}
+
+func (m *memoryQueueStore) find(chapterID string) *data.QueueItem {
+	for _, item := range m.items {
+		if item.ChapterID == chapterID {
+			return item
+		}
+	}
+	return nil
+}
+
+func (m *memoryQueueStore) EnqueueChapter(mangaID, chapterID string) error {
+	if m.find(chapterID) == nil {
+		m.items = append(m.items, &data.QueueItem{ChapterID: chapterID, MangaID: mangaID, Position: len(m.items) + 1, Status: data.QueueQueued})
+	}
+	return nil
+}
+
+func (m *memoryQueueStore) ListQueue() ([]*data.QueueItem, error) {
+	sort.SliceStable(m.items, func(i, j int) bool { return m.items[i].Position < m.items[j].Position })
+	return m.items, nil
+}
+
+func (m *memoryQueueStore) NextQueueItem() (*data.QueueItem, error) {
+	items, _ := m.ListQueue()
+	for _, item := range items {
+		if item.Status == data.QueueQueued {
+			return item, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryQueueStore) UpdateQueueItem(chapterID, status, errMsg string) error {
+	item := m.find(chapterID)
+	if item == nil {
+		return fmt.Errorf("not queued")
+	}
+	item.Status, item.Error = status, errMsg
+	return nil
+}
+
+func (m *memoryQueueStore) UpdateQueueStatuses(mangaID, to string, from ...string) (int64, error) {
+	var changed int64
+	for _, item := range m.items {
+		for _, status := range from {
+			if item.Status == status && (mangaID == "" || item.MangaID == mangaID) {
+				item.Status = to
+				changed++
+				break
+			}
+		}
+	}
+	return changed, nil
+}
+
+func (m *memoryQueueStore) MoveQueueItem(chapterID string, position int) error {
+	item := m.find(chapterID)
+	if item == nil {
+		return fmt.Errorf("not queued")
+	}
+	for _, other := range m.items {
+		if other != item && other.Position >= position {
+			other.Position++
+		}
+	}
+	item.Position = position
+	return nil
+}
+
+func (m *memoryQueueStore) ClearQueue(statuses ...string) (int64, error) {
+	var kept []*data.QueueItem
+	for _, item := range m.items {
+		keep := len(statuses) > 0
+		for _, status := range statuses {
+			if item.Status == status {
+				keep = false
+			}
+		}
+		if keep {
+			kept = append(kept, item)
+		}
+	}
+	removed := int64(len(m.items) - len(kept))
+	m.items = kept
+	return removed, nil
+}
+
+func TestDownloadQueue_Run(t *testing.T) {
+	pngData := createTestPNG()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngData)
+	}))
+	defer server.Close()
+
+	manga := &data.Manga{ID: "manga-1", Name: "Test Manga"}
+	chapters := []*data.Chapter{
+		{ID: "ch-1", MangaID: "manga-1", Number: "1"},
+		{ID: "ch-2", MangaID: "manga-1", Number: "2"},
+		{ID: "ch-3", MangaID: "manga-1", Number: "3"},
+		{ID: "ch-4", MangaID: "manga-1", Number: "4"},
+	}
+
+	var order []string
+	source := &mockSource{
+		getPagesFunc: func(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+			order = append(order, chapter.ID)
+			if chapter.ID == "ch-2" {
+				return nil, fmt.Errorf("chapter removed")
+			}
+			return []string{server.URL + "/page.png"}, nil
+		},
+	}
+	repo := &mockRepository{
+		getMangaFunc: func(id string) (*data.Manga, error) { return manga, nil },
+		getChaptersFunc: func(mangaID string) ([]*data.Chapter, error) {
+			return chapters, nil
+		},
+	}
+
+	downloader := NewDownloader(source, repo, t.TempDir())
+	defer downloader.Close()
+
+	store := &memoryQueueStore{}
+	queue := NewDownloadQueue(repo, store, downloader)
+	if err := queue.Add(manga, chapters...); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	// An interrupted download is retried, a paused one is left alone and
+	// moved chapters are processed first
+	store.UpdateQueueItem("ch-1", data.QueueActive, "")
+	store.UpdateQueueItem("ch-4", data.QueuePaused, "")
+	if err := queue.Move("ch-3", 1); err != nil {
+		t.Fatalf("Move() error = %v", err)
+	}
+
+	if err := queue.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if fmt.Sprint(order) != "[ch-3 ch-1 ch-2]" {
+		t.Errorf("processing order = %v, want [ch-3 ch-1 ch-2]", order)
+	}
+
+	want := map[string]string{
+		"ch-1": data.QueueDone,
+		"ch-2": data.QueueFailed,
+		"ch-3": data.QueueDone,
+		"ch-4": data.QueuePaused,
+	}
+	entries, err := queue.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	for _, entry := range entries {
+		if entry.Status != want[entry.ChapterID] {
+			t.Errorf("%s status = %s, want %s", entry.ChapterID, entry.Status, want[entry.ChapterID])
+		}
+		if entry.Manga != manga || entry.Chapter == nil {
+			t.Errorf("%s entry is missing its manga or chapter", entry.ChapterID)
+		}
+	}
+	if entries[2].Error == "" {
+		t.Error("failed chapter should record its error")
+	}
+
+	// Resuming queues paused and failed chapters again
+	if resumed, _ := queue.Resume(""); resumed != 2 {
+		t.Errorf("Resume() = %d, want 2", resumed)
+	}
+	if cleared, _ := queue.Clear(data.QueueDone); cleared != 2 {
+		t.Errorf("Clear() = %d, want 2", cleared)
+	}
+}
+
+func TestDownloadQueue_RunCancelled(t *testing.T) {
+	store := &memoryQueueStore{}
+	store.EnqueueChapter("manga-1", "ch-1")
+
+	downloader := NewDownloader(&mockSource{}, &mockRepository{}, t.TempDir())
+	defer downloader.Close()
+	queue := NewDownloadQueue(&mockRepository{}, store, downloader)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := queue.Run(ctx); err != context.Canceled {
+		t.Errorf("Run() error = %v, want context.Canceled", err)
+	}
+	if store.items[0].Status != data.QueueQueued {
+		t.Errorf("cancelled run should leave the chapter queued, got %s", store.items[0].Status)
+	}
+}
+
+func TestDownloadQueue_Stop(t *testing.T) {
+	pngData := createTestPNG()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngData)
+	}))
+	defer server.Close()
+
+	manga := &data.Manga{ID: "manga-1", Name: "Test Manga"}
+	chapters := []*data.Chapter{
+		{ID: "ch-1", MangaID: "manga-1", Number: "1"},
+		{ID: "ch-2", MangaID: "manga-1", Number: "2"},
+	}
+	repo := &mockRepository{
+		getMangaFunc:    func(id string) (*data.Manga, error) { return manga, nil },
+		getChaptersFunc: func(mangaID string) ([]*data.Chapter, error) { return chapters, nil },
+	}
+
+	var queue *DownloadQueue
+	source := &mockSource{
+		getPagesFunc: func(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+			queue.Stop() // The chapter downloading still finishes
+			return []string{server.URL + "/page.png"}, nil
+		},
+	}
+	downloader := NewDownloader(source, repo, t.TempDir())
+	defer downloader.Close()
+
+	store := &memoryQueueStore{}
+	queue = NewDownloadQueue(repo, store, downloader)
+	queue.Add(manga, chapters...)
+
+	if err := queue.Run(context.Background()); err != context.Canceled {
+		t.Errorf("Run() error = %v, want context.Canceled", err)
+	}
+	if store.items[0].Status != data.QueueDone || store.items[1].Status != data.QueueQueued {
+		t.Errorf("statuses = %s, %s, want done, queued", store.items[0].Status, store.items[1].Status)
+	}
+	if queue.Running() {
+		t.Error("queue should not be running once stopped")
+	}
+}
+
+func TestDownloadQueue_RunFollowsMangaSettings(t *testing.T) {
+	pngData := createTestPNG()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngData)
+	}))
+	defer server.Close()
+
+	cbzManga := &data.Manga{ID: "cbz", Name: "CBZ Manga"}
+	volumeManga := &data.Manga{ID: "volume", Name: "Volume Manga"}
+	chapters := map[string][]*data.Chapter{
+		"cbz": {{ID: "c-1", MangaID: "cbz", Number: "1"}},
+		"volume": {
+			{ID: "v-1", MangaID: "volume", Number: "1", Volume: "1"},
+			{ID: "v-2", MangaID: "volume", Number: "2", Volume: "1"},
+			{ID: "v-3", MangaID: "volume", Number: "3", Volume: "2"},
+		},
+	}
+	var downloaded []string
+	source := &mockSource{
+		getPagesFunc: func(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+			downloaded = append(downloaded, chapter.ID)
+			return []string{server.URL + "/page.png"}, nil
+		},
+	}
+	paths := make(map[string]string)
+	repo := &mockRepository{
+		getMangaFunc: func(id string) (*data.Manga, error) {
+			if id == "cbz" {
+				return cbzManga, nil
+			}
+			return volumeManga, nil
+		},
+		getChaptersFunc: func(mangaID string) ([]*data.Chapter, error) {
+			return chapters[mangaID], nil
+		},
+		updateChapterStatusFunc: func(chapterID string, downloaded bool, filePath string) error {
+			paths[chapterID] = filePath
+			return nil
+		},
+	}
+
+	downloader := NewDownloader(source, repo, t.TempDir())
+	defer downloader.Close()
+	downloader.SetMangaSettings(memorySettings{
+		"cbz":    {MangaID: "cbz", Format: BatchCBZ},
+		"volume": {MangaID: "volume", BundleMode: string(BundleVolume)},
+	})
+
+	store := &memoryQueueStore{}
+	queue := NewDownloadQueue(repo, store, downloader)
+	queue.Add(cbzManga, chapters["cbz"]...)
+	queue.Add(volumeManga, chapters["volume"]...)
+	if err := queue.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if filepath.Ext(paths["c-1"]) != ".cbz" {
+		t.Errorf("Chapter of the cbz manga written to %q, want a CBZ", paths["c-1"])
+	}
+	if paths["v-1"] == "" || paths["v-1"] != paths["v-2"] || paths["v-3"] == paths["v-1"] {
+		t.Errorf("Chapters written to %v, want volume 1 in one book", paths)
+	}
+	// Each chapter is downloaded once, with the rest of its volume
+	if len(downloaded) != 4 {
+		t.Errorf("Downloaded %v, want every chapter once", downloaded)
+	}
+	for _, item := range store.items {
+		if item.Status != data.QueueDone {
+			t.Errorf("%s status = %s, want done", item.ChapterID, item.Status)
+		}
+	}
+}
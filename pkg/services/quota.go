@@ -0,0 +1,314 @@
+package services
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/log"
+)
+
+// EvictionPolicy picks the chapters removed when the downloads exceed the
+// storage quota
+type EvictionPolicy string
+
+const (
+	EvictOldestRead EvictionPolicy = "oldest-read" // Read chapters, read longest ago first; unread ones are kept
+	EvictLargest    EvictionPolicy = "largest"     // Any chapter, largest file first
+)
+
+// ParseEvictionPolicy parses an eviction policy name
+func ParseEvictionPolicy(name string) (EvictionPolicy, error) {
+	switch policy := EvictionPolicy(strings.ToLower(name)); policy {
+	case EvictOldestRead, EvictLargest:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown eviction policy %q (use oldest-read or largest)", name)
+}
+
+// FavoritesCollection is the collection whose mangas are never evicted
+const FavoritesCollection = "Favorites"
+
+// State keys the quota is kept under
+const (
+	quotaLimitKey  = "storage_quota"
+	quotaPolicyKey = "storage_quota_policy"
+)
+
+// Quota limits the size of the download directory
+type Quota struct {
+	Limit  int64 // Bytes, 0 for no quota
+	Policy EvictionPolicy
+}
+
+// QuotaStore is the library access needed to enforce a quota
+type QuotaStore interface {
+	ListMangas() ([]*data.Manga, error)
+	GetChapters(mangaID string) ([]*data.Chapter, error)
+	GetCollection(collection string) ([]*data.Manga, error)
+	UpdateChapterStatus(chapterID string, downloaded bool, filePath string) error
+	DeleteChapterChecksum(chapterID string) error
+	GetState(key string) (string, error)
+	SetState(key, value string) error
+}
+
+// LoadQuota returns the quota saved with SaveQuota, a zero Limit when none
+func LoadQuota(store QuotaStore) (Quota, error) {
+	limit, err := store.GetState(quotaLimitKey)
+	if err != nil || limit == "" {
+		return Quota{}, err
+	}
+	quota := Quota{Policy: EvictOldestRead}
+	if quota.Limit, err = strconv.ParseInt(limit, 10, 64); err != nil {
+		return Quota{}, fmt.Errorf("invalid storage quota %q: %w", limit, err)
+	}
+	if policy, err := store.GetState(quotaPolicyKey); err != nil {
+		return Quota{}, err
+	} else if policy != "" {
+		if quota.Policy, err = ParseEvictionPolicy(policy); err != nil {
+			return Quota{}, err
+		}
+	}
+	return quota, nil
+}
+
+// SaveQuota keeps the quota for the next runs, a zero Limit turns it off
+func SaveQuota(store QuotaStore, quota Quota) error {
+	if quota.Limit <= 0 {
+		return store.SetState(quotaLimitKey, "")
+	}
+	if err := store.SetState(quotaLimitKey, strconv.FormatInt(quota.Limit, 10)); err != nil {
+		return err
+	}
+	return store.SetState(quotaPolicyKey, string(quota.Policy))
+}
+
+// ParseSize parses a size such as "500MB", "20 GB" or "1.5T", with binary
+// units like FormatBytes. A plain number is in bytes.
+func ParseSize(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "IB"), "B")
+	multiplier := int64(1)
+	if n := len(s); n > 0 {
+		if exp := strings.IndexByte("KMGT", s[n-1]); exp >= 0 {
+			multiplier = int64(1) << (10 * (exp + 1))
+			s = s[:n-1]
+		}
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q (e.g. 500MB or 20GB)", size)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// EvictedFile is a downloaded file removed to get under the quota, with the
+// chapters it held
+type EvictedFile struct {
+	Manga    *data.Manga
+	Chapters []*data.Chapter
+	Path     string
+	Size     int64
+}
+
+// EvictionReport tells what enforcing a quota removed
+type EvictionReport struct {
+	Usage   int64 // Size of the download directory before eviction
+	Limit   int64
+	Freed   int64
+	Evicted []EvictedFile
+}
+
+// evicted returns the files evicted, none for a nil report
+func (r *EvictionReport) evicted() []EvictedFile {
+	if r == nil {
+		return nil
+	}
+	return r.Evicted
+}
+
+// Over reports whether the downloads are still over the quota after
+// eviction, when no more chapters could be evicted by the policy
+func (r *EvictionReport) Over() bool {
+	return r.Limit > 0 && r.Usage-r.Freed > r.Limit
+}
+
+// DirSize returns the size of the regular files under dir, 0 when it
+// doesn't exist
+func DirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if entry.Type().IsRegular() {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// EnforceQuota removes downloaded chapters of dir by the quota's policy
+// until the directory fits in the quota, marking them as not downloaded.
+// Mangas in the Favorites collection are never evicted. With dryRun, the
+// report lists what would be removed and nothing is touched.
+func EnforceQuota(store QuotaStore, dir string, quota Quota, dryRun bool) (*EvictionReport, error) {
+	return enforceQuota(store, dir, quota, dryRun, nil)
+}
+
+// enforceQuota is EnforceQuota never evicting the files in keep, such as
+// the ones just downloaded
+func enforceQuota(store QuotaStore, dir string, quota Quota, dryRun bool, keep map[string]bool) (*EvictionReport, error) {
+	usage, err := DirSize(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to measure %s: %w", dir, err)
+	}
+	report := &EvictionReport{Usage: usage, Limit: quota.Limit}
+	if quota.Limit <= 0 || usage <= quota.Limit {
+		return report, nil
+	}
+
+	candidates, err := evictionCandidates(store, quota.Policy, keep)
+	if err != nil {
+		return nil, err
+	}
+	for _, candidate := range candidates {
+		if usage-report.Freed <= quota.Limit {
+			break
+		}
+		if !dryRun {
+			if err := os.Remove(candidate.Path); err != nil && !os.IsNotExist(err) {
+				return report, fmt.Errorf("failed to remove %s: %w", candidate.Path, err)
+			}
+			for _, chapter := range candidate.Chapters {
+				if err := store.UpdateChapterStatus(chapter.ID, false, ""); err != nil {
+					return report, err
+				}
+				if err := store.DeleteChapterChecksum(chapter.ID); err != nil {
+					return report, err
+				}
+			}
+		}
+		report.Freed += candidate.Size
+		report.Evicted = append(report.Evicted, candidate)
+	}
+	return report, nil
+}
+
+// evictionCandidates lists the downloaded files that may be evicted, in the
+// order the policy removes them, the files in keep left out. The chapters
+// of a volume share a file, it is evicted when all of them can be.
+func evictionCandidates(store QuotaStore, policy EvictionPolicy, keep map[string]bool) ([]EvictedFile, error) {
+	favorites, err := store.GetCollection(FavoritesCollection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get favorites: %w", err)
+	}
+	protected := make(map[string]bool, len(favorites))
+	for _, manga := range favorites {
+		protected[manga.ID] = true
+	}
+
+	mangas, err := store.ListMangas()
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]*EvictedFile)
+	lastRead := make(map[string]time.Time) // Latest read of the chapters of a file
+	unread := make(map[string]bool)
+	var paths []string
+	for _, manga := range mangas {
+		if protected[manga.ID] {
+			continue
+		}
+		chapters, err := store.GetChapters(manga.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get chapters of %s: %w", manga.Name, err)
+		}
+		for _, chapter := range chapters {
+			if !chapter.Downloaded || chapter.FilePath == "" {
+				continue
+			}
+			file, ok := files[chapter.FilePath]
+			if !ok {
+				info, err := os.Stat(chapter.FilePath)
+				if err != nil || !info.Mode().IsRegular() {
+					continue // 'mangas repair' resets those
+				}
+				file = &EvictedFile{Manga: manga, Path: chapter.FilePath, Size: info.Size()}
+				files[chapter.FilePath] = file
+				paths = append(paths, chapter.FilePath)
+			}
+			file.Chapters = append(file.Chapters, chapter)
+			if !chapter.Read {
+				unread[chapter.FilePath] = true
+			}
+			if chapter.ReadAt.After(lastRead[chapter.FilePath]) {
+				lastRead[chapter.FilePath] = chapter.ReadAt
+			}
+		}
+	}
+
+	var candidates []EvictedFile
+	for _, path := range paths {
+		if keep[path] || policy == EvictOldestRead && unread[path] {
+			continue
+		}
+		candidates = append(candidates, *files[path])
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if policy == EvictLargest {
+			return candidates[i].Size > candidates[j].Size
+		}
+		return lastRead[candidates[i].Path].Before(lastRead[candidates[j].Path])
+	})
+	return candidates, nil
+}
+
+// enforceQuota applies the storage quota of SetQuotaStore after
+// chapters were downloaded, never evicting their books
+func (d *Downloader) enforceQuota(downloaded []*data.Chapter) {
+	settings := d.settings()
+	if settings.quota == nil {
+		return
+	}
+	d.quotaMu.Lock()
+	defer d.quotaMu.Unlock()
+
+	quota, err := LoadQuota(settings.quota)
+	if err == nil && quota.Limit == 0 {
+		return
+	}
+	keep := make(map[string]bool, len(downloaded))
+	for _, chapter := range downloaded {
+		if chapter.Downloaded && chapter.FilePath != "" {
+			keep[chapter.FilePath] = true
+		}
+	}
+	var report *EvictionReport
+	if err == nil {
+		report, err = enforceQuota(settings.quota, d.downloadDir, quota, false, keep)
+	}
+	if err != nil {
+		log.Warn("storage quota not enforced", "err", err)
+	}
+	for _, file := range report.evicted() {
+		log.Info("evicted to get under the storage quota", "manga_id", file.Manga.ID, "path", file.Path, "size", file.Size)
+	}
+	if settings.onEvict != nil && (err != nil || len(report.Evicted) > 0 || report.Over()) {
+		settings.onEvict(report, err)
+	}
+}
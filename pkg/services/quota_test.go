@@ -0,0 +1,286 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+// mockQuotaStore is a library of mangas and chapters kept in memory
+type mockQuotaStore struct {
+	mangas    []*data.Manga
+	chapters  map[string][]*data.Chapter
+	favorites []*data.Manga
+	state     map[string]string
+	checksums map[string]bool // Chapters with a checksum
+}
+
+func (m *mockQuotaStore) ListMangas() ([]*data.Manga, error) { return m.mangas, nil }
+
+func (m *mockQuotaStore) GetChapters(mangaID string) ([]*data.Chapter, error) {
+	return m.chapters[mangaID], nil
+}
+
+func (m *mockQuotaStore) GetCollection(collection string) ([]*data.Manga, error) {
+	if strings.EqualFold(collection, FavoritesCollection) {
+		return m.favorites, nil
+	}
+	return nil, nil
+}
+
+func (m *mockQuotaStore) UpdateChapterStatus(chapterID string, downloaded bool, filePath string) error {
+	for _, chapters := range m.chapters {
+		for _, chapter := range chapters {
+			if chapter.ID == chapterID {
+				chapter.Downloaded, chapter.FilePath = downloaded, filePath
+			}
+		}
+	}
+	return nil
+}
+
+func (m *mockQuotaStore) DeleteChapterChecksum(chapterID string) error {
+	delete(m.checksums, chapterID)
+	return nil
+}
+
+func (m *mockQuotaStore) GetState(key string) (string, error) { return m.state[key], nil }
+
+func (m *mockQuotaStore) SetState(key, value string) error {
+	m.state[key] = value
+	return nil
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{
+		"512":    512,
+		"10KB":   10 << 10,
+		"500MB":  500 << 20,
+		"20 GB":  20 << 30,
+		"1.5t":   3 << 39,
+		"2GiB":   2 << 30,
+		"100 mb": 100 << 20,
+	}
+	for size, want := range tests {
+		if got, err := ParseSize(size); err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", size, got, err, want)
+		}
+	}
+	for _, size := range []string{"", "GB", "-1GB", "lots"} {
+		if _, err := ParseSize(size); err == nil {
+			t.Errorf("ParseSize(%q) should fail", size)
+		}
+	}
+}
+
+func TestQuota_SaveAndLoad(t *testing.T) {
+	store := &mockQuotaStore{state: make(map[string]string)}
+
+	if quota, err := LoadQuota(store); err != nil || quota.Limit != 0 {
+		t.Fatalf("LoadQuota() = %+v, %v, want no quota", quota, err)
+	}
+
+	want := Quota{Limit: 20 << 30, Policy: EvictLargest}
+	if err := SaveQuota(store, want); err != nil {
+		t.Fatalf("SaveQuota() error = %v", err)
+	}
+	if got, err := LoadQuota(store); err != nil || got != want {
+		t.Errorf("LoadQuota() = %+v, %v, want %+v", got, err, want)
+	}
+
+	if err := SaveQuota(store, Quota{}); err != nil {
+		t.Fatalf("SaveQuota() error = %v", err)
+	}
+	if got, _ := LoadQuota(store); got.Limit != 0 {
+		t.Errorf("Expected the quota to be off, got %+v", got)
+	}
+}
+
+func TestEnforceQuota(t *testing.T) {
+	// Three mangas with 100, 300 and 200 byte chapters, the last a favorite
+	setup := func(t *testing.T) (*mockQuotaStore, string) {
+		dir := t.TempDir()
+		file := func(name string, size int) string {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+				t.Fatal(err)
+			}
+			return path
+		}
+		now := time.Now()
+		store := &mockQuotaStore{
+			mangas: []*data.Manga{{ID: "m1", Name: "One"}, {ID: "m2", Name: "Two"}, {ID: "m3", Name: "Three"}},
+			chapters: map[string][]*data.Chapter{
+				"m1": {
+					{ID: "c1", Number: "1", Downloaded: true, FilePath: file("one_1.epub", 100), Read: true, ReadAt: now.Add(-time.Hour)},
+					{ID: "c2", Number: "2", Downloaded: true, FilePath: file("one_2.epub", 100)},
+				},
+				"m2": {
+					{ID: "c3", Number: "1", Downloaded: true, FilePath: file("two_1.epub", 300), Read: true, ReadAt: now.Add(-2 * time.Hour)},
+				},
+				"m3": {
+					{ID: "c4", Number: "1", Downloaded: true, FilePath: file("three_1.epub", 200), Read: true, ReadAt: now.Add(-3 * time.Hour)},
+				},
+			},
+			favorites: []*data.Manga{{ID: "m3"}},
+			state:     make(map[string]string),
+			checksums: map[string]bool{"c1": true, "c2": true, "c3": true, "c4": true},
+		}
+		return store, dir
+	}
+	evicted := func(report *EvictionReport) []string {
+		var ids []string
+		for _, file := range report.Evicted {
+			for _, chapter := range file.Chapters {
+				ids = append(ids, chapter.ID)
+			}
+		}
+		return ids
+	}
+
+	t.Run("under quota", func(t *testing.T) {
+		store, dir := setup(t)
+		report, err := EnforceQuota(store, dir, Quota{Limit: 1000, Policy: EvictLargest}, false)
+		if err != nil {
+			t.Fatalf("EnforceQuota() error = %v", err)
+		}
+		if report.Usage != 700 || len(report.Evicted) != 0 {
+			t.Errorf("Expected 700 bytes used and nothing evicted, got %+v", report)
+		}
+	})
+
+	t.Run("oldest read first", func(t *testing.T) {
+		store, dir := setup(t)
+		report, err := EnforceQuota(store, dir, Quota{Limit: 350, Policy: EvictOldestRead}, false)
+		if err != nil {
+			t.Fatalf("EnforceQuota() error = %v", err)
+		}
+		// c4 is a favorite and c2 unread, so c3 then c1 go
+		if got := evicted(report); strings.Join(got, ",") != "c3,c1" {
+			t.Errorf("Expected c3 and c1 evicted, got %v", got)
+		}
+		if report.Freed != 400 || report.Over() {
+			t.Errorf("Expected 400 bytes freed, got %+v", report)
+		}
+		c1 := store.chapters["m1"][0]
+		if c1.Downloaded || c1.FilePath != "" || store.checksums["c1"] {
+			t.Errorf("Expected c1 reset, got %+v", c1)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "one_1.epub")); !os.IsNotExist(err) {
+			t.Error("Expected the file of c1 removed")
+		}
+		if !store.chapters["m1"][1].Downloaded {
+			t.Error("Unread chapter should be kept")
+		}
+	})
+
+	t.Run("largest first", func(t *testing.T) {
+		store, dir := setup(t)
+		report, err := EnforceQuota(store, dir, Quota{Limit: 450, Policy: EvictLargest}, false)
+		if err != nil {
+			t.Fatalf("EnforceQuota() error = %v", err)
+		}
+		if got := evicted(report); strings.Join(got, ",") != "c3" {
+			t.Errorf("Expected c3 evicted, got %v", got)
+		}
+	})
+
+	t.Run("favorites are kept even over quota", func(t *testing.T) {
+		store, dir := setup(t)
+		report, err := EnforceQuota(store, dir, Quota{Limit: 100, Policy: EvictLargest}, false)
+		if err != nil {
+			t.Fatalf("EnforceQuota() error = %v", err)
+		}
+		if len(report.Evicted) != 3 || !report.Over() {
+			t.Errorf("Expected 3 files evicted and still over quota, got %+v", report)
+		}
+		if !store.chapters["m3"][0].Downloaded {
+			t.Error("Favorite should not be evicted")
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		store, dir := setup(t)
+		report, err := EnforceQuota(store, dir, Quota{Limit: 450, Policy: EvictLargest}, true)
+		if err != nil {
+			t.Fatalf("EnforceQuota() error = %v", err)
+		}
+		if len(report.Evicted) != 1 || !store.chapters["m2"][0].Downloaded {
+			t.Errorf("Expected 1 file listed and nothing touched, got %+v", report)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "two_1.epub")); err != nil {
+			t.Errorf("Expected the file kept: %v", err)
+		}
+	})
+}
+
+func TestDownloader_EnforcesQuota(t *testing.T) {
+	pngData := createTestPNG()
+	pages := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngData)
+	}))
+	defer pages.Close()
+	source := &mockSource{
+		getPagesFunc: func(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+			return []string{pages.URL + "/page.png"}, nil
+		},
+	}
+
+	// An old 100 byte chapter and a new one, larger once downloaded
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.epub")
+	if err := os.WriteFile(old, make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manga := &data.Manga{ID: "m1", Name: "Naruto"}
+	fresh := &data.Chapter{ID: "c2", MangaID: "m1", Number: "2"}
+	store := &mockQuotaStore{
+		mangas: []*data.Manga{manga},
+		chapters: map[string][]*data.Chapter{
+			"m1": {{ID: "c1", MangaID: "m1", Number: "1", Downloaded: true, FilePath: old}, fresh},
+		},
+		state:     make(map[string]string),
+		checksums: make(map[string]bool),
+	}
+	if err := SaveQuota(store, Quota{Limit: 50, Policy: EvictLargest}); err != nil {
+		t.Fatal(err)
+	}
+
+	downloader := NewDownloader(source, &mockRepository{}, dir)
+	defer downloader.Close()
+	downloader.SetQuotaStore(store)
+	var reports []*EvictionReport
+	downloader.OnEviction(func(report *EvictionReport, err error) {
+		if err != nil {
+			t.Errorf("enforcing the quota failed: %v", err)
+		}
+		reports = append(reports, report)
+	})
+
+	if err := downloader.DownloadManga(manga, []*data.Chapter{fresh}); err != nil {
+		t.Fatalf("DownloadManga failed: %v", err)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("Expected one eviction report, got %d", len(reports))
+	}
+	// The largest book is the one just downloaded, it stays
+	if evicted := reports[0].Evicted; len(evicted) != 1 || evicted[0].Path != old {
+		t.Errorf("Expected only the old chapter evicted, got %+v", evicted)
+	}
+	if !reports[0].Over() {
+		t.Error("Expected the downloads still over the quota")
+	}
+	if !fresh.Downloaded {
+		t.Fatal("Expected the new chapter downloaded")
+	}
+	if _, err := os.Stat(fresh.FilePath); err != nil {
+		t.Errorf("Expected the new chapter kept: %v", err)
+	}
+}
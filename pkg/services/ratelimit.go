@@ -0,0 +1,67 @@
+package services
+
+import (
+	"net/url"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces requests evenly at a fixed rate. Unlike a ticker it does
+// not fire while idle, so the first request after a pause goes out immediately.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// newRateLimiter creates a limiter allowing perSecond requests per second.
+// A non-positive rate disables limiting.
+func newRateLimiter(perSecond float64) *rateLimiter {
+	limiter := &rateLimiter{}
+	if perSecond > 0 {
+		limiter.interval = time.Duration(float64(time.Second) / perSecond)
+	}
+	return limiter
+}
+
+// Wait blocks until the next request is allowed
+func (l *rateLimiter) Wait() {
+	if l.interval == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	time.Sleep(wait)
+}
+
+// hostLimiters applies per-host rate limits on top of the global one
+type hostLimiters struct {
+	limiters map[string]*rateLimiter
+}
+
+func newHostLimiters(limits map[string]float64) *hostLimiters {
+	h := &hostLimiters{limiters: make(map[string]*rateLimiter, len(limits))}
+	for host, perSecond := range limits {
+		h.limiters[host] = newRateLimiter(perSecond)
+	}
+	return h
+}
+
+// Wait blocks until a request to rawURL's host is allowed
+func (h *hostLimiters) Wait(rawURL string) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return
+	}
+	if limiter, ok := h.limiters[u.Hostname()]; ok {
+		limiter.Wait()
+	}
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Wait(t *testing.T) {
+	t.Run("spaces requests", func(t *testing.T) {
+		limiter := newRateLimiter(20) // 50ms apart
+
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			limiter.Wait()
+		}
+		elapsed := time.Since(start)
+
+		// First request is immediate, the next two wait 50ms each
+		if elapsed < 90*time.Millisecond {
+			t.Errorf("3 requests took %v, want at least 100ms", elapsed)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		limiter := newRateLimiter(0)
+
+		start := time.Now()
+		for i := 0; i < 100; i++ {
+			limiter.Wait()
+		}
+		if elapsed := time.Since(start); elapsed > 10*time.Millisecond {
+			t.Errorf("unlimited requests took %v", elapsed)
+		}
+	})
+}
+
+func TestHostLimiters_Wait(t *testing.T) {
+	limiters := newHostLimiters(map[string]float64{"slow.example.com": 20})
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		limiters.Wait("https://fast.example.com/page.png")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Millisecond {
+		t.Errorf("unlimited host took %v", elapsed)
+	}
+
+	start = time.Now()
+	for i := 0; i < 3; i++ {
+		limiters.Wait("https://slow.example.com:8443/page.png")
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("limited host took %v, want at least 100ms", elapsed)
+	}
+}
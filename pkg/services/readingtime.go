@@ -0,0 +1,54 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+// DefaultReadingSpeed is the pages read per minute when none is configured,
+// about ten seconds a page
+const DefaultReadingSpeed = 6.0
+
+// ReadingTime estimates how long the backlog takes to read at
+// pagesPerMinute, rounded to the minute
+func ReadingTime(backlog *data.ReadingBacklog, pagesPerMinute float64) time.Duration {
+	if backlog == nil || pagesPerMinute <= 0 {
+		return 0
+	}
+	minutes := backlog.EstimatedPages() / pagesPerMinute
+	return time.Duration(minutes * float64(time.Minute)).Round(time.Minute)
+}
+
+// FormatReadingTime renders a reading time, e.g. "2h 15m", "< 1m" for
+// anything shorter than a minute
+func FormatReadingTime(d time.Duration) string {
+	d = d.Round(time.Minute)
+	switch {
+	case d < time.Minute:
+		return "< 1m"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dh %dm", int(d.Hours()), int((d % time.Hour).Minutes()))
+	}
+}
+
+// ReadingSummary describes what is left to read of a backlog, e.g. "12
+// chapters left, about 2h 15m", "" when everything downloaded was read
+func ReadingSummary(backlog *data.ReadingBacklog, pagesPerMinute float64) string {
+	if backlog == nil || backlog.Chapters == 0 {
+		return ""
+	}
+	chapters := "1 chapter"
+	if backlog.Chapters > 1 {
+		chapters = fmt.Sprintf("%d chapters", backlog.Chapters)
+	}
+	if backlog.EstimatedPages() == 0 {
+		return chapters + " left"
+	}
+	return fmt.Sprintf("%s left, about %s", chapters, FormatReadingTime(ReadingTime(backlog, pagesPerMinute)))
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+func TestLoadReadingSpeed(t *testing.T) {
+	store := &mockQuotaStore{state: make(map[string]string)}
+
+	if speed, err := LoadReadingSpeed(store); err != nil || speed != DefaultReadingSpeed {
+		t.Fatalf("LoadReadingSpeed() = %v, %v, want the default", speed, err)
+	}
+	for _, invalid := range []string{"fast", "0", "-2"} {
+		if err := SetConfig(store, ReadingSpeedKey, invalid); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+	if err := SetConfig(store, ReadingSpeedKey, "2.5"); err != nil {
+		t.Fatalf("SetConfig() error = %v", err)
+	}
+	if speed, err := LoadReadingSpeed(store); err != nil || speed != 2.5 {
+		t.Errorf("LoadReadingSpeed() = %v, %v, want 2.5", speed, err)
+	}
+}
+
+func TestReadingTime(t *testing.T) {
+	backlog := &data.ReadingBacklog{Chapters: 4, Pages: 100, Unknown: 1, AveragePages: 20}
+	if got := ReadingTime(backlog, 4); got != 30*time.Minute {
+		t.Errorf("ReadingTime() = %v, want 30m for 120 pages at 4 a minute", got)
+	}
+	if got := ReadingSummary(backlog, 1); got != "4 chapters left, about 2h" {
+		t.Errorf("ReadingSummary() = %q", got)
+	}
+	if got := ReadingSummary(&data.ReadingBacklog{Chapters: 1}, 6); got != "1 chapter left" {
+		t.Errorf("ReadingSummary() = %q, want no time for chapters of unknown length", got)
+	}
+	if got := ReadingSummary(&data.ReadingBacklog{}, 6); got != "" {
+		t.Errorf("ReadingSummary() = %q, want nothing left", got)
+	}
+
+	for d, want := range map[time.Duration]string{
+		20 * time.Second:              "< 1m",
+		45 * time.Minute:              "45m",
+		2 * time.Hour:                 "2h",
+		2*time.Hour + 15*time.Minute:  "2h 15m",
+		26*time.Hour + 29*time.Second: "26h",
+	} {
+		if got := FormatReadingTime(d); got != want {
+			t.Errorf("FormatReadingTime(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+)
+
+// ReadMarker marks chapters as read
+type ReadMarker interface {
+	MarkChapterRead(chapterID string, read bool) error
+}
+
+// ReadStatusOptions tunes ImportDeviceReadStatus
+type ReadStatusOptions struct {
+	MangaIDs []string // Manga to import, the whole library when empty
+	DryRun   bool     // Only report what would be marked as read
+	OnBook   func(ReadStatusBook)
+}
+
+// ReadStatusBook is a book read on a device, with the chapters it marks as
+// read
+type ReadStatusBook struct {
+	Manga    *data.Manga
+	Path     string // Relative to the device root
+	Progress *integrations.KOReaderProgress
+	Marked   []*data.Chapter // Chapters read on the device and not in the library
+	Err      error
+}
+
+// ReadStatusReport summarizes an import
+type ReadStatusReport struct {
+	Books  []ReadStatusBook // Books opened on the device
+	Marked int              // Chapters marked as read
+}
+
+// ImportDeviceReadStatus marks as read the chapters read on a device with
+// KOReader. Books are found from what 'mangas sync' copied to the device,
+// and their progress is read from the sidecar KOReader keeps next to them.
+// A finished book marks all its chapters as read; a volume read partway
+// marks the chapters before the position reached. Chapters are never marked
+// unread.
+func ImportDeviceReadStatus(repo Repository, store SyncStore, marker ReadMarker, device *integrations.Device, options ReadStatusOptions) (*ReadStatusReport, error) {
+	if device.ID == "" {
+		return nil, fmt.Errorf("nothing was synced to the device at %s yet, use 'mangas sync' first", device.Root)
+	}
+	records, err := store.GetDeviceSyncs(device.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get synced chapters: %w", err)
+	}
+	mangas, err := syncedMangas(repo, options.MangaIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReadStatusReport{}
+	for _, manga := range mangas {
+		chapters, err := repo.GetChapters(manga.ID)
+		if err != nil {
+			return report, fmt.Errorf("failed to get chapters of %s: %w", manga.Name, err)
+		}
+		for _, book := range syncedBooks(chapters, records) {
+			path := records[book[0].ID].Path
+			progress, err := integrations.ReadKOReaderProgress(filepath.Join(device.Root, path))
+			if progress == nil && err == nil {
+				continue
+			}
+			file := ReadStatusBook{Manga: manga, Path: path, Progress: progress, Err: err}
+			if err == nil {
+				file.Marked, file.Err = markBookRead(marker, book, progress, options.DryRun)
+				report.Marked += len(file.Marked)
+			}
+			report.Books = append(report.Books, file)
+			if options.OnBook != nil {
+				options.OnBook(file)
+			}
+		}
+	}
+	return report, nil
+}
+
+// syncedBooks groups the chapters synced to a device by the book holding
+// them, in order
+func syncedBooks(chapters []*data.Chapter, records map[string]*data.DeviceSync) [][]*data.Chapter {
+	var books [][]*data.Chapter
+	index := make(map[string]int)
+	for _, chapter := range chapters {
+		record := records[chapter.ID]
+		if record == nil {
+			continue
+		}
+		if i, ok := index[record.Path]; ok {
+			books[i] = append(books[i], chapter)
+			continue
+		}
+		index[record.Path] = len(books)
+		books = append(books, []*data.Chapter{chapter})
+	}
+	return books
+}
+
+// markBookRead marks the chapters of a book read up to progress, and
+// returns the ones that weren't read yet
+func markBookRead(marker ReadMarker, chapters []*data.Chapter, progress *integrations.KOReaderProgress, dryRun bool) ([]*data.Chapter, error) {
+	read := len(chapters)
+	if !progress.Finished() {
+		// Chapters are assumed to be of the same length
+		read = int(progress.Percent * float64(len(chapters)))
+	}
+
+	var marked []*data.Chapter
+	for _, chapter := range chapters[:read] {
+		if chapter.Read {
+			continue
+		}
+		if !dryRun {
+			if err := marker.MarkChapterRead(chapter.ID, true); err != nil {
+				return marked, fmt.Errorf("failed to mark chapter %s as read: %w", chapter.Number, err)
+			}
+		}
+		marked = append(marked, chapter)
+	}
+	return marked, nil
+}
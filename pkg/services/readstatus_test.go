@@ -0,0 +1,86 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+)
+
+// readMarks records the chapters marked as read
+type readMarks map[string]bool
+
+func (m readMarks) MarkChapterRead(chapterID string, read bool) error {
+	m[chapterID] = read
+	return nil
+}
+
+func TestImportDeviceReadStatus(t *testing.T) {
+	manga := &data.Manga{ID: "m1", Name: "Read Status"}
+	chapters := []*data.Chapter{
+		{ID: "c1", Number: "1"},
+		{ID: "c2", Number: "2", Read: true},
+		{ID: "c3", Number: "3"},
+		{ID: "c4", Number: "4"},
+		{ID: "c5", Number: "5"},
+		{ID: "c6", Number: "6"}, // Never synced
+	}
+	repo := &mockRepository{
+		listMangasFunc:  func() ([]*data.Manga, error) { return []*data.Manga{manga}, nil },
+		getChaptersFunc: func(string) ([]*data.Chapter, error) { return chapters, nil },
+	}
+
+	root := t.TempDir()
+	device, err := integrations.DetectDevice(root)
+	if err != nil {
+		t.Fatalf("DetectDevice() error = %v", err)
+	}
+	if _, err := ImportDeviceReadStatus(repo, memorySyncStore{}, readMarks{}, device, ReadStatusOptions{}); err == nil {
+		t.Error("Expected an error for a device never synced")
+	}
+	device.EnsureID()
+
+	// Chapter 1 alone, chapters 2 and 3 in a volume, chapters 4 and 5 in
+	// another one
+	store := memorySyncStore{}
+	books := map[string][]string{"c1.epub": {"c1"}, "v1.epub": {"c2", "c3"}, "v2.epub": {"c4", "c5"}}
+	for path, ids := range books {
+		for _, id := range ids {
+			store.SaveDeviceSync(&data.DeviceSync{DeviceID: device.ID, ChapterID: id, Path: filepath.Join("mangas", path)})
+		}
+	}
+	sidecar := func(book, percent, status string) {
+		path := integrations.KOReaderSidecar(filepath.Join(root, "mangas", book))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		content := fmt.Sprintf("return {\n    [\"percent_finished\"] = %s,\n    [\"summary\"] = {\n        [\"status\"] = %q,\n    },\n}\n", percent, status)
+		os.WriteFile(path, []byte(content), 0644)
+	}
+	sidecar("c1.epub", "0.4", "reading")
+	sidecar("v1.epub", "1", "complete")
+	sidecar("v2.epub", "0.6", "reading")
+
+	marks := readMarks{}
+	report, err := ImportDeviceReadStatus(repo, store, marks, device, ReadStatusOptions{DryRun: true})
+	if err != nil || report.Marked != 2 || len(marks) != 0 {
+		t.Fatalf("Dry run = %+v, %v, marked %v, want 2 chapters to mark and none marked", report, err, marks)
+	}
+
+	var opened []string
+	report, err = ImportDeviceReadStatus(repo, store, marks, device, ReadStatusOptions{
+		OnBook: func(book ReadStatusBook) { opened = append(opened, filepath.Base(book.Path)) },
+	})
+	if err != nil {
+		t.Fatalf("ImportDeviceReadStatus() error = %v", err)
+	}
+	// Chapter 1 is being read, chapter 2 was read already, and the second
+	// volume is read past chapter 4
+	if len(marks) != 2 || !marks["c3"] || !marks["c4"] {
+		t.Errorf("Expected chapters 3 and 4 marked as read, got %v", marks)
+	}
+	if len(opened) != 3 || report.Marked != 2 {
+		t.Errorf("Expected 3 books opened on the device, got %v (%d marked)", opened, report.Marked)
+	}
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+// StaleChapter is a chapter marked as downloaded whose file can't be read
+type StaleChapter struct {
+	Manga   *data.Manga
+	Chapter *data.Chapter
+	Reason  string
+}
+
+// FindStaleChapters returns the chapters of mangas marked as downloaded
+// whose file is missing, empty or not a regular file. Only the file system
+// is checked, files are not opened.
+func FindStaleChapters(repo Repository, mangas []*data.Manga) ([]StaleChapter, error) {
+	var stale []StaleChapter
+	for _, manga := range mangas {
+		chapters, err := repo.GetChapters(manga.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get chapters of %s: %w", manga.Name, err)
+		}
+		for _, chapter := range chapters {
+			if !chapter.Downloaded {
+				continue
+			}
+			if reason := staleReason(chapter.FilePath); reason != "" {
+				stale = append(stale, StaleChapter{Manga: manga, Chapter: chapter, Reason: reason})
+			}
+		}
+	}
+	return stale, nil
+}
+
+// staleReason tells why the file of a downloaded chapter can't be read, ""
+// when it looks fine
+func staleReason(path string) string {
+	if path == "" {
+		return "no file recorded"
+	}
+	info, err := os.Stat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return "file is missing"
+	case err != nil:
+		return err.Error()
+	case !info.Mode().IsRegular():
+		return "not a regular file"
+	case info.Size() == 0:
+		return "file is empty"
+	}
+	return ""
+}
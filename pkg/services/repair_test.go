@@ -0,0 +1,46 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+func TestFindStaleChapters(t *testing.T) {
+	dir := t.TempDir()
+	intact := filepath.Join(dir, "intact.epub")
+	empty := filepath.Join(dir, "empty.epub")
+	os.WriteFile(intact, []byte("epub"), 0644)
+	os.WriteFile(empty, nil, 0644)
+
+	chapters := []*data.Chapter{
+		{ID: "c1", Downloaded: true, FilePath: intact},
+		{ID: "c2", Downloaded: true, FilePath: filepath.Join(dir, "deleted.epub")},
+		{ID: "c3", Downloaded: true, FilePath: empty},
+		{ID: "c4", Downloaded: true, FilePath: dir},
+		{ID: "c5", Downloaded: true},
+		{ID: "c6", FilePath: filepath.Join(dir, "deleted.epub")}, // Not downloaded
+	}
+	repo := &mockRepository{getChaptersFunc: func(string) ([]*data.Chapter, error) { return chapters, nil }}
+
+	stale, err := FindStaleChapters(repo, []*data.Manga{{ID: "m1", Name: "Manga"}})
+	if err != nil {
+		t.Fatalf("FindStaleChapters() error = %v", err)
+	}
+	want := map[string]string{
+		"c2": "file is missing",
+		"c3": "file is empty",
+		"c4": "not a regular file",
+		"c5": "no file recorded",
+	}
+	if len(stale) != len(want) {
+		t.Fatalf("Expected %d stale chapters, got %+v", len(want), stale)
+	}
+	for _, s := range stale {
+		if want[s.Chapter.ID] != s.Reason || s.Manga.ID != "m1" {
+			t.Errorf("Chapter %s: reason %q, want %q", s.Chapter.ID, s.Reason, want[s.Chapter.ID])
+		}
+	}
+}
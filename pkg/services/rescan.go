@@ -0,0 +1,229 @@
+package services
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// RescanReport summarizes how the library was rebuilt from the files on disk
+type RescanReport struct {
+	Files            int      // Books read
+	Skipped          []string // Files that could not be read, with the reason
+	MangasAdded      int
+	ChaptersAdded    int
+	ChaptersRepaired int // Known chapters relinked to their file
+}
+
+// rescanManga holds the library records of a manga found during a rescan
+type rescanManga struct {
+	manga    *data.Manga
+	added    bool
+	chapters []*data.Chapter
+}
+
+// Rescan walks dir (the download directory when empty) for EPUB and CBZ files
+// and rebuilds the library records they describe, so a lost or damaged
+// database can be recovered from the downloads. Manga are matched by ID, then
+// by name; chapters by ID, then by number. Books written before identifiers
+// were embedded get "local-" IDs.
+func (c *MangaController) Rescan(dir string) (*RescanReport, error) {
+	if dir == "" {
+		dir = c.downloadDir
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	library, err := c.repo.ListMangas()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mangas: %w", err)
+	}
+	byID := make(map[string]*rescanManga, len(library))
+	byName := make(map[string]*rescanManga, len(library))
+	for _, manga := range library {
+		entry := &rescanManga{manga: manga}
+		byID[manga.ID] = entry
+		byName[strings.ToLower(manga.Name)] = entry
+	}
+
+	var books []string
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".epub", ".cbz":
+			if !d.IsDir() {
+				books = append(books, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk directory: %w", err)
+	}
+	// Chapter EPUBs come first so they win over CBZ copies of the same chapter
+	sort.SliceStable(books, func(i, j int) bool {
+		return strings.HasSuffix(books[i], ".epub") && !strings.HasSuffix(books[j], ".epub")
+	})
+
+	report := &RescanReport{}
+	for _, path := range books {
+		info, err := integrations.ReadBookInfo(path)
+		if err != nil {
+			report.Skipped = append(report.Skipped, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		report.Files++
+
+		entry := byID[info.MangaID]
+		if entry == nil {
+			entry = byName[strings.ToLower(info.MangaName)]
+		}
+		if entry == nil {
+			id := info.MangaID
+			if id == "" {
+				id = localID(info.MangaName)
+			}
+			entry = &rescanManga{
+				manga: &data.Manga{
+					ID:          id,
+					Name:        info.MangaName,
+					Description: info.Description,
+					Source:      info.Source,
+				},
+				added: true,
+			}
+			byID[id] = entry
+			byName[strings.ToLower(info.MangaName)] = entry
+			report.MangasAdded++
+		} else if !entry.added && entry.chapters == nil {
+			if entry.chapters, err = c.repo.GetChapters(entry.manga.ID); err != nil {
+				return nil, fmt.Errorf("failed to get chapters of %s: %w", entry.manga.Name, err)
+			}
+		}
+
+		for _, found := range info.Chapters {
+			if err := c.rescanChapter(entry, info, found, report); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, entry := range byID {
+		if !entry.added {
+			continue
+		}
+		entry.manga.Status = "completed"
+		for _, ch := range entry.chapters {
+			if !ch.Downloaded {
+				entry.manga.Status = "partial"
+			}
+		}
+		if err := c.repo.SaveManga(entry.manga); err != nil {
+			return nil, fmt.Errorf("failed to save manga %s: %w", entry.manga.Name, err)
+		}
+		for _, ch := range entry.chapters {
+			if err := c.repo.SaveChapter(ch); err != nil {
+				return nil, fmt.Errorf("failed to save chapter %s: %w", ch.Number, err)
+			}
+		}
+	}
+	return report, nil
+}
+
+// rescanChapter records a chapter found in a book. Only EPUBs mark chapters as
+// downloaded, as they are what the downloader writes and the exports read.
+// Chapters of manga already in the library are saved right away; those of new
+// manga are saved once the manga is.
+func (c *MangaController) rescanChapter(entry *rescanManga, info *integrations.BookInfo, found integrations.BookChapter, report *RescanReport) error {
+	isEPUB := strings.EqualFold(filepath.Ext(info.Path), ".epub")
+
+	var chapter *data.Chapter
+	number := utils.NormalizeChapterNumber(found.Number)
+	for _, ch := range entry.chapters {
+		if found.ID != "" && ch.ID == found.ID {
+			chapter = ch
+			break
+		}
+		if chapter == nil && utils.NormalizeChapterNumber(ch.Number) == number {
+			chapter = ch
+		}
+	}
+
+	if chapter == nil {
+		id := found.ID
+		if id == "" {
+			id = localID(strings.TrimPrefix(entry.manga.ID, "local-") + "-" + number)
+		}
+		language := info.Language
+		if language == "" {
+			language = "en"
+		}
+		chapter = &data.Chapter{
+			ID:       id,
+			MangaID:  entry.manga.ID,
+			Title:    found.Title,
+			Language: language,
+			Volume:   found.Volume,
+			Number:   found.Number,
+		}
+		if isEPUB {
+			chapter.Downloaded = true
+			chapter.FilePath = info.Path
+		}
+		entry.chapters = append(entry.chapters, chapter)
+		report.ChaptersAdded++
+	} else {
+		if !isEPUB || (chapter.Downloaded && fileExists(chapter.FilePath)) {
+			return nil
+		}
+		chapter.Downloaded = true
+		chapter.FilePath = info.Path
+		report.ChaptersRepaired++
+	}
+
+	if entry.added {
+		return nil
+	}
+	if err := c.repo.SaveChapter(chapter); err != nil {
+		return fmt.Errorf("failed to save chapter %s: %w", chapter.Number, err)
+	}
+	return nil
+}
+
+// localID builds an ID for a record recovered from a file that does not carry
+// its source ID
+func localID(name string) string {
+	var b strings.Builder
+	b.WriteString("local-")
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimRight(b.String(), "-")
+}
+
+// fileExists reports whether path names an existing file
+func fileExists(path string) bool {
+	if path == "" {
+		return false
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+)
+
+func TestControllerRescan(t *testing.T) {
+	dir := t.TempDir()
+	writeChapter := func(manga *data.Manga, chapter *data.Chapter) string {
+		builder := integrations.NewEPubBuilder(filepath.Join(dir, manga.Name))
+		os.MkdirAll(filepath.Join(dir, manga.Name), 0755)
+		builder.Init(manga, chapter)
+		builder.Next(integrations.ImageData{Content: createTestPNG(), ContentType: "image/png"})
+		path, err := builder.Done()
+		if err != nil {
+			t.Fatalf("Done() failed: %v", err)
+		}
+		return path
+	}
+
+	known := &data.Manga{ID: "known", Name: "Known Manga", Source: "mangadex"}
+	lost := &data.Manga{ID: "lost", Name: "Lost Manga", Source: "comick"}
+	knownPath := writeChapter(known, &data.Chapter{ID: "known-1", Number: "1"})
+	writeChapter(known, &data.Chapter{ID: "known-2", Number: "2"})
+	lostPath := writeChapter(lost, &data.Chapter{ID: "lost-7", Number: "7", Title: "Seven"})
+
+	// A CBZ from before identifiers, only named after the chapter
+	cbz, _ := os.Create(filepath.Join(dir, "Legacy_ch_2.cbz"))
+	w := zip.NewWriter(cbz)
+	f, _ := w.Create("0001.png")
+	f.Write(createTestPNG())
+	w.Close()
+	cbz.Close()
+	os.WriteFile(filepath.Join(dir, "broken.epub"), []byte("broken"), 0644)
+
+	mangas := map[string]*data.Manga{known.ID: known}
+	chapters := map[string]*data.Chapter{
+		"known-1": {ID: "known-1", MangaID: "known", Number: "1", Downloaded: true, FilePath: "/gone/known_1.epub"},
+	}
+	repo := &mockRepository{
+		listMangasFunc: func() ([]*data.Manga, error) {
+			var result []*data.Manga
+			for _, m := range mangas {
+				result = append(result, m)
+			}
+			return result, nil
+		},
+		getChaptersFunc: func(mangaID string) ([]*data.Chapter, error) {
+			var result []*data.Chapter
+			for _, ch := range chapters {
+				if ch.MangaID == mangaID {
+					copy := *ch
+					result = append(result, &copy)
+				}
+			}
+			return result, nil
+		},
+		saveMangaFunc: func(manga *data.Manga) error {
+			mangas[manga.ID] = manga
+			return nil
+		},
+		saveChapterFunc: func(chapter *data.Chapter) error {
+			chapters[chapter.ID] = chapter
+			return nil
+		},
+	}
+	controller := &MangaController{repo: repo, downloadDir: dir}
+
+	report, err := controller.Rescan("")
+	if err != nil {
+		t.Fatalf("Rescan() error = %v", err)
+	}
+	if report.Files != 4 || len(report.Skipped) != 1 {
+		t.Errorf("Expected 4 files read and 1 skipped, got %d and %v", report.Files, report.Skipped)
+	}
+	if report.MangasAdded != 2 || report.ChaptersAdded != 3 || report.ChaptersRepaired != 1 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+
+	if ch := chapters["known-1"]; !ch.Downloaded || ch.FilePath != knownPath {
+		t.Errorf("Expected the known chapter to be relinked, got %+v", ch)
+	}
+	if ch := chapters["known-2"]; ch == nil || !ch.Downloaded || ch.MangaID != "known" {
+		t.Errorf("Expected the missing chapter to be added, got %+v", ch)
+	}
+
+	if m := mangas["lost"]; m == nil || m.Name != "Lost Manga" || m.Source != "comick" || m.Status != "completed" {
+		t.Errorf("Expected the lost manga to be restored with its ID, got %+v", m)
+	}
+	if ch := chapters["lost-7"]; ch == nil || ch.Title != "Seven" || ch.FilePath != lostPath {
+		t.Errorf("Expected the lost chapter to be restored, got %+v", ch)
+	}
+
+	if m := mangas["local-legacy"]; m == nil || m.Status != "partial" {
+		t.Errorf("Expected a local manga for the legacy file, got %+v", m)
+	}
+	if ch := chapters["local-legacy-2"]; ch == nil || ch.Downloaded || ch.Number != "2" {
+		t.Errorf("Expected a not downloaded chapter for the CBZ, got %+v", ch)
+	}
+
+	// A second pass finds everything in place
+	report, err = controller.Rescan(dir)
+	if err != nil {
+		t.Fatalf("Rescan() error = %v", err)
+	}
+	if report.MangasAdded != 0 || report.ChaptersAdded != 0 || report.ChaptersRepaired != 0 {
+		t.Errorf("Expected nothing to repair, got %+v", report)
+	}
+}
+
+func TestLocalID(t *testing.T) {
+	tests := map[string]string{
+		"One Piece":    "local-one-piece",
+		"Re:Zero -- 2": "local-re-zero-2",
+		"ワンピース":        "local-ワンピース",
+		"Trailing!":    "local-trailing",
+	}
+	for name, want := range tests {
+		if got := localID(name); got != want {
+			t.Errorf("localID(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy controls how failed HTTP requests are retried
+type RetryPolicy struct {
+	Attempts      int           // Total attempts including the first one, 1 disables retries
+	Backoff       time.Duration // Delay before the first retry, doubled on every retry
+	MaxBackoff    time.Duration // Upper bound for the delay between retries
+	Jitter        float64       // Random fraction (0-1) added or removed from each delay
+	RetryOnStatus []int         // HTTP statuses worth retrying; network errors always are
+}
+
+// DefaultRetryPolicy retries transient CDN failures a few times
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Attempts:   3,
+		Backoff:    500 * time.Millisecond,
+		MaxBackoff: 10 * time.Second,
+		Jitter:     0.2,
+		RetryOnStatus: []int{
+			http.StatusRequestTimeout,
+			http.StatusTooEarly,
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// shouldRetry reports whether a response status is worth retrying
+func (p RetryPolicy) shouldRetry(status int) bool {
+	for _, s := range p.RetryOnStatus {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns the wait before the given retry (1 for the first retry)
+func (p RetryPolicy) delay(retry int) time.Duration {
+	wait := p.Backoff
+	for i := 1; i < retry && (p.MaxBackoff <= 0 || wait < p.MaxBackoff); i++ {
+		wait *= 2
+	}
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		wait += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(wait))
+	}
+	return wait
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: 350 * time.Millisecond}
+
+	tests := []struct {
+		retry int
+		want  time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 350 * time.Millisecond}, // Capped
+		{10, 350 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := policy.delay(tt.retry); got != tt.want {
+			t.Errorf("delay(%d) = %v, want %v", tt.retry, got, tt.want)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 20; i++ {
+		got := policy.delay(1)
+		if got < 50*time.Millisecond || got > 150*time.Millisecond {
+			t.Fatalf("delay with jitter = %v, want within 50-150ms", got)
+		}
+	}
+}
+
+func TestRetryPolicy_ShouldRetry(t *testing.T) {
+	policy := DefaultRetryPolicy()
+	if !policy.shouldRetry(http.StatusServiceUnavailable) {
+		t.Error("503 should be retried")
+	}
+	if policy.shouldRetry(http.StatusNotFound) {
+		t.Error("404 should not be retried")
+	}
+}
+
+func TestDownloader_RetryTransientErrors(t *testing.T) {
+	pngData := createTestPNG()
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first two requests fail with a transient error
+		if atomic.AddInt32(&requests, 1) <= 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngData)
+	}))
+	defer server.Close()
+
+	options := DefaultDownloaderOptions()
+	options.RequestsPerSecond = 0
+	options.Retry.Backoff = time.Millisecond
+	options.Retry.Jitter = 0
+
+	t.Run("succeeds after retries", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		downloader := NewDownloaderWithOptions(&mockSource{}, &mockRepository{}, t.TempDir(), options)
+		defer downloader.Close()
+		updates := downloader.GetProgressChannel()
+
+		images, err := downloader.downloadPages(&mockSource{}, &data.Manga{ID: "m"}, &data.Chapter{ID: "c"}, []string{server.URL})
+		if err != nil {
+			t.Fatalf("downloadPages() error = %v", err)
+		}
+		if len(images) != 1 {
+			t.Fatalf("Expected 1 image, got %d", len(images))
+		}
+
+		progress := <-updates
+		if progress.Retries != 2 {
+			t.Errorf("Expected 2 retries in progress, got %d", progress.Retries)
+		}
+	})
+
+	t.Run("gives up after attempts", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		limited := options
+		limited.Retry.Attempts = 2
+		downloader := NewDownloaderWithOptions(&mockSource{}, &mockRepository{}, t.TempDir(), limited)
+		defer downloader.Close()
+
+		if _, err := downloader.downloadImage(server.URL, 0); err == nil {
+			t.Error("downloadImage() should fail when retries are exhausted")
+		}
+		if got := atomic.LoadInt32(&requests); got != 2 {
+			t.Errorf("Expected 2 requests, got %d", got)
+		}
+	})
+
+	t.Run("does not retry client errors", func(t *testing.T) {
+		var notFound int32
+		missing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&notFound, 1)
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer missing.Close()
+
+		downloader := NewDownloaderWithOptions(&mockSource{}, &mockRepository{}, t.TempDir(), options)
+		defer downloader.Close()
+
+		if _, err := downloader.downloadImage(missing.URL, 0); err == nil {
+			t.Error("downloadImage() should fail on 404")
+		}
+		if notFound != 1 {
+			t.Errorf("Expected a single request for 404, got %d", notFound)
+		}
+	})
+}
+
+// TestDownloader_ChaosNetwork downloads a chapter through a network that
+// drops connections, fails requests and cuts bodies short: retries must
+// still end with every page intact
+func TestDownloader_ChaosNetwork(t *testing.T) {
+	pngData := createTestPNG()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngData)
+	}))
+	defer server.Close()
+
+	chaos := utils.ChaosOptions{Latency: time.Millisecond, Errors: 0.2, Drops: 0.1, Truncate: 0.2, Seed: 42}
+	if err := utils.SetClientOptions("chaos-test", utils.ClientOptions{Chaos: &chaos}); err != nil {
+		t.Fatalf("SetClientOptions() error = %v", err)
+	}
+	defer utils.SetClientOptions("chaos-test", utils.ClientOptions{})
+
+	options := DefaultDownloaderOptions()
+	options.RequestsPerSecond = 0
+	options.Retry.Attempts = 12
+	options.Retry.Backoff = time.Millisecond
+	options.Retry.Jitter = 0
+	downloader := NewDownloaderWithOptions(&mockSource{}, &mockRepository{}, t.TempDir(), options)
+	defer downloader.Close()
+
+	urls := make([]string, 8)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("%s/page/%d.png", server.URL, i)
+	}
+	images, err := downloader.downloadPages(&mockSource{}, &data.Manga{ID: "m", Source: "chaos-test"}, &data.Chapter{ID: "c"}, urls)
+	if err != nil {
+		t.Fatalf("downloadPages() error = %v", err)
+	}
+	if len(images) != len(urls) {
+		t.Fatalf("Expected %d images, got %d", len(urls), len(images))
+	}
+	for i, image := range images {
+		if !bytes.Equal(image.Content, pngData) {
+			t.Errorf("page %d is damaged: %d bytes, want %d", i, len(image.Content), len(pngData))
+		}
+	}
+}
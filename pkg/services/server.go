@@ -0,0 +1,228 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+)
+
+// LibraryServer serves the library as an OPDS catalog so e-readers can browse
+// it and download the generated EPUB (or on-the-fly CBZ) files over HTTP.
+//
+//	GET /opds                             library navigation feed
+//	GET /opds/manga/{id}                  downloaded chapters of a manga
+//	GET /download/{id}/{chapter}.epub     chapter EPUB
+//	GET /download/{id}/{chapter}.cbz      chapter repackaged as CBZ
+type LibraryServer struct {
+	repo  Repository
+	title string
+	mux   *http.ServeMux
+}
+
+// NewLibraryServer creates an OPDS server for the library in repo
+func NewLibraryServer(repo Repository, title string) *LibraryServer {
+	if title == "" {
+		title = "Manga Library"
+	}
+
+	s := &LibraryServer{
+		repo:  repo,
+		title: title,
+		mux:   http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/opds", http.StatusFound)
+	})
+	s.mux.HandleFunc("GET /opds", s.handleLibrary)
+	s.mux.HandleFunc("GET /opds/manga/{id}", s.handleManga)
+	s.mux.HandleFunc("GET /download/{id}/{file}", s.handleDownload)
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *LibraryServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handleLibrary lists the manga that have downloaded chapters
+func (s *LibraryServer) handleLibrary(w http.ResponseWriter, r *http.Request) {
+	mangas, err := s.repo.ListMangas()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	feed := integrations.NewOPDSFeed("urn:mangas:library", s.title, time.Now())
+	feed.Links = []integrations.OPDSLink{
+		{Rel: integrations.OPDSRelSelf, Href: "/opds", Type: integrations.OPDSNavigationType},
+		{Rel: integrations.OPDSRelStart, Href: "/opds", Type: integrations.OPDSNavigationType},
+	}
+
+	for _, manga := range mangas {
+		chapters, updated := s.downloadedChapters(manga.ID)
+		if len(chapters) == 0 {
+			continue
+		}
+
+		entry := integrations.OPDSEntry{
+			ID:      "urn:mangas:manga:" + manga.ID,
+			Title:   manga.Name,
+			Updated: integrations.OPDSTime(updated),
+			Links: []integrations.OPDSLink{{
+				Rel:   integrations.OPDSRelSubsection,
+				Href:  "/opds/manga/" + url.PathEscape(manga.ID),
+				Type:  integrations.OPDSAcquisitionType,
+				Title: fmt.Sprintf("%d chapters", len(chapters)),
+			}},
+		}
+		if manga.Description != "" {
+			entry.Content = &integrations.OPDSContent{Type: "text", Text: manga.Description}
+		}
+		if manga.CoverURL != "" {
+			entry.Links = append(entry.Links,
+				integrations.OPDSLink{Rel: integrations.OPDSRelImage, Href: manga.CoverURL, Type: "image/jpeg"},
+				integrations.OPDSLink{Rel: integrations.OPDSRelThumbnail, Href: manga.CoverURL, Type: "image/jpeg"},
+			)
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	s.writeFeed(w, feed, integrations.OPDSNavigationType)
+}
+
+// handleManga lists the downloaded chapters of a manga with their download links
+func (s *LibraryServer) handleManga(w http.ResponseWriter, r *http.Request) {
+	manga, err := s.repo.GetManga(r.PathValue("id"))
+	if err != nil || manga == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	chapters, updated := s.downloadedChapters(manga.ID)
+	self := "/opds/manga/" + url.PathEscape(manga.ID)
+	feed := integrations.NewOPDSFeed("urn:mangas:manga:"+manga.ID, manga.Name, updated)
+	feed.Links = []integrations.OPDSLink{
+		{Rel: integrations.OPDSRelSelf, Href: self, Type: integrations.OPDSAcquisitionType},
+		{Rel: integrations.OPDSRelStart, Href: "/opds", Type: integrations.OPDSNavigationType},
+		{Rel: integrations.OPDSRelUp, Href: "/opds", Type: integrations.OPDSNavigationType},
+	}
+
+	for _, ch := range chapters {
+		title := fmt.Sprintf("Chapter %s", ch.Number)
+		if ch.Volume != "" && ch.Volume != "0" {
+			title = fmt.Sprintf("Vol. %s, %s", ch.Volume, title)
+		}
+		if ch.Title != "" {
+			title = fmt.Sprintf("%s: %s", title, ch.Title)
+		}
+
+		download := "/download/" + url.PathEscape(manga.ID) + "/" + url.PathEscape(ch.ID)
+		feed.Entries = append(feed.Entries, integrations.OPDSEntry{
+			ID:      "urn:mangas:chapter:" + ch.ID,
+			Title:   title,
+			Updated: integrations.OPDSTime(fileModTime(ch.FilePath)),
+			Author:  &integrations.OPDSAuthor{Name: manga.Name},
+			Links: []integrations.OPDSLink{
+				{Rel: integrations.OPDSRelAcquisition, Href: download + ".epub", Type: integrations.EPUBMediaType, Title: "EPUB"},
+				{Rel: integrations.OPDSRelAcquisition, Href: download + ".cbz", Type: integrations.CBZMediaType, Title: "CBZ"},
+			},
+		})
+	}
+
+	s.writeFeed(w, feed, integrations.OPDSAcquisitionType)
+}
+
+// handleDownload serves a chapter EPUB, or converts it to CBZ on the fly
+func (s *LibraryServer) handleDownload(w http.ResponseWriter, r *http.Request) {
+	file := r.PathValue("file")
+	ext := filepath.Ext(file)
+	chapterID := strings.TrimSuffix(file, ext)
+
+	manga, err := s.repo.GetManga(r.PathValue("id"))
+	if err != nil || manga == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var chapter *data.Chapter
+	chapters, _ := s.downloadedChapters(manga.ID)
+	for _, ch := range chapters {
+		if ch.ID == chapterID {
+			chapter = ch
+			break
+		}
+	}
+	if chapter == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch ext {
+	case ".epub":
+		w.Header().Set("Content-Type", integrations.EPUBMediaType)
+		w.Header().Set("Content-Disposition", attachment(filepath.Base(chapter.FilePath)))
+		http.ServeFile(w, r, chapter.FilePath)
+	case ".cbz":
+		w.Header().Set("Content-Type", integrations.CBZMediaType)
+		w.Header().Set("Content-Disposition", attachment(integrations.CBZFilename(manga, chapter)))
+		if err := integrations.WriteCBZ(w, manga, chapter); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// downloadedChapters returns the chapters of a manga whose EPUB is on disk
+// and the time the most recent one was written
+func (s *LibraryServer) downloadedChapters(mangaID string) ([]*data.Chapter, time.Time) {
+	chapters, err := s.repo.GetChapters(mangaID)
+	if err != nil {
+		return nil, time.Time{}
+	}
+
+	var downloaded []*data.Chapter
+	var updated time.Time
+	for _, ch := range chapters {
+		if !ch.Downloaded || ch.FilePath == "" {
+			continue
+		}
+		modTime := fileModTime(ch.FilePath)
+		if modTime.IsZero() {
+			continue // EPUB moved or deleted
+		}
+		if modTime.After(updated) {
+			updated = modTime
+		}
+		downloaded = append(downloaded, ch)
+	}
+	return downloaded, updated
+}
+
+func (s *LibraryServer) writeFeed(w http.ResponseWriter, feed *integrations.OPDSFeed, contentType string) {
+	w.Header().Set("Content-Type", contentType+";charset=utf-8")
+	if err := feed.Encode(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// fileModTime returns the modification time of a file, or the zero time if
+// it does not exist
+func fileModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+func attachment(filename string) string {
+	return fmt.Sprintf("attachment; filename=%q", filename)
+}
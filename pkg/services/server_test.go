@@ -0,0 +1,122 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+)
+
+func TestLibraryServer(t *testing.T) {
+	manga := &data.Manga{ID: "manga-1", Name: "Test Manga", Description: "A test", CoverURL: "https://example.com/cover.jpg"}
+	chapter := &data.Chapter{ID: "ch-1", MangaID: "manga-1", Number: "1", Title: "Start"}
+
+	builder := integrations.NewEPubBuilder(t.TempDir())
+	if err := builder.Init(manga, chapter); err != nil {
+		t.Fatalf("Init() failed: %v", err)
+	}
+	builder.Next(integrations.ImageData{Content: createTestPNG(), ContentType: "image/png", Index: 0})
+	epubPath, err := builder.Done()
+	if err != nil {
+		t.Fatalf("Done() failed: %v", err)
+	}
+	chapter.Downloaded = true
+	chapter.FilePath = epubPath
+
+	repo := &mockRepository{
+		listMangasFunc: func() ([]*data.Manga, error) {
+			return []*data.Manga{manga, {ID: "manga-2", Name: "Not Downloaded"}}, nil
+		},
+		getMangaFunc: func(id string) (*data.Manga, error) {
+			if id == manga.ID {
+				return manga, nil
+			}
+			return nil, nil
+		},
+		getChaptersFunc: func(mangaID string) ([]*data.Chapter, error) {
+			if mangaID == manga.ID {
+				return []*data.Chapter{chapter, {ID: "ch-2", MangaID: "manga-1", Number: "2"}}, nil
+			}
+			return nil, nil
+		},
+	}
+
+	server := httptest.NewServer(NewLibraryServer(repo, ""))
+	defer server.Close()
+
+	get := func(path string) (*http.Response, []byte) {
+		t.Helper()
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, body
+	}
+
+	t.Run("library feed", func(t *testing.T) {
+		resp, body := get("/opds")
+		if !strings.HasPrefix(resp.Header.Get("Content-Type"), integrations.OPDSNavigationType) {
+			t.Errorf("Content-Type = %q", resp.Header.Get("Content-Type"))
+		}
+		feed := string(body)
+		if !strings.Contains(feed, "<title>Test Manga</title>") || !strings.Contains(feed, `href="/opds/manga/manga-1"`) {
+			t.Errorf("library feed is missing the downloaded manga:\n%s", feed)
+		}
+		if strings.Contains(feed, "Not Downloaded") {
+			t.Error("library feed should skip manga without downloaded chapters")
+		}
+	})
+
+	t.Run("manga feed", func(t *testing.T) {
+		_, body := get("/opds/manga/manga-1")
+		feed := string(body)
+		if strings.Count(feed, "<entry>") != 1 {
+			t.Errorf("expected 1 downloaded chapter entry:\n%s", feed)
+		}
+		for _, want := range []string{`href="/download/manga-1/ch-1.epub"`, `href="/download/manga-1/ch-1.cbz"`, "Chapter 1: Start"} {
+			if !strings.Contains(feed, want) {
+				t.Errorf("manga feed is missing %s", want)
+			}
+		}
+	})
+
+	t.Run("epub download", func(t *testing.T) {
+		resp, body := get("/download/manga-1/ch-1.epub")
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != integrations.EPUBMediaType {
+			t.Fatalf("status = %d, Content-Type = %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		if _, err := zip.NewReader(bytes.NewReader(body), int64(len(body))); err != nil {
+			t.Errorf("downloaded EPUB is not a zip: %v", err)
+		}
+	})
+
+	t.Run("cbz download", func(t *testing.T) {
+		resp, body := get("/download/manga-1/ch-1.cbz")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d", resp.StatusCode)
+		}
+		reader, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+		if err != nil {
+			t.Fatalf("downloaded CBZ is not a zip: %v", err)
+		}
+		if len(reader.File) != 2 {
+			t.Errorf("expected 1 page and ComicInfo.xml, got %d entries", len(reader.File))
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		for _, path := range []string{"/opds/manga/missing", "/download/manga-1/ch-2.epub", "/download/manga-1/ch-1.pdf"} {
+			if resp, _ := get(path); resp.StatusCode != http.StatusNotFound {
+				t.Errorf("GET %s status = %d, want 404", path, resp.StatusCode)
+			}
+		}
+	})
+}
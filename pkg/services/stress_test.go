@@ -0,0 +1,315 @@
+package services
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/sources"
+)
+
+// TestStress_ConcurrentController uses one controller from many goroutines
+// at once, the way the TUI screens, the queue and the downloads do. It finds
+// nothing by itself: run it with the race detector, see 'make stress'.
+func TestStress_ConcurrentController(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping stress test in short mode")
+	}
+
+	pngData := createTestPNG()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngData)
+	}))
+	defer server.Close()
+
+	// Every call returns chapters of its own, like the real sources
+	source := &mockSource{
+		searchFunc: func(query string, options sources.SearchOptions) ([]*data.Manga, error) {
+			return []*data.Manga{{ID: "found", Name: query}}, nil
+		},
+		getChaptersFunc: func(manga *data.Manga) ([]*data.Chapter, error) {
+			chapters := make([]*data.Chapter, 4)
+			for i := range chapters {
+				chapters[i] = &data.Chapter{
+					ID:       fmt.Sprintf("%s-ch%d", manga.ID, i+1),
+					MangaID:  manga.ID,
+					Number:   fmt.Sprintf("%d", i+1),
+					Language: "en",
+				}
+			}
+			return chapters, nil
+		},
+		getPagesFunc: func(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+			return []string{server.URL + "/1.png", server.URL + "/2.png"}, nil
+		},
+	}
+
+	dir := t.TempDir()
+	controller, err := NewMangaControllerWithConfig(ControllerConfig{
+		DownloadDir: filepath.Join(dir, "downloads"),
+		DBPath:      filepath.Join(dir, "stress.db"),
+		Downloader:  &DownloaderOptions{MaxConcurrentChapters: 3, MaxConcurrentPages: 2, RequestsPerSecond: 1000},
+	})
+	if err != nil {
+		t.Fatalf("NewMangaControllerWithConfig() error = %v", err)
+	}
+	defer controller.Close()
+	controller.source = source
+	controller.downloader = NewDownloaderWithOptions(source, controller.db, controller.downloadDir, DownloaderOptions{MaxConcurrentChapters: 3, MaxConcurrentPages: 2, RequestsPerSecond: 1000})
+	controller.downloader.SetChecksumStore(controller.db)
+	controller.queue = NewDownloadQueue(controller.db, controller.db, controller.downloader)
+
+	const mangas = 4
+	for i := 0; i < mangas; i++ {
+		manga := &data.Manga{ID: fmt.Sprintf("stress-%d", i), Name: fmt.Sprintf("Stress %d", i)}
+		if err := controller.AddMangaToLibrary(manga); err != nil {
+			t.Fatalf("AddMangaToLibrary() error = %v", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	run := func(name string, times int, work func(i int) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < times; i++ {
+				if err := work(i); err != nil {
+					errs <- fmt.Errorf("%s: %w", name, err)
+					return
+				}
+			}
+		}()
+	}
+
+	// Downloads, each with its own settings
+	for d := 0; d < 2; d++ {
+		d := d
+		run("download", 3, func(i int) error {
+			manga := &data.Manga{ID: fmt.Sprintf("stress-%d", (d+i)%mangas), Name: "Stress"}
+			options := DownloadOptions{Language: "en", AltText: integrations.AltTextPage}
+			if i%2 == 0 {
+				options.AltText = integrations.AltTextDescriptive
+				options.Webtoon = &integrations.WebtoonOptions{}
+			}
+			return controller.DownloadManga(manga, options)
+		})
+	}
+
+	// The queue, fed and run while the rest goes on
+	run("queue", 2, func(i int) error {
+		manga, err := controller.GetMangaFromLibrary(fmt.Sprintf("stress-%d", i))
+		if err != nil {
+			return err
+		}
+		if _, err := controller.SyncManga(manga); err != nil {
+			return err
+		}
+		chapters, err := controller.GetChaptersFromLibrary(manga.ID)
+		if err != nil || len(chapters) == 0 {
+			return fmt.Errorf("no chapters: %v", err)
+		}
+		if err := controller.Queue().Add(manga, chapters...); err != nil {
+			return err
+		}
+		if _, err := controller.Queue().Prefetch(manga, chapters[0], 2); err != nil {
+			return err
+		}
+		if controller.Queue().Running() {
+			return nil
+		}
+		return controller.Queue().Run(t.Context())
+	})
+
+	// Screens reading the library and searching
+	run("library", 20, func(i int) error {
+		mangas, err := controller.ListLibraryMangas()
+		if err != nil {
+			return err
+		}
+		for _, manga := range mangas {
+			if _, err := controller.GetChaptersFromLibrary(manga.ID); err != nil {
+				return err
+			}
+		}
+		_, err = controller.Queue().List()
+		return err
+	})
+	run("search", 20, func(i int) error {
+		_, err := controller.SearchManga(fmt.Sprintf("query %d", i), sources.SearchOptions{})
+		return err
+	})
+
+	// Settings changed mid-download
+	run("settings", 50, func(i int) error {
+		controller.downloader.SetImageCheck(integrations.ImageCheckOff)
+		controller.downloader.SetArchivePasswords(fmt.Sprint(i))
+		controller.downloader.SetOCR(nil)
+		return nil
+	})
+
+	// Progress readers coming and going, like the dashboard
+	run("progress", 20, func(i int) error {
+		updates, unsubscribe := controller.SubscribeProgress()
+		select {
+		case <-updates:
+		default:
+		}
+		unsubscribe()
+		return nil
+	})
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// Downloads racing over a chapter still leave it consistent
+	for i := 0; i < mangas; i++ {
+		chapters, err := controller.GetChaptersFromLibrary(fmt.Sprintf("stress-%d", i))
+		if err != nil {
+			t.Fatalf("GetChaptersFromLibrary() error = %v", err)
+		}
+		for _, chapter := range chapters {
+			if chapter.Downloaded && chapter.FilePath == "" {
+				t.Errorf("Chapter %s is downloaded without a file", chapter.ID)
+			}
+		}
+	}
+}
+
+// TestStress_ConcurrentDownloadOptions runs two downloads at once with
+// different options: each book is written with the options of its own call,
+// and none of them is left on the controller's downloader. Run it with the
+// race detector, see 'make stress'.
+func TestStress_ConcurrentDownloadOptions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping stress test in short mode")
+	}
+
+	pngData := createTestPNG()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngData)
+	}))
+	defer server.Close()
+
+	source := &mockSource{
+		getChaptersFunc: func(manga *data.Manga) ([]*data.Chapter, error) {
+			chapters := make([]*data.Chapter, 3)
+			for i := range chapters {
+				chapters[i] = &data.Chapter{
+					ID:       fmt.Sprintf("%s-ch%d", manga.ID, i+1),
+					MangaID:  manga.ID,
+					Number:   fmt.Sprintf("%d", i+1),
+					Language: "en",
+				}
+			}
+			return chapters, nil
+		},
+		getPagesFunc: func(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+			return []string{server.URL + "/1.png", server.URL + "/2.png"}, nil
+		},
+	}
+
+	dir := t.TempDir()
+	controller, err := NewMangaControllerWithConfig(ControllerConfig{
+		DownloadDir: filepath.Join(dir, "downloads"),
+		DBPath:      filepath.Join(dir, "stress.db"),
+	})
+	if err != nil {
+		t.Fatalf("NewMangaControllerWithConfig() error = %v", err)
+	}
+	defer controller.Close()
+	controller.source = source
+	controller.downloader = NewDownloaderWithOptions(source, controller.db, controller.downloadDir, DownloaderOptions{MaxConcurrentChapters: 3, MaxConcurrentPages: 2, RequestsPerSecond: 1000})
+
+	modes := map[string]integrations.AltTextMode{
+		"page":        integrations.AltTextPage,
+		"descriptive": integrations.AltTextDescriptive,
+	}
+	for id := range modes {
+		manga := &data.Manga{ID: id, Name: "Stress " + id}
+		if err := controller.AddMangaToLibrary(manga); err != nil {
+			t.Fatalf("AddMangaToLibrary() error = %v", err)
+		}
+		if _, err := controller.SyncManga(manga); err != nil {
+			t.Fatalf("SyncManga() error = %v", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*len(modes))
+	for id, mode := range modes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 3; i++ {
+				manga := &data.Manga{ID: id, Name: "Stress " + id}
+				if err := controller.DownloadManga(manga, DownloadOptions{Language: "en", AltText: mode, Force: true}); err != nil {
+					errs <- fmt.Errorf("%s: %w", id, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	for id, mode := range modes {
+		chapters, err := controller.GetChaptersFromLibrary(id)
+		if err != nil || len(chapters) == 0 {
+			t.Fatalf("GetChaptersFromLibrary() = %d chapters, error = %v", len(chapters), err)
+		}
+		for _, chapter := range chapters {
+			pages := readBookPages(t, chapter.FilePath)
+			if descriptive := strings.Contains(pages, ", page 1 of 2"); descriptive != (mode == integrations.AltTextDescriptive) {
+				t.Errorf("Chapter %s written with the ALT text of another download:\n%s", chapter.ID, pages)
+			}
+		}
+	}
+	if settings := controller.downloader.settings(); settings.altText != "" || settings.force {
+		t.Errorf("Download options left on the downloader: alt text %q, force %v", settings.altText, settings.force)
+	}
+}
+
+// readBookPages returns the page documents of the EPUB at path
+func readBookPages(t *testing.T, path string) string {
+	t.Helper()
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("failed to open EPUB: %v", err)
+	}
+	defer reader.Close()
+
+	var pages strings.Builder
+	for _, file := range reader.File {
+		if !strings.HasSuffix(file.Name, ".xhtml") {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", file.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", file.Name, err)
+		}
+		pages.Write(content)
+	}
+	return pages.String()
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/log"
+)
+
+// Thumbnail strips show the first pages of a chapter side by side
+const (
+	thumbnailPages = 8   // Pages in a strip
+	thumbnailWidth = 120 // Width of each page in pixels
+)
+
+// thumbnailLimit bounds the strips kept, the least recently used go first
+const thumbnailLimit = 1000
+
+// thumbnailDir is set by SetThumbnailDir
+var thumbnailDir string
+
+// SetThumbnailDir overrides where page thumbnails are cached, "" restores
+// the default
+func SetThumbnailDir(dir string) {
+	thumbnailDir = dir
+}
+
+// DefaultThumbnailDir returns where page thumbnails are cached: the
+// directory given to SetThumbnailDir, or ~/.mangas/thumbnails
+func DefaultThumbnailDir() string {
+	if thumbnailDir != "" {
+		return thumbnailDir
+	}
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".mangas", "thumbnails")
+}
+
+// ThumbnailCache stores a strip of page thumbnails per downloaded chapter,
+// keyed by chapter ID, so the chapter content can be checked at a glance
+// without opening the book. Past limit strips, the least recently used are
+// removed.
+type ThumbnailCache struct {
+	dir   string
+	limit int
+}
+
+// NewThumbnailCache returns a cache storing thumbnail strips in dir
+func NewThumbnailCache(dir string) *ThumbnailCache {
+	return &ThumbnailCache{dir: dir, limit: thumbnailLimit}
+}
+
+// Path returns where the strip of a chapter is stored
+func (c *ThumbnailCache) Path(chapterID string) string {
+	name := strings.NewReplacer("/", "_", `\`, "_", "..", "_").Replace(chapterID)
+	return filepath.Join(c.dir, name+".jpg")
+}
+
+// Save renders the first pages of a chapter as a strip of numbered
+// thumbnails and stores it
+func (c *ThumbnailCache) Save(chapterID string, pages [][]byte) error {
+	if len(pages) > thumbnailPages {
+		pages = pages[:thumbnailPages]
+	}
+	strip, err := integrations.BuildContactSheet(pages, integrations.ContactSheetOptions{
+		Columns:    thumbnailPages,
+		ThumbWidth: thumbnailWidth,
+		Padding:    4,
+		Quality:    80,
+		Labels:     true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render thumbnails: %w", err)
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create thumbnail dir: %w", err)
+	}
+	if err := os.WriteFile(c.Path(chapterID), strip, 0644); err != nil {
+		return err
+	}
+	c.prune()
+	return nil
+}
+
+// prune removes the least recently used strips past the limit. Strips are
+// touched when read, so their modification time tells.
+func (c *ThumbnailCache) prune() {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil || len(entries) <= c.limit {
+		return
+	}
+	type strip struct {
+		path string
+		used time.Time
+	}
+	strips := make([]strip, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		strips = append(strips, strip{filepath.Join(c.dir, entry.Name()), info.ModTime()})
+	}
+	sort.Slice(strips, func(i, j int) bool { return strips[i].used.Before(strips[j].used) })
+	for _, old := range strips[:max(len(strips)-c.limit, 0)] {
+		os.Remove(old.path)
+	}
+}
+
+// Get returns the thumbnail strip of a downloaded chapter. Chapters
+// downloaded before thumbnails were kept, or whose strip was removed, get
+// one rendered from their book, from their own pages in a volume.
+func (c *ThumbnailCache) Get(chapter *data.Chapter) ([]byte, error) {
+	path := c.Path(chapter.ID)
+	if strip, err := os.ReadFile(path); err == nil {
+		now := time.Now()
+		os.Chtimes(path, now, now)
+		return strip, nil
+	}
+	if !chapter.Downloaded || chapter.FilePath == "" {
+		return nil, fmt.Errorf("chapter %s is not downloaded", chapter.Number)
+	}
+	pages, err := readChapterThumbnails(chapter)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Save(chapter.ID, pages); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(c.Path(chapter.ID))
+}
+
+// readChapterThumbnails reads the first pages of a downloaded chapter
+func readChapterThumbnails(chapter *data.Chapter) ([][]byte, error) {
+	book, err := integrations.OpenBook(chapter.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer book.Close()
+
+	indexes, err := book.ChapterPages(chapter.Number)
+	if err != nil {
+		return nil, err
+	}
+	if len(indexes) > thumbnailPages {
+		indexes = indexes[:thumbnailPages]
+	}
+	pages := make([][]byte, 0, len(indexes))
+	for _, index := range indexes {
+		page, err := book.Page(index)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, page)
+	}
+	return pages, nil
+}
+
+// saveThumbnails keeps the thumbnail strip of a downloaded chapter, when
+// a thumbnail cache is set. Failures only cost the strip.
+func (d *Downloader) saveThumbnails(chapter *data.Chapter, pages [][]byte) {
+	cache := d.settings().thumbnails
+	if cache == nil || len(pages) == 0 {
+		return
+	}
+	if err := cache.Save(chapter.ID, pages); err != nil {
+		log.Warn("failed to save page thumbnails", "chapter_id", chapter.ID, "err", err)
+	}
+}
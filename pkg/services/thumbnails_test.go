@@ -0,0 +1,106 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+func TestThumbnailCache_Get(t *testing.T) {
+	cache := NewThumbnailCache(t.TempDir())
+
+	if _, err := cache.Get(&data.Chapter{ID: "ch-1", Number: "1"}); err == nil {
+		t.Error("Expected an error for a chapter that isn't downloaded")
+	}
+
+	pages := make([][]byte, 10)
+	for i := range pages {
+		pages[i] = createTestPNG()
+	}
+	if err := cache.Save("source/ch-1", pages); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if filepath.Dir(cache.Path("source/ch-1")) != cache.dir {
+		t.Errorf("Expected the strip in the cache dir, got %s", cache.Path("source/ch-1"))
+	}
+	strip, err := cache.Get(&data.Chapter{ID: "source/ch-1"})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(strip))
+	if err != nil || format != "jpeg" {
+		t.Fatalf("Expected a JPEG strip, got %s, %v", format, err)
+	}
+	if config.Width < thumbnailPages*thumbnailWidth || config.Width > 2*thumbnailPages*thumbnailWidth {
+		t.Errorf("Expected %d pages side by side, got a %dpx wide strip", thumbnailPages, config.Width)
+	}
+}
+
+func TestDownloader_SavesThumbnails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(createTestPNG())
+	}))
+	defer server.Close()
+
+	source := &mockSource{
+		getPagesFunc: func(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+			return []string{server.URL + "/1.png", server.URL + "/2.png"}, nil
+		},
+	}
+	downloader := NewDownloader(source, &mockRepository{}, t.TempDir())
+	defer downloader.Close()
+	cache := NewThumbnailCache(t.TempDir())
+	downloader.SetThumbnailCache(cache)
+
+	chapter := &data.Chapter{ID: "ch-1", MangaID: "manga-1", Number: "1"}
+	if err := downloader.DownloadChapter(&data.Manga{ID: "manga-1", Name: "Thumbs"}, chapter); err != nil {
+		t.Fatalf("DownloadChapter() error = %v", err)
+	}
+	if _, err := os.Stat(cache.Path("ch-1")); err != nil {
+		t.Errorf("Expected the thumbnails saved with the chapter: %v", err)
+	}
+
+	// Chapters downloaded without thumbnails get them from their book
+	os.Remove(cache.Path("ch-1"))
+	if _, err := cache.Get(chapter); err != nil {
+		t.Errorf("Get() error = %v, want thumbnails rendered from %s", err, chapter.FilePath)
+	}
+	if _, err := os.Stat(cache.Path("ch-1")); err != nil {
+		t.Error(fmt.Errorf("expected the rendered thumbnails kept: %w", err))
+	}
+}
+
+func TestThumbnailCache_Prune(t *testing.T) {
+	cache := NewThumbnailCache(t.TempDir())
+	cache.limit = 2
+	pages := [][]byte{createTestPNG()}
+
+	old := time.Now().Add(-time.Hour)
+	for i, id := range []string{"ch-1", "ch-2"} {
+		if err := cache.Save(id, pages); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		used := old.Add(time.Duration(i) * time.Minute)
+		os.Chtimes(cache.Path(id), used, used)
+	}
+	// Reading ch-1 makes ch-2 the least recently used
+	cache.Get(&data.Chapter{ID: "ch-1"})
+	if err := cache.Save("ch-3", pages); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	for id, kept := range map[string]bool{"ch-1": true, "ch-2": false, "ch-3": true} {
+		if _, err := os.Stat(cache.Path(id)); (err == nil) != kept {
+			t.Errorf("Strip of %s kept = %v, want %v", id, err == nil, kept)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// transfers aggregates the progress of the manga being downloaded, for the
+// speed, chapter counts and ETA of DownloadProgress
+type transfers struct {
+	mu     sync.Mutex
+	mangas map[string]*transfer
+}
+
+// transfer is the download of several chapters of a manga
+type transfer struct {
+	started  time.Time
+	bytes    int64
+	chapters int
+	finished map[string]bool    // Chapters downloaded or failed
+	partial  map[string]float64 // Share of the pages of unfinished chapters downloaded
+}
+
+func newTransfers() *transfers {
+	return &transfers{mangas: make(map[string]*transfer)}
+}
+
+// start tracks the download of chapters chapters of a manga until the
+// returned function is called
+func (t *transfers) start(mangaID string, chapters int) func() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tr := &transfer{
+		started:  time.Now(),
+		chapters: chapters,
+		finished: make(map[string]bool),
+		partial:  make(map[string]float64),
+	}
+	t.mangas[mangaID] = tr
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.mangas[mangaID] == tr {
+			delete(t.mangas, mangaID)
+		}
+	}
+}
+
+// addBytes records bytes received for a manga
+func (t *transfers) addBytes(mangaID string, n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if tr := t.mangas[mangaID]; tr != nil {
+		tr.bytes += int64(n)
+	}
+}
+
+// annotate records progress in the transfer of its manga, if any, and fills
+// in the manga aggregate, speed and ETA
+func (t *transfers) annotate(progress *DownloadProgress) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tr := t.mangas[progress.MangaID]
+	if tr == nil || progress.ChapterID == "" {
+		return
+	}
+
+	switch progress.Status {
+	case "complete", "error":
+		tr.finished[progress.ChapterID] = true
+		delete(tr.partial, progress.ChapterID)
+	case "downloading":
+		if progress.TotalPages > 0 && !tr.finished[progress.ChapterID] {
+			tr.partial[progress.ChapterID] = float64(progress.CurrentPage) / float64(progress.TotalPages)
+		}
+	case "processing":
+		if !tr.finished[progress.ChapterID] {
+			tr.partial[progress.ChapterID] = 1
+		}
+	}
+
+	done := float64(len(tr.finished))
+	for _, share := range tr.partial {
+		done += share
+	}
+	elapsed := time.Since(tr.started)
+	progress.MangaChapters = tr.chapters
+	progress.MangaCompleted = len(tr.finished)
+	progress.Speed = transferSpeed(tr.bytes, elapsed)
+	progress.ETA = EstimateETA(elapsed, done, float64(tr.chapters))
+}
+
+// transferSpeed returns bytes per second over elapsed
+func transferSpeed(bytes int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(bytes) / elapsed.Seconds()
+}
+
+// EstimateETA extrapolates the time left to get from done to total units of
+// work at the pace of the elapsed time. It is 0 when unknown, before any
+// work is done.
+func EstimateETA(elapsed time.Duration, done, total float64) time.Duration {
+	if done <= 0 || total <= 0 || elapsed <= 0 {
+		return 0
+	}
+	if done >= total {
+		return 0
+	}
+	remaining := time.Duration(float64(elapsed) / done * (total - done))
+	return remaining.Round(time.Second)
+}
+
+// FormatBytes renders a byte count with a binary unit, e.g. "3.4 MB"
+func FormatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	value, exp := float64(bytes)/unit, 0
+	for value >= unit && exp < 3 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", value, "KMGT"[exp])
+}
+
+// FormatETA renders a time left, e.g. "2m30s", or "" when unknown
+func FormatETA(eta time.Duration) string {
+	if eta <= 0 {
+		return ""
+	}
+	if eta >= time.Hour {
+		eta = eta.Round(time.Minute)
+	}
+	return eta.String()
+}
+
+// TransferSummary renders the bytes, speed and ETA of a progress update,
+// e.g. "3.4 MB, 1.2 MB/s, ETA 2m30s", skipping what is unknown
+func (p DownloadProgress) TransferSummary() string {
+	var parts []string
+	if p.Bytes > 0 {
+		parts = append(parts, FormatBytes(p.Bytes))
+	}
+	if p.Speed > 0 {
+		parts = append(parts, FormatBytes(int64(p.Speed))+"/s")
+	}
+	if eta := FormatETA(p.ETA); eta != "" {
+		parts = append(parts, "ETA "+eta)
+	}
+	return strings.Join(parts, ", ")
+}
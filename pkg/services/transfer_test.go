@@ -0,0 +1,101 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimateETA(t *testing.T) {
+	tests := []struct {
+		elapsed     time.Duration
+		done, total float64
+		want        time.Duration
+	}{
+		{10 * time.Second, 1, 4, 30 * time.Second},
+		{10 * time.Second, 2.5, 5, 10 * time.Second},
+		{10 * time.Second, 0, 4, 0}, // Nothing done yet, unknown
+		{10 * time.Second, 4, 4, 0},
+		{0, 1, 4, 0},
+	}
+
+	for _, tt := range tests {
+		if got := EstimateETA(tt.elapsed, tt.done, tt.total); got != tt.want {
+			t.Errorf("EstimateETA(%v, %v, %v) = %v, want %v", tt.elapsed, tt.done, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		bytes int64
+		want  string
+	}{
+		{512, "512 B"},
+		{1536, "1.5 KB"},
+		{3 << 20, "3.0 MB"},
+		{5 << 30, "5.0 GB"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatBytes(tt.bytes); got != tt.want {
+			t.Errorf("FormatBytes(%d) = %q, want %q", tt.bytes, got, tt.want)
+		}
+	}
+}
+
+func TestTransfersAnnotate(t *testing.T) {
+	tr := newTransfers()
+
+	// Downloads outside a manga download are left alone
+	progress := DownloadProgress{MangaID: "m1", ChapterID: "c1", Status: "complete"}
+	tr.annotate(&progress)
+	if progress.MangaChapters != 0 {
+		t.Errorf("Expected no aggregate without a manga download, got %+v", progress)
+	}
+
+	stop := tr.start("m1", 4)
+	tr.mangas["m1"].started = time.Now().Add(-10 * time.Second)
+	tr.addBytes("m1", 10240)
+
+	for _, id := range []string{"c1", "c2"} {
+		progress := DownloadProgress{MangaID: "m1", ChapterID: id, Status: "complete"}
+		tr.annotate(&progress)
+	}
+	// A repeated completion counts once
+	progress = DownloadProgress{MangaID: "m1", ChapterID: "c2", Status: "complete"}
+	tr.annotate(&progress)
+
+	if progress.MangaChapters != 4 || progress.MangaCompleted != 2 {
+		t.Errorf("Expected 2 of 4 chapters, got %d of %d", progress.MangaCompleted, progress.MangaChapters)
+	}
+	if progress.ETA < 9*time.Second || progress.ETA > 11*time.Second {
+		t.Errorf("Expected an ETA around 10s, got %v", progress.ETA)
+	}
+	if progress.Speed < 900 || progress.Speed > 1100 {
+		t.Errorf("Expected about 1 KB/s, got %v", progress.Speed)
+	}
+
+	// Half of a third chapter shortens the estimate
+	progress = DownloadProgress{MangaID: "m1", ChapterID: "c3", Status: "downloading", CurrentPage: 5, TotalPages: 10}
+	tr.annotate(&progress)
+	if progress.ETA < 5*time.Second || progress.ETA > 7*time.Second {
+		t.Errorf("Expected an ETA around 6s, got %v", progress.ETA)
+	}
+
+	stop()
+	progress = DownloadProgress{MangaID: "m1", ChapterID: "c4", Status: "complete"}
+	tr.annotate(&progress)
+	if progress.MangaChapters != 0 {
+		t.Error("Expected the manga download to be forgotten once stopped")
+	}
+}
+
+func TestTransferSummary(t *testing.T) {
+	progress := DownloadProgress{Bytes: 3 << 20, Speed: 1536, ETA: 150 * time.Second}
+	if got, want := progress.TransferSummary(), "3.0 MB, 1.5 KB/s, ETA 2m30s"; got != want {
+		t.Errorf("TransferSummary() = %q, want %q", got, want)
+	}
+	if got := (DownloadProgress{}).TransferSummary(); got != "" {
+		t.Errorf("Expected an empty summary, got %q", got)
+	}
+}
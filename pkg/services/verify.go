@@ -0,0 +1,210 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+	"github.com/kerbaras/mangas/pkg/log"
+)
+
+// ChecksumStore records the checksums of downloaded chapters
+type ChecksumStore interface {
+	SaveChapterChecksum(sum *data.ChapterChecksum) error
+	GetChapterChecksums(mangaID string) (map[string]*data.ChapterChecksum, error)
+}
+
+// VerifyStatus is the state of a downloaded book on disk
+type VerifyStatus string
+
+const (
+	VerifyOK       VerifyStatus = "ok"       // Intact
+	VerifyRecorded VerifyStatus = "recorded" // Intact, its checksums were recorded for the first time
+	VerifyMissing  VerifyStatus = "missing"  // The file is gone
+	VerifyCorrupt  VerifyStatus = "corrupt"  // The file is damaged
+)
+
+// VerifyOptions tunes VerifyDownloads
+type VerifyOptions struct {
+	Deep   bool // Decode every page, even of files matching their checksum
+	OnFile func(VerifyResult)
+}
+
+// VerifyResult is the state of a downloaded book
+type VerifyResult struct {
+	Manga    *data.Manga
+	Chapters []*data.Chapter // Several when chapters were bundled in a volume
+	Path     string
+	Status   VerifyStatus
+	Err      error // What is wrong with a corrupt file
+}
+
+// VerifyReport summarizes VerifyDownloads
+type VerifyReport struct {
+	Files   int
+	OK      int            // Intact files, including recorded ones
+	Damaged []VerifyResult // Missing and corrupt files
+}
+
+// VerifyDownloads checks the downloaded books of mangas against the
+// checksums recorded when they were written. Files without checksums, or
+// not matching them, have every page read and decoded: intact ones get their
+// checksums recorded, the others are reported as corrupt.
+func VerifyDownloads(repo Repository, store ChecksumStore, mangas []*data.Manga, options VerifyOptions) (*VerifyReport, error) {
+	report := &VerifyReport{}
+	for _, manga := range mangas {
+		chapters, err := repo.GetChapters(manga.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get chapters of %s: %w", manga.Name, err)
+		}
+		records, err := store.GetChapterChecksums(manga.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get checksums of %s: %w", manga.Name, err)
+		}
+
+		// Chapters of a volume share their file
+		var paths []string
+		books := make(map[string][]*data.Chapter)
+		for _, chapter := range chapters {
+			if !chapter.Downloaded || chapter.FilePath == "" {
+				continue
+			}
+			if books[chapter.FilePath] == nil {
+				paths = append(paths, chapter.FilePath)
+			}
+			books[chapter.FilePath] = append(books[chapter.FilePath], chapter)
+		}
+
+		for _, path := range paths {
+			result := verifyBook(store, records, manga, books[path], path, options.Deep)
+			report.Files++
+			if result.Status == VerifyOK || result.Status == VerifyRecorded {
+				report.OK++
+			} else {
+				report.Damaged = append(report.Damaged, result)
+			}
+			if options.OnFile != nil {
+				options.OnFile(result)
+			}
+		}
+	}
+	return report, nil
+}
+
+// verifyBook checks the file of chapters against their recorded checksums
+func verifyBook(store ChecksumStore, records map[string]*data.ChapterChecksum, manga *data.Manga, chapters []*data.Chapter, path string, deep bool) VerifyResult {
+	result := VerifyResult{Manga: manga, Chapters: chapters, Path: path}
+	corrupt := func(err error) VerifyResult {
+		result.Status, result.Err = VerifyCorrupt, err
+		return result
+	}
+
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		result.Status = VerifyMissing
+		return result
+	}
+	if err != nil {
+		return corrupt(err)
+	}
+	sum, err := integrations.FileSHA256(path)
+	if err != nil {
+		return corrupt(err)
+	}
+
+	// The pages recorded for the chapters in the file, by checksum
+	recorded, expected := false, make(map[string]int)
+	matches := true
+	for _, chapter := range chapters {
+		record := records[chapter.ID]
+		if record == nil || record.Path != path {
+			matches = false
+			continue
+		}
+		recorded = true
+		matches = matches && record.SHA256 == sum
+		for _, page := range record.Pages {
+			expected[page]++
+		}
+	}
+	if matches && !deep {
+		result.Status = VerifyOK
+		return result
+	}
+
+	// Checksums recorded before the file changed, or none at all: the pages
+	// tell whether it is still intact
+	pages, err := integrations.ReadBookPages(path)
+	if err != nil {
+		return corrupt(err)
+	}
+	images := make([]integrations.ImageData, len(pages))
+	for i, page := range pages {
+		if err := integrations.CheckImage(page, "", integrations.ImageCheckDecode); err != nil {
+			return corrupt(fmt.Errorf("page %d: %w", i+1, err))
+		}
+		images[i] = integrations.ImageData{Content: page, Index: i}
+	}
+	sums := integrations.HashPages(images)
+	if len(expected) > 0 {
+		for _, page := range sums {
+			expected[page]--
+		}
+		for _, count := range expected {
+			if count != 0 {
+				return corrupt(fmt.Errorf("pages differ from the downloaded ones"))
+			}
+		}
+	}
+
+	result.Status = VerifyOK
+	if !matches {
+		if !recorded {
+			result.Status = VerifyRecorded
+		}
+		// One chapter of a volume gets the pages, so they are counted once
+		for i, chapter := range chapters {
+			record := &data.ChapterChecksum{ChapterID: chapter.ID, Path: path, SHA256: sum, Size: info.Size()}
+			if i == 0 {
+				record.Pages = sums
+			}
+			if err := store.SaveChapterChecksum(record); err != nil {
+				log.Warn("failed to record checksums", "chapter_id", chapter.ID, "err", err)
+			}
+		}
+	}
+	return result
+}
+
+// recordChecksums stores the checksums of the book written for chapters,
+// pages holding the checksums of the pages of each chapter by ID. Failing to
+// record them does not fail the download, verify records them later.
+func (d *Downloader) recordChecksums(path string, chapters []*data.Chapter, pages map[string][]string) {
+	store := d.settings().checksums
+	if store == nil {
+		return
+	}
+	sum, err := integrations.FileSHA256(path)
+	var size int64
+	if info, statErr := os.Stat(path); statErr == nil {
+		size = info.Size()
+	}
+	for _, chapter := range chapters {
+		if err == nil {
+			err = store.SaveChapterChecksum(&data.ChapterChecksum{
+				ChapterID: chapter.ID,
+				Path:      path,
+				SHA256:    sum,
+				Size:      size,
+				Pages:     pages[chapter.ID],
+			})
+		}
+		if err != nil {
+			log.Warn("failed to record checksums", "chapter_id", chapter.ID, "err", err)
+			return
+		}
+	}
+}
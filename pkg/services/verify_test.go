@@ -0,0 +1,118 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/integrations"
+)
+
+// memoryChecksumStore keeps chapter checksums in memory
+type memoryChecksumStore map[string]*data.ChapterChecksum
+
+func (s memoryChecksumStore) SaveChapterChecksum(sum *data.ChapterChecksum) error {
+	s[sum.ChapterID] = sum
+	return nil
+}
+
+func (s memoryChecksumStore) GetChapterChecksums(mangaID string) (map[string]*data.ChapterChecksum, error) {
+	sums := make(map[string]*data.ChapterChecksum)
+	for id, sum := range s {
+		sums[id] = sum
+	}
+	return sums, nil
+}
+
+// encodeTestPage returns a decodable PNG of the given height
+func encodeTestPage(t *testing.T, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, height))); err != nil {
+		t.Fatalf("failed to encode page: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestVerifyDownloads(t *testing.T) {
+	dir := t.TempDir()
+	manga := &data.Manga{ID: "m1", Name: "Verify Manga"}
+	builder := integrations.NewEPubBuilder(dir)
+	builder.Init(manga, &data.Chapter{ID: "c1", Number: "1"})
+	builder.Next(integrations.ImageData{Content: encodeTestPage(t, 4), ContentType: "image/png", Index: 0})
+	builder.Next(integrations.ImageData{Content: encodeTestPage(t, 6), ContentType: "image/png", Index: 1})
+	path, err := builder.Done()
+	if err != nil {
+		t.Fatalf("Done() failed: %v", err)
+	}
+
+	chapters := []*data.Chapter{
+		{ID: "c1", Number: "1", Downloaded: true, FilePath: path},
+		{ID: "c2", Number: "2", Downloaded: true, FilePath: path + ".missing"},
+		{ID: "c3", Number: "3"}, // Not downloaded
+	}
+	repo := &mockRepository{getChaptersFunc: func(string) ([]*data.Chapter, error) { return chapters, nil }}
+	store := memoryChecksumStore{}
+
+	var statuses []VerifyStatus
+	options := VerifyOptions{OnFile: func(result VerifyResult) { statuses = append(statuses, result.Status) }}
+	report, err := VerifyDownloads(repo, store, []*data.Manga{manga}, options)
+	if err != nil {
+		t.Fatalf("VerifyDownloads() error = %v", err)
+	}
+	if report.Files != 2 || report.OK != 1 || len(report.Damaged) != 1 || report.Damaged[0].Status != VerifyMissing {
+		t.Fatalf("Unexpected report: %+v", report)
+	}
+	if statuses[0] != VerifyRecorded || store["c1"] == nil || len(store["c1"].Pages) != 2 {
+		t.Fatalf("Expected the checksums of an intact file to be recorded, got %v, %+v", statuses, store["c1"])
+	}
+
+	statuses = nil
+	VerifyDownloads(repo, store, []*data.Manga{manga}, options)
+	if statuses[0] != VerifyOK {
+		t.Errorf("Expected the recorded file to be intact, got %s", statuses[0])
+	}
+
+	// A truncated file
+	content, _ := os.ReadFile(path)
+	os.WriteFile(path, content[:len(content)/2], 0644)
+	report, _ = VerifyDownloads(repo, store, []*data.Manga{manga}, options)
+	if len(report.Damaged) != 2 || report.Damaged[0].Status != VerifyCorrupt || report.Damaged[0].Err == nil {
+		t.Errorf("Expected the truncated file to be corrupt, got %+v", report.Damaged)
+	}
+}
+
+func TestDownloader_ImageCheck(t *testing.T) {
+	page := encodeTestPage(t, 4)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "image/png")
+		if requests == 1 {
+			w.Write([]byte("<html>Service unavailable</html>")) // A broken CDN answer
+			return
+		}
+		w.Write(page)
+	}))
+	defer server.Close()
+
+	options := DefaultDownloaderOptions()
+	options.RequestsPerSecond = 0
+	options.Retry.Backoff = time.Millisecond
+	d := NewDownloaderWithOptions(&mockSource{}, &mockRepository{}, t.TempDir(), options)
+	d.SetImageCheck(integrations.ImageCheckDecode)
+
+	image, err := d.downloadImage(server.URL, 0)
+	if err != nil {
+		t.Fatalf("downloadImage() error = %v", err)
+	}
+	if requests != 2 || !bytes.Equal(image.Content, page) {
+		t.Errorf("Expected the damaged page to be downloaded again, %d requests made", requests)
+	}
+}
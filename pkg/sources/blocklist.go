@@ -0,0 +1,152 @@
+package sources
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+// Blocklist hides the chapters of blocked scanlation groups and uploaders
+type Blocklist struct {
+	groups    map[string]bool // Lowercased group IDs and names
+	uploaders map[string]bool // Lowercased uploader IDs
+}
+
+// NewBlocklist creates a blocklist from stored entries
+func NewBlocklist(entries []data.BlockedGroup) *Blocklist {
+	b := &Blocklist{
+		groups:    make(map[string]bool),
+		uploaders: make(map[string]bool),
+	}
+	for _, entry := range entries {
+		value := strings.ToLower(strings.TrimSpace(entry.Value))
+		if value == "" {
+			continue
+		}
+		if entry.Kind == data.BlockUploader {
+			b.uploaders[value] = true
+		} else {
+			b.groups[value] = true
+		}
+	}
+	return b
+}
+
+// Empty reports whether nothing is blocked
+func (b *Blocklist) Empty() bool {
+	return b == nil || (len(b.groups) == 0 && len(b.uploaders) == 0)
+}
+
+// Blocks reports whether a chapter was released by a blocked group or uploader
+func (b *Blocklist) Blocks(chapter *data.Chapter) bool {
+	if b.Empty() {
+		return false
+	}
+	for _, groups := range [][]string{chapter.GroupIDs, chapter.Groups} {
+		for _, group := range groups {
+			if b.groups[strings.ToLower(group)] {
+				return true
+			}
+		}
+	}
+	return chapter.UploaderID != "" && b.uploaders[strings.ToLower(chapter.UploaderID)]
+}
+
+// Filter returns the chapters that are not blocked
+func (b *Blocklist) Filter(chapters []*data.Chapter) []*data.Chapter {
+	if b.Empty() {
+		return chapters
+	}
+	filtered := make([]*data.Chapter, 0, len(chapters))
+	for _, ch := range chapters {
+		if !b.Blocks(ch) {
+			filtered = append(filtered, ch)
+		}
+	}
+	return filtered
+}
+
+// GroupIDs returns the blocked groups given by ID, for sources that can
+// exclude them server-side
+func (b *Blocklist) GroupIDs() []string {
+	if b == nil {
+		return nil
+	}
+	return ids(b.groups)
+}
+
+// UploaderIDs returns the blocked uploaders given by ID
+func (b *Blocklist) UploaderIDs() []string {
+	if b == nil {
+		return nil
+	}
+	return ids(b.uploaders)
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// ids returns the values that are IDs rather than names
+func ids(values map[string]bool) []string {
+	var ids []string
+	for value := range values {
+		if uuidPattern.MatchString(value) {
+			ids = append(ids, value)
+		}
+	}
+	return ids
+}
+
+// GroupBlocker is implemented by sources that hide the chapters of blocked
+// groups and uploaders
+type GroupBlocker interface {
+	SetBlocklist(blocklist *Blocklist)
+}
+
+// ValidateBlockedGroup checks that uploaders are given by ID, as the names
+// of uploaders are not available in chapter lists
+func ValidateBlockedGroup(entry data.BlockedGroup) error {
+	if entry.Value == "" {
+		return fmt.Errorf("empty %s", entry.Kind)
+	}
+	if entry.Kind == data.BlockUploader && !uuidPattern.MatchString(strings.ToLower(entry.Value)) {
+		return fmt.Errorf("uploaders must be given by ID, got %q", entry.Value)
+	}
+	return nil
+}
+
+// ParseBlocklist reads a blocklist file. Each line holds a group ID or name,
+// optionally prefixed with its kind, followed by an optional comment:
+//
+//	# Groups known for machine translations
+//	group 5fed0576-8b94-4f9a-b6a7-08eecd69800d  # Example Scans
+//	uploader 2c0b6b6a-0bc5-4c39-9b4c-1b2b2b2b2b2b
+//	Lazy Scans
+func ParseBlocklist(r io.Reader) ([]data.BlockedGroup, error) {
+	var entries []data.BlockedGroup
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, note, _ := strings.Cut(scanner.Text(), "#")
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+
+		entry := data.BlockedGroup{Kind: data.BlockGroup, Value: text, Note: strings.TrimSpace(note)}
+		if kind, value, ok := strings.Cut(text, " "); ok {
+			switch strings.ToLower(kind) {
+			case data.BlockGroup, data.BlockUploader:
+				entry.Kind = strings.ToLower(kind)
+				entry.Value = strings.TrimSpace(value)
+			}
+		}
+		if err := ValidateBlockedGroup(entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,75 @@
+package sources
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	blockedGroupID    = "5fed0576-8b94-4f9a-b6a7-08eecd69800d"
+	blockedUploaderID = "2c0b6b6a-0bc5-4c39-9b4c-1b2b2b2b2b2b"
+)
+
+func TestParseBlocklist(t *testing.T) {
+	entries, err := ParseBlocklist(strings.NewReader(`
+# Shared blocklist
+group ` + strings.ToUpper(blockedGroupID) + `  # Example Scans
+uploader ` + blockedUploaderID + `
+Lazy Scans
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, []data.BlockedGroup{
+		{Kind: data.BlockGroup, Value: strings.ToUpper(blockedGroupID), Note: "Example Scans"},
+		{Kind: data.BlockUploader, Value: blockedUploaderID},
+		{Kind: data.BlockGroup, Value: "Lazy Scans"},
+	}, entries)
+
+	_, err = ParseBlocklist(strings.NewReader("uploader someone"))
+	assert.ErrorContains(t, err, "line 1")
+}
+
+func TestBlocklist_Filter(t *testing.T) {
+	blocklist := NewBlocklist([]data.BlockedGroup{
+		{Kind: data.BlockGroup, Value: strings.ToUpper(blockedGroupID)},
+		{Kind: data.BlockGroup, Value: "Lazy Scans"},
+		{Kind: data.BlockUploader, Value: blockedUploaderID},
+	})
+
+	chapters := []*data.Chapter{
+		{ID: "by-id", GroupIDs: []string{"other-group", blockedGroupID}},
+		{ID: "by-name", Groups: []string{"lazy scans"}},
+		{ID: "by-uploader", UploaderID: blockedUploaderID},
+		{ID: "kept", GroupIDs: []string{"other-group"}, Groups: []string{"Good Scans"}},
+		{ID: "no-group"},
+	}
+
+	var kept []string
+	for _, ch := range blocklist.Filter(chapters) {
+		kept = append(kept, ch.ID)
+	}
+	assert.Equal(t, []string{"kept", "no-group"}, kept)
+	assert.Equal(t, []string{blockedGroupID}, blocklist.GroupIDs(), "names can't be excluded server-side")
+	assert.Equal(t, []string{blockedUploaderID}, blocklist.UploaderIDs())
+
+	var empty *Blocklist
+	assert.Len(t, empty.Filter(chapters), len(chapters))
+	assert.Empty(t, empty.GroupIDs())
+	assert.Empty(t, empty.UploaderIDs())
+}
+
+func TestRegistry_SetBlocklist(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("mangadex", func() Source { return NewMangaDex() })
+	registry.Register("comick", func() Source { return NewComick() })
+
+	before, _ := registry.Get("mangadex")
+	blocklist := NewBlocklist([]data.BlockedGroup{{Kind: data.BlockGroup, Value: "Lazy Scans"}})
+	registry.SetBlocklist(blocklist)
+	after, _ := registry.Get("comick")
+
+	assert.Same(t, blocklist, before.(*MangaDex).blocklist)
+	assert.Same(t, blocklist, after.(*Comick).blocklist)
+}
@@ -0,0 +1,251 @@
+package sources
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// ComickCover is a cover entry of a Comick comic
+type ComickCover struct {
+	B2Key string `json:"b2key"`
+}
+
+// ComickComic is a comic as returned by the Comick API
+type ComickComic struct {
+	HID      string        `json:"hid"`
+	Slug     string        `json:"slug"`
+	Title    string        `json:"title"`
+	Desc     string        `json:"desc"`
+	MDCovers []ComickCover `json:"md_covers"`
+}
+
+func (c *ComickComic) ToManga() *data.Manga {
+	return &data.Manga{
+		ID:          c.HID,
+		Name:        c.Title,
+		Description: c.Desc,
+		Source:      "comick",
+		Status:      "",
+		URL:         comickComicURL(c.Slug),
+	}
+}
+
+// ComickChapter is a chapter as returned by the Comick API
+type ComickChapter struct {
+	HID      string   `json:"hid"`
+	Title    string   `json:"title"`
+	Language string   `json:"lang"`
+	Volume   string   `json:"vol"`
+	Number   string   `json:"chap"`
+	Groups   []string `json:"group_name"`
+}
+
+func (c *ComickChapter) ToChapter() *data.Chapter {
+	return &data.Chapter{
+		ID:         c.HID,
+		Title:      c.Title,
+		Language:   c.Language,
+		Volume:     c.Volume,
+		Number:     utils.ChapterNumber(c.Number, c.Title),
+		Downloaded: false,
+		FilePath:   "",
+		Groups:     c.Groups,
+	}
+}
+
+func comickComicURL(slug string) string {
+	if slug == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://comick.io/comic/%s", slug)
+}
+
+type Comick struct {
+	api       *utils.API
+	imageHost string
+
+	mu        sync.Mutex
+	blocklist *Blocklist // Guarded by mu
+}
+
+// SetBlocklist hides the chapters of blocked groups, matched by name
+func (c *Comick) SetBlocklist(blocklist *Blocklist) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.blocklist = blocklist
+}
+
+// comickStatuses maps publication statuses to the status codes of the API
+var comickStatuses = map[string]string{
+	"ongoing":   "1",
+	"completed": "2",
+	"cancelled": "3",
+	"hiatus":    "4",
+}
+
+// comickOrders maps search orders to the sort parameter of the API, orders
+// it can't sort in are left to the default
+var comickOrders = map[string]string{
+	"latest":  "uploaded",
+	"follows": "user_follow_count",
+	"rating":  "rating",
+}
+
+// Search finds comics by title. Comick can't filter on the language of the
+// chapters, the other options are passed on.
+func (c *Comick) Search(query string, options SearchOptions) ([]*data.Manga, error) {
+	params := url.Values{
+		"q":     {query},
+		"limit": {"10"},
+	}
+	if status, ok := comickStatuses[options.Status]; ok {
+		params.Set("status", status)
+	}
+	if options.Year > 0 {
+		params.Set("from", strconv.Itoa(options.Year))
+		params.Set("to", strconv.Itoa(options.Year))
+	}
+	if len(options.ContentRating) > 0 {
+		params["content_rating"] = options.ContentRating
+	}
+	if sort, ok := comickOrders[options.Order]; ok {
+		params.Set("sort", sort)
+	}
+	var comics []ComickComic
+	if err := c.api.Get("/v1.0/search", params, &comics); err != nil {
+		return nil, err
+	}
+	out := make([]*data.Manga, len(comics))
+	for i, comic := range comics {
+		out[i] = comic.ToManga()
+	}
+	return out, nil
+}
+
+func (c *Comick) GetManga(id string) (*data.Manga, error) {
+	comic, err := c.getComic(id)
+	if err != nil {
+		return nil, err
+	}
+	return comic.ToManga(), nil
+}
+
+func (c *Comick) GetChapters(manga *data.Manga) ([]*data.Chapter, error) {
+	return c.GetChaptersIn(manga)
+}
+
+// comickFeedLimit is the size of the pages the chapter feed is read in
+const comickFeedLimit = 300
+
+// GetChaptersIn returns the chapters of a comic translated to one of
+// languages, or all of them when none is given. The feed filters on one
+// language at a time, so each one is read on its own, page by page.
+func (c *Comick) GetChaptersIn(manga *data.Manga, languages ...string) ([]*data.Chapter, error) {
+	if len(languages) == 0 {
+		languages = []string{""}
+	}
+	var out []*data.Chapter
+	for _, language := range languages {
+		chapters, err := c.chapterFeed(manga.ID, language)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, chapters...)
+	}
+	c.mu.Lock()
+	blocklist := c.blocklist
+	c.mu.Unlock()
+	return blocklist.Filter(out), nil
+}
+
+// chapterFeed reads every page of the chapter feed of a comic, in language
+// when not empty
+func (c *Comick) chapterFeed(id, language string) ([]*data.Chapter, error) {
+	var out []*data.Chapter
+	for page := 1; ; page++ {
+		var feed struct {
+			Chapters []ComickChapter `json:"chapters"`
+			Total    int             `json:"total"`
+		}
+		params := url.Values{
+			"page":  {strconv.Itoa(page)},
+			"limit": {strconv.Itoa(comickFeedLimit)},
+		}
+		if language != "" {
+			params.Set("lang", language)
+		}
+		if err := c.api.Get(fmt.Sprintf("/comic/%s/chapters", id), params, &feed); err != nil {
+			return nil, err
+		}
+		for _, chapter := range feed.Chapters {
+			out = append(out, chapter.ToChapter())
+		}
+		if len(feed.Chapters) < comickFeedLimit || (feed.Total > 0 && len(out) >= feed.Total) {
+			return out, nil
+		}
+	}
+}
+
+func (c *Comick) GetPages(_ *data.Manga, chapter *data.Chapter) ([]string, error) {
+	var resp struct {
+		Chapter struct {
+			Images []ComickCover `json:"md_images"`
+		} `json:"chapter"`
+	}
+	if err := c.api.Get(fmt.Sprintf("/chapter/%s", chapter.ID), nil, &resp); err != nil {
+		return nil, err
+	}
+	pages := make([]string, len(resp.Chapter.Images))
+	for i, image := range resp.Chapter.Images {
+		pages[i] = c.imageURL(image.B2Key)
+	}
+	return pages, nil
+}
+
+// GetMangaCoverURL returns the URL of the first cover of the comic
+func (c *Comick) GetMangaCoverURL(manga *data.Manga) (string, error) {
+	comic, err := c.getComic(manga.ID)
+	if err != nil {
+		return "", err
+	}
+	if len(comic.MDCovers) == 0 || comic.MDCovers[0].B2Key == "" {
+		return "", fmt.Errorf("no cover art found for manga")
+	}
+	return c.imageURL(comic.MDCovers[0].B2Key), nil
+}
+
+// GetChapterCoverURL returns the manga cover, Comick has no chapter covers
+func (c *Comick) GetChapterCoverURL(manga *data.Manga, _ *data.Chapter) (string, error) {
+	return c.GetMangaCoverURL(manga)
+}
+
+// getComic fetches a comic by hid or slug
+func (c *Comick) getComic(id string) (*ComickComic, error) {
+	var resp struct {
+		Comic ComickComic `json:"comic"`
+	}
+	if err := c.api.Get(fmt.Sprintf("/comic/%s", id), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Comic, nil
+}
+
+func (c *Comick) imageURL(key string) string {
+	return fmt.Sprintf("%s/%s", c.imageHost, key)
+}
+
+func init() {
+	Register("comick", NewComick)
+}
+
+func NewComick() Source {
+	return &Comick{
+		api:       utils.NewSourceAPI("comick", "https://api.comick.fun"),
+		imageHost: "https://meo.comick.pictures",
+	}
+}
@@ -0,0 +1,121 @@
+package sources
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestComick(t *testing.T, handler http.HandlerFunc) *Comick {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &Comick{api: utils.NewAPI(server.URL), imageHost: "https://images.test"}
+}
+
+func TestComick_ImplementsSource(t *testing.T) {
+	assert.Implements(t, new(Source), NewComick())
+}
+
+func TestComick_Search(t *testing.T) {
+	comick := newTestComick(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1.0/search", r.URL.Path)
+		assert.Equal(t, "naruto", r.URL.Query().Get("q"))
+		w.Write([]byte(`[{"hid":"abc","slug":"naruto","title":"Naruto","desc":"Ninjas"}]`))
+	})
+
+	mangas, err := comick.Search("naruto", SearchOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, mangas, 1)
+	assert.Equal(t, "abc", mangas[0].ID)
+	assert.Equal(t, "Naruto", mangas[0].Name)
+	assert.Equal(t, "comick", mangas[0].Source)
+}
+
+func TestComick_SearchOptions(t *testing.T) {
+	comick := newTestComick(t, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		assert.Equal(t, "2", query.Get("status"))
+		assert.Equal(t, "2020", query.Get("from"))
+		assert.Equal(t, "2020", query.Get("to"))
+		assert.Equal(t, "uploaded", query.Get("sort"))
+		w.Write([]byte(`[]`))
+	})
+
+	_, err := comick.Search("naruto", SearchOptions{Status: "completed", Year: 2020, Order: "latest"})
+	assert.NoError(t, err)
+}
+
+func TestSearchOptions_Validate(t *testing.T) {
+	assert.NoError(t, SearchOptions{}.Validate())
+	assert.NoError(t, SearchOptions{Status: "hiatus", ContentRating: []string{"safe"}, Order: "title", Year: 2001}.Validate())
+	assert.Error(t, SearchOptions{Status: "finished"}.Validate())
+	assert.Error(t, SearchOptions{ContentRating: []string{"nsfw"}}.Validate())
+	assert.Error(t, SearchOptions{Order: "random"}.Validate())
+	assert.Equal(t, "en, completed, 2020, by latest", SearchOptions{Language: "en", Status: "completed", Year: 2020, Order: "latest"}.Summary())
+}
+
+func TestComick_GetChapters(t *testing.T) {
+	comick := newTestComick(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/comic/abc/chapters", r.URL.Path)
+		w.Write([]byte(`{"chapters":[{"hid":"ch1","chap":"1","vol":"1","title":"Start","lang":"en"}]}`))
+	})
+
+	chapters, err := comick.GetChapters(&data.Manga{ID: "abc"})
+	assert.NoError(t, err)
+	assert.Len(t, chapters, 1)
+	assert.Equal(t, "ch1", chapters[0].ID)
+	assert.Equal(t, "1", chapters[0].Number)
+	assert.Equal(t, "en", chapters[0].Language)
+}
+
+func TestComick_GetChaptersPaginates(t *testing.T) {
+	var pages []string
+	comick := newTestComick(t, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		assert.Equal(t, "es", query.Get("lang"))
+		pages = append(pages, query.Get("page"))
+		if query.Get("page") == "2" {
+			w.Write([]byte(`{"chapters":[{"hid":"last","chap":"301","lang":"es"}],"total":301}`))
+			return
+		}
+		chapters := make([]string, comickFeedLimit)
+		for i := range chapters {
+			chapters[i] = fmt.Sprintf(`{"hid":"ch%d","chap":"%d","lang":"es"}`, i+1, i+1)
+		}
+		fmt.Fprintf(w, `{"chapters":[%s],"total":301}`, strings.Join(chapters, ","))
+	})
+
+	chapters, err := comick.GetChaptersIn(&data.Manga{ID: "abc"}, "es")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, pages)
+	assert.Len(t, chapters, 301)
+	assert.Equal(t, "301", chapters[300].Number)
+}
+
+func TestComick_GetPagesAndCover(t *testing.T) {
+	comick := newTestComick(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/chapter/ch1":
+			w.Write([]byte(`{"chapter":{"md_images":[{"b2key":"p1.jpg"},{"b2key":"p2.jpg"}]}}`))
+		case "/comic/abc":
+			w.Write([]byte(`{"comic":{"hid":"abc","md_covers":[{"b2key":"cover.jpg"}]}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	pages, err := comick.GetPages(nil, &data.Chapter{ID: "ch1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://images.test/p1.jpg", "https://images.test/p2.jpg"}, pages)
+
+	cover, err := comick.GetMangaCoverURL(&data.Manga{ID: "abc"})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://images.test/cover.jpg", cover)
+}
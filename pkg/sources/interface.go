@@ -4,11 +4,99 @@ import (
 	"github.com/kerbaras/mangas/pkg/data"
 )
 
+// Source is a website manga are fetched from. The registry shares one
+// instance of each source with every goroutine, implementations must be safe
+// for concurrent use.
 type Source interface {
-	Search(query string) ([]*data.Manga, error)
+	Search(query string, options SearchOptions) ([]*data.Manga, error)
 	GetManga(id string) (*data.Manga, error)
 	GetChapters(manga *data.Manga) ([]*data.Chapter, error)
 	GetPages(manga *data.Manga, chapter *data.Chapter) ([]string, error)
 	GetMangaCoverURL(manga *data.Manga) (string, error)
 	GetChapterCoverURL(manga *data.Manga, chapter *data.Chapter) (string, error)
 }
+
+// Recommender is implemented by sources that can list popular manga by tag
+type Recommender interface {
+	Popular(tags []string, limit int) ([]*data.Manga, error)
+}
+
+// RelationProvider is implemented by sources that know about related series
+type RelationProvider interface {
+	GetRelations(manga *data.Manga) ([]*data.Relation, error)
+}
+
+// ArchivePasswordProvider is implemented by sources that deliver chapters as
+// password-protected archives
+type ArchivePasswordProvider interface {
+	ArchivePasswords(manga *data.Manga, chapter *data.Chapter) []string
+}
+
+// Linker is implemented by sources that can build the canonical web page of
+// a manga or chapter, for entries stored without one
+type Linker interface {
+	MangaURL(manga *data.Manga) string
+	ChapterURL(manga *data.Manga, chapter *data.Chapter) string
+}
+
+// LanguageFilterer is implemented by sources that can restrict a chapter feed
+// to some languages themselves, instead of sending every translation
+type LanguageFilterer interface {
+	GetChaptersIn(manga *data.Manga, languages ...string) ([]*data.Chapter, error)
+}
+
+// FollowsProvider is implemented by sources with user accounts, listing the
+// manga the logged in user follows with their reading status
+type FollowsProvider interface {
+	Follows() ([]*data.Manga, error)
+}
+
+// GetChaptersIn returns the chapters of a manga translated to one of
+// languages, asking the source to filter them when it can. No languages
+// returns every chapter.
+func GetChaptersIn(source Source, manga *data.Manga, languages ...string) ([]*data.Chapter, error) {
+	if len(languages) == 0 {
+		return source.GetChapters(manga)
+	}
+	if filterer, ok := source.(LanguageFilterer); ok {
+		return filterer.GetChaptersIn(manga, languages...)
+	}
+
+	chapters, err := source.GetChapters(manga)
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(languages))
+	for _, language := range languages {
+		wanted[language] = true
+	}
+	var filtered []*data.Chapter
+	for _, chapter := range chapters {
+		if wanted[chapter.Language] {
+			filtered = append(filtered, chapter)
+		}
+	}
+	return filtered, nil
+}
+
+// MangaURL returns the stored page of a manga, or builds it from the source
+func MangaURL(source Source, manga *data.Manga) string {
+	if manga.URL != "" {
+		return manga.URL
+	}
+	if linker, ok := source.(Linker); ok {
+		return linker.MangaURL(manga)
+	}
+	return ""
+}
+
+// ChapterURL returns the stored page of a chapter, or builds it from the source
+func ChapterURL(source Source, manga *data.Manga, chapter *data.Chapter) string {
+	if chapter.URL != "" {
+		return chapter.URL
+	}
+	if linker, ok := source.(Linker); ok {
+		return linker.ChapterURL(manga, chapter)
+	}
+	return ""
+}
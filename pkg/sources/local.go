@@ -0,0 +1,305 @@
+package sources
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/utils"
+)
+
+// LocalDirEnv overrides the directory the local source reads, ~/.mangas/local
+// by default
+const LocalDirEnv = "MANGAS_LOCAL_DIR"
+
+// localLanguage is the language of local chapters when none is asked for
+const localLanguage = "en"
+
+var (
+	localImageExtensions   = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".webp": true, ".gif": true}
+	localArchiveExtensions = map[string]bool{".cbz": true, ".zip": true, ".cbr": true, ".rar": true, ".cb7": true, ".7z": true}
+
+	// "Vol. 3", "Volume 3", "v03"
+	localVolumePattern = regexp.MustCompile(`(?i)(?:^|[^\p{L}])(?:volume|vol|v)\.?\s*(\d+)`)
+	localNumberPattern = regexp.MustCompile(`\d+(?:\.\d+)?`)
+)
+
+// LocalSource is implemented by sources reading manga from disk. Their pages
+// are file:// paths under Root, the downloader reads no local file for other
+// sources.
+type LocalSource interface {
+	Root() string
+}
+
+// Local is a source reading a directory of scans: every directory under its
+// root is a manga, and its chapters are the directories of images or the
+// CBZ, RAR and 7z archives in it. Images right in the manga directory are a
+// single chapter.
+type Local struct {
+	root string
+}
+
+// NewLocal creates the local source, reading the directory of LocalDirEnv or
+// ~/.mangas/local
+func NewLocal() Source {
+	root := os.Getenv(LocalDirEnv)
+	if root == "" {
+		homeDir, _ := os.UserHomeDir()
+		root = filepath.Join(homeDir, ".mangas", "local")
+	}
+	return NewLocalAt(root)
+}
+
+// NewLocalAt creates a local source reading root
+func NewLocalAt(root string) *Local {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	return &Local{root: root}
+}
+
+// Root returns the directory the source reads
+func (l *Local) Root() string {
+	return l.root
+}
+
+// Search returns the manga whose directory name contains every word of query
+func (l *Local) Search(query string, options SearchOptions) ([]*data.Manga, error) {
+	entries, err := os.ReadDir(l.root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("local directory %s does not exist, set %s to read another one", l.root, LocalDirEnv)
+		}
+		return nil, err
+	}
+
+	words := strings.Fields(strings.ToLower(query))
+	var results []*data.Manga
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		name := strings.ToLower(entry.Name())
+		matches := true
+		for _, word := range words {
+			if !strings.Contains(name, word) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			results = append(results, l.manga(entry.Name()))
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return utils.NaturalLess(results[i].Name, results[j].Name) })
+	return results, nil
+}
+
+// GetManga returns the manga of a directory under the root
+func (l *Local) GetManga(id string) (*data.Manga, error) {
+	if id == "." || strings.ContainsAny(id, `/\`) {
+		return nil, fmt.Errorf("invalid local ID %q", id)
+	}
+	dir, err := l.path(id)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("manga not found: %s", id)
+	}
+	return l.manga(id), nil
+}
+
+// GetChapters returns the chapters of a manga, sorted by name
+func (l *Local) GetChapters(manga *data.Manga) ([]*data.Chapter, error) {
+	return l.GetChaptersIn(manga)
+}
+
+// GetChaptersIn returns the chapters of a manga. Scans on disk carry no
+// language, they are in the first language asked for.
+func (l *Local) GetChaptersIn(manga *data.Manga, languages ...string) ([]*data.Chapter, error) {
+	language := localLanguage
+	if len(languages) > 0 {
+		language = languages[0]
+	}
+
+	dir, err := l.path(manga.ID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	var names []string
+	hasImages := false
+	for _, entry := range entries {
+		name := entry.Name()
+		switch {
+		case strings.HasPrefix(name, "."):
+		case entry.IsDir():
+			if images, _ := localImages(filepath.Join(dir, name)); len(images) > 0 {
+				names = append(names, name)
+			}
+		case localArchiveExtensions[strings.ToLower(filepath.Ext(name))]:
+			names = append(names, name)
+		case isLocalPage(name):
+			hasImages = true
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return utils.NaturalLess(names[i], names[j]) })
+
+	var chapters []*data.Chapter
+	for i, name := range names {
+		chapters = append(chapters, localChapter(manga.ID, name, i+1, language))
+	}
+	if len(chapters) == 0 && hasImages {
+		chapters = append(chapters, &data.Chapter{ID: manga.ID, MangaID: manga.ID, Title: manga.Name, Number: "1", Language: language})
+	}
+	return chapters, nil
+}
+
+// GetPages returns the file:// paths of the images of a chapter sorted by
+// name, or of its archive, which the downloader unpacks
+func (l *Local) GetPages(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+	path, err := l.path(chapter.ID)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("chapter not found: %s", chapter.ID)
+	}
+	if !info.IsDir() {
+		return []string{localPageURL(path)}, nil
+	}
+
+	images, err := localImages(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(images) == 0 {
+		return nil, fmt.Errorf("no images in %s", path)
+	}
+	pages := make([]string, len(images))
+	for i, image := range images {
+		pages[i] = localPageURL(image)
+	}
+	return pages, nil
+}
+
+// GetMangaCoverURL returns the path of the cover image of the manga
+// directory, or of the first page of its first chapter of images
+func (l *Local) GetMangaCoverURL(manga *data.Manga) (string, error) {
+	dir, err := l.path(manga.ID)
+	if err != nil {
+		return "", err
+	}
+	for _, ext := range []string{".jpg", ".jpeg", ".png", ".webp"} {
+		cover := filepath.Join(dir, "cover"+ext)
+		if _, err := os.Stat(cover); err == nil {
+			return cover, nil
+		}
+	}
+
+	chapters, err := l.GetChapters(manga)
+	if err != nil {
+		return "", err
+	}
+	for _, chapter := range chapters {
+		path, _ := l.path(chapter.ID)
+		if images, _ := localImages(path); len(images) > 0 {
+			return images[0], nil
+		}
+	}
+	return "", fmt.Errorf("no cover for %s", manga.Name)
+}
+
+// GetChapterCoverURL returns no cover, chapters use the manga's
+func (l *Local) GetChapterCoverURL(manga *data.Manga, chapter *data.Chapter) (string, error) {
+	return "", nil
+}
+
+func (l *Local) manga(name string) *data.Manga {
+	return &data.Manga{ID: name, Name: name, Source: "local"}
+}
+
+// path returns the path of an ID under the root, refusing IDs leaving it
+func (l *Local) path(id string) (string, error) {
+	if id == "" || filepath.IsAbs(id) {
+		return "", fmt.Errorf("invalid local ID %q", id)
+	}
+	return utils.SafeJoin(l.root, filepath.FromSlash(id))
+}
+
+// localChapter returns the chapter of an entry of a manga directory, numbered
+// after its name, or its position when the name has no number
+func localChapter(mangaID, name string, position int, language string) *data.Chapter {
+	title := name
+	if localArchiveExtensions[strings.ToLower(filepath.Ext(name))] {
+		title = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+
+	number := utils.InferChapterNumber(title)
+	withoutVolume := localVolumePattern.ReplaceAllString(title, " ")
+	if number == "" {
+		if numbers := localNumberPattern.FindAllString(withoutVolume, -1); len(numbers) > 0 {
+			number = utils.NormalizeChapterNumber(numbers[len(numbers)-1])
+		}
+	}
+	if number == "" {
+		number = strconv.Itoa(position)
+	}
+
+	volume := ""
+	if match := localVolumePattern.FindStringSubmatch(title); match != nil {
+		volume = strings.TrimLeft(match[1], "0")
+	}
+	return &data.Chapter{
+		ID:       mangaID + "/" + name,
+		MangaID:  mangaID,
+		Title:    title,
+		Number:   number,
+		Volume:   volume,
+		Language: language,
+	}
+}
+
+// localImages returns the paths of the images of a directory, sorted by name
+func localImages(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var images []string
+	for _, entry := range entries {
+		if !entry.IsDir() && isLocalPage(entry.Name()) {
+			images = append(images, entry.Name())
+		}
+	}
+	sort.Slice(images, func(i, j int) bool { return utils.NaturalLess(images[i], images[j]) })
+	for i, image := range images {
+		images[i] = filepath.Join(dir, image)
+	}
+	return images, nil
+}
+
+// isLocalPage reports whether a file is a page image, covers are not
+func isLocalPage(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return localImageExtensions[ext] && !strings.HasPrefix(name, ".") && strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name))) != "cover"
+}
+
+func localPageURL(path string) string {
+	return "file://" + filepath.ToSlash(path)
+}
+
+func init() {
+	Register("local", NewLocal)
+}
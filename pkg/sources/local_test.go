@@ -0,0 +1,97 @@
+package sources
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeLocalFiles creates empty files at the slash separated paths under root
+func writeLocalFiles(t *testing.T, root string, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		path = filepath.Join(root, filepath.FromSlash(path))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte("x"), 0644))
+	}
+}
+
+func TestLocal_ImplementsSource(t *testing.T) {
+	assert.Implements(t, new(Source), NewLocal())
+	assert.Implements(t, new(LocalSource), NewLocal())
+}
+
+func TestLocal_Scan(t *testing.T) {
+	root := t.TempDir()
+	writeLocalFiles(t, root,
+		"Berserk/cover.jpg",
+		"Berserk/Vol. 2 Chapter 10/2.png",
+		"Berserk/Vol. 2 Chapter 10/10.png",
+		"Berserk/Vol. 1 Chapter 9.5/01.jpg",
+		"Berserk/Berserk 011.cbz",
+		"Berserk/notes.txt",
+		"Berserk/empty/readme.txt",
+		"One Shot/001.png",
+		"One Shot/002.png",
+	)
+	local := NewLocalAt(root)
+
+	mangas, err := local.Search("berserk", SearchOptions{})
+	require.NoError(t, err)
+	require.Len(t, mangas, 1)
+	assert.Equal(t, "Berserk", mangas[0].ID)
+	assert.Equal(t, "local", mangas[0].Source)
+	mangas, err = local.Search("", SearchOptions{})
+	require.NoError(t, err)
+	assert.Len(t, mangas, 2)
+
+	chapters, err := local.GetChaptersIn(mangas[0], "es")
+	require.NoError(t, err)
+	require.Len(t, chapters, 3)
+	assert.Equal(t, "Berserk/Berserk 011.cbz", chapters[0].ID)
+	assert.Equal(t, "11", chapters[0].Number)
+	assert.Equal(t, "es", chapters[0].Language)
+	assert.Equal(t, "9.5", chapters[1].Number)
+	assert.Equal(t, "1", chapters[1].Volume)
+	assert.Equal(t, "10", chapters[2].Number)
+	assert.Equal(t, "2", chapters[2].Volume)
+
+	pages, err := local.GetPages(mangas[0], chapters[2])
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"file://" + filepath.ToSlash(filepath.Join(root, "Berserk", "Vol. 2 Chapter 10", "2.png")),
+		"file://" + filepath.ToSlash(filepath.Join(root, "Berserk", "Vol. 2 Chapter 10", "10.png")),
+	}, pages)
+	pages, err = local.GetPages(mangas[0], chapters[0])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"file://" + filepath.ToSlash(filepath.Join(root, "Berserk", "Berserk 011.cbz"))}, pages)
+
+	cover, err := local.GetMangaCoverURL(mangas[0])
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "Berserk", "cover.jpg"), cover)
+
+	oneShot, err := local.GetManga("One Shot")
+	require.NoError(t, err)
+	chapters, err = local.GetChapters(oneShot)
+	require.NoError(t, err)
+	require.Len(t, chapters, 1)
+	assert.Equal(t, "en", chapters[0].Language)
+	pages, err = local.GetPages(oneShot, chapters[0])
+	require.NoError(t, err)
+	assert.Len(t, pages, 2)
+}
+
+func TestLocal_RefusesPathsOutsideRoot(t *testing.T) {
+	root := t.TempDir()
+	writeLocalFiles(t, root, "Manga/1/1.png")
+	local := NewLocalAt(filepath.Join(root, "Manga"))
+
+	_, err := local.GetManga("..")
+	assert.Error(t, err)
+	_, err = local.GetPages(&data.Manga{ID: "1"}, &data.Chapter{ID: "../../Manga/1"})
+	assert.Error(t, err)
+}
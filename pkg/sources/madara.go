@@ -0,0 +1,394 @@
+package sources
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/utils"
+	"golang.org/x/net/html"
+	"gopkg.in/yaml.v3"
+)
+
+// SitesDirEnv overrides the directory site definitions are read from,
+// ~/.mangas/sources by default
+const SitesDirEnv = "MANGAS_SOURCES_DIR"
+
+// MadaraSite defines a site built on the Madara WordPress theme, read from a
+// YAML file. Only the name and base URL are required, the selectors default
+// to the theme's markup.
+type MadaraSite struct {
+	Name      string          `yaml:"name"`       // Source name, as given to --source
+	BaseURL   string          `yaml:"base_url"`   // e.g. https://example.com
+	Language  string          `yaml:"language"`   // Language of the chapters, "en" when empty
+	MangaPath string          `yaml:"manga_path"` // Path of the manga pages, "manga" when empty
+	RateLimit float64         `yaml:"rate_limit"` // Requests per second to the site, unlimited when 0
+	Selectors MadaraSelectors `yaml:"selectors"`
+}
+
+// MadaraSelectors are the CSS selectors finding the parts of a site's pages
+type MadaraSelectors struct {
+	Search      string `yaml:"search"`      // Links to manga in search results
+	Title       string `yaml:"title"`       // Title on the manga page
+	Description string `yaml:"description"` // Summary on the manga page
+	Cover       string `yaml:"cover"`       // Cover image on the manga page
+	Chapters    string `yaml:"chapters"`    // Links to chapters on the manga page
+	Pages       string `yaml:"pages"`       // Page images on the chapter page
+}
+
+// defaultMadaraSelectors match the markup of the Madara theme
+var defaultMadaraSelectors = MadaraSelectors{
+	Search:      ".c-tabs-item__content .post-title a, .page-item-detail .post-title a",
+	Title:       ".post-title h1, .post-title h3",
+	Description: ".description-summary .summary__content, .summary__content",
+	Cover:       ".summary_image img",
+	Chapters:    "li.wp-manga-chapter a",
+	Pages:       ".reading-content img",
+}
+
+// Validate checks the definition has a name and an http(s) base URL
+func (s MadaraSite) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if strings.ContainsAny(s.Name, " =/") {
+		return fmt.Errorf("name %q can't hold spaces, = or /", s.Name)
+	}
+	base, err := url.Parse(s.BaseURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return fmt.Errorf("%s: base_url must be an http(s) URL, got %q", s.Name, s.BaseURL)
+	}
+	return nil
+}
+
+// madaraSelectors are the compiled selectors of a site
+type madaraSelectors struct {
+	search, title, description, cover, chapters, pages selector
+}
+
+// Madara is a source scraping a site built on the Madara WordPress theme
+type Madara struct {
+	site      MadaraSite
+	api       *utils.API
+	selectors madaraSelectors
+}
+
+// NewMadara creates the source of a site definition
+func NewMadara(site MadaraSite) (*Madara, error) {
+	if err := site.Validate(); err != nil {
+		return nil, err
+	}
+	site.BaseURL = strings.TrimSuffix(site.BaseURL, "/")
+	if site.Language == "" {
+		site.Language = "en"
+	}
+	site.MangaPath = strings.Trim(site.MangaPath, "/")
+	if site.MangaPath == "" {
+		site.MangaPath = "manga"
+	}
+
+	m := &Madara{site: site, api: utils.NewSourceAPI(site.Name, "")}
+	for _, field := range []struct {
+		sel             *selector
+		value, fallback string
+	}{
+		{&m.selectors.search, site.Selectors.Search, defaultMadaraSelectors.Search},
+		{&m.selectors.title, site.Selectors.Title, defaultMadaraSelectors.Title},
+		{&m.selectors.description, site.Selectors.Description, defaultMadaraSelectors.Description},
+		{&m.selectors.cover, site.Selectors.Cover, defaultMadaraSelectors.Cover},
+		{&m.selectors.chapters, site.Selectors.Chapters, defaultMadaraSelectors.Chapters},
+		{&m.selectors.pages, site.Selectors.Pages, defaultMadaraSelectors.Pages},
+	} {
+		value := field.value
+		if value == "" {
+			value = field.fallback
+		}
+		sel, err := compileSelector(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", site.Name, err)
+		}
+		*field.sel = sel
+	}
+	return m, nil
+}
+
+// Search returns the manga of the site's search results
+func (m *Madara) Search(query string, options SearchOptions) ([]*data.Manga, error) {
+	doc, err := m.get(m.site.BaseURL+"/", url.Values{"s": {query}, "post_type": {"wp-manga"}})
+	if err != nil {
+		return nil, err
+	}
+	var results []*data.Manga
+	seen := make(map[string]bool)
+	for _, link := range m.selectors.search.all(doc) {
+		id := m.mangaID(nodeAttr(link, "href"))
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		results = append(results, &data.Manga{ID: id, Name: nodeText(link), Source: m.site.Name, URL: m.MangaURL(&data.Manga{ID: id})})
+	}
+	return results, nil
+}
+
+// GetManga returns the manga of a page of the site, by its slug
+func (m *Madara) GetManga(id string) (*data.Manga, error) {
+	manga := &data.Manga{ID: id, Source: m.site.Name}
+	manga.URL = m.MangaURL(manga)
+	doc, err := m.get(manga.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	title := m.selectors.title.first(doc)
+	if title == nil {
+		return nil, fmt.Errorf("manga not found: %s", id)
+	}
+	manga.Name = nodeText(title)
+	if description := m.selectors.description.first(doc); description != nil {
+		manga.Description = nodeText(description)
+	}
+	return manga, nil
+}
+
+// GetChapters returns the chapters listed on the manga page, oldest first.
+// Newer versions of the theme load the list separately, it is then fetched
+// from the theme's AJAX endpoints.
+func (m *Madara) GetChapters(manga *data.Manga) ([]*data.Chapter, error) {
+	mangaURL := m.MangaURL(manga)
+	doc, err := m.get(mangaURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	links := m.selectors.chapters.all(doc)
+	if len(links) == 0 {
+		if list, err := m.post(mangaURL+"ajax/chapters/", url.Values{}); err == nil {
+			links = m.selectors.chapters.all(list)
+		}
+	}
+	if len(links) == 0 {
+		if holder := (selector{{{id: "manga-chapters-holder"}}}).first(doc); holder != nil && nodeAttr(holder, "data-id") != "" {
+			form := url.Values{"action": {"manga_get_chapters"}, "manga": {nodeAttr(holder, "data-id")}}
+			if list, err := m.post(m.site.BaseURL+"/wp-admin/admin-ajax.php", form); err == nil {
+				links = m.selectors.chapters.all(list)
+			}
+		}
+	}
+
+	var chapters []*data.Chapter
+	seen := make(map[string]bool)
+	for _, link := range links {
+		href := nodeAttr(link, "href")
+		id := m.chapterID(manga, href)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		title := nodeText(link)
+		number := utils.InferChapterNumber(title)
+		if number == "" {
+			number = utils.InferChapterNumber(strings.ReplaceAll(strings.TrimPrefix(id, manga.ID+"/"), "-", " "))
+		}
+		chapters = append(chapters, &data.Chapter{
+			ID:       id,
+			MangaID:  manga.ID,
+			Title:    title,
+			Number:   number,
+			Language: m.site.Language,
+			URL:      href,
+		})
+	}
+	slices.Reverse(chapters)
+	return chapters, nil
+}
+
+// GetPages returns the page images of the chapter page
+func (m *Madara) GetPages(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+	doc, err := m.get(m.ChapterURL(manga, chapter), url.Values{"style": {"list"}})
+	if err != nil {
+		return nil, err
+	}
+	var pages []string
+	for _, img := range m.selectors.pages.all(doc) {
+		if src := imageSource(img); src != "" {
+			pages = append(pages, m.absolute(src))
+		}
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no pages found for chapter %s", chapter.ID)
+	}
+	return pages, nil
+}
+
+// GetMangaCoverURL returns the cover image of the manga page
+func (m *Madara) GetMangaCoverURL(manga *data.Manga) (string, error) {
+	doc, err := m.get(m.MangaURL(manga), nil)
+	if err != nil {
+		return "", err
+	}
+	if cover := m.selectors.cover.first(doc); cover != nil {
+		if src := imageSource(cover); src != "" {
+			return m.absolute(src), nil
+		}
+	}
+	return "", fmt.Errorf("no cover found for %s", manga.ID)
+}
+
+// GetChapterCoverURL returns the manga cover, chapters have none
+func (m *Madara) GetChapterCoverURL(manga *data.Manga, chapter *data.Chapter) (string, error) {
+	return m.GetMangaCoverURL(manga)
+}
+
+// MangaURL returns the page of a manga on the site
+func (m *Madara) MangaURL(manga *data.Manga) string {
+	if manga.URL != "" {
+		return manga.URL
+	}
+	return fmt.Sprintf("%s/%s/%s/", m.site.BaseURL, m.site.MangaPath, manga.ID)
+}
+
+// ChapterURL returns the page of a chapter on the site
+func (m *Madara) ChapterURL(manga *data.Manga, chapter *data.Chapter) string {
+	if chapter.URL != "" {
+		return chapter.URL
+	}
+	return fmt.Sprintf("%s/%s/%s/", m.site.BaseURL, m.site.MangaPath, chapter.ID)
+}
+
+func (m *Madara) get(pageURL string, params url.Values) (*html.Node, error) {
+	page, err := m.api.GetHTML(pageURL, params)
+	if err != nil {
+		return nil, err
+	}
+	return html.Parse(bytes.NewReader(page))
+}
+
+func (m *Madara) post(pageURL string, form url.Values) (*html.Node, error) {
+	page, err := m.api.PostFormHTML(pageURL, form)
+	if err != nil {
+		return nil, err
+	}
+	return html.Parse(bytes.NewReader(page))
+}
+
+// mangaPath returns the path of a link of the site under the manga pages,
+// "" for other links
+func (m *Madara) mangaPath(link string) string {
+	parsed, err := url.Parse(m.absolute(link))
+	if err != nil {
+		return ""
+	}
+	rest, ok := strings.CutPrefix(strings.Trim(parsed.Path, "/"), m.site.MangaPath+"/")
+	if !ok {
+		return ""
+	}
+	return rest
+}
+
+// mangaID returns the slug of a link to a manga page
+func (m *Madara) mangaID(link string) string {
+	slug, _, _ := strings.Cut(m.mangaPath(link), "/")
+	return slug
+}
+
+// chapterID returns the ID of a link to a chapter page: the manga slug and
+// the chapter slug
+func (m *Madara) chapterID(manga *data.Manga, link string) string {
+	path := m.mangaPath(link)
+	if !strings.HasPrefix(path, manga.ID+"/") {
+		return ""
+	}
+	return path
+}
+
+// absolute resolves a link of the site against its base URL
+func (m *Madara) absolute(link string) string {
+	base, err := url.Parse(m.site.BaseURL + "/")
+	if err != nil {
+		return link
+	}
+	ref, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// imageSource returns the URL of an image, lazy loaded ones included
+func imageSource(img *html.Node) string {
+	for _, attr := range []string{"data-src", "data-lazy-src", "src"} {
+		if src := nodeAttr(img, attr); src != "" && !strings.HasPrefix(src, "data:") {
+			return src
+		}
+	}
+	return ""
+}
+
+// DefaultSitesDir returns where site definitions are read from: the
+// directory of SitesDirEnv, or ~/.mangas/sources
+func DefaultSitesDir() string {
+	if dir := os.Getenv(SitesDirEnv); dir != "" {
+		return dir
+	}
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".mangas", "sources")
+}
+
+// LoadSites registers a Madara source for every YAML site definition of dir.
+// A missing directory has no sites; a definition can't replace a source
+// already registered. Broken definitions are skipped and reported in the
+// error, the others are still registered.
+func LoadSites(dir string) ([]string, error) {
+	var paths []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+		paths = append(paths, matches...)
+	}
+	slices.Sort(paths)
+
+	var loaded []string
+	var errs []error
+	for _, path := range paths {
+		source, err := readSite(path)
+		if err == nil && slices.Contains(Names(), source.site.Name) {
+			err = fmt.Errorf("source %s already exists", source.site.Name)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(path), err))
+			continue
+		}
+		if host := hostOf(source.site.BaseURL); source.site.RateLimit > 0 && host != "" {
+			utils.SharedLimiter.SetLimit(host, source.site.RateLimit, max(1, int(source.site.RateLimit)))
+		}
+		Register(source.site.Name, func() Source { return source })
+		loaded = append(loaded, source.site.Name)
+	}
+	return loaded, errors.Join(errs...)
+}
+
+func readSite(path string) (*Madara, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var site MadaraSite
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&site); err != nil {
+		return nil, fmt.Errorf("invalid site definition: %w", err)
+	}
+	return NewMadara(site)
+}
+
+func hostOf(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return parsed.Hostname()
+}
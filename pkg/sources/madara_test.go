@@ -0,0 +1,142 @@
+package sources
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+func TestSelector(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<div id="main" class="list wide">
+		<ul><li class="item"><a href="/a" data-id="1">A</a></li></ul>
+		<p><a href="/b">B</a></p>
+		<a href="https://other/c">C</a>
+	</div>`))
+	require.NoError(t, err)
+
+	texts := func(s string) []string {
+		sel, err := compileSelector(s)
+		require.NoError(t, err)
+		var texts []string
+		for _, n := range sel.all(doc) {
+			texts = append(texts, nodeText(n))
+		}
+		return texts
+	}
+	assert.Equal(t, []string{"A", "B", "C"}, texts("#main a"))
+	assert.Equal(t, []string{"C"}, texts("div.wide > a"))
+	assert.Equal(t, []string{"A"}, texts("li.item a[data-id=1]"))
+	assert.Equal(t, []string{"A", "B"}, texts("a[href^='/']"))
+	assert.Equal(t, []string{"B", "C"}, texts("p a, a[href*=other]"))
+	assert.Empty(t, texts("ul > a"))
+
+	for _, invalid := range []string{"", "a >", "a[href", "div..x", "a, "} {
+		_, err := compileSelector(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func newTestMadara(t *testing.T, pages map[string]string) *Madara {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.Method+" "+r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(strings.ReplaceAll(page, "{base}", "http://"+r.Host)))
+	}))
+	t.Cleanup(server.Close)
+	madara, err := NewMadara(MadaraSite{Name: "test-madara", BaseURL: server.URL})
+	require.NoError(t, err)
+	return madara
+}
+
+func TestMadara(t *testing.T) {
+	madara := newTestMadara(t, map[string]string{
+		"GET /": `<div class="c-tabs-item__content"><div class="post-title"><h3><a href="{base}/manga/solo-leveling/">Solo Leveling</a></h3></div></div>`,
+		"GET /manga/solo-leveling/": `<div class="post-title"><h1> Solo Leveling </h1></div>
+			<div class="summary_image"><img data-src="{base}/covers/solo.jpg" src="data:image/gif;base64,x"></div>
+			<div class="summary__content"><p>Hunters.</p></div>
+			<div id="manga-chapters-holder" data-id="42"></div>`,
+		"POST /manga/solo-leveling/ajax/chapters/": `<ul>
+			<li class="wp-manga-chapter"><a href="{base}/manga/solo-leveling/chapter-2-5/">Chapter 2.5 </a></li>
+			<li class="wp-manga-chapter"><a href="{base}/manga/solo-leveling/chapter-1/">Prologue</a></li>
+		</ul>`,
+		"GET /manga/solo-leveling/chapter-1/": `<div class="reading-content">
+			<img src=" {base}/pages/1.jpg ">
+			<img data-lazy-src="/pages/2.jpg">
+		</div>`,
+	})
+
+	results, err := madara.Search("solo", SearchOptions{})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "solo-leveling", results[0].ID)
+	assert.Equal(t, "Solo Leveling", results[0].Name)
+	assert.Equal(t, "test-madara", results[0].Source)
+
+	manga, err := madara.GetManga("solo-leveling")
+	require.NoError(t, err)
+	assert.Equal(t, "Solo Leveling", manga.Name)
+	assert.Equal(t, "Hunters.", manga.Description)
+
+	chapters, err := madara.GetChapters(manga)
+	require.NoError(t, err)
+	require.Len(t, chapters, 2)
+	assert.Equal(t, "solo-leveling/chapter-1", chapters[0].ID)
+	assert.Equal(t, "1", chapters[0].Number)
+	assert.Equal(t, "2.5", chapters[1].Number)
+	assert.Equal(t, "en", chapters[1].Language)
+
+	pages, err := madara.GetPages(manga, chapters[0])
+	require.NoError(t, err)
+	require.Len(t, pages, 2)
+	assert.True(t, strings.HasSuffix(pages[0], "/pages/1.jpg"), pages[0])
+	assert.True(t, strings.HasPrefix(pages[1], "http://") && strings.HasSuffix(pages[1], "/pages/2.jpg"), pages[1])
+
+	cover, err := madara.GetMangaCoverURL(manga)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(cover, "/covers/solo.jpg"), cover)
+
+	_, err = madara.GetManga("missing")
+	assert.Error(t, err)
+}
+
+func TestLoadSites(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "site.yaml"), []byte(`
+name: test-site
+base_url: https://example.com/
+language: es
+selectors:
+  pages: "div.page img"
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "typo.yml"), []byte("name: typo\nbase_url: https://example.com\nselector: {}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "builtin.yaml"), []byte("name: mangadex\nbase_url: https://example.com\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nourl.yaml"), []byte("name: nourl\nbase_url: example.com\n"), 0644))
+
+	loaded, err := LoadSites(dir)
+	assert.Equal(t, []string{"test-site"}, loaded)
+	require.Error(t, err)
+	for _, broken := range []string{"typo.yml", "builtin.yaml", "nourl.yaml"} {
+		assert.Contains(t, err.Error(), broken)
+	}
+
+	source, err := Get("test-site")
+	require.NoError(t, err)
+	madara := source.(*Madara)
+	assert.Equal(t, "https://example.com/manga/one/", madara.MangaURL(&data.Manga{ID: "one"}))
+
+	loaded, err = LoadSites(filepath.Join(dir, "missing"))
+	assert.NoError(t, err)
+	assert.Empty(t, loaded)
+}
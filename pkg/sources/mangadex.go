@@ -3,24 +3,60 @@ package sources
 import (
 	"fmt"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/kerbaras/mangas/pkg/data"
 	"github.com/kerbaras/mangas/pkg/utils"
 )
 
+// MangaAttributes holds the attributes of a MangaDex manga
+type MangaAttributes struct {
+	Title       map[string]string `json:"title"`
+	Description map[string]string `json:"description"`
+	Tags        []Tag             `json:"tags"`
+	Year        int               `json:"year"`
+	Status      string            `json:"status"` // Publication status
+}
+
+// Tag is a MangaDex genre/theme/format tag
+type Tag struct {
+	ID         string `json:"id"`
+	Attributes struct {
+		Name  map[string]string `json:"name"`
+		Group string            `json:"group"`
+	} `json:"attributes"`
+}
+
+// Name returns the English name of the tag
+func (t *Tag) Name() string {
+	if name := t.Attributes.Name["en"]; name != "" {
+		return name
+	}
+	for _, v := range t.Attributes.Name {
+		return v
+	}
+	return ""
+}
+
 type Manga struct {
-	ID           string `json:"id"`
-	Attributes   struct {
-		Title       map[string]string `json:"title"`
-		Description map[string]string `json:"description"`
+	ID            string          `json:"id"`
+	Attributes    MangaAttributes `json:"attributes"`
+	Relationships []Relationship  `json:"relationships"`
+}
+
+// Relationship is a reference from a MangaDex entity to another one.
+// Attributes are only filled for types requested through includes[].
+type Relationship struct {
+	Type       string `json:"type"`
+	ID         string `json:"id"`
+	Related    string `json:"related"` // Only set for manga relationships
+	Attributes struct {
+		FileName string            `json:"fileName"`
+		Title    map[string]string `json:"title"`
+		Name     string            `json:"name"` // Author and artist name
 	} `json:"attributes"`
-	Relationships []struct {
-		Type       string `json:"type"`
-		ID         string `json:"id"`
-		Attributes struct {
-			FileName string `json:"fileName"`
-		} `json:"attributes"`
-	} `json:"relationships"`
 }
 
 func (m *Manga) ToManga() *data.Manga {
@@ -41,19 +77,54 @@ func (m *Manga) ToManga() *data.Manga {
 		}
 	}
 
+	var tags, genres []string
+	for _, tag := range m.Attributes.Tags {
+		if name := tag.Name(); name != "" {
+			tags = append(tags, name)
+			if tag.Attributes.Group == "genre" {
+				genres = append(genres, name)
+			}
+		}
+	}
+
+	// Names are only available when requested with includes[]
+	var authors, artists []string
+	for _, rel := range m.Relationships {
+		if rel.Attributes.Name == "" {
+			continue
+		}
+		switch rel.Type {
+		case "author":
+			authors = append(authors, rel.Attributes.Name)
+		case "artist":
+			artists = append(artists, rel.Attributes.Name)
+		}
+	}
+
 	return &data.Manga{
-		ID:          m.ID,
-		Name:        title,
-		Description: description,
-		Source:      "mangadex",
-		Status:      "",
+		ID:                m.ID,
+		Name:              title,
+		Description:       description,
+		Source:            "mangadex",
+		Status:            "",
+		URL:               mangaDexMangaURL(m.ID),
+		Tags:              tags,
+		Genres:            genres,
+		Authors:           authors,
+		Artists:           artists,
+		Year:              m.Attributes.Year,
+		PublicationStatus: m.Attributes.Status,
 	}
 }
 
+// mangaIncludes requests the author and artist names along with a manga
+var mangaIncludes = []string{"author", "artist"}
+
 type Chapter struct {
 	data.Chapter
-	ID         string `json:"id"`
-	Attributes struct {
+	ID            string         `json:"id"`
+	Relationships []Relationship `json:"relationships"`
+	Attributes    struct {
 		Title    string   `json:"title"`
 		Language string   `json:"translatedLanguage"`
 		Hash     string   `json:"hash"`
@@ -65,25 +136,91 @@ type Chapter struct {
 }
 
 func (c *Chapter) ToChapter() *data.Chapter {
-	return &data.Chapter{
+	chapter := &data.Chapter{
 		ID:         c.ID,
 		Title:      c.Attributes.Title,
 		Language:   c.Attributes.Language,
 		Volume:     c.Attributes.Volume,
-		Number:     c.Attributes.Number,
+		Number:     utils.ChapterNumber(c.Attributes.Number, c.Attributes.Title),
 		Downloaded: false,
 		FilePath:   "",
+		URL:        mangaDexChapterURL(c.ID),
+	}
+	for _, rel := range c.Relationships {
+		switch rel.Type {
+		case "scanlation_group":
+			chapter.GroupIDs = append(chapter.GroupIDs, rel.ID)
+			if rel.Attributes.Name != "" {
+				chapter.Groups = append(chapter.Groups, rel.Attributes.Name)
+			}
+		case "user":
+			chapter.UploaderID = rel.ID
+		}
 	}
+	return chapter
+}
+
+func mangaDexMangaURL(id string) string {
+	return fmt.Sprintf("https://mangadex.org/title/%s", id)
+}
+
+func mangaDexChapterURL(id string) string {
+	return fmt.Sprintf("https://mangadex.org/chapter/%s", id)
 }
 
 type MangaDex struct {
-	api *utils.API
+	api       *utils.API
+	blocklist *Blocklist
+
+	auth    *utils.API // Account login, see mangadex_auth.go
+	mu      sync.Mutex // Guards session and blocklist
+	session *MangaDexSession
+}
+
+// SetBlocklist excludes the chapters of blocked groups and uploaders from feeds
+func (m *MangaDex) SetBlocklist(blocklist *Blocklist) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blocklist = blocklist
 }
 
-func (m *MangaDex) Search(query string) ([]*data.Manga, error) {
+// blocked returns the blocklist set with SetBlocklist
+func (m *MangaDex) blocked() *Blocklist {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.blocklist
+}
+
+// mangaDexOrders maps search orders to the order parameter of the API
+var mangaDexOrders = map[string][2]string{
+	"relevance": {"order[relevance]", "desc"},
+	"latest":    {"order[latestUploadedChapter]", "desc"},
+	"title":     {"order[title]", "asc"},
+	"year":      {"order[year]", "desc"},
+	"follows":   {"order[followedCount]", "desc"},
+	"rating":    {"order[rating]", "desc"},
+}
+
+func (m *MangaDex) Search(query string, options SearchOptions) ([]*data.Manga, error) {
 	params := url.Values{
-		"title": {query},
-		"limit": {"10"},
+		"title":      {query},
+		"limit":      {"10"},
+		"includes[]": mangaIncludes,
+	}
+	if options.Language != "" {
+		params.Set("availableTranslatedLanguage[]", options.Language)
+	}
+	if options.Status != "" {
+		params.Set("status[]", options.Status)
+	}
+	if options.Year > 0 {
+		params.Set("year", strconv.Itoa(options.Year))
+	}
+	if len(options.ContentRating) > 0 {
+		params["contentRating[]"] = options.ContentRating
+	}
+	if order, ok := mangaDexOrders[options.Order]; ok {
+		params.Set(order[0], order[1])
 	}
 	var mangas struct {
 		Data []Manga `json:"data"`
@@ -102,24 +239,61 @@ func (m *MangaDex) GetManga(id string) (*data.Manga, error) {
 	var manga struct {
 		Data Manga `json:"data"`
 	}
-	if err := m.api.Get(fmt.Sprintf("/manga/%s", id), nil, &manga); err != nil {
+	params := url.Values{"includes[]": mangaIncludes}
+	if err := m.api.Get(fmt.Sprintf("/manga/%s", id), params, &manga); err != nil {
 		return nil, err
 	}
 	return manga.Data.ToManga(), nil
 }
 
 func (m *MangaDex) GetChapters(manga *data.Manga) ([]*data.Chapter, error) {
-	var feed struct {
-		Data []Chapter `json:"data"`
-	}
-	if err := m.api.Get(fmt.Sprintf("/manga/%s/feed", manga.ID), nil, &feed); err != nil {
-		return nil, err
-	}
-	out := make([]*data.Chapter, len(feed.Data))
-	for i, chapter := range feed.Data {
-		out[i] = chapter.ToChapter()
+	return m.GetChaptersIn(manga)
+}
+
+// mangaDexFeedLimit is the largest page the chapter feed serves
+const mangaDexFeedLimit = 500
+
+// GetChaptersIn returns the chapters of a manga translated to one of
+// languages, or all of them when none is given. The feed is read page by page
+// in volume and chapter order, so long series are not cut at the page size.
+func (m *MangaDex) GetChaptersIn(manga *data.Manga, languages ...string) ([]*data.Chapter, error) {
+	blocklist := m.blocked()
+	var out []*data.Chapter
+	for offset := 0; ; {
+		var feed struct {
+			Data  []Chapter `json:"data"`
+			Total int       `json:"total"`
+		}
+		params := url.Values{
+			"includes[]":     {"scanlation_group"},
+			"limit":          {strconv.Itoa(mangaDexFeedLimit)},
+			"offset":         {strconv.Itoa(offset)},
+			"order[volume]":  {"asc"},
+			"order[chapter]": {"asc"},
+		}
+		if len(languages) > 0 {
+			params["translatedLanguage[]"] = languages
+		}
+		// Blocked IDs are excluded by the API, names are filtered below
+		if ids := blocklist.GroupIDs(); len(ids) > 0 {
+			params["excludedGroups[]"] = ids
+		}
+		if ids := blocklist.UploaderIDs(); len(ids) > 0 {
+			params["excludedUploaders[]"] = ids
+		}
+		if err := m.api.Get(fmt.Sprintf("/manga/%s/feed", manga.ID), params, &feed); err != nil {
+			return nil, err
+		}
+		for _, chapter := range feed.Data {
+			out = append(out, chapter.ToChapter())
+		}
+
+		offset += len(feed.Data)
+		if len(feed.Data) == 0 || offset >= feed.Total {
+			break
+		}
 	}
-	return out, nil
+	return blocklist.Filter(out), nil
 }
 
 func (m *MangaDex) GetPages(_ *data.Manga, chapter *data.Chapter) ([]string, error) {
@@ -140,6 +314,50 @@ func (m *MangaDex) GetPages(_ *data.Manga, chapter *data.Chapter) ([]string, err
 	return pages, nil
 }
 
+// MangaURL returns the MangaDex title page of a manga
+func (m *MangaDex) MangaURL(manga *data.Manga) string {
+	return mangaDexMangaURL(manga.ID)
+}
+
+// ChapterURL returns the MangaDex reader page of a chapter
+func (m *MangaDex) ChapterURL(_ *data.Manga, chapter *data.Chapter) string {
+	return mangaDexChapterURL(chapter.ID)
+}
+
+// GetRelations returns the sequels, prequels, spin-offs and other series related to a manga
+func (m *MangaDex) GetRelations(manga *data.Manga) ([]*data.Relation, error) {
+	var mangaResp struct {
+		Data Manga `json:"data"`
+	}
+	params := url.Values{
+		"includes[]": {"manga"},
+	}
+	if err := m.api.Get(fmt.Sprintf("/manga/%s", manga.ID), params, &mangaResp); err != nil {
+		return nil, err
+	}
+
+	var relations []*data.Relation
+	for _, rel := range mangaResp.Data.Relationships {
+		if rel.Type != "manga" || rel.Related == "" {
+			continue
+		}
+		name := rel.Attributes.Title["en"]
+		if name == "" {
+			for _, v := range rel.Attributes.Title {
+				name = v
+				break
+			}
+		}
+		relations = append(relations, &data.Relation{
+			MangaID:   manga.ID,
+			RelatedID: rel.ID,
+			Name:      name,
+			Type:      rel.Related,
+		})
+	}
+	return relations, nil
+}
+
 // GetMangaCoverURL returns the cover image URL for a manga
 func (m *MangaDex) GetMangaCoverURL(manga *data.Manga) (string, error) {
 	// Get manga with relationships to find cover art
@@ -183,7 +401,80 @@ func (m *MangaDex) GetChapterCoverURL(manga *data.Manga, chapter *data.Chapter)
 	return m.GetMangaCoverURL(manga)
 }
 
+// mangaDexListLimit is the largest page of manga lists, and
+// mangaDexListWindow how deep into a list offsets can go
+const (
+	mangaDexListLimit  = 100
+	mangaDexListWindow = 10000
+)
+
+// Popular returns the most followed manga tagged with any of the given tag
+// names, reading as many pages as limit needs. Tags unknown to MangaDex are
+// ignored, none known is an error rather than a list of every manga.
+func (m *MangaDex) Popular(tags []string, limit int) ([]*data.Manga, error) {
+	tagIDs, err := m.resolveTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	if len(tagIDs) == 0 {
+		return nil, fmt.Errorf("no MangaDex tags match %s", strings.Join(tags, ", "))
+	}
+	limit = min(limit, mangaDexListWindow)
+
+	var out []*data.Manga
+	for len(out) < limit {
+		var mangas struct {
+			Data  []Manga `json:"data"`
+			Total int     `json:"total"`
+		}
+		params := url.Values{
+			"limit":                {strconv.Itoa(min(limit-len(out), mangaDexListLimit))},
+			"offset":               {strconv.Itoa(len(out))},
+			"order[followedCount]": {"desc"},
+			"includedTagsMode":     {"OR"},
+			"includedTags[]":       tagIDs,
+		}
+		if err := m.api.Get("/manga", params, &mangas); err != nil {
+			return nil, err
+		}
+		for _, manga := range mangas.Data {
+			out = append(out, manga.ToManga())
+		}
+		if len(mangas.Data) == 0 || len(out) >= mangas.Total {
+			break
+		}
+	}
+	return out, nil
+}
+
+// resolveTags maps tag names to MangaDex tag IDs, ignoring unknown names
+func (m *MangaDex) resolveTags(names []string) ([]string, error) {
+	var list struct {
+		Data []Tag `json:"data"`
+	}
+	if err := m.api.Get("/manga/tag", nil, &list); err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[strings.ToLower(name)] = true
+	}
+
+	var ids []string
+	for _, tag := range list.Data {
+		if wanted[strings.ToLower(tag.Name())] {
+			ids = append(ids, tag.ID)
+		}
+	}
+	return ids, nil
+}
+
+func init() {
+	Register("mangadex", NewMangaDex)
+}
+
 func NewMangaDex() Source {
 	baseURL := "https://api.mangadex.org"
-	return &MangaDex{api: utils.NewAPI(baseURL)}
+	return &MangaDex{api: utils.NewSourceAPI("mangadex", baseURL), auth: utils.NewAPI(mangaDexAuthURL)}
 }
@@ -0,0 +1,177 @@
+package sources
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+// mangaDexAuthURL is the OpenID Connect endpoint of MangaDex accounts
+const mangaDexAuthURL = "https://auth.mangadex.org/realms/mangadex/protocol/openid-connect"
+
+// mangaDexFollowsLimit is the largest page of followed manga the API serves
+const mangaDexFollowsLimit = 100
+
+// MangaDexCredentials log in with a personal API client, created in the
+// MangaDex account settings, and the account it belongs to
+type MangaDexCredentials struct {
+	ClientID     string
+	ClientSecret string
+	Username     string
+	Password     string
+}
+
+// MangaDexSession is a logged in MangaDex session. The password is not kept:
+// the refresh token renews the access token when it expires.
+type MangaDexSession struct {
+	ClientID     string    `json:"client_id"`
+	ClientSecret string    `json:"client_secret"`
+	Username     string    `json:"username"`
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+type mangaDexToken struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// Login authenticates with the password grant of a personal client and
+// starts using the session for account requests
+func (m *MangaDex) Login(credentials MangaDexCredentials) (*MangaDexSession, error) {
+	if credentials.ClientID == "" || credentials.ClientSecret == "" {
+		return nil, fmt.Errorf("a personal client ID and secret are required")
+	}
+	form := url.Values{
+		"grant_type":    {"password"},
+		"username":      {credentials.Username},
+		"password":      {credentials.Password},
+		"client_id":     {credentials.ClientID},
+		"client_secret": {credentials.ClientSecret},
+	}
+	var token mangaDexToken
+	if err := m.auth.PostForm("/token", form, &token); err != nil {
+		return nil, fmt.Errorf("login failed: %w", err)
+	}
+
+	session := &MangaDexSession{
+		ClientID:     credentials.ClientID,
+		ClientSecret: credentials.ClientSecret,
+		Username:     credentials.Username,
+	}
+	session.update(token)
+
+	m.mu.Lock()
+	m.session = session
+	m.mu.Unlock()
+	return session, nil
+}
+
+// SetSession resumes a session saved from an earlier Login
+func (m *MangaDex) SetSession(session *MangaDexSession) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.session = session
+}
+
+// Session returns the current session, with its tokens refreshed if they
+// were, or nil when logged out. Save it to stay logged in.
+func (m *MangaDex) Session() *MangaDexSession {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.session
+}
+
+// Follows returns the manga followed by the logged in user, with their
+// reading status
+func (m *MangaDex) Follows() ([]*data.Manga, error) {
+	var out []*data.Manga
+	for offset := 0; ; {
+		var follows struct {
+			Data  []Manga `json:"data"`
+			Total int     `json:"total"`
+		}
+		params := url.Values{
+			"includes[]": mangaIncludes,
+			"limit":      {strconv.Itoa(mangaDexFollowsLimit)},
+			"offset":     {strconv.Itoa(offset)},
+		}
+		if err := m.getAuthorized("/user/follows/manga", params, &follows); err != nil {
+			return nil, fmt.Errorf("failed to get follows: %w", err)
+		}
+		for _, manga := range follows.Data {
+			out = append(out, manga.ToManga())
+		}
+
+		offset += len(follows.Data)
+		if len(follows.Data) == 0 || offset >= follows.Total {
+			break
+		}
+	}
+
+	var statuses struct {
+		Statuses map[string]string `json:"statuses"`
+	}
+	if err := m.getAuthorized("/manga/status", nil, &statuses); err != nil {
+		return nil, fmt.Errorf("failed to get reading statuses: %w", err)
+	}
+	for _, manga := range out {
+		manga.ReadingStatus = statuses.Statuses[manga.ID]
+	}
+	return out, nil
+}
+
+// getAuthorized performs an account request with the session's access token
+func (m *MangaDex) getAuthorized(path string, params url.Values, v any) error {
+	token, err := m.accessToken()
+	if err != nil {
+		return err
+	}
+	header := http.Header{"Authorization": {"Bearer " + token}}
+	return m.api.GetWithHeader(path, params, header, v)
+}
+
+// accessToken returns a valid access token, refreshing it when it expired
+func (m *MangaDex) accessToken() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.session == nil {
+		return "", fmt.Errorf("not logged in to MangaDex, run 'mangas login mangadex'")
+	}
+	// Renew a little early so the token does not expire in flight
+	if time.Now().Add(30 * time.Second).Before(m.session.ExpiresAt) {
+		return m.session.AccessToken, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {m.session.RefreshToken},
+		"client_id":     {m.session.ClientID},
+		"client_secret": {m.session.ClientSecret},
+	}
+	var token mangaDexToken
+	if err := m.auth.PostForm("/token", form, &token); err != nil {
+		return "", fmt.Errorf("session expired, log in again: %w", err)
+	}
+	session := *m.session
+	session.update(token)
+	m.session = &session
+	return session.AccessToken, nil
+}
+
+// update stores freshly issued tokens. The refresh token is only replaced
+// when a new one is issued.
+func (s *MangaDexSession) update(token mangaDexToken) {
+	s.AccessToken = token.AccessToken
+	if token.RefreshToken != "" {
+		s.RefreshToken = token.RefreshToken
+	}
+	s.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+}
@@ -0,0 +1,75 @@
+package sources
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMangaDex_LoginAndFollows(t *testing.T) {
+	var grants []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			require.NoError(t, r.ParseForm())
+			grants = append(grants, r.PostForm.Get("grant_type"))
+			assert.Equal(t, "client", r.PostForm.Get("client_id"))
+			if r.PostForm.Get("grant_type") == "password" {
+				assert.Equal(t, "hunter2", r.PostForm.Get("password"))
+				// Already expired, so the next request refreshes it
+				w.Write([]byte(`{"access_token":"first","refresh_token":"refresh-1","expires_in":0}`))
+				return
+			}
+			assert.Equal(t, "refresh-1", r.PostForm.Get("refresh_token"))
+			w.Write([]byte(`{"access_token":"second","expires_in":900}`))
+		case "/user/follows/manga":
+			assert.Equal(t, "Bearer second", r.Header.Get("Authorization"))
+			if r.URL.Query().Get("offset") == "0" {
+				w.Write([]byte(`{"data":[{"id":"m1","attributes":{"title":{"en":"One"}}}],"total":2}`))
+				return
+			}
+			w.Write([]byte(`{"data":[{"id":"m2","attributes":{"title":{"en":"Two"}}}],"total":2}`))
+		case "/manga/status":
+			assert.Equal(t, "Bearer second", r.Header.Get("Authorization"))
+			w.Write([]byte(`{"result":"ok","statuses":{"m1":"reading"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	md := &MangaDex{api: utils.NewAPI(server.URL), auth: utils.NewAPI(server.URL)}
+
+	_, err := md.Follows()
+	assert.ErrorContains(t, err, "not logged in")
+
+	session, err := md.Login(MangaDexCredentials{ClientID: "client", ClientSecret: "secret", Username: "reader", Password: "hunter2"})
+	require.NoError(t, err)
+	assert.Equal(t, "first", session.AccessToken)
+
+	follows, err := md.Follows()
+	require.NoError(t, err)
+	require.Len(t, follows, 2)
+	assert.Equal(t, "One", follows[0].Name)
+	assert.Equal(t, "reading", follows[0].ReadingStatus)
+	assert.Empty(t, follows[1].ReadingStatus)
+
+	// The refreshed session keeps the refresh token and the account
+	assert.Equal(t, []string{"password", "refresh_token"}, grants)
+	renewed := md.Session()
+	assert.Equal(t, "second", renewed.AccessToken)
+	assert.Equal(t, "refresh-1", renewed.RefreshToken)
+	assert.Equal(t, "reader", renewed.Username)
+	assert.True(t, renewed.ExpiresAt.After(time.Now().Add(time.Minute)))
+}
+
+func TestMangaDex_LoginRequiresClient(t *testing.T) {
+	md := &MangaDex{}
+	_, err := md.Login(MangaDexCredentials{Username: "reader", Password: "hunter2"})
+	assert.Error(t, err)
+}
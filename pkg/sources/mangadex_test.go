@@ -1,19 +1,22 @@
 package sources
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/kerbaras/mangas/pkg/utils"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestMangaToManga(t *testing.T) {
 	mdManga := &Manga{
 		ID: "test-id",
-		Attributes: struct {
-			Title       map[string]string `json:"title"`
-			Description map[string]string `json:"description"`
-		}{
+		Attributes: MangaAttributes{
 			Title: map[string]string{
 				"en": "English Title",
 				"ja": "日本語タイトル",
@@ -30,16 +33,14 @@ func TestMangaToManga(t *testing.T) {
 	assert.Equal(t, manga.Name, "English Title")
 	assert.Equal(t, manga.Description, "English Description")
 	assert.Equal(t, manga.Source, "mangadex")
+	assert.Equal(t, "https://mangadex.org/title/test-id", manga.URL)
 }
 
 func TestMangaToMangaFallback(t *testing.T) {
 	// Test fallback when English title is not available
 	mdManga := &Manga{
 		ID: "test-id",
-		Attributes: struct {
-			Title       map[string]string `json:"title"`
-			Description map[string]string `json:"description"`
-		}{
+		Attributes: MangaAttributes{
 			Title: map[string]string{
 				"ja": "日本語タイトル",
 			},
@@ -55,6 +56,61 @@ func TestMangaToMangaFallback(t *testing.T) {
 	assert.Equal(t, manga.Description, "日本語の説明")
 }
 
+func TestMangaToMangaTags(t *testing.T) {
+	action := Tag{ID: "tag-1"}
+	action.Attributes.Name = map[string]string{"en": "Action"}
+	romance := Tag{ID: "tag-2"}
+	romance.Attributes.Name = map[string]string{"ja": "恋愛"}
+
+	mdManga := &Manga{
+		ID: "test-id",
+		Attributes: MangaAttributes{
+			Title: map[string]string{"en": "Tagged"},
+			Tags:  []Tag{action, romance},
+		},
+	}
+
+	manga := mdManga.ToManga()
+
+	assert.Equal(t, []string{"Action", "恋愛"}, manga.Tags)
+}
+
+func TestMangaToMangaMetadata(t *testing.T) {
+	action := Tag{ID: "tag-1"}
+	action.Attributes.Name = map[string]string{"en": "Action"}
+	action.Attributes.Group = "genre"
+	school := Tag{ID: "tag-2"}
+	school.Attributes.Name = map[string]string{"en": "School Life"}
+	school.Attributes.Group = "theme"
+
+	author := Relationship{Type: "author", ID: "author-1"}
+	author.Attributes.Name = "Author Name"
+	artist := Relationship{Type: "artist", ID: "artist-1"}
+	artist.Attributes.Name = "Artist Name"
+	unnamed := Relationship{Type: "author", ID: "author-2"} // Not included in the response
+
+	mdManga := &Manga{
+		ID: "test-id",
+		Attributes: MangaAttributes{
+			Title:  map[string]string{"en": "Tagged"},
+			Tags:   []Tag{action, school},
+			Year:   2012,
+			Status: "completed",
+		},
+		Relationships: []Relationship{author, artist, unnamed, {Type: "cover_art", ID: "cover-1"}},
+	}
+
+	manga := mdManga.ToManga()
+
+	assert.Equal(t, []string{"Action", "School Life"}, manga.Tags)
+	assert.Equal(t, []string{"Action"}, manga.Genres)
+	assert.Equal(t, []string{"Author Name"}, manga.Authors)
+	assert.Equal(t, []string{"Artist Name"}, manga.Artists)
+	assert.Equal(t, 2012, manga.Year)
+	assert.Equal(t, "completed", manga.PublicationStatus)
+	assert.Equal(t, "", manga.Status, "download status is not the publication status")
+}
+
 func TestChapterToChapter(t *testing.T) {
 	mdChapter := &Chapter{
 		ID: "chapter-id",
@@ -83,6 +139,7 @@ func TestChapterToChapter(t *testing.T) {
 	assert.Equal(t, chapter.Number, "5")
 	assert.False(t, chapter.Downloaded)
 	assert.Empty(t, chapter.FilePath)
+	assert.Equal(t, "https://mangadex.org/chapter/chapter-id", chapter.URL)
 
 	if chapter.Downloaded {
 		assert.False(t, chapter.Downloaded)
@@ -90,6 +147,31 @@ func TestChapterToChapter(t *testing.T) {
 	}
 }
 
+func TestChapterToChapterInfersNumber(t *testing.T) {
+	mdChapter := &Chapter{ID: "chapter-id"}
+	mdChapter.Attributes.Title = "Ch. 12 — The Duel"
+
+	assert.Equal(t, "12", mdChapter.ToChapter().Number)
+
+	comick := &ComickChapter{HID: "c1", Title: "Episode 3", Number: ""}
+	assert.Equal(t, "3", comick.ToChapter().Number)
+}
+
+func TestChapterToChapterGroups(t *testing.T) {
+	group := Relationship{Type: "scanlation_group", ID: "group-1"}
+	group.Attributes.Name = "Example Scans"
+	mdChapter := &Chapter{
+		ID:            "chapter-id",
+		Relationships: []Relationship{group, {Type: "user", ID: "user-1"}, {Type: "manga", ID: "manga-1"}},
+	}
+
+	chapter := mdChapter.ToChapter()
+
+	assert.Equal(t, []string{"group-1"}, chapter.GroupIDs)
+	assert.Equal(t, []string{"Example Scans"}, chapter.Groups)
+	assert.Equal(t, "user-1", chapter.UploaderID)
+}
+
 // Test interface implementation
 func TestMangaDex_ImplementsSource(t *testing.T) {
 	md := NewMangaDex()
@@ -99,7 +181,7 @@ func TestMangaDex_ImplementsSource(t *testing.T) {
 func TestSourceInterfaceMethods(t *testing.T) {
 	md := NewMangaDex()
 	assert.NotPanics(t, func() {
-		md.Search("test")
+		md.Search("test", SearchOptions{})
 	})
 	assert.NotPanics(t, func() {
 		md.GetManga("test-id")
@@ -114,7 +196,7 @@ func TestSourceInterfaceMethods(t *testing.T) {
 
 func TestMangaDex_Search(t *testing.T) {
 	md := NewMangaDex()
-	mangas, err := md.Search("naruto")
+	mangas, err := md.Search("naruto", SearchOptions{})
 	if err != nil {
 		t.Fatalf("Search failed: %v", err)
 	}
@@ -165,3 +247,139 @@ func TestMangaDex_GetPages(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Len(t, pages, 6)
 }
+
+func newTestMangaDex(t *testing.T, handler http.HandlerFunc) *MangaDex {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &MangaDex{api: utils.NewAPI(server.URL)}
+}
+
+func TestMangaDex_GetChaptersPaginated(t *testing.T) {
+	total := 1203
+	var offsets []string
+	md := newTestMangaDex(t, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		assert.Equal(t, "/manga/m1/feed", r.URL.Path)
+		assert.Equal(t, "500", query.Get("limit"))
+		assert.Equal(t, "asc", query.Get("order[volume]"))
+		assert.Equal(t, "asc", query.Get("order[chapter]"))
+		assert.Equal(t, []string{"en", "es"}, query["translatedLanguage[]"])
+		offsets = append(offsets, query.Get("offset"))
+
+		offset, _ := strconv.Atoi(query.Get("offset"))
+		var items []string
+		for i := offset; i < total && i < offset+500; i++ {
+			items = append(items, fmt.Sprintf(`{"id":"ch-%d","attributes":{"chapter":"%d","translatedLanguage":"en"}}`, i, i+1))
+		}
+		fmt.Fprintf(w, `{"data":[%s],"total":%d}`, strings.Join(items, ","), total)
+	})
+
+	chapters, err := md.GetChaptersIn(&data.Manga{ID: "m1"}, "en", "es")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0", "500", "1000"}, offsets)
+	assert.Len(t, chapters, total)
+	assert.Equal(t, "ch-1202", chapters[total-1].ID)
+}
+
+func TestMangaDex_PopularPaginated(t *testing.T) {
+	total := 250
+	var limits, offsets []string
+	md := newTestMangaDex(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/manga/tag" {
+			w.Write([]byte(`{"data":[{"id":"t-action","attributes":{"name":{"en":"Action"}}}]}`))
+			return
+		}
+		query := r.URL.Query()
+		assert.Equal(t, []string{"t-action"}, query["includedTags[]"])
+		limits = append(limits, query.Get("limit"))
+		offsets = append(offsets, query.Get("offset"))
+
+		offset, _ := strconv.Atoi(query.Get("offset"))
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		var items []string
+		for i := offset; i < total && i < offset+limit; i++ {
+			items = append(items, fmt.Sprintf(`{"id":"m-%d","attributes":{"title":{"en":"Manga %d"}}}`, i, i))
+		}
+		fmt.Fprintf(w, `{"data":[%s],"total":%d}`, strings.Join(items, ","), total)
+	})
+
+	mangas, err := md.Popular([]string{"action", "Unknown"}, 230)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"100", "100", "30"}, limits)
+	assert.Equal(t, []string{"0", "100", "200"}, offsets)
+	assert.Len(t, mangas, 230)
+
+	// Nothing to filter by is not every manga
+	_, err = md.Popular([]string{"Unknown"}, 10)
+	assert.Error(t, err)
+}
+
+func TestMangaDex_SearchOptions(t *testing.T) {
+	md := newTestMangaDex(t, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		assert.Equal(t, "/manga", r.URL.Path)
+		assert.Equal(t, "naruto", query.Get("title"))
+		assert.Equal(t, "en", query.Get("availableTranslatedLanguage[]"))
+		assert.Equal(t, "completed", query.Get("status[]"))
+		assert.Equal(t, "2020", query.Get("year"))
+		assert.Equal(t, []string{"safe", "suggestive"}, query["contentRating[]"])
+		assert.Equal(t, "desc", query.Get("order[followedCount]"))
+		w.Write([]byte(`{"data":[{"id":"m1","attributes":{"title":{"en":"Naruto"}}}]}`))
+	})
+
+	mangas, err := md.Search("naruto", SearchOptions{
+		Language:      "en",
+		Status:        "completed",
+		Year:          2020,
+		ContentRating: []string{"safe", "suggestive"},
+		Order:         "follows",
+	})
+	assert.NoError(t, err)
+	assert.Len(t, mangas, 1)
+
+	// No options, no filters
+	md = newTestMangaDex(t, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		for _, param := range []string{"availableTranslatedLanguage[]", "status[]", "year", "contentRating[]"} {
+			assert.Empty(t, query[param], param)
+		}
+		w.Write([]byte(`{"data":[]}`))
+	})
+	_, err = md.Search("naruto", SearchOptions{})
+	assert.NoError(t, err)
+}
+
+func TestMangaDex_GetChaptersAllLanguages(t *testing.T) {
+	md := newTestMangaDex(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.URL.Query()["translatedLanguage[]"])
+		w.Write([]byte(`{"data":[{"id":"ch-1","attributes":{"chapter":"1","translatedLanguage":"ja"}}],"total":1}`))
+	})
+
+	chapters, err := GetChaptersIn(md, &data.Manga{ID: "m1"})
+	assert.NoError(t, err)
+	assert.Len(t, chapters, 1)
+
+	// An error response is reported instead of read as an empty feed
+	md = newTestMangaDex(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"result":"error","errors":[{"status":400}]}`))
+	})
+	_, err = md.GetChapters(&data.Manga{ID: "m1"})
+	var statusErr *utils.StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusBadRequest, statusErr.StatusCode)
+}
+
+func TestGetChaptersIn_FiltersLocally(t *testing.T) {
+	comick := newTestComick(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"chapters":[{"hid":"a","chap":"1","lang":"en"},{"hid":"b","chap":"1","lang":"fr"}]}`))
+	})
+
+	// Hides Comick's own filtering
+	source := struct{ Source }{comick}
+	chapters, err := GetChaptersIn(source, &data.Manga{ID: "abc"}, "fr")
+	assert.NoError(t, err)
+	assert.Len(t, chapters, 1)
+	assert.Equal(t, "b", chapters[0].ID)
+}
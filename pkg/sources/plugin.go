@@ -0,0 +1,412 @@
+package sources
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+// PluginProtocol is the version of the protocol spoken with plugins, sent
+// with every request
+const PluginProtocol = 1
+
+// PluginsDirEnv overrides the directory plugins are installed in,
+// ~/.mangas/plugins by default
+const PluginsDirEnv = "MANGAS_PLUGINS_DIR"
+
+// pluginTimeout is how long a plugin may take to answer a request
+var pluginTimeout = 2 * time.Minute
+
+var (
+	pluginMu  sync.Mutex // Guards pluginCtx
+	pluginCtx = context.Background()
+)
+
+// SetPluginContext makes plugin calls stop when ctx is cancelled, e.g. by
+// Ctrl-C, rather than run until they answer or time out
+func SetPluginContext(ctx context.Context) {
+	pluginMu.Lock()
+	defer pluginMu.Unlock()
+	pluginCtx = ctx
+}
+
+func pluginContext() context.Context {
+	pluginMu.Lock()
+	defer pluginMu.Unlock()
+	return pluginCtx
+}
+
+// Plugin is a source implemented by an external program. For every call,
+// the program is run with a PluginRequest as JSON on stdin and prints a
+// PluginResponse as JSON on stdout; anything on stderr is kept for errors.
+type Plugin struct {
+	name string
+	path string
+}
+
+// PluginRequest is what a plugin reads on stdin. Method is one of info,
+// search, manga, chapters, pages, manga_cover and chapter_cover.
+type PluginRequest struct {
+	Protocol int            `json:"protocol"`
+	Method   string         `json:"method"`
+	Query    string         `json:"query,omitempty"`    // search
+	Search   *PluginSearch  `json:"search,omitempty"`   // search
+	MangaID  string         `json:"manga_id,omitempty"` // manga
+	Manga    *PluginManga   `json:"manga,omitempty"`    // chapters, pages, manga_cover, chapter_cover
+	Chapter  *PluginChapter `json:"chapter,omitempty"`  // pages, chapter_cover
+}
+
+// PluginResponse is what a plugin prints on stdout: an error, or the result
+// of the method. Results are a PluginInfo for info, a list of PluginManga
+// for search, a PluginManga for manga, a list of PluginChapter for
+// chapters, a list of image URLs for pages and an image URL for covers.
+type PluginResponse struct {
+	Error  string          `json:"error,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// PluginInfo describes a plugin
+type PluginInfo struct {
+	Name     string `json:"name"` // Source name, as given to --source
+	Version  string `json:"version"`
+	Protocol int    `json:"protocol"` // PluginProtocol the plugin speaks
+}
+
+// PluginSearch are the search options of a search request
+type PluginSearch struct {
+	Language      string   `json:"language,omitempty"`
+	Status        string   `json:"status,omitempty"`
+	Year          int      `json:"year,omitempty"`
+	ContentRating []string `json:"content_rating,omitempty"`
+	Order         string   `json:"order,omitempty"`
+}
+
+// PluginManga is a manga as exchanged with plugins
+type PluginManga struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Status      string `json:"status,omitempty"`
+	URL         string `json:"url,omitempty"`
+}
+
+// PluginChapter is a chapter as exchanged with plugins
+type PluginChapter struct {
+	ID       string   `json:"id"`
+	Title    string   `json:"title,omitempty"`
+	Language string   `json:"language,omitempty"`
+	Volume   string   `json:"volume,omitempty"`
+	Number   string   `json:"number,omitempty"`
+	URL      string   `json:"url,omitempty"`
+	Groups   []string `json:"groups,omitempty"`
+}
+
+// NewPlugin creates the source of the plugin program at path
+func NewPlugin(name, path string) *Plugin {
+	return &Plugin{name: name, path: path}
+}
+
+// Path returns the program of the plugin
+func (p *Plugin) Path() string {
+	return p.path
+}
+
+// Info asks the plugin to describe itself
+func (p *Plugin) Info() (*PluginInfo, error) {
+	var info PluginInfo
+	if err := p.call(PluginRequest{Method: "info"}, &info); err != nil {
+		return nil, err
+	}
+	if info.Name == "" {
+		return nil, fmt.Errorf("plugin %s has no name", p.path)
+	}
+	if info.Protocol != PluginProtocol {
+		return nil, fmt.Errorf("plugin %s speaks protocol %d, expected %d", info.Name, info.Protocol, PluginProtocol)
+	}
+	return &info, nil
+}
+
+func (p *Plugin) Search(query string, options SearchOptions) ([]*data.Manga, error) {
+	var results []PluginManga
+	search := &PluginSearch{
+		Language:      options.Language,
+		Status:        options.Status,
+		Year:          options.Year,
+		ContentRating: options.ContentRating,
+		Order:         options.Order,
+	}
+	if err := p.call(PluginRequest{Method: "search", Query: query, Search: search}, &results); err != nil {
+		return nil, err
+	}
+	mangas := make([]*data.Manga, len(results))
+	for i, result := range results {
+		mangas[i] = p.manga(result)
+	}
+	return mangas, nil
+}
+
+func (p *Plugin) GetManga(id string) (*data.Manga, error) {
+	var result PluginManga
+	if err := p.call(PluginRequest{Method: "manga", MangaID: id}, &result); err != nil {
+		return nil, err
+	}
+	if result.ID == "" {
+		return nil, fmt.Errorf("manga not found: %s", id)
+	}
+	return p.manga(result), nil
+}
+
+func (p *Plugin) GetChapters(manga *data.Manga) ([]*data.Chapter, error) {
+	var results []PluginChapter
+	if err := p.call(PluginRequest{Method: "chapters", Manga: pluginManga(manga)}, &results); err != nil {
+		return nil, err
+	}
+	chapters := make([]*data.Chapter, 0, len(results))
+	for _, result := range results {
+		if result.ID == "" {
+			continue
+		}
+		chapters = append(chapters, &data.Chapter{
+			ID:       result.ID,
+			MangaID:  manga.ID,
+			Title:    result.Title,
+			Language: result.Language,
+			Volume:   result.Volume,
+			Number:   result.Number,
+			URL:      result.URL,
+			Groups:   result.Groups,
+		})
+	}
+	return chapters, nil
+}
+
+func (p *Plugin) GetPages(manga *data.Manga, chapter *data.Chapter) ([]string, error) {
+	var pages []string
+	err := p.call(PluginRequest{Method: "pages", Manga: pluginManga(manga), Chapter: pluginChapter(chapter)}, &pages)
+	return pages, err
+}
+
+func (p *Plugin) GetMangaCoverURL(manga *data.Manga) (string, error) {
+	var cover string
+	err := p.call(PluginRequest{Method: "manga_cover", Manga: pluginManga(manga)}, &cover)
+	return cover, err
+}
+
+func (p *Plugin) GetChapterCoverURL(manga *data.Manga, chapter *data.Chapter) (string, error) {
+	var cover string
+	err := p.call(PluginRequest{Method: "chapter_cover", Manga: pluginManga(manga), Chapter: pluginChapter(chapter)}, &cover)
+	return cover, err
+}
+
+// call runs the plugin on a request and decodes the result into v
+func (p *Plugin) call(request PluginRequest, v any) error {
+	request.Protocol = PluginProtocol
+	input, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	parent := pluginContext()
+	ctx, cancel := context.WithTimeout(parent, pluginTimeout)
+	defer cancel()
+	var output, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.path)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = bytes.NewReader(input), &output, &stderr
+	// Children of the plugin holding its output open don't delay a stop
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		if parent.Err() != nil {
+			return fmt.Errorf("plugin %s: %s interrupted: %w", p.label(), request.Method, parent.Err())
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("plugin %s: %s timed out after %s", p.label(), request.Method, pluginTimeout)
+		}
+		return fmt.Errorf("plugin %s: %s failed: %w: %s", p.label(), request.Method, err, lastLine(stderr.String()))
+	}
+
+	var response PluginResponse
+	if err := json.Unmarshal(output.Bytes(), &response); err != nil {
+		return fmt.Errorf("plugin %s: invalid %s response: %w", p.label(), request.Method, err)
+	}
+	if response.Error != "" {
+		return fmt.Errorf("plugin %s: %s", p.label(), response.Error)
+	}
+	if len(response.Result) == 0 {
+		return fmt.Errorf("plugin %s: no result for %s", p.label(), request.Method)
+	}
+	if err := json.Unmarshal(response.Result, v); err != nil {
+		return fmt.Errorf("plugin %s: invalid %s result: %w", p.label(), request.Method, err)
+	}
+	return nil
+}
+
+func (p *Plugin) label() string {
+	if p.name != "" {
+		return p.name
+	}
+	return filepath.Base(p.path)
+}
+
+func (p *Plugin) manga(result PluginManga) *data.Manga {
+	return &data.Manga{
+		ID:          result.ID,
+		Name:        result.Name,
+		Description: result.Description,
+		Status:      result.Status,
+		URL:         result.URL,
+		Source:      p.name,
+	}
+}
+
+func pluginManga(manga *data.Manga) *PluginManga {
+	return &PluginManga{ID: manga.ID, Name: manga.Name, Description: manga.Description, Status: manga.Status, URL: manga.URL}
+}
+
+func pluginChapter(chapter *data.Chapter) *PluginChapter {
+	return &PluginChapter{
+		ID:       chapter.ID,
+		Title:    chapter.Title,
+		Language: chapter.Language,
+		Volume:   chapter.Volume,
+		Number:   chapter.Number,
+		URL:      chapter.URL,
+		Groups:   chapter.Groups,
+	}
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return lines[len(lines)-1]
+}
+
+// DefaultPluginsDir returns where plugins are installed: the directory of
+// PluginsDirEnv, or ~/.mangas/plugins
+func DefaultPluginsDir() string {
+	if dir := os.Getenv(PluginsDirEnv); dir != "" {
+		return dir
+	}
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".mangas", "plugins")
+}
+
+// LoadPlugins registers a source for every executable of dir, named after
+// the file. A missing directory has no plugins; a plugin can't replace a
+// source already registered, those are reported in the error.
+func LoadPlugins(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var loaded []string
+	var errs []error
+	for _, entry := range entries {
+		name := pluginName(entry.Name())
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !isExecutable(filepath.Join(dir, entry.Name())) {
+			continue
+		}
+		if slices.Contains(Names(), name) {
+			errs = append(errs, fmt.Errorf("%s: source %s already exists", entry.Name(), name))
+			continue
+		}
+		plugin := NewPlugin(name, filepath.Join(dir, entry.Name()))
+		Register(name, func() Source { return plugin })
+		loaded = append(loaded, name)
+	}
+	return loaded, errors.Join(errs...)
+}
+
+// InstallPlugin asks the plugin program at path for its name and copies it
+// to dir under that name, replacing an older version. Built-in sources and
+// sites can't be replaced.
+func InstallPlugin(path, dir string) (*PluginInfo, error) {
+	info, err := NewPlugin("", path).Info()
+	if err != nil {
+		return nil, err
+	}
+	// Dots are out too: the extension is dropped from the file name, see
+	// pluginName, so "my.source" would load as "my"
+	if info.Name == "" || strings.ContainsAny(info.Name, ` =/\.`) {
+		return nil, fmt.Errorf("invalid plugin name %q", info.Name)
+	}
+	if source, err := Get(info.Name); err == nil {
+		if _, isPlugin := source.(*Plugin); !isPlugin {
+			return nil, fmt.Errorf("source %s already exists", info.Name)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	target := filepath.Join(dir, info.Name+filepath.Ext(path))
+	tmp, err := os.CreateTemp(dir, ".install-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return nil, err
+	}
+	// An older version may be another kind of program, e.g. a script
+	_ = RemovePlugin(info.Name, dir)
+	return info, os.Rename(tmp.Name(), target)
+}
+
+// RemovePlugin deletes the plugin installed under name from dir
+func RemovePlugin(name, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && pluginName(entry.Name()) == name {
+			return os.Remove(filepath.Join(dir, entry.Name()))
+		}
+	}
+	return fmt.Errorf("no plugin named %s in %s", name, dir)
+}
+
+// pluginName is the source name of a plugin file: its name without the
+// extension, for plugins shipped as scripts or Windows executables
+func pluginName(file string) string {
+	return strings.TrimSuffix(file, filepath.Ext(file))
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(filepath.Ext(path), ".exe")
+	}
+	return info.Mode().Perm()&0111 != 0
+}
@@ -0,0 +1,129 @@
+package sources
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPluginScript answers every method of the protocol from canned JSON
+const testPluginScript = `#!/bin/sh
+request=$(cat)
+case "$request" in
+*'"method":"info"'*) echo '{"result":{"name":"test-plugin","version":"1.0","protocol":1}}' ;;
+*'"method":"search"'*) echo '{"result":[{"id":"m1","name":"Plugged"}]}' ;;
+*'"method":"manga"'*) echo '{"result":{"id":"m1","name":"Plugged","url":"https://example.com/m1"}}' ;;
+*'"method":"chapters"'*) echo '{"result":[{"id":"c1","number":"1","language":"en"},{"id":""}]}' ;;
+*'"method":"pages"'*) echo '{"result":["https://example.com/1.png"]}' ;;
+*'"method":"manga_cover"'*) echo '{"error":"no cover"}' ;;
+*) echo "unknown request" >&2; exit 1 ;;
+esac
+`
+
+func writeTestPlugin(t *testing.T, dir, name, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins in tests are shell scripts")
+	}
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(script), 0755))
+	return path
+}
+
+func TestPlugin(t *testing.T) {
+	plugin := NewPlugin("test-plugin", writeTestPlugin(t, t.TempDir(), "plugin.sh", testPluginScript))
+
+	info, err := plugin.Info()
+	require.NoError(t, err)
+	assert.Equal(t, "test-plugin", info.Name)
+	assert.Equal(t, "1.0", info.Version)
+
+	mangas, err := plugin.Search("plug", SearchOptions{})
+	require.NoError(t, err)
+	require.Len(t, mangas, 1)
+	assert.Equal(t, "m1", mangas[0].ID)
+	assert.Equal(t, "test-plugin", mangas[0].Source)
+
+	manga, err := plugin.GetManga("m1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/m1", manga.URL)
+
+	chapters, err := plugin.GetChapters(manga)
+	require.NoError(t, err)
+	require.Len(t, chapters, 1, "chapters without an ID are dropped")
+	assert.Equal(t, "m1", chapters[0].MangaID)
+
+	pages, err := plugin.GetPages(manga, chapters[0])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://example.com/1.png"}, pages)
+
+	_, err = plugin.GetMangaCoverURL(manga)
+	assert.ErrorContains(t, err, "no cover")
+	_, err = plugin.GetChapterCoverURL(manga, &data.Chapter{ID: "c1"})
+	assert.ErrorContains(t, err, "unknown request")
+}
+
+func TestInstallPlugin(t *testing.T) {
+	dir := t.TempDir()
+	program := writeTestPlugin(t, t.TempDir(), "downloaded.sh", testPluginScript)
+
+	info, err := InstallPlugin(program, dir)
+	require.NoError(t, err)
+	assert.Equal(t, "test-plugin", info.Name)
+	_, err = os.Stat(filepath.Join(dir, "test-plugin.sh"))
+	require.NoError(t, err)
+
+	loaded, err := LoadPlugins(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"test-plugin"}, loaded)
+	source, err := Get("test-plugin")
+	require.NoError(t, err)
+	assert.IsType(t, &Plugin{}, source)
+
+	// Installed again, it is upgraded in place
+	_, err = InstallPlugin(program, dir)
+	require.NoError(t, err)
+
+	builtin := writeTestPlugin(t, t.TempDir(), "builtin.sh", `#!/bin/sh
+echo '{"result":{"name":"mangadex","protocol":1}}'
+`)
+	_, err = InstallPlugin(builtin, dir)
+	assert.ErrorContains(t, err, "already exists")
+	old := writeTestPlugin(t, t.TempDir(), "old.sh", `#!/bin/sh
+echo '{"result":{"name":"old","protocol":0}}'
+`)
+	_, err = InstallPlugin(old, dir)
+	assert.ErrorContains(t, err, "protocol")
+	dotted := writeTestPlugin(t, t.TempDir(), "dotted.sh", `#!/bin/sh
+echo '{"result":{"name":"my.source","protocol":1}}'
+`)
+	_, err = InstallPlugin(dotted, dir)
+	assert.ErrorContains(t, err, "invalid plugin name")
+
+	require.NoError(t, RemovePlugin("test-plugin", dir))
+	assert.Error(t, RemovePlugin("test-plugin", dir))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestPluginInterrupted(t *testing.T) {
+	plugin := NewPlugin("slow", writeTestPlugin(t, t.TempDir(), "slow.sh", "#!/bin/sh\nexec sleep 30\n"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	SetPluginContext(ctx)
+	t.Cleanup(func() { SetPluginContext(context.Background()) })
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := plugin.GetManga("m1")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), 10*time.Second)
+}
@@ -0,0 +1,126 @@
+package sources
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/kerbaras/mangas/pkg/data"
+)
+
+// DefaultSource is the name of the source used when none is specified
+const DefaultSource = "mangadex"
+
+// Factory creates a new Source instance
+type Factory func() Source
+
+// Registry keeps track of the available sources by name.
+// Sources are created lazily on first use and reused afterwards.
+type Registry struct {
+	mu        sync.Mutex
+	factories map[string]Factory
+	instances map[string]Source
+	blocklist *Blocklist
+}
+
+// NewRegistry creates an empty source registry
+func NewRegistry() *Registry {
+	return &Registry{
+		factories: make(map[string]Factory),
+		instances: make(map[string]Source),
+	}
+}
+
+// Register adds a source factory under the given name, replacing any previous one
+func (r *Registry) Register(name string, factory Factory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.factories[name] = factory
+	delete(r.instances, name)
+}
+
+// Get returns the source registered under name
+func (r *Registry) Get(name string) (Source, error) {
+	if name == "" {
+		name = DefaultSource
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if source, ok := r.instances[name]; ok {
+		return source, nil
+	}
+	factory, ok := r.factories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown source: %s", name)
+	}
+	source := factory()
+	if blocker, ok := source.(GroupBlocker); ok && r.blocklist != nil {
+		blocker.SetBlocklist(r.blocklist)
+	}
+	r.instances[name] = source
+	return source, nil
+}
+
+// SetBlocklist hides the chapters of blocked groups in every source that
+// supports it, including the ones created later
+func (r *Registry) SetBlocklist(blocklist *Blocklist) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.blocklist = blocklist
+	for _, source := range r.instances {
+		if blocker, ok := source.(GroupBlocker); ok {
+			blocker.SetBlocklist(blocklist)
+		}
+	}
+}
+
+// Names returns the sorted names of all registered sources
+func (r *Registry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultRegistry holds the built-in sources, which register themselves on init
+var DefaultRegistry = NewRegistry()
+
+// Register adds a source factory to the default registry
+func Register(name string, factory Factory) {
+	DefaultRegistry.Register(name, factory)
+}
+
+// Get returns a source from the default registry
+func Get(name string) (Source, error) {
+	return DefaultRegistry.Get(name)
+}
+
+// SetBlocklist sets the blocklist of the sources in the default registry
+func SetBlocklist(blocklist *Blocklist) {
+	DefaultRegistry.SetBlocklist(blocklist)
+}
+
+// Names returns the names of the sources in the default registry
+func Names() []string {
+	return DefaultRegistry.Names()
+}
+
+// ForManga returns the source a manga was added from, or fallback when the
+// manga has no source or its source is not registered
+func ForManga(manga *data.Manga, fallback Source) Source {
+	if manga == nil || manga.Source == "" {
+		return fallback
+	}
+	source, err := Get(manga.Source)
+	if err != nil {
+		return fallback
+	}
+	return source
+}
@@ -0,0 +1,44 @@
+package sources
+
+import (
+	"testing"
+
+	"github.com/kerbaras/mangas/pkg/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_RegisterAndGet(t *testing.T) {
+	registry := NewRegistry()
+	created := 0
+	registry.Register("mangadex", func() Source {
+		created++
+		return NewMangaDex()
+	})
+
+	first, err := registry.Get("mangadex")
+	assert.NoError(t, err)
+	second, err := registry.Get("")
+	assert.NoError(t, err)
+
+	assert.Same(t, first, second)
+	assert.Equal(t, 1, created)
+	assert.Equal(t, []string{"mangadex"}, registry.Names())
+
+	_, err = registry.Get("unknown")
+	assert.Error(t, err)
+}
+
+func TestDefaultRegistry_BuiltinSources(t *testing.T) {
+	assert.Contains(t, Names(), "mangadex")
+	assert.Contains(t, Names(), "comick")
+}
+
+func TestForManga(t *testing.T) {
+	fallback := NewMangaDex()
+
+	assert.Equal(t, fallback, ForManga(&data.Manga{}, fallback))
+	assert.Equal(t, fallback, ForManga(&data.Manga{Source: "unknown"}, fallback))
+
+	comick, _ := Get("comick")
+	assert.Same(t, comick, ForManga(&data.Manga{Source: "comick"}, fallback))
+}
@@ -0,0 +1,67 @@
+package sources
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Publication statuses a search can be restricted to
+var SearchStatuses = []string{"ongoing", "completed", "hiatus", "cancelled"}
+
+// Content ratings a search can be restricted to
+var ContentRatings = []string{"safe", "suggestive", "erotica", "pornographic"}
+
+// Orders search results can be sorted in. Relevance is the default.
+var SearchOrders = []string{"relevance", "latest", "title", "year", "follows", "rating"}
+
+// SearchOptions narrows down a search. Zero values don't filter, and sources
+// ignore the options they can't filter on.
+type SearchOptions struct {
+	Language      string   // Language chapters are translated to, e.g. "en"
+	Status        string   // One of SearchStatuses
+	Year          int      // Year of first publication
+	ContentRating []string // Some of ContentRatings
+	Order         string   // One of SearchOrders
+}
+
+// Validate reports options with unknown values
+func (o SearchOptions) Validate() error {
+	if o.Status != "" && !slices.Contains(SearchStatuses, o.Status) {
+		return fmt.Errorf("unknown status %q (available: %s)", o.Status, strings.Join(SearchStatuses, ", "))
+	}
+	for _, rating := range o.ContentRating {
+		if !slices.Contains(ContentRatings, rating) {
+			return fmt.Errorf("unknown content rating %q (available: %s)", rating, strings.Join(ContentRatings, ", "))
+		}
+	}
+	if o.Order != "" && !slices.Contains(SearchOrders, o.Order) {
+		return fmt.Errorf("unknown order %q (available: %s)", o.Order, strings.Join(SearchOrders, ", "))
+	}
+	if o.Year < 0 {
+		return fmt.Errorf("invalid year %d", o.Year)
+	}
+	return nil
+}
+
+// Summary describes the filters set, e.g. "completed, 2020", or returns ""
+// when none is
+func (o SearchOptions) Summary() string {
+	var parts []string
+	if o.Language != "" {
+		parts = append(parts, o.Language)
+	}
+	if o.Status != "" {
+		parts = append(parts, o.Status)
+	}
+	if o.Year > 0 {
+		parts = append(parts, fmt.Sprint(o.Year))
+	}
+	if len(o.ContentRating) > 0 {
+		parts = append(parts, strings.Join(o.ContentRating, "/"))
+	}
+	if o.Order != "" && o.Order != "relevance" {
+		parts = append(parts, "by "+o.Order)
+	}
+	return strings.Join(parts, ", ")
+}
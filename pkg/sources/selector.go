@@ -0,0 +1,265 @@
+package sources
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// selector is a compiled CSS selector, for scraping sources. It supports the
+// subset site definitions need: type, #id, .class and [attr], [attr=value],
+// [attr^=value], [attr*=value] selectors, combined with descendant and child
+// (>) combinators, and comma separated lists.
+type selector [][]selectorPart
+
+// selectorPart is a compound selector, like div.chapter[data-id]
+type selectorPart struct {
+	tag     string
+	id      string
+	classes []string
+	attrs   []attrMatcher
+	child   bool // Its element is a child of the previous part's, not any descendant
+}
+
+type attrMatcher struct {
+	name, op, value string
+}
+
+// compileSelector parses a CSS selector
+func compileSelector(s string) (selector, error) {
+	var sel selector
+	for _, alternative := range strings.Split(s, ",") {
+		parts, err := compileSelectorParts(alternative)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", s, err)
+		}
+		sel = append(sel, parts)
+	}
+	return sel, nil
+}
+
+func compileSelectorParts(s string) ([]selectorPart, error) {
+	var parts []selectorPart
+	child := false
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '>':
+			if len(parts) == 0 || child {
+				return nil, fmt.Errorf("misplaced >")
+			}
+			child = true
+			i++
+		default:
+			part, n, err := compileCompound(s[i:])
+			if err != nil {
+				return nil, err
+			}
+			part.child = child
+			parts = append(parts, part)
+			child = false
+			i += n
+		}
+	}
+	if len(parts) == 0 || child {
+		return nil, fmt.Errorf("empty selector")
+	}
+	return parts, nil
+}
+
+// compileCompound parses the compound selector at the start of s, returning
+// how many bytes it took
+func compileCompound(s string) (selectorPart, int, error) {
+	var part selectorPart
+	i := 0
+	name := func() string {
+		start := i
+		for i < len(s) && isSelectorNameByte(s[i]) {
+			i++
+		}
+		return s[start:i]
+	}
+
+	if i < len(s) && s[i] == '*' {
+		i++
+	} else {
+		part.tag = strings.ToLower(name())
+	}
+	for i < len(s) {
+		switch s[i] {
+		case '#':
+			i++
+			if part.id = name(); part.id == "" {
+				return part, i, fmt.Errorf("empty id")
+			}
+		case '.':
+			i++
+			class := name()
+			if class == "" {
+				return part, i, fmt.Errorf("empty class")
+			}
+			part.classes = append(part.classes, class)
+		case '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return part, i, fmt.Errorf("unclosed [")
+			}
+			attr, err := compileAttr(s[i+1 : i+end])
+			if err != nil {
+				return part, i, err
+			}
+			part.attrs = append(part.attrs, attr)
+			i += end + 1
+		case ' ', '\t', '\n', '>':
+			return part, i, nil
+		default:
+			return part, i, fmt.Errorf("unexpected %q", s[i])
+		}
+	}
+	if i == 0 {
+		return part, i, fmt.Errorf("empty selector")
+	}
+	return part, i, nil
+}
+
+func compileAttr(s string) (attrMatcher, error) {
+	for _, op := range []string{"^=", "*=", "="} {
+		if name, value, ok := strings.Cut(s, op); ok {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				return attrMatcher{}, fmt.Errorf("empty attribute name")
+			}
+			value = strings.Trim(strings.TrimSpace(value), `"'`)
+			return attrMatcher{name: strings.ToLower(name), op: op, value: value}, nil
+		}
+	}
+	name := strings.TrimSpace(s)
+	if name == "" {
+		return attrMatcher{}, fmt.Errorf("empty attribute name")
+	}
+	return attrMatcher{name: strings.ToLower(name)}, nil
+}
+
+func isSelectorNameByte(c byte) bool {
+	return c == '-' || c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// all returns the elements under root matching the selector, in document
+// order
+func (sel selector) all(root *html.Node) []*html.Node {
+	var matches []*html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && sel.matches(c) {
+				matches = append(matches, c)
+			}
+			walk(c)
+		}
+	}
+	walk(root)
+	return matches
+}
+
+// first returns the first element under root matching the selector, nil
+// when none does
+func (sel selector) first(root *html.Node) *html.Node {
+	if matches := sel.all(root); len(matches) > 0 {
+		return matches[0]
+	}
+	return nil
+}
+
+func (sel selector) matches(n *html.Node) bool {
+	for _, parts := range sel {
+		if matchParts(n, parts) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchParts reports whether n matches the last part and its ancestors the
+// parts before it
+func matchParts(n *html.Node, parts []selectorPart) bool {
+	last := parts[len(parts)-1]
+	if !last.matches(n) {
+		return false
+	}
+	if len(parts) == 1 {
+		return true
+	}
+	for parent := n.Parent; parent != nil && parent.Type == html.ElementNode; parent = parent.Parent {
+		if matchParts(parent, parts[:len(parts)-1]) {
+			return true
+		}
+		if last.child {
+			break
+		}
+	}
+	return false
+}
+
+func (p selectorPart) matches(n *html.Node) bool {
+	if p.tag != "" && n.Data != p.tag {
+		return false
+	}
+	if p.id != "" && nodeAttr(n, "id") != p.id {
+		return false
+	}
+	if len(p.classes) > 0 {
+		classes := strings.Fields(nodeAttr(n, "class"))
+		for _, class := range p.classes {
+			if !slices.Contains(classes, class) {
+				return false
+			}
+		}
+	}
+	for _, attr := range p.attrs {
+		value, ok := lookupAttr(n, attr.name)
+		switch {
+		case !ok:
+			return false
+		case attr.op == "=" && value != attr.value,
+			attr.op == "^=" && !strings.HasPrefix(value, attr.value),
+			attr.op == "*=" && !strings.Contains(value, attr.value):
+			return false
+		}
+	}
+	return true
+}
+
+func lookupAttr(n *html.Node, name string) (string, bool) {
+	for _, attr := range n.Attr {
+		if attr.Key == name {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+// nodeAttr returns the value of an attribute of n, "" when it has none
+func nodeAttr(n *html.Node, name string) string {
+	value, _ := lookupAttr(n, name)
+	return strings.TrimSpace(value)
+}
+
+// nodeText returns the text of n and its children, with whitespace collapsed
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -1,22 +1,58 @@
 package utils
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 )
 
+// maxRateLimitRetries is how many times a request answered with 429 Too Many
+// Requests is retried
+const maxRateLimitRetries = 3
+
 type API struct {
-	client  *http.Client
 	baseURL string
+	limiter *HostLimiter
+	source  string // Requests are counted in Stats under this name, when set
 }
 
 func NewAPI(baseURL string) *API {
-	return &API{client: http.DefaultClient, baseURL: baseURL}
+	return &API{baseURL: baseURL, limiter: SharedLimiter}
+}
+
+// NewSourceAPI returns an API client whose requests are counted in Stats
+// under the name of source
+func NewSourceAPI(source, baseURL string) *API {
+	api := NewAPI(baseURL)
+	api.source = source
+	return api
+}
+
+// StatusError is returned for responses with a non-2xx status
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Body       string // Start of the response body, for the error message
+}
+
+func (e *StatusError) Error() string {
+	if e.Body != "" {
+		return fmt.Sprintf("bad status: %s: %s", e.Status, e.Body)
+	}
+	return fmt.Sprintf("bad status: %s", e.Status)
 }
 
 func (a *API) Get(path string, params url.Values, v any) error {
+	return a.GetWithHeader(path, params, nil, v)
+}
+
+// GetWithHeader is Get with extra request headers, such as Authorization
+func (a *API) GetWithHeader(path string, params url.Values, header http.Header, v any) error {
 	if params != nil {
 		path += "?" + params.Encode()
 	}
@@ -26,10 +62,159 @@ func (a *API) Get(path string, params url.Values, v any) error {
 	}
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/json")
-	resp, err := a.client.Do(req)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	return a.fetch(req, v)
+}
+
+// PostForm posts a URL-encoded form and decodes the JSON response
+func (a *API) PostForm(path string, form url.Values, v any) error {
+	body := form.Encode()
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s%s", a.baseURL, path), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return a.do(req, []byte(body), v)
+}
+
+// GetHTML fetches a web page, for sources scraping sites without an API
+func (a *API) GetHTML(path string, params url.Values) ([]byte, error) {
+	if params != nil {
+		path += "?" + params.Encode()
+	}
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s%s", a.baseURL, path), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html")
+	var page []byte
+	err = a.fetch(req, &page)
+	return page, err
+}
+
+// PostFormHTML posts a URL-encoded form and returns the HTML answered
+func (a *API) PostFormHTML(path string, form url.Values) ([]byte, error) {
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s%s", a.baseURL, path), nil)
 	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	var page []byte
+	err = a.do(req, []byte(form.Encode()), &page)
+	return page, err
+}
+
+// fetch sends a GET request, answering it from the response cache while the
+// cached response is fresh, or whatever its age when offline. Requests with
+// credentials are never cached.
+func (a *API) fetch(req *http.Request, v any) error {
+	cache := ResponseCache()
+	if cache == nil || req.Header.Get("Authorization") != "" {
+		return a.do(req, nil, v)
+	}
+	key := req.URL.String()
+	if body, fresh, ok := cache.Get(key); ok && (fresh || Offline()) {
+		return decodeBody(body, v)
+	}
+	ttl := CacheTTL(req.URL)
+	if ttl <= 0 {
+		return a.do(req, nil, v)
+	}
+
+	var body []byte
+	if err := a.do(req, nil, &body); err != nil {
 		return err
 	}
+	// A failure to store the response doesn't fail the request
+	_ = cache.Put(key, body, ttl)
+	return decodeBody(body, v)
+}
+
+// decodeBody decodes a JSON body, or copies it into a *[]byte
+func decodeBody(body []byte, v any) error {
+	if raw, ok := v.(*[]byte); ok {
+		*raw = body
+		return nil
+	}
+	return json.Unmarshal(body, v)
+}
+
+// do sends a request, retrying it when rate limited, and decodes the response
+func (a *API) do(req *http.Request, body []byte, v any) error {
+	for retry := 0; ; retry++ {
+		if body != nil {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(len(body))
+		}
+		a.limiter.Wait(req.URL.String())
+		start := time.Now()
+		resp, err := HTTPClient(a.source).Do(req)
+		if err != nil {
+			a.record(start, 0, err)
+			return err
+		}
+		backedOff := a.limiter.Observe(resp)
+
+		if resp.StatusCode == http.StatusTooManyRequests && retry < maxRateLimitRetries {
+			resp.Body.Close()
+			a.record(start, 0, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status})
+			// Without a hint from the server, wait 1s, 2s, 4s...
+			if !backedOff {
+				a.limiter.Backoff(req.URL.String(), time.Now().Add(time.Second<<retry))
+			}
+			continue
+		}
+		body := &countingReader{reader: resp.Body}
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{body, resp.Body}
+		err = decodeResponse(resp, v)
+		a.record(start, body.count, err)
+		return err
+	}
+}
+
+// record counts a request in Stats when the client belongs to a source
+func (a *API) record(start time.Time, bytes int64, err error) {
+	if a.source != "" {
+		Stats.Record(a.source, time.Since(start), bytes, err)
+	}
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// decodeResponse decodes a JSON response, or reads it as is into a *[]byte,
+// or returns a StatusError for non-2xx statuses
+func decodeResponse(resp *http.Response, v any) error {
 	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return &StatusError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       strings.TrimSpace(string(body)),
+		}
+	}
+	if raw, ok := v.(*[]byte); ok {
+		var err error
+		*raw, err = io.ReadAll(resp.Body)
+		return err
+	}
 	return json.NewDecoder(resp.Body).Decode(v)
 }
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPI_Get(t *testing.T) {
+	t.Run("retries after 429", func(t *testing.T) {
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.Write([]byte(`{"result":"ok"}`))
+		}))
+		defer server.Close()
+
+		var resp struct{ Result string }
+		if err := NewAPI(server.URL).Get("/manga", nil, &resp); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if calls != 2 || resp.Result != "ok" {
+			t.Errorf("Expected a retry to succeed, got %d calls and %q", calls, resp.Result)
+		}
+	})
+
+	t.Run("status error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"result":"error"}`))
+		}))
+		defer server.Close()
+
+		var resp struct{ Result string }
+		err := NewAPI(server.URL).Get("/manga/missing", nil, &resp)
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+			t.Fatalf("Expected a 404 StatusError, got %v", err)
+		}
+		if resp.Result != "" {
+			t.Error("Expected error bodies not to be decoded")
+		}
+	})
+
+	t.Run("gives up on persistent 429", func(t *testing.T) {
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+
+		var statusErr *StatusError
+		if err := NewAPI(server.URL).Get("/manga", nil, &struct{}{}); !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusTooManyRequests {
+			t.Fatalf("Expected a 429 StatusError, got %v", err)
+		}
+		if calls != maxRateLimitRetries+1 {
+			t.Errorf("Got %d calls, want %d", calls, maxRateLimitRetries+1)
+		}
+	})
+}
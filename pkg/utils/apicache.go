@@ -0,0 +1,257 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrOffline is returned for requests that can't be answered from the cache
+// in offline mode
+var ErrOffline = errors.New("offline and not cached")
+
+// cacheRule is how long the responses of an endpoint are cached. Patterns
+// are paths where * stands for one segment, e.g. /manga/*/feed.
+type cacheRule struct {
+	host    string
+	pattern []string
+	ttl     time.Duration
+}
+
+var cacheRules struct {
+	sync.Mutex
+	rules []cacheRule
+}
+
+func init() {
+	// Searches and details change rarely, chapter feeds more often; at-home
+	// servers hand out short-lived tokens and are never cached
+	SetCacheTTL("api.mangadex.org", "/manga", time.Hour)
+	SetCacheTTL("api.mangadex.org", "/manga/*", 24*time.Hour)
+	SetCacheTTL("api.mangadex.org", "/manga/*/feed", 15*time.Minute)
+	SetCacheTTL("api.mangadex.org", "/manga/tag", 7*24*time.Hour)
+	SetCacheTTL("api.comick.fun", "/v1.0/search", time.Hour)
+	SetCacheTTL("api.comick.fun", "/comic/*", 24*time.Hour)
+	SetCacheTTL("api.comick.fun", "/comic/*/chapters", 15*time.Minute)
+}
+
+// SetCacheTTL caches the responses of the endpoints of host matching
+// pattern for ttl, 0 stops caching them. Endpoints matching no pattern are
+// not cached.
+func SetCacheTTL(host, pattern string, ttl time.Duration) {
+	cacheRules.Lock()
+	defer cacheRules.Unlock()
+	segments := strings.Split(strings.Trim(pattern, "/"), "/")
+	for i, rule := range cacheRules.rules {
+		if rule.host == host && strings.Join(rule.pattern, "/") == strings.Join(segments, "/") {
+			cacheRules.rules[i].ttl = ttl
+			return
+		}
+	}
+	cacheRules.rules = append(cacheRules.rules, cacheRule{host: host, pattern: segments, ttl: ttl})
+}
+
+// CacheTTL returns how long the response to a GET of u is cached, 0 when
+// it is not. The pattern with the fewest * wins, /manga/tag over /manga/*.
+func CacheTTL(u *url.URL) time.Duration {
+	cacheRules.Lock()
+	defer cacheRules.Unlock()
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	ttl, wildcards := time.Duration(0), -1
+	for _, rule := range cacheRules.rules {
+		if rule.host != u.Hostname() || !matchSegments(rule.pattern, segments) {
+			continue
+		}
+		if n := strings.Count(strings.Join(rule.pattern, "/"), "*"); wildcards < 0 || n < wildcards {
+			ttl, wildcards = rule.ttl, n
+		}
+	}
+	return ttl
+}
+
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) != len(segments) {
+		return false
+	}
+	for i, segment := range pattern {
+		if segment != "*" && segment != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// responseCache is set by SetResponseCache
+var responseCache struct {
+	sync.Mutex
+	cache *APICache
+}
+
+// SetResponseCache makes API clients answer GET requests from cache, nil
+// turns caching off
+func SetResponseCache(cache *APICache) {
+	responseCache.Lock()
+	defer responseCache.Unlock()
+	responseCache.cache = cache
+}
+
+// ResponseCache returns the cache set with SetResponseCache, nil when
+// responses are not cached
+func ResponseCache() *APICache {
+	responseCache.Lock()
+	defer responseCache.Unlock()
+	return responseCache.cache
+}
+
+// DefaultResponseCacheDir returns where API responses are cached,
+// ~/.mangas/cache/api
+func DefaultResponseCacheDir() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".mangas", "cache", "api")
+}
+
+// cachedResponse is a response body kept by an APICache
+type cachedResponse struct {
+	URL     string    `json:"url"`
+	Body    []byte    `json:"body"`
+	Expires time.Time `json:"expires"`
+}
+
+// APICache keeps the bodies of API responses in memory, and on disk so the
+// next runs use them too. Expired responses are kept, offline mode still
+// serves them.
+type APICache struct {
+	dir string // "" keeps responses in memory only
+	now func() time.Time
+
+	mu     sync.Mutex
+	memory map[string]*cachedResponse
+}
+
+// NewAPICache returns a cache storing responses in dir, or in memory only
+// when dir is ""
+func NewAPICache(dir string) *APICache {
+	return &APICache{dir: dir, now: time.Now, memory: make(map[string]*cachedResponse)}
+}
+
+// Dir returns the directory responses are stored in
+func (c *APICache) Dir() string {
+	return c.dir
+}
+
+// Get returns the body cached for url and whether it is still fresh
+func (c *APICache) Get(url string) (body []byte, fresh, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	response := c.memory[url]
+	if response == nil && c.dir != "" {
+		response = c.read(url)
+		if response != nil {
+			c.memory[url] = response
+		}
+	}
+	if response == nil {
+		return nil, false, false
+	}
+	return response.Body, c.now().Before(response.Expires), true
+}
+
+// Put caches the body of the response to url for ttl
+func (c *APICache) Put(url string, body []byte, ttl time.Duration) error {
+	response := &cachedResponse{URL: url, Body: body, Expires: c.now().Add(ttl)}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.memory[url] = response
+	if c.dir == "" {
+		return nil
+	}
+	content, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	path := c.path(url)
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Clean removes the cached responses, or only the expired ones, returning
+// how many were removed and the bytes freed
+func (c *APICache) Clean(expiredOnly bool) (int, int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for url, response := range c.memory {
+		if !expiredOnly || !c.now().Before(response.Expires) {
+			delete(c.memory, url)
+		}
+	}
+	if c.dir == "" {
+		return 0, 0, nil
+	}
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+	removed, freed := 0, int64(0)
+	for _, file := range entries {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(c.dir, file.Name())
+		if expiredOnly {
+			var response cachedResponse
+			if content, err := os.ReadFile(path); err == nil && json.Unmarshal(content, &response) == nil && c.now().Before(response.Expires) {
+				continue
+			}
+		}
+		if info, err := file.Info(); err == nil {
+			freed += info.Size()
+		}
+		if err := os.Remove(path); err != nil {
+			return removed, freed, err
+		}
+		removed++
+	}
+	return removed, freed, nil
+}
+
+func (c *APICache) read(url string) *cachedResponse {
+	content, err := os.ReadFile(c.path(url))
+	if err != nil {
+		return nil
+	}
+	var response cachedResponse
+	if json.Unmarshal(content, &response) != nil || response.URL != url {
+		return nil
+	}
+	return &response
+}
+
+func (c *APICache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCacheTTL(t *testing.T) {
+	for path, want := range map[string]time.Duration{
+		"/manga":          time.Hour,
+		"/manga/abc":      24 * time.Hour,
+		"/manga/abc/feed": 15 * time.Minute,
+		"/manga/tag":      7 * 24 * time.Hour,
+		"/at-home/server": 0,
+		"/manga/abc/x/y":  0,
+	} {
+		u, _ := url.Parse("https://api.mangadex.org" + path + "?limit=10")
+		if got := CacheTTL(u); got != want {
+			t.Errorf("CacheTTL(%s) = %v, want %v", path, got, want)
+		}
+	}
+	if u, _ := url.Parse("https://example.com/manga"); CacheTTL(u) != 0 {
+		t.Error("Expected the endpoints of other hosts not to be cached")
+	}
+}
+
+func TestAPI_ResponseCache(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"result":"ok"}`))
+	}))
+	defer server.Close()
+	host, _ := url.Parse(server.URL)
+	SetCacheTTL(host.Hostname(), "/cached/*", time.Minute)
+	defer SetCacheTTL(host.Hostname(), "/cached/*", 0)
+
+	dir := t.TempDir()
+	cache := NewAPICache(dir)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	SetResponseCache(cache)
+	defer SetResponseCache(nil)
+
+	get := func(path string) error {
+		var resp struct{ Result string }
+		err := NewAPI(server.URL).Get(path, nil, &resp)
+		if err == nil && resp.Result != "ok" {
+			t.Errorf("Get(%s) = %q, want ok", path, resp.Result)
+		}
+		return err
+	}
+	for range 2 {
+		if err := get("/cached/1"); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected the second request to be answered from cache, got %d calls", calls)
+	}
+	get("/other")
+	get("/other")
+	if calls != 3 {
+		t.Errorf("Expected endpoints without a TTL not to be cached, got %d calls", calls)
+	}
+
+	// The next run reads the responses from disk
+	cache = NewAPICache(dir)
+	cache.now = func() time.Time { return now.Add(2 * time.Minute) }
+	SetResponseCache(cache)
+	SetOffline(true)
+	defer SetOffline(false)
+	if err := get("/cached/1"); err != nil {
+		t.Errorf("Expected an expired response to be served offline, got %v", err)
+	}
+	if err := get("/cached/2"); !errors.Is(err, ErrOffline) {
+		t.Errorf("Expected ErrOffline for a response not cached, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected no request offline, got %d calls", calls-3)
+	}
+
+	SetOffline(false)
+	get("/cached/1")
+	if calls != 4 {
+		t.Errorf("Expected an expired response to be fetched again, got %d calls", calls)
+	}
+}
+
+func TestAPICache_Clean(t *testing.T) {
+	cache := NewAPICache(t.TempDir())
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	cache.Put("https://example.com/short", []byte("{}"), time.Minute)
+	cache.Put("https://example.com/long", []byte("{}"), time.Hour)
+
+	cache.now = func() time.Time { return now.Add(2 * time.Minute) }
+	removed, _, err := cache.Clean(true)
+	if err != nil || removed != 1 {
+		t.Fatalf("Clean(expired) = %d, %v, want 1 removed", removed, err)
+	}
+	if _, _, ok := cache.Get("https://example.com/long"); !ok {
+		t.Error("Expected a fresh response to be kept")
+	}
+	removed, freed, err := cache.Clean(false)
+	if err != nil || removed != 1 || freed == 0 {
+		t.Fatalf("Clean() = %d, %d, %v, want 1 removed", removed, freed, err)
+	}
+	if _, _, ok := cache.Get("https://example.com/long"); ok {
+		t.Error("Expected every response to be removed")
+	}
+}
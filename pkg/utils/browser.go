@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// OpenBrowser opens a URL in the user's default browser
+func OpenBrowser(url string) error {
+	if url == "" {
+		return fmt.Errorf("no URL to open")
+	}
+	if err := openDefault(url); err != nil {
+		return fmt.Errorf("failed to open browser: %w", err)
+	}
+	return nil
+}
+
+// OpenFile opens a file with the default application for its type, such as
+// the e-book reader for a downloaded chapter
+func OpenFile(path string) error {
+	if path == "" {
+		return fmt.Errorf("no file to open")
+	}
+	if err := openDefault(path); err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	return nil
+}
+
+// openDefault hands a URL or a path to the desktop
+func openDefault(target string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", target)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		cmd = exec.Command("xdg-open", target)
+	}
+	return cmd.Start()
+}
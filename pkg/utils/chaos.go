@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChaosOptions are the faults a ChaosTransport injects, to see how retries
+// and resumed downloads cope with a bad network. Rates are probabilities
+// between 0 and 1, drawn for every request.
+type ChaosOptions struct {
+	Latency  time.Duration // Delay before each request, give or take half of it
+	Errors   float64       // Requests answered with 500 Internal Server Error
+	Drops    float64       // Connections dropped before an answer
+	Truncate float64       // Bodies cut short, failing with io.ErrUnexpectedEOF
+	Seed     int64         // Makes the faults reproducible, 0 picks a random seed
+}
+
+// DefaultChaos is a flaky but usable network
+var DefaultChaos = ChaosOptions{Latency: 200 * time.Millisecond, Errors: 0.1, Drops: 0.05, Truncate: 0.05}
+
+// ParseChaos parses comma separated faults such as
+// "latency=300ms,errors=0.2,drops=0.1,truncate=0.1,seed=42". Faults not
+// given are off, "default" stands for DefaultChaos.
+func ParseChaos(spec string) (ChaosOptions, error) {
+	if spec == "default" {
+		return DefaultChaos, nil
+	}
+
+	var options ChaosOptions
+	for _, part := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return options, fmt.Errorf("invalid chaos setting %q (use name=value)", part)
+		}
+
+		var err error
+		switch name {
+		case "latency":
+			options.Latency, err = time.ParseDuration(value)
+		case "errors":
+			options.Errors, err = parseRate(value)
+		case "drops":
+			options.Drops, err = parseRate(value)
+		case "truncate":
+			options.Truncate, err = parseRate(value)
+		case "seed":
+			options.Seed, err = strconv.ParseInt(value, 10, 64)
+		default:
+			return options, fmt.Errorf("unknown chaos setting %q (use latency, errors, drops, truncate or seed)", name)
+		}
+		if err != nil {
+			return options, fmt.Errorf("invalid chaos %s %q: %w", name, value, err)
+		}
+	}
+	return options, nil
+}
+
+func parseRate(value string) (float64, error) {
+	rate, err := strconv.ParseFloat(value, 64)
+	if err == nil && (rate < 0 || rate > 1) {
+		err = fmt.Errorf("not between 0 and 1")
+	}
+	return rate, err
+}
+
+// ChaosTransport is a RoundTripper injecting network faults into the
+// requests it forwards
+type ChaosTransport struct {
+	base    http.RoundTripper
+	options ChaosOptions
+
+	mu     sync.Mutex
+	random *rand.Rand
+}
+
+// NewChaosTransport wraps base, http.DefaultTransport when nil
+func NewChaosTransport(base http.RoundTripper, options ChaosOptions) *ChaosTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	seed := options.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &ChaosTransport{base: base, options: options, random: rand.New(rand.NewSource(seed))}
+}
+
+// roll reports whether a fault happening at rate happens now
+func (t *ChaosTransport) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.random.Float64() < rate
+}
+
+// delay returns the latency of a request
+func (t *ChaosTransport) delay() time.Duration {
+	if t.options.Latency <= 0 {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.options.Latency/2 + time.Duration(t.random.Int63n(int64(t.options.Latency)))
+}
+
+func (t *ChaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if delay := t.delay(); delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	if t.roll(t.options.Drops) {
+		return nil, fmt.Errorf("chaos: connection to %s dropped", req.URL.Host)
+	}
+	if t.roll(t.options.Errors) {
+		return &http.Response{
+			Status:     "500 Internal Server Error",
+			StatusCode: http.StatusInternalServerError,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{"Content-Type": {"text/plain"}},
+			Body:       io.NopCloser(strings.NewReader("chaos: internal server error")),
+			Request:    req,
+		}, nil
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || !t.roll(t.options.Truncate) {
+		return resp, err
+	}
+	// Cut the body somewhere in its first half, like a connection closed
+	// mid-transfer
+	limit := int64(0)
+	if resp.ContentLength > 1 {
+		t.mu.Lock()
+		limit = t.random.Int63n(resp.ContentLength / 2)
+		t.mu.Unlock()
+	}
+	resp.Body = &truncatedBody{body: resp.Body, remaining: limit}
+	return resp, nil
+}
+
+// truncatedBody fails with io.ErrUnexpectedEOF after remaining bytes
+type truncatedBody struct {
+	body      io.ReadCloser
+	remaining int64
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.body.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func (b *truncatedBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseChaos(t *testing.T) {
+	if got, err := ParseChaos("default"); err != nil || got != DefaultChaos {
+		t.Errorf("ParseChaos(default) = %+v, %v, want DefaultChaos", got, err)
+	}
+
+	got, err := ParseChaos("latency=300ms, errors=0.2,drops=0.1,truncate=0.5,seed=42")
+	if err != nil {
+		t.Fatalf("ParseChaos() error = %v", err)
+	}
+	want := ChaosOptions{Latency: 300 * time.Millisecond, Errors: 0.2, Drops: 0.1, Truncate: 0.5, Seed: 42}
+	if got != want {
+		t.Errorf("ParseChaos() = %+v, want %+v", got, want)
+	}
+
+	for _, spec := range []string{"errors", "errors=2", "drops=-0.1", "latency=soon", "jitter=1"} {
+		if _, err := ParseChaos(spec); err == nil {
+			t.Errorf("ParseChaos(%q) should fail", spec)
+		}
+	}
+}
+
+func TestChaosTransport(t *testing.T) {
+	body := strings.Repeat("manga page ", 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	defer server.Close()
+
+	get := func(options ChaosOptions) (*http.Response, error) {
+		client := &http.Client{Transport: NewChaosTransport(nil, options)}
+		return client.Get(server.URL)
+	}
+
+	t.Run("passes requests through", func(t *testing.T) {
+		resp, err := get(ChaosOptions{Seed: 1})
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		defer resp.Body.Close()
+		content, err := io.ReadAll(resp.Body)
+		if err != nil || string(content) != body {
+			t.Errorf("body = %d bytes, %v, want %d bytes", len(content), err, len(body))
+		}
+	})
+
+	t.Run("drops connections", func(t *testing.T) {
+		if _, err := get(ChaosOptions{Drops: 1}); err == nil {
+			t.Error("Get() should fail when the connection is dropped")
+		}
+	})
+
+	t.Run("answers with errors", func(t *testing.T) {
+		resp, err := get(ChaosOptions{Errors: 1})
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusInternalServerError {
+			t.Errorf("status = %d, want 500", resp.StatusCode)
+		}
+	})
+
+	t.Run("truncates bodies", func(t *testing.T) {
+		resp, err := get(ChaosOptions{Truncate: 1, Seed: 7})
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		defer resp.Body.Close()
+		content, err := io.ReadAll(resp.Body)
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("ReadAll() error = %v, want io.ErrUnexpectedEOF", err)
+		}
+		if len(content) >= len(body)/2 {
+			t.Errorf("read %d bytes, want less than half of %d", len(content), len(body))
+		}
+	})
+
+	t.Run("adds latency", func(t *testing.T) {
+		start := time.Now()
+		resp, err := get(ChaosOptions{Latency: 40 * time.Millisecond})
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		resp.Body.Close()
+		if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+			t.Errorf("request took %v, want at least 20ms", elapsed)
+		}
+	})
+}
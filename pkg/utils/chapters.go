@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// NormalizeChapterNumber returns the canonical form of a chapter number, so
+// "010", "10.50" and "Ch. 10.5" compare equal to "10" and "10.5".
+// Numbers that are not numeric are returned trimmed.
+func NormalizeChapterNumber(number string) string {
+	number = strings.TrimSpace(number)
+	trimmed := strings.ToLower(number)
+	for _, prefix := range []string{"chapter", "ch.", "ch"} {
+		if strings.HasPrefix(trimmed, prefix) {
+			trimmed = strings.TrimSpace(trimmed[len(prefix):])
+			break
+		}
+	}
+	trimmed = strings.Replace(trimmed, ",", ".", 1)
+
+	whole, fraction, hasFraction := strings.Cut(trimmed, ".")
+	if whole == "" || !isDigits(whole) || (hasFraction && !isDigits(fraction)) {
+		return number
+	}
+
+	whole = strings.TrimLeft(whole, "0")
+	if whole == "" {
+		whole = "0"
+	}
+	fraction = strings.TrimRight(fraction, "0")
+	if fraction == "" {
+		return whole
+	}
+	return whole + "." + fraction
+}
+
+// ChapterSortKey returns a fixed-width key that sorts chapter numbers
+// numerically when compared as strings: "2" < "10" < "10.5" < "100".
+// Non-numeric numbers sort after every numeric one.
+func ChapterSortKey(number string) string {
+	normalized := NormalizeChapterNumber(number)
+	whole, fraction, _ := strings.Cut(normalized, ".")
+	if whole == "" || !isDigits(whole) || !isDigits(fraction) || len(whole) > 8 {
+		return "~" + strings.ToLower(normalized)
+	}
+
+	if len(fraction) > 4 {
+		fraction = fraction[:4]
+	}
+	return strings.Repeat("0", 8-len(whole)) + whole + "." + fraction + strings.Repeat("0", 4-len(fraction))
+}
+
+// NaturalLess compares strings treating digit runs as numbers, so
+// "page_2" sorts before "page_10"
+func NaturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		da, db := leadingDigits(a), leadingDigits(b)
+		if da != "" && db != "" {
+			na, nb := strings.TrimLeft(da, "0"), strings.TrimLeft(db, "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			a, b = a[len(da):], b[len(db):]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	// "Ch. 12", "Chapter 12.5", "Episode 3", "Ep.4", "#7"
+	chapterMarkerPattern = regexp.MustCompile(`(?i)(?:^|[^\p{L}])(?:chapter|chap|ch|episode|ep)\.?\s*(\d+(?:[.,]\d+)?)|#\s*(\d+(?:[.,]\d+)?)`)
+	// "第12話"
+	cjkChapterPattern = regexp.MustCompile(`第\s*(\d+(?:\.\d+)?)\s*[話话章回]`)
+	// "12 - The Duel", "12.5: Extra"
+	leadingNumberPattern = regexp.MustCompile(`^\s*(\d+(?:[.,]\d+)?)(?:$|[\s:.\-–—)])`)
+)
+
+// InferChapterNumber extracts the chapter number from a title like
+// "Ch. 12 — The Duel" or "12 - The Duel", for sources that leave the number
+// empty. It returns "" when the title has no recognizable number.
+func InferChapterNumber(title string) string {
+	for _, pattern := range []*regexp.Regexp{chapterMarkerPattern, cjkChapterPattern, leadingNumberPattern} {
+		match := pattern.FindStringSubmatch(title)
+		if match == nil {
+			continue
+		}
+		for _, group := range match[1:] {
+			if group != "" {
+				return NormalizeChapterNumber(group)
+			}
+		}
+	}
+	return ""
+}
+
+// ChapterNumber returns number, or the number inferred from title when the
+// source left it empty
+func ChapterNumber(number, title string) string {
+	if strings.TrimSpace(number) != "" {
+		return number
+	}
+	return InferChapterNumber(title)
+}
+
+// IsNumericChapter reports whether number is a plain chapter number like
+// "12" or "10.5" once normalized
+func IsNumericChapter(number string) bool {
+	whole, fraction, _ := strings.Cut(NormalizeChapterNumber(number), ".")
+	return whole != "" && isDigits(whole) && isDigits(fraction)
+}
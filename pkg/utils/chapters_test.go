@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeChapterNumber(t *testing.T) {
+	tests := []struct {
+		number string
+		want   string
+	}{
+		{"10", "10"},
+		{"010", "10"},
+		{"10.50", "10.5"},
+		{"10.0", "10"},
+		{"0", "0"},
+		{"000.5", "0.5"},
+		{" Ch. 12 ", "12"},
+		{"Chapter 7", "7"},
+		{"10,5", "10.5"},
+		{"Extra", "Extra"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeChapterNumber(tt.number); got != tt.want {
+			t.Errorf("NormalizeChapterNumber(%q) = %q, want %q", tt.number, got, tt.want)
+		}
+	}
+}
+
+func TestChapterSortKey(t *testing.T) {
+	numbers := []string{"Extra", "100", "10.5", "2", "010", "0.5", "10.25", "1"}
+	sort.Slice(numbers, func(i, j int) bool {
+		return ChapterSortKey(numbers[i]) < ChapterSortKey(numbers[j])
+	})
+
+	want := "0.5,1,2,010,10.25,10.5,100,Extra"
+	if got := strings.Join(numbers, ","); got != want {
+		t.Errorf("sorted chapters = %s, want %s", got, want)
+	}
+
+	if ChapterSortKey("010") != ChapterSortKey("10.0") {
+		t.Error("equivalent chapter numbers should share a sort key")
+	}
+}
+
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"page2.png", "page10.png", true},
+		{"page10.png", "page2.png", false},
+		{"page002.png", "page10.png", true},
+		{"page_9999.png", "page_10000.png", true},
+		{"a.png", "b.png", true},
+		{"ch1/p9.png", "ch2/p1.png", true},
+	}
+
+	for _, tt := range tests {
+		if got := NaturalLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("NaturalLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestInferChapterNumber(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Ch. 12 — The Duel", "12"},
+		{"Chapter 012.50: Extra", "12.5"},
+		{"Vol. 3 Ch.7", "7"},
+		{"Episode 4", "4"},
+		{"ep.05", "5"},
+		{"#21 Homecoming", "21"},
+		{"第15話 再会", "15"},
+		{"12 - The Duel", "12"},
+		{"3: Start", "3"},
+		{"Epilogue 2", ""},
+		{"2nd Season Begins", ""},
+		{"Oneshot", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := InferChapterNumber(tt.title); got != tt.want {
+			t.Errorf("InferChapterNumber(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+
+	if got := ChapterNumber("8", "Ch. 12"); got != "8" {
+		t.Errorf("ChapterNumber() = %q, want the source number", got)
+	}
+	if got := ChapterNumber(" ", "Ch. 12"); got != "12" {
+		t.Errorf("ChapterNumber() = %q, want the inferred number", got)
+	}
+}
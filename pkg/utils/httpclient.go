@@ -0,0 +1,226 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClientOptions configures the HTTP client the requests of a source are
+// sent with, both API calls and image downloads
+type ClientOptions struct {
+	UserAgent string        // Replaces Go's User-Agent when set
+	Header    http.Header   // Sent with every request, e.g. the Referer some image hosts require
+	Proxy     string        // http, https or socks5 URL, "direct" for none; "" follows HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	Timeout   time.Duration // Limit for a whole request, 0 for none
+	Chaos     *ChaosOptions // Network faults injected into the requests, for testing
+}
+
+// IsZero reports whether no option is set
+func (o ClientOptions) IsZero() bool {
+	return o.UserAgent == "" && len(o.Header) == 0 && o.Proxy == "" && o.Timeout == 0 && o.Chaos == nil
+}
+
+// Merge returns the options with the ones set in override replacing them.
+// Headers are combined, override winning for the same name.
+func (o ClientOptions) Merge(override ClientOptions) ClientOptions {
+	merged := o
+	if override.UserAgent != "" {
+		merged.UserAgent = override.UserAgent
+	}
+	if override.Proxy != "" {
+		merged.Proxy = override.Proxy
+	}
+	if override.Timeout != 0 {
+		merged.Timeout = override.Timeout
+	}
+	if override.Chaos != nil {
+		merged.Chaos = override.Chaos
+	}
+	if len(override.Header) > 0 {
+		merged.Header = o.Header.Clone()
+		if merged.Header == nil {
+			merged.Header = make(http.Header)
+		}
+		for key, values := range override.Header {
+			merged.Header[key] = values
+		}
+	}
+	return merged
+}
+
+// ParseHeader parses a "Name: value" header
+func ParseHeader(header string) (string, string, error) {
+	name, value, ok := strings.Cut(header, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return "", "", fmt.Errorf("invalid header %q (use \"Name: value\")", header)
+	}
+	return textproto.CanonicalMIMEHeaderKey(name), strings.TrimSpace(value), nil
+}
+
+// NewHTTPClient returns a client sending requests with options
+func NewHTTPClient(options ClientOptions) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	switch options.Proxy {
+	case "":
+		// The default transport follows the environment
+	case "direct":
+		transport.Proxy = nil
+	default:
+		proxy, err := url.Parse(options.Proxy)
+		if err != nil || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid proxy %q", options.Proxy)
+		}
+		switch proxy.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q (use http, https or socks5)", proxy.Scheme)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	var roundTripper http.RoundTripper = transport
+	if options.Chaos != nil {
+		roundTripper = NewChaosTransport(transport, *options.Chaos)
+	}
+	if options.UserAgent != "" || len(options.Header) > 0 {
+		roundTripper = &headerTransport{base: roundTripper, userAgent: options.UserAgent, header: options.Header}
+	}
+	return &http.Client{Transport: roundTripper, Timeout: options.Timeout}, nil
+}
+
+// headerTransport adds headers to the requests not setting them already
+type headerTransport struct {
+	base      http.RoundTripper
+	userAgent string
+	header    http.Header
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for key, values := range t.header {
+		if req.Header.Get(key) == "" {
+			req.Header[key] = values
+		}
+	}
+	if t.userAgent != "" && req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// offlineTransport fails every request, in offline mode
+type offlineTransport struct{}
+
+func (offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, ErrOffline
+}
+
+// clients holds the options set with SetClientOptions and the clients built
+// from them
+var clients = struct {
+	sync.Mutex
+	defaults ClientOptions
+	sources  map[string]ClientOptions
+	built    map[string]*http.Client
+	offline  bool
+}{sources: make(map[string]ClientOptions), built: make(map[string]*http.Client)}
+
+// SetOffline turns offline mode on or off. Offline, no request reaches the
+// network: API clients only answer from the response cache, and every other
+// request fails with ErrOffline.
+func SetOffline(offline bool) {
+	clients.Lock()
+	defer clients.Unlock()
+	clients.offline = offline
+	clients.built = make(map[string]*http.Client)
+}
+
+// Offline reports whether offline mode is on
+func Offline() bool {
+	clients.Lock()
+	defer clients.Unlock()
+	return clients.offline
+}
+
+// SetClientOptions sets the options of the HTTP client of source, or the
+// defaults of every source when source is "". Source options are merged
+// over the defaults.
+func SetClientOptions(source string, options ClientOptions) error {
+	if _, err := NewHTTPClient(options); err != nil {
+		return err
+	}
+
+	clients.Lock()
+	defer clients.Unlock()
+	if source == "" {
+		clients.defaults = options
+	} else {
+		clients.sources[source] = options
+	}
+	clients.built = make(map[string]*http.Client)
+	return nil
+}
+
+// HTTPClient returns the client for the requests of source, "" for requests
+// of no source in particular. Without options it is http.DefaultClient;
+// offline, a client failing every request.
+func HTTPClient(source string) *http.Client {
+	clients.Lock()
+	defer clients.Unlock()
+	if client, ok := clients.built[source]; ok {
+		return client
+	}
+
+	options := clients.defaults.Merge(clients.sources[source])
+	client := http.DefaultClient
+	if clients.offline {
+		client = &http.Client{Transport: offlineTransport{}}
+	} else if !options.IsZero() {
+		// The options were checked by SetClientOptions
+		client, _ = NewHTTPClient(options)
+	}
+	clients.built[source] = client
+	return client
+}
+
+// ClientOptionsFromEnv reads the options of source from the environment:
+// MANGAS_USER_AGENT, MANGAS_HEADERS (one "Name: value" per line), MANGAS_PROXY
+// and MANGAS_HTTP_TIMEOUT, or MANGAS_<SOURCE>_USER_AGENT and so on for a
+// source.
+func ClientOptionsFromEnv(source string, getenv func(string) string) (ClientOptions, error) {
+	prefix := "MANGAS_"
+	if source != "" {
+		prefix += strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(source)) + "_"
+	}
+
+	options := ClientOptions{
+		UserAgent: getenv(prefix + "USER_AGENT"),
+		Proxy:     getenv(prefix + "PROXY"),
+	}
+	for _, line := range strings.Split(getenv(prefix+"HEADERS"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		name, value, err := ParseHeader(line)
+		if err != nil {
+			return options, fmt.Errorf("%sHEADERS: %w", prefix, err)
+		}
+		if options.Header == nil {
+			options.Header = make(http.Header)
+		}
+		options.Header.Add(name, value)
+	}
+	if timeout := getenv(prefix + "HTTP_TIMEOUT"); timeout != "" {
+		var err error
+		if options.Timeout, err = time.ParseDuration(timeout); err != nil {
+			return options, fmt.Errorf("%sHTTP_TIMEOUT: %w", prefix, err)
+		}
+	}
+	return options, nil
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientOptions(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	defer SetClientOptions("", ClientOptions{})
+	defer SetClientOptions("test-source", ClientOptions{})
+
+	if HTTPClient("test-source") != http.DefaultClient {
+		t.Fatal("Expected the default client without options")
+	}
+
+	SetClientOptions("", ClientOptions{UserAgent: "mangas/1.0", Header: http.Header{"Referer": {"https://default/"}}})
+	SetClientOptions("test-source", ClientOptions{Header: http.Header{"Referer": {"https://source/"}}, Timeout: time.Minute})
+
+	var v struct{}
+	if err := NewSourceAPI("test-source", server.URL).Get("/", nil, &v); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Get("User-Agent") != "mangas/1.0" || got.Get("Referer") != "https://source/" {
+		t.Errorf("Expected the source options over the defaults, got %v", got)
+	}
+	if HTTPClient("test-source").Timeout != time.Minute || HTTPClient("other").Timeout != 0 {
+		t.Error("Expected the timeout to apply to the source only")
+	}
+
+	// Headers of the request win
+	if err := NewAPI(server.URL).GetWithHeader("/", nil, http.Header{"Referer": {"https://request/"}}, &v); err != nil {
+		t.Fatalf("GetWithHeader() error = %v", err)
+	}
+	if got.Get("Referer") != "https://request/" {
+		t.Errorf("Expected the request header to be kept, got %q", got.Get("Referer"))
+	}
+
+	for _, proxy := range []string{"ftp://proxy:21", "not a url"} {
+		if err := SetClientOptions("", ClientOptions{Proxy: proxy}); err == nil {
+			t.Errorf("Expected proxy %q to be rejected", proxy)
+		}
+	}
+	if err := SetClientOptions("", ClientOptions{Proxy: "socks5://127.0.0.1:1080"}); err != nil {
+		t.Errorf("SetClientOptions() error = %v", err)
+	}
+}
+
+func TestClientOptionsFromEnv(t *testing.T) {
+	env := map[string]string{
+		"MANGAS_PROXY":               "http://proxy:3128",
+		"MANGAS_COMICK_USER_AGENT":   "Mozilla/5.0",
+		"MANGAS_COMICK_HEADERS":      "Referer: https://comick.io/\nX-Requested-With: mangas",
+		"MANGAS_COMICK_HTTP_TIMEOUT": "45s",
+	}
+	getenv := func(key string) string { return env[key] }
+
+	defaults, err := ClientOptionsFromEnv("", getenv)
+	if err != nil || defaults.Proxy != "http://proxy:3128" || defaults.UserAgent != "" {
+		t.Errorf("ClientOptionsFromEnv() = %+v, %v", defaults, err)
+	}
+	comick, err := ClientOptionsFromEnv("comick", getenv)
+	if err != nil {
+		t.Fatalf("ClientOptionsFromEnv() error = %v", err)
+	}
+	if comick.UserAgent != "Mozilla/5.0" || comick.Timeout != 45*time.Second || comick.Header.Get("Referer") != "https://comick.io/" || comick.Header.Get("X-Requested-With") != "mangas" {
+		t.Errorf("Unexpected options: %+v", comick)
+	}
+
+	env["MANGAS_COMICK_HEADERS"] = "no colon"
+	if _, err := ClientOptionsFromEnv("comick", getenv); err == nil {
+		t.Error("Expected an invalid header to fail")
+	}
+}
@@ -0,0 +1,300 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// IconsEnv names the environment variable choosing the icon set, it wins
+// over the icons config key
+const IconsEnv = "MANGAS_ICONS"
+
+// Icon sets, see SetIconSet
+const (
+	IconSetAuto  = "auto"  // Detected from the terminal
+	IconSetEmoji = "emoji" // Emoji, the default on UTF-8 terminals
+	IconSetNerd  = "nerd"  // Nerd Font glyphs, for patched terminal fonts
+	IconSetASCII = "ascii" // Plain ASCII, for terminals without Unicode
+)
+
+// IconSets are the names accepted by SetIconSet
+var IconSets = []string{IconSetAuto, IconSetEmoji, IconSetNerd, IconSetASCII}
+
+// Icon is a glyph of the user-facing output, printed from the current icon
+// set so messages read the same whatever the terminal can render
+type Icon int
+
+const (
+	IconSuccess  Icon = iota // Finished fine
+	IconFailure              // Failed
+	IconCheck                // An item that went fine
+	IconCross                // An item that failed
+	IconWarning              // Something was skipped or degraded
+	IconInfo                 // A notice
+	IconTip                  // A hint on what to do next
+	IconBullet               // A list item
+	IconArrow                // Leads to
+	IconDownload             // Downloads, queues and imports
+	IconUpload               // Exports of the library
+	IconLibrary              // The library and collections
+	IconBook                 // Chapters and books
+	IconSearch               // Searches and scans
+	IconBlocked              // Blocklist
+	IconSync                 // Updates, refreshes and conversions
+	IconDelete               // Removals
+	IconKey                  // Logins
+	IconPlug                 // Connected readers
+	IconDevice               // Device profiles
+	IconNetwork              // Servers and sources
+	IconDisk                 // Storage
+	IconWeb                  // Web pages and translations
+	IconClean                // Cache cleanups
+	IconImage                // Pages and covers
+	IconPackage              // Collection exports
+	IconRestore              // Restores and resumed work
+	IconPause                // Paused
+	IconResume               // Resumed
+	IconSkip                 // Skipped
+	IconWait                 // Waiting
+	IconLock                 // Locked
+	IconUnlock               // Unlocked
+	IconChoice               // Several candidates
+	IconEmpty                // Nothing there
+	IconNote                 // Reports
+	IconList                 // Plans and selections
+	IconStats                // Statistics
+	IconSettings             // Filters and settings
+	IconNew                  // New chapters
+	IconMove                 // Reordering
+	IconFolder               // Output files
+	IconHome                 // The dashboard
+	IconError                // Errors
+	IconPalette              // The command palette
+	IconStop                 // Stopped on request
+	IconTime                 // Durations
+	IconRepair               // Repairs
+	iconCount
+)
+
+// iconSets holds the glyphs of each icon set. Emoji drawn with a variation
+// selector take a trailing space, as terminals often render them one cell
+// narrower than they advance.
+var iconSets = map[string][iconCount]string{
+	IconSetEmoji: {
+		IconSuccess:  "✅",
+		IconFailure:  "❌",
+		IconCheck:    "✓",
+		IconCross:    "✗",
+		IconWarning:  "⚠️ ",
+		IconInfo:     "ℹ️ ",
+		IconTip:      "💡",
+		IconBullet:   "•",
+		IconArrow:    "→",
+		IconDownload: "📥",
+		IconUpload:   "📤",
+		IconLibrary:  "📚",
+		IconBook:     "📖",
+		IconSearch:   "🔍",
+		IconBlocked:  "🚫",
+		IconSync:     "🔄",
+		IconDelete:   "🗑️ ",
+		IconKey:      "🔑",
+		IconPlug:     "🔌",
+		IconDevice:   "📱",
+		IconNetwork:  "📡",
+		IconDisk:     "💾",
+		IconWeb:      "🌐",
+		IconClean:    "🧹",
+		IconImage:    "🖼️ ",
+		IconPackage:  "📦",
+		IconRestore:  "♻️ ",
+		IconPause:    "⏸️ ",
+		IconResume:   "▶️ ",
+		IconSkip:     "⏭️ ",
+		IconWait:     "⏳",
+		IconLock:     "🔒",
+		IconUnlock:   "🔓",
+		IconChoice:   "🔀",
+		IconEmpty:    "📭",
+		IconNote:     "📝",
+		IconList:     "📋",
+		IconStats:    "📊",
+		IconSettings: "🎛️ ",
+		IconNew:      "✨",
+		IconMove:     "↕️ ",
+		IconFolder:   "📁",
+		IconHome:     "🏠",
+		IconError:    "⚠️ ",
+		IconPalette:  "⌘",
+		IconStop:     "⏹️ ",
+		IconTime:     "⏱ ",
+		IconRepair:   "🔧",
+	},
+	// Font Awesome glyphs, shipped by every Nerd Font
+	IconSetNerd: {
+		IconSuccess:  "\uf058",
+		IconFailure:  "\uf057",
+		IconCheck:    "\uf00c",
+		IconCross:    "\uf00d",
+		IconWarning:  "\uf071",
+		IconInfo:     "\uf05a",
+		IconTip:      "\uf0eb",
+		IconBullet:   "•",
+		IconArrow:    "\uf061",
+		IconDownload: "\uf019",
+		IconUpload:   "\uf093",
+		IconLibrary:  "\uf02d",
+		IconBook:     "\uf02d",
+		IconSearch:   "\uf002",
+		IconBlocked:  "\uf05e",
+		IconSync:     "\uf021",
+		IconDelete:   "\uf1f8",
+		IconKey:      "\uf084",
+		IconPlug:     "\uf1e6",
+		IconDevice:   "\uf10b",
+		IconNetwork:  "\uf09e",
+		IconDisk:     "\uf0a0",
+		IconWeb:      "\uf0ac",
+		IconClean:    "\uf12d",
+		IconImage:    "\uf03e",
+		IconPackage:  "\uf187",
+		IconRestore:  "\uf1b8",
+		IconPause:    "\uf04c",
+		IconResume:   "\uf04b",
+		IconSkip:     "\uf051",
+		IconWait:     "\uf254",
+		IconLock:     "\uf023",
+		IconUnlock:   "\uf09c",
+		IconChoice:   "\uf074",
+		IconEmpty:    "\uf01c",
+		IconNote:     "\uf040",
+		IconList:     "\uf03a",
+		IconStats:    "\uf080",
+		IconSettings: "\uf1de",
+		IconNew:      "\uf005",
+		IconMove:     "\uf07d",
+		IconFolder:   "\uf07b",
+		IconHome:     "\uf015",
+		IconError:    "\uf06a",
+		IconPalette:  "\uf120",
+		IconStop:     "\uf04d",
+		IconTime:     "\uf017",
+		IconRepair:   "\uf0ad",
+	},
+	IconSetASCII: {
+		IconSuccess:  "[ok]",
+		IconFailure:  "[x]",
+		IconCheck:    "+",
+		IconCross:    "x",
+		IconWarning:  "[!]",
+		IconInfo:     "[i]",
+		IconTip:      "[i]",
+		IconBullet:   "-",
+		IconArrow:    "->",
+		IconDownload: "*",
+		IconUpload:   "*",
+		IconLibrary:  "*",
+		IconBook:     "*",
+		IconSearch:   "*",
+		IconBlocked:  "*",
+		IconSync:     "*",
+		IconDelete:   "*",
+		IconKey:      "*",
+		IconPlug:     "*",
+		IconDevice:   "*",
+		IconNetwork:  "*",
+		IconDisk:     "*",
+		IconWeb:      "*",
+		IconClean:    "*",
+		IconImage:    "*",
+		IconPackage:  "*",
+		IconRestore:  "*",
+		IconPause:    "||",
+		IconResume:   ">",
+		IconSkip:     ">>",
+		IconWait:     "...",
+		IconLock:     "(locked)",
+		IconUnlock:   "*",
+		IconChoice:   "[?]",
+		IconEmpty:    "*",
+		IconNote:     "*",
+		IconList:     "*",
+		IconStats:    "*",
+		IconSettings: "*",
+		IconNew:      "+",
+		IconMove:     "*",
+		IconFolder:   "*",
+		IconHome:     "*",
+		IconError:    "[!]",
+		IconPalette:  ">",
+		IconStop:     "[]",
+		IconTime:     "*",
+		IconRepair:   "*",
+	},
+}
+
+// currentIcons is the icon set in use, detected at startup
+var currentIcons = iconSets[DetectIconSet(os.Getenv)]
+
+// String returns the glyph of the icon in the current icon set
+func (i Icon) String() string {
+	if i < 0 || i >= iconCount {
+		return ""
+	}
+	return currentIcons[i]
+}
+
+// SetIconSet switches the icon set used by every Icon. IconSetAuto, or "",
+// detects it again from the environment.
+func SetIconSet(name string) error {
+	if err := ValidateIconSet(name); err != nil {
+		return err
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || name == IconSetAuto {
+		name = DetectIconSet(os.Getenv)
+	}
+	currentIcons = iconSets[name]
+	return nil
+}
+
+// ValidateIconSet fails for names SetIconSet doesn't accept
+func ValidateIconSet(name string) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if _, ok := iconSets[name]; ok || name == "" || name == IconSetAuto {
+		return nil
+	}
+	return fmt.Errorf("unknown icon set %q, expected one of %s", name, strings.Join(IconSets, ", "))
+}
+
+// DetectIconSet returns the icon set suiting the terminal: the one named by
+// MANGAS_ICONS, ASCII when the locale isn't UTF-8 or the terminal is the
+// Linux console, emoji otherwise. Nerd Font glyphs are never detected, they
+// depend on the font and must be asked for.
+func DetectIconSet(getenv func(string) string) string {
+	if name := strings.ToLower(getenv(IconsEnv)); name != IconSetAuto {
+		if _, ok := iconSets[name]; ok {
+			return name
+		}
+	}
+
+	switch getenv("TERM") {
+	case "dumb", "linux":
+		return IconSetASCII
+	}
+
+	// The first locale variable set decides the character encoding
+	for _, variable := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		locale := getenv(variable)
+		if locale == "" {
+			continue
+		}
+		locale = strings.ToLower(strings.ReplaceAll(locale, "-", ""))
+		if !strings.Contains(locale, "utf8") {
+			return IconSetASCII
+		}
+		break
+	}
+	return IconSetEmoji
+}
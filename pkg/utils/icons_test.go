@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDetectIconSet(t *testing.T) {
+	tests := []struct {
+		env  map[string]string
+		want string
+	}{
+		{map[string]string{}, IconSetEmoji},
+		{map[string]string{"LANG": "en_US.UTF-8"}, IconSetEmoji},
+		{map[string]string{"LANG": "en_US.utf8"}, IconSetEmoji},
+		{map[string]string{"LANG": "C"}, IconSetASCII},
+		{map[string]string{"LANG": "en_US.UTF-8", "LC_ALL": "POSIX"}, IconSetASCII},
+		{map[string]string{"LANG": "C", "LC_CTYPE": "de_DE.UTF-8"}, IconSetEmoji},
+		{map[string]string{"LANG": "en_US.UTF-8", "TERM": "linux"}, IconSetASCII},
+		{map[string]string{"TERM": "dumb"}, IconSetASCII},
+		{map[string]string{"TERM": "dumb", IconsEnv: "nerd"}, IconSetNerd},
+		{map[string]string{"LANG": "C", IconsEnv: "Emoji"}, IconSetEmoji},
+		{map[string]string{"LANG": "C", IconsEnv: "auto"}, IconSetASCII},
+		{map[string]string{IconsEnv: "bogus"}, IconSetEmoji},
+	}
+
+	for _, tt := range tests {
+		got := DetectIconSet(func(name string) string { return tt.env[name] })
+		if got != tt.want {
+			t.Errorf("DetectIconSet(%v) = %q, want %q", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestIconSets_Complete(t *testing.T) {
+	for name, set := range iconSets {
+		for icon, glyph := range set {
+			if glyph == "" {
+				t.Errorf("icon %d has no glyph in the %s set", icon, name)
+			}
+		}
+	}
+
+	for _, glyph := range iconSets[IconSetASCII] {
+		for _, r := range glyph {
+			if r > 127 {
+				t.Errorf("ASCII glyph %q isn't ASCII", glyph)
+			}
+		}
+	}
+}
+
+func TestSetIconSet(t *testing.T) {
+	defer SetIconSet(IconSetAuto)
+
+	if err := SetIconSet("ascii"); err != nil {
+		t.Fatalf("SetIconSet failed: %v", err)
+	}
+	if got := fmt.Sprintf("%s Export complete", IconSuccess); got != "[ok] Export complete" {
+		t.Errorf("ASCII output = %q", got)
+	}
+	if err := SetIconSet("Nerd"); err != nil || IconCheck.String() != "" {
+		t.Errorf("SetIconSet(Nerd) = %v, check = %q", err, IconCheck.String())
+	}
+
+	if err := SetIconSet("fancy"); err == nil {
+		t.Error("expected an error for an unknown icon set")
+	}
+	if IconCheck.String() != "" {
+		t.Error("an unknown icon set must keep the current one")
+	}
+	if err := ValidateIconSet("auto"); err != nil {
+		t.Errorf("ValidateIconSet(auto) = %v", err)
+	}
+}
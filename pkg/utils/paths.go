@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrPathTraversal is returned for paths escaping the directory they
+	// must stay in
+	ErrPathTraversal = errors.New("path escapes its directory")
+	// ErrSymlinkedOutput is returned for output paths that are symlinks
+	ErrSymlinkedOutput = errors.New("output path is a symlink")
+)
+
+// AllowSymlinks lets output files and directories be symlinks. It is off by
+// default, so a link planted in the download or export directory cannot
+// redirect writes elsewhere; --allow-symlinks turns it on.
+var AllowSymlinks = false
+
+// SafeJoin joins elems to base like filepath.Join, failing with
+// ErrPathTraversal when the result is outside base, e.g. for names holding
+// ".." or absolute paths
+func SafeJoin(base string, elems ...string) (string, error) {
+	joined := filepath.Join(append([]string{base}, elems...)...)
+	if !IsWithin(base, joined) {
+		return "", fmt.Errorf("%w: %s", ErrPathTraversal, filepath.Join(elems...))
+	}
+	return joined, nil
+}
+
+// IsWithin reports whether path is base or inside it, lexically
+func IsWithin(base, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(base), filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// CheckOutputPath fails with ErrSymlinkedOutput when the file at path, or
+// its directory, is a symlink, unless AllowSymlinks is set. Missing files
+// and directories are fine, they are created as regular ones.
+func CheckOutputPath(path string) error {
+	if err := CheckOutputDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	return checkNotSymlink(path)
+}
+
+// CheckOutputDir fails with ErrSymlinkedOutput when dir is a symlink, unless
+// AllowSymlinks is set. A missing dir is created in its nearest existing
+// parent, which must then not be a symlink either. Either way, the
+// directory written to must be one and be writable.
+func CheckOutputDir(dir string) error {
+	if err := checkNotSymlink(dir); err != nil {
+		return err
+	}
+
+	existing := dir
+	for {
+		info, err := os.Stat(existing)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("not a directory: %s", existing)
+			}
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return nil
+		}
+		existing = parent
+	}
+	if existing != dir {
+		if err := checkNotSymlink(existing); err != nil {
+			return err
+		}
+	}
+	return checkWritable(existing)
+}
+
+// checkWritable fails when no file can be created in dir
+func checkWritable(dir string) error {
+	probe, err := os.CreateTemp(dir, ".mangas-write-*")
+	if err != nil {
+		return fmt.Errorf("directory not writable: %w", err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+func checkNotSymlink(path string) error {
+	if AllowSymlinks {
+		return nil
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("%w: %s (use --allow-symlinks to write through it)", ErrSymlinkedOutput, path)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSafeJoin(t *testing.T) {
+	base := filepath.Join("downloads", "mangas")
+	tests := []struct {
+		elems []string
+		want  string
+		err   bool
+	}{
+		{[]string{"One Piece", "ch_1.epub"}, filepath.Join(base, "One Piece", "ch_1.epub"), false},
+		{[]string{"a/../b"}, filepath.Join(base, "b"), false},
+		{[]string{".."}, "", true},
+		{[]string{"..", "mangas-evil"}, "", true},
+		{[]string{"series", "../../../etc/passwd"}, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := SafeJoin(base, tt.elems...)
+		if tt.err {
+			if !errors.Is(err, ErrPathTraversal) {
+				t.Errorf("SafeJoin(%q) error = %v, want ErrPathTraversal", tt.elems, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("SafeJoin(%q) = %q, %v, want %q", tt.elems, got, err, tt.want)
+		}
+	}
+}
+
+func TestIsWithin(t *testing.T) {
+	tests := []struct {
+		base, path string
+		want       bool
+	}{
+		{"/tmp/out", "/tmp/out", true},
+		{"/tmp/out", "/tmp/out/a/b.png", true},
+		{"/tmp/out", "/tmp/out/../out2/x", false},
+		{"/tmp/out", "/tmp/outside", false},
+		{"/tmp/out", "/etc/passwd", false},
+		{"/tmp/out", "/tmp/out/..file", true},
+	}
+
+	for _, tt := range tests {
+		if got := IsWithin(tt.base, tt.path); got != tt.want {
+			t.Errorf("IsWithin(%q, %q) = %v, want %v", tt.base, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCheckOutputPath(t *testing.T) {
+	dir := t.TempDir()
+	target := t.TempDir()
+	link := filepath.Join(dir, "linked")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	fileLink := filepath.Join(dir, "book.epub")
+	if err := os.Symlink(filepath.Join(target, "book.epub"), fileLink); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CheckOutputPath(filepath.Join(dir, "new", "book.epub")); err != nil {
+		t.Errorf("missing path: %v", err)
+	}
+	if err := CheckOutputPath(filepath.Join(link, "book.epub")); !errors.Is(err, ErrSymlinkedOutput) {
+		t.Errorf("symlinked directory: error = %v, want ErrSymlinkedOutput", err)
+	}
+	if err := CheckOutputPath(fileLink); !errors.Is(err, ErrSymlinkedOutput) {
+		t.Errorf("symlinked file: error = %v, want ErrSymlinkedOutput", err)
+	}
+
+	AllowSymlinks = true
+	defer func() { AllowSymlinks = false }()
+	if err := CheckOutputPath(filepath.Join(link, "book.epub")); err != nil {
+		t.Errorf("symlinks allowed: %v", err)
+	}
+}
+
+func TestCheckOutputDir(t *testing.T) {
+	dir := t.TempDir()
+	target := t.TempDir()
+	link := filepath.Join(dir, "linked")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CheckOutputDir(filepath.Join(dir, "new", "nested")); err != nil {
+		t.Errorf("missing directories: %v", err)
+	}
+	if err := CheckOutputDir(filepath.Join(link, "new", "nested")); !errors.Is(err, ErrSymlinkedOutput) {
+		t.Errorf("missing directories in a symlink: error = %v, want ErrSymlinkedOutput", err)
+	}
+	if err := CheckOutputDir(filepath.Join(file, "new")); err == nil {
+		t.Error("Expected an error for a parent that is a file")
+	}
+
+	readOnly := filepath.Join(dir, "read-only")
+	if err := os.Mkdir(readOnly, 0o555); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkWritable(readOnly); err == nil {
+		t.Skip("permissions are not enforced for this user")
+	}
+	if err := CheckOutputDir(filepath.Join(readOnly, "new")); err == nil {
+		t.Error("Expected an error for a parent that is not writable")
+	}
+}
@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostLimiter rate limits requests per host with token buckets. Hosts
+// without a limit are not throttled, but every host honours the backoffs
+// servers ask for with rate-limit headers.
+type HostLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	shared  *sharedBudget // Budgets shared with other processes, if set
+}
+
+type bucket struct {
+	rate    float64   // Tokens added per second, 0 for no limit
+	burst   float64   // Bucket capacity
+	tokens  float64   // Available tokens, negative when requests are queued
+	last    time.Time // Last refill
+	blocked time.Time // No request before this time
+}
+
+// SharedLimiter is the limiter shared by the sources' API clients and the
+// image downloader, so they draw from the same budget per host
+var SharedLimiter = NewHostLimiter()
+
+func init() {
+	// MangaDex allows about 5 requests per second per client
+	SharedLimiter.SetLimit("api.mangadex.org", 5, 5)
+	// Comick throttles its API harder than its image host
+	SharedLimiter.SetLimit("api.comick.fun", 3, 3)
+	SharedLimiter.SetLimit("meo.comick.pictures", 10, 10)
+}
+
+// NewHostLimiter creates a limiter without any host limit
+func NewHostLimiter() *HostLimiter {
+	return &HostLimiter{buckets: make(map[string]*bucket)}
+}
+
+// SetLimit allows perSecond requests per second to host, with bursts of up to
+// burst requests. A non-positive rate removes the limit.
+func (l *HostLimiter) SetLimit(host string, perSecond float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.bucket(host)
+	if perSecond <= 0 {
+		b.rate, b.burst, b.tokens = 0, 0, 0
+		return
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	b.rate, b.burst, b.tokens = perSecond, float64(burst), float64(burst)
+	b.last = time.Now()
+}
+
+// ShareBudgets coordinates the limits and backoffs of the limited hosts
+// with the other processes sharing dir, so running the TUI and a download
+// side by side stays within a source's limits. Requests fall back to this
+// process's budget when dir can't be used.
+func (l *HostLimiter) ShareBudgets(dir string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.shared = &sharedBudget{dir: dir}
+}
+
+// Wait blocks until a request to rawURL's host is allowed
+func (l *HostLimiter) Wait(rawURL string) {
+	host := hostOf(rawURL)
+	if host == "" {
+		return
+	}
+
+	l.mu.Lock()
+	b, ok := l.buckets[host]
+	if !ok {
+		l.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	if shared := l.shared; shared != nil {
+		rate, burst := b.rate, b.burst
+		l.mu.Unlock()
+		if wait, err := shared.reserve(host, rate, burst, now); err == nil {
+			time.Sleep(wait)
+			return
+		}
+		l.mu.Lock()
+	}
+	var wait time.Duration
+	if b.rate > 0 {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+		b.tokens--
+		if b.tokens < 0 {
+			wait = time.Duration(-b.tokens / b.rate * float64(time.Second))
+		}
+	}
+	if blocked := b.blocked.Sub(now); blocked > wait {
+		wait = blocked
+	}
+	l.mu.Unlock()
+
+	time.Sleep(wait)
+}
+
+// Backoff holds the requests to rawURL's host until the given time
+func (l *HostLimiter) Backoff(rawURL string, until time.Time) {
+	host := hostOf(rawURL)
+	if host == "" {
+		return
+	}
+
+	l.mu.Lock()
+	if b := l.bucket(host); until.After(b.blocked) {
+		b.blocked = until
+	}
+	shared := l.shared
+	l.mu.Unlock()
+
+	if shared != nil {
+		shared.backoff(host, until)
+	}
+}
+
+// Observe backs off the host of a response when the server asks for it: on
+// 429 Too Many Requests with a Retry-After, or when the X-RateLimit headers
+// report the budget as spent. It reports whether a backoff was applied.
+func (l *HostLimiter) Observe(resp *http.Response) bool {
+	if resp == nil || resp.Request == nil || resp.Request.URL == nil {
+		return false
+	}
+
+	until, ok := RetryAfter(resp.Header, time.Now())
+	if !ok {
+		return false
+	}
+	if resp.StatusCode != http.StatusTooManyRequests && strings.TrimSpace(resp.Header.Get("X-RateLimit-Remaining")) != "0" {
+		return false
+	}
+	l.Backoff(resp.Request.URL.String(), until)
+	return true
+}
+
+// bucket returns the bucket of host, creating it when needed. The caller
+// must hold the lock.
+func (l *HostLimiter) bucket(host string) *bucket {
+	b, ok := l.buckets[host]
+	if !ok {
+		b = &bucket{last: time.Now()}
+		l.buckets[host] = b
+	}
+	return b
+}
+
+// RetryAfter returns when requests may resume according to the Retry-After
+// header (seconds or an HTTP date) or MangaDex's X-RateLimit-Retry-After
+// (a Unix timestamp)
+func RetryAfter(header http.Header, now time.Time) (time.Time, bool) {
+	if value := strings.TrimSpace(header.Get("Retry-After")); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			return now.Add(time.Duration(seconds) * time.Second), true
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			return date, true
+		}
+	}
+	if value := strings.TrimSpace(header.Get("X-RateLimit-Retry-After")); value != "" {
+		if unix, err := strconv.ParseInt(value, 10, 64); err == nil && unix > 0 {
+			return time.Unix(unix, 0), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHostLimiter_Wait(t *testing.T) {
+	limiter := NewHostLimiter()
+	limiter.SetLimit("slow.example.com", 20, 2) // Bursts of 2, then 50ms apart
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		limiter.Wait("https://fast.example.com/page.png")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Millisecond {
+		t.Errorf("unlimited host took %v", elapsed)
+	}
+
+	start = time.Now()
+	for i := 0; i < 4; i++ {
+		limiter.Wait("https://slow.example.com:8443/page.png")
+	}
+	// The burst goes out at once, the next two wait 50ms each
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("limited host took %v, want about 100ms", elapsed)
+	}
+}
+
+func TestHostLimiter_Backoff(t *testing.T) {
+	limiter := NewHostLimiter()
+	limiter.Backoff("https://busy.example.com/api", time.Now().Add(100*time.Millisecond))
+
+	start := time.Now()
+	limiter.Wait("https://busy.example.com/other")
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("Wait() returned after %v, want the 100ms backoff", elapsed)
+	}
+
+	start = time.Now()
+	limiter.Wait("https://busy.example.com/other")
+	if elapsed := time.Since(start); elapsed > 10*time.Millisecond {
+		t.Errorf("Wait() after the backoff took %v", elapsed)
+	}
+}
+
+func TestHostLimiter_Observe(t *testing.T) {
+	request, _ := http.NewRequest("GET", "https://api.example.com/manga", nil)
+	response := func(status int, header http.Header) *http.Response {
+		return &http.Response{StatusCode: status, Header: header, Request: request}
+	}
+
+	limiter := NewHostLimiter()
+	if limiter.Observe(response(http.StatusTooManyRequests, http.Header{})) {
+		t.Error("Expected no backoff without a Retry-After")
+	}
+	if limiter.Observe(response(http.StatusOK, http.Header{"X-Ratelimit-Remaining": {"3"}, "X-Ratelimit-Retry-After": {"9999999999"}})) {
+		t.Error("Expected no backoff while requests remain")
+	}
+	if !limiter.Observe(response(http.StatusTooManyRequests, http.Header{"Retry-After": {"0"}})) {
+		t.Error("Expected a backoff for 429 with a Retry-After")
+	}
+
+	until := time.Now().Add(time.Hour).Unix()
+	header := http.Header{}
+	header.Set("X-RateLimit-Remaining", "0")
+	header.Set("X-RateLimit-Retry-After", strconv.FormatInt(until, 10))
+	if !limiter.Observe(response(http.StatusOK, header)) {
+		t.Fatal("Expected a backoff once the budget is spent")
+	}
+	if blocked := limiter.buckets["api.example.com"].blocked; blocked.Unix() != until {
+		t.Errorf("Blocked until %v, want %v", blocked, time.Unix(until, 0))
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Time
+		ok     bool
+	}{
+		{"seconds", http.Header{"Retry-After": {"30"}}, now.Add(30 * time.Second), true},
+		{"http date", http.Header{"Retry-After": {"Mon, 01 Jan 2024 12:01:00 GMT"}}, now.Add(time.Minute), true},
+		{"mangadex", http.Header{"X-Ratelimit-Retry-After": {strconv.FormatInt(now.Add(5*time.Second).Unix(), 10)}}, now.Add(5 * time.Second), true},
+		{"invalid", http.Header{"Retry-After": {"soon"}}, time.Time{}, false},
+		{"missing", http.Header{}, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := RetryAfter(tt.header, now)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("%s: RetryAfter() = %v, %v, want %v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestHostLimiter_ShareBudgets(t *testing.T) {
+	// Two processes limited to 20 requests per second between them
+	dir := t.TempDir()
+	first, second := NewHostLimiter(), NewHostLimiter()
+	for _, limiter := range []*HostLimiter{first, second} {
+		limiter.SetLimit("api.example.com", 20, 1)
+		limiter.ShareBudgets(dir)
+	}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		first.Wait("https://api.example.com/manga")
+		second.Wait("https://api.example.com/manga")
+	}
+	// The first request goes out at once, the next five wait 50ms each
+	if elapsed := time.Since(start); elapsed < 240*time.Millisecond || elapsed > 1500*time.Millisecond {
+		t.Errorf("shared budget took %v, want about 250ms", elapsed)
+	}
+
+	// A backoff asked by the server holds the other process too
+	first.Backoff("https://api.example.com/manga", time.Now().Add(150*time.Millisecond))
+	start = time.Now()
+	second.Wait("https://api.example.com/chapter")
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Errorf("Wait() returned after %v, want the 150ms backoff of the other process", elapsed)
+	}
+}
+
+func TestHostLimiter_ShareBudgetsFallback(t *testing.T) {
+	// An unusable directory falls back to the budget of the process
+	file := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(file, nil, 0644)
+	limiter := NewHostLimiter()
+	limiter.SetLimit("api.example.com", 20, 1)
+	limiter.ShareBudgets(filepath.Join(file, "ratelimit"))
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		limiter.Wait("https://api.example.com/manga")
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("fallback budget took %v, want about 100ms", elapsed)
+	}
+}
+
+func TestLockFile_BreaksStaleLocks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "host.lock")
+	os.WriteFile(path, nil, 0644)
+	old := time.Now().Add(-2 * leaseLockTimeout)
+	os.Chtimes(path, old, old)
+
+	unlock, err := lockFile(path)
+	if err != nil {
+		t.Fatalf("lockFile() error = %v", err)
+	}
+	unlock()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected the lock removed on unlock")
+	}
+}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Lease files coordinating the budget of a host across processes
+const (
+	leaseLockTimeout = 2 * time.Second // Locks older than this were left by a crashed process
+	leaseLockPoll    = time.Millisecond
+)
+
+// sharedBudget keeps the rate limit state of each host in a file of dir, so
+// every mangas process (the TUI, a download in another terminal, 'mangas
+// serve', ...) draws from the same budget. Each file holds the theoretical
+// arrival time of the next request and the backoff deadline, and is only
+// changed while holding its lock file.
+type sharedBudget struct {
+	dir string
+}
+
+// leaseState is the content of a host's lease file
+type leaseState struct {
+	next    time.Time // Theoretical arrival time of the next request
+	blocked time.Time // No request before this time
+}
+
+// reserve books the next request to host allowed by rate and burst, and
+// returns how long to wait for it. A zero rate only honours the backoff.
+func (s *sharedBudget) reserve(host string, rate, burst float64, now time.Time) (time.Duration, error) {
+	var wait time.Duration
+	err := s.update(host, func(state *leaseState) {
+		at := now
+		if rate > 0 {
+			// Generic cell rate algorithm: requests are spaced by interval,
+			// up to burst of them may go ahead of schedule
+			interval := time.Duration(float64(time.Second) / rate)
+			if state.next.Before(now) {
+				state.next = now
+			}
+			if allowed := state.next.Add(-time.Duration(burst-1) * interval); allowed.After(at) {
+				at = allowed
+			}
+			state.next = state.next.Add(interval)
+		}
+		if state.blocked.After(at) {
+			at = state.blocked
+		}
+		wait = at.Sub(now)
+	})
+	return wait, err
+}
+
+// backoff holds the requests of every process to host until the given time
+func (s *sharedBudget) backoff(host string, until time.Time) error {
+	return s.update(host, func(state *leaseState) {
+		if until.After(state.blocked) {
+			state.blocked = until
+		}
+	})
+}
+
+// update changes the lease state of host while holding its lock
+func (s *sharedBudget) update(host string, change func(*leaseState)) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, strings.ReplaceAll(host, ":", "_"))
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	state := readLease(path)
+	change(&state)
+	content := fmt.Sprintf("%d %d\n", state.next.UnixNano(), state.blocked.UnixNano())
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// readLease reads a lease file, a missing or damaged one is a fresh state
+func readLease(path string) leaseState {
+	var state leaseState
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return state
+	}
+	fields := strings.Fields(string(content))
+	if len(fields) != 2 {
+		return state
+	}
+	if next, err := strconv.ParseInt(fields[0], 10, 64); err == nil && next > 0 {
+		state.next = time.Unix(0, next)
+	}
+	if blocked, err := strconv.ParseInt(fields[1], 10, 64); err == nil && blocked > 0 {
+		state.blocked = time.Unix(0, blocked)
+	}
+	return state
+}
+
+// lockFile takes an exclusive lock by creating path, waiting while another
+// process holds it. Locks left by crashed processes are broken after
+// leaseLockTimeout.
+func lockFile(path string) (func(), error) {
+	deadline := time.Now().Add(2 * leaseLockTimeout)
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > leaseLockTimeout {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for %s", path)
+		}
+		time.Sleep(leaseLockPoll)
+	}
+}
@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A source is degraded once enough requests were made and too many of them
+// failed or were slow
+const (
+	degradedMinRequests = 10
+	degradedFailureRate = 0.5
+	degradedLatency     = 10 * time.Second
+)
+
+// SourceCounters aggregates the requests made to a source
+type SourceCounters struct {
+	Requests int64
+	Failures int64
+	Latency  time.Duration // Total, see AverageLatency
+	Bytes    int64
+}
+
+// Add adds other to the counters
+func (c *SourceCounters) Add(other SourceCounters) {
+	c.Requests += other.Requests
+	c.Failures += other.Failures
+	c.Latency += other.Latency
+	c.Bytes += other.Bytes
+}
+
+// AverageLatency returns the mean time a request took
+func (c SourceCounters) AverageLatency() time.Duration {
+	if c.Requests == 0 {
+		return 0
+	}
+	return c.Latency / time.Duration(c.Requests)
+}
+
+// FailureRate returns the share of requests that failed, from 0 to 1
+func (c SourceCounters) FailureRate() float64 {
+	if c.Requests == 0 {
+		return 0
+	}
+	return float64(c.Failures) / float64(c.Requests)
+}
+
+// Degraded tells why the counters show a source working badly, or returns
+// "" when it works fine or too few requests were made to tell
+func (c SourceCounters) Degraded() string {
+	if c.Requests < degradedMinRequests {
+		return ""
+	}
+	if rate := c.FailureRate(); rate >= degradedFailureRate {
+		return fmt.Sprintf("%.0f%% of %d requests failed", rate*100, c.Requests)
+	}
+	if latency := c.AverageLatency(); latency >= degradedLatency {
+		return fmt.Sprintf("requests take %s on average", latency.Round(100*time.Millisecond))
+	}
+	return ""
+}
+
+// Stats collects the requests made to the sources by the running process
+var Stats = NewSourceStats()
+
+// SourceStats collects request counters per source. Counters are kept for
+// the whole session, to spot degraded sources, and separately since the last
+// Take, to be stored.
+type SourceStats struct {
+	mu         sync.Mutex
+	session    map[string]*SourceCounters
+	pending    map[string]*SourceCounters
+	warned     map[string]bool
+	onDegraded func(source, reason string)
+}
+
+// NewSourceStats returns an empty collector
+func NewSourceStats() *SourceStats {
+	return &SourceStats{
+		session: make(map[string]*SourceCounters),
+		pending: make(map[string]*SourceCounters),
+		warned:  make(map[string]bool),
+	}
+}
+
+// OnDegraded sets the function called the first time a source is degraded
+// during the session, see SourceCounters.Degraded
+func (s *SourceStats) OnDegraded(fn func(source, reason string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onDegraded = fn
+}
+
+// Record counts a request to source that took latency and received bytes,
+// failed when err is not nil
+func (s *SourceStats) Record(source string, latency time.Duration, bytes int64, err error) {
+	request := SourceCounters{Requests: 1, Latency: latency, Bytes: bytes}
+	if err != nil {
+		request.Failures = 1
+	}
+
+	s.mu.Lock()
+	for _, counters := range []map[string]*SourceCounters{s.session, s.pending} {
+		if counters[source] == nil {
+			counters[source] = &SourceCounters{}
+		}
+		counters[source].Add(request)
+	}
+	reason := s.session[source].Degraded()
+	notify := s.onDegraded
+	if reason == "" || s.warned[source] {
+		notify = nil
+	}
+	if notify != nil {
+		s.warned[source] = true
+	}
+	s.mu.Unlock()
+
+	if notify != nil {
+		notify(source, reason)
+	}
+}
+
+// Session returns the counters of the session by source
+func (s *SourceStats) Session() map[string]SourceCounters {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyCounters(s.session)
+}
+
+// Take returns the counters recorded since the last Take by source, and
+// resets them
+func (s *SourceStats) Take() map[string]SourceCounters {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	taken := copyCounters(s.pending)
+	s.pending = make(map[string]*SourceCounters)
+	return taken
+}
+
+// SourceNames returns the sources of counters, sorted
+func SourceNames(counters map[string]SourceCounters) []string {
+	names := make([]string, 0, len(counters))
+	for name := range counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func copyCounters(counters map[string]*SourceCounters) map[string]SourceCounters {
+	copied := make(map[string]SourceCounters, len(counters))
+	for source, c := range counters {
+		copied[source] = *c
+	}
+	return copied
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSourceCountersDegraded(t *testing.T) {
+	tests := []struct {
+		name     string
+		counters SourceCounters
+		want     string
+	}{
+		{"healthy", SourceCounters{Requests: 20, Failures: 2, Latency: 20 * time.Second}, ""},
+		{"too few requests", SourceCounters{Requests: 3, Failures: 3}, ""},
+		{"failing", SourceCounters{Requests: 10, Failures: 6, Latency: time.Second}, "60% of 10 requests failed"},
+		{"slow", SourceCounters{Requests: 10, Latency: 150 * time.Second}, "requests take 15s on average"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.counters.Degraded(); got != tt.want {
+				t.Errorf("Degraded() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourceStats(t *testing.T) {
+	stats := NewSourceStats()
+	var alerts []string
+	stats.OnDegraded(func(source, reason string) {
+		alerts = append(alerts, source+": "+reason)
+	})
+
+	failure := errors.New("timeout")
+	for i := 0; i < 12; i++ {
+		stats.Record("mangadex", 100*time.Millisecond, 1000, failure)
+	}
+	stats.Record("comick", time.Second, 50, nil)
+
+	if len(alerts) != 1 || !strings.HasPrefix(alerts[0], "mangadex: 100% of 10") {
+		t.Errorf("Expected a single alert for mangadex, got %v", alerts)
+	}
+
+	taken := stats.Take()
+	if got := taken["mangadex"]; got.Requests != 12 || got.Failures != 12 || got.Bytes != 12000 || got.AverageLatency() != 100*time.Millisecond {
+		t.Errorf("Unexpected mangadex counters: %+v", got)
+	}
+	if names := SourceNames(taken); len(names) != 2 || names[0] != "comick" {
+		t.Errorf("SourceNames() = %v", names)
+	}
+
+	// Taking resets the counters to store, not those of the session
+	stats.Record("comick", time.Second, 50, nil)
+	if taken := stats.Take(); taken["comick"].Requests != 1 || len(taken) != 1 {
+		t.Errorf("Expected only the new request, got %+v", taken)
+	}
+	if session := stats.Session(); session["comick"].Requests != 2 || session["mangadex"].Requests != 12 {
+		t.Errorf("Expected the session counters to be kept, got %+v", session)
+	}
+}
+
+func TestSourceAPIRecordsStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"result":"ok"}`))
+	}))
+	defer server.Close()
+
+	previous := Stats
+	Stats = NewSourceStats()
+	defer func() { Stats = previous }()
+
+	api := NewSourceAPI("test", server.URL)
+	var resp struct{ Result string }
+	api.Get("/manga", nil, &resp)
+	api.Get("/missing", nil, &resp)
+	NewAPI(server.URL).Get("/manga", nil, &resp) // Not a source, not counted
+
+	got := Stats.Session()["test"]
+	if got.Requests != 2 || got.Failures != 1 || got.Bytes != int64(len(`{"result":"ok"}`)) {
+		t.Errorf("Unexpected counters: %+v", got)
+	}
+	if len(Stats.Session()) != 1 {
+		t.Errorf("Expected only the source to be counted, got %+v", Stats.Session())
+	}
+}
@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ownerFilename holds the PID of the run owning a workspace
+const ownerFilename = "owner.pid"
+
+// Temp is the temporary file manager of the running process, its workspace
+// is removed when the command ends (see Cleanup) and by the next run when
+// the process crashed
+var Temp = NewTempManager(filepath.Join(os.TempDir(), "mangas-work"))
+
+// TempManager hands out temporary directories and files, all inside one
+// workspace per run under root, and tracks them so nothing is left behind:
+// artifacts can be released one by one, Cleanup removes the workspace, and
+// RecoverStale removes the workspaces of runs that crashed.
+type TempManager struct {
+	mu        sync.Mutex
+	root      string
+	workspace string
+	artifacts map[string]bool
+}
+
+// NewTempManager returns a manager keeping its workspaces under root. The
+// workspace itself is created on first use.
+func NewTempManager(root string) *TempManager {
+	return &TempManager{root: root, artifacts: make(map[string]bool)}
+}
+
+// Workspace returns the directory of the run, creating it if needed
+func (m *TempManager) Workspace() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ensureWorkspace()
+}
+
+func (m *TempManager) ensureWorkspace() (string, error) {
+	if m.workspace != "" {
+		return m.workspace, nil
+	}
+	if err := os.MkdirAll(m.root, 0755); err != nil {
+		return "", fmt.Errorf("failed to create temp root: %w", err)
+	}
+	workspace, err := os.MkdirTemp(m.root, fmt.Sprintf("run-%d-*", os.Getpid()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp workspace: %w", err)
+	}
+	owner := []byte(strconv.Itoa(os.Getpid()))
+	if err := os.WriteFile(filepath.Join(workspace, ownerFilename), owner, 0644); err != nil {
+		os.RemoveAll(workspace)
+		return "", fmt.Errorf("failed to write temp workspace owner: %w", err)
+	}
+	m.workspace = workspace
+	return workspace, nil
+}
+
+// MkdirTemp creates a directory in the workspace, like os.MkdirTemp
+func (m *TempManager) MkdirTemp(pattern string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	workspace, err := m.ensureWorkspace()
+	if err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp(workspace, pattern)
+	if err != nil {
+		return "", err
+	}
+	m.artifacts[dir] = true
+	return dir, nil
+}
+
+// CreateTemp creates a file in the workspace, like os.CreateTemp
+func (m *TempManager) CreateTemp(pattern string) (*os.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	workspace, err := m.ensureWorkspace()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.CreateTemp(workspace, pattern)
+	if err != nil {
+		return nil, err
+	}
+	m.artifacts[file.Name()] = true
+	return file, nil
+}
+
+// CacheDir returns the directory name under root, created if needed. Unlike
+// the workspace it is kept across runs, for work an interrupted run can
+// resume, and its owner removes it once done.
+func (m *TempManager) CacheDir(name string) (string, error) {
+	dir, err := SafeJoin(filepath.Join(m.root, "cache"), name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return dir, nil
+}
+
+// Release removes an artifact as soon as it is no longer needed
+func (m *TempManager) Release(path string) error {
+	m.mu.Lock()
+	delete(m.artifacts, path)
+	m.mu.Unlock()
+	return os.RemoveAll(path)
+}
+
+// Artifacts returns the artifacts not released yet, sorted
+func (m *TempManager) Artifacts() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	artifacts := make([]string, 0, len(m.artifacts))
+	for path := range m.artifacts {
+		artifacts = append(artifacts, path)
+	}
+	sort.Strings(artifacts)
+	return artifacts
+}
+
+// Cleanup removes the workspace with every artifact left in it. The
+// manager can be used again afterwards, with a new workspace.
+func (m *TempManager) Cleanup() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.workspace == "" {
+		return nil
+	}
+	err := os.RemoveAll(m.workspace)
+	m.workspace = ""
+	m.artifacts = make(map[string]bool)
+	return err
+}
+
+// RecoverStale removes the workspaces left by runs that are no longer
+// running, e.g. after a crash, and returns how many were removed.
+// Workspaces without an owner are removed once older than an hour.
+func (m *TempManager) RecoverStale() (int, error) {
+	entries, err := os.ReadDir(m.root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	m.mu.Lock()
+	current := m.workspace
+	m.mu.Unlock()
+
+	removed := 0
+	var errs []error
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), "run-") {
+			continue
+		}
+		workspace := filepath.Join(m.root, entry.Name())
+		if workspace == current || !staleWorkspace(workspace) {
+			continue
+		}
+		if err := os.RemoveAll(workspace); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed++
+	}
+	return removed, errors.Join(errs...)
+}
+
+// staleWorkspace reports whether the run owning workspace is gone
+func staleWorkspace(workspace string) bool {
+	content, err := os.ReadFile(filepath.Join(workspace, ownerFilename))
+	if err != nil {
+		// Owner not written yet, or lost: only old workspaces are stale
+		info, err := os.Stat(workspace)
+		return err == nil && time.Since(info.ModTime()) > time.Hour
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return true
+	}
+	return pid != os.Getpid() && !processAlive(pid)
+}
+
+// processAlive reports whether a process with the PID is running. Signal 0
+// only checks for its existence.
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return !errors.Is(process.Signal(syscall.Signal(0)), os.ErrProcessDone)
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTempManager(t *testing.T) {
+	manager := NewTempManager(t.TempDir())
+
+	dir, err := manager.MkdirTemp("epub-*")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	file, err := manager.CreateTemp("cover-*.jpg")
+	if err != nil {
+		t.Fatalf("CreateTemp() error = %v", err)
+	}
+	file.Close()
+
+	workspace, _ := manager.Workspace()
+	for _, path := range []string{dir, file.Name()} {
+		if filepath.Dir(path) != workspace {
+			t.Errorf("Expected %s in the workspace %s", path, workspace)
+		}
+	}
+	if got := manager.Artifacts(); len(got) != 2 {
+		t.Errorf("Expected 2 tracked artifacts, got %v", got)
+	}
+
+	if err := manager.Release(dir); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Error("Expected released directory to be removed")
+	}
+	if got := manager.Artifacts(); len(got) != 1 || got[0] != file.Name() {
+		t.Errorf("Expected only the file to be tracked, got %v", got)
+	}
+
+	if err := manager.Cleanup(); err != nil {
+		t.Fatalf("Cleanup() error = %v", err)
+	}
+	if _, err := os.Stat(workspace); !os.IsNotExist(err) {
+		t.Error("Expected the workspace to be removed on Cleanup")
+	}
+	if len(manager.Artifacts()) != 0 {
+		t.Error("Expected no artifacts after Cleanup")
+	}
+
+	// A new workspace is created when used again
+	if _, err := manager.MkdirTemp("ocr-*"); err != nil {
+		t.Fatalf("MkdirTemp() after Cleanup error = %v", err)
+	}
+	manager.Cleanup()
+}
+
+func TestTempManagerRecoverStale(t *testing.T) {
+	root := t.TempDir()
+	manager := NewTempManager(root)
+	current, err := manager.Workspace()
+	if err != nil {
+		t.Fatalf("Workspace() error = %v", err)
+	}
+
+	workspace := func(name, owner string, age time.Duration) string {
+		dir := filepath.Join(root, name)
+		os.MkdirAll(filepath.Join(dir, "epub-1"), 0755)
+		if owner != "" {
+			os.WriteFile(filepath.Join(dir, ownerFilename), []byte(owner), 0644)
+		}
+		old := time.Now().Add(-age)
+		os.Chtimes(dir, old, old)
+		return dir
+	}
+
+	// A PID past the usual limits is not running
+	crashed := workspace("run-999999999-1", "999999999", 0)
+	// Another live run, this process's parent
+	alive := workspace("run-live-1", strconv.Itoa(os.Getppid()), 0)
+	unowned := workspace("run-unowned-1", "", 2*time.Hour)
+	fresh := workspace("run-fresh-1", "", 0)
+	other := workspace("kindle-cache", "", 2*time.Hour)
+
+	removed, err := manager.RecoverStale()
+	if err != nil {
+		t.Fatalf("RecoverStale() error = %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("Expected 2 stale workspaces removed, got %d", removed)
+	}
+	for _, dir := range []string{crashed, unowned} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("Expected stale %s to be removed", filepath.Base(dir))
+		}
+	}
+	for _, dir := range []string{current, alive, fresh, other} {
+		if _, err := os.Stat(dir); err != nil {
+			t.Errorf("Expected %s to be kept: %v", filepath.Base(dir), err)
+		}
+	}
+}
+
+func TestTempManagerCacheDir(t *testing.T) {
+	root := t.TempDir()
+	manager := NewTempManager(root)
+
+	dir, err := manager.CacheDir(filepath.Join("kindle", "kindle-oasis3"))
+	if err != nil {
+		t.Fatalf("CacheDir() error = %v", err)
+	}
+	if !IsWithin(root, dir) {
+		t.Errorf("Expected %s under %s", dir, root)
+	}
+	if _, err := manager.CacheDir("../outside"); err == nil {
+		t.Error("Expected a cache directory outside the root to be rejected")
+	}
+
+	// Kept across runs, unlike the workspace
+	manager.Workspace()
+	manager.Cleanup()
+	manager.RecoverStale()
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("Expected the cache directory to be kept: %v", err)
+	}
+}